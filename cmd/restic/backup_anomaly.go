@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/backup"
+)
+
+// anomalyWebhookTimeout limits the time to deliver an anomaly event.
+const anomalyWebhookTimeout = 30 * time.Second
+
+// anomalyEvent is sent as JSON to the URL given by --anomaly-webhook.
+type anomalyEvent struct {
+	MessageType string           `json:"message_type"` // "anomaly"
+	Time        time.Time        `json:"time"`
+	Hostname    string           `json:"hostname"`
+	Paths       []string         `json:"paths"`
+	SnapshotID  string           `json:"snapshot_id,omitempty"`
+	DryRun      bool             `json:"dry_run,omitempty"`
+	Anomalies   []backup.Anomaly `json:"anomalies"`
+}
+
+// detectAnomalies compares the statistics of the finished backup of targets
+// against the recent backups of the same targets stored in history. Each
+// anomaly is reported as a warning and, if configured, sent to the webhook.
+// Failing to deliver the event only results in a warning.
+func detectAnomalies(ctx context.Context, detector backup.AnomalyDetector, history *backup.History, targets []string, id restic.ID, summary *archiver.Summary, opts BackupOptions, gopts GlobalOptions) {
+	if history == nil {
+		return
+	}
+	current := backup.NewRunStats(summary)
+	anomalies := detector.Detect(history.Runs, current)
+	if len(anomalies) == 0 {
+		return
+	}
+
+	Warnf("WARNING: this backup changed much more data than the recent backups of the same targets.\n")
+	Warnf("The source data may have been encrypted or deleted, for example by ransomware:\n")
+	for _, a := range anomalies {
+		Warnf("  %v\n", a)
+	}
+
+	if opts.AnomalyWebhook == "" {
+		return
+	}
+	event := anomalyEvent{
+		MessageType: "anomaly",
+		Time:        current.Time,
+		Hostname:    opts.Host,
+		Paths:       targets,
+		DryRun:      opts.DryRun,
+		Anomalies:   anomalies,
+	}
+	if !id.IsNull() {
+		event.SnapshotID = id.String()
+	}
+	if err := sendAnomalyEvent(ctx, opts.AnomalyWebhook, event, gopts); err != nil {
+		Warnf("unable to send anomaly event to webhook: %v\n", err)
+	}
+}
+
+// sendAnomalyEvent posts event as JSON to url.
+func sendAnomalyEvent(ctx context.Context, url string, event anomalyEvent, gopts GlobalOptions) error {
+	buf, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	rt, err := backend.Transport(gopts.TransportOptions)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: rt, Timeout: anomalyWebhookTimeout}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("webhook returned %v", resp.Status)
+	}
+	return nil
+}
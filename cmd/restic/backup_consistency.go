@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// frozenFile is the metadata of a file at the consistency point.
+type frozenFile struct {
+	size       int64
+	modTime    time.Time
+	changeTime time.Time
+	inode      uint64
+}
+
+// consistencyGroup records the metadata of all files while the applications
+// are frozen and detects files which changed before they were backed up.
+type consistencyGroup struct {
+	ignoreFlags uint
+
+	m     sync.Mutex
+	files map[string]frozenFile
+}
+
+func newConsistencyGroup(ignoreFlags uint) *consistencyGroup {
+	return &consistencyGroup{
+		ignoreFlags: ignoreFlags,
+		files:       make(map[string]frozenFile),
+	}
+}
+
+// Record wraps the select function of the scanner which runs while the
+// applications are frozen, it records the metadata of all selected files.
+func (c *consistencyGroup) Record(selectFn archiver.SelectFunc) archiver.SelectFunc {
+	return func(item string, fi *fs.ExtendedFileInfo, filesys fs.FS) bool {
+		if !selectFn(item, fi, filesys) {
+			return false
+		}
+		if fi.Mode.IsRegular() {
+			c.m.Lock()
+			c.files[item] = frozenFile{
+				size:       fi.Size,
+				modTime:    fi.ModTime,
+				changeTime: fi.ChangeTime,
+				inode:      fi.Inode,
+			}
+			c.m.Unlock()
+		}
+		return true
+	}
+}
+
+// Check wraps the select function of the archiver. Files which were created
+// after the consistency point are excluded, modified files are still saved.
+// Both are reported using errorFn.
+func (c *consistencyGroup) Check(selectFn archiver.SelectFunc, errorFn func(item string, err error)) archiver.SelectFunc {
+	return func(item string, fi *fs.ExtendedFileInfo, filesys fs.FS) bool {
+		if !selectFn(item, fi, filesys) {
+			return false
+		}
+		if !fi.Mode.IsRegular() {
+			return true
+		}
+
+		c.m.Lock()
+		frozen, ok := c.files[item]
+		c.m.Unlock()
+
+		if !ok {
+			errorFn(item, errors.Errorf("%v: file was created after the consistency point, skipping", item))
+			return false
+		}
+		if c.changed(frozen, fi) {
+			errorFn(item, errors.Errorf("%v: file was modified after the consistency point", item))
+		}
+		return true
+	}
+}
+
+func (c *consistencyGroup) changed(frozen frozenFile, fi *fs.ExtendedFileInfo) bool {
+	switch {
+	case frozen.size != fi.Size:
+		return true
+	case !frozen.modTime.Equal(fi.ModTime):
+		return true
+	case c.ignoreFlags&archiver.ChangeIgnoreCtime == 0 && !frozen.changeTime.Equal(fi.ChangeTime):
+		return true
+	case c.ignoreFlags&archiver.ChangeIgnoreInode == 0 && frozen.inode != fi.Inode:
+		return true
+	}
+	return false
+}
+
+// runFrozen runs fn between the freeze and the thaw command. The thaw command
+// is always run once the freeze command was started, even if fn or the freeze
+// command failed or ctx was canceled.
+func runFrozen(ctx context.Context, freeze, thaw string, fn func() error) (err error) {
+	if thaw != "" {
+		defer func() {
+			// the applications must be thawed even if the backup was canceled
+			terr := runHookCommand(context.Background(), "thaw", thaw, nil, nil)
+			if err == nil {
+				err = terr
+			}
+		}()
+	}
+
+	if freeze != "" {
+		if err := runHookCommand(ctx, "freeze", freeze, nil, nil); err != nil {
+			return err
+		}
+	}
+	return fn()
+}
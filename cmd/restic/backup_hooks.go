@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/backup"
+)
+
+// runHookCommand runs a command configured for the backup, like the freeze
+// or the post command. env is added to the environment of the command and
+// stdin, if not nil, is passed as its standard input. Its output is written to
+// stderr such that it does not interfere with the JSON output on stdout.
+func runHookCommand(ctx context.Context, name, command string, env []string, stdin io.Reader) error {
+	args, err := backend.SplitShellStrings(command)
+	if err != nil {
+		return errors.Fatalf("invalid %v command: %v", name, err)
+	}
+	if len(args) == 0 {
+		return errors.Fatalf("%v command is empty", name)
+	}
+
+	debug.Log("running %v command %v", name, args)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = globalOptions.stderr
+	cmd.Stderr = globalOptions.stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Fatalf("%v command %q failed: %v", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// backupResult collects the outcome of a backup for the post command.
+type backupResult struct {
+	snapshotID restic.ID
+	summary    *archiver.Summary
+	dryRun     bool
+}
+
+// postCommandInput is passed as JSON to the standard input of the post command.
+type postCommandInput struct {
+	ExitStatus int                   `json:"exit_status"`
+	Error      string                `json:"error,omitempty"`
+	SnapshotID string                `json:"snapshot_id,omitempty"`
+	Summary    *backup.SummaryOutput `json:"summary,omitempty"`
+}
+
+// postCommandInput returns the information about the backup which is passed
+// to the post command. err is the error returned by the backup.
+func (r *backupResult) postCommandInput(err error) postCommandInput {
+	input := postCommandInput{ExitStatus: exitCodeFor(err)}
+	if err != nil {
+		input.Error = err.Error()
+	}
+	if !r.snapshotID.IsNull() {
+		input.SnapshotID = r.snapshotID.String()
+	}
+	if r.summary != nil {
+		summary := backup.NewSummaryOutput(r.snapshotID, r.summary, r.dryRun)
+		input.Summary = &summary
+	}
+	return input
+}
+
+// env returns the environment variables describing the backup for the post
+// command.
+func (in postCommandInput) env() []string {
+	env := []string{
+		fmt.Sprintf("RESTIC_BACKUP_EXIT_STATUS=%d", in.ExitStatus),
+		"RESTIC_BACKUP_ERROR=" + in.Error,
+		"RESTIC_SNAPSHOT_ID=" + in.SnapshotID,
+	}
+	if in.Summary != nil {
+		env = append(env,
+			fmt.Sprintf("RESTIC_BACKUP_FILES_NEW=%d", in.Summary.FilesNew),
+			fmt.Sprintf("RESTIC_BACKUP_FILES_CHANGED=%d", in.Summary.FilesChanged),
+			fmt.Sprintf("RESTIC_BACKUP_FILES_UNMODIFIED=%d", in.Summary.FilesUnmodified),
+			fmt.Sprintf("RESTIC_BACKUP_DATA_ADDED=%d", in.Summary.DataAdded),
+			fmt.Sprintf("RESTIC_BACKUP_TOTAL_BYTES_PROCESSED=%d", in.Summary.TotalBytesProcessed),
+			fmt.Sprintf("RESTIC_BACKUP_DURATION=%.3f", in.Summary.TotalDuration),
+		)
+	}
+	return env
+}
+
+// runPostCommand runs the post command after a backup which returned err. The
+// error of the post command is only returned if the backup was successful.
+func runPostCommand(command string, result *backupResult, err error) error {
+	input := result.postCommandInput(err)
+	buf, jerr := json.Marshal(input)
+	if jerr != nil {
+		return jerr
+	}
+
+	// the post command must also run if the backup was canceled
+	perr := runHookCommand(context.Background(), "post", command, input.env(), bytes.NewReader(buf))
+	if err != nil {
+		if perr != nil {
+			Warnf("%v\n", perr)
+		}
+		return err
+	}
+	return perr
+}
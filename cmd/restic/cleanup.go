@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"github.com/restic/restic/internal/debug"
@@ -19,9 +20,51 @@ func createGlobalContext() context.Context {
 	return ctx
 }
 
+var interruptHandler struct {
+	sync.Mutex
+	fn func()
+}
+
+// setInterruptHandler registers fn to be called on the first SIGINT signal
+// instead of canceling the global context. This allows a command to stop
+// gracefully and save the work done so far. A second signal or SIGTERM cancels
+// the context as usual, as SIGTERM is commonly followed by SIGKILL shortly
+// afterwards. The returned function removes the handler.
+func setInterruptHandler(fn func()) (remove func()) {
+	interruptHandler.Lock()
+	interruptHandler.fn = fn
+	interruptHandler.Unlock()
+
+	return func() {
+		interruptHandler.Lock()
+		interruptHandler.fn = nil
+		interruptHandler.Unlock()
+	}
+}
+
+// takeInterruptHandler returns and removes the registered interrupt handler.
+func takeInterruptHandler() func() {
+	interruptHandler.Lock()
+	defer interruptHandler.Unlock()
+
+	fn := interruptHandler.fn
+	interruptHandler.fn = nil
+	return fn
+}
+
 // cleanupHandler handles the SIGINT and SIGTERM signals.
 func cleanupHandler(c <-chan os.Signal, cancel context.CancelFunc) {
 	s := <-c
+	if s != syscall.SIGINT {
+		// SIGTERM aborts immediately
+		_ = takeInterruptHandler()
+	} else if fn := takeInterruptHandler(); fn != nil {
+		debug.Log("signal %v received, stopping gracefully", s)
+		Warnf("%ssignal %v received, finishing the files in progress, send the signal again to abort immediately\n", clearLine(0), s)
+		fn()
+		s = <-c
+	}
+
 	debug.Log("signal %v received, cleaning up", s)
 	Warnf("%ssignal %v received, cleaning up\n", clearLine(0), s)
 
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestCleanupHandlerInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupted := make(chan struct{})
+	remove := setInterruptHandler(func() { close(interrupted) })
+	defer remove()
+
+	ch := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		cleanupHandler(ch, cancel)
+		close(done)
+	}()
+
+	ch <- syscall.SIGINT
+	<-interrupted
+	rtest.OK(t, ctx.Err())
+
+	ch <- syscall.SIGINT
+	<-done
+	rtest.Equals(t, context.Canceled, ctx.Err())
+}
+
+func TestCleanupHandlerTerminate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	remove := setInterruptHandler(func() { t.Error("interrupt handler called for SIGTERM") })
+	defer remove()
+
+	ch := make(chan os.Signal, 1)
+	ch <- syscall.SIGTERM
+	cleanupHandler(ch, cancel)
+	rtest.Equals(t, context.Canceled, ctx.Err())
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cmdAlias = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage snapshot aliases",
+	Long: `
+The "alias" command manages human-friendly names for snapshots, which are
+stored in the repository. An alias can be used instead of a snapshot ID
+wherever a single snapshot is selected, for example by "restore", "diff",
+"ls" or "dump". The "mount" command lists aliased snapshots in the "aliases"
+directory.
+
+Alias names must not consist only of hexadecimal digits, as they could be
+confused with snapshot IDs, and must not contain ':', '/', '\' or whitespace.
+	`,
+	DisableAutoGenTag: true,
+	GroupID:           cmdGroupDefault,
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdAlias)
+}
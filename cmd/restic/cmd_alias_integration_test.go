@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunAliasList(t testing.TB, gopts GlobalOptions) []aliasInfo {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runAliasList(context.TODO(), gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var list []aliasInfo
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &list))
+	return list
+}
+
+func TestAlias(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	ids := testListSnapshots(t, env.gopts, 2)
+
+	rtest.Equals(t, 0, len(testRunAliasList(t, env.gopts)))
+	rtest.OK(t, runAliasSet(context.TODO(), AliasSetOptions{}, env.gopts, []string{"golden-master", ids[0].Str()}))
+	rtest.Assert(t, runAliasSet(context.TODO(), AliasSetOptions{}, env.gopts, []string{"abc123", ids[0].Str()}) != nil, "hex alias name was accepted")
+	rtest.Assert(t, runAliasSet(context.TODO(), AliasSetOptions{}, env.gopts, []string{"golden-master", ids[1].Str()}) != nil, "existing alias was replaced without --force")
+
+	list := testRunAliasList(t, env.gopts)
+	rtest.Equals(t, 1, len(list))
+	rtest.Equals(t, "golden-master", list[0].Name)
+	rtest.Equals(t, ids[0], list[0].SnapshotID)
+
+	// the alias can be used instead of the snapshot ID
+	rtest.Equals(t, testRunLs(t, env.gopts, ids[0].String()), testRunLs(t, env.gopts, "golden-master"))
+
+	rtest.OK(t, runAliasSet(context.TODO(), AliasSetOptions{Force: true}, env.gopts, []string{"golden-master", ids[1].Str()}))
+	list = testRunAliasList(t, env.gopts)
+	rtest.Equals(t, ids[1], list[0].SnapshotID)
+
+	rtest.Assert(t, runAliasRemove(context.TODO(), env.gopts, []string{"unknown"}) != nil, "removing unknown alias succeeded")
+	rtest.OK(t, runAliasSet(context.TODO(), AliasSetOptions{}, env.gopts, []string{"before-upgrade", ids[0].Str()}))
+	rtest.OK(t, runAliasRemove(context.TODO(), env.gopts, []string{"before-upgrade"}))
+	rtest.Equals(t, 1, len(testRunAliasList(t, env.gopts)))
+
+	// aliases of forgotten snapshots are removed
+	testRunForget(t, env.gopts, ForgetOptions{}, ids[1].String())
+	rtest.Equals(t, 0, len(testRunAliasList(t, env.gopts)))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/table"
+	"github.com/spf13/cobra"
+)
+
+var cmdAliasList = &cobra.Command{
+	Use:   "list",
+	Short: "List snapshot aliases",
+	Long: `
+The "list" sub-command lists all snapshot aliases together with the snapshots
+they refer to. Aliases of snapshots which no longer exist are marked as missing.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAliasList(cmd.Context(), globalOptions, args)
+	},
+}
+
+func init() {
+	cmdAlias.AddCommand(cmdAliasList)
+}
+
+// aliasInfo describes an alias in the output of 'alias list'.
+type aliasInfo struct {
+	Name       string    `json:"name"`
+	SnapshotID restic.ID `json:"snapshot_id"`
+	ShortID    string    `json:"-"`
+	Missing    bool      `json:"missing,omitempty"`
+	Snapshot   *Snapshot `json:"snapshot,omitempty"`
+}
+
+func runAliasList(ctx context.Context, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the alias list command expects no arguments")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	aliases, err := restic.LoadSnapshotAliases(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	list := make([]aliasInfo, 0, len(aliases))
+	for name, id := range aliases {
+		info := aliasInfo{Name: name, SnapshotID: id, ShortID: id.Str()}
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		if err != nil {
+			info.Missing = true
+		} else {
+			info.Snapshot = &Snapshot{Snapshot: sn, ID: sn.ID(), ShortID: sn.ID().Str()}
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(list)
+	}
+
+	tab := table.New()
+	tab.AddColumn("Name", "{{ .Name }}")
+	tab.AddColumn("Snapshot", "{{ .ShortID }}")
+	tab.AddColumn("Time", "{{ if .Missing }}missing{{ else }}{{ .Snapshot.Time.Local.Format \""+TimeFormat+"\" }}{{ end }}")
+	tab.AddColumn("Host", "{{ if not .Missing }}{{ .Snapshot.Hostname }}{{ end }}")
+	tab.AddColumn("Paths", "{{ if not .Missing }}{{ join .Snapshot.Paths \", \" }}{{ end }}")
+	for _, info := range list {
+		tab.AddRow(info)
+	}
+	return tab.Write(globalOptions.stdout)
+}
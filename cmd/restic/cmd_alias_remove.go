@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdAliasRemove = &cobra.Command{
+	Use:   "remove [flags] name [name ...]",
+	Short: "Remove snapshot aliases",
+	Long: `
+The "remove" sub-command removes the given aliases. The snapshots they refer to
+are not modified.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAliasRemove(cmd.Context(), globalOptions, args)
+	},
+}
+
+func init() {
+	cmdAlias.AddCommand(cmdAliasRemove)
+}
+
+func runAliasRemove(ctx context.Context, gopts GlobalOptions, args []string) error {
+	if len(args) == 0 {
+		return errors.Fatal("no alias specified")
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	aliases, err := restic.LoadSnapshotAliases(ctx, repo)
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if _, ok := aliases[name]; !ok {
+			return errors.Fatalf("alias %q does not exist", name)
+		}
+		delete(aliases, name)
+	}
+
+	if err := restic.SaveSnapshotAliases(ctx, repo, aliases); err != nil {
+		return err
+	}
+	Verbosef("removed %d aliases\n", len(args))
+	return nil
+}
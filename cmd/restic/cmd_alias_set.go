@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdAliasSet = &cobra.Command{
+	Use:   "set [flags] name snapshotID",
+	Short: "Assign a name to a snapshot",
+	Long: `
+The "set" sub-command assigns the name to the given snapshot. An existing alias
+with the same name is only replaced if --force is specified.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAliasSet(cmd.Context(), aliasSetOptions, globalOptions, args)
+	},
+}
+
+// AliasSetOptions bundles all options for the 'alias set' command.
+type AliasSetOptions struct {
+	Force bool
+}
+
+var aliasSetOptions AliasSetOptions
+
+func init() {
+	cmdAlias.AddCommand(cmdAliasSet)
+
+	f := cmdAliasSet.Flags()
+	f.BoolVarP(&aliasSetOptions.Force, "force", "f", false, "replace an existing alias with the same name")
+}
+
+func runAliasSet(ctx context.Context, opts AliasSetOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 2 {
+		return errors.Fatal("the alias set command expects a name and a snapshot ID")
+	}
+	name := args[0]
+	if err := restic.ValidateAliasName(name); err != nil {
+		return errors.Fatal(err.Error())
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sn, subfolder, err := restic.FindSnapshot(ctx, repo, repo, args[1])
+	if err != nil {
+		return errors.Fatalf("failed to find snapshot: %v", err)
+	}
+	if subfolder != "" {
+		return errors.Fatal("an alias cannot refer to a subfolder of a snapshot")
+	}
+
+	aliases, err := restic.LoadSnapshotAliases(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if old, ok := aliases[name]; ok && old != *sn.ID() && !opts.Force {
+		return errors.Fatalf("alias %q already refers to snapshot %v, use --force to replace it", name, old.Str())
+	}
+
+	aliases[name] = *sn.ID()
+	if err := restic.SaveSnapshotAliases(ctx, repo, aliases); err != nil {
+		return err
+	}
+
+	Verbosef("alias %v now refers to snapshot %v\n", name, sn.ID().Str())
+	return nil
+}
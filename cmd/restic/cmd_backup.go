@@ -6,10 +6,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +20,10 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/s3"
+	"github.com/restic/restic/internal/backend/sftp"
+	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/filter"
@@ -43,12 +49,25 @@ EXIT STATUS
 Exit status is 0 if the command was successful.
 Exit status is 1 if there was a fatal error (no snapshot created).
 Exit status is 3 if some source data could not be read (incomplete snapshot created).
+Exit status is 4 if the changes were below --min-change-files and --min-change-bytes (no snapshot created).
 Exit status is 10 if the repository does not exist.
 Exit status is 11 if the repository is already locked.
 Exit status is 12 if the password is incorrect.
+Exit status is 130 if the backup was interrupted (partial snapshot created if
+the backup was able to stop gracefully).
 `,
-	PreRun: func(_ *cobra.Command, _ []string) {
+	PreRun: func(_ *cobra.Command, args []string) {
 		if backupOptions.Host == "" {
+			// use the name of the remote host for sftp sources
+			if cfg, _, err := parseSFTPTargets(args); err == nil && cfg != nil {
+				backupOptions.Host = cfg.Host
+				return
+			}
+			if host := sourceURLHost(backupOptions.SourceURL); host != "" {
+				backupOptions.Host = host
+				return
+			}
+
 			hostname, err := os.Hostname()
 			if err != nil {
 				debug.Log("os.Hostname() returned err: %v", err)
@@ -70,30 +89,51 @@ Exit status is 12 if the password is incorrect.
 type BackupOptions struct {
 	filter.ExcludePatternOptions
 
-	Parent            string
-	GroupBy           restic.SnapshotGroupByOptions
-	Force             bool
-	ExcludeOtherFS    bool
-	ExcludeIfPresent  []string
-	ExcludeCaches     bool
-	ExcludeLargerThan string
-	Stdin             bool
-	StdinFilename     string
-	StdinCommand      bool
-	Tags              restic.TagLists
-	Host              string
-	FilesFrom         []string
-	FilesFromVerbatim []string
-	FilesFromRaw      []string
-	TimeStamp         string
-	WithAtime         bool
-	IgnoreInode       bool
-	IgnoreCtime       bool
-	UseFsSnapshot     bool
-	DryRun            bool
-	ReadConcurrency   uint
-	NoScan            bool
-	SkipIfUnchanged   bool
+	Parent              string
+	GroupBy             restic.SnapshotGroupByOptions
+	Force               bool
+	ExcludeOtherFS      bool
+	ExcludeIfPresent    []string
+	ExcludeCaches       bool
+	ExcludeLargerThan   string
+	ExcludeOlderThan    restic.Duration
+	ExcludeUIDs         []uint
+	ExcludeGIDs         []uint
+	ExcludeUntrackedGit bool
+	Stdin               bool
+	StdinFilename       string
+	StdinCommand        bool
+	StdinCommandsFrom   string
+	SourceURL           string
+	Tags                restic.TagLists
+	Host                string
+	FilesFrom           []string
+	FilesFromVerbatim   []string
+	FilesFromRaw        []string
+	TimeStamp           string
+	WithAtime           bool
+	NoAtime             bool
+	IgnoreInode         bool
+	IgnoreCtime         bool
+	UseFsSnapshot       bool
+	ExcludeADS          bool
+	NoWindowsACLs       bool
+	DryRun              bool
+	ReadConcurrency     uint
+	NoScan              bool
+	FreezeCommand       string
+	ThawCommand         string
+	PreCommand          string
+	PostCommand         string
+	VerifyPercent       float64
+	CheckPacks          uint
+	LogExcluded         string
+	SkipIfUnchanged     bool
+	MinChangeFiles      uint
+	MinChangeBytes      string
+	MinFreeSpace        string
+	AnomalyFactor       float64
+	AnomalyWebhook      string
 }
 
 var backupOptions BackupOptions
@@ -102,6 +142,14 @@ var backupFSTestHook func(fs fs.FS) fs.FS
 // ErrInvalidSourceData is used to report an incomplete backup
 var ErrInvalidSourceData = errors.New("at least one source file could not be read")
 
+// ErrBackupInterrupted is used to report that a partial snapshot was saved
+// after the backup was interrupted
+var ErrBackupInterrupted = errors.New("backup was interrupted, the snapshot only contains the files processed so far")
+
+// ErrBelowChangeThreshold is used to report that no snapshot was created
+// because the changes did not reach --min-change-files or --min-change-bytes
+var ErrBelowChangeThreshold = errors.New("changes are below the thresholds, no snapshot was created")
+
 func init() {
 	cmdRoot.AddCommand(cmdBackup)
 
@@ -111,15 +159,13 @@ func init() {
 	f.VarP(&backupOptions.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma (disable grouping with '')")
 	f.BoolVarP(&backupOptions.Force, "force", "f", false, `force re-reading the source files/directories (overrides the "parent" flag)`)
 
-	backupOptions.ExcludePatternOptions.Add(f)
-
-	f.BoolVarP(&backupOptions.ExcludeOtherFS, "one-file-system", "x", false, "exclude other file systems, don't cross filesystem boundaries and subvolumes")
-	f.StringArrayVar(&backupOptions.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:header]`, exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
-	f.BoolVar(&backupOptions.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See https://bford.info/cachedir/ for the Cache Directory Tagging Standard`)
-	f.StringVar(&backupOptions.ExcludeLargerThan, "exclude-larger-than", "", "max `size` of the files to be backed up (allowed suffixes: k/K, m/M, g/G, t/T)")
+	addExcludeFlags(f, &backupOptions)
+	f.StringVar(&backupOptions.LogExcluded, "log-excluded", "", "write the excluded files and directories and the rule which excluded them to `file` as JSON lines")
 	f.BoolVar(&backupOptions.Stdin, "stdin", false, "read backup from stdin")
 	f.StringVar(&backupOptions.StdinFilename, "stdin-filename", "stdin", "`filename` to use when reading from stdin")
 	f.BoolVar(&backupOptions.StdinCommand, "stdin-from-command", false, "interpret arguments as command to execute and store its stdout")
+	f.StringVar(&backupOptions.StdinCommandsFrom, "stdin-commands-from", "", "read lines of `file` containing a filename followed by a command and store the stdout of each command under the filename")
+	f.StringVar(&backupOptions.SourceURL, "source-url", "", "back up the files/dirs from the remote `location` (sftp:... or s3:...) instead of the local file system")
 	f.Var(&backupOptions.Tags, "tag", "add `tags` for the new snapshot in the format `tag[,tag,...]` (can be specified multiple times)")
 	f.UintVar(&backupOptions.ReadConcurrency, "read-concurrency", 0, "read `n` files concurrently (default: $RESTIC_READ_CONCURRENCY or 2)")
 	f.StringVarP(&backupOptions.Host, "host", "H", "", "set the `hostname` for the snapshot manually (default: $RESTIC_HOST). To prevent an expensive rescan use the \"parent\" flag")
@@ -134,14 +180,30 @@ func init() {
 	f.StringArrayVar(&backupOptions.FilesFromRaw, "files-from-raw", nil, "read the files to backup from `file` (can be combined with file args; can be specified multiple times)")
 	f.StringVar(&backupOptions.TimeStamp, "time", "", "`time` of the backup (ex. '2012-11-01 22:08:41') (default: now)")
 	f.BoolVar(&backupOptions.WithAtime, "with-atime", false, "store the atime for all files and directories")
+	f.BoolVar(&backupOptions.NoAtime, "no-atime", false, "preserve the atime of files and directories read by the backup, also where O_NOATIME cannot be used (implies --ignore-ctime)")
 	f.BoolVar(&backupOptions.IgnoreInode, "ignore-inode", false, "ignore inode number and ctime changes when checking for modified files")
 	f.BoolVar(&backupOptions.IgnoreCtime, "ignore-ctime", false, "ignore ctime changes when checking for modified files")
 	f.BoolVarP(&backupOptions.DryRun, "dry-run", "n", false, "do not upload or write any data, just show what would be done")
 	f.BoolVar(&backupOptions.NoScan, "no-scan", false, "do not run scanner to estimate size of backup")
+	f.StringVar(&backupOptions.FreezeCommand, "freeze-command", "", "run `command` before scanning the files, the scan result is used to detect files changed after the scan")
+	f.StringVar(&backupOptions.ThawCommand, "thaw-command", "", "run `command` once the scan started after --freeze-command has finished")
+	f.StringVar(&backupOptions.PreCommand, "pre-command", "", "run `command` before the backup, the backup is not started if it fails")
+	f.StringVar(&backupOptions.PostCommand, "post-command", "", "run `command` after the backup, it receives the snapshot ID, statistics and exit status as environment variables and as JSON on stdin")
+	f.Float64Var(&backupOptions.VerifyPercent, "verify-percent", 0, "download and verify `n` percent of the pack files written by the backup")
+	f.UintVar(&backupOptions.CheckPacks, "check-packs", 5, "check that `n` randomly selected pack files still exist in the repository before starting the backup (0 to disable)")
+	if runtime.GOOS == "windows" || runtime.GOOS == "linux" {
+		f.BoolVar(&backupOptions.UseFsSnapshot, "use-fs-snapshot", false, "use filesystem snapshot where possible (Windows VSS, Linux btrfs, zfs or LVM)")
+	}
 	if runtime.GOOS == "windows" {
-		f.BoolVar(&backupOptions.UseFsSnapshot, "use-fs-snapshot", false, "use filesystem snapshot where possible (currently only Windows VSS)")
+		f.BoolVar(&backupOptions.ExcludeADS, "exclude-ads", false, "do not back up alternate data streams of files")
+		f.BoolVar(&backupOptions.NoWindowsACLs, "no-windows-acls", false, "do not back up the security descriptors (owner and ACLs) of files and directories")
 	}
-	f.BoolVar(&backupOptions.SkipIfUnchanged, "skip-if-unchanged", false, "skip snapshot creation if identical to parent snapshot")
+	f.BoolVar(&backupOptions.SkipIfUnchanged, "skip-if-unchanged", false, "skip snapshot creation if identical to the latest snapshot of the snapshot group")
+	f.UintVar(&backupOptions.MinChangeFiles, "min-change-files", 0, "skip snapshot creation if fewer than `n` files were added, changed or removed compared to the parent snapshot")
+	f.StringVar(&backupOptions.MinChangeBytes, "min-change-bytes", "", "skip snapshot creation if the added, changed and removed files are smaller than `size` in total (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.StringVar(&backupOptions.MinFreeSpace, "min-free-space", "", "abort the backup if less than `size` of space remains for the repository according to the backend quota or free space (default: abort if no space remains)")
+	f.Float64Var(&backupOptions.AnomalyFactor, "anomaly-factor", 0, "warn if the backup changed `n` times more files or data than usual for the same targets (0 to disable)")
+	f.StringVar(&backupOptions.AnomalyWebhook, "anomaly-webhook", "", "send a JSON event to `url` if --anomaly-factor detects an anomaly")
 
 	// parse read concurrency from env, on error the default value will be used
 	readConcurrency, _ := strconv.ParseUint(os.Getenv("RESTIC_READ_CONCURRENCY"), 10, 32)
@@ -155,9 +217,9 @@ func init() {
 
 // filterExisting returns a slice of all existing items, or an error if no
 // items exist at all.
-func filterExisting(items []string) (result []string, err error) {
+func filterExisting(items []string, filesys fs.FS) (result []string, err error) {
 	for _, item := range items {
-		_, err := fs.Lstat(item)
+		_, err := filesys.Lstat(item)
 		if errors.Is(err, os.ErrNotExist) {
 			Warnf("%v does not exist, skipping\n", item)
 			continue
@@ -264,6 +326,94 @@ func readFilenamesRaw(r io.Reader) (names []string, err error) {
 	}
 }
 
+// stdinCommand is a command whose standard output is stored as a file.
+type stdinCommand struct {
+	Filename string
+	Args     []string
+}
+
+// readStdinCommands reads the commands to execute from filename. Each line
+// contains the filename used for the output of the command, followed by the
+// command and its arguments, which are split like in a shell. Empty lines and
+// lines starting with '#' are ignored.
+func readStdinCommands(filename string) ([]stdinCommand, error) {
+	lines, err := readLines(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []stdinCommand
+	seen := make(map[string]struct{})
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' { // '#' marks a comment.
+			continue
+		}
+
+		args, err := backend.SplitShellStrings(line)
+		if err != nil {
+			return nil, errors.Fatalf("--stdin-commands-from: line %d: %v", i+1, err)
+		}
+		if len(args) < 2 {
+			return nil, errors.Fatalf("--stdin-commands-from: line %d: expected filename and command", i+1)
+		}
+		filename := path.Join("/", args[0])
+		if _, ok := seen[filename]; ok {
+			return nil, errors.Fatalf("--stdin-commands-from: line %d: duplicate filename %q", i+1, args[0])
+		}
+		seen[filename] = struct{}{}
+
+		commands = append(commands, stdinCommand{
+			Filename: filename,
+			Args:     args[1:],
+		})
+	}
+
+	if len(commands) == 0 {
+		return nil, errors.Fatal("--stdin-commands-from: no commands found")
+	}
+	return commands, nil
+}
+
+// isSFTPTarget returns true if target refers to a path on a remote host.
+func isSFTPTarget(target string) bool {
+	return strings.HasPrefix(target, "sftp:")
+}
+
+// parseSFTPTargets parses the targets in the format sftp://user@host[:port]/path
+// or sftp:user@host:path. It returns the config for the connection to the
+// remote host and the remote paths. All targets must refer to the same remote
+// host. If no target refers to a remote host, the config is nil.
+func parseSFTPTargets(targets []string) (*sftp.Config, []string, error) {
+	remote := 0
+	for _, target := range targets {
+		if isSFTPTarget(target) {
+			remote++
+		}
+	}
+	if remote == 0 {
+		return nil, targets, nil
+	}
+	if remote != len(targets) {
+		return nil, nil, errors.Fatal("local and sftp source files/dirs cannot be used together")
+	}
+
+	var cfg *sftp.Config
+	paths := make([]string, 0, len(targets))
+	for _, target := range targets {
+		c, err := sftp.ParseConfig(target)
+		if err != nil {
+			return nil, nil, errors.Fatalf("invalid sftp source %v: %v", target, err)
+		}
+		if cfg != nil && (c.User != cfg.User || c.Host != cfg.Host || c.Port != cfg.Port) {
+			return nil, nil, errors.Fatal("all sftp source files/dirs must be located on the same host")
+		}
+		cfg = c
+		paths = append(paths, c.Path)
+	}
+	return cfg, paths, nil
+}
+
 // Check returns an error when an invalid combination of options was set.
 func (opts BackupOptions) Check(gopts GlobalOptions, args []string) error {
 	if gopts.password == "" && !gopts.InsecureNoPassword {
@@ -272,6 +422,7 @@ func (opts BackupOptions) Check(gopts GlobalOptions, args []string) error {
 		}
 
 		filesFrom := append(append(opts.FilesFrom, opts.FilesFromVerbatim...), opts.FilesFromRaw...)
+		filesFrom = append(filesFrom, opts.StdinCommandsFrom)
 		for _, filename := range filesFrom {
 			if filename == "-" {
 				return errors.Fatal("unable to read password from stdin when data is to be read from stdin, use --password-file or $RESTIC_PASSWORD")
@@ -295,46 +446,216 @@ func (opts BackupOptions) Check(gopts GlobalOptions, args []string) error {
 		}
 	}
 
+	if opts.StdinCommandsFrom != "" {
+		if opts.Stdin || opts.StdinCommand {
+			return errors.Fatal("--stdin-commands-from cannot be used together with --stdin or --stdin-from-command")
+		}
+		if len(opts.FilesFrom) > 0 || len(opts.FilesFromVerbatim) > 0 || len(opts.FilesFromRaw) > 0 {
+			return errors.Fatal("--stdin-commands-from and --files-from cannot be used together")
+		}
+		if len(args) > 0 {
+			return errors.Fatal("--stdin-commands-from was specified and files/dirs were listed as arguments")
+		}
+	}
+
+	if opts.VerifyPercent < 0 || opts.VerifyPercent > 100 {
+		return errors.Fatal("--verify-percent must be between 0 and 100")
+	}
+
+	if opts.FreezeCommand != "" || opts.ThawCommand != "" {
+		if opts.Stdin || opts.StdinCommand || opts.StdinCommandsFrom != "" {
+			return errors.Fatal("--freeze-command and --thaw-command cannot be used together with --stdin, --stdin-from-command or --stdin-commands-from")
+		}
+		if opts.FreezeCommand == "" {
+			return errors.Fatal("--thaw-command requires --freeze-command")
+		}
+	}
+
+	if opts.SourceURL != "" {
+		if opts.Stdin || opts.StdinCommand || opts.StdinCommandsFrom != "" {
+			return errors.Fatal("--source-url cannot be used together with --stdin, --stdin-from-command or --stdin-commands-from")
+		}
+		if slices.ContainsFunc(args, isSFTPTarget) {
+			return errors.Fatal("--source-url and sftp source files/dirs cannot be used together")
+		}
+		if len(opts.FilesFrom) > 0 || len(opts.FilesFromVerbatim) > 0 || len(opts.FilesFromRaw) > 0 {
+			return errors.Fatal("--source-url and --files-from cannot be used together")
+		}
+		if opts.UseFsSnapshot {
+			return errors.Fatal("--use-fs-snapshot cannot be used with --source-url")
+		}
+		if opts.ExcludeUntrackedGit {
+			return errors.Fatal("--exclude-untracked-git cannot be used with --source-url")
+		}
+	}
+
+	if !opts.StdinCommand && slices.ContainsFunc(args, isSFTPTarget) {
+		if len(opts.FilesFrom) > 0 || len(opts.FilesFromVerbatim) > 0 || len(opts.FilesFromRaw) > 0 {
+			return errors.Fatal("sftp source files/dirs and --files-from cannot be used together")
+		}
+		if opts.UseFsSnapshot {
+			return errors.Fatal("--use-fs-snapshot cannot be used with sftp source files/dirs")
+		}
+		if opts.ExcludeUntrackedGit {
+			return errors.Fatal("--exclude-untracked-git cannot be used with sftp source files/dirs")
+		}
+	}
+
 	return nil
 }
 
-// collectRejectByNameFuncs returns a list of all functions which may reject data
+// openSFTPSource connects to the remote host which contains the source
+// files/dirs. The options for the connection are the same as for sftp
+// repositories.
+func openSFTPSource(cfg *sftp.Config, gopts GlobalOptions) (*fs.SFTP, func() error, error) {
+	if err := gopts.extended.Extract("sftp").Apply("sftp", cfg); err != nil {
+		return nil, nil, err
+	}
+
+	client, closeFn, err := sftp.Connect(*cfg)
+	if err != nil {
+		return nil, nil, errors.Fatalf("unable to connect to sftp source %v: %v", cfg.Host, err)
+	}
+	return fs.NewSFTP(client), closeFn, nil
+}
+
+// sourceURLHost returns the host name used for snapshots of the given source
+// location. For sftp sources this is the name of the remote host, for s3
+// sources the name of the bucket. If the location is invalid, the empty string
+// is returned.
+func sourceURLHost(sourceURL string) string {
+	switch {
+	case isSFTPTarget(sourceURL):
+		if cfg, err := sftp.ParseConfig(sourceURL); err == nil {
+			return cfg.Host
+		}
+	case strings.HasPrefix(sourceURL, "s3:"):
+		if cfg, err := s3.ParseConfig(sourceURL); err == nil {
+			return cfg.Bucket
+		}
+	}
+	return ""
+}
+
+// openSourceURL opens the file system at the remote source location. The
+// location is given in the same format as a repository location. If no
+// files/dirs are given, the path contained in the location is backed up.
+func openSourceURL(ctx context.Context, sourceURL string, gopts GlobalOptions, args []string) (fs.FS, []string, func() error, error) {
+	switch {
+	case isSFTPTarget(sourceURL):
+		cfg, err := sftp.ParseConfig(sourceURL)
+		if err != nil {
+			return nil, nil, nil, errors.Fatalf("invalid source url %v: %v", sourceURL, err)
+		}
+		if len(args) == 0 {
+			args = []string{cfg.Path}
+		}
+
+		sftpFS, closeFn, err := openSFTPSource(cfg, gopts)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return sftpFS, args, closeFn, nil
+
+	case strings.HasPrefix(sourceURL, "s3:"):
+		cfg, err := s3.ParseConfig(sourceURL)
+		if err != nil {
+			return nil, nil, nil, errors.Fatalf("invalid source url %v: %v", sourceURL, err)
+		}
+		if cfg.Bucket == "" {
+			return nil, nil, nil, errors.Fatalf("invalid source url %v: bucket name not found", sourceURL)
+		}
+		if len(args) == 0 {
+			args = []string{"/" + cfg.Prefix}
+		}
+
+		cfg.ApplyEnvironment("")
+		if err := gopts.extended.Extract("s3").Apply("s3", cfg); err != nil {
+			return nil, nil, nil, err
+		}
+		rt, err := backend.Transport(gopts.TransportOptions)
+		if err != nil {
+			return nil, nil, nil, errors.Fatal(err.Error())
+		}
+		if gopts.transportWrapper != nil {
+			rt = gopts.transportWrapper(rt)
+		}
+
+		client, err := s3.Connect(*cfg, rt)
+		if err != nil {
+			return nil, nil, nil, errors.Fatalf("unable to connect to s3 source %v: %v", cfg.Endpoint, err)
+		}
+		return fs.NewS3(ctx, client, cfg.Bucket), args, func() error { return nil }, nil
+	}
+
+	return nil, nil, nil, errors.Fatalf("unsupported source url %v, only sftp: and s3: locations are supported", sourceURL)
+}
+
+// collectRejectByNameRules returns a list of all rules which may reject data
 // from being saved in a snapshot based on path only
-func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository) (fs []archiver.RejectByNameFunc, err error) {
+func collectRejectByNameRules(opts BackupOptions, repo *repository.Repository) (rules []rejectByNameRule, err error) {
 	// exclude restic cache
-	if repo.Cache != nil {
+	if repo != nil && repo.Cache != nil {
 		f, err := rejectResticCache(repo)
 		if err != nil {
 			return nil, err
 		}
 
-		fs = append(fs, f)
+		rules = append(rules, rejectByNameRule{reject: f, rule: staticRule("restic cache directory")})
 	}
 
-	fsPatterns, err := opts.ExcludePatternOptions.CollectPatterns(Warnf)
+	patternRules, err := opts.ExcludePatternOptions.CollectPatternRules(Warnf)
 	if err != nil {
 		return nil, err
 	}
-	for _, pat := range fsPatterns {
-		fs = append(fs, archiver.RejectByNameFunc(pat))
+	for _, pr := range patternRules {
+		pr := pr
+		rules = append(rules, rejectByNameRule{
+			reject: archiver.RejectByNameFunc(pr.Reject),
+			rule: func(item string) string {
+				return fmt.Sprintf("--%v pattern %q", pr.Option, pr.Matching(item))
+			},
+			negating: func(item string) string {
+				if pattern := pr.Negating(item); pattern != "" {
+					return fmt.Sprintf("--%v pattern %q", pr.Option, pattern)
+				}
+				return ""
+			},
+		})
 	}
 
-	return fs, nil
+	return rules, nil
+}
+
+// changeIgnoreFlags returns the flags controlling the change detection.
+func changeIgnoreFlags(opts BackupOptions) uint {
+	var flags uint
+	if opts.IgnoreInode {
+		// --ignore-inode implies --ignore-ctime: on FUSE, the ctime is not
+		// reliable either.
+		flags |= archiver.ChangeIgnoreCtime | archiver.ChangeIgnoreInode
+	}
+	// --no-atime implies --ignore-ctime: restoring the access time updates
+	// the ctime, such that all files would be read again by the next backup.
+	if opts.IgnoreCtime || opts.NoAtime {
+		flags |= archiver.ChangeIgnoreCtime
+	}
+	return flags
 }
 
-// collectRejectFuncs returns a list of all functions which may reject data
-// from being saved in a snapshot based on path and file info
-func collectRejectFuncs(opts BackupOptions, targets []string, fs fs.FS) (funcs []archiver.RejectFunc, err error) {
+// collectRejectRules returns a list of all rules which may reject data from
+// being saved in a snapshot based on path and file info
+func collectRejectRules(opts BackupOptions, targets []string, fs fs.FS) (rules []rejectRule, err error) {
 	// allowed devices
-	if opts.ExcludeOtherFS && !opts.Stdin && !opts.StdinCommand {
+	if opts.ExcludeOtherFS && !opts.Stdin && !opts.StdinCommand && opts.StdinCommandsFrom == "" {
 		f, err := archiver.RejectByDevice(targets, fs)
 		if err != nil {
 			return nil, err
 		}
-		funcs = append(funcs, f)
+		rules = append(rules, rejectRule{reject: f, rule: "--one-file-system"})
 	}
 
-	if len(opts.ExcludeLargerThan) != 0 && !opts.Stdin && !opts.StdinCommand {
+	if len(opts.ExcludeLargerThan) != 0 && !opts.Stdin && !opts.StdinCommand && opts.StdinCommandsFrom == "" {
 		maxSize, err := ui.ParseBytes(opts.ExcludeLargerThan)
 		if err != nil {
 			return nil, err
@@ -344,11 +665,37 @@ func collectRejectFuncs(opts BackupOptions, targets []string, fs fs.FS) (funcs [
 		if err != nil {
 			return nil, err
 		}
-		funcs = append(funcs, f)
+		rules = append(rules, rejectRule{reject: f, rule: "--exclude-larger-than " + opts.ExcludeLargerThan})
 	}
 
-	if opts.ExcludeCaches {
-		opts.ExcludeIfPresent = append(opts.ExcludeIfPresent, "CACHEDIR.TAG:Signature: 8a477f597d28d172789f06886806bc55")
+	if !opts.ExcludeOlderThan.Zero() && !opts.Stdin && !opts.StdinCommand && opts.StdinCommandsFrom == "" {
+		d := opts.ExcludeOlderThan
+		if d.Hours < 0 || d.Days < 0 || d.Months < 0 || d.Years < 0 {
+			return nil, errors.Fatal("--exclude-older-than must not be negative")
+		}
+		cutoff := time.Now().AddDate(-d.Years, -d.Months, -d.Days).Add(time.Hour * time.Duration(-d.Hours))
+		rules = append(rules, rejectRule{reject: archiver.RejectOlderThan(cutoff), rule: "--exclude-older-than " + d.String()})
+	}
+
+	if (len(opts.ExcludeUIDs) > 0 || len(opts.ExcludeGIDs) > 0) && !opts.Stdin && !opts.StdinCommand && opts.StdinCommandsFrom == "" {
+		if runtime.GOOS == "windows" {
+			return nil, errors.Fatal("--exclude-uid and --exclude-gid are not supported on Windows")
+		}
+
+		var uids, gids []uint32
+		for _, uid := range opts.ExcludeUIDs {
+			if uid > math.MaxUint32 {
+				return nil, errors.Fatalf("invalid uid %v", uid)
+			}
+			uids = append(uids, uint32(uid))
+		}
+		for _, gid := range opts.ExcludeGIDs {
+			if gid > math.MaxUint32 {
+				return nil, errors.Fatalf("invalid gid %v", gid)
+			}
+			gids = append(gids, uint32(gid))
+		}
+		rules = append(rules, rejectRule{reject: archiver.RejectByOwner(uids, gids), rule: "--exclude-uid/--exclude-gid"})
 	}
 
 	for _, spec := range opts.ExcludeIfPresent {
@@ -357,15 +704,29 @@ func collectRejectFuncs(opts BackupOptions, targets []string, fs fs.FS) (funcs [
 			return nil, err
 		}
 
-		funcs = append(funcs, f)
+		rules = append(rules, rejectRule{reject: f, rule: "--exclude-if-present " + spec})
 	}
 
-	return funcs, nil
+	if opts.ExcludeCaches {
+		f, err := archiver.RejectIfPresent("CACHEDIR.TAG:Signature: 8a477f597d28d172789f06886806bc55", Warnf)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rejectRule{reject: f, rule: "--exclude-caches"})
+	}
+
+	if opts.ExcludeUntrackedGit && !opts.Stdin && !opts.StdinCommand && opts.StdinCommandsFrom == "" {
+		rules = append(rules, rejectRule{reject: archiver.RejectUntrackedGit(Warnf), rule: "--exclude-untracked-git"})
+	}
+
+	return rules, nil
 }
 
 // collectTargets returns a list of target files/dirs from several sources.
-func collectTargets(opts BackupOptions, args []string) (targets []string, err error) {
-	if opts.Stdin || opts.StdinCommand {
+// Only files/dirs which exist in filesys are returned.
+func collectTargets(opts BackupOptions, args []string, filesys fs.FS) (targets []string, err error) {
+	if opts.Stdin || opts.StdinCommand || opts.StdinCommandsFrom != "" {
 		return nil, nil
 	}
 
@@ -422,7 +783,11 @@ func collectTargets(opts BackupOptions, args []string) (targets []string, err er
 		return nil, errors.Fatal("nothing to backup, please specify source files/dirs")
 	}
 
-	targets, err = filterExisting(targets)
+	// remove duplicates before checking that the targets exist, as lists
+	// passed via --files-from-verbatim can be huge
+	targets = uniqueTargets(targets, filesys)
+
+	targets, err = filterExisting(targets, filesys)
 	if err != nil {
 		return nil, err
 	}
@@ -430,6 +795,22 @@ func collectTargets(opts BackupOptions, args []string) (targets []string, err er
 	return targets, nil
 }
 
+// uniqueTargets removes targets which only differ in their spelling from an
+// earlier target, for example "dir/" and "./dir". The first occurrence is kept.
+func uniqueTargets(targets []string, filesys fs.FS) []string {
+	seen := make(map[string]struct{}, len(targets))
+	result := targets[:0]
+	for _, target := range targets {
+		clean := filesys.Clean(target)
+		if _, ok := seen[clean]; ok {
+			continue
+		}
+		seen[clean] = struct{}{}
+		result = append(result, target)
+	}
+	return result
+}
+
 // parent returns the ID of the parent snapshot. If there is none, nil is
 // returned.
 func findParentSnapshot(ctx context.Context, repo restic.ListerLoaderUnpacked, opts BackupOptions, targets []string, timeStampLimit time.Time) (*restic.Snapshot, error) {
@@ -441,6 +822,28 @@ func findParentSnapshot(ctx context.Context, repo restic.ListerLoaderUnpacked, o
 	if snName == "" {
 		snName = "latest"
 	}
+	f := snapshotGroupFilter(opts, targets, timeStampLimit)
+
+	sn, _, err := f.FindLatest(ctx, repo, repo, snName)
+	// Snapshot not found is ok if no explicit parent was set
+	if opts.Parent == "" && errors.Is(err, restic.ErrNoSnapshotFound) {
+		err = nil
+	}
+	return sn, err
+}
+
+// findLatestSnapshot returns the latest snapshot of the group the new snapshot
+// belongs to according to --group-by. If there is none, nil is returned.
+func findLatestSnapshot(ctx context.Context, repo restic.ListerLoaderUnpacked, opts BackupOptions, targets []string, timeStampLimit time.Time) (*restic.Snapshot, error) {
+	f := snapshotGroupFilter(opts, targets, timeStampLimit)
+	sn, _, err := f.FindLatest(ctx, repo, repo, "latest")
+	if errors.Is(err, restic.ErrNoSnapshotFound) {
+		return nil, nil
+	}
+	return sn, err
+}
+
+func snapshotGroupFilter(opts BackupOptions, targets []string, timeStampLimit time.Time) restic.SnapshotFilter {
 	f := restic.SnapshotFilter{TimestampLimit: timeStampLimit}
 	if opts.GroupBy.Host {
 		f.Hosts = []string{opts.Host}
@@ -451,41 +854,129 @@ func findParentSnapshot(ctx context.Context, repo restic.ListerLoaderUnpacked, o
 	if opts.GroupBy.Tag {
 		f.Tags = []restic.TagList{opts.Tags.Flatten()}
 	}
-
-	sn, _, err := f.FindLatest(ctx, repo, repo, snName)
-	// Snapshot not found is ok if no explicit parent was set
-	if opts.Parent == "" && errors.Is(err, restic.ErrNoSnapshotFound) {
-		err = nil
-	}
-	return sn, err
+	return f
 }
 
-func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) (err error) {
 	var vsscfg fs.VSSConfig
-	var err error
+	var fsSnapshotCfg fs.FSSnapshotConfig
 
+	sourceCfg, err := fs.ParseSourceConfig(gopts.extended)
+	if err != nil {
+		return err
+	}
+	chunkerProfile, err := archiver.ParseChunkerConfig(gopts.extended)
+	if err != nil {
+		return err
+	}
 	if runtime.GOOS == "windows" {
 		if vsscfg, err = fs.ParseVSSConfig(gopts.extended); err != nil {
 			return err
 		}
 	}
+	if runtime.GOOS == "linux" {
+		if fsSnapshotCfg, err = fs.ParseFSSnapshotConfig(gopts.extended); err != nil {
+			return err
+		}
+	}
 
 	err = opts.Check(gopts, args)
 	if err != nil {
 		return err
 	}
 
-	targets, err := collectTargets(opts, args)
+	result := &backupResult{dryRun: opts.DryRun}
+	if opts.PostCommand != "" {
+		defer func() {
+			err = runPostCommand(opts.PostCommand, result, err)
+		}()
+	}
+	if opts.PreCommand != "" {
+		if err := runHookCommand(ctx, "pre", opts.PreCommand, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	var minChangeBytes int64
+	if opts.MinChangeBytes != "" {
+		minChangeBytes, err = ui.ParseBytes(opts.MinChangeBytes)
+		if err != nil {
+			return errors.Fatalf("invalid value for --min-change-bytes: %v", err)
+		}
+	}
+
+	var minFreeSpace int64
+	if opts.MinFreeSpace != "" {
+		minFreeSpace, err = ui.ParseBytes(opts.MinFreeSpace)
+		if err != nil {
+			return errors.Fatalf("invalid value for --min-free-space: %v", err)
+		}
+	}
+
+	if opts.AnomalyFactor < 0 {
+		return errors.Fatal("--anomaly-factor must not be negative")
+	}
+	if opts.AnomalyWebhook != "" && opts.AnomalyFactor == 0 {
+		return errors.Fatal("--anomaly-webhook requires --anomaly-factor")
+	}
+
+	localFS := fs.Local{
+		NoAtime:               opts.NoAtime,
+		AlternateDataStreams:  !opts.ExcludeADS,
+		NoSecurityDescriptors: opts.NoWindowsACLs,
+	}
+	var sourceFS fs.FS = localFS
+	if opts.SourceURL != "" {
+		remoteFS, paths, closeSource, err := openSourceURL(ctx, opts.SourceURL, gopts, args)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = closeSource()
+		}()
+		sourceFS = remoteFS
+		args = paths
+	} else if !opts.StdinCommand {
+		sftpCfg, paths, err := parseSFTPTargets(args)
+		if err != nil {
+			return err
+		}
+		if sftpCfg != nil {
+			sftpFS, closeSFTP, err := openSFTPSource(sftpCfg, gopts)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = closeSFTP()
+			}()
+			sourceFS = sftpFS
+			args = paths
+		}
+	}
+
+	targets, err := collectTargets(opts, args, sourceFS)
 	if err != nil {
 		return err
 	}
 
+	switch sourceFS.(type) {
+	case *fs.SFTP, *fs.S3:
+		// relative paths refer to the working directory on the remote host or
+		// to the root of the bucket
+		for i, target := range targets {
+			targets[i], err = sourceFS.Abs(target)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	timeStamp := time.Now()
 	backupStart := timeStamp
 	if opts.TimeStamp != "" {
-		timeStamp, err = time.ParseInLocation(TimeFormat, opts.TimeStamp, time.Local)
+		timeStamp, err = parseSnapshotTime("time", opts.TimeStamp, backupStart)
 		if err != nil {
-			return errors.Fatalf("error in time option: %v\n", err)
+			return err
 		}
 	}
 
@@ -509,8 +1000,8 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		calculateProgressInterval(!gopts.Quiet, gopts.JSON))
 	defer progressReporter.Done()
 
-	// rejectByNameFuncs collect functions that can reject items from the backup based on path only
-	rejectByNameFuncs, err := collectRejectByNameFuncs(opts, repo)
+	// rejectByNameRules collect functions that can reject items from the backup based on path only
+	rejectByNameRules, err := collectRejectByNameRules(opts, repo)
 	if err != nil {
 		return err
 	}
@@ -531,6 +1022,19 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		}
 	}
 
+	var latestSnapshot *restic.Snapshot
+	if opts.SkipIfUnchanged {
+		if !opts.Stdin && !opts.Force && opts.Parent == "" {
+			// the parent is already the latest snapshot of the group
+			latestSnapshot = parentSnapshot
+		} else {
+			latestSnapshot, err = findLatestSnapshot(ctx, repo, opts, targets, timeStamp)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	if !gopts.JSON {
 		progressPrinter.V("load index files")
 	}
@@ -541,7 +1045,13 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		return err
 	}
 
-	var targetFS fs.FS = fs.Local{}
+	if opts.CheckPacks > 0 {
+		if err := checkPackSample(ctx, repo, opts.CheckPacks, gopts, progressPrinter); err != nil {
+			return err
+		}
+	}
+
+	targetFS := sourceFS
 	if runtime.GOOS == "windows" && opts.UseFsSnapshot {
 		if err = fs.HasSufficientPrivilegesForVSS(); err != nil {
 			return err
@@ -558,9 +1068,32 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		}
 
 		localVss := fs.NewLocalVss(errorHandler, messageHandler, vsscfg)
+		localVss.FS = localFS
 		defer localVss.DeleteSnapshots()
 		targetFS = localVss
 	}
+	if runtime.GOOS == "linux" && opts.UseFsSnapshot {
+		errorHandler := func(item string, err error) {
+			_ = progressReporter.Error(item, err)
+		}
+
+		messageHandler := func(msg string, args ...interface{}) {
+			if !gopts.JSON {
+				progressPrinter.P(msg, args...)
+			}
+		}
+
+		localSnapshot, err := fs.NewLocalFSSnapshot(errorHandler, messageHandler, fsSnapshotCfg)
+		if err != nil {
+			return err
+		}
+		defer localSnapshot.DeleteSnapshots()
+		targetFS = localSnapshot
+	}
+	if sourceCfg.IOTimeout > 0 {
+		// a hanging file system must not stall the whole backup
+		targetFS = fs.NewTimeout(targetFS, sourceCfg.IOTimeout)
+	}
 
 	if opts.Stdin || opts.StdinCommand {
 		if !gopts.JSON {
@@ -583,24 +1116,108 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		targets = []string{filename}
 	}
 
+	if opts.StdinCommandsFrom != "" {
+		commands, err := readStdinCommands(opts.StdinCommandsFrom)
+		if err != nil {
+			return err
+		}
+
+		// start all commands before reading any output, such that all data
+		// is captured at roughly the same time
+		readers := make([]*fs.Reader, 0, len(commands))
+		sources := make([]*fs.CommandReader, 0, len(commands))
+		// commands whose output was not read completely, for example because
+		// the backup failed, must not be left running
+		defer func() {
+			for _, source := range sources {
+				source.Kill()
+			}
+		}()
+		for _, command := range commands {
+			source, err := fs.NewCommandReader(ctx, command.Args, globalOptions.stderr)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, source)
+			readers = append(readers, &fs.Reader{
+				ModTime:    timeStamp,
+				Name:       command.Filename,
+				Mode:       0644,
+				ReadCloser: source,
+			})
+			targets = append(targets, command.Filename)
+		}
+
+		targetFS, err = fs.NewMultiReader(readers...)
+		if err != nil {
+			return err
+		}
+	}
+
 	if backupFSTestHook != nil {
 		targetFS = backupFSTestHook(targetFS)
 	}
 
-	// rejectFuncs collect functions that can reject items from the backup based on path and file info
-	rejectFuncs, err := collectRejectFuncs(opts, targets, targetFS)
+	// statistics of the previous backup of the same targets improve the
+	// estimate of the remaining time early on
+	var historyFilename string
+	var history *backup.History
+	if repo.Cache != nil {
+		historyFilename = repo.Cache.ProgressHistoryFilename(backup.HistoryKey(opts.Host, targets))
+		history, err = backup.LoadHistory(historyFilename)
+		if err != nil {
+			debug.Log("unable to load progress history: %v", err)
+		}
+		progressReporter.SetHistory(history)
+	} else if opts.AnomalyFactor > 0 {
+		Warnf("--anomaly-factor requires the cache to store the statistics of recent backups\n")
+	}
+
+	spaceKnown, err := checkFreeSpace(ctx, repo, uint64(minFreeSpace), history, gopts, progressPrinter)
+	if err != nil {
+		return err
+	}
+
+	// rejectRules collect functions that can reject items from the backup based on path and file info
+	rejectRules, err := collectRejectRules(opts, targets, targetFS)
 	if err != nil {
 		return err
 	}
 
-	selectByNameFilter := archiver.CombineRejectByNames(rejectByNameFuncs)
-	selectFilter := archiver.CombineRejects(rejectFuncs)
+	selectByNameFilter := combineRejectByNameRules(rejectByNameRules)
+	selectFilter := combineRejectRules(rejectRules)
+
+	var consistency *consistencyGroup
+	if opts.FreezeCommand != "" {
+		// only the scan runs while the applications are frozen, it records the
+		// state of all files at the consistency point
+		consistency = newConsistencyGroup(changeIgnoreFlags(opts))
+		sc := archiver.NewScanner(targetFS)
+		sc.SelectByName = selectByNameFilter
+		sc.Select = consistency.Record(selectFilter)
+		sc.Error = progressPrinter.ScannerError
+		sc.Result = progressReporter.ReportTotal
+
+		if !gopts.JSON {
+			progressPrinter.V("run freeze command and scan %v", targets)
+		}
+		start := time.Now()
+		err = runFrozen(ctx, opts.FreezeCommand, opts.ThawCommand, func() error {
+			return sc.Scan(ctx, targets)
+		})
+		if err != nil {
+			return err
+		}
+		if !gopts.JSON {
+			progressPrinter.V("applications were frozen for %v", time.Since(start).Round(time.Millisecond))
+		}
+	}
 
 	wg, wgCtx := errgroup.WithContext(ctx)
 	cancelCtx, cancel := context.WithCancel(wgCtx)
 	defer cancel()
 
-	if !opts.NoScan {
+	if !opts.NoScan && consistency == nil {
 		sc := archiver.NewScanner(targetFS)
 		sc.SelectByName = selectByNameFilter
 		sc.Select = selectFilter
@@ -613,9 +1230,24 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		wg.Go(func() error { return sc.Scan(cancelCtx, targets) })
 	}
 
-	arch := archiver.New(repo, targetFS, archiver.Options{ReadConcurrency: opts.ReadConcurrency})
+	closeExcludeLog := func() error { return nil }
+	arch := archiver.New(repo, targetFS, archiver.Options{ReadConcurrency: opts.ReadConcurrency, ChunkerProfile: chunkerProfile})
 	arch.SelectByName = selectByNameFilter
 	arch.Select = selectFilter
+	if opts.LogExcluded != "" {
+		// only the archiver logs the excluded items, the scanner evaluates the
+		// same rules
+		excludeLog, err := newExcludeLog(opts.LogExcluded)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = excludeLog.Close()
+		}()
+		arch.SelectByName = excludeLog.SelectByName(rejectByNameRules)
+		arch.Select = excludeLog.Select(rejectRules)
+		closeExcludeLog = excludeLog.Close
+	}
 	arch.WithAtime = opts.WithAtime
 	success := true
 	arch.Error = func(item string, err error) error {
@@ -631,14 +1263,12 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	arch.CompleteItem = progressReporter.CompleteItem
 	arch.StartFile = progressReporter.StartFile
 	arch.CompleteBlob = progressReporter.CompleteBlob
+	arch.ChangeIgnoreFlags = changeIgnoreFlags(opts)
 
-	if opts.IgnoreInode {
-		// --ignore-inode implies --ignore-ctime: on FUSE, the ctime is not
-		// reliable either.
-		arch.ChangeIgnoreFlags |= archiver.ChangeIgnoreCtime | archiver.ChangeIgnoreInode
-	}
-	if opts.IgnoreCtime {
-		arch.ChangeIgnoreFlags |= archiver.ChangeIgnoreCtime
+	if consistency != nil {
+		arch.Select = consistency.Check(arch.Select, func(item string, err error) {
+			_ = arch.Error(item, err)
+		})
 	}
 
 	snapshotOpts := archiver.SnapshotOptions{
@@ -650,12 +1280,22 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		ParentSnapshot:  parentSnapshot,
 		ProgramVersion:  "restic " + version,
 		SkipIfUnchanged: opts.SkipIfUnchanged,
+		LatestSnapshot:  latestSnapshot,
+		MinChangeFiles:  opts.MinChangeFiles,
+		MinChangeBytes:  uint64(minChangeBytes),
+		CountRemoved:    opts.AnomalyFactor > 0,
+		Supersedes:      partialSnapshotChain(ctx, repo, parentSnapshot),
 	}
 
 	if !gopts.JSON {
 		progressPrinter.V("start backup on %v", targets)
 	}
+	// the first SIGINT saves a partial snapshot
+	removeInterruptHandler := setInterruptHandler(arch.Interrupt)
 	_, id, summary, err := arch.Snapshot(ctx, targets, snapshotOpts)
+	removeInterruptHandler()
+	result.snapshotID = id
+	result.summary = summary
 
 	// cleanly shutdown all running goroutines
 	cancel()
@@ -667,13 +1307,190 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	if err != nil {
 		return errors.Fatalf("unable to save snapshot: %v", err)
 	}
+	if err := closeExcludeLog(); err != nil {
+		return err
+	}
+
+	if spaceKnown {
+		space, ok, err := repo.Space(ctx)
+		if err != nil {
+			debug.Log("unable to determine the remaining space: %v", err)
+		} else if ok {
+			summary.SpaceRemaining = &space.Remaining
+		}
+	}
 
 	// Report finished execution
 	progressReporter.Finish(id, summary, opts.DryRun)
+	if opts.AnomalyFactor > 0 && werr == nil && !summary.Interrupted {
+		detectAnomalies(ctx, backup.NewThresholdDetector(opts.AnomalyFactor), history, targets, id, summary, opts, gopts)
+	}
+	if werr == nil && !opts.DryRun && !id.IsNull() {
+		err = repository.RecordStats(ctx, repo, repository.StatsHistoryEntry{
+			Command:        "backup",
+			SnapshotID:     &id,
+			BytesProcessed: summary.ProcessedBytes,
+			DataAdded:      summary.DataSize,
+		})
+		if err != nil {
+			Warnf("failed to record repository statistics: %v\n", err)
+		}
+
+		if historyFilename != "" && success && !summary.Interrupted {
+			if err := backup.SaveHistory(historyFilename, progressReporter.NewHistory(summary)); err != nil {
+				debug.Log("unable to save progress history: %v", err)
+			}
+		}
+
+		if opts.VerifyPercent > 0 {
+			if err := verifySavedPacks(ctx, repo, opts.VerifyPercent, gopts, progressPrinter); err != nil {
+				return err
+			}
+		}
+	}
+	if summary.Interrupted {
+		return ErrBackupInterrupted
+	}
 	if !success {
 		return ErrInvalidSourceData
 	}
+	if werr == nil && summary.BelowChangeThreshold {
+		return ErrBelowChangeThreshold
+	}
 
 	// Return error if any
 	return werr
 }
+
+// partialSnapshotChain returns the IDs of the partial snapshots which were
+// created in a row up to and including parent, newest first. A complete
+// backup based on parent supersedes these snapshots.
+func partialSnapshotChain(ctx context.Context, repo restic.LoaderUnpacked, parent *restic.Snapshot) restic.IDs {
+	var ids restic.IDs
+	for sn := parent; sn != nil && sn.Partial; {
+		ids = append(ids, *sn.ID())
+		if sn.Parent == nil {
+			break
+		}
+		next, err := restic.LoadSnapshot(ctx, repo, *sn.Parent)
+		if err != nil {
+			// the chain ends if the parent was already removed
+			debug.Log("unable to load parent snapshot %v: %v", sn.Parent.Str(), err)
+			break
+		}
+		sn = next
+	}
+	return ids
+}
+
+// checkPackSample checks that a few pack files referenced by the index still
+// exist. Pack files which were deleted externally, for example by a lifecycle
+// rule of the storage bucket, would otherwise only be noticed by check or
+// restore. New backups could also silently depend on the missing data.
+func checkPackSample(ctx context.Context, repo *repository.Repository, n uint, gopts GlobalOptions, printer backup.ProgressPrinter) error {
+	if !gopts.JSON {
+		printer.V("check that %d pack files exist", n)
+	}
+	checked, missing, err := repo.CheckPackSample(ctx, n)
+	if err != nil {
+		return errors.Fatalf("failed to check pack files: %v", err)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for _, id := range missing {
+		Warnf("pack file %v is missing from the repository\n", id)
+	}
+	return errors.Fatalf("%d of %d checked pack files are missing from the repository. They were likely deleted outside of restic, "+
+		"for example by a lifecycle or retention rule of the storage bucket. Disable such rules for the repository, "+
+		"then run `restic check` and follow the troubleshooting guide to repair the repository before creating new backups", len(missing), checked)
+}
+
+// parseSnapshotTime parses the time of a snapshot specified using the option
+// name. The time must not be after now, which is the time the snapshot is
+// created.
+func parseSnapshotTime(name string, str string, now time.Time) (time.Time, error) {
+	t, err := parseTime(str)
+	if err != nil {
+		return time.Time{}, errors.Fatalf("invalid --%v %q, use for example %q, %q or RFC 3339",
+			name, str, TimeFormat, "2006-01-02")
+	}
+	if t.After(now) {
+		return time.Time{}, errors.Fatalf("--%v %v is in the future", name, t.Format(TimeFormat))
+	}
+	return t, nil
+}
+
+// checkFreeSpace checks that space remains for the repository before the
+// backup starts, based on the quota and free space reported by the backend.
+// The backup is aborted if less than minFree remains. A warning is printed if
+// the previous backup of the same targets processed more new and changed data
+// than remains available. It returns whether the remaining space is known.
+func checkFreeSpace(ctx context.Context, repo *repository.Repository, minFree uint64, history *backup.History, gopts GlobalOptions, printer backup.ProgressPrinter) (bool, error) {
+	space, ok, err := repo.Space(ctx)
+	if err != nil {
+		Warnf("unable to determine the space available for the repository: %v\n", err)
+		return false, nil
+	}
+	if !ok {
+		return false, nil
+	}
+	if !gopts.JSON {
+		printer.V("%s of space remaining for the repository", ui.FormatBytes(space.Remaining))
+	}
+
+	switch {
+	case space.Quota > 0 && space.Used >= space.Quota:
+		return true, errors.Fatalf("the repository size of %s has reached the quota of %s", ui.FormatBytes(space.Used), ui.FormatBytes(space.Quota))
+	case space.Remaining == 0:
+		return true, errors.Fatal("no space remains for the repository")
+	case space.Remaining < minFree:
+		return true, errors.Fatalf("only %s of space remains for the repository, which is less than --min-free-space %s",
+			ui.FormatBytes(space.Remaining), ui.FormatBytes(minFree))
+	}
+	if history != nil && space.Remaining < history.ChangedBytes {
+		Warnf("warning: only %s of space remains for the repository, but the previous backup of the same targets processed %s of new and changed files\n",
+			ui.FormatBytes(space.Remaining), ui.FormatBytes(history.ChangedBytes))
+	}
+	return true, nil
+}
+
+// verifySavedPacks downloads a random subset of the pack files saved by the
+// backup and checks their integrity.
+func verifySavedPacks(ctx context.Context, repo *repository.Repository, percent float64, gopts GlobalOptions, printer backup.ProgressPrinter) error {
+	packs := selectRandomPacksByPercentage(repo.SavedPacks(), percent)
+	if len(packs) == 0 {
+		return nil
+	}
+	if !gopts.JSON {
+		printer.V("verifying %d new pack files", len(packs))
+	}
+
+	if repo.Cache != nil {
+		// the pack files must be downloaded from the repository
+		for id := range packs {
+			_ = repo.Cache.Forget(backend.Handle{Type: restic.PackFile, Name: id.String()})
+		}
+	}
+
+	chkr := checker.New(repo, false)
+	errChan := make(chan error)
+	go chkr.ReadPacks(ctx, packs, nil, errChan)
+
+	errorsFound := 0
+	for err := range errChan {
+		errorsFound++
+		Warnf("verification of new pack file failed: %v\n", err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errorsFound > 0 {
+		return errors.Fatalf("%d of %d verified pack files are damaged, run `restic check --read-data` to check the repository", errorsFound, len(packs))
+	}
+	if !gopts.JSON {
+		printer.P("verified %d new pack files", len(packs))
+	}
+	return nil
+}
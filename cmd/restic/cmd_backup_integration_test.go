@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -395,6 +401,82 @@ func TestBackupExclude(t *testing.T) {
 		"expected file %q not in first snapshot, but it's included", "passwords.txt")
 }
 
+func TestBackupExcludeByAttributes(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	datadir := filepath.Join(env.base, "testdata")
+	rtest.OK(t, os.MkdirAll(filepath.Join(datadir, "old"), 0755))
+	for _, filename := range []string{"new.txt", "old.txt", "old/new.txt"} {
+		rtest.OK(t, os.WriteFile(filepath.Join(datadir, filename), []byte(filename), 0o666))
+	}
+	old := time.Now().AddDate(-3, 0, 0)
+	rtest.OK(t, os.Chtimes(filepath.Join(datadir, "old.txt"), old, old))
+	rtest.OK(t, os.Chtimes(filepath.Join(datadir, "old"), old, old))
+
+	snapshots := make(map[string]struct{})
+	opts := BackupOptions{ExcludeOlderThan: restic.Duration{Years: 2}}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	snapshots, snapshotID := lastSnapshot(snapshots, loadSnapshotMap(t, env.gopts))
+	files := testRunLs(t, env.gopts, snapshotID)
+	rtest.Assert(t, includes(files, "/testdata/new.txt"), "new file is missing")
+	rtest.Assert(t, !includes(files, "/testdata/old.txt"), "old file was not excluded")
+	// directories are always included
+	rtest.Assert(t, includes(files, "/testdata/old/new.txt"), "new file in old directory is missing")
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	opts = BackupOptions{ExcludeUIDs: []uint{uint(os.Getuid())}}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	_, snapshotID = lastSnapshot(snapshots, loadSnapshotMap(t, env.gopts))
+	files = testRunLs(t, env.gopts, snapshotID)
+	rtest.Assert(t, !includes(files, "/testdata/new.txt"), "file of excluded user was not excluded")
+	rtest.Assert(t, includes(files, "/testdata/old"), "directory of excluded user is missing")
+}
+
+func TestBackupLogExcluded(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	datadir := filepath.Join(env.base, "testdata")
+	for _, filename := range backupExcludeFilenames {
+		fp := filepath.Join(datadir, filename)
+		rtest.OK(t, os.MkdirAll(filepath.Dir(fp), 0755))
+		rtest.OK(t, os.WriteFile(fp, []byte(filename), 0o666))
+	}
+
+	logfile := filepath.Join(env.base, "excluded.jsonl")
+	opts := BackupOptions{LogExcluded: logfile}
+	opts.Excludes = []string{"*.tar.gz", "private/secret"}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+
+	f, err := os.Open(logfile)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, f.Close())
+	}()
+
+	rules := make(map[string]string)
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var item excludedItem
+		rtest.OK(t, dec.Decode(&item))
+		rel := strings.TrimPrefix(filepath.ToSlash(item.Path), filepath.ToSlash(env.base))
+		rules[rel] = item.Rule
+	}
+
+	// the contents of excluded directories are not logged
+	rtest.Equals(t, map[string]string{
+		"/testdata/foo.tar.gz":     `--exclude pattern "*.tar.gz"`,
+		"/testdata/private/secret": `--exclude pattern "private/secret"`,
+	}, rules)
+}
+
 func TestBackupErrors(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		return
@@ -695,6 +777,258 @@ func TestStdinFromCommandFailNoOutputAndExitCode(t *testing.T) {
 	testRunCheck(t, env.gopts)
 }
 
+func TestStdinCommandsFrom(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	manifest := filepath.Join(env.base, "commands")
+	rtest.OK(t, os.WriteFile(manifest, []byte(`# database dumps
+db/first.sql python -c "print('first')"
+db/second.sql python -c "print('second')"
+`), 0644))
+
+	opts := BackupOptions{StdinCommandsFrom: manifest}
+	testRunBackup(t, filepath.Dir(env.testdata), nil, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	testRunCheck(t, env.gopts)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, snapshotIDs[0].String())
+	for _, name := range []string{"first", "second"} {
+		buf, err := os.ReadFile(filepath.Join(restoredir, "db", name+".sql"))
+		rtest.OK(t, err)
+		rtest.Equals(t, name+"\n", string(buf))
+	}
+
+	// a failing command must not create a snapshot
+	rtest.OK(t, os.WriteFile(manifest, []byte(`first python -c "print('first')"
+second python -c "import sys; sys.exit(1)"
+`), 0644))
+	err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), nil, opts, env.gopts)
+	rtest.Assert(t, err != nil, "Expected error while backing up")
+	testListSnapshots(t, env.gopts, 1)
+}
+
+func TestBackupFreezeCommand(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	hooklog := filepath.Join(env.base, "hooks")
+	opts := BackupOptions{
+		FreezeCommand: fmt.Sprintf(`python -c "open('%s', 'a').write('freeze\n')"`, filepath.ToSlash(hooklog)),
+		ThawCommand:   fmt.Sprintf(`python -c "open('%s', 'a').write('thaw\n')"`, filepath.ToSlash(hooklog)),
+	}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+	buf, err := os.ReadFile(hooklog)
+	rtest.OK(t, err)
+	rtest.Equals(t, "freeze\nthaw\n", string(buf))
+
+	// files modified or created after the consistency point are reported
+	modified := filepath.ToSlash(filepath.Join(env.testdata, "modified"))
+	created := filepath.ToSlash(filepath.Join(env.testdata, "created"))
+	rtest.OK(t, os.WriteFile(modified, []byte("before"), 0600))
+	opts.ThawCommand = fmt.Sprintf(`python -c "open('%s', 'a').write('after'); open('%s', 'w').write('new')"`, modified, created)
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err == ErrInvalidSourceData, "unexpected error %v", err)
+	testListSnapshots(t, env.gopts, 2)
+
+	out := strings.Join(testRunLs(t, env.gopts, "latest"), "\n")
+	rtest.Assert(t, strings.Contains(out, "/testdata/modified"), "modified file missing in snapshot:\n%v", out)
+	rtest.Assert(t, !strings.Contains(out, "/testdata/created"), "file created after the consistency point was saved:\n%v", out)
+
+	// the thaw command runs even if the freeze command failed
+	rtest.OK(t, os.Remove(hooklog))
+	opts.FreezeCommand = `python -c "import sys; sys.exit(1)"`
+	opts.ThawCommand = fmt.Sprintf(`python -c "open('%s', 'a').write('thaw\n')"`, filepath.ToSlash(hooklog))
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil, "failed freeze command was ignored")
+	buf, err = os.ReadFile(hooklog)
+	rtest.OK(t, err)
+	rtest.Equals(t, "thaw\n", string(buf))
+	testListSnapshots(t, env.gopts, 2)
+}
+
+func TestBackupPrePostCommand(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	hooklog := filepath.ToSlash(filepath.Join(env.base, "hooks"))
+	postinput := filepath.ToSlash(filepath.Join(env.base, "post.json"))
+	opts := BackupOptions{
+		PreCommand: fmt.Sprintf(`python -c "open('%s', 'a').write('pre\n')"`, hooklog),
+		PostCommand: fmt.Sprintf(`python -c "import os, sys; open('%s', 'a').write('post ' + os.environ['RESTIC_BACKUP_EXIT_STATUS'] + ' ' + os.environ['RESTIC_SNAPSHOT_ID'] + '\n'); open('%s', 'w').write(sys.stdin.read())"`,
+			hooklog, postinput),
+	}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	id := testListSnapshots(t, env.gopts, 1)[0]
+
+	buf, err := os.ReadFile(hooklog)
+	rtest.OK(t, err)
+	rtest.Equals(t, "pre\npost 0 "+id.String()+"\n", string(buf))
+	var input postCommandInput
+	buf, err = os.ReadFile(postinput)
+	rtest.OK(t, err)
+	rtest.OK(t, json.Unmarshal(buf, &input))
+	rtest.Equals(t, 0, input.ExitStatus)
+	rtest.Equals(t, id.String(), input.SnapshotID)
+	rtest.Assert(t, input.Summary != nil && input.Summary.TotalFilesProcessed > 0, "missing summary in %v", string(buf))
+
+	// the post command runs even if the pre command failed
+	rtest.OK(t, os.Remove(hooklog))
+	opts.PreCommand = `python -c "import sys; sys.exit(1)"`
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil, "failed pre command was ignored")
+	buf, err = os.ReadFile(hooklog)
+	rtest.OK(t, err)
+	rtest.Equals(t, "post 1 \n", string(buf))
+	testListSnapshots(t, env.gopts, 1)
+}
+
+// corruptPackBackend flips a bit in every saved pack file.
+type corruptPackBackend struct {
+	backend.Backend
+}
+
+func (be *corruptPackBackend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if h.Type != backend.PackFile {
+		return be.Backend.Save(ctx, h, rd)
+	}
+	buf, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	buf[0] ^= 0x01
+	return be.Backend.Save(ctx, h, backend.NewByteReader(buf, nil))
+}
+
+func TestBackupVerifyPercent(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{VerifyPercent: 100}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	// damaged pack files are detected right after the backup
+	rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, "new"), []byte("new data"), 0600))
+	env.gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) {
+		return &corruptPackBackend{Backend: r}, nil
+	}
+	err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "verified pack files are damaged"), "unexpected error %v", err)
+	// the snapshot was saved before the verification
+	testListSnapshots(t, env.gopts, 2)
+
+	opts.VerifyPercent = 101
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil && errors.IsFatal(err), "invalid percentage was accepted: %v", err)
+}
+
+func TestBackupCheckPacks(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{CheckPacks: 1000}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	// simulate a pack file deleted by a lifecycle rule
+	packs := testRunList(t, "packs", env.gopts)
+	removePacks(env.gopts, t, restic.NewIDSet(packs[0]))
+
+	err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "1 of "+fmt.Sprint(len(packs))+" checked pack files are missing"), "unexpected error %v", err)
+	// no new snapshot must be created
+	testListSnapshots(t, env.gopts, 1)
+}
+
+func TestBackupQuota(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	env.gopts.extended["local.quota"] = "1T"
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	// the quota is exceeded by the first backup
+	env.gopts.extended["local.quota"] = "1K"
+	err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "has reached the quota of 1.000 KiB"), "unexpected error %v", err)
+
+	env.gopts.extended["local.quota"] = "1T"
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{MinFreeSpace: "2T"}, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "less than --min-free-space"), "unexpected error %v", err)
+	testListSnapshots(t, env.gopts, 1)
+}
+
+func TestBackupAnomaly(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	events := make(chan anomalyEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event anomalyEvent
+		rtest.OK(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	defer srv.Close()
+
+	src := filepath.Join(env.base, "src")
+	rtest.OK(t, os.MkdirAll(src, 0755))
+	opts := BackupOptions{AnomalyFactor: 10, AnomalyWebhook: srv.URL}
+	for i := 0; i < 3; i++ {
+		rtest.OK(t, os.WriteFile(filepath.Join(src, fmt.Sprintf("file%d", i)), []byte("content"), 0644))
+		testRunBackup(t, env.base, []string{"src"}, opts, env.gopts)
+	}
+	rtest.Equals(t, 0, len(events))
+
+	// the median of one changed file per run is raised to 100 files
+	for i := 0; i < 1000; i++ {
+		rtest.OK(t, os.WriteFile(filepath.Join(src, fmt.Sprintf("new%d", i)), []byte("content"), 0644))
+	}
+	testRunBackup(t, env.base, []string{"src"}, opts, env.gopts)
+	rtest.Equals(t, 1, len(events))
+
+	event := <-events
+	rtest.Equals(t, "anomaly", event.MessageType)
+	rtest.Equals(t, 1, len(event.Anomalies))
+	rtest.Equals(t, "files_changed", event.Anomalies[0].Metric)
+	rtest.Equals(t, uint64(1000), event.Anomalies[0].Value)
+	rtest.Equals(t, uint64(100), event.Anomalies[0].Baseline)
+	rtest.Assert(t, event.SnapshotID != "", "snapshot ID is missing")
+}
+
+func TestBackupTime(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	start := time.Now()
+	opts := BackupOptions{TimeStamp: "2012-11-01 22:08:41"}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+
+	ctx, repo, unlock, err := openWithReadLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	sn, err := restic.LoadSnapshot(ctx, repo, testListSnapshots(t, env.gopts, 1)[0])
+	unlock()
+	rtest.OK(t, err)
+	rtest.Equals(t, opts.TimeStamp, sn.Time.Format(TimeFormat))
+	rtest.Assert(t, sn.IngestedAt != nil && !sn.IngestedAt.Before(start.Truncate(time.Second)),
+		"unexpected ingestion time %v", sn.IngestedAt)
+
+	opts.TimeStamp = time.Now().Add(time.Hour).Format(TimeFormat)
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "is in the future"), "unexpected error %v", err)
+}
+
 func TestBackupEmptyPassword(t *testing.T) {
 	// basic sanity test that empty passwords work
 	env, cleanup := withTestEnvironment(t)
@@ -721,5 +1055,105 @@ func TestBackupSkipIfUnchanged(t *testing.T) {
 		testListSnapshots(t, env.gopts, 1)
 	}
 
+	// the latest snapshot of the group is used even if the parent is not
+	opts.Force = true
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	// a snapshot with different tags is not identical
+	opts.Tags = restic.TagLists{{"other"}}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	testRunCheck(t, env.gopts)
+}
+
+func TestBackupMinChange(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{MinChangeFiles: 2, MinChangeBytes: "1M"}
+
+	// the first snapshot is always created
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err == ErrBelowChangeThreshold, "unexpected error %v", err)
+	testListSnapshots(t, env.gopts, 1)
+
+	// changes accumulate as the parent snapshot stays the same
+	for i, name := range []string{"new1", "new2"} {
+		rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, name), []byte("data"), 0600))
+		err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+		if i == 0 {
+			rtest.Assert(t, err == ErrBelowChangeThreshold, "unexpected error %v", err)
+		} else {
+			rtest.OK(t, err)
+		}
+	}
+	testListSnapshots(t, env.gopts, 2)
+
+	// removed files count as changes
+	rtest.OK(t, os.Remove(filepath.Join(env.testdata, "new1")))
+	rtest.OK(t, os.Remove(filepath.Join(env.testdata, "new2")))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 3)
+
+	opts.MinChangeBytes = "invalid"
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil && errors.IsFatal(err), "invalid size was accepted: %v", err)
+
+	// skipped snapshots leave unused blobs behind
+	_, err = testRunCheckOutput(env.gopts, false)
+	rtest.OK(t, err)
+}
+
+func findSFTPServerBinary() string {
+	for _, dir := range strings.Split(rtest.TestSFTPPath, ":") {
+		testpath := filepath.Join(dir, "sftp-server")
+		if _, err := os.Stat(testpath); err == nil {
+			return testpath
+		}
+	}
+	return ""
+}
+
+func TestBackupSFTPSource(t *testing.T) {
+	sftpServer := findSFTPServerBinary()
+	if sftpServer == "" {
+		t.Skip("sftp server binary not found")
+	}
+
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	// hardlinks cannot be detected and timestamps only have a resolution of
+	// one second via sftp
+	testsDir := filepath.Join(env.testdata, "0", "tests")
+	rtest.OK(t, os.Remove(filepath.Join(testsDir, "testfile-hardlink")))
+	mtime := time.Unix(time.Now().Unix(), 0)
+	rtest.OK(t, os.Chtimes(testsDir, mtime, mtime))
+	env.gopts.extended["sftp.command"] = sftpServer
+
+	opts := BackupOptions{Host: "remote"}
+	testRunBackup(t, "", []string{"sftp:remote:" + env.testdata}, opts, env.gopts)
 	testRunCheck(t, env.gopts)
+
+	snapshots := testListSnapshots(t, env.gopts, 1)
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, snapshots[0].String()+":"+toPathInSnapshot(filepath.Dir(env.testdata)))
+	diff := directoriesContentsDiff(env.testdata, filepath.Join(restoredir, "testdata"))
+	rtest.Assert(t, diff == "", "directories are not equal: %v", diff)
+
+	// the second backup uses the first one as parent
+	testRunBackup(t, "", []string{"sftp:remote:" + env.testdata}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	// the source can also be specified via --source-url
+	opts.SourceURL = "sftp:remote:" + env.testdata
+	testRunBackup(t, "", nil, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 3)
 }
@@ -9,7 +9,10 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/fs"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -50,6 +53,12 @@ func TestCollectTargets(t *testing.T) {
 		_, err = fmt.Fprintf(f2, "%s\r\n\n", filepath.Join(dir, filename))
 		rtest.OK(t, err)
 	}
+	// Duplicates with a different spelling should be removed.
+	sep := string(filepath.Separator)
+	for _, filename := range []string{fooSpace, "fromfile"} {
+		_, err = fmt.Fprintf(f2, "%s\n", dir+sep+"."+sep+filename)
+		rtest.OK(t, err)
+	}
 	rtest.OK(t, f2.Close())
 
 	f3, err := os.Create(filepath.Join(dir, "fromfile-raw"))
@@ -67,7 +76,7 @@ func TestCollectTargets(t *testing.T) {
 		FilesFromRaw:      []string{f3.Name()},
 	}
 
-	targets, err := collectTargets(opts, []string{filepath.Join(dir, "cmdline arg")})
+	targets, err := collectTargets(opts, []string{filepath.Join(dir, "cmdline arg")}, fs.Local{})
 	rtest.OK(t, err)
 	sort.Strings(targets)
 	rtest.Equals(t, expect, targets)
@@ -113,3 +122,80 @@ func TestReadFilenamesRaw(t *testing.T) {
 	rtest.Assert(t, strings.Contains(err.Error(), "zero byte"),
 		"wrong error message: %v", err.Error())
 }
+
+func TestParseSFTPTargets(t *testing.T) {
+	cfg, paths, err := parseSFTPTargets([]string{"/home", "foo"})
+	rtest.OK(t, err)
+	rtest.Assert(t, cfg == nil, "unexpected sftp config %v", cfg)
+	rtest.Equals(t, []string{"/home", "foo"}, paths)
+
+	_, _, err = parseSFTPTargets([]string{"sftp://user@host//etc", "sftp://user@other//etc"})
+	rtest.Assert(t, err != nil, "targets on different hosts were accepted")
+
+	cfg, paths, err = parseSFTPTargets([]string{"sftp://user@host//etc", "sftp:user@host:data/../docs"})
+	rtest.OK(t, err)
+	rtest.Equals(t, "user", cfg.User)
+	rtest.Equals(t, "host", cfg.Host)
+	rtest.Equals(t, []string{"/etc", "docs"}, paths)
+
+	_, _, err = parseSFTPTargets([]string{"sftp://user@host//etc", "/home"})
+	rtest.Assert(t, err != nil, "mixed local and sftp targets were accepted")
+}
+
+func TestSourceURLHost(t *testing.T) {
+	rtest.Equals(t, "host", sourceURLHost("sftp://user@host//etc"))
+	rtest.Equals(t, "host", sourceURLHost("sftp:user@host:data"))
+	rtest.Equals(t, "bucket", sourceURLHost("s3:s3.amazonaws.com/bucket/prefix"))
+	rtest.Equals(t, "", sourceURLHost("/home"))
+	rtest.Equals(t, "", sourceURLHost(""))
+}
+
+func TestBackupSourceURLCheck(t *testing.T) {
+	gopts := GlobalOptions{password: "secret"}
+	for _, opts := range []BackupOptions{
+		{SourceURL: "s3:host/bucket", Stdin: true},
+		{SourceURL: "s3:host/bucket", FilesFrom: []string{"files"}},
+		{SourceURL: "s3:host/bucket", UseFsSnapshot: true},
+		{SourceURL: "s3:host/bucket", ExcludeUntrackedGit: true},
+	} {
+		rtest.Assert(t, opts.Check(gopts, nil) != nil, "invalid options %+v were accepted", opts)
+	}
+
+	opts := BackupOptions{SourceURL: "s3:host/bucket"}
+	rtest.Assert(t, opts.Check(gopts, []string{"sftp:user@host:data"}) != nil, "sftp targets were accepted")
+	rtest.OK(t, opts.Check(gopts, []string{"/data"}))
+}
+
+func TestParseSnapshotTime(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local)
+	for str, want := range map[string]time.Time{
+		"2012-11-01 22:08:41":       time.Date(2012, 11, 1, 22, 8, 41, 0, time.Local),
+		"2012-11-01":                time.Date(2012, 11, 1, 0, 0, 0, 0, time.Local),
+		"2012-11-01T22:08:41Z":      time.Date(2012, 11, 1, 22, 8, 41, 0, time.UTC),
+		"2024-05-06 07:08:09":       now,
+		"2012-11-01 22:08:41 +0200": time.Date(2012, 11, 1, 22, 8, 41, 0, time.FixedZone("", 2*3600)),
+	} {
+		got, err := parseSnapshotTime("time", str, now)
+		rtest.OK(t, err)
+		rtest.Assert(t, got.Equal(want), "parsing %q: want %v, got %v", str, want, got)
+	}
+
+	for _, str := range []string{"2012-13-01", "yesterday", "2024-05-06 07:08:10", "2025-01-01"} {
+		_, err := parseSnapshotTime("time", str, now)
+		rtest.Assert(t, err != nil, "invalid time %q was accepted", str)
+	}
+}
+
+func TestChangeIgnoreFlags(t *testing.T) {
+	for _, test := range []struct {
+		opts  BackupOptions
+		flags uint
+	}{
+		{BackupOptions{}, 0},
+		{BackupOptions{IgnoreCtime: true}, archiver.ChangeIgnoreCtime},
+		{BackupOptions{IgnoreInode: true}, archiver.ChangeIgnoreCtime | archiver.ChangeIgnoreInode},
+		{BackupOptions{NoAtime: true}, archiver.ChangeIgnoreCtime},
+	} {
+		rtest.Equals(t, test.flags, changeIgnoreFlags(test.opts))
+	}
+}
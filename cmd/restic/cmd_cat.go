@@ -75,6 +75,10 @@ func runCat(ctx context.Context, gopts GlobalOptions, args []string) error {
 	}
 	defer unlock()
 
+	if err := enablePackCache(repo, gopts); err != nil {
+		return err
+	}
+
 	tpe := args[0]
 
 	var id restic.ID
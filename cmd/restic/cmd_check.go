@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/restic/restic/internal/backend/cache"
+	"github.com/restic/restic/internal/backend/cost"
 	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
@@ -215,7 +216,19 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		return errors.Fatal("the check command expects no arguments, only options - please see `restic help check` for usage and flags")
 	}
 
-	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	prices, err := parsePriceTable(gopts)
+	if err != nil {
+		return err
+	}
+
+	printer := newProgressPrinter(gopts.JSON, gopts.verbosity, term)
+	// hints are printed even with --quiet
+	printHint := term.Print
+	if gopts.JSON {
+		printHint = func(msg string) {
+			printer.P("%s", msg)
+		}
+	}
 
 	cleanup := prepareCheckCache(opts, &gopts, printer)
 	defer cleanup()
@@ -236,7 +249,7 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	}
 
 	printer.P("load indexes\n")
-	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	bar := newIndexPrinterProgress(gopts, printer, term)
 	hints, errs := chkr.LoadIndex(ctx, bar)
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -248,10 +261,10 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	for _, hint := range hints {
 		switch hint.(type) {
 		case *checker.ErrDuplicatePacks:
-			term.Print(hint.Error())
+			printHint(hint.Error())
 			suggestIndexRebuild = true
 		case *checker.ErrMixedPack:
-			term.Print(hint.Error())
+			printHint(hint.Error())
 			mixedFound = true
 		default:
 			printer.E("error: %v\n", hint)
@@ -260,10 +273,10 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	}
 
 	if suggestIndexRebuild {
-		term.Print("Duplicate packs are non-critical, you can run `restic repair index' to correct this.\n")
+		printHint("Duplicate packs are non-critical, you can run `restic repair index' to correct this.\n")
 	}
 	if mixedFound {
-		term.Print("Mixed packs with tree and data blobs are non-critical, you can run `restic prune` to correct this.\n")
+		printHint("Mixed packs with tree and data blobs are non-critical, you can run `restic prune` to correct this.\n")
 	}
 
 	if len(errs) > 0 {
@@ -275,6 +288,12 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		return errors.Fatal("repository contains errors")
 	}
 
+	ops, err := estimateCheckOperations(ctx, repo, chkr.GetPacks(), opts)
+	if err != nil {
+		return err
+	}
+	printCostEstimate(printer, ops, prices)
+
 	orphanedPacks := 0
 	errChan := make(chan error)
 	salvagePacks := restic.NewIDSet()
@@ -316,7 +335,7 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		bar := newTerminalProgressMax(!gopts.Quiet, 0, "snapshots", term)
+		bar := printer.NewCounter("snapshots")
 		defer bar.Done()
 		chkr.Structure(ctx, bar, errChan)
 	}()
@@ -355,7 +374,8 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	doReadData := func(packs map[restic.ID]int64) {
 		packCount := uint64(len(packs))
 
-		p := newTerminalProgressMax(!gopts.Quiet, packCount, "packs", term)
+		p := printer.NewCounter("packs")
+		p.SetMax(packCount)
 		errChan := make(chan error)
 
 		go chkr.ReadPacks(ctx, packs, p, errChan)
@@ -479,3 +499,50 @@ func selectRandomPacksByFileSize(allPacks map[restic.ID]int64, subsetSize int64,
 	packs := selectRandomPacksByPercentage(allPacks, subsetPercentage)
 	return packs
 }
+
+// estimateCheckOperations estimates the backend requests which are necessary
+// to check the packs, trees and, if requested, the data of the repository.
+// All pack files containing trees are assumed to be downloaded, even though
+// some of them may already be cached.
+func estimateCheckOperations(ctx context.Context, repo restic.Repository, allPacks map[restic.ID]int64, opts CheckOptions) (cost.Operations, error) {
+	// listing the pack files
+	ops := cost.Operations{List: 1}
+
+	treePacks := restic.NewIDSet()
+	err := repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		if pb.Type == restic.TreeBlob {
+			treePacks.Insert(pb.PackID)
+		}
+	})
+	if err != nil {
+		return cost.Operations{}, err
+	}
+
+	var totalSize int64
+	for _, size := range allPacks {
+		totalSize += size
+	}
+
+	// fraction of the pack files read when checking the data
+	fraction := 0.0
+	switch {
+	case opts.ReadData:
+		fraction = 1
+	case opts.ReadDataSubset != "":
+		if dataSubset, err := stringToIntSlice(opts.ReadDataSubset); err == nil {
+			fraction = 1 / float64(dataSubset[1])
+		} else if percentage, err := parsePercentage(opts.ReadDataSubset); err == nil {
+			fraction = percentage / 100
+		} else if subsetSize, err := ui.ParseBytes(opts.ReadDataSubset); err == nil && totalSize > 0 {
+			fraction = min(float64(subsetSize)/float64(totalSize), 1)
+		}
+	}
+
+	for id := range treePacks {
+		ops.Get++
+		ops.Download += uint64(allPacks[id])
+	}
+	ops.Get += uint64(fraction * float64(len(allPacks)))
+	ops.Download += uint64(fraction * float64(totalSize))
+	return ops, nil
+}
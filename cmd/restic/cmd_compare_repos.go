@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdCompareRepos = &cobra.Command{
+	Use:   "compare-repos [flags] [snapshotID ...]",
+	Short: "Compare the snapshots stored in two repositories",
+	Long: `
+The "compare-repos" command verifies that two repositories, for example a
+repository and a replica created using "copy" or by synchronizing the
+repository files, contain the same snapshots. The first repository is
+specified using the usual "--repo" options, the second one using "--from-repo".
+
+Snapshots are matched by their ID. Snapshots created by the "copy" command are
+matched with the snapshot they were copied from. For each pair of matching
+snapshots, the command checks that both reference the same tree and that all
+tree and data blobs used by the snapshot are present in both repositories.
+
+The command reports snapshots which only exist in one of the repositories,
+matching snapshots whose trees differ and blobs which are missing in one of the
+repositories. The blob contents are not read, use "check --read-data" for that.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the repositories contain the same snapshots.
+Exit status is 1 if the repositories differ or there was any other error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+	GroupID:           cmdGroupDefault,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCompareRepos(cmd.Context(), compareReposOptions, globalOptions, args)
+	},
+}
+
+// CompareReposOptions bundles all options for the compare-repos command.
+type CompareReposOptions struct {
+	secondaryRepoOptions
+	restic.SnapshotFilter
+}
+
+var compareReposOptions CompareReposOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdCompareRepos)
+
+	f := cmdCompareRepos.Flags()
+	initSecondaryRepoOptions(f, &compareReposOptions.secondaryRepoOptions, "second", "to compare with")
+	initMultiSnapshotFilter(f, &compareReposOptions.SnapshotFilter, true)
+}
+
+// compareTreeMismatch describes a pair of matching snapshots which reference
+// different trees.
+type compareTreeMismatch struct {
+	First      string    `json:"first"`
+	Second     string    `json:"second"`
+	FirstTree  restic.ID `json:"first_tree"`
+	SecondTree restic.ID `json:"second_tree"`
+}
+
+// compareBlob identifies a blob which is missing in one of the repositories.
+type compareBlob struct {
+	ID   restic.ID       `json:"id"`
+	Type restic.BlobType `json:"type"`
+}
+
+// CompareReposReport is the result of comparing two repositories.
+type CompareReposReport struct {
+	MessageType string `json:"message_type"` // "summary"
+
+	FirstSnapshots  int `json:"first_snapshots"`
+	SecondSnapshots int `json:"second_snapshots"`
+	Matching        int `json:"matching_snapshots"`
+
+	OnlyInFirst         []string              `json:"only_in_first"`
+	OnlyInSecond        []string              `json:"only_in_second"`
+	TreeMismatches      []compareTreeMismatch `json:"tree_mismatches"`
+	MissingInFirst      []compareBlob         `json:"missing_in_first"`
+	MissingInSecond     []compareBlob         `json:"missing_in_second"`
+	CheckedBlobs        int                   `json:"checked_blobs"`
+	DivergentRepository bool                  `json:"divergent"`
+}
+
+// snapshotKey returns the ID which identifies a snapshot across repositories.
+// For snapshots created by the copy command this is the ID of the original
+// snapshot.
+func snapshotKey(sn *restic.Snapshot) restic.ID {
+	if sn.Original != nil && !sn.Original.IsNull() {
+		return *sn.Original
+	}
+	return *sn.ID()
+}
+
+func loadSnapshotsByKey(ctx context.Context, snapshotLister restic.Lister, repo restic.LoaderUnpacked, filter *restic.SnapshotFilter, args []string) (map[restic.ID]*restic.Snapshot, int, error) {
+	count := 0
+	snapshots := make(map[restic.ID]*restic.Snapshot)
+	for sn := range FindFilteredSnapshots(ctx, snapshotLister, repo, filter, args) {
+		count++
+		key := snapshotKey(sn)
+		// prefer the snapshot itself over later copies of it
+		if other, ok := snapshots[key]; ok && other.ID().Equal(key) {
+			continue
+		}
+		snapshots[key] = sn
+	}
+	return snapshots, count, ctx.Err()
+}
+
+func blobList(blobs restic.BlobSet) []compareBlob {
+	list := []compareBlob{}
+	for _, bh := range blobs.List() {
+		list = append(list, compareBlob{ID: bh.ID, Type: bh.Type})
+	}
+	return list
+}
+
+// compareRepos compares the snapshots and blobs of both repositories. The
+// indexes of both repositories must already be loaded.
+func compareRepos(ctx context.Context, first, second restic.Repository, firstLister, secondLister restic.Lister,
+	filter *restic.SnapshotFilter, args []string) (*CompareReposReport, error) {
+
+	firstSnapshots, firstCount, err := loadSnapshotsByKey(ctx, firstLister, first, filter, args)
+	if err != nil {
+		return nil, err
+	}
+	secondSnapshots, secondCount, err := loadSnapshotsByKey(ctx, secondLister, second, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompareReposReport{
+		MessageType:     "summary",
+		FirstSnapshots:  firstCount,
+		SecondSnapshots: secondCount,
+		OnlyInFirst:     []string{},
+		OnlyInSecond:    []string{},
+		TreeMismatches:  []compareTreeMismatch{},
+	}
+
+	var trees restic.IDs
+	seenTrees := restic.NewIDSet()
+	for key, sn := range firstSnapshots {
+		other, ok := secondSnapshots[key]
+		if !ok {
+			report.OnlyInFirst = append(report.OnlyInFirst, sn.ID().String())
+			continue
+		}
+		if !sn.Tree.Equal(*other.Tree) {
+			report.TreeMismatches = append(report.TreeMismatches, compareTreeMismatch{
+				First:      sn.ID().String(),
+				Second:     other.ID().String(),
+				FirstTree:  *sn.Tree,
+				SecondTree: *other.Tree,
+			})
+			continue
+		}
+		report.Matching++
+		if !seenTrees.Has(*sn.Tree) {
+			seenTrees.Insert(*sn.Tree)
+			trees = append(trees, *sn.Tree)
+		}
+	}
+	// only snapshots requested on the command line must exist in the second repository
+	if len(args) == 0 {
+		for key, sn := range secondSnapshots {
+			if _, ok := firstSnapshots[key]; !ok {
+				report.OnlyInSecond = append(report.OnlyInSecond, sn.ID().String())
+			}
+		}
+	}
+	sort.Strings(report.OnlyInFirst)
+	sort.Strings(report.OnlyInSecond)
+	sort.Slice(report.TreeMismatches, func(i, j int) bool {
+		return report.TreeMismatches[i].First < report.TreeMismatches[j].First
+	})
+
+	// Both repositories reference the same trees. As blobs are content
+	// addressed, the set of blobs used by these trees is identical in both
+	// repositories and it is sufficient to traverse it once.
+	blobs := restic.NewBlobSet()
+	err = restic.FindUsedBlobs(ctx, first, trees, blobs, nil)
+	if err != nil {
+		return nil, errors.Fatalf("failed to load trees from the first repository: %v", err)
+	}
+	report.CheckedBlobs = len(blobs)
+
+	missingInFirst := restic.NewBlobSet()
+	missingInSecond := restic.NewBlobSet()
+	for bh := range blobs {
+		if _, ok := first.LookupBlobSize(bh.Type, bh.ID); !ok {
+			missingInFirst.Insert(bh)
+		}
+		if _, ok := second.LookupBlobSize(bh.Type, bh.ID); !ok {
+			missingInSecond.Insert(bh)
+		}
+	}
+	report.MissingInFirst = blobList(missingInFirst)
+	report.MissingInSecond = blobList(missingInSecond)
+
+	report.DivergentRepository = len(report.OnlyInFirst) > 0 || len(report.OnlyInSecond) > 0 ||
+		len(report.TreeMismatches) > 0 || len(report.MissingInFirst) > 0 || len(report.MissingInSecond) > 0
+	return report, nil
+}
+
+func printCompareReposReport(report *CompareReposReport) {
+	for _, id := range report.OnlyInFirst {
+		Printf("snapshot %v only exists in the first repository\n", id[:8])
+	}
+	for _, id := range report.OnlyInSecond {
+		Printf("snapshot %v only exists in the second repository\n", id[:8])
+	}
+	for _, m := range report.TreeMismatches {
+		Printf("snapshots %v and %v reference different trees %v and %v\n",
+			m.First[:8], m.Second[:8], m.FirstTree.Str(), m.SecondTree.Str())
+	}
+	for _, b := range report.MissingInFirst {
+		Printf("%v blob %v is missing in the first repository\n", b.Type, b.ID.Str())
+	}
+	for _, b := range report.MissingInSecond {
+		Printf("%v blob %v is missing in the second repository\n", b.Type, b.ID.Str())
+	}
+
+	if report.DivergentRepository {
+		Printf("\n")
+	}
+	Printf("compared %d snapshots in the first and %d snapshots in the second repository, %d match\n",
+		report.FirstSnapshots, report.SecondSnapshots, report.Matching)
+	Printf("checked %d blobs referenced by matching snapshots\n", report.CheckedBlobs)
+}
+
+func runCompareRepos(ctx context.Context, opts CompareReposOptions, gopts GlobalOptions, args []string) error {
+	secondGopts, _, err := fillSecondaryGlobalOpts(ctx, opts.secondaryRepoOptions, gopts, "second")
+	if err != nil {
+		return err
+	}
+
+	ctx, firstRepo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ctx, secondRepo, unlock, err := openWithReadLock(ctx, secondGopts, secondGopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	firstSnapshotLister, err := restic.MemorizeList(ctx, firstRepo, restic.SnapshotFile)
+	if err != nil {
+		return err
+	}
+	secondSnapshotLister, err := restic.MemorizeList(ctx, secondRepo, restic.SnapshotFile)
+	if err != nil {
+		return err
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err := firstRepo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+	bar = newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err := secondRepo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	report, err := compareRepos(ctx, firstRepo, secondRepo, firstSnapshotLister, secondSnapshotLister, &opts.SnapshotFilter, args)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		err = json.NewEncoder(globalOptions.stdout).Encode(report)
+		if err != nil {
+			return err
+		}
+	} else {
+		printCompareReposReport(report)
+	}
+
+	if report.DivergentRepository {
+		return errors.Fatal("repositories differ")
+	}
+	return nil
+}
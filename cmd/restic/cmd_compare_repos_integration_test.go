@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunCompareRepos(t testing.TB, gopts GlobalOptions, otherGopts GlobalOptions) (*CompareReposReport, error) {
+	opts := CompareReposOptions{
+		secondaryRepoOptions: secondaryRepoOptions{
+			Repo:     otherGopts.Repo,
+			password: otherGopts.password,
+		},
+	}
+
+	var err error
+	buf, captureErr := withCaptureStdout(func() error {
+		gopts.JSON = true
+		err = runCompareRepos(context.TODO(), opts, gopts, nil)
+		return nil
+	})
+	rtest.OK(t, captureErr)
+
+	var report CompareReposReport
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &report))
+	return &report, err
+}
+
+func TestCompareRepos(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, opts, env.gopts)
+
+	testRunInit(t, env2.gopts)
+	testRunCopy(t, env.gopts, env2.gopts)
+
+	report, err := testRunCompareRepos(t, env.gopts, env2.gopts)
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, report.Matching)
+	rtest.Assert(t, !report.DivergentRepository, "unexpected divergence: %+v", report)
+	rtest.Assert(t, report.CheckedBlobs > 0, "no blobs were checked")
+
+	// a new snapshot which was not copied yet
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "4")}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 3)
+
+	report, err = testRunCompareRepos(t, env.gopts, env2.gopts)
+	rtest.Assert(t, err != nil, "expected an error for divergent repositories")
+	rtest.Assert(t, report.DivergentRepository, "missing divergence")
+	rtest.Equals(t, 2, report.Matching)
+	rtest.Equals(t, 1, len(report.OnlyInFirst))
+	rtest.Equals(t, 0, len(report.OnlyInSecond))
+
+	found := false
+	for _, id := range snapshotIDs {
+		if id.String() == report.OnlyInFirst[0] {
+			found = true
+		}
+	}
+	rtest.Assert(t, found, "unexpected snapshot %v", report.OnlyInFirst[0])
+
+	// swapping the repositories reports the snapshot as only in the second one
+	report, err = testRunCompareRepos(t, env2.gopts, env.gopts)
+	rtest.Assert(t, err != nil, "expected an error for divergent repositories")
+	rtest.Equals(t, 0, len(report.OnlyInFirst))
+	rtest.Equals(t, 1, len(report.OnlyInSecond))
+}
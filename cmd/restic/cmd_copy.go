@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
+	"github.com/restic/restic/internal/backend/cost"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/spf13/cobra"
@@ -31,6 +34,10 @@ repository, /may occupy up to twice their space/ in the destination repository.
 This can be mitigated by the "--copy-chunker-params" option when initializing a
 new destination repository using the "init" command.
 
+After copying, the command prints how many blobs already existed in the
+destination repository and how many were transferred. It also reports whether
+both repositories use the same chunker parameters.
+
 EXIT STATUS
 ===========
 
@@ -118,6 +125,33 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []
 		return ctx.Err()
 	}
 
+	prices, err := parsePriceTable(gopts)
+	if err != nil {
+		return err
+	}
+	packSize := uint64(secondaryGopts.PackSize) * 1024 * 1024
+	if packSize == 0 {
+		packSize = repository.DefaultPackSize
+	}
+	// suppress the text output in JSON mode, only the summary is printed
+	verbosef, verboseff := Verbosef, Verboseff
+	if gopts.JSON {
+		verbosef = func(string, ...interface{}) {}
+		verboseff = verbosef
+	}
+
+	estimate := func(copyBlobs restic.BlobSet, packs int) {
+		msg := formatCostEstimate(estimateCopyOperations(srcRepo, copyBlobs, packs, packSize), prices, "  ")
+		if prices != nil && !gopts.JSON {
+			Printf("%s", msg)
+		} else {
+			verbosef("%s", msg)
+		}
+	}
+
+	stats := newCopyStats()
+	stats.SameChunkerParams = srcRepo.Config().ChunkerPolynomial == dstRepo.Config().ChunkerPolynomial
+
 	// remember already processed trees across all snapshots
 	visitedTrees := restic.NewIDSet()
 
@@ -132,19 +166,20 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []
 			isCopy := false
 			for _, originalSn := range originalSns {
 				if similarSnapshots(originalSn, sn) {
-					Verboseff("\n%v\n", sn)
-					Verboseff("skipping source snapshot %s, was already copied to snapshot %s\n", sn.ID().Str(), originalSn.ID().Str())
+					verboseff("\n%v\n", sn)
+					verboseff("skipping source snapshot %s, was already copied to snapshot %s\n", sn.ID().Str(), originalSn.ID().Str())
 					isCopy = true
 					break
 				}
 			}
 			if isCopy {
+				stats.SnapshotsSkipped++
 				continue
 			}
 		}
-		Verbosef("\n%v\n", sn)
-		Verbosef("  copy started, this may take a while...\n")
-		if err := copyTree(ctx, srcRepo, dstRepo, visitedTrees, *sn.Tree, gopts.Quiet); err != nil {
+		verbosef("\n%v\n", sn)
+		verbosef("  copy started, this may take a while...\n")
+		if err := copyTree(ctx, srcRepo, dstRepo, visitedTrees, *sn.Tree, gopts.Quiet || gopts.JSON, estimate, stats); err != nil {
 			return err
 		}
 		debug.Log("tree copied")
@@ -159,9 +194,87 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []
 		if err != nil {
 			return err
 		}
-		Verbosef("snapshot %s saved\n", newID.Str())
+		verbosef("snapshot %s saved\n", newID.Str())
+		stats.SnapshotsCopied++
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if gopts.JSON {
+		stats.MessageType = "summary"
+		return json.NewEncoder(globalOptions.stdout).Encode(stats)
+	}
+	printCopyStats(stats)
+	return nil
+}
+
+// copyStats summarizes which blobs of the copied snapshots already existed in
+// the destination repository and which had to be transferred.
+type copyStats struct {
+	MessageType      string `json:"message_type"` // "summary"
+	SnapshotsCopied  uint   `json:"snapshots_copied"`
+	SnapshotsSkipped uint   `json:"snapshots_skipped"`
+
+	ExistingBlobs     uint64 `json:"existing_blobs"`
+	ExistingBytes     uint64 `json:"existing_bytes"`
+	ExistingDataBlobs uint64 `json:"existing_data_blobs"`
+	ExistingDataBytes uint64 `json:"existing_data_bytes"`
+	TransferredBlobs  uint64 `json:"transferred_blobs"`
+	TransferredBytes  uint64 `json:"transferred_bytes"`
+
+	// SameChunkerParams is set if both repositories split files into the
+	// same chunks, which is required to deduplicate file data between
+	// copied snapshots and backups created in the destination repository.
+	SameChunkerParams bool `json:"same_chunker_params"`
+
+	// blobs already counted, a blob is only counted once even if it is
+	// referenced by multiple snapshots
+	counted restic.BlobSet
+}
+
+func newCopyStats() *copyStats {
+	return &copyStats{counted: restic.NewBlobSet()}
+}
+
+// addExisting counts a blob which was found in the destination repository.
+func (s *copyStats) addExisting(h restic.BlobHandle, size uint) {
+	if s.counted.Has(h) {
+		return
+	}
+	s.counted.Insert(h)
+	s.ExistingBlobs++
+	s.ExistingBytes += uint64(size)
+	if h.Type == restic.DataBlob {
+		s.ExistingDataBlobs++
+		s.ExistingDataBytes += uint64(size)
+	}
+}
+
+// addTransferred counts a blob which is copied to the destination repository.
+func (s *copyStats) addTransferred(h restic.BlobHandle, size uint) {
+	if s.counted.Has(h) {
+		return
+	}
+	s.counted.Insert(h)
+	s.TransferredBlobs++
+	s.TransferredBytes += uint64(size)
+}
+
+func printCopyStats(s *copyStats) {
+	if s.SnapshotsCopied == 0 {
+		return
+	}
+	Verbosef("\ncopied %d snapshots, skipped %d snapshots which were already copied\n", s.SnapshotsCopied, s.SnapshotsSkipped)
+	Verbosef("  blobs already in destination: %d (%s, %s of file data)\n", s.ExistingBlobs, ui.FormatBytes(s.ExistingBytes), ui.FormatBytes(s.ExistingDataBytes))
+	Verbosef("  blobs transferred:            %d (%s)\n", s.TransferredBlobs, ui.FormatBytes(s.TransferredBytes))
+	if s.SameChunkerParams {
+		Verbosef("  both repositories use the same chunker parameters\n")
+	} else {
+		Verbosef("  the repositories use different chunker parameters, file data is not deduplicated\n" +
+			"  between copied snapshots and backups created in the destination repository.\n" +
+			"  Initialize the destination using \"init --copy-chunker-params\" to avoid this.\n")
 	}
-	return ctx.Err()
 }
 
 func similarSnapshots(sna *restic.Snapshot, snb *restic.Snapshot) bool {
@@ -184,7 +297,7 @@ func similarSnapshots(sna *restic.Snapshot, snb *restic.Snapshot) bool {
 }
 
 func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository,
-	visitedTrees restic.IDSet, rootTreeID restic.ID, quiet bool) error {
+	visitedTrees restic.IDSet, rootTreeID restic.ID, quiet bool, estimate func(copyBlobs restic.BlobSet, packs int), stats *copyStats) error {
 
 	wg, wgCtx := errgroup.WithContext(ctx)
 
@@ -203,6 +316,16 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 		for _, p := range pb {
 			packList.Insert(p.PackID)
 		}
+		size, _ := srcRepo.LookupBlobSize(h.Type, h.ID)
+		stats.addTransferred(h, size)
+	}
+	// check whether the destination already contains a blob
+	exists := func(h restic.BlobHandle) bool {
+		size, ok := dstRepo.LookupBlobSize(h.Type, h.ID)
+		if ok {
+			stats.addExisting(h, size)
+		}
+		return ok
 	}
 
 	wg.Go(func() error {
@@ -213,7 +336,7 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 
 			// Do we already have this tree blob?
 			treeHandle := restic.BlobHandle{ID: tree.ID, Type: restic.TreeBlob}
-			if _, ok := dstRepo.LookupBlobSize(treeHandle.Type, treeHandle.ID); !ok {
+			if !exists(treeHandle) {
 				// copy raw tree bytes to avoid problems if the serialization changes
 				enqueue(treeHandle)
 			}
@@ -223,7 +346,7 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 				// Copy the blobs for this file.
 				for _, blobID := range entry.Content {
 					h := restic.BlobHandle{Type: restic.DataBlob, ID: blobID}
-					if _, ok := dstRepo.LookupBlobSize(h.Type, h.ID); !ok {
+					if !exists(h) {
 						enqueue(h)
 					}
 				}
@@ -236,6 +359,8 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 		return err
 	}
 
+	estimate(copyBlobs, len(packList))
+
 	bar := newProgressMax(!quiet, uint64(len(packList)), "packs copied")
 	_, err = repository.Repack(ctx, srcRepo, dstRepo, packList, copyBlobs, bar)
 	bar.Done()
@@ -244,3 +369,22 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 	}
 	return nil
 }
+
+// estimateCopyOperations estimates the backend requests which are necessary
+// to copy the blobs stored in the given number of source packs. Besides the
+// new pack files, an index file and the snapshot are uploaded.
+func estimateCopyOperations(srcRepo restic.Repository, copyBlobs restic.BlobSet, packs int, packSize uint64) cost.Operations {
+	var size uint64
+	for h := range copyBlobs {
+		if pbs := srcRepo.LookupBlob(h.Type, h.ID); len(pbs) > 0 {
+			size += uint64(pbs[0].Length)
+		}
+	}
+
+	return cost.Operations{
+		Get:      uint64(packs),
+		Put:      (size+packSize-1)/packSize + 2,
+		Download: size,
+		Upload:   size,
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"testing"
@@ -121,6 +122,47 @@ func TestCopyIncremental(t *testing.T) {
 	testListSnapshots(t, env.gopts, 3)
 }
 
+func testRunCopyJSON(t testing.TB, srcGopts GlobalOptions, dstGopts GlobalOptions) copyStats {
+	buf, err := withCaptureStdout(func() error {
+		srcGopts.JSON = true
+		testRunCopy(t, srcGopts, dstGopts)
+		return nil
+	})
+	rtest.OK(t, err)
+
+	var stats copyStats
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &stats))
+	rtest.Equals(t, "summary", stats.MessageType)
+	return stats
+}
+
+func TestCopyStats(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+
+	testRunInit(t, env2.gopts)
+	stats := testRunCopyJSON(t, env.gopts, env2.gopts)
+	rtest.Equals(t, uint(1), stats.SnapshotsCopied)
+	rtest.Equals(t, uint64(0), stats.ExistingBlobs)
+	rtest.Assert(t, stats.TransferredBlobs > 0 && stats.TransferredBytes > 0, "expected transferred blobs, got %+v", stats)
+	rtest.Assert(t, !stats.SameChunkerParams, "expected different chunker parameters")
+
+	// the data of the new snapshot was already copied
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+	stats = testRunCopyJSON(t, env.gopts, env2.gopts)
+	rtest.Equals(t, uint(1), stats.SnapshotsCopied)
+	rtest.Equals(t, uint(1), stats.SnapshotsSkipped)
+	rtest.Assert(t, stats.ExistingDataBlobs > 0 && stats.ExistingDataBytes > 0, "expected existing data blobs, got %+v", stats)
+	rtest.Assert(t, stats.ExistingBlobs >= stats.ExistingDataBlobs, "inconsistent stats %+v", stats)
+	testRunCheck(t, env2.gopts)
+}
+
 func TestCopyUnstableJSON(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -0,0 +1,345 @@
+//go:build debug
+// +build debug
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+)
+
+var cmdDebugAnalyzePack = &cobra.Command{
+	Use:   "analyze-pack [pack-ID...]",
+	Short: "Analyze the blobs stored in pack files",
+	Long: `
+The "analyze-pack" command loads the given pack files, or a random sample of
+pack files if none are given, and reports the compression ratio and the entropy
+of the contained blobs. It also reports blobs which are stored more than once,
+either within the analyzed pack files or anywhere in the repository according
+to the index. This helps to diagnose repositories which are unexpectedly large.
+
+Blobs with a low entropy which are stored uncompressed could be compressed,
+whereas a high entropy indicates data which was already compressed or
+encrypted before the backup.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDebugAnalyzePack(cmd.Context(), globalOptions, debugAnalyzePackOpts, args)
+	},
+}
+
+type DebugAnalyzePackOptions struct {
+	Sample int
+}
+
+var debugAnalyzePackOpts DebugAnalyzePackOptions
+
+func init() {
+	cmdDebug.AddCommand(cmdDebugAnalyzePack)
+	cmdDebugAnalyzePack.Flags().IntVar(&debugAnalyzePackOpts.Sample, "sample", 20, "analyze a random sample of `n` pack files if no pack files are given")
+}
+
+// entropyBuckets is the number of buckets of the entropy histogram, each
+// bucket covers one bit per byte.
+const entropyBuckets = 8
+
+// lowEntropy is the entropy in bits per byte below which data is considered
+// to be compressible.
+const lowEntropy = 7
+
+// analyzedBlob describes a single blob of an analyzed pack file.
+type analyzedBlob struct {
+	Type            restic.BlobType `json:"type"`
+	ID              restic.ID       `json:"id"`
+	Pack            restic.ID       `json:"pack"`
+	StoredLength    uint            `json:"stored_length"`
+	PlaintextLength uint            `json:"plaintext_length"`
+	Compressed      bool            `json:"compressed"`
+	// Entropy in bits per byte of the plaintext.
+	Entropy float64 `json:"entropy"`
+	// Copies is the number of pack files which contain the blob according to
+	// the index.
+	Copies int `json:"copies"`
+}
+
+type blobTypeStats struct {
+	Blobs          uint64  `json:"blobs"`
+	StoredBytes    uint64  `json:"stored_bytes"`
+	PlaintextBytes uint64  `json:"plaintext_bytes"`
+	Ratio          float64 `json:"compression_ratio"`
+}
+
+type entropyBucket struct {
+	Min            int    `json:"min"`
+	Max            int    `json:"max"`
+	Blobs          uint64 `json:"blobs"`
+	PlaintextBytes uint64 `json:"plaintext_bytes"`
+}
+
+type duplicateStats struct {
+	Blobs uint64 `json:"blobs"`
+	// ExtraBytes is the stored size of all copies except the first one.
+	ExtraBytes uint64 `json:"extra_bytes"`
+}
+
+type packAnalysis struct {
+	Packs   restic.IDs                `json:"packs"`
+	Types   map[string]*blobTypeStats `json:"types"`
+	Entropy []entropyBucket           `json:"entropy"`
+	// Compressible lists uncompressed blobs with a low entropy.
+	Compressible blobTypeStats `json:"compressible"`
+	// DuplicatesInSample are blobs found more than once in the analyzed packs.
+	DuplicatesInSample duplicateStats `json:"duplicates_in_sample"`
+	// DuplicatesInIndex are blobs which the index lists in several packs.
+	DuplicatesInIndex duplicateStats `json:"duplicates_in_index"`
+	Blobs             []analyzedBlob `json:"blobs,omitempty"`
+}
+
+// shannonEntropy returns the entropy of buf in bits per byte.
+func shannonEntropy(buf []byte) float64 {
+	if len(buf) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range buf {
+		counts[b]++
+	}
+
+	entropy := 0.
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(len(buf))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func samplePacks(ctx context.Context, repo *repository.Repository, n int) (restic.IDs, error) {
+	var packs restic.IDs
+	err := repo.List(ctx, restic.PackFile, func(id restic.ID, _ int64) error {
+		packs = append(packs, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rand.Shuffle(len(packs), func(i, j int) {
+		packs[i], packs[j] = packs[j], packs[i]
+	})
+	if len(packs) > n {
+		packs = packs[:n]
+	}
+	return packs, nil
+}
+
+func analyzePack(ctx context.Context, repo *repository.Repository, packID restic.ID) ([]analyzedBlob, error) {
+	var blobs []restic.Blob
+	for pbs := range repo.ListPacksFromIndex(ctx, restic.NewIDSet(packID)) {
+		blobs = append(blobs, pbs.Blobs...)
+	}
+	if len(blobs) == 0 {
+		return nil, errors.Errorf("pack %v is not contained in the index", packID.Str())
+	}
+
+	entries := make(map[restic.BlobHandle]restic.Blob, len(blobs))
+	for _, blob := range blobs {
+		entries[blob.BlobHandle] = blob
+	}
+
+	var result []analyzedBlob
+	err := repo.LoadBlobsFromPack(ctx, packID, blobs, func(bh restic.BlobHandle, buf []byte, err error) error {
+		if err != nil {
+			Warnf("error loading blob %v from pack %v: %v\n", bh, packID.Str(), err)
+			return nil
+		}
+		entry := entries[bh]
+		result = append(result, analyzedBlob{
+			Type:            bh.Type,
+			ID:              bh.ID,
+			Pack:            packID,
+			StoredLength:    entry.Length,
+			PlaintextLength: uint(len(buf)),
+			Compressed:      entry.IsCompressed(),
+			Entropy:         shannonEntropy(buf),
+			Copies:          len(repo.LookupBlob(bh.Type, bh.ID)),
+		})
+		return nil
+	})
+	return result, err
+}
+
+func newPackAnalysis(packs restic.IDs, blobs []analyzedBlob) *packAnalysis {
+	a := &packAnalysis{
+		Packs: packs,
+		Types: make(map[string]*blobTypeStats),
+	}
+	for i := 0; i < entropyBuckets; i++ {
+		a.Entropy = append(a.Entropy, entropyBucket{Min: i, Max: i + 1})
+	}
+
+	seen := restic.NewBlobSet()
+	for _, blob := range blobs {
+		stats, ok := a.Types[blob.Type.String()]
+		if !ok {
+			stats = &blobTypeStats{}
+			a.Types[blob.Type.String()] = stats
+		}
+		stats.Blobs++
+		stats.StoredBytes += uint64(blob.StoredLength)
+		stats.PlaintextBytes += uint64(blob.PlaintextLength)
+
+		bucket := min(int(blob.Entropy), entropyBuckets-1)
+		a.Entropy[bucket].Blobs++
+		a.Entropy[bucket].PlaintextBytes += uint64(blob.PlaintextLength)
+
+		if !blob.Compressed && blob.Entropy < lowEntropy {
+			a.Compressible.Blobs++
+			a.Compressible.StoredBytes += uint64(blob.StoredLength)
+			a.Compressible.PlaintextBytes += uint64(blob.PlaintextLength)
+		}
+
+		h := restic.BlobHandle{Type: blob.Type, ID: blob.ID}
+		if seen.Has(h) {
+			a.DuplicatesInSample.Blobs++
+			a.DuplicatesInSample.ExtraBytes += uint64(blob.StoredLength)
+			continue
+		}
+		seen.Insert(h)
+		if blob.Copies > 1 {
+			a.DuplicatesInIndex.Blobs++
+			a.DuplicatesInIndex.ExtraBytes += uint64(blob.Copies-1) * uint64(blob.StoredLength)
+		}
+	}
+
+	for _, stats := range a.Types {
+		stats.Ratio = compressionRatio(stats)
+	}
+	a.Compressible.Ratio = compressionRatio(&a.Compressible)
+	return a
+}
+
+func compressionRatio(stats *blobTypeStats) float64 {
+	if stats.StoredBytes == 0 {
+		return 0
+	}
+	return float64(stats.PlaintextBytes) / float64(stats.StoredBytes)
+}
+
+func printPackAnalysis(a *packAnalysis, verbose bool) {
+	if verbose {
+		for _, blob := range a.Blobs {
+			Printf("  %v %v in pack %v: stored %v, plaintext %v, entropy %.2f, %d copies\n",
+				blob.Type, blob.ID.Str(), blob.Pack.Str(), ui.FormatBytes(uint64(blob.StoredLength)),
+				ui.FormatBytes(uint64(blob.PlaintextLength)), blob.Entropy, blob.Copies)
+		}
+		Printf("\n")
+	}
+
+	Printf("analyzed %d packs\n\n", len(a.Packs))
+
+	types := make([]string, 0, len(a.Types))
+	for tpe := range a.Types {
+		types = append(types, tpe)
+	}
+	sort.Strings(types)
+	for _, tpe := range types {
+		stats := a.Types[tpe]
+		Printf("%-6v %8d blobs, stored %10v, plaintext %10v, compression ratio %.2f\n",
+			tpe, stats.Blobs, ui.FormatBytes(stats.StoredBytes), ui.FormatBytes(stats.PlaintextBytes), stats.Ratio)
+	}
+
+	Printf("\nentropy in bits per byte:\n")
+	for _, bucket := range a.Entropy {
+		Printf("  %d-%d: %8d blobs, plaintext %10v\n", bucket.Min, bucket.Max, bucket.Blobs, ui.FormatBytes(bucket.PlaintextBytes))
+	}
+
+	Printf("\n%-44s %8d blobs, stored %v\n", fmt.Sprintf("uncompressed with entropy below %d bits/byte:", lowEntropy),
+		a.Compressible.Blobs, ui.FormatBytes(a.Compressible.StoredBytes))
+	Printf("%-44s %8d blobs, extra copies %v\n", "duplicates within the analyzed packs:",
+		a.DuplicatesInSample.Blobs, ui.FormatBytes(a.DuplicatesInSample.ExtraBytes))
+	Printf("%-44s %8d blobs, extra copies %v\n", "stored in several packs according to index:",
+		a.DuplicatesInIndex.Blobs, ui.FormatBytes(a.DuplicatesInIndex.ExtraBytes))
+}
+
+func runDebugAnalyzePack(ctx context.Context, gopts GlobalOptions, opts DebugAnalyzePackOptions, args []string) error {
+	if len(args) == 0 && opts.Sample <= 0 {
+		return errors.Fatal("--sample must be positive")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	err = repo.LoadIndex(ctx, bar)
+	if err != nil {
+		return err
+	}
+
+	var packs restic.IDs
+	for _, name := range args {
+		id, err := restic.ParseID(name)
+		if err != nil {
+			id, err = restic.Find(ctx, repo, restic.PackFile, name)
+			if err != nil {
+				return err
+			}
+		}
+		packs = append(packs, id)
+	}
+	if len(packs) == 0 {
+		packs, err = samplePacks(ctx, repo, opts.Sample)
+		if err != nil {
+			return err
+		}
+	}
+
+	var blobs []analyzedBlob
+	for _, id := range packs {
+		result, err := analyzePack(ctx, repo, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			Warnf("error: %v\n", err)
+			continue
+		}
+		blobs = append(blobs, result...)
+	}
+
+	analysis := newPackAnalysis(packs, blobs)
+	if gopts.JSON {
+		analysis.Blobs = blobs
+		return json.NewEncoder(globalOptions.stdout).Encode(analysis)
+	}
+	if gopts.verbosity >= 2 {
+		analysis.Blobs = blobs
+	}
+	printPackAnalysis(analysis, gopts.verbosity >= 2)
+	return nil
+}
@@ -0,0 +1,121 @@
+//go:build debug
+// +build debug
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestShannonEntropy(t *testing.T) {
+	random := make([]byte, 1<<16)
+	for i := range random {
+		random[i] = byte(i)
+	}
+
+	for _, test := range []struct {
+		name    string
+		buf     []byte
+		entropy float64
+	}{
+		{"empty", nil, 0},
+		{"constant", bytes.Repeat([]byte{'a'}, 100), 0},
+		{"two-symbols", bytes.Repeat([]byte{'a', 'b'}, 100), 1},
+		{"all-bytes", random, 8},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			entropy := shannonEntropy(test.buf)
+			rtest.Assert(t, math.Abs(entropy-test.entropy) < 1e-9, "expected entropy %v, got %v", test.entropy, entropy)
+		})
+	}
+}
+
+func TestNewPackAnalysis(t *testing.T) {
+	pack1 := restic.NewRandomID()
+	pack2 := restic.NewRandomID()
+	dup := restic.NewRandomID()
+
+	blobs := []analyzedBlob{
+		// compressible: uncompressed with low entropy
+		{Type: restic.DataBlob, ID: restic.NewRandomID(), Pack: pack1, StoredLength: 100, PlaintextLength: 100, Entropy: 2, Copies: 1},
+		// already compressed
+		{Type: restic.DataBlob, ID: restic.NewRandomID(), Pack: pack1, StoredLength: 50, PlaintextLength: 200, Compressed: true, Entropy: 3, Copies: 1},
+		// high entropy, stored three times according to the index and twice
+		// in the analyzed packs
+		{Type: restic.DataBlob, ID: dup, Pack: pack1, StoredLength: 300, PlaintextLength: 300, Entropy: 7.9, Copies: 3},
+		{Type: restic.DataBlob, ID: dup, Pack: pack2, StoredLength: 300, PlaintextLength: 300, Entropy: 7.9, Copies: 3},
+		{Type: restic.TreeBlob, ID: restic.NewRandomID(), Pack: pack2, StoredLength: 10, PlaintextLength: 40, Compressed: true, Entropy: 8, Copies: 1},
+	}
+
+	a := newPackAnalysis(restic.IDs{pack1, pack2}, blobs)
+
+	rtest.Equals(t, 2, len(a.Types))
+	rtest.Equals(t, blobTypeStats{Blobs: 4, StoredBytes: 750, PlaintextBytes: 900, Ratio: 1.2}, *a.Types["data"])
+	rtest.Equals(t, blobTypeStats{Blobs: 1, StoredBytes: 10, PlaintextBytes: 40, Ratio: 4}, *a.Types["tree"])
+
+	rtest.Equals(t, entropyBuckets, len(a.Entropy))
+	rtest.Equals(t, uint64(1), a.Entropy[2].Blobs)
+	rtest.Equals(t, uint64(1), a.Entropy[3].Blobs)
+	// entropy of exactly 8 bits per byte is counted in the last bucket
+	rtest.Equals(t, uint64(3), a.Entropy[7].Blobs)
+	rtest.Equals(t, uint64(640), a.Entropy[7].PlaintextBytes)
+
+	rtest.Equals(t, blobTypeStats{Blobs: 1, StoredBytes: 100, PlaintextBytes: 100, Ratio: 1}, a.Compressible)
+	rtest.Equals(t, duplicateStats{Blobs: 1, ExtraBytes: 300}, a.DuplicatesInSample)
+	rtest.Equals(t, duplicateStats{Blobs: 1, ExtraBytes: 600}, a.DuplicatesInIndex)
+}
+
+func testRunDebugAnalyzePack(t testing.TB, gopts GlobalOptions, opts DebugAnalyzePackOptions, args []string) packAnalysis {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runDebugAnalyzePack(context.TODO(), gopts, opts, args)
+	})
+	rtest.OK(t, err)
+
+	var a packAnalysis
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &a))
+	return a
+}
+
+func TestDebugAnalyzePack(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+
+	packs := testRunList(t, "packs", env.gopts)
+	rtest.Assert(t, len(packs) > 1, "expected several packs, got %v", len(packs))
+
+	var args []string
+	for _, id := range packs {
+		args = append(args, id.String())
+	}
+	a := testRunDebugAnalyzePack(t, env.gopts, DebugAnalyzePackOptions{}, args)
+	rtest.Equals(t, len(packs), len(a.Packs))
+
+	var blobs uint64
+	for _, stats := range a.Types {
+		blobs += stats.Blobs
+	}
+	rtest.Equals(t, uint64(len(a.Blobs)), blobs)
+	rtest.Assert(t, a.Types["data"] != nil && a.Types["data"].Blobs > 0, "no data blobs analyzed")
+	rtest.Assert(t, a.Types["tree"] != nil && a.Types["tree"].Blobs > 0, "no tree blobs analyzed")
+	rtest.Equals(t, duplicateStats{}, a.DuplicatesInSample)
+	rtest.Equals(t, duplicateStats{}, a.DuplicatesInIndex)
+
+	// pack IDs can be abbreviated
+	a = testRunDebugAnalyzePack(t, env.gopts, DebugAnalyzePackOptions{}, []string{packs[0].Str()})
+	rtest.Equals(t, restic.IDs{packs[0]}, a.Packs)
+
+	// a random sample is analyzed if no pack is given
+	a = testRunDebugAnalyzePack(t, env.gopts, DebugAnalyzePackOptions{Sample: 1}, nil)
+	rtest.Equals(t, 1, len(a.Packs))
+}
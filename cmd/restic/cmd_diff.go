@@ -3,15 +3,22 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"os"
 	"path"
 	"reflect"
 	"sort"
+	"strings"
 
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/dump"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/walker"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 )
 
 var cmdDiff = &cobra.Command{
@@ -36,6 +43,12 @@ To only compare files in specific subfolders, you can use the
 "snapshotID:subfolder" syntax, where "subfolder" is a path within the
 snapshot.
 
+The "--format" option exports all files, directories and symlinks which were
+added or whose content was modified in the second snapshot as a "tar" or "zip"
+archive. Removed items are not part of the archive. The archive is written to
+stdout unless "--target" is specified, in which case the list of changes is
+still printed.
+
 EXIT STATUS
 ===========
 
@@ -55,6 +68,8 @@ Exit status is 12 if the password is incorrect.
 // DiffOptions collects all options for the diff command.
 type DiffOptions struct {
 	ShowMetadata bool
+	Format       string
+	Target       string
 }
 
 var diffOptions DiffOptions
@@ -64,6 +79,8 @@ func init() {
 
 	f := cmdDiff.Flags()
 	f.BoolVar(&diffOptions.ShowMetadata, "metadata", false, "print changes in metadata")
+	f.StringVar(&diffOptions.Format, "format", "", "export added and modified files as archive in `format` \"tar\" or \"zip\"")
+	f.StringVarP(&diffOptions.Target, "target", "t", "", "write the archive to target `path` instead of stdout")
 }
 
 func loadSnapshot(ctx context.Context, be restic.Lister, repo restic.LoaderUnpacked, desc string) (*restic.Snapshot, string, error) {
@@ -76,9 +93,13 @@ func loadSnapshot(ctx context.Context, be restic.Lister, repo restic.LoaderUnpac
 
 // Comparer collects all things needed to compare two snapshots.
 type Comparer struct {
-	repo        restic.BlobLoader
+	// loader loads the trees of both snapshots
+	loader      *walker.TreeLoader
 	opts        DiffOptions
 	printChange func(change *Change)
+	// exportCh receives all nodes which were added or modified in the second
+	// snapshot, if set.
+	exportCh chan<- *restic.Node
 }
 
 type Change struct {
@@ -171,13 +192,40 @@ func updateBlobs(repo restic.Loader, blobs restic.BlobSet, stats *DiffStat) {
 	}
 }
 
-func (c *Comparer) printDir(ctx context.Context, mode string, stats *DiffStat, blobs restic.BlobSet, prefix string, id restic.ID) error {
-	debug.Log("print %v tree %v", mode, id)
-	tree, err := restic.LoadTree(ctx, c.repo, id)
-	if err != nil {
-		return err
+// export sends a copy of node, which is part of the second snapshot, to
+// exportCh using name as path.
+func (c *Comparer) export(ctx context.Context, name string, node *restic.Node) error {
+	if c.exportCh == nil {
+		return nil
+	}
+	// the archive formats only support these node types
+	if node.Type != restic.NodeTypeFile && node.Type != restic.NodeTypeDir && node.Type != restic.NodeTypeSymlink {
+		return nil
 	}
 
+	n := *node
+	n.Path = path.Clean(name)
+	select {
+	case c.exportCh <- &n:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// subtreeIDs returns the subtrees of all directories in nodes.
+func subtreeIDs(nodes []*restic.Node) restic.IDs {
+	var ids restic.IDs
+	for _, node := range nodes {
+		if node.Type == restic.NodeTypeDir {
+			ids = append(ids, *node.Subtree)
+		}
+	}
+	return ids
+}
+
+func (c *Comparer) printDir(ctx context.Context, mode string, stats *DiffStat, blobs restic.BlobSet, prefix string, tree *restic.Tree) error {
+	queue := c.loader.Queue(ctx, subtreeIDs(tree.Nodes))
 	for _, node := range tree.Nodes {
 		if ctx.Err() != nil {
 			return ctx.Err()
@@ -190,9 +238,18 @@ func (c *Comparer) printDir(ctx context.Context, mode string, stats *DiffStat, b
 		c.printChange(NewChange(name, mode))
 		stats.Add(node)
 		addBlobs(blobs, node)
+		if mode == "+" {
+			if err := c.export(ctx, name, node); err != nil {
+				return err
+			}
+		}
 
 		if node.Type == restic.NodeTypeDir {
-			err := c.printDir(ctx, mode, stats, blobs, name, *node.Subtree)
+			debug.Log("print %v tree %v", mode, node.Subtree)
+			subtree, err := queue.Next()
+			if err == nil {
+				err = c.printDir(ctx, mode, stats, blobs, name, subtree)
+			}
 			if err != nil && err != context.Canceled {
 				Warnf("error: %v\n", err)
 			}
@@ -202,13 +259,8 @@ func (c *Comparer) printDir(ctx context.Context, mode string, stats *DiffStat, b
 	return ctx.Err()
 }
 
-func (c *Comparer) collectDir(ctx context.Context, blobs restic.BlobSet, id restic.ID) error {
-	debug.Log("print tree %v", id)
-	tree, err := restic.LoadTree(ctx, c.repo, id)
-	if err != nil {
-		return err
-	}
-
+func (c *Comparer) collectDir(ctx context.Context, blobs restic.BlobSet, tree *restic.Tree) error {
+	queue := c.loader.Queue(ctx, subtreeIDs(tree.Nodes))
 	for _, node := range tree.Nodes {
 		if ctx.Err() != nil {
 			return ctx.Err()
@@ -217,7 +269,11 @@ func (c *Comparer) collectDir(ctx context.Context, blobs restic.BlobSet, id rest
 		addBlobs(blobs, node)
 
 		if node.Type == restic.NodeTypeDir {
-			err := c.collectDir(ctx, blobs, *node.Subtree)
+			debug.Log("collect tree %v", node.Subtree)
+			subtree, err := queue.Next()
+			if err == nil {
+				err = c.collectDir(ctx, blobs, subtree)
+			}
 			if err != nil && err != context.Canceled {
 				Warnf("error: %v\n", err)
 			}
@@ -250,19 +306,54 @@ func uniqueNodeNames(tree1, tree2 *restic.Tree) (tree1Nodes, tree2Nodes map[stri
 	return tree1Nodes, tree2Nodes, uniqueNames
 }
 
+// diffSubtrees returns the subtrees loaded by diffTrees in the order in which
+// they are needed.
+func diffSubtrees(tree1Nodes, tree2Nodes map[string]*restic.Node, names []string) restic.IDs {
+	var ids restic.IDs
+	for _, name := range names {
+		node1, t1 := tree1Nodes[name]
+		node2, t2 := tree2Nodes[name]
+		switch {
+		case t1 && t2:
+			if node1.Type == restic.NodeTypeDir && node2.Type == restic.NodeTypeDir {
+				ids = append(ids, *node1.Subtree)
+				if !(*node1.Subtree).Equal(*node2.Subtree) {
+					ids = append(ids, *node2.Subtree)
+				}
+			}
+		case t1 && !t2:
+			if node1.Type == restic.NodeTypeDir {
+				ids = append(ids, *node1.Subtree)
+			}
+		case !t1 && t2:
+			if node2.Type == restic.NodeTypeDir {
+				ids = append(ids, *node2.Subtree)
+			}
+		}
+	}
+	return ids
+}
+
+// diffTree loads the trees id1 and id2 and compares them.
 func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, prefix string, id1, id2 restic.ID) error {
 	debug.Log("diffing %v to %v", id1, id2)
-	tree1, err := restic.LoadTree(ctx, c.repo, id1)
+	queue := c.loader.Queue(ctx, restic.IDs{id1, id2})
+	tree1, err := queue.Next()
 	if err != nil {
 		return err
 	}
 
-	tree2, err := restic.LoadTree(ctx, c.repo, id2)
+	tree2, err := queue.Next()
 	if err != nil {
 		return err
 	}
 
+	return c.diffTrees(ctx, stats, prefix, tree1, tree2)
+}
+
+func (c *Comparer) diffTrees(ctx context.Context, stats *DiffStatsContainer, prefix string, tree1, tree2 *restic.Tree) error {
 	tree1Nodes, tree2Nodes, names := uniqueNodeNames(tree1, tree2)
+	queue := c.loader.Queue(ctx, diffSubtrees(tree1Nodes, tree2Nodes, names))
 
 	for _, name := range names {
 		if ctx.Err() != nil {
@@ -310,13 +401,27 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			if mod != "" {
 				c.printChange(NewChange(name, mod))
 			}
+			if strings.ContainsAny(mod, "TM") {
+				if err := c.export(ctx, name, node2); err != nil {
+					return err
+				}
+			}
 
 			if node1.Type == restic.NodeTypeDir && node2.Type == restic.NodeTypeDir {
-				var err error
+				debug.Log("diffing %v to %v", node1.Subtree, node2.Subtree)
+				subtree1, err := queue.Next()
 				if (*node1.Subtree).Equal(*node2.Subtree) {
-					err = c.collectDir(ctx, stats.BlobsCommon, *node1.Subtree)
+					if err == nil {
+						err = c.collectDir(ctx, stats.BlobsCommon, subtree1)
+					}
 				} else {
-					err = c.diffTree(ctx, stats, name, *node1.Subtree, *node2.Subtree)
+					subtree2, err2 := queue.Next()
+					if err == nil {
+						err = err2
+					}
+					if err == nil {
+						err = c.diffTrees(ctx, stats, name, subtree1, subtree2)
+					}
 				}
 				if err != nil && err != context.Canceled {
 					Warnf("error: %v\n", err)
@@ -331,7 +436,10 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			stats.Removed.Add(node1)
 
 			if node1.Type == restic.NodeTypeDir {
-				err := c.printDir(ctx, "-", &stats.Removed, stats.BlobsBefore, prefix, *node1.Subtree)
+				subtree, err := queue.Next()
+				if err == nil {
+					err = c.printDir(ctx, "-", &stats.Removed, stats.BlobsBefore, prefix, subtree)
+				}
 				if err != nil && err != context.Canceled {
 					Warnf("error: %v\n", err)
 				}
@@ -343,9 +451,15 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			}
 			c.printChange(NewChange(prefix, "+"))
 			stats.Added.Add(node2)
+			if err := c.export(ctx, prefix, node2); err != nil {
+				return err
+			}
 
 			if node2.Type == restic.NodeTypeDir {
-				err := c.printDir(ctx, "+", &stats.Added, stats.BlobsAfter, prefix, *node2.Subtree)
+				subtree, err := queue.Next()
+				if err == nil {
+					err = c.printDir(ctx, "+", &stats.Added, stats.BlobsAfter, prefix, subtree)
+				}
 				if err != nil && err != context.Canceled {
 					Warnf("error: %v\n", err)
 				}
@@ -356,11 +470,60 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 	return ctx.Err()
 }
 
+// diffTreeToArchive compares both trees and writes all added or modified
+// nodes to an archive in the format requested in opts.
+func (c *Comparer) diffTreeToArchive(ctx context.Context, repo restic.Loader, opts DiffOptions, stats *DiffStatsContainer, id1, id2 restic.ID) error {
+	var w io.Writer = globalOptions.stdout
+	if opts.Target != "" {
+		file, err := os.Create(opts.Target)
+		if err != nil {
+			return fmt.Errorf("cannot write archive: %w", err)
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		w = file
+	}
+
+	// buffered to deal with variable download/write speeds
+	ch := make(chan *restic.Node, 10)
+	c.exportCh = ch
+	defer func() {
+		c.exportCh = nil
+	}()
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	wg.Go(func() error {
+		defer close(ch)
+		return c.diffTree(wgCtx, stats, "/", id1, id2)
+	})
+	wg.Go(func() error {
+		return dump.New(opts.Format, repo, w).DumpNodes(wgCtx, ch)
+	})
+	return wg.Wait()
+}
+
 func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []string) error {
 	if len(args) != 2 {
 		return errors.Fatalf("specify two snapshot IDs")
 	}
 
+	switch opts.Format {
+	case "", "tar", "zip":
+	default:
+		return errors.Fatalf("unknown archive format %q", opts.Format)
+	}
+	if opts.Target != "" && opts.Format == "" {
+		return errors.Fatal("--target requires --format")
+	}
+	// the archive occupies stdout, thus suppress all other output
+	archiveToStdout := opts.Format != "" && opts.Target == ""
+	if archiveToStdout {
+		if err := checkStdoutArchive(); err != nil {
+			return err
+		}
+	}
+
 	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
 	if err != nil {
 		return err
@@ -382,7 +545,7 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 		return err
 	}
 
-	if !gopts.JSON {
+	if !gopts.JSON && !archiveToStdout {
 		Verbosef("comparing snapshot %v to %v:\n\n", sn1.ID().Str(), sn2.ID().Str())
 	}
 	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
@@ -409,8 +572,8 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 	}
 
 	c := &Comparer{
-		repo: repo,
-		opts: opts,
+		loader: walker.NewTreeLoader(repo, int(repo.Connections())),
+		opts:   opts,
 		printChange: func(change *Change) {
 			Printf("%-5s%v\n", change.Modifier, change.Path)
 		},
@@ -426,7 +589,7 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 		}
 	}
 
-	if gopts.Quiet {
+	if gopts.Quiet || archiveToStdout {
 		c.printChange = func(_ *Change) {}
 	}
 
@@ -441,7 +604,11 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 	stats.BlobsBefore.Insert(restic.BlobHandle{Type: restic.TreeBlob, ID: *sn1.Tree})
 	stats.BlobsAfter.Insert(restic.BlobHandle{Type: restic.TreeBlob, ID: *sn2.Tree})
 
-	err = c.diffTree(ctx, stats, "/", *sn1.Tree, *sn2.Tree)
+	if opts.Format != "" {
+		err = c.diffTreeToArchive(ctx, repo, opts, stats, *sn1.Tree, *sn2.Tree)
+	} else {
+		err = c.diffTree(ctx, stats, "/", *sn1.Tree, *sn2.Tree)
+	}
 	if err != nil {
 		return err
 	}
@@ -450,6 +617,10 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 	updateBlobs(repo, stats.BlobsBefore.Sub(both).Sub(stats.BlobsCommon), &stats.Removed)
 	updateBlobs(repo, stats.BlobsAfter.Sub(both).Sub(stats.BlobsCommon), &stats.Added)
 
+	if archiveToStdout {
+		return nil
+	}
+
 	if gopts.JSON {
 		err := json.NewEncoder(globalOptions.stdout).Encode(stats)
 		if err != nil {
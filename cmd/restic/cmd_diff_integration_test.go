@@ -1,13 +1,16 @@
 package main
 
 import (
+	"archive/tar"
 	"bufio"
 	"context"
 	"encoding/json"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
 
@@ -15,10 +18,14 @@ import (
 )
 
 func testRunDiffOutput(gopts GlobalOptions, firstSnapshotID string, secondSnapshotID string) (string, error) {
+	opts := DiffOptions{
+		ShowMetadata: false,
+	}
+	return testRunDiffOutputWithOpts(gopts, opts, firstSnapshotID, secondSnapshotID)
+}
+
+func testRunDiffOutputWithOpts(gopts GlobalOptions, opts DiffOptions, firstSnapshotID string, secondSnapshotID string) (string, error) {
 	buf, err := withCaptureStdout(func() error {
-		opts := DiffOptions{
-			ShowMetadata: false,
-		}
 		return runDiff(context.TODO(), opts, gopts, []string{firstSnapshotID, secondSnapshotID})
 	})
 	return buf.String(), err
@@ -191,3 +198,37 @@ func TestDiffJSON(t *testing.T) {
 		stat.ChangedFiles == 1, "unexpected statistics")
 	rtest.Assert(t, stat.SourceSnapshot == firstSnapshotID && stat.TargetSnapshot == secondSnapshotID, "unexpected snapshot ids")
 }
+
+func TestDiffArchive(t *testing.T) {
+	env, cleanup, firstSnapshotID, secondSnapshotID := setupDiffRepo(t)
+	defer cleanup()
+
+	opts := DiffOptions{Format: "tar"}
+	buf, err := withCaptureStdout(func() error {
+		return runDiff(context.TODO(), opts, env.gopts, []string{firstSnapshotID, secondSnapshotID})
+	})
+	rtest.OK(t, err)
+
+	var names []string
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		names = append(names, path.Base(hdr.Name))
+	}
+	sort.Strings(names)
+	rtest.Equals(t, []string{"modfile1", "modfile2", "modfile3", "modfile4", "submoddir2", "subsubmoddir"}, names)
+
+	// the change list is still printed if the archive is written to a file
+	env.gopts.Quiet = false
+	opts.Target = filepath.Join(env.base, "diff.tar")
+	out, err := testRunDiffOutputWithOpts(env.gopts, opts, firstSnapshotID, secondSnapshotID)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(out, "modfile2"), "missing change list in output %q", out)
+	fi, err := os.Stat(opts.Target)
+	rtest.OK(t, err)
+	rtest.Assert(t, fi.Size() > 512*1024, "archive is too small: %v bytes", fi.Size())
+}
@@ -146,6 +146,10 @@ func runDump(ctx context.Context, opts DumpOptions, gopts GlobalOptions, args []
 	}
 	defer unlock()
 
+	if err := enablePackCache(repo, gopts); err != nil {
+		return err
+	}
+
 	sn, subfolder, err := (&restic.SnapshotFilter{
 		Hosts: opts.Hosts,
 		Paths: opts.Paths,
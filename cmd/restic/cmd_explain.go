@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var cmdExplain = &cobra.Command{
+	Use:   "explain name",
+	Short: "Print documentation for a flag or an extended option",
+	Long: `
+The "explain" command prints the documentation for a command line flag or an
+extended option. This includes the type and the default value, the commands
+which accept a flag and an extended description for some options.
+
+Extended options are specified as "namespace.name", for example
+"s3.storage-class". If only the namespace is given, all extended options in
+this namespace are explained. Command line flags are specified by their long
+name, the leading dashes are optional but require separating the name using
+"--", for example "restic explain -- --pack-size".
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+`,
+	Example: `restic explain pack-size
+restic explain s3.storage-class
+restic explain s3`,
+	GroupID:           cmdGroupAdvanced,
+	DisableAutoGenTag: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.Fatal("the explain command expects exactly one flag or extended option")
+		}
+		return runExplain(globalOptions.stdout, cmdRoot, args[0])
+	},
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdExplain)
+}
+
+func runExplain(w io.Writer, root *cobra.Command, name string) error {
+	name = strings.TrimLeft(name, "-")
+	if name == "" {
+		return errors.Fatal("empty name")
+	}
+
+	if opt, ok := options.Lookup(name); ok {
+		explainOption(w, opt)
+		return nil
+	}
+
+	var namespace []options.Help
+	for _, opt := range options.List() {
+		if opt.Namespace == strings.ToLower(name) {
+			namespace = append(namespace, opt)
+		}
+	}
+	if len(namespace) > 0 {
+		for i, opt := range namespace {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			explainOption(w, opt)
+		}
+		return nil
+	}
+
+	uses := findFlag(root, name)
+	if len(uses) == 0 {
+		return errors.Fatalf("unknown flag or extended option %q, use \"restic options\" to list the extended options", name)
+	}
+	for i, use := range uses {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		explainFlag(w, use)
+	}
+	return nil
+}
+
+func explainOption(w io.Writer, opt options.Help) {
+	key := opt.Namespace + "." + opt.Name
+	fmt.Fprintf(w, "%s\n", key)
+	fmt.Fprintf(w, "  Type:    %s\n", opt.Type)
+	if opt.Default != "" {
+		fmt.Fprintf(w, "  Default: %s\n", opt.Default)
+	}
+	fmt.Fprintf(w, "  Usage:   -o %s=%s\n\n", key, opt.Type)
+	writeWrapped(w, opt.Text, "  ")
+	if opt.Details != "" {
+		fmt.Fprintln(w)
+		writeWrapped(w, opt.Details, "  ")
+	}
+}
+
+// flagUse describes a flag and the commands which accept it. Global flags
+// have no commands.
+type flagUse struct {
+	flag     *pflag.Flag
+	commands []string
+}
+
+// findFlag returns all flags with the given name. Flags which are identical for
+// several commands are merged.
+func findFlag(root *cobra.Command, name string) []flagUse {
+	if f := root.PersistentFlags().Lookup(name); f != nil {
+		return []flagUse{{flag: f}}
+	}
+
+	var uses []flagUse
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		for _, sub := range cmd.Commands() {
+			if f := sub.LocalFlags().Lookup(name); f != nil {
+				addFlagUse(&uses, f, sub.CommandPath()[len(root.Name())+1:])
+			}
+			walk(sub)
+		}
+	}
+	walk(root)
+
+	sort.Slice(uses, func(i, j int) bool {
+		return uses[i].commands[0] < uses[j].commands[0]
+	})
+	return uses
+}
+
+func addFlagUse(uses *[]flagUse, f *pflag.Flag, command string) {
+	for i, use := range *uses {
+		if use.flag.Usage == f.Usage && use.flag.DefValue == f.DefValue && use.flag.Value.Type() == f.Value.Type() {
+			(*uses)[i].commands = append((*uses)[i].commands, command)
+			sort.Strings((*uses)[i].commands)
+			return
+		}
+	}
+	*uses = append(*uses, flagUse{flag: f, commands: []string{command}})
+}
+
+func explainFlag(w io.Writer, use flagUse) {
+	f := use.flag
+	name := "--" + f.Name
+	if f.Shorthand != "" {
+		name = "-" + f.Shorthand + ", " + name
+	}
+	fmt.Fprintf(w, "%s\n", name)
+
+	if len(use.commands) == 0 {
+		fmt.Fprintf(w, "  Commands: all (global flag)\n")
+	} else {
+		fmt.Fprintf(w, "  Commands: %s\n", strings.Join(use.commands, ", "))
+	}
+	typ := f.Value.Type()
+	fmt.Fprintf(w, "  Type:     %s\n", typ)
+	if f.DefValue != "" && f.DefValue != "[]" && !(typ != "bool" && f.DefValue == "0") {
+		fmt.Fprintf(w, "  Default:  %s\n", f.DefValue)
+	}
+	if f.Deprecated != "" {
+		fmt.Fprintf(w, "  Deprecated: %s\n", f.Deprecated)
+	}
+	fmt.Fprintln(w)
+
+	// the backquotes mark the name of the flag value in the usage text
+	writeWrapped(w, strings.ReplaceAll(f.Usage, "`", ""), "  ")
+}
+
+// writeWrapped writes text wrapped at 80 columns, each line starts with indent.
+func writeWrapped(w io.Writer, text, indent string) {
+	const width = 80
+
+	line := indent
+	for _, word := range strings.Fields(text) {
+		if len(line) > len(indent) && len(line)+1+len(word) > width {
+			fmt.Fprintln(w, line)
+			line = indent
+		}
+		if len(line) > len(indent) {
+			line += " "
+		}
+		line += word
+	}
+	if len(line) > len(indent) {
+		fmt.Fprintln(w, line)
+	}
+}
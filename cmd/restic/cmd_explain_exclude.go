@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var cmdExplainExclude = &cobra.Command{
+	Use:   "explain-exclude [flags] path [path...]",
+	Short: "Show whether a path is excluded from a backup and by which rule",
+	Long: `
+The "explain-exclude" command evaluates the exclude options of the backup
+command against the given paths. For each path it prints whether it would be
+included in the backup or the rule which excludes it. If a parent directory of
+the path is excluded, the rule for that directory is printed. If a negated
+exclude pattern includes the path again, that pattern is printed as well. With
+--walk, the given directories are traversed and all items below them are
+evaluated, skipping excluded directories like the backup command does. The
+content of files is not read, except for the files required by
+--exclude-if-present.
+
+The options --expect-included and --expect-excluded allow validating a backup
+policy, for example in a CI pipeline: if one of the given paths is not included
+or excluded as expected, the mismatch is reported and the exit status is 5.
+
+The exclude options are specified exactly as for the backup command. The
+options --one-file-system, --exclude-if-present and --exclude-caches depend on
+the backup targets, use --target to specify them. Parent directories of the
+targets are not evaluated, as the backup command does not evaluate them either.
+The restic cache directory is not taken into account.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful and all expectations were met.
+Exit status is 1 if there was any error.
+Exit status is 5 if at least one path was not included or excluded as expected.
+`,
+	Example: `restic explain-exclude --exclude "*.tmp" --exclude-caches /home/user/project/build.tmp
+restic explain-exclude --exclude-file excludes.txt --target /home /home/user/.cache`,
+	GroupID:           cmdGroupAdvanced,
+	DisableAutoGenTag: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		return runExplainExclude(explainExcludeOptions, globalOptions, args)
+	},
+}
+
+// ExplainExcludeOptions bundles all options for the explain-exclude and
+// filter test commands.
+type ExplainExcludeOptions struct {
+	BackupOptions
+	Targets        []string
+	Walk           bool
+	ExpectIncluded []string
+	ExpectExcluded []string
+}
+
+func (opts *ExplainExcludeOptions) Add(f *pflag.FlagSet) {
+	addExcludeFlags(f, &opts.BackupOptions)
+	f.StringArrayVar(&opts.Targets, "target", nil, "evaluate the paths as part of a backup of `directory` (can be specified multiple times)")
+	f.BoolVar(&opts.Walk, "walk", false, "evaluate all items below the given directories")
+	f.StringArrayVar(&opts.ExpectIncluded, "expect-included", nil, "fail if `path` is excluded (can be specified multiple times)")
+	f.StringArrayVar(&opts.ExpectExcluded, "expect-excluded", nil, "fail if `path` is included (can be specified multiple times)")
+}
+
+var explainExcludeOptions ExplainExcludeOptions
+
+// ErrFilterMismatch is used to report that a path was not included or
+// excluded as expected.
+var ErrFilterMismatch = errors.New("at least one path was not included or excluded as expected")
+
+func init() {
+	cmdRoot.AddCommand(cmdExplainExclude)
+
+	explainExcludeOptions.Add(cmdExplainExclude.Flags())
+}
+
+// excludeExplanation is the result of evaluating the exclude rules for a path.
+type excludeExplanation struct {
+	Path     string `json:"path"`
+	Excluded bool   `json:"excluded"`
+	// ExcludedPath is either Path or the parent directory which is excluded.
+	ExcludedPath string `json:"excluded_path,omitempty"`
+	// Rule is the rule which excludes the path or, for included paths, the
+	// negated pattern which includes it again.
+	Rule string `json:"rule,omitempty"`
+}
+
+func runExplainExclude(opts ExplainExcludeOptions, gopts GlobalOptions, args []string) error {
+	if len(args) == 0 && len(opts.ExpectIncluded) == 0 && len(opts.ExpectExcluded) == 0 {
+		return errors.Fatal("no path given")
+	}
+
+	targets, byNameRules, rules, err := collectExcludeRules(opts.BackupOptions, opts.Targets)
+	if err != nil {
+		return err
+	}
+	filesys := fs.Local{}
+	report := func(result excludeExplanation) error {
+		return printExcludeExplanation(gopts.stdout, gopts.JSON, result)
+	}
+
+	for _, arg := range args {
+		item, err := filepath.Abs(arg)
+		if err != nil {
+			return errors.Fatalf("invalid path %v: %v", arg, err)
+		}
+		result, err := explainExclude(item, targets, byNameRules, rules, filesys)
+		if err != nil {
+			return err
+		}
+		if err := report(result); err != nil {
+			return err
+		}
+		if opts.Walk && !result.Excluded {
+			if err := walkExcludes(item, byNameRules, rules, filesys, report); err != nil {
+				return err
+			}
+		}
+	}
+
+	mismatches := 0
+	check := func(paths []string, expectExcluded bool) error {
+		for _, p := range paths {
+			item, err := filepath.Abs(p)
+			if err != nil {
+				return errors.Fatalf("invalid path %v: %v", p, err)
+			}
+			result, err := explainExclude(item, targets, byNameRules, rules, filesys)
+			if err != nil {
+				return err
+			}
+			if result.Excluded == expectExcluded {
+				continue
+			}
+
+			mismatches++
+			if expectExcluded {
+				Warnf("%v: expected to be excluded, but it is included\n", item)
+			} else {
+				Warnf("%v: expected to be included, but it is excluded\n", item)
+			}
+			if err := report(result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(opts.ExpectIncluded, false); err != nil {
+		return err
+	}
+	if err := check(opts.ExpectExcluded, true); err != nil {
+		return err
+	}
+
+	if mismatches > 0 {
+		return ErrFilterMismatch
+	}
+	return nil
+}
+
+// collectExcludeRules returns the absolute paths of the targets and the rules
+// created from the exclude options of opts, like the backup command does.
+func collectExcludeRules(opts BackupOptions, targetArgs []string) (targets []string, byNameRules []rejectByNameRule, rules []rejectRule, err error) {
+	for _, target := range targetArgs {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return nil, nil, nil, errors.Fatalf("invalid target %v: %v", target, err)
+		}
+		targets = append(targets, abs)
+	}
+	if opts.ExcludeOtherFS && len(targets) == 0 {
+		return nil, nil, nil, errors.Fatal("--one-file-system requires --target")
+	}
+
+	byNameRules, err = collectRejectByNameRules(opts, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rules, err = collectRejectRules(opts, targets, fs.Local{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return targets, byNameRules, rules, nil
+}
+
+// explainExclude evaluates the rules for item and all its parent directories
+// below the target which contains item. Without targets, all parent
+// directories are evaluated.
+func explainExclude(item string, targets []string, byNameRules []rejectByNameRule, rules []rejectRule, filesys fs.FS) (excludeExplanation, error) {
+	isTarget := func(p string) bool {
+		for _, target := range targets {
+			if p == target {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(targets) > 0 {
+		found := false
+		for _, target := range targets {
+			if fs.HasPathPrefix(target, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return excludeExplanation{}, errors.Fatalf("%v is not contained in any of the targets", item)
+		}
+	}
+
+	// collect the item and its parents, starting at the item
+	var items []string
+	for p := item; ; p = filepath.Dir(p) {
+		items = append(items, p)
+		if isTarget(p) || filepath.Dir(p) == p {
+			break
+		}
+	}
+
+	for i := len(items) - 1; i >= 0; i-- {
+		result, _, err := evaluateExclude(items[i], byNameRules, rules, filesys)
+		if err != nil {
+			return excludeExplanation{}, errors.Fatalf("%v", err)
+		}
+		if result.Excluded {
+			result.Path = item
+			return result, nil
+		}
+	}
+	return excludeExplanation{Path: item, Rule: matchNegatingRules(byNameRules, item)}, nil
+}
+
+// evaluateExclude evaluates the rules for item only, without its parent
+// directories. The file info of item is returned if it was required to
+// evaluate the rules.
+func evaluateExclude(item string, byNameRules []rejectByNameRule, rules []rejectRule, filesys fs.FS) (excludeExplanation, *fs.ExtendedFileInfo, error) {
+	var fi *fs.ExtendedFileInfo
+	rule := matchRejectByNameRules(byNameRules, item)
+	if rule == "" {
+		var err error
+		fi, err = filesys.Lstat(item)
+		if err != nil {
+			return excludeExplanation{}, nil, err
+		}
+		rule = matchRejectRules(rules, item, fi, filesys)
+	}
+	if rule != "" {
+		return excludeExplanation{Path: item, Excluded: true, ExcludedPath: item, Rule: rule}, fi, nil
+	}
+	return excludeExplanation{Path: item, Rule: matchNegatingRules(byNameRules, item)}, fi, nil
+}
+
+// walkExcludes evaluates the rules for all items below dir in the same way as
+// the backup command, that is excluded directories are not traversed. The
+// result for each item is passed to report.
+func walkExcludes(dir string, byNameRules []rejectByNameRule, rules []rejectRule, filesys fs.FS, report func(excludeExplanation) error) error {
+	fi, err := filesys.Lstat(dir)
+	if err != nil {
+		return errors.Fatalf("%v", err)
+	}
+	if !fi.Mode.IsDir() {
+		return nil
+	}
+
+	names, err := fs.Readdirnames(filesys, dir, fs.O_NOFOLLOW)
+	if err != nil {
+		return errors.Fatalf("%v", err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		item := filepath.Join(dir, name)
+		result, fi, err := evaluateExclude(item, byNameRules, rules, filesys)
+		if err != nil {
+			Warnf("%v\n", err)
+			continue
+		}
+
+		if err := report(result); err != nil {
+			return err
+		}
+		if !result.Excluded && fi.Mode.IsDir() {
+			if err := walkExcludes(item, byNameRules, rules, filesys, report); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func printExcludeExplanation(w io.Writer, asJSON bool, result excludeExplanation) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(result)
+	}
+
+	var err error
+	switch {
+	case !result.Excluded && result.Rule != "":
+		_, err = fmt.Fprintf(w, "%v: included by %v\n", result.Path, result.Rule)
+	case !result.Excluded:
+		_, err = fmt.Fprintf(w, "%v: included\n", result.Path)
+	case result.ExcludedPath == result.Path:
+		_, err = fmt.Fprintf(w, "%v: excluded by %v\n", result.Path, result.Rule)
+	default:
+		_, err = fmt.Fprintf(w, "%v: excluded, parent directory %v is excluded by %v\n", result.Path, result.ExcludedPath, result.Rule)
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestExplainExclude(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	for _, filename := range []string{"keep.txt", "foo.tar.gz", "build/out.o", "cache/CACHEDIR.TAG", "cache/data"} {
+		fp := filepath.Join(tempdir, filename)
+		rtest.OK(t, os.MkdirAll(filepath.Dir(fp), 0755))
+		data := filename
+		if filepath.Base(filename) == "CACHEDIR.TAG" {
+			data = "Signature: 8a477f597d28d172789f06886806bc55"
+		}
+		rtest.OK(t, os.WriteFile(fp, []byte(data), 0o666))
+	}
+
+	opts := ExplainExcludeOptions{Targets: []string{tempdir}}
+	opts.Excludes = []string{"*.tar.gz", "/**/build"}
+	opts.ExcludeCaches = true
+
+	for _, test := range []struct {
+		path     string
+		expected excludeExplanation
+	}{
+		{"keep.txt", excludeExplanation{}},
+		{"foo.tar.gz", excludeExplanation{Excluded: true, ExcludedPath: "foo.tar.gz", Rule: `--exclude pattern "*.tar.gz"`}},
+		{"build/out.o", excludeExplanation{Excluded: true, ExcludedPath: "build", Rule: `--exclude pattern "/**/build"`}},
+		{"cache/data", excludeExplanation{Excluded: true, ExcludedPath: "cache/data", Rule: "--exclude-caches"}},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			gopts := GlobalOptions{JSON: true, stdout: buf}
+			rtest.OK(t, runExplainExclude(opts, gopts, []string{filepath.Join(tempdir, test.path)}))
+
+			var result excludeExplanation
+			rtest.OK(t, json.Unmarshal(buf.Bytes(), &result))
+			test.expected.Path = filepath.Join(tempdir, test.path)
+			if test.expected.ExcludedPath != "" {
+				test.expected.ExcludedPath = filepath.Join(tempdir, test.expected.ExcludedPath)
+			}
+			rtest.Equals(t, test.expected, result)
+		})
+	}
+
+	err := runExplainExclude(opts, GlobalOptions{stdout: &bytes.Buffer{}}, []string{filepath.Dir(tempdir)})
+	rtest.Assert(t, err != nil, "path outside of the targets was accepted")
+}
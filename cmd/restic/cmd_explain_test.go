@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestExplain(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		contains []string
+	}{
+		{"s3.storage-class", []string{"s3.storage-class\n", "Type:    string", "DEEP_ARCHIVE"}},
+		{"S3.Connections", []string{"s3.connections\n", "Default: 5"}},
+		{"local", []string{"local.connections\n", "local.limit-upload\n"}},
+		{"pack-size", []string{"--pack-size\n", "Commands: all (global flag)", "Type:     uint"}},
+		{"--dry-run", []string{"--dry-run\n", "backup", "restore"}},
+		{"target", []string{"Commands: dump\n", "Commands: restore\n", "directory to extract data to"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			rtest.OK(t, runExplain(buf, cmdRoot, test.name))
+			for _, s := range test.contains {
+				rtest.Assert(t, strings.Contains(buf.String(), s), "output does not contain %q:\n%s", s, buf.String())
+			}
+		})
+	}
+
+	err := runExplain(&bytes.Buffer{}, cmdRoot, "no-such-flag")
+	rtest.Assert(t, err != nil, "unknown flag was accepted")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cmdFilter = &cobra.Command{
+	Use:               "filter",
+	Short:             "Test exclude rules",
+	GroupID:           cmdGroupAdvanced,
+	DisableAutoGenTag: true,
+}
+
+var cmdFilterTest = &cobra.Command{
+	Use:   "test [flags] [path...]",
+	Short: "Test which paths are excluded from a backup by the exclude options",
+	Long: `
+The "filter test" command is the same as the "explain-exclude" command. It
+evaluates the exclude options of the backup command against the given paths and
+prints for each path whether it is included in the backup, together with the
+rule which decided this. With --walk, the given directories are traversed and
+all items below them are evaluated, skipping excluded directories like the
+backup command does.
+
+The options --expect-included and --expect-excluded allow validating a backup
+policy, for example in a CI pipeline: if one of the given paths is not included
+or excluded as expected, the mismatch is reported and the exit status is 5.
+
+See "restic help explain-exclude" for details on the exclude options.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful and all expectations were met.
+Exit status is 1 if there was any error.
+Exit status is 5 if at least one path was not included or excluded as expected.
+`,
+	Example: `restic filter test --exclude-file excludes.txt --target /home --walk /home/user/project
+restic filter test --exclude-file excludes.txt --expect-excluded /home/user/.cache --expect-included /home/user/documents`,
+	DisableAutoGenTag: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		return runExplainExclude(filterTestOptions, globalOptions, args)
+	},
+}
+
+var filterTestOptions ExplainExcludeOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdFilter)
+	cmdFilter.AddCommand(cmdFilterTest)
+
+	filterTestOptions.Add(cmdFilterTest.Flags())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestFilterTest(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	for _, filename := range []string{"keep.txt", "foo.tmp", "important.tmp", "build/out.o", "src/main.go"} {
+		fp := filepath.Join(tempdir, filename)
+		rtest.OK(t, os.MkdirAll(filepath.Dir(fp), 0755))
+		rtest.OK(t, os.WriteFile(fp, []byte(filename), 0o666))
+	}
+
+	opts := ExplainExcludeOptions{Targets: []string{tempdir}, Walk: true}
+	opts.Excludes = []string{"*.tmp", "!important.tmp", "/**/build"}
+
+	buf := &bytes.Buffer{}
+	rtest.OK(t, runExplainExclude(opts, GlobalOptions{JSON: true, stdout: buf}, []string{tempdir}))
+
+	results := make(map[string]excludeExplanation)
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var result excludeExplanation
+		rtest.OK(t, json.Unmarshal(sc.Bytes(), &result))
+		rel, err := filepath.Rel(tempdir, result.Path)
+		rtest.OK(t, err)
+		results[filepath.ToSlash(rel)] = result
+	}
+	rtest.OK(t, sc.Err())
+
+	// items below excluded directories are not visited
+	rtest.Equals(t, 7, len(results))
+	for _, item := range []string{".", "keep.txt", "src", "src/main.go"} {
+		rtest.Equals(t, excludeExplanation{Path: results[item].Path}, results[item])
+	}
+	rtest.Equals(t, `--exclude pattern "!important.tmp"`, results["important.tmp"].Rule)
+	rtest.Assert(t, !results["important.tmp"].Excluded, "important.tmp was excluded")
+	rtest.Equals(t, `--exclude pattern "*.tmp"`, results["foo.tmp"].Rule)
+	rtest.Assert(t, results["foo.tmp"].Excluded, "foo.tmp was not excluded")
+	rtest.Equals(t, `--exclude pattern "/**/build"`, results["build"].Rule)
+
+	// expectations
+	opts.Walk = false
+	opts.ExpectIncluded = []string{filepath.Join(tempdir, "important.tmp"), filepath.Join(tempdir, "src/main.go")}
+	opts.ExpectExcluded = []string{filepath.Join(tempdir, "build/out.o")}
+	rtest.OK(t, runExplainExclude(opts, GlobalOptions{stdout: &bytes.Buffer{}}, nil))
+
+	opts.ExpectExcluded = append(opts.ExpectExcluded, filepath.Join(tempdir, "keep.txt"))
+	err := runExplainExclude(opts, GlobalOptions{stdout: &bytes.Buffer{}}, nil)
+	rtest.Assert(t, err == ErrFilterMismatch, "unexpected error %v", err)
+	rtest.Equals(t, 5, exitCodeFor(err))
+}
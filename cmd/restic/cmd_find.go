@@ -25,6 +25,7 @@ repo.
 It can also be used to search for restic blobs or trees for troubleshooting.`,
 	Example: `restic find config.json
 restic find --json "*.yml" "*.json"
+restic find --json=lines "*.log"
 restic find --json --blob 420f620f b46ebe8a ddd38656
 restic find --show-pack-id --blob 420f620f
 restic find --tree 577c2bc9 f81f2e22 a62827a9
@@ -56,6 +57,7 @@ type FindOptions struct {
 	CaseInsensitive    bool
 	ListLong           bool
 	HumanReadable      bool
+	Blobs              bool
 	restic.SnapshotFilter
 }
 
@@ -75,6 +77,7 @@ func init() {
 	f.BoolVarP(&findOptions.CaseInsensitive, "ignore-case", "i", false, "ignore case for pattern")
 	f.BoolVarP(&findOptions.ListLong, "long", "l", false, "use a long listing format showing size and mode")
 	f.BoolVar(&findOptions.HumanReadable, "human-readable", false, "print sizes in human readable format")
+	f.BoolVar(&findOptions.Blobs, "blobs", false, "include the blobs of each matching file and the pack files containing them (requires --json)")
 
 	initMultiSnapshotFilter(f, &findOptions.SnapshotFilter, true)
 }
@@ -97,6 +100,7 @@ var timeFormats = []string{
 	"02.01.2006 15:04:05 -0700",
 	"02.01.2006 15:04:05 MST",
 	"Mon Jan 2 15:04:05 -0700 MST 2006",
+	time.RFC3339,
 }
 
 func parseTime(str string) (time.Time, error) {
@@ -113,6 +117,8 @@ type statefulOutput struct {
 	ListLong      bool
 	HumanReadable bool
 	JSON          bool
+	JSONLines     bool
+	Blobs         blobLookup // looks up the blobs of matching files, if set
 	inuse         bool
 	newsn         *restic.Snapshot
 	oldsn         *restic.Snapshot
@@ -120,11 +126,25 @@ type statefulOutput struct {
 }
 
 func (s *statefulOutput) PrintPatternJSON(path string, node *restic.Node) {
+	// each line must be self-contained, thus include the snapshot ID
+	var snapshotIDForLines string
+	if s.JSONLines {
+		snapshotIDForLines = s.newsn.ID().String()
+	}
+
+	var blobs *[]jsonBlob
+	if s.Blobs != nil && node.Type == restic.NodeTypeFile {
+		list := nodeBlobsJSON(s.Blobs, node)
+		blobs = &list
+	}
+
 	type findNode restic.Node
 	b, err := json.Marshal(struct {
 		// Add these attributes
-		Path        string `json:"path,omitempty"`
-		Permissions string `json:"permissions,omitempty"`
+		Path        string      `json:"path,omitempty"`
+		Permissions string      `json:"permissions,omitempty"`
+		Snapshot    string      `json:"snapshot,omitempty"`
+		Blobs       *[]jsonBlob `json:"blobs,omitempty"`
 
 		*findNode
 
@@ -138,12 +158,18 @@ func (s *statefulOutput) PrintPatternJSON(path string, node *restic.Node) {
 	}{
 		Path:        path,
 		Permissions: node.Mode.String(),
+		Snapshot:    snapshotIDForLines,
+		Blobs:       blobs,
 		findNode:    (*findNode)(node),
 	})
 	if err != nil {
 		Warnf("Marshall failed: %v\n", err)
 		return
 	}
+	if s.JSONLines {
+		Println(string(b))
+		return
+	}
 	if !s.inuse {
 		Printf("[")
 		s.inuse = true
@@ -203,6 +229,10 @@ func (s *statefulOutput) PrintObjectJSON(kind, id, nodepath, treeID string, sn *
 		Warnf("Marshall failed: %v\n", err)
 		return
 	}
+	if s.JSONLines {
+		Println(string(b))
+		return
+	}
 	if !s.inuse {
 		Printf("[")
 		s.inuse = true
@@ -234,6 +264,9 @@ func (s *statefulOutput) PrintObject(kind, id, nodepath, treeID string, sn *rest
 }
 
 func (s *statefulOutput) Finish() {
+	if s.JSONLines {
+		return
+	}
 	if s.JSON {
 		// do some finishing up
 		if s.oldsn != nil {
@@ -266,7 +299,7 @@ func (f *Finder) findInSnapshot(ctx context.Context, sn *restic.Snapshot) error
 	}
 
 	f.out.newsn = sn
-	return walker.Walk(ctx, f.repo, *sn.Tree, walker.WalkVisitor{ProcessNode: func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) error {
+	return walker.WalkParallel(ctx, f.repo, *sn.Tree, int(f.repo.Connections()), walker.WalkVisitor{ProcessNode: func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) error {
 		if err != nil {
 			debug.Log("Error loading tree %v: %v", parentTreeID, err)
 
@@ -344,7 +377,7 @@ func (f *Finder) findIDs(ctx context.Context, sn *restic.Snapshot) error {
 	}
 
 	f.out.newsn = sn
-	return walker.Walk(ctx, f.repo, *sn.Tree, walker.WalkVisitor{ProcessNode: func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) error {
+	return walker.WalkParallel(ctx, f.repo, *sn.Tree, int(f.repo.Connections()), walker.WalkVisitor{ProcessNode: func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) error {
 		if err != nil {
 			debug.Log("Error loading tree %v: %v", parentTreeID, err)
 
@@ -575,6 +608,9 @@ func runFind(ctx context.Context, opts FindOptions, gopts GlobalOptions, args []
 		(opts.TreeID && opts.PackID) {
 		return errors.Fatal("cannot have several ID types")
 	}
+	if opts.Blobs && !gopts.JSON {
+		return errors.Fatal("--blobs requires --json")
+	}
 
 	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
 	if err != nil {
@@ -594,7 +630,10 @@ func runFind(ctx context.Context, opts FindOptions, gopts GlobalOptions, args []
 	f := &Finder{
 		repo: repo,
 		pat:  pat,
-		out:  statefulOutput{ListLong: opts.ListLong, HumanReadable: opts.HumanReadable, JSON: gopts.JSON},
+		out:  statefulOutput{ListLong: opts.ListLong, HumanReadable: opts.HumanReadable, JSON: gopts.JSON, JSONLines: gopts.JSONLines},
+	}
+	if opts.Blobs {
+		f.out.Blobs = repo
 	}
 
 	if opts.BlobID {
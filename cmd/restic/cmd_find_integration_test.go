@@ -85,3 +85,69 @@ func TestFindJSON(t *testing.T) {
 	rtest.Assert(t, len(matches[0].Matches) == 3, "expected 3 files to match (%v)", datafile)
 	rtest.Assert(t, matches[0].Hits == 3, "expected hits to show 3 matches (%v)", datafile)
 }
+
+func TestFindJSONLines(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+
+	env.gopts.JSONLines = true
+	results := testRunFind(t, true, env.gopts, "testfile*")
+
+	type lineMatch struct {
+		testMatch
+		SnapshotID string `json:"snapshot"`
+	}
+	snapshots := make(map[string]int)
+	lines := strings.Split(strings.TrimSpace(string(results)), "\n")
+	for _, line := range lines {
+		var match lineMatch
+		rtest.OK(t, json.Unmarshal([]byte(line), &match))
+		rtest.Assert(t, strings.Contains(match.Path, "testfile"), "unexpected match %q", match.Path)
+		snapshots[match.SnapshotID]++
+	}
+	rtest.Equals(t, 6, len(lines))
+	for _, id := range snapshotIDs {
+		rtest.Equals(t, 3, snapshots[id.String()])
+	}
+}
+
+func TestFindJSONBlobs(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		return runFind(context.TODO(), FindOptions{Blobs: true}, gopts, []string{"testfile"})
+	})
+	rtest.OK(t, err)
+
+	var matches []struct {
+		Matches []struct {
+			Size  uint64     `json:"size"`
+			Blobs []jsonBlob `json:"blobs"`
+		} `json:"matches"`
+	}
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &matches))
+	rtest.Equals(t, 1, len(matches))
+	rtest.Equals(t, 1, len(matches[0].Matches))
+
+	match := matches[0].Matches[0]
+	rtest.Assert(t, len(match.Blobs) > 0, "no blobs returned")
+	var size uint64
+	for _, blob := range match.Blobs {
+		rtest.Assert(t, len(blob.Packs) > 0, "blob %v has no pack", blob.ID)
+		size += uint64(blob.Size)
+	}
+	rtest.Equals(t, match.Size, size)
+}
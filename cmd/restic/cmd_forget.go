@@ -5,10 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/policy"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui/termstatus"
 	"github.com/spf13/cobra"
@@ -25,6 +31,21 @@ If there are not enough snapshots to keep one for each duration related
 "--keep-{within-,}*" option, the oldest snapshot in the group is kept
 additionally.
 
+Alternatively, "--policy-file" reads a list of rules from a YAML file. Each
+rule applies its own keep options to the snapshots matching the hosts, tags
+and paths of the rule. Each snapshot is handled by the first matching rule,
+snapshots which match no rule are kept.
+
+With "--collapse-identical", snapshots which are identical to the preceding
+snapshot of the group, that is they have the same content, hostname, paths and
+tags, are removed. Of each run of identical snapshots only the oldest is kept.
+The newest snapshot of a group is always kept. This option can be used alone or
+in addition to a policy.
+
+With "--forget-superseded", partial snapshots of interrupted backups are
+removed once a later complete backup which used them as parent exists. This
+option can also be used alone or in addition to a policy.
+
 Please note that this command really only deletes the snapshot object in the
 repository, which is a reference to data stored there. In order to remove the
 unreferenced data after "forget" was run successfully, see the "prune" command.
@@ -40,6 +61,8 @@ Exit status is 1 if there was any error.
 Exit status is 10 if the repository does not exist.
 Exit status is 11 if the repository is already locked.
 Exit status is 12 if the password is incorrect.
+Exit status is 13 if the server refused to remove data, for example because the
+repository is append-only.
 `,
 	GroupID:           cmdGroupDefault,
 	DisableAutoGenTag: true,
@@ -102,6 +125,9 @@ type ForgetOptions struct {
 	KeepTags      restic.TagLists
 
 	UnsafeAllowRemoveAll bool
+	PolicyFile           string
+	CollapseIdentical    bool
+	ForgetSuperseded     bool
 
 	restic.SnapshotFilter
 	Compact bool
@@ -110,6 +136,8 @@ type ForgetOptions struct {
 	GroupBy restic.SnapshotGroupByOptions
 	DryRun  bool
 	Prune   bool
+
+	OverrideHold string
 }
 
 var forgetOptions ForgetOptions
@@ -133,6 +161,9 @@ func init() {
 	f.VarP(&forgetOptions.WithinYearly, "keep-within-yearly", "", "keep yearly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
 	f.Var(&forgetOptions.KeepTags, "keep-tag", "keep snapshots with this `taglist` (can be specified multiple times)")
 	f.BoolVar(&forgetOptions.UnsafeAllowRemoveAll, "unsafe-allow-remove-all", false, "allow deleting all snapshots of a snapshot group")
+	f.StringVar(&forgetOptions.PolicyFile, "policy-file", "", "read the rules which snapshots to keep from the YAML `file` instead of the --keep-* options")
+	f.BoolVar(&forgetOptions.CollapseIdentical, "collapse-identical", false, "remove snapshots which are identical to the preceding snapshot of the group, only the oldest and the newest snapshot of identical snapshots are kept")
+	f.BoolVar(&forgetOptions.ForgetSuperseded, "forget-superseded", false, "remove partial snapshots which are superseded by a later complete snapshot")
 
 	initMultiSnapshotFilter(f, &forgetOptions.SnapshotFilter, false)
 	f.StringArrayVar(&forgetOptions.Hosts, "hostname", nil, "only consider snapshots with the given `hostname` (can be specified multiple times)")
@@ -147,6 +178,7 @@ func init() {
 	f.VarP(&forgetOptions.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma (disable grouping with '')")
 	f.BoolVarP(&forgetOptions.DryRun, "dry-run", "n", false, "do not delete anything, just print what would be done")
 	f.BoolVar(&forgetOptions.Prune, "prune", false, "automatically run the 'prune' command if snapshots have been removed")
+	f.StringVar(&forgetOptions.OverrideHold, "override-hold", "", "run despite an active legal hold, the `justification` is recorded in the repository")
 
 	f.SortFlags = false
 	addPruneOptions(cmdForget, &forgetPruneOptions)
@@ -179,6 +211,16 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 		return err
 	}
 
+	if opts.PolicyFile != "" && len(args) > 0 {
+		return errors.Fatal("--policy-file cannot be combined with snapshot IDs")
+	}
+	if opts.CollapseIdentical && len(args) > 0 {
+		return errors.Fatal("--collapse-identical cannot be combined with snapshot IDs")
+	}
+	if opts.ForgetSuperseded && len(args) > 0 {
+		return errors.Fatal("--forget-superseded cannot be combined with snapshot IDs")
+	}
+
 	if gopts.NoLock && !opts.DryRun {
 		return errors.Fatal("--no-lock is only applicable in combination with --dry-run for forget command")
 	}
@@ -189,6 +231,15 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 	}
 	defer unlock()
 
+	if !opts.DryRun {
+		if err := checkHold(ctx, repo, "forget", opts.OverrideHold); err != nil {
+			return err
+		}
+		if !repo.CanDelete() {
+			return repository.ErrAppendOnly
+		}
+	}
+
 	verbosity := gopts.verbosity
 	if gopts.JSON {
 		verbosity = 0
@@ -218,7 +269,7 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			return err
 		}
 
-		policy := restic.ExpirePolicy{
+		expirePolicy := restic.ExpirePolicy{
 			Last:          int(opts.Last),
 			Hourly:        int(opts.Hourly),
 			Daily:         int(opts.Daily),
@@ -234,7 +285,35 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			Tags:          opts.KeepTags,
 		}
 
-		if policy.Empty() {
+		applyPolicy := func(list restic.Snapshots) (keep, remove restic.Snapshots, reasons []restic.KeepReason) {
+			return restic.ApplyPolicy(list, expirePolicy)
+		}
+		description := expirePolicy.String()
+
+		if opts.PolicyFile != "" {
+			if !expirePolicy.Empty() {
+				return errors.Fatal("--policy-file cannot be combined with --keep-* options")
+			}
+			if opts.UnsafeAllowRemoveAll {
+				return errors.Fatal("--policy-file cannot be combined with --unsafe-allow-remove-all")
+			}
+			rules, err := policy.Load(opts.PolicyFile)
+			if err != nil {
+				return err
+			}
+			applyPolicy = rules.Apply
+			description = rules.String()
+		} else if expirePolicy.Empty() && (opts.CollapseIdentical || opts.ForgetSuperseded) {
+			var matches []string
+			if opts.CollapseIdentical {
+				matches = append(matches, "not identical")
+			}
+			if opts.ForgetSuperseded {
+				matches = append(matches, "not superseded")
+			}
+			applyPolicy = keepAllSnapshots(matches)
+			description = "keep all snapshots"
+		} else if expirePolicy.Empty() {
 			if opts.UnsafeAllowRemoveAll {
 				if opts.SnapshotFilter.Empty() {
 					return errors.Fatal("--unsafe-allow-remove-all is not allowed unless a snapshot filter option is specified")
@@ -245,7 +324,17 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			}
 		}
 
-		printer.P("Applying Policy: %v\n", policy)
+		if opts.CollapseIdentical {
+			description += ", except identical snapshots"
+		}
+		// partial snapshots may be in a different group than the snapshot
+		// superseding them, for example when grouping by tags
+		var superseded restic.Snapshots
+		if opts.ForgetSuperseded {
+			superseded = restic.FindSupersededSnapshots(snapshots)
+			description += ", except superseded partial snapshots"
+		}
+		printer.P("Applying Policy: %v\n", description)
 
 		for k, snapshotGroup := range snapshotGroups {
 			if ctx.Err() != nil {
@@ -269,9 +358,33 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			fg.Host = key.Hostname
 			fg.Paths = key.Paths
 
-			keep, remove, reasons := restic.ApplyPolicy(snapshotGroup, policy)
+			// snapshots which are removed regardless of the policy
+			var extra restic.Snapshots
+			extraReasons := make(map[restic.ID]string)
+			if opts.ForgetSuperseded {
+				rest := withoutSnapshots(snapshotGroup, superseded)
+				extra = withoutSnapshots(snapshotGroup, rest)
+				snapshotGroup = rest
+				for _, sn := range extra {
+					extraReasons[*sn.ID()] = removeReasonSuperseded
+				}
+			}
+			if opts.CollapseIdentical {
+				identical := restic.FindIdenticalSnapshots(snapshotGroup)
+				snapshotGroup = withoutSnapshots(snapshotGroup, identical)
+				extra = append(extra, identical...)
+				for _, sn := range identical {
+					extraReasons[*sn.ID()] = removeReasonIdentical
+				}
+			}
+
+			keep, remove, reasons := applyPolicy(snapshotGroup)
+			if len(extra) > 0 {
+				remove = append(remove, extra...)
+				sort.Stable(remove)
+			}
 
-			if feature.Flag.Enabled(feature.SafeForgetKeepTags) && !policy.Empty() && len(keep) == 0 {
+			if feature.Flag.Enabled(feature.SafeForgetKeepTags) && (!expirePolicy.Empty() || opts.PolicyFile != "") && len(keep) == 0 {
 				return fmt.Errorf("refusing to delete last snapshot of snapshot group \"%v\"", key.String())
 			}
 			if len(keep) != 0 && !gopts.Quiet && !gopts.JSON {
@@ -287,6 +400,7 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 				printer.P("\n")
 			}
 			fg.Remove = asJSONSnapshots(remove)
+			fg.RemoveReasons = asJSONRemoveReasons(remove, extraReasons)
 
 			fg.Reasons = asJSONKeeps(reasons)
 
@@ -302,11 +416,38 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 		return ctx.Err()
 	}
 
+	if len(removeSnIDs) > 0 {
+		// removing a snapshot which is protected by a retention period would
+		// only hide it, while prune could remove the data it references
+		retained, err := repo.ListRetained(ctx, restic.SnapshotFile)
+		if err != nil {
+			return err
+		}
+		for id, until := range retained {
+			if removeSnIDs.Has(id) {
+				printer.E("keeping %v/%v, it is protected by a retention period until %v\n", restic.SnapshotFile, id.Str(), until.Format(time.RFC3339))
+				removeSnIDs.Delete(id)
+			}
+		}
+	}
+
 	if len(removeSnIDs) > 0 {
 		if !opts.DryRun {
 			bar := printer.NewCounter("files deleted")
+			var m sync.Mutex
+			lockedSnIDs := restic.NewIDSet()
 			err := restic.ParallelRemove(ctx, repo, removeSnIDs, restic.SnapshotFile, func(id restic.ID, err error) error {
-				if err != nil {
+				var rerr *backend.RetentionError
+				var perr *backend.PolicyError
+				if errors.As(err, &rerr) {
+					printer.E("keeping %v/%v: %v\n", restic.SnapshotFile, id.Str(), rerr)
+					m.Lock()
+					lockedSnIDs.Insert(id)
+					m.Unlock()
+				} else if errors.As(err, &perr) {
+					// all further deletions will fail as well
+					return err
+				} else if err != nil {
 					printer.E("unable to remove %v/%v from the repository\n", restic.SnapshotFile, id)
 				} else {
 					printer.VV("removed %v/%v\n", restic.SnapshotFile, id)
@@ -317,6 +458,16 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			if err != nil {
 				return err
 			}
+
+			// snapshots protected by a retention period still exist and must not be
+			// ignored when searching for used blobs during prune
+			for id := range lockedSnIDs {
+				removeSnIDs.Delete(id)
+			}
+
+			if err := removeAliases(ctx, repo, removeSnIDs.Has, printer); err != nil {
+				return err
+			}
 		} else {
 			printer.P("Would have removed the following snapshots:\n%v\n\n", removeSnIDs)
 		}
@@ -342,6 +493,36 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 	return nil
 }
 
+// keepAllSnapshots returns a policy which keeps all snapshots of the list
+// with the given matches as reason. It is used if --collapse-identical or
+// --forget-superseded is specified without a policy.
+func keepAllSnapshots(matches []string) func(list restic.Snapshots) (keep, remove restic.Snapshots, reasons []restic.KeepReason) {
+	return func(list restic.Snapshots) (keep, remove restic.Snapshots, reasons []restic.KeepReason) {
+		sort.Stable(list)
+		for _, sn := range list {
+			reasons = append(reasons, restic.KeepReason{Snapshot: sn, Matches: matches})
+		}
+		return list, nil, reasons
+	}
+}
+
+// withoutSnapshots returns the snapshots from list which are not contained in
+// remove.
+func withoutSnapshots(list restic.Snapshots, remove restic.Snapshots) restic.Snapshots {
+	ids := restic.NewIDSet()
+	for _, sn := range remove {
+		ids.Insert(*sn.ID())
+	}
+
+	var res restic.Snapshots
+	for _, sn := range list {
+		if !ids.Has(*sn.ID()) {
+			res = append(res, sn)
+		}
+	}
+	return res
+}
+
 // ForgetGroup helps to print what is forgotten in JSON.
 type ForgetGroup struct {
 	Tags    []string     `json:"tags"`
@@ -350,6 +531,8 @@ type ForgetGroup struct {
 	Keep    []Snapshot   `json:"keep"`
 	Remove  []Snapshot   `json:"remove"`
 	Reasons []KeepReason `json:"reasons"`
+
+	RemoveReasons []RemoveReason `json:"remove_reasons"`
 }
 
 func asJSONSnapshots(list restic.Snapshots) []Snapshot {
@@ -387,6 +570,41 @@ func asJSONKeeps(list []restic.KeepReason) []KeepReason {
 	return resultList
 }
 
+// Reasons why a snapshot is removed, as reported in RemoveReason.
+const (
+	removeReasonPolicy     = "not kept by policy"
+	removeReasonIdentical  = "identical to older snapshot"
+	removeReasonSuperseded = "superseded by complete snapshot"
+)
+
+// RemoveReason helps to print why a snapshot is removed as JSON.
+type RemoveReason struct {
+	Snapshot Snapshot `json:"snapshot"`
+	Reason   string   `json:"reason"`
+}
+
+// asJSONRemoveReasons returns the reason for removing each snapshot in list.
+// Snapshots not contained in extra were removed by the policy.
+func asJSONRemoveReasons(list restic.Snapshots, extra map[restic.ID]string) []RemoveReason {
+	var resultList []RemoveReason
+	for _, sn := range list {
+		reason, ok := extra[*sn.ID()]
+		if !ok {
+			reason = removeReasonPolicy
+		}
+		r := RemoveReason{
+			Snapshot: Snapshot{
+				Snapshot: sn,
+				ID:       sn.ID(),
+				ShortID:  sn.ID().Str(),
+			},
+			Reason: reason,
+		}
+		resultList = append(resultList, r)
+	}
+	return resultList
+}
+
 func printJSONForget(stdout io.Writer, forgets []*ForgetGroup) error {
 	return json.NewEncoder(stdout).Encode(forgets)
 }
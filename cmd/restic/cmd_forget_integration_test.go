@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -64,3 +67,128 @@ func TestRunForgetSafetyNet(t *testing.T) {
 	})
 	testListSnapshots(t, env.gopts, 0)
 }
+
+func TestRunForgetPolicyFile(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+
+	for _, opts := range []BackupOptions{
+		{Host: "x"}, {Host: "x"}, {Host: "x"},
+		{Host: "y", Tags: restic.TagLists{{"db"}}}, {Host: "y", Tags: restic.TagLists{{"db"}}},
+		{Host: "y"},
+	} {
+		testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	}
+	testListSnapshots(t, env.gopts, 6)
+
+	policyFile := filepath.Join(env.base, "policy.yaml")
+	rtest.OK(t, os.WriteFile(policyFile, []byte(`
+rules:
+  - tags: [db]
+    keep:
+      last: 1
+  - hosts: [x]
+    keep:
+      last: 2
+`), 0o600))
+	opts := ForgetOptions{
+		PolicyFile: policyFile,
+		GroupBy:    restic.SnapshotGroupByOptions{Host: true, Path: true},
+	}
+
+	err := testRunForgetMayFail(env.gopts, ForgetOptions{PolicyFile: policyFile, Last: 1})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "cannot be combined"), "unexpected error %v", err)
+
+	testRunForget(t, env.gopts, opts)
+	// two snapshots of host x, one db snapshot and the untagged snapshot of host y remain
+	testListSnapshots(t, env.gopts, 4)
+}
+
+func TestRunForgetCollapseIdentical(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	// use a relative path, otherwise changes to the parent folders result in
+	// different snapshots
+	for i := 0; i < 4; i++ {
+		testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	}
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "new"), 100))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 5)
+
+	// the three newer snapshots with the old content are identical to the
+	// oldest one, which is in turn not kept by the policy
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		return testRunForgetMayFail(gopts, ForgetOptions{
+			DryRun:            true,
+			Last:              1,
+			CollapseIdentical: true,
+			GroupBy:           restic.SnapshotGroupByOptions{Host: true, Path: true},
+		})
+	})
+	rtest.OK(t, err)
+	var forgets []*ForgetGroup
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &forgets))
+	rtest.Equals(t, 1, len(forgets))
+	reasons := make(map[string]int)
+	for _, r := range forgets[0].RemoveReasons {
+		reasons[r.Reason]++
+	}
+	rtest.Equals(t, map[string]int{removeReasonIdentical: 3, removeReasonPolicy: 1}, reasons)
+	testListSnapshots(t, env.gopts, 5)
+
+	testRunForget(t, env.gopts, ForgetOptions{
+		CollapseIdentical: true,
+		GroupBy:           restic.SnapshotGroupByOptions{Host: true, Path: true},
+	})
+	// the first snapshot with the old content and the snapshot with the new content remain
+	testListSnapshots(t, env.gopts, 2)
+}
+
+func TestRunForgetSuperseded(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	first := testListSnapshots(t, env.gopts, 1)[0]
+
+	// simulate two interrupted backups
+	ctx, repo, unlock, err := openWithAppendLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	parent, err := restic.LoadSnapshot(ctx, repo, first)
+	rtest.OK(t, err)
+	var partial restic.IDs
+	for i := 0; i < 2; i++ {
+		sn := *parent
+		sn.Time = parent.Time.Add(time.Millisecond)
+		sn.Parent = parent.ID()
+		sn.Partial = true
+		id, err := restic.SaveSnapshot(ctx, repo, &sn)
+		rtest.OK(t, err)
+		partial = append(restic.IDs{id}, partial...)
+		parent, err = restic.LoadSnapshot(ctx, repo, id)
+		rtest.OK(t, err)
+	}
+	rtest.Equals(t, partial, partialSnapshotChain(ctx, repo, parent))
+	unlock()
+
+	// the next complete backup supersedes both partial snapshots
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	newest, _ := testRunSnapshots(t, env.gopts)
+	rtest.Assert(t, !newest.Partial, "complete snapshot is marked as partial")
+	rtest.Equals(t, partial, newest.Supersedes)
+	testListSnapshots(t, env.gopts, 4)
+
+	testRunForget(t, env.gopts, ForgetOptions{
+		ForgetSuperseded: true,
+		GroupBy:          restic.SnapshotGroupByOptions{Host: true, Path: true},
+	})
+	testListSnapshots(t, env.gopts, 2)
+}
@@ -92,3 +92,13 @@ func TestForgetOptionValues(t *testing.T) {
 		}
 	}
 }
+
+func TestForgetKeepAllSnapshotsReasons(t *testing.T) {
+	list := restic.Snapshots{{Hostname: "foo"}, {Hostname: "bar"}}
+	keep, remove, reasons := keepAllSnapshots([]string{"not identical", "not superseded"})(list)
+	rtest.Equals(t, 2, len(keep))
+	rtest.Equals(t, 0, len(remove))
+	for _, reason := range reasons {
+		rtest.Equals(t, []string{"not identical", "not superseded"}, reason.Matches)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdHold = &cobra.Command{
+	Use:   "hold",
+	Short: "Manage legal holds",
+	Long: `
+The "hold" command allows to freeze a repository, for example for legal or
+compliance reasons. While a hold is active, the "forget", "prune", "rewrite",
+"tag", "repair packs" and "key rotate-master" commands as well as
+"repair snapshots --forget" and "replicate --delete" refuse to run. Creating
+new backups is still possible.
+
+A command can be run despite an active hold by passing a justification to its
+--override-hold option. Each override is recorded in the hold and is shown by
+"restic hold status". Placing, overriding and clearing holds is also recorded
+in an append-only audit log, which is shown by "restic hold status --audit".
+	`,
+	DisableAutoGenTag: true,
+	GroupID:           cmdGroupDefault,
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdHold)
+}
+
+// checkHold returns an error if the repository is under a legal hold, unless
+// a justification to override the hold is given. The override is recorded in
+// the hold.
+func checkHold(ctx context.Context, repo restic.StateSaver, command string, justification string) error {
+	hold, err := restic.CheckHold(ctx, repo, command, justification)
+	var herr *restic.HoldError
+	if errors.As(err, &herr) {
+		return errors.Fatalf("repository is under a legal hold since %v: %v\n"+
+			"The hold can be overridden with --override-hold \"<justification>\"",
+			herr.Hold.Time.Local().Format(TimeFormat), herr.Hold.Reason)
+	}
+	if err != nil {
+		return err
+	}
+	if hold != nil {
+		Warnf("overriding legal hold (%v) with justification: %v\n", hold.Reason, justification)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdHoldClear = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove the legal hold from the repository",
+	Long: `
+The "clear" sub-command removes the legal hold from the repository. The reason
+for removing the hold must be specified using --justification. It is recorded
+in the audit log of the repository, which also keeps the records of the hold
+and its overrides.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHoldClear(cmd.Context(), holdClearOptions, globalOptions, args)
+	},
+}
+
+// HoldClearOptions bundles all options for the 'hold clear' command.
+type HoldClearOptions struct {
+	Justification string
+}
+
+var holdClearOptions HoldClearOptions
+
+func init() {
+	cmdHold.AddCommand(cmdHoldClear)
+
+	f := cmdHoldClear.Flags()
+	f.StringVar(&holdClearOptions.Justification, "justification", "", "the `justification` for removing the hold, it is recorded in the repository")
+}
+
+func runHoldClear(ctx context.Context, opts HoldClearOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the hold clear command expects no arguments")
+	}
+	if opts.Justification == "" {
+		return errors.Fatal("please specify the justification for removing the hold using --justification")
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hold, err := restic.LoadHold(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if hold == nil {
+		return errors.Fatal("repository is not under a legal hold")
+	}
+
+	err = restic.ClearHold(ctx, repo, opts.Justification)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("removed legal hold\n")
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+func testRunHoldStatus(t testing.TB, gopts GlobalOptions) holdStatusJSON {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runHoldStatus(context.TODO(), HoldStatusOptions{Audit: true}, gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var status holdStatusJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &status))
+	return status
+}
+
+func TestHold(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	for i := 0; i < 3; i++ {
+		testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	}
+
+	rtest.Assert(t, !testRunHoldStatus(t, env.gopts).Active, "unexpected active hold")
+	rtest.Assert(t, runHoldSet(context.TODO(), HoldSetOptions{}, env.gopts, nil) != nil, "hold without reason was accepted")
+	rtest.OK(t, runHoldSet(context.TODO(), HoldSetOptions{Reason: "case 42"}, env.gopts, nil))
+	rtest.Assert(t, runHoldSet(context.TODO(), HoldSetOptions{Reason: "case 43"}, env.gopts, nil) != nil, "second hold was accepted")
+
+	status := testRunHoldStatus(t, env.gopts)
+	rtest.Assert(t, status.Active, "hold is not active")
+	rtest.Equals(t, "case 42", status.Hold.Reason)
+
+	// forget, prune and rewrite must refuse to run, dry runs are allowed
+	err := testRunForgetMayFail(env.gopts, ForgetOptions{Last: 1})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "legal hold"), "unexpected error %v", err)
+	testRunForget(t, env.gopts, ForgetOptions{Last: 1, DryRun: true})
+	err = withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runPrune(ctx, PruneOptions{MaxUnused: "5%"}, env.gopts, term)
+	})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "legal hold"), "unexpected error %v", err)
+	err = runRewrite(context.TODO(), RewriteOptions{Metadata: snapshotMetadataArgs{Hostname: "other"}}, env.gopts, nil)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "legal hold"), "unexpected error %v", err)
+	err = runTag(context.TODO(), TagOptions{AddTags: restic.TagLists{{"foo"}}}, env.gopts, nil)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "legal hold"), "unexpected error %v", err)
+	err = runRepairSnapshots(context.TODO(), env.gopts, RepairOptions{Forget: true}, nil)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "legal hold"), "unexpected error %v", err)
+	testListSnapshots(t, env.gopts, 3)
+
+	// overriding the hold is recorded
+	testRunForget(t, env.gopts, ForgetOptions{Last: 2, OverrideHold: "approved by legal"})
+	testListSnapshots(t, env.gopts, 2)
+	status = testRunHoldStatus(t, env.gopts)
+	rtest.Equals(t, 1, len(status.Hold.Overrides))
+	rtest.Equals(t, "forget", status.Hold.Overrides[0].Command)
+	rtest.Equals(t, "approved by legal", status.Hold.Overrides[0].Justification)
+
+	rtest.Assert(t, runHoldClear(context.TODO(), HoldClearOptions{}, env.gopts, nil) != nil, "hold was cleared without justification")
+	rtest.OK(t, runHoldClear(context.TODO(), HoldClearOptions{Justification: "case closed"}, env.gopts, nil))
+	status = testRunHoldStatus(t, env.gopts)
+	rtest.Assert(t, !status.Active, "hold was not removed")
+	rtest.Assert(t, runHoldClear(context.TODO(), HoldClearOptions{Justification: "again"}, env.gopts, nil) != nil, "clearing a missing hold succeeded")
+
+	// the audit log survives clearing the hold
+	rtest.Equals(t, 3, len(status.Audit))
+	rtest.Equals(t, restic.HoldActionOverride, status.Audit[1].Action)
+	rtest.Equals(t, "case closed", status.Audit[2].Text)
+	testRunForget(t, env.gopts, ForgetOptions{Last: 1})
+	testListSnapshots(t, env.gopts, 1)
+}
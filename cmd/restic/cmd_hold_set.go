@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdHoldSet = &cobra.Command{
+	Use:   "set [flags]",
+	Short: "Place the repository under a legal hold",
+	Long: `
+The "set" sub-command places the repository under a legal hold. The reason for
+the hold must be specified using --reason.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHoldSet(cmd.Context(), holdSetOptions, globalOptions, args)
+	},
+}
+
+// HoldSetOptions bundles all options for the 'hold set' command.
+type HoldSetOptions struct {
+	Reason string
+}
+
+var holdSetOptions HoldSetOptions
+
+func init() {
+	cmdHold.AddCommand(cmdHoldSet)
+
+	f := cmdHoldSet.Flags()
+	f.StringVar(&holdSetOptions.Reason, "reason", "", "the `reason` for the hold, for example a case number")
+}
+
+func runHoldSet(ctx context.Context, opts HoldSetOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the hold set command expects no arguments, only options - please see `restic help hold set` for usage and flags")
+	}
+	if opts.Reason == "" {
+		return errors.Fatal("please specify the reason for the hold using --reason")
+	}
+
+	// the exclusive lock ensures that no forget or prune run is in progress
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hold, err := restic.LoadHold(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if hold != nil {
+		return errors.Fatalf("repository is already under a legal hold since %v: %v",
+			hold.Time.Local().Format(TimeFormat), hold.Reason)
+	}
+
+	_, err = restic.PlaceHold(ctx, repo, opts.Reason)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("repository is now under a legal hold\n")
+	return nil
+}
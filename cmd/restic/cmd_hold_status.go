@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdHoldStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Show the legal hold of the repository",
+	Long: `
+The "status" sub-command shows whether the repository is under a legal hold,
+and lists the commands which were run despite the hold. The --audit option
+shows the complete audit log instead, which also contains holds which were
+cleared in the meantime.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHoldStatus(cmd.Context(), holdStatusOptions, globalOptions, args)
+	},
+}
+
+// HoldStatusOptions bundles all options for the 'hold status' command.
+type HoldStatusOptions struct {
+	Audit bool
+}
+
+var holdStatusOptions HoldStatusOptions
+
+func init() {
+	cmdHold.AddCommand(cmdHoldStatus)
+
+	f := cmdHoldStatus.Flags()
+	f.BoolVar(&holdStatusOptions.Audit, "audit", false, "show the audit log of all holds")
+}
+
+type holdStatusJSON struct {
+	Active bool               `json:"active"`
+	Hold   *restic.Hold       `json:"hold,omitempty"`
+	Audit  []restic.HoldEvent `json:"audit,omitempty"`
+}
+
+func runHoldStatus(ctx context.Context, opts HoldStatusOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the hold status command expects no arguments")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hold, err := restic.LoadHold(ctx, repo)
+	if err != nil {
+		return err
+	}
+	var audit []restic.HoldEvent
+	if opts.Audit {
+		audit, err = restic.LoadHoldAudit(ctx, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(holdStatusJSON{Active: hold != nil, Hold: hold, Audit: audit})
+	}
+
+	if opts.Audit {
+		if len(audit) == 0 {
+			Printf("audit log is empty\n")
+		}
+		for _, ev := range audit {
+			command := ev.Action
+			if ev.Command != "" {
+				command += " " + ev.Command
+			}
+			Printf("%v %v by %v@%v: %v\n", ev.Time.Local().Format(TimeFormat), command, ev.Username, ev.Hostname, ev.Text)
+		}
+		return nil
+	}
+
+	if hold == nil {
+		Printf("repository is not under a legal hold\n")
+		return nil
+	}
+
+	Printf("repository is under a legal hold since %v\n", hold.Time.Local().Format(TimeFormat))
+	Printf("  created by: %v@%v\n", hold.Username, hold.Hostname)
+	Printf("  reason:     %v\n", hold.Reason)
+	if len(hold.Overrides) > 0 {
+		Printf("\noverrides:\n")
+	}
+	for _, o := range hold.Overrides {
+		Printf("  %v %v by %v@%v: %v\n", o.Time.Local().Format(TimeFormat), o.Command, o.Username, o.Hostname, o.Justification)
+	}
+	return nil
+}
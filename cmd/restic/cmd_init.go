@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/restic/chunker"
 	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/transform"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
@@ -20,6 +23,20 @@ var cmdInit = &cobra.Command{
 	Long: `
 The "init" command initializes a new repository.
 
+If --password-keyfile is specified, the repository can only be opened using
+both the password and the keyfile.
+
+With --append-only, pack and snapshot files can only be removed using a key
+with the delete capability. The key created by this command receives this
+capability, use "restic key add" to create keys without it for the hosts that
+only need to create backups.
+
+With --wizard, the command interactively asks where to store the repository
+and its password and chooses the compression and pack size based on the data
+to back up. It then creates the repository and writes a configuration file
+which contains the repository location, the backend credentials and the
+chosen options as environment variables.
+
 EXIT STATUS
 ===========
 
@@ -38,6 +55,9 @@ type InitOptions struct {
 	secondaryRepoOptions
 	CopyChunkerParameters bool
 	RepositoryVersion     string
+	PackTransforms        []string
+	AppendOnly            bool
+	Wizard                bool
 }
 
 var initOptions InitOptions
@@ -49,6 +69,9 @@ func init() {
 	initSecondaryRepoOptions(f, &initOptions.secondaryRepoOptions, "secondary", "to copy chunker parameters from")
 	f.BoolVar(&initOptions.CopyChunkerParameters, "copy-chunker-params", false, "copy chunker parameters from the secondary repository (useful with the copy command)")
 	f.StringVar(&initOptions.RepositoryVersion, "repository-version", "stable", "repository format version to use, allowed values are a format version, 'latest' and 'stable'")
+	f.StringSliceVar(&initOptions.PackTransforms, "pack-transform", nil, "apply `transform` to all pack files, available transforms: "+strings.Join(transform.Names(), ", ")+" (can be specified multiple times)")
+	f.BoolVar(&initOptions.AppendOnly, "append-only", false, "only allow keys with the delete capability to remove data and snapshots from the repository")
+	f.BoolVar(&initOptions.Wizard, "wizard", false, "interactively ask for the repository location, password and options")
 }
 
 func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []string) error {
@@ -56,6 +79,29 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 		return errors.Fatal("the init command expects no arguments, only options - please see `restic help init` for usage and flags")
 	}
 
+	var wizard *initWizardResult
+	if opts.Wizard {
+		if gopts.JSON {
+			return errors.Fatal("--wizard and --json cannot be specified at the same time")
+		}
+		if opts.CopyChunkerParameters {
+			return errors.Fatal("--wizard and --copy-chunker-params cannot be specified at the same time")
+		}
+		if gopts.Repo != "" || gopts.RepositoryFile != "" {
+			return errors.Fatal("--wizard asks for the repository location, it must not be specified")
+		}
+
+		var err error
+		wizard, err = newInitWizard(ctx, os.Stdin, gopts.stdout).run()
+		if err != nil {
+			return err
+		}
+		gopts, err = wizard.apply(gopts)
+		if err != nil {
+			return err
+		}
+	}
+
 	var version uint
 	if opts.RepositoryVersion == "latest" || opts.RepositoryVersion == "" {
 		version = restic.MaxRepoVersion
@@ -72,6 +118,14 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 		return errors.Fatalf("only repository versions between %v and %v are allowed", restic.MinRepoVersion, restic.MaxRepoVersion)
 	}
 
+	if len(opts.PackTransforms) > 0 && version < restic.FeaturesRepoVersion {
+		return errors.Fatalf("pack transforms require --repository-version %v or later", restic.FeaturesRepoVersion)
+	}
+	// make sure that the transforms are configured before creating the repository
+	if _, err := transform.Load(opts.PackTransforms, gopts.extended); err != nil {
+		return err
+	}
+
 	chunkerPolynomial, err := maybeReadChunkerPolynomial(ctx, opts, gopts)
 	if err != nil {
 		return err
@@ -95,18 +149,33 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 	}
 
 	s, err := repository.New(be, repository.Options{
-		Compression: gopts.Compression,
-		PackSize:    gopts.PackSize * 1024 * 1024,
+		Compression:          gopts.Compression,
+		PackSize:             gopts.PackSize * 1024 * 1024,
+		PackTransformOptions: gopts.extended,
 	})
 	if err != nil {
 		return errors.Fatal(err.Error())
 	}
 
-	err = s.Init(ctx, version, gopts.password, chunkerPolynomial)
+	if gopts.PasswordKeyfile != "" {
+		keyfile, err := loadKeyfile(gopts.PasswordKeyfile)
+		if err != nil {
+			return err
+		}
+		s.UseKeyfile(keyfile)
+	}
+
+	err = s.Init(ctx, version, gopts.password, chunkerPolynomial, opts.PackTransforms)
 	if err != nil {
 		return errors.Fatalf("create key in repository at %s failed: %v\n", location.StripPassword(gopts.backends, gopts.Repo), err)
 	}
 
+	if opts.AppendOnly {
+		if err := repository.EnableAppendOnly(ctx, s); err != nil {
+			return errors.Fatalf("enabling append-only mode failed: %v\n", err)
+		}
+	}
+
 	if !gopts.JSON {
 		Verbosef("created restic repository %v at %s", s.Config().ID[:10], location.StripPassword(gopts.backends, gopts.Repo))
 		if opts.CopyChunkerParameters && chunkerPolynomial != nil {
@@ -119,6 +188,9 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 		Verbosef("the repository. Losing your password means that your data is\n")
 		Verbosef("irrecoverably lost.\n")
 
+		if wizard != nil {
+			return wizard.save(ctx, gopts)
+		}
 	} else {
 		status := initSuccess{
 			MessageType: "initialized",
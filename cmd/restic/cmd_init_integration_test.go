@@ -2,13 +2,17 @@ package main
 
 import (
 	"context"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
 )
 
 func testRunInit(t testing.TB, opts GlobalOptions) {
@@ -54,3 +58,148 @@ func TestInitCopyChunkerParams(t *testing.T) {
 		"expected equal chunker polynomials, got %v expected %v", repo.Config().ChunkerPolynomial,
 		otherRepo.Config().ChunkerPolynomial)
 }
+
+func TestInitPackTransforms(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	repository.TestUseLowSecurityKDFParameters(t)
+	restic.TestDisableCheckPolynomial(t)
+
+	keyFile := filepath.Join(env.base, "sign.key")
+	rtest.OK(t, os.WriteFile(keyFile, rtest.Random(23, 32), 0o600))
+	env.gopts.extended["transform.sign.key-file"] = keyFile
+
+	initOpts := InitOptions{PackTransforms: []string{"sign"}}
+	rtest.OK(t, runInit(context.TODO(), initOpts, env.gopts, nil))
+
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunCheck(t, env.gopts)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, testListSnapshots(t, env.gopts, 1)[0].String()+":"+toPathInSnapshot(filepath.Dir(env.testdata)))
+	diffs := directoriesContentsDiff(env.testdata, filepath.Join(restoredir, "testdata"))
+	rtest.Assert(t, diffs == "", "directories are not equal: %v", diffs)
+
+	// the repository cannot be opened without the key
+	delete(env.gopts.extended, "transform.sign.key-file")
+	_, err := OpenRepository(context.TODO(), env.gopts)
+	rtest.Assert(t, err != nil, "expected opening the repository without key to fail")
+}
+
+func TestInitAppendOnly(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list keys more than once
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	repository.TestUseLowSecurityKDFParameters(t)
+	restic.TestDisableCheckPolynomial(t)
+
+	rtest.OK(t, runInit(context.TODO(), InitOptions{AppendOnly: true}, env.gopts, nil))
+	testRunKeyAddNewKey(t, "restricted", env.gopts)
+
+	restricted := env.gopts
+	restricted.password = "restricted"
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, restricted)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, restricted)
+	snapshotIDs := testListSnapshots(t, restricted, 2)
+
+	err := testRunForgetMayFail(restricted, ForgetOptions{}, snapshotIDs[0].String())
+	rtest.Assert(t, err == repository.ErrAppendOnly, "unexpected error for forget: %v", err)
+	testListSnapshots(t, restricted, 2)
+
+	testRunForget(t, env.gopts, ForgetOptions{}, snapshotIDs[0].String())
+	testListSnapshots(t, restricted, 1)
+
+	err = withTermStatus(restricted, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runPrune(ctx, PruneOptions{MaxUnused: "5%"}, restricted, term)
+	})
+	rtest.Assert(t, err == repository.ErrAppendOnly, "unexpected error for prune: %v", err)
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "5%"})
+	testRunCheck(t, env.gopts)
+}
+
+func TestInitWizard(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	repository.TestUseLowSecurityKDFParameters(t)
+	restic.TestDisableCheckPolynomial(t)
+
+	configFile := filepath.Join(env.base, "config", "test.env")
+	// an existing file must not keep its permissions
+	rtest.OK(t, os.MkdirAll(filepath.Dir(configFile), 0700))
+	rtest.OK(t, os.WriteFile(configFile, nil, 0644))
+	answers := []string{
+		"1", env.repo, // local directory
+		"secret",
+		configFile,
+	}
+	if provider, _ := localPasswordProvider(); provider != "" {
+		answers = append(answers, "2") // password file
+	}
+	answers = append(answers,
+		"n", "y", // maximum compression
+		"3", // more than 1 TiB
+		env.testdata,
+	)
+
+	res, err := newInitWizard(context.TODO(), strings.NewReader(strings.Join(answers, "\n")+"\n"), io.Discard).run()
+	rtest.OK(t, err)
+	rtest.Equals(t, env.repo, res.Repo)
+	rtest.Equals(t, "secret", res.Password)
+	rtest.Equals(t, filepath.Join(env.base, "config", "test.password"), res.PasswordFile)
+	rtest.Equals(t, "max", res.Compression)
+	rtest.Equals(t, uint(64), res.PackSize)
+
+	gopts := env.gopts
+	gopts.Repo = ""
+	gopts.password = ""
+	gopts, err = res.apply(gopts)
+	rtest.OK(t, err)
+	rtest.OK(t, runInit(context.TODO(), InitOptions{}, gopts, nil))
+	rtest.OK(t, res.save(context.TODO(), gopts))
+
+	config, err := os.ReadFile(configFile)
+	rtest.OK(t, err)
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(configFile)
+		rtest.OK(t, err)
+		rtest.Equals(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+	rtest.Equals(t, "# restic configuration created by \"restic init --wizard\"\n"+
+		"RESTIC_REPOSITORY="+shellQuote(env.repo)+"\n"+
+		"RESTIC_PASSWORD_FILE="+shellQuote(res.PasswordFile)+"\n"+
+		"RESTIC_COMPRESSION=max\n"+
+		"RESTIC_PACK_SIZE=64\n", string(config))
+
+	// the repository can be opened using the stored password
+	gopts.password = ""
+	gopts.PasswordFile = res.PasswordFile
+	gopts.password, err = resolvePassword(context.TODO(), gopts, "")
+	rtest.OK(t, err)
+	_, err = OpenRepository(context.TODO(), gopts)
+	rtest.OK(t, err)
+
+	// incomplete answers are rejected
+	_, err = newInitWizard(context.TODO(), strings.NewReader("1\n"), io.Discard).run()
+	rtest.Assert(t, err != nil, "expected incomplete answers to fail")
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, test := range []struct {
+		in, out string
+	}{
+		{"/home/user/repo", "/home/user/repo"},
+		{"s3:s3.amazonaws.com/bucket", "s3:s3.amazonaws.com/bucket"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+	} {
+		rtest.Equals(t, test.out, shellQuote(test.in))
+	}
+}
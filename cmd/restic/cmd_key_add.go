@@ -16,6 +16,14 @@ var cmdKeyAdd = &cobra.Command{
 	Long: `
 The "add" sub-command creates a new key and validates the key. Returns the new key ID.
 
+If --new-password-keyfile is specified, both the new password and the keyfile
+are required to open the repository using the new key. Pass the keyfile to other
+commands using --password-keyfile.
+
+For append-only repositories, the new key cannot remove data or snapshots
+unless --allow-delete is passed. This requires that the current key also has
+the delete capability.
+
 EXIT STATUS
 ===========
 
@@ -33,6 +41,8 @@ type KeyAddOptions struct {
 	InsecureNoPassword bool
 	Username           string
 	Hostname           string
+	NewPasswordKeyfile string
+	AllowDelete        bool
 }
 
 func (opts *KeyAddOptions) Add(flags *pflag.FlagSet) {
@@ -40,6 +50,7 @@ func (opts *KeyAddOptions) Add(flags *pflag.FlagSet) {
 	flags.BoolVar(&opts.InsecureNoPassword, "new-insecure-no-password", false, "add an empty password for the repository (insecure)")
 	flags.StringVarP(&opts.Username, "user", "", "", "the username for new key")
 	flags.StringVarP(&opts.Hostname, "host", "", "", "the hostname for new key")
+	flags.StringVar(&opts.NewPasswordKeyfile, "new-password-keyfile", "", "require the `file` in addition to the new password to open the new key")
 }
 
 func init() {
@@ -47,6 +58,7 @@ func init() {
 
 	var keyAddOpts KeyAddOptions
 	keyAddOpts.Add(cmdKeyAdd.Flags())
+	cmdKeyAdd.Flags().BoolVar(&keyAddOpts.AllowDelete, "allow-delete", false, "allow the new key to remove data and snapshots from an append-only repository")
 	cmdKeyAdd.RunE = func(cmd *cobra.Command, args []string) error {
 		return runKeyAdd(cmd.Context(), globalOptions, keyAddOpts, args)
 	}
@@ -67,15 +79,35 @@ func runKeyAdd(ctx context.Context, gopts GlobalOptions, opts KeyAddOptions, arg
 }
 
 func addKey(ctx context.Context, repo *repository.Repository, gopts GlobalOptions, opts KeyAddOptions) error {
+	var capabilities []string
+	if opts.AllowDelete {
+		if !repo.CanDelete() {
+			return repository.ErrAppendOnly
+		}
+		capabilities = append(capabilities, repository.KeyCapabilityDelete)
+	}
+
+	var keyfile []byte
+	if opts.NewPasswordKeyfile != "" {
+		var err error
+		keyfile, err = loadKeyfile(opts.NewPasswordKeyfile)
+		if err != nil {
+			return err
+		}
+	}
+
 	pw, err := getNewPassword(ctx, gopts, opts.NewPasswordFile, opts.InsecureNoPassword)
 	if err != nil {
 		return err
 	}
 
-	id, err := repository.AddKey(ctx, repo, pw, opts.Username, opts.Hostname, repo.Key())
+	id, err := repository.AddKey(ctx, repo, pw, opts.Username, opts.Hostname, repo.Key(), capabilities, keyfile)
 	if err != nil {
 		return errors.Fatalf("creating new key failed: %v\n", err)
 	}
+	if keyfile != nil {
+		repo.UseKeyfile(keyfile)
+	}
 
 	err = switchToNewKeyAndRemoveIfBroken(ctx, repo, id, pw)
 	if err != nil {
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/escrow"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var cmdKeyExport = &cobra.Command{
+	Use:   "export",
+	Short: "Export the master key to an escrow file encrypted to offline recipients",
+	Long: `
+The "export" sub-command writes the master key of the repository to an escrow
+file. The file is encrypted to one or more recipients using the age file format
+(https://age-encryption.org). Recipients are age X25519 public keys as created
+by "age-keygen", for example "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p".
+
+The escrow file allows restoring access to the repository using "key import" if
+all passwords are lost. Anyone holding the escrow file and the private key of
+one of the recipients has full access to the repository. Store the private keys
+offline and separate from the escrow file.
+
+The escrow file is written to stdout unless "--output" is specified.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+	DisableAutoGenTag: true,
+}
+
+type KeyExportOptions struct {
+	Output         string
+	Recipients     []string
+	RecipientsFile string
+}
+
+func (opts *KeyExportOptions) Add(flags *pflag.FlagSet) {
+	flags.StringVar(&opts.Output, "output", "", "write the escrow file to `file` instead of stdout")
+	flags.StringArrayVar(&opts.Recipients, "recipient", nil, "encrypt the escrow file to the age `recipient` (can be specified multiple times)")
+	flags.StringVar(&opts.RecipientsFile, "recipients-file", "", "read age recipients from `file`, one per line")
+}
+
+func init() {
+	cmdKey.AddCommand(cmdKeyExport)
+
+	var keyExportOpts KeyExportOptions
+	keyExportOpts.Add(cmdKeyExport.Flags())
+	cmdKeyExport.RunE = func(cmd *cobra.Command, args []string) error {
+		return runKeyExport(cmd.Context(), globalOptions, keyExportOpts, args)
+	}
+}
+
+func loadRecipients(opts KeyExportOptions) ([]*escrow.Recipient, error) {
+	lines := append([]string{}, opts.Recipients...)
+	if opts.RecipientsFile != "" {
+		f, err := os.Open(opts.RecipientsFile)
+		if err != nil {
+			return nil, errors.Fatalf("unable to read recipients file: %v", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, errors.Fatalf("unable to read recipients file: %v", err)
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, errors.Fatal("no recipients specified, use --recipient or --recipients-file")
+	}
+
+	var recipients []*escrow.Recipient
+	for _, line := range lines {
+		r, err := escrow.ParseRecipient(line)
+		if err != nil {
+			return nil, errors.Fatalf("%v", err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+func runKeyExport(ctx context.Context, gopts GlobalOptions, opts KeyExportOptions, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("the key export command expects no arguments, only options - please see `restic help key export` for usage and flags")
+	}
+
+	recipients, err := loadRecipients(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := escrow.Export(repo.Config().ID, repo.Key(), recipients)
+	if err != nil {
+		return errors.Fatalf("exporting master key failed: %v", err)
+	}
+
+	if opts.Output == "" {
+		_, err = globalOptions.stdout.Write(data)
+		return err
+	}
+
+	err = os.WriteFile(opts.Output, data, 0600)
+	if err != nil {
+		return errors.Fatalf("unable to write escrow file: %v", err)
+	}
+	Verbosef("exported master key for %d recipients to %v\n", len(recipients), opts.Output)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/escrow"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var cmdKeyImport = &cobra.Command{
+	Use:   "import",
+	Short: "Recover access to the repository from an escrow file; returns the new key ID",
+	Long: `
+The "import" sub-command decrypts an escrow file created by "key export" using
+an age identity file as created by "age-keygen". It then uses the master key
+stored in the escrow file to add a new key (password) to the repository. No
+existing password is required.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+	`,
+	DisableAutoGenTag: true,
+}
+
+type KeyImportOptions struct {
+	KeyAddOptions
+	Input         string
+	IdentityFiles []string
+}
+
+func (opts *KeyImportOptions) Add(flags *pflag.FlagSet) {
+	opts.KeyAddOptions.Add(flags)
+	flags.StringVar(&opts.Input, "input", "", "read the escrow file from `file`")
+	flags.StringArrayVar(&opts.IdentityFiles, "identity", nil, "age identity `file` used to decrypt the escrow file (can be specified multiple times)")
+}
+
+func init() {
+	cmdKey.AddCommand(cmdKeyImport)
+
+	var keyImportOpts KeyImportOptions
+	keyImportOpts.Add(cmdKeyImport.Flags())
+	cmdKeyImport.RunE = func(cmd *cobra.Command, args []string) error {
+		return runKeyImport(cmd.Context(), globalOptions, keyImportOpts, args)
+	}
+}
+
+func loadIdentities(files []string) ([]*escrow.Identity, error) {
+	if len(files) == 0 {
+		return nil, errors.Fatal("no identity file specified, use --identity")
+	}
+
+	var identities []*escrow.Identity
+	for _, filename := range files {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, errors.Fatalf("unable to read identity file: %v", err)
+		}
+		ids, err := escrow.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Fatalf("unable to parse identity file %v: %v", filename, err)
+		}
+		identities = append(identities, ids...)
+	}
+	return identities, nil
+}
+
+func runKeyImport(ctx context.Context, gopts GlobalOptions, opts KeyImportOptions, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("the key import command expects no arguments, only options - please see `restic help key import` for usage and flags")
+	}
+	if opts.Input == "" {
+		return errors.Fatal("no escrow file specified, use --input")
+	}
+
+	identities, err := loadIdentities(opts.IdentityFiles)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(opts.Input)
+	if err != nil {
+		return errors.Fatalf("unable to read escrow file: %v", err)
+	}
+	keyFile, err := escrow.Import(data, identities)
+	if err != nil {
+		return errors.Fatalf("unable to decrypt escrow file: %v", err)
+	}
+
+	gopts.masterKey = keyFile.MasterKey
+	ctx, repo, unlock, err := openWithAppendLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if repo.Config().ID != keyFile.Repository {
+		Warnf("escrow file was exported from repository %v, the repository ID is %v\n", keyFile.Repository, repo.Config().ID)
+	}
+
+	return addKey(ctx, repo, gopts, opts.KeyAddOptions)
+}
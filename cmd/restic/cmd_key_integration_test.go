@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -10,8 +11,10 @@ import (
 	"testing"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/escrow"
 	"github.com/restic/restic/internal/repository"
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
 )
 
 func testRunKeyListOtherIDs(t testing.TB, gopts GlobalOptions) []string {
@@ -148,6 +151,46 @@ func TestKeyAddEmpty(t *testing.T) {
 	testRunCheck(t, env.gopts)
 }
 
+func TestKeyAddKeyfile(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list keys more than once
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	keyfile := filepath.Join(env.base, "key.bin")
+	rtest.OK(t, os.WriteFile(keyfile, rtest.Random(42, 64), 0o600))
+	otherKeyfile := filepath.Join(env.base, "other.bin")
+	rtest.OK(t, os.WriteFile(otherKeyfile, rtest.Random(43, 64), 0o600))
+
+	testKeyNewPassword = "mfa"
+	err := runKeyAdd(context.TODO(), env.gopts, KeyAddOptions{NewPasswordKeyfile: keyfile}, []string{})
+	testKeyNewPassword = ""
+	rtest.OK(t, err)
+
+	gopts := env.gopts
+	gopts.password = "mfa"
+	_, err = OpenRepository(context.TODO(), gopts)
+	rtest.Assert(t, errors.Is(err, repository.ErrNoKeyFound), "opening without keyfile: unexpected error %v", err)
+	gopts.PasswordKeyfile = otherKeyfile
+	_, err = OpenRepository(context.TODO(), gopts)
+	rtest.Assert(t, errors.Is(err, repository.ErrNoKeyFound), "opening with wrong keyfile: unexpected error %v", err)
+	gopts.PasswordKeyfile = keyfile
+	testRunCheck(t, gopts)
+
+	// the new key still requires the keyfile after changing the password
+	testRunKeyPasswd(t, "mfa2", gopts)
+	gopts.password = "mfa2"
+	testRunCheck(t, gopts)
+	gopts.PasswordKeyfile = ""
+	_, err = OpenRepository(context.TODO(), gopts)
+	rtest.Assert(t, errors.Is(err, repository.ErrNoKeyFound), "opening without keyfile: unexpected error %v", err)
+
+	// a keyfile is ignored for keys which do not require it
+	env.gopts.PasswordKeyfile = keyfile
+	testRunCheck(t, env.gopts)
+}
+
 type emptySaveBackend struct {
 	backend.Backend
 }
@@ -212,3 +255,85 @@ func TestKeyCommandInvalidArguments(t *testing.T) {
 	t.Log(err)
 	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "one argument"), "unexpected error for key remove: %v", err)
 }
+
+func TestKeyExportImport(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list keys more than once
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	identity, err := escrow.GenerateIdentity()
+	rtest.OK(t, err)
+	identityFile := filepath.Join(env.base, "identity.txt")
+	rtest.OK(t, os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600))
+	escrowFile := filepath.Join(env.base, "key.enc")
+
+	err = runKeyExport(context.TODO(), env.gopts, KeyExportOptions{Output: escrowFile}, nil)
+	rtest.Assert(t, err != nil, "expected error without recipients")
+
+	rtest.OK(t, runKeyExport(context.TODO(), env.gopts, KeyExportOptions{
+		Output:     escrowFile,
+		Recipients: []string{identity.Recipient().String()},
+	}, nil))
+
+	// an unrelated identity cannot decrypt the escrow file
+	other, err := escrow.GenerateIdentity()
+	rtest.OK(t, err)
+	otherFile := filepath.Join(env.base, "other.txt")
+	rtest.OK(t, os.WriteFile(otherFile, []byte(other.String()+"\n"), 0600))
+	err = runKeyImport(context.TODO(), env.gopts, KeyImportOptions{Input: escrowFile, IdentityFiles: []string{otherFile}}, nil)
+	rtest.Assert(t, err != nil, "expected error for wrong identity")
+
+	// recover access without knowing the current password
+	testKeyNewPassword = "recovered"
+	defer func() {
+		testKeyNewPassword = ""
+	}()
+	gopts := env.gopts
+	gopts.password = "wrong password"
+	rtest.OK(t, runKeyImport(context.TODO(), gopts, KeyImportOptions{Input: escrowFile, IdentityFiles: []string{identityFile}}, nil))
+
+	env.gopts.password = "recovered"
+	rtest.Equals(t, 1, len(testRunKeyListOtherIDs(t, env.gopts)))
+	testRunCheck(t, env.gopts)
+}
+
+func TestKeyRotateMaster(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list keys more than once
+	env.gopts.backendTestHook = nil
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	testRunKeyAddNewKey(t, "other", env.gopts)
+
+	testKeyNewPassword = "rotated"
+	defer func() {
+		testKeyNewPassword = ""
+	}()
+
+	err := withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runKeyRotateMaster(ctx, env.gopts, KeyRotateMasterOptions{}, []string{}, term)
+	})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "--remove-other-keys"), "unexpected error %v", err)
+
+	rtest.OK(t, withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runKeyRotateMaster(ctx, env.gopts, KeyRotateMasterOptions{RemoveOtherKeys: true}, []string{}, term)
+	}))
+
+	env.gopts.password = "rotated"
+	rtest.Equals(t, 0, len(testRunKeyListOtherIDs(t, env.gopts)))
+	testRunCheck(t, env.gopts)
+
+	// the snapshot was saved again using the new master key
+	newIDs := testListSnapshots(t, env.gopts, 1)
+	rtest.Assert(t, newIDs[0] != snapshotIDs[0], "snapshot ID did not change")
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, newIDs[0].String()+":"+toPathInSnapshot(filepath.Dir(env.testdata)))
+	diff := directoriesContentsDiff(env.testdata, filepath.Join(restoredir, "testdata"))
+	rtest.Assert(t, diff == "", "directories are not equal: %v", diff)
+}
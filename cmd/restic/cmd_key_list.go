@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 
 	"github.com/restic/restic/internal/repository"
@@ -61,6 +62,9 @@ func listKeys(ctx context.Context, s *repository.Repository, gopts GlobalOptions
 		UserName string `json:"userName"`
 		HostName string `json:"hostName"`
 		Created  string `json:"created"`
+
+		Capabilities []string `json:"capabilities,omitempty"`
+		Keyfile      bool     `json:"keyfile"`
 	}
 
 	var m sync.Mutex
@@ -80,6 +84,9 @@ func listKeys(ctx context.Context, s *repository.Repository, gopts GlobalOptions
 			UserName: k.Username,
 			HostName: k.Hostname,
 			Created:  k.Created.Local().Format(TimeFormat),
+
+			Capabilities: k.Capabilities,
+			Keyfile:      k.Keyfile,
 		}
 
 		m.Lock()
@@ -101,6 +108,12 @@ func listKeys(ctx context.Context, s *repository.Repository, gopts GlobalOptions
 	tab.AddColumn("User", "{{ .UserName }}")
 	tab.AddColumn("Host", "{{ .HostName }}")
 	tab.AddColumn("Created", "{{ .Created }}")
+	if slices.ContainsFunc(keys, func(k keyInfo) bool { return k.Keyfile }) {
+		tab.AddColumn("Keyfile", "{{if .Keyfile}}yes{{else}}no{{end}}")
+	}
+	if s.Config().AppendOnly {
+		tab.AddColumn("Capabilities", "{{ join .Capabilities \", \" }}")
+	}
 
 	for _, key := range keys {
 		tab.AddRow(key)
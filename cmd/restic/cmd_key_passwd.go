@@ -16,6 +16,9 @@ var cmdKeyPasswd = &cobra.Command{
 The "passwd" sub-command creates a new key, validates the key and remove the old key ID.
 Returns the new key ID. 
 
+If the current key requires a keyfile, the new key requires the same keyfile
+unless a different one is specified using --new-password-keyfile.
+
 EXIT STATUS
 ===========
 
@@ -57,15 +60,37 @@ func runKeyPasswd(ctx context.Context, gopts GlobalOptions, opts KeyPasswdOption
 }
 
 func changePassword(ctx context.Context, repo *repository.Repository, gopts GlobalOptions, opts KeyPasswdOptions) error {
+	// the new key requires the same keyfile as the current key, unless a
+	// different one is specified
+	var keyfile []byte
+	if opts.NewPasswordKeyfile != "" {
+		var err error
+		keyfile, err = loadKeyfile(opts.NewPasswordKeyfile)
+		if err != nil {
+			return err
+		}
+	} else if !repo.KeyID().IsNull() {
+		current, err := repository.LoadKey(ctx, repo, repo.KeyID())
+		if err != nil {
+			return err
+		}
+		if current.Keyfile {
+			keyfile = repo.Keyfile()
+		}
+	}
+
 	pw, err := getNewPassword(ctx, gopts, opts.NewPasswordFile, opts.InsecureNoPassword)
 	if err != nil {
 		return err
 	}
 
-	id, err := repository.AddKey(ctx, repo, pw, "", "", repo.Key())
+	id, err := repository.AddKey(ctx, repo, pw, "", "", repo.Key(), repo.KeyCapabilities(), keyfile)
 	if err != nil {
 		return errors.Fatalf("creating new key failed: %v\n", err)
 	}
+	if keyfile != nil {
+		repo.UseKeyfile(keyfile)
+	}
 	oldID := repo.KeyID()
 
 	err = switchToNewKeyAndRemoveIfBroken(ctx, repo, id, pw)
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/ui/termstatus"
+	"github.com/spf13/cobra"
+)
+
+var cmdKeyRotateMaster = &cobra.Command{
+	Use:   "rotate-master",
+	Short: "Replace the master key and re-encrypt all data in the repository",
+	Long: `
+The "rotate-master" sub-command generates a new master key and re-encrypts all
+data in the repository using it. This allows retiring a master key which may
+have been leaked, without copying all data to a new repository.
+
+The current key is replaced by a new key for the new password. All other keys
+still grant access using the previous master key and must be removed first,
+either using "restic key remove" or by passing "--remove-other-keys". Add new
+keys for other users once the rotation is complete.
+
+All pack files are repacked, which can take a long time. The option
+"--max-duration" stops repacking after the given duration. Until the rotation is
+complete, the previous master key is kept in the key file such that all data
+remains accessible. Run the command again to continue the rotation. Do not
+access the repository using older restic versions before the rotation is
+complete.
+
+Snapshot files are also saved again, which changes their IDs. Snapshot aliases,
+superseded snapshots and the replication state are updated accordingly. Mirrors
+created using "restic replicate" from this repository copy all snapshots again.
+The rotation requires repository version 3.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+Exit status is 13 if the server refused to remove data, for example because the
+repository is append-only.
+	`,
+	DisableAutoGenTag: true,
+}
+
+// KeyRotateMasterOptions collects all options for the key rotate-master command.
+type KeyRotateMasterOptions struct {
+	NewPasswordFile    string
+	InsecureNoPassword bool
+	RemoveOtherKeys    bool
+	MaxDuration        time.Duration
+	OverrideHold       string
+}
+
+func init() {
+	cmdKey.AddCommand(cmdKeyRotateMaster)
+
+	var opts KeyRotateMasterOptions
+	f := cmdKeyRotateMaster.Flags()
+	f.StringVarP(&opts.NewPasswordFile, "new-password-file", "", "", "`file` from which to read the new password")
+	f.BoolVar(&opts.InsecureNoPassword, "new-insecure-no-password", false, "use an empty new password for the repository (insecure)")
+	f.BoolVar(&opts.RemoveOtherKeys, "remove-other-keys", false, "remove all keys except the current one")
+	f.DurationVar(&opts.MaxDuration, "max-duration", 0, "stop re-encrypting after the given `duration`, like 30m or 2h, a later run continues the work (default: no limit)")
+	f.StringVar(&opts.OverrideHold, "override-hold", "", "run despite an active legal hold, the `justification` is recorded in the repository")
+	cmdKeyRotateMaster.RunE = func(cmd *cobra.Command, args []string) error {
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runKeyRotateMaster(cmd.Context(), globalOptions, opts, args, term)
+	}
+}
+
+func runKeyRotateMaster(ctx context.Context, gopts GlobalOptions, opts KeyRotateMasterOptions, args []string, term *termstatus.Terminal) error {
+	if len(args) > 0 {
+		return fmt.Errorf("the key rotate-master command expects no arguments, only options - please see `restic help key rotate-master` for usage and flags")
+	}
+	if opts.MaxDuration < 0 {
+		return errors.Fatal("--max-duration must not be negative")
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := checkHold(ctx, repo, "key rotate-master", opts.OverrideHold); err != nil {
+		return err
+	}
+
+	pw, err := getNewPassword(ctx, gopts, opts.NewPasswordFile, opts.InsecureNoPassword)
+	if err != nil {
+		return err
+	}
+
+	var deadline time.Time
+	if opts.MaxDuration > 0 {
+		deadline = time.Now().Add(opts.MaxDuration)
+	}
+
+	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	complete, err := repository.RotateMasterKey(ctx, repo, repository.RotateMasterKeyOptions{
+		Password:        pw,
+		RemoveOtherKeys: opts.RemoveOtherKeys,
+		Deadline:        deadline,
+	}, printer)
+	if errors.Is(err, repository.ErrOtherKeysExist) {
+		return errors.Fatalf("%v, remove them using \"restic key remove\" or pass --remove-other-keys", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if complete {
+		printer.P("master key rotation complete\n")
+	}
+	return nil
+}
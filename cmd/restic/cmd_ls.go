@@ -14,6 +14,7 @@ import (
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/walker"
 )
 
@@ -36,6 +37,13 @@ will allow traversing into matching directories' subfolders.
 Any directory paths specified must be absolute (starting with
 a path separator); paths use the forward slash '/' as separator.
 
+The listed entries can be filtered by their metadata. The options
+--newer-than and --older-than select entries by their modification time
+relative to now, for example "--newer-than 7d". The options --min-size
+and --max-size only list files within the given size range, and --type
+only lists entries of the given type. Directories are still traversed if
+they do not match the filters.
+
 EXIT STATUS
 ===========
 
@@ -59,6 +67,13 @@ type LsOptions struct {
 	Recursive     bool
 	HumanReadable bool
 	Ncdu          bool
+	Blobs         bool
+
+	NewerThan restic.Duration
+	OlderThan restic.Duration
+	MinSize   string
+	MaxSize   string
+	Types     []string
 }
 
 var lsOptions LsOptions
@@ -72,6 +87,127 @@ func init() {
 	flags.BoolVar(&lsOptions.Recursive, "recursive", false, "include files in subfolders of the listed directories")
 	flags.BoolVar(&lsOptions.HumanReadable, "human-readable", false, "print sizes in human readable format")
 	flags.BoolVar(&lsOptions.Ncdu, "ncdu", false, "output NCDU export format (pipe into 'ncdu -f -')")
+	flags.BoolVar(&lsOptions.Blobs, "blobs", false, "include the blobs of each file and the pack files containing them (requires --json)")
+	flags.Var(&lsOptions.NewerThan, "newer-than", "only list entries modified within the last `duration` (e.g. 7d or 12h)")
+	flags.Var(&lsOptions.OlderThan, "older-than", "only list entries modified more than `duration` ago (e.g. 1y6m)")
+	flags.StringVar(&lsOptions.MinSize, "min-size", "", "only list files with at least `size` (e.g. 100M)")
+	flags.StringVar(&lsOptions.MaxSize, "max-size", "", "only list files with at most `size` (e.g. 1G)")
+	flags.StringArrayVar(&lsOptions.Types, "type", nil, "only list entries of `type` (file, dir, symlink, dev, chardev, fifo, socket or irregular, can be specified multiple times)")
+}
+
+// lsFilter selects the entries printed by ls based on their metadata. Its
+// zero value matches all entries.
+type lsFilter struct {
+	// modification time range, zero values are unbounded
+	newerThan, olderThan time.Time
+	// size range, only files match if one of the bounds is set
+	minSize, maxSize int64
+	types            map[restic.NodeType]struct{}
+}
+
+var lsNodeTypes = []restic.NodeType{
+	restic.NodeTypeFile, restic.NodeTypeDir, restic.NodeTypeSymlink, restic.NodeTypeDev,
+	restic.NodeTypeCharDev, restic.NodeTypeFifo, restic.NodeTypeSocket, restic.NodeTypeIrregular,
+}
+
+// durationBefore returns the time d before now.
+func durationBefore(now time.Time, d restic.Duration) time.Time {
+	return now.AddDate(-d.Years, -d.Months, -d.Days).Add(time.Hour * time.Duration(-d.Hours))
+}
+
+func newLsFilter(opts LsOptions, now time.Time) (lsFilter, error) {
+	f := lsFilter{minSize: -1, maxSize: -1}
+
+	for _, d := range []struct {
+		name string
+		d    restic.Duration
+		t    *time.Time
+	}{
+		{"--newer-than", opts.NewerThan, &f.newerThan},
+		{"--older-than", opts.OlderThan, &f.olderThan},
+	} {
+		if d.d.Zero() {
+			continue
+		}
+		if d.d.Hours < 0 || d.d.Days < 0 || d.d.Months < 0 || d.d.Years < 0 {
+			return lsFilter{}, errors.Fatalf("%v must not be negative", d.name)
+		}
+		*d.t = durationBefore(now, d.d)
+	}
+
+	for _, s := range []struct {
+		name  string
+		value string
+		size  *int64
+	}{
+		{"--min-size", opts.MinSize, &f.minSize},
+		{"--max-size", opts.MaxSize, &f.maxSize},
+	} {
+		if s.value == "" {
+			continue
+		}
+		size, err := ui.ParseBytes(s.value)
+		if err != nil {
+			return lsFilter{}, errors.Fatalf("invalid %v: %v", s.name, err)
+		}
+		if size < 0 {
+			return lsFilter{}, errors.Fatalf("%v must not be negative", s.name)
+		}
+		*s.size = size
+	}
+	if f.minSize >= 0 && f.maxSize >= 0 && f.minSize > f.maxSize {
+		return lsFilter{}, errors.Fatal("--min-size must not be larger than --max-size")
+	}
+
+	for _, t := range opts.Types {
+		found := false
+		for _, nt := range lsNodeTypes {
+			if restic.NodeType(t) == nt {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return lsFilter{}, errors.Fatalf("invalid --type %q", t)
+		}
+		if f.types == nil {
+			f.types = make(map[restic.NodeType]struct{})
+		}
+		f.types[restic.NodeType(t)] = struct{}{}
+	}
+	return f, nil
+}
+
+// active returns whether the filter rejects any entries.
+func (f lsFilter) active() bool {
+	return !f.newerThan.IsZero() || !f.olderThan.IsZero() || f.minSize >= 0 || f.maxSize >= 0 || f.types != nil
+}
+
+// match returns whether node should be printed.
+func (f lsFilter) match(node *restic.Node) bool {
+	if !f.newerThan.IsZero() && node.ModTime.Before(f.newerThan) {
+		return false
+	}
+	if !f.olderThan.IsZero() && !node.ModTime.Before(f.olderThan) {
+		return false
+	}
+	if f.minSize >= 0 || f.maxSize >= 0 {
+		if node.Type != restic.NodeTypeFile {
+			return false
+		}
+		if f.minSize >= 0 && node.Size < uint64(f.minSize) {
+			return false
+		}
+		if f.maxSize >= 0 && node.Size > uint64(f.maxSize) {
+			return false
+		}
+	}
+	if f.types != nil {
+		if _, ok := f.types[node.Type]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 type lsPrinter interface {
@@ -83,6 +219,8 @@ type lsPrinter interface {
 
 type jsonLsPrinter struct {
 	enc *json.Encoder
+	// blobs is used to look up the blobs of files, if set
+	blobs blobLookup
 }
 
 func (p *jsonLsPrinter) Snapshot(sn *restic.Snapshot) error {
@@ -108,10 +246,47 @@ func (p *jsonLsPrinter) Node(path string, node *restic.Node, isPrefixDirectory b
 	if isPrefixDirectory {
 		return nil
 	}
-	return lsNodeJSON(p.enc, path, node)
+	return lsNodeJSON(p.enc, path, node, p.blobs)
+}
+
+// blobLookup returns the locations of a blob in the repository.
+type blobLookup interface {
+	LookupBlob(t restic.BlobType, id restic.ID) []restic.PackedBlob
+}
+
+// jsonBlob describes a blob of a file and the pack files it is stored in.
+type jsonBlob struct {
+	ID    restic.ID      `json:"id"`
+	Size  uint           `json:"size"`
+	Packs []jsonBlobPack `json:"packs"`
 }
 
-func lsNodeJSON(enc *json.Encoder, path string, node *restic.Node) error {
+// jsonBlobPack describes the location of a blob in a pack file.
+type jsonBlobPack struct {
+	ID     restic.ID `json:"id"`
+	Offset uint      `json:"offset"`
+	Length uint      `json:"length"`
+}
+
+// nodeBlobsJSON returns the blobs of the file node in the order of its
+// content. A blob which is missing from the index has no packs and a size of
+// zero.
+func nodeBlobsJSON(lookup blobLookup, node *restic.Node) []jsonBlob {
+	blobs := make([]jsonBlob, 0, len(node.Content))
+	for _, id := range node.Content {
+		blob := jsonBlob{ID: id, Packs: []jsonBlobPack{}}
+		for _, pb := range lookup.LookupBlob(restic.DataBlob, id) {
+			blob.Size = pb.DataLength()
+			blob.Packs = append(blob.Packs, jsonBlobPack{ID: pb.PackID, Offset: pb.Offset, Length: pb.Length})
+		}
+		blobs = append(blobs, blob)
+	}
+	return blobs
+}
+
+// lsNodeJSON prints the node. If lookup is not nil, the blobs of files are
+// included.
+func lsNodeJSON(enc *json.Encoder, path string, node *restic.Node, lookup blobLookup) error {
 	n := &struct {
 		Name        string      `json:"name"`
 		Type        string      `json:"type"`
@@ -124,11 +299,14 @@ func lsNodeJSON(enc *json.Encoder, path string, node *restic.Node) error {
 		ModTime     time.Time   `json:"mtime,omitempty"`
 		AccessTime  time.Time   `json:"atime,omitempty"`
 		ChangeTime  time.Time   `json:"ctime,omitempty"`
+		BirthTime   *time.Time  `json:"btime,omitempty"`
 		Inode       uint64      `json:"inode,omitempty"`
+		Blobs       *[]jsonBlob `json:"blobs,omitempty"`
 		MessageType string      `json:"message_type"` // "node"
 		StructType  string      `json:"struct_type"`  // "node", deprecated
 
-		size uint64 // Target for Size pointer.
+		size  uint64     // Target for Size pointer.
+		blobs []jsonBlob // Target for Blobs pointer.
 	}{
 		Name:        node.Name,
 		Type:        string(node.Type),
@@ -141,6 +319,7 @@ func lsNodeJSON(enc *json.Encoder, path string, node *restic.Node) error {
 		ModTime:     node.ModTime,
 		AccessTime:  node.AccessTime,
 		ChangeTime:  node.ChangeTime,
+		BirthTime:   node.BirthTime,
 		Inode:       node.Inode,
 		MessageType: "node",
 		StructType:  "node",
@@ -149,6 +328,10 @@ func lsNodeJSON(enc *json.Encoder, path string, node *restic.Node) error {
 	// but never for other types.
 	if node.Type == restic.NodeTypeFile {
 		n.Size = &n.size
+		if lookup != nil {
+			n.blobs = nodeBlobsJSON(lookup, node)
+			n.Blobs = &n.blobs
+		}
 	}
 
 	return enc.Encode(n)
@@ -284,6 +467,16 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 	if opts.Ncdu && gopts.JSON {
 		return errors.Fatal("only either '--json' or '--ncdu' can be specified")
 	}
+	if opts.Blobs && !gopts.JSON {
+		return errors.Fatal("--blobs requires --json")
+	}
+	filter, err := newLsFilter(opts, time.Now())
+	if err != nil {
+		return err
+	}
+	if opts.Ncdu && filter.active() {
+		return errors.Fatal("--ncdu cannot be combined with the --newer-than, --older-than, --min-size, --max-size and --type filters")
+	}
 
 	// extract any specific directories to walk
 	var dirs []string
@@ -349,9 +542,13 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 	var printer lsPrinter
 
 	if gopts.JSON {
-		printer = &jsonLsPrinter{
+		p := &jsonLsPrinter{
 			enc: json.NewEncoder(globalOptions.stdout),
 		}
+		if opts.Blobs {
+			p.blobs = repo
+		}
+		printer = p
 	} else if opts.Ncdu {
 		printer = &ncduLsPrinter{
 			out: globalOptions.stdout,
@@ -392,11 +589,14 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 
 		printedDir := false
 		if withinDir(nodepath) {
-			// if we're within a target path, print the node
-			if err := printer.Node(nodepath, node, false); err != nil {
-				return err
+			// if we're within a target path, print the node unless it is
+			// rejected by the metadata filters
+			if filter.match(node) {
+				if err := printer.Node(nodepath, node, false); err != nil {
+					return err
+				}
+				printedDir = true
 			}
-			printedDir = true
 
 			// if recursive listing is requested, signal the walker that it
 			// should continue walking recursively
@@ -429,7 +629,7 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 		return nil
 	}
 
-	err = walker.Walk(ctx, repo, *sn.Tree, walker.WalkVisitor{
+	err = walker.WalkParallel(ctx, repo, *sn.Tree, int(repo.Connections()), walker.WalkVisitor{
 		ProcessNode: processNode,
 		LeaveDir: func(path string) error {
 			// the root path `/` has no corresponding node and is thus also skipped by processNode
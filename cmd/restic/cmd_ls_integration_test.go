@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -49,3 +50,85 @@ func TestRunLsNcdu(t *testing.T) {
 		assertIsValidJSON(t, ncdu)
 	}
 }
+
+func TestRunLsFilter(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, env.testdata+"/0", []string{"."}, BackupOptions{}, env.gopts)
+
+	gopts := env.gopts
+	gopts.JSON = true
+	out := testRunLsWithOpts(t, gopts, LsOptions{Recursive: true, MinSize: "10K", Types: []string{"file"}}, []string{"latest"})
+
+	files := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n")[1:] {
+		var node struct {
+			Type string `json:"type"`
+			Size uint64 `json:"size"`
+		}
+		rtest.OK(t, json.Unmarshal([]byte(line), &node))
+		rtest.Equals(t, "file", node.Type)
+		rtest.Assert(t, node.Size >= 10*1024, "file with %d bytes is too small", node.Size)
+		files++
+	}
+	rtest.Assert(t, files > 0, "no files listed")
+
+	_, err := withCaptureStdout(func() error {
+		return runLs(context.TODO(), LsOptions{Ncdu: true, MinSize: "1K"}, env.gopts, []string{"latest"})
+	})
+	rtest.Assert(t, err != nil, "expected error for --ncdu with filters")
+}
+
+func TestRunLsBlobs(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, env.testdata+"/0", []string{"."}, BackupOptions{}, env.gopts)
+
+	gopts := env.gopts
+	gopts.JSON = true
+	out := testRunLsWithOpts(t, gopts, LsOptions{Blobs: true, Recursive: true}, []string{"latest"})
+
+	ctx, repo, unlock, err := openWithReadLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	defer unlock()
+	rtest.OK(t, repo.LoadIndex(ctx, nil))
+
+	files := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		var node struct {
+			Type  string     `json:"type"`
+			Size  uint64     `json:"size"`
+			Blobs []jsonBlob `json:"blobs"`
+		}
+		rtest.OK(t, json.Unmarshal([]byte(line), &node))
+		if node.Type != "file" {
+			rtest.Assert(t, node.Blobs == nil, "unexpected blobs for %v", node.Type)
+			continue
+		}
+		files++
+
+		var size uint64
+		for _, blob := range node.Blobs {
+			rtest.Assert(t, len(blob.Packs) > 0, "blob %v has no pack", blob.ID)
+			for _, pack := range blob.Packs {
+				found := false
+				for _, pb := range repo.LookupBlob(restic.DataBlob, blob.ID) {
+					found = found || (pb.PackID == pack.ID && pb.Offset == pack.Offset && pb.Length == pack.Length)
+				}
+				rtest.Assert(t, found, "wrong location %v for blob %v", pack, blob.ID)
+			}
+			size += uint64(blob.Size)
+		}
+		rtest.Equals(t, node.Size, size)
+	}
+	rtest.Assert(t, files > 0, "no files listed")
+
+	_, err = withCaptureStdout(func() error {
+		return runLs(context.TODO(), LsOptions{Blobs: true}, env.gopts, []string{"latest"})
+	})
+	rtest.Assert(t, err != nil, "--blobs without --json was accepted")
+}
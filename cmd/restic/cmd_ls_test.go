@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -96,7 +97,7 @@ func TestLsNodeJSON(t *testing.T) {
 		c := lsTestNodes[i]
 		buf := new(bytes.Buffer)
 		enc := json.NewEncoder(buf)
-		err := lsNodeJSON(enc, c.path, &c.Node)
+		err := lsNodeJSON(enc, c.path, &c.Node, nil)
 		rtest.OK(t, err)
 		rtest.Equals(t, expect+"\n", buf.String())
 
@@ -168,3 +169,96 @@ func TestLsNcdu(t *testing.T) {
 ]
 `, buf.String())
 }
+
+// testBlobLookup stores each blob at a fixed location in a single pack file.
+type testBlobLookup map[restic.ID]restic.PackedBlob
+
+func (l testBlobLookup) LookupBlob(_ restic.BlobType, id restic.ID) []restic.PackedBlob {
+	pb, ok := l[id]
+	if !ok {
+		return nil
+	}
+	return []restic.PackedBlob{pb}
+}
+
+func TestLsNodeJSONBlobs(t *testing.T) {
+	blob := restic.TestParseID("1111111111111111111111111111111111111111111111111111111111111111")
+	missing := restic.TestParseID("2222222222222222222222222222222222222222222222222222222222222222")
+	pack := restic.TestParseID("3333333333333333333333333333333333333333333333333333333333333333")
+	lookup := testBlobLookup{
+		blob: {
+			Blob: restic.Blob{
+				BlobHandle: restic.BlobHandle{Type: restic.DataBlob, ID: blob},
+				Length:     uint(crypto.CiphertextLength(100)),
+				Offset:     200,
+			},
+			PackID: pack,
+		},
+	}
+
+	for _, test := range []struct {
+		node   restic.Node
+		expect string
+	}{
+		{
+			restic.Node{Name: "file", Type: restic.NodeTypeFile, Size: 100, Content: restic.IDs{blob, missing}},
+			`{"name":"file","type":"file","path":"/file","uid":0,"gid":0,"size":100,"permissions":"----------","mtime":"0001-01-01T00:00:00Z","atime":"0001-01-01T00:00:00Z","ctime":"0001-01-01T00:00:00Z","blobs":[{"id":"1111111111111111111111111111111111111111111111111111111111111111","size":100,"packs":[{"id":"3333333333333333333333333333333333333333333333333333333333333333","offset":200,"length":132}]},{"id":"2222222222222222222222222222222222222222222222222222222222222222","size":0,"packs":[]}],"message_type":"node","struct_type":"node"}`,
+		},
+		{
+			restic.Node{Name: "file", Type: restic.NodeTypeFile},
+			`{"name":"file","type":"file","path":"/file","uid":0,"gid":0,"size":0,"permissions":"----------","mtime":"0001-01-01T00:00:00Z","atime":"0001-01-01T00:00:00Z","ctime":"0001-01-01T00:00:00Z","blobs":[],"message_type":"node","struct_type":"node"}`,
+		},
+		{
+			restic.Node{Name: "file", Type: restic.NodeTypeDir},
+			`{"name":"file","type":"dir","path":"/file","uid":0,"gid":0,"permissions":"----------","mtime":"0001-01-01T00:00:00Z","atime":"0001-01-01T00:00:00Z","ctime":"0001-01-01T00:00:00Z","message_type":"node","struct_type":"node"}`,
+		},
+	} {
+		buf := new(bytes.Buffer)
+		rtest.OK(t, lsNodeJSON(json.NewEncoder(buf), "/file", &test.node, lookup))
+		rtest.Equals(t, test.expect+"\n", buf.String())
+	}
+}
+
+func TestLsFilter(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	file := func(size uint64, age time.Duration) *restic.Node {
+		return &restic.Node{Type: restic.NodeTypeFile, Size: size, ModTime: now.Add(-age)}
+	}
+	dir := &restic.Node{Type: restic.NodeTypeDir, ModTime: now.Add(-time.Hour)}
+
+	for _, test := range []struct {
+		opts    LsOptions
+		node    *restic.Node
+		matches bool
+	}{
+		{LsOptions{}, dir, true},
+		{LsOptions{NewerThan: restic.Duration{Days: 7}}, file(1, 24*time.Hour), true},
+		{LsOptions{NewerThan: restic.Duration{Days: 7}}, file(1, 8*24*time.Hour), false},
+		{LsOptions{OlderThan: restic.Duration{Days: 7}}, file(1, 24*time.Hour), false},
+		{LsOptions{OlderThan: restic.Duration{Days: 7}}, file(1, 8*24*time.Hour), true},
+		{LsOptions{MinSize: "1K"}, file(1024, 0), true},
+		{LsOptions{MinSize: "1K"}, file(1023, 0), false},
+		{LsOptions{MaxSize: "1K"}, file(1025, 0), false},
+		{LsOptions{MaxSize: "1K"}, file(0, 0), true},
+		{LsOptions{MaxSize: "1K"}, dir, false},
+		{LsOptions{Types: []string{"dir"}}, dir, true},
+		{LsOptions{Types: []string{"dir"}}, file(0, 0), false},
+		{LsOptions{Types: []string{"dir", "file"}}, file(0, 0), true},
+		{LsOptions{NewerThan: restic.Duration{Days: 7}, MinSize: "1M"}, file(2*1024*1024, time.Hour), true},
+		{LsOptions{NewerThan: restic.Duration{Days: 7}, MinSize: "1M"}, file(2*1024*1024, 30*24*time.Hour), false},
+	} {
+		f, err := newLsFilter(test.opts, now)
+		rtest.OK(t, err)
+		rtest.Assert(t, f.match(test.node) == test.matches, "%+v: expected match %v for %+v", test.opts, test.matches, test.node)
+	}
+
+	for _, opts := range []LsOptions{
+		{NewerThan: restic.Duration{Days: -1}},
+		{MinSize: "foo"},
+		{MinSize: "2K", MaxSize: "1K"},
+		{Types: []string{"blob"}},
+	} {
+		_, err := newLsFilter(opts, now)
+		rtest.Assert(t, err != nil, "expected error for %+v", opts)
+	}
+}
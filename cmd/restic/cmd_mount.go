@@ -26,7 +26,30 @@ var cmdMount = &cobra.Command{
 	Short: "Mount the repository",
 	Long: `
 The "mount" command mounts the repository via fuse to a directory. This is a
-read-only mount.
+read-only mount unless "--allow-writes-to" is specified.
+
+Writable Snapshots
+==================
+
+With "--allow-writes-to dir", the files and directories within snapshots can be
+modified. The repository itself is never changed. Instead, all changes are
+stored in the given local staging directory, which is layered over the
+snapshots. Each snapshot uses a subdirectory of the staging directory named
+after the snapshot ID. Changes are therefore visible in all directories that
+contain the same snapshot and are kept when the repository is mounted again
+with the same staging directory.
+
+Ownership
+=========
+
+By default, files and directories are presented with the owner and
+permissions stored in the snapshot. When mounting snapshots created by another
+user, for example by root on another machine, the files are then usually not
+readable for the user running the mount. With "--map-ownership=current-user",
+all files and directories are presented as owned by the current user. The user
+may read all files and traverse all directories, while all permissions for the
+group and other users as well as the setuid, setgid and sticky bits are
+removed.
 
 Snapshot Directories
 ====================
@@ -52,6 +75,7 @@ For path templates, you can use the following patterns which will be replaced:
     %u by username
     %h by hostname
     %t by tags
+    %a by snapshot aliases, see "restic alias"
     %T by timestamp as specified by --time-template
 
 The default path templates are:
@@ -59,6 +83,7 @@ The default path templates are:
     "snapshots/%T"
     "hosts/%h/%T"
     "tags/%t/%T"
+    "aliases/%a"
 
 EXIT STATUS
 ===========
@@ -79,11 +104,13 @@ Exit status is 12 if the password is incorrect.
 // MountOptions collects all options for the mount command.
 type MountOptions struct {
 	OwnerRoot            bool
+	MapOwnership         string
 	AllowOther           bool
 	NoDefaultPermissions bool
 	restic.SnapshotFilter
 	TimeTemplate  string
 	PathTemplates []string
+	AllowWritesTo string
 }
 
 var mountOptions MountOptions
@@ -93,9 +120,12 @@ func init() {
 
 	mountFlags := cmdMount.Flags()
 	mountFlags.BoolVar(&mountOptions.OwnerRoot, "owner-root", false, "use 'root' as the owner of files and dirs")
+	mountFlags.StringVar(&mountOptions.MapOwnership, "map-ownership", "", "present files and dirs as owned by the current user with permissions to read them (`mode`: current-user)")
 	mountFlags.BoolVar(&mountOptions.AllowOther, "allow-other", false, "allow other users to access the data in the mounted directory")
 	mountFlags.BoolVar(&mountOptions.NoDefaultPermissions, "no-default-permissions", false, "for 'allow-other', ignore Unix permissions and allow users to read all snapshot files")
 
+	mountFlags.StringVar(&mountOptions.AllowWritesTo, "allow-writes-to", "", "allow modifying snapshots, storing the changes in the staging `directory`")
+
 	initMultiSnapshotFilter(mountFlags, &mountOptions.SnapshotFilter, true)
 
 	mountFlags.StringArrayVar(&mountOptions.PathTemplates, "path-template", nil, "set `template` for path names (can be specified multiple times)")
@@ -113,6 +143,15 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 		return errors.Fatal("time template string cannot start or end with '/'")
 	}
 
+	switch opts.MapOwnership {
+	case "", "current-user":
+	default:
+		return errors.Fatalf("invalid --map-ownership %q, only \"current-user\" is supported", opts.MapOwnership)
+	}
+	if opts.MapOwnership != "" && opts.OwnerRoot {
+		return errors.Fatal("--map-ownership and --owner-root cannot be combined")
+	}
+
 	if len(args) == 0 {
 		return errors.Fatal("wrong number of parameters")
 	}
@@ -126,6 +165,16 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 		return err
 	}
 
+	if opts.AllowWritesTo != "" {
+		fi, err := os.Stat(opts.AllowWritesTo)
+		if err != nil {
+			return errors.Fatalf("unable to access staging directory: %v", err)
+		}
+		if !fi.IsDir() {
+			return errors.Fatalf("staging directory %v is not a directory", opts.AllowWritesTo)
+		}
+	}
+
 	debug.Log("start mount")
 	defer debug.Log("finish mount")
 
@@ -142,10 +191,12 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 	}
 
 	mountOptions := []systemFuse.MountOption{
-		systemFuse.ReadOnly(),
 		systemFuse.FSName("restic"),
 		systemFuse.MaxReadahead(128 * 1024),
 	}
+	if opts.AllowWritesTo == "" {
+		mountOptions = append(mountOptions, systemFuse.ReadOnly())
+	}
 
 	if opts.AllowOther {
 		mountOptions = append(mountOptions, systemFuse.AllowOther())
@@ -166,14 +217,19 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 	}
 
 	cfg := fuse.Config{
-		OwnerIsRoot:   opts.OwnerRoot,
-		Filter:        opts.SnapshotFilter,
-		TimeTemplate:  opts.TimeTemplate,
-		PathTemplates: opts.PathTemplates,
+		OwnerIsRoot:        opts.OwnerRoot,
+		OwnerIsCurrentUser: opts.MapOwnership == "current-user",
+		Filter:             opts.SnapshotFilter,
+		TimeTemplate:       opts.TimeTemplate,
+		PathTemplates:      opts.PathTemplates,
+		StagingDir:         opts.AllowWritesTo,
 	}
 	root := fuse.NewRoot(repo, cfg)
 
 	Printf("Now serving the repository at %s\n", mountpoint)
+	if opts.AllowWritesTo != "" {
+		Printf("Changes to snapshots are stored in %s\n", opts.AllowWritesTo)
+	}
 	Printf("Use another terminal or tool to browse the contents of this folder.\n")
 	Printf("When finished, quit with Ctrl-c here or umount the mountpoint.\n")
 
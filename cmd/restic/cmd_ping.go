@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdPing = &cobra.Command{
+	Use:   "ping [flags]",
+	Short: "Check that the repository is reachable and writable",
+	Long: `
+The "ping" command checks whether the repository can be used for backups. It
+opens the repository using the password, measures the latency of the backend,
+estimates the clock skew between this host and the backend and verifies that a
+file can be written, read back and deleted.
+
+The clock skew can only be determined for backends which use HTTP, it is
+derived from the Date header of the responses. A clock skew of more than five
+minutes is reported as failure, as it interferes with the detection of stale
+locks.
+
+The file written by the write check is a short-lived non-exclusive lock file,
+such that it does not confuse other restic processes. The check does not test
+whether the repository is currently locked. Use --no-lock to skip the write,
+read and delete checks for read-only repositories.
+
+With --json, the result is printed as a single JSON object which is suitable
+for monitoring systems.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if all checks were successful.
+Exit status is 1 if at least one check failed.
+Exit status is 10 if the repository does not exist.
+Exit status is 12 if the password is incorrect.
+`,
+	GroupID:           cmdGroupDefault,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.Fatal("the ping command expects no arguments, only options - please see `restic help ping` for usage and flags")
+		}
+		return runPing(cmd.Context(), pingOptions, globalOptions)
+	},
+}
+
+// PingOptions collects all options for the ping command.
+type PingOptions struct {
+	Count uint
+}
+
+var pingOptions PingOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdPing)
+
+	f := cmdPing.Flags()
+	f.UintVar(&pingOptions.Count, "count", 3, "measure the latency using `n` requests")
+}
+
+// maxClockSkew is the maximum allowed difference between the clock of this
+// host and the backend.
+const maxClockSkew = 5 * time.Minute
+
+type pingCheck struct {
+	Name     string  `json:"name"`
+	Success  bool    `json:"success"`
+	Skipped  bool    `json:"skipped,omitempty"`
+	Duration float64 `json:"duration"` // in seconds
+	Message  string  `json:"message,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type pingResult struct {
+	MessageType string      `json:"message_type"` // "summary"
+	Success     bool        `json:"success"`
+	Checks      []pingCheck `json:"checks"`
+	LatencyMin  float64     `json:"latency_min,omitempty"` // in seconds
+	LatencyAvg  float64     `json:"latency_avg,omitempty"` // in seconds
+	LatencyMax  float64     `json:"latency_max,omitempty"` // in seconds
+	// ClockSkew is the difference between the time of the backend and the
+	// local time in seconds, it is omitted if it could not be determined
+	ClockSkew *float64 `json:"clock_skew,omitempty"`
+}
+
+// run runs a single check and records its result.
+func (r *pingResult) run(name string, fn func() (string, error)) error {
+	start := time.Now()
+	msg, err := fn()
+	check := pingCheck{
+		Name:     name,
+		Success:  err == nil,
+		Duration: time.Since(start).Seconds(),
+		Message:  msg,
+	}
+	if err != nil {
+		check.Error = err.Error()
+		r.Success = false
+	}
+	r.Checks = append(r.Checks, check)
+	return err
+}
+
+func (r *pingResult) skip(name string, msg string) {
+	r.Checks = append(r.Checks, pingCheck{Name: name, Success: true, Skipped: true, Message: msg})
+}
+
+// serverTimeRecorder records the Date header of HTTP responses to estimate the
+// clock skew between this host and the backend.
+type serverTimeRecorder struct {
+	rt http.RoundTripper
+
+	m     sync.Mutex
+	skew  time.Duration
+	valid bool
+}
+
+func (r *serverTimeRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := r.rt.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err == nil {
+		// the server time was determined at some point during the request
+		local := start.Add(time.Since(start) / 2)
+		r.m.Lock()
+		r.skew = date.Sub(local)
+		r.valid = true
+		r.m.Unlock()
+	}
+	return res, nil
+}
+
+func (r *serverTimeRecorder) clockSkew() (time.Duration, bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.skew, r.valid
+}
+
+func runPing(ctx context.Context, opts PingOptions, gopts GlobalOptions) error {
+	if opts.Count == 0 {
+		return errors.Fatal("--count must be at least 1")
+	}
+
+	recorder := &serverTimeRecorder{}
+	gopts.transportWrapper = func(rt http.RoundTripper) http.RoundTripper {
+		recorder.rt = rt
+		return recorder
+	}
+
+	result := &pingResult{MessageType: "summary", Success: true}
+	var repo *repository.Repository
+	err := result.run("open", func() (string, error) {
+		var err error
+		repo, err = OpenRepository(ctx, gopts)
+		if err != nil {
+			return "", err
+		}
+		return "repository " + repo.Config().ID, nil
+	})
+	if err != nil {
+		// the error is printed when restic exits
+		if gopts.JSON {
+			printPingResult(result, gopts)
+		}
+		return err
+	}
+
+	var firstErr error
+	record := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	record(result.run("latency", func() (string, error) {
+		return measureLatency(ctx, repo, opts.Count, result)
+	}))
+
+	if skew, ok := recorder.clockSkew(); ok {
+		seconds := skew.Seconds()
+		result.ClockSkew = &seconds
+		record(result.run("clock-skew", func() (string, error) {
+			if skew > maxClockSkew || skew < -maxClockSkew {
+				return "", errors.Errorf("clock of the backend differs by %v", skew.Round(time.Second))
+			}
+			return "backend clock differs by " + skew.Round(time.Second).String(), nil
+		}))
+	} else {
+		result.skip("clock-skew", "backend does not report its time")
+	}
+
+	if gopts.NoLock {
+		for _, name := range []string{"write", "read", "delete"} {
+			result.skip(name, "skipped due to --no-lock")
+		}
+	} else {
+		record(checkWriteReadDelete(ctx, repo, result))
+	}
+
+	printPingResult(result, gopts)
+	if firstErr != nil {
+		return errors.Fatalf("ping failed: %v", firstErr)
+	}
+	return nil
+}
+
+// measureLatency loads the config file count times and stores the latency in
+// the result.
+func measureLatency(ctx context.Context, repo *repository.Repository, count uint, result *pingResult) (string, error) {
+	var minLatency, maxLatency, total time.Duration
+	for i := uint(0); i < count; i++ {
+		start := time.Now()
+		_, err := repo.LoadUnpacked(ctx, restic.ConfigFile, restic.ID{})
+		if err != nil {
+			return "", err
+		}
+		d := time.Since(start)
+
+		total += d
+		if i == 0 || d < minLatency {
+			minLatency = d
+		}
+		if d > maxLatency {
+			maxLatency = d
+		}
+	}
+
+	avg := total / time.Duration(count)
+	result.LatencyMin = minLatency.Seconds()
+	result.LatencyAvg = avg.Seconds()
+	result.LatencyMax = maxLatency.Seconds()
+	return "min " + minLatency.Round(time.Microsecond).String() +
+		", avg " + avg.Round(time.Microsecond).String() +
+		", max " + maxLatency.Round(time.Microsecond).String(), nil
+}
+
+// checkWriteReadDelete writes a non-exclusive lock file, reads it back and
+// removes it again. A lock file is used such that the probe does not confuse
+// other restic processes.
+func checkWriteReadDelete(ctx context.Context, repo *repository.Repository, result *pingResult) error {
+	hostname, _ := os.Hostname()
+	probe, err := json.Marshal(&restic.Lock{
+		Time:     time.Now(),
+		Hostname: hostname,
+		PID:      os.Getpid(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var id restic.ID
+	err = result.run("write", func() (string, error) {
+		id, err = repo.SaveUnpacked(ctx, restic.LockFile, probe)
+		return "", err
+	})
+	if err != nil {
+		result.skip("read", "skipped as write failed")
+		result.skip("delete", "skipped as write failed")
+		return err
+	}
+
+	readErr := result.run("read", func() (string, error) {
+		buf, err := repo.LoadUnpacked(ctx, restic.LockFile, id)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(buf, probe) {
+			return "", errors.New("file read back differs from the file written")
+		}
+		return "", nil
+	})
+
+	err = result.run("delete", func() (string, error) {
+		return "", repo.RemoveUnpacked(ctx, restic.LockFile, id)
+	})
+	if readErr != nil {
+		return readErr
+	}
+	return err
+}
+
+func printPingResult(result *pingResult, gopts GlobalOptions) {
+	if gopts.JSON {
+		err := json.NewEncoder(globalOptions.stdout).Encode(result)
+		if err != nil {
+			Warnf("JSON encode failed: %v\n", err)
+		}
+		return
+	}
+
+	for _, check := range result.Checks {
+		status := "ok"
+		switch {
+		case check.Skipped:
+			status = "skipped"
+		case !check.Success:
+			status = "FAILED"
+		}
+
+		detail := check.Message
+		if check.Error != "" {
+			detail = check.Error
+		}
+		line := fmt.Sprintf("%-12s %-8s %v", check.Name, status, detail)
+		Printf("%s\n", strings.TrimRight(line, " "))
+	}
+}
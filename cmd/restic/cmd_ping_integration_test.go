@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunPing(t testing.TB, gopts GlobalOptions) pingResult {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runPing(context.TODO(), PingOptions{Count: 2}, gopts)
+	})
+	rtest.OK(t, err)
+
+	var result pingResult
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &result))
+	return result
+}
+
+func TestPing(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	result := testRunPing(t, env.gopts)
+	rtest.Assert(t, result.Success, "ping failed: %v", result.Checks)
+	names := []string{}
+	for _, check := range result.Checks {
+		names = append(names, check.Name)
+	}
+	rtest.Equals(t, []string{"open", "latency", "clock-skew", "write", "read", "delete"}, names)
+	rtest.Assert(t, result.ClockSkew == nil, "unexpected clock skew for local backend")
+	rtest.Equals(t, 0, len(testRunList(t, "locks", env.gopts)))
+
+	env.gopts.NoLock = true
+	result = testRunPing(t, env.gopts)
+	rtest.Assert(t, result.Success, "ping failed: %v", result.Checks)
+	rtest.Assert(t, result.Checks[3].Skipped, "write check was not skipped with --no-lock")
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestServerTimeRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	}))
+	defer srv.Close()
+
+	recorder := &serverTimeRecorder{rt: http.DefaultTransport}
+	_, ok := recorder.clockSkew()
+	rtest.Assert(t, !ok, "clock skew available before the first request")
+
+	res, err := (&http.Client{Transport: recorder}).Get(srv.URL)
+	rtest.OK(t, err)
+	rtest.OK(t, res.Body.Close())
+
+	skew, ok := recorder.clockSkew()
+	rtest.Assert(t, ok, "clock skew was not recorded")
+	rtest.Assert(t, skew < -59*time.Minute && skew > -61*time.Minute, "unexpected clock skew %v", skew)
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"math"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/restic/restic/internal/backend/cost"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
@@ -25,6 +30,20 @@ var cmdPrune = &cobra.Command{
 The "prune" command checks the repository and removes data that is not
 referenced and therefore not needed any more.
 
+Repacking large repositories can take a long time. The option "--max-duration"
+stops repacking after the given duration, a later prune run continues where the
+previous one stopped.
+
+The option "--analyze-only" reports the unused data of each pack file and what
+prune would do for several values of "--max-unused", including the estimated
+backend requests and transferred data. The repository is not modified. Use
+"--json" to get the report for each pack file.
+
+The option "--dedup-only" only removes additional copies of data which was
+stored more than once, for example by concurrent backups from several hosts.
+It does not load any snapshots and keeps all other data, which makes it cheap
+enough to run regularly between full prune runs.
+
 EXIT STATUS
 ===========
 
@@ -33,6 +52,8 @@ Exit status is 1 if there was any error.
 Exit status is 10 if the repository does not exist.
 Exit status is 11 if the repository is already locked.
 Exit status is 12 if the password is incorrect.
+Exit status is 13 if the server refused to remove data, for example because the
+repository is append-only.
 `,
 	GroupID:           cmdGroupDefault,
 	DisableAutoGenTag: true,
@@ -46,6 +67,8 @@ Exit status is 12 if the password is incorrect.
 // PruneOptions collects all options for the cleanup command.
 type PruneOptions struct {
 	DryRun                bool
+	AnalyzeOnly           bool
+	DedupOnly             bool
 	UnsafeNoSpaceRecovery string
 
 	unsafeRecovery bool
@@ -59,6 +82,10 @@ type PruneOptions struct {
 	RepackCacheableOnly bool
 	RepackSmall         bool
 	RepackUncompressed  bool
+
+	MaxDuration time.Duration
+
+	OverrideHold string
 }
 
 var pruneOptions PruneOptions
@@ -67,7 +94,10 @@ func init() {
 	cmdRoot.AddCommand(cmdPrune)
 	f := cmdPrune.Flags()
 	f.BoolVarP(&pruneOptions.DryRun, "dry-run", "n", false, "do not modify the repository, just print what would be done")
+	f.BoolVar(&pruneOptions.AnalyzeOnly, "analyze-only", false, "do not modify the repository, report the unused data per pack and the effect of several --max-unused values")
+	f.BoolVar(&pruneOptions.DedupOnly, "dedup-only", false, "only remove duplicate copies of data, keep everything else")
 	f.StringVarP(&pruneOptions.UnsafeNoSpaceRecovery, "unsafe-recover-no-free-space", "", "", "UNSAFE, READ THE DOCUMENTATION BEFORE USING! Try to recover a repository stuck with no free space. Do not use without trying out 'prune --max-repack-size 0' first.")
+	f.StringVar(&pruneOptions.OverrideHold, "override-hold", "", "run despite an active legal hold, the `justification` is recorded in the repository")
 	addPruneOptions(cmdPrune, &pruneOptions)
 }
 
@@ -78,6 +108,7 @@ func addPruneOptions(c *cobra.Command, pruneOptions *PruneOptions) {
 	f.BoolVar(&pruneOptions.RepackCacheableOnly, "repack-cacheable-only", false, "only repack packs which are cacheable")
 	f.BoolVar(&pruneOptions.RepackSmall, "repack-small", false, "repack pack files below 80% of target pack size")
 	f.BoolVar(&pruneOptions.RepackUncompressed, "repack-uncompressed", false, "repack all uncompressed data")
+	f.DurationVar(&pruneOptions.MaxDuration, "max-duration", 0, "stop repacking after the given `duration`, like 30m or 2h, a later prune run continues the work (default: no limit)")
 }
 
 func verifyPruneOptions(opts *PruneOptions) error {
@@ -93,50 +124,63 @@ func verifyPruneOptions(opts *PruneOptions) error {
 		// prevent repacking data to make sure users cannot get stuck.
 		opts.MaxRepackBytes = 0
 	}
+	if opts.MaxDuration < 0 {
+		return errors.Fatal("--max-duration must not be negative")
+	}
+
+	maxUnusedBytes, err := parseMaxUnused(opts.MaxUnused)
+	if err != nil {
+		return err
+	}
+	opts.maxUnusedBytes = maxUnusedBytes
+
+	return nil
+}
 
-	maxUnused := strings.TrimSpace(opts.MaxUnused)
+// parseMaxUnused parses the value of --max-unused. It returns a function
+// which calculates the number of unused bytes tolerated after repacking.
+func parseMaxUnused(value string) (func(used uint64) (unused uint64), error) {
+	maxUnused := strings.TrimSpace(value)
 	if maxUnused == "" {
-		return errors.Fatalf("invalid value for --max-unused: %q", opts.MaxUnused)
+		return nil, errors.Fatalf("invalid value for --max-unused: %q", value)
 	}
 
 	// parse MaxUnused either as unlimited, a percentage, or an absolute number of bytes
 	switch {
 	case maxUnused == "unlimited":
-		opts.maxUnusedBytes = func(_ uint64) uint64 {
+		return func(_ uint64) uint64 {
 			return math.MaxUint64
-		}
+		}, nil
 
 	case strings.HasSuffix(maxUnused, "%"):
 		maxUnused = strings.TrimSuffix(maxUnused, "%")
 		p, err := strconv.ParseFloat(maxUnused, 64)
 		if err != nil {
-			return errors.Fatalf("invalid percentage %q passed for --max-unused: %v", opts.MaxUnused, err)
+			return nil, errors.Fatalf("invalid percentage %q passed for --max-unused: %v", value, err)
 		}
 
 		if p < 0 {
-			return errors.Fatal("percentage for --max-unused must be positive")
+			return nil, errors.Fatal("percentage for --max-unused must be positive")
 		}
 
 		if p >= 100 {
-			return errors.Fatal("percentage for --max-unused must be below 100%")
+			return nil, errors.Fatal("percentage for --max-unused must be below 100%")
 		}
 
-		opts.maxUnusedBytes = func(used uint64) uint64 {
+		return func(used uint64) uint64 {
 			return uint64(p / (100 - p) * float64(used))
-		}
+		}, nil
 
 	default:
 		size, err := ui.ParseBytes(maxUnused)
 		if err != nil {
-			return errors.Fatalf("invalid number of bytes %q for --max-unused: %v", opts.MaxUnused, err)
+			return nil, errors.Fatalf("invalid number of bytes %q for --max-unused: %v", value, err)
 		}
 
-		opts.maxUnusedBytes = func(_ uint64) uint64 {
+		return func(_ uint64) uint64 {
 			return uint64(size)
-		}
+		}, nil
 	}
-
-	return nil
 }
 
 func runPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, term *termstatus.Terminal) error {
@@ -149,6 +193,25 @@ func runPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, term
 		return errors.Fatal("disabled compression and `--repack-uncompressed` are mutually exclusive")
 	}
 
+	if opts.AnalyzeOnly {
+		if opts.UnsafeNoSpaceRecovery != "" {
+			return errors.Fatal("--analyze-only and --unsafe-recover-no-free-space are mutually exclusive")
+		}
+		// the analysis never modifies the repository
+		opts.DryRun = true
+	}
+
+	if opts.DedupOnly {
+		switch {
+		case opts.AnalyzeOnly:
+			return errors.Fatal("--dedup-only and --analyze-only are mutually exclusive")
+		case opts.UnsafeNoSpaceRecovery != "":
+			return errors.Fatal("--dedup-only and --unsafe-recover-no-free-space are mutually exclusive")
+		case opts.RepackSmall || opts.RepackUncompressed:
+			return errors.Fatal("--dedup-only cannot be combined with --repack-small or --repack-uncompressed")
+		}
+	}
+
 	if gopts.NoLock && !opts.DryRun {
 		return errors.Fatal("--no-lock is only applicable in combination with --dry-run for prune command")
 	}
@@ -159,6 +222,15 @@ func runPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, term
 	}
 	defer unlock()
 
+	if !opts.DryRun {
+		if err := checkHold(ctx, repo, "prune", opts.OverrideHold); err != nil {
+			return err
+		}
+		if !repo.CanDelete() {
+			return repository.ErrAppendOnly
+		}
+	}
+
 	if opts.UnsafeNoSpaceRecovery != "" {
 		repoID := repo.Config().ID
 		if opts.UnsafeNoSpaceRecovery != repoID {
@@ -171,16 +243,33 @@ func runPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, term
 }
 
 func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo *repository.Repository, ignoreSnapshots restic.IDSet, term *termstatus.Terminal) error {
-	if repo.Cache == nil {
-		Print("warning: running prune without a cache, this may be very slow!\n")
+	prices, err := parsePriceTable(gopts)
+	if err != nil {
+		return err
+	}
+
+	var deadline time.Time
+	if opts.MaxDuration > 0 {
+		deadline = time.Now().Add(opts.MaxDuration)
 	}
 
-	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	// the JSON report of --analyze-only must not be mixed with messages
+	jsonReport := opts.AnalyzeOnly && gopts.JSON
+
+	verbosity := gopts.verbosity
+	if jsonReport {
+		verbosity = 0
+	}
+	printer := newProgressPrinter(gopts.JSON && !jsonReport, verbosity, term)
+
+	if repo.Cache == nil && !jsonReport {
+		printer.P("warning: running prune without a cache, this may be very slow!\n")
+	}
 
 	printer.P("loading indexes...\n")
 	// loading the index before the snapshots is ok, as we use an exclusive lock here
-	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
-	err := repo.LoadIndex(ctx, bar)
+	bar := newIndexPrinterProgress(gopts, printer, term)
+	err = repo.LoadIndex(ctx, bar)
 	if err != nil {
 		return err
 	}
@@ -195,10 +284,20 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOption
 		RepackCacheableOnly: opts.RepackCacheableOnly,
 		RepackSmall:         opts.RepackSmall,
 		RepackUncompressed:  opts.RepackUncompressed,
+		DedupOnly:           opts.DedupOnly,
+
+		Deadline: deadline,
+	}
+
+	if opts.AnalyzeOnly {
+		return runPruneAnalysis(ctx, opts, gopts, popts, repo, ignoreSnapshots, prices, printer)
 	}
 
+	// the snapshots which still exist, only known if they were loaded
+	var snapshots restic.IDSet
 	plan, err := repository.PlanPrune(ctx, popts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
-		return getUsedBlobs(ctx, repo, usedBlobs, ignoreSnapshots, printer)
+		snapshots = restic.NewIDSet()
+		return getUsedBlobs(ctx, repo, usedBlobs, ignoreSnapshots, snapshots, printer)
 	}, printer)
 	if err != nil {
 		return err
@@ -207,6 +306,13 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOption
 		return ctx.Err()
 	}
 
+	if snapshots != nil && !opts.DryRun {
+		err = removeAliases(ctx, repo, func(id restic.ID) bool { return !snapshots.Has(id) }, printer)
+		if err != nil {
+			return err
+		}
+	}
+
 	if popts.DryRun {
 		printer.P("\nWould have made the following changes:")
 	}
@@ -215,6 +321,7 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOption
 	if err != nil {
 		return err
 	}
+	printCostEstimate(printer, plan.EstimateOperations(), prices)
 
 	// Trigger GC to reset garbage collection threshold
 	runtime.GC()
@@ -256,6 +363,9 @@ func printPruneStats(printer progress.Printer, stats repository.PruneStats) erro
 	printer.V("unused packs:       %10d\n\n", stats.Packs.Unused)
 
 	printer.V("to keep:      %10d packs\n", stats.Packs.Keep)
+	if stats.Packs.Retained > 0 {
+		printer.V("retained:     %10d packs protected by a retention period\n", stats.Packs.Retained)
+	}
 	printer.V("to repack:    %10d packs\n", stats.Packs.Repack)
 	printer.V("to delete:    %10d packs\n", stats.Packs.Remove)
 	if stats.Packs.Unref > 0 {
@@ -264,7 +374,173 @@ func printPruneStats(printer progress.Printer, stats repository.PruneStats) erro
 	return nil
 }
 
-func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet, ignoreSnapshots restic.IDSet, printer progress.Printer) error {
+// pruneAnalysisMaxUnused are the values of --max-unused evaluated by
+// --analyze-only in addition to the value set by the user.
+var pruneAnalysisMaxUnused = []string{"0%", "5%", "10%", "20%", "unlimited"}
+
+// pruneAnalysisPack is the JSON report for a pack file.
+type pruneAnalysisPack struct {
+	ID             restic.ID `json:"id"`
+	Type           string    `json:"type"`
+	Size           uint64    `json:"size"`
+	Indexed        bool      `json:"indexed"`
+	UsedBlobs      uint      `json:"used_blobs"`
+	UnusedBlobs    uint      `json:"unused_blobs"`
+	DuplicateBlobs uint      `json:"duplicate_blobs"`
+	UsedSize       uint64    `json:"used_size"`
+	UnusedSize     uint64    `json:"unused_size"`
+	Uncompressed   bool      `json:"uncompressed"`
+	// Actions maps each evaluated value of --max-unused to the action
+	Actions map[string]repository.PackAction `json:"actions"`
+}
+
+// pruneAnalysisScenario is the JSON report for a value of --max-unused.
+type pruneAnalysisScenario struct {
+	MaxUnused       string           `json:"max_unused"`
+	KeepPacks       uint             `json:"keep_packs"`
+	RepackPacks     uint             `json:"repack_packs"`
+	RemovePacks     uint             `json:"remove_packs"`
+	RepackSize      uint64           `json:"repack_size"`
+	FreedSize       uint64           `json:"freed_size"`
+	UnusedSizeAfter uint64           `json:"unused_size_after"`
+	Estimate        costEstimateJSON `json:"estimate"`
+}
+
+type pruneAnalysis struct {
+	TotalSize        uint64                  `json:"total_size"`
+	UsedSize         uint64                  `json:"used_size"`
+	UnusedSize       uint64                  `json:"unused_size"`
+	UnreferencedSize uint64                  `json:"unreferenced_size"`
+	Scenarios        []pruneAnalysisScenario `json:"scenarios"`
+	Packs            []pruneAnalysisPack     `json:"packs"`
+}
+
+// runPruneAnalysis reports the unused data of each pack file and the effect of
+// several values of --max-unused without modifying the repository.
+func runPruneAnalysis(ctx context.Context, opts PruneOptions, gopts GlobalOptions, popts repository.PruneOptions, repo *repository.Repository, ignoreSnapshots restic.IDSet, prices *cost.PriceTable, printer progress.Printer) error {
+	settings := pruneAnalysisMaxUnused
+	if !slices.Contains(settings, strings.TrimSpace(opts.MaxUnused)) {
+		settings = append(slices.Clone(settings), strings.TrimSpace(opts.MaxUnused))
+	}
+	var maxUnused []func(uint64) uint64
+	for _, setting := range settings {
+		fn, err := parseMaxUnused(setting)
+		if err != nil {
+			return err
+		}
+		maxUnused = append(maxUnused, fn)
+	}
+
+	packs, scenarios, err := repository.AnalyzePrune(ctx, popts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		return getUsedBlobs(ctx, repo, usedBlobs, ignoreSnapshots, nil, printer)
+	}, maxUnused, printer)
+	if err != nil {
+		return err
+	}
+
+	var report pruneAnalysis
+	for _, p := range packs {
+		report.TotalSize += p.Size
+		if !p.Indexed {
+			report.UnreferencedSize += p.Size
+		}
+	}
+	if len(scenarios) > 0 {
+		stats := scenarios[0].Stats
+		report.UsedSize = stats.Size.Used
+		report.UnusedSize = stats.Size.Duplicate + stats.Size.Unused
+	}
+
+	for i, sc := range scenarios {
+		stats := sc.Stats
+		freed := stats.Size.Remove + stats.Size.Repackrm + stats.Size.Unref
+		report.Scenarios = append(report.Scenarios, pruneAnalysisScenario{
+			MaxUnused:       settings[i],
+			KeepPacks:       stats.Packs.Keep,
+			RepackPacks:     stats.Packs.Repack,
+			RemovePacks:     stats.Packs.Remove + stats.Packs.Unref,
+			RepackSize:      stats.Size.Repack,
+			FreedSize:       freed,
+			UnusedSizeAfter: stats.Size.Duplicate + stats.Size.Unused - stats.Size.Remove - stats.Size.Repackrm,
+			Estimate:        newCostEstimateJSON(sc.Operations, prices),
+		})
+	}
+
+	for _, p := range packs {
+		pack := pruneAnalysisPack{
+			ID:             p.ID,
+			Size:           p.Size,
+			Indexed:        p.Indexed,
+			UsedBlobs:      p.UsedBlobs,
+			UnusedBlobs:    p.UnusedBlobs,
+			DuplicateBlobs: p.DuplicateBlobs,
+			UsedSize:       p.UsedSize,
+			UnusedSize:     p.UnusedSize,
+			Uncompressed:   p.Uncompressed,
+			Actions:        make(map[string]repository.PackAction, len(scenarios)),
+		}
+		switch {
+		case !p.Indexed:
+			pack.Type = "unreferenced"
+			pack.UnusedSize = p.Size
+		case p.Type == restic.InvalidBlob:
+			pack.Type = "mixed"
+		default:
+			pack.Type = p.Type.String()
+		}
+		for i, sc := range scenarios {
+			pack.Actions[settings[i]] = sc.Actions[p.ID]
+		}
+		report.Packs = append(report.Packs, pack)
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(report)
+	}
+
+	printer.P("\ntotal size:   %s in %d packs\n", ui.FormatBytes(report.TotalSize), len(report.Packs))
+	printer.P("used:         %s\n", ui.FormatBytes(report.UsedSize))
+	printer.P("unused:       %s (%s of total size)\n", ui.FormatBytes(report.UnusedSize), ui.FormatPercent(report.UnusedSize, report.TotalSize))
+	if report.UnreferencedSize > 0 {
+		printer.P("unreferenced: %s\n", ui.FormatBytes(report.UnreferencedSize))
+	}
+
+	for i, sc := range report.Scenarios {
+		printer.P("\n--max-unused %s: repack %d packs / %s, delete %d packs, frees %s, unused after prune %s\n",
+			sc.MaxUnused, sc.RepackPacks, ui.FormatBytes(sc.RepackSize), sc.RemovePacks,
+			ui.FormatBytes(sc.FreedSize), ui.FormatBytes(sc.UnusedSizeAfter))
+		printer.P("%s", formatCostEstimate(scenarios[i].Operations, prices, "  "))
+	}
+
+	printer.V("\n")
+	for _, p := range report.Packs {
+		var actions []string
+		for _, setting := range settings {
+			actions = append(actions, fmt.Sprintf("%s=%s", setting, p.Actions[setting]))
+		}
+		printer.V("pack %s %-12s size %10s unused %10s  %s\n", p.ID.Str(), p.Type,
+			ui.FormatBytes(p.Size), ui.FormatBytes(p.UnusedSize), strings.Join(actions, " "))
+	}
+	return nil
+}
+
+// removeAliases removes the aliases of the snapshots for which remove returns
+// true.
+func removeAliases(ctx context.Context, repo *repository.Repository, remove func(id restic.ID) bool, printer progress.Printer) error {
+	removed, err := restic.RemoveSnapshotAliases(ctx, repo, remove)
+	if err != nil {
+		return err
+	}
+	for _, name := range removed {
+		printer.P("removed alias %v of a removed snapshot\n", name)
+	}
+	return nil
+}
+
+// getUsedBlobs adds the blobs referenced by all snapshots except
+// ignoreSnapshots to usedBlobs. If snapshots is not nil, the IDs of these
+// snapshots are added to it.
+func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet, ignoreSnapshots restic.IDSet, snapshots restic.IDSet, printer progress.Printer) error {
 	var snapshotTrees restic.IDs
 	printer.P("loading all snapshots...\n")
 	err := restic.ForAllSnapshots(ctx, repo, repo, ignoreSnapshots,
@@ -275,6 +551,9 @@ func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.
 			}
 			debug.Log("add snapshot %v (tree %v)", id, *sn.Tree)
 			snapshotTrees = append(snapshotTrees, *sn.Tree)
+			if snapshots != nil {
+				snapshots.Insert(id)
+			}
 			return nil
 		})
 	if err != nil {
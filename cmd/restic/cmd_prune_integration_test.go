@@ -116,6 +116,42 @@ func testPrune(t *testing.T, pruneOpts PruneOptions, checkOpts CheckOptions) {
 	}))
 }
 
+func TestPruneAnalyzeOnly(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+	packsBefore := testRunList(t, "packs", env.gopts)
+
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		gopts.backendTestHook = func(r backend.Backend) (backend.Backend, error) { return newListOnceBackend(r), nil }
+		opts := PruneOptions{MaxUnused: "15%", AnalyzeOnly: true}
+		return withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+			return runPrune(context.TODO(), opts, gopts, term)
+		})
+	})
+	rtest.OK(t, err)
+
+	var report pruneAnalysis
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &report))
+	rtest.Equals(t, len(packsBefore), len(report.Packs))
+	rtest.Assert(t, report.UnusedSize > 0, "no unused data reported")
+
+	var settings []string
+	for _, sc := range report.Scenarios {
+		settings = append(settings, sc.MaxUnused)
+	}
+	rtest.Equals(t, append(pruneAnalysisMaxUnused, "15%"), settings)
+	for _, p := range report.Packs {
+		rtest.Equals(t, len(settings), len(p.Actions))
+	}
+
+	// the repository is not modified
+	rtest.Equals(t, packsBefore, testRunList(t, "packs", env.gopts))
+}
+
 var pruneDefaultOptions = PruneOptions{MaxUnused: "5%"}
 
 func TestPruneWithDamagedRepository(t *testing.T) {
@@ -33,15 +33,25 @@ Exit status is 12 if the password is incorrect.
 	RunE: func(cmd *cobra.Command, args []string) error {
 		term, cancel := setupTermstatus()
 		defer cancel()
-		return runRepairPacks(cmd.Context(), globalOptions, term, args)
+		return runRepairPacks(cmd.Context(), repairPacksOptions, globalOptions, term, args)
 	},
 }
 
+// RepairPacksOptions collects all options for the repair packs command.
+type RepairPacksOptions struct {
+	OverrideHold string
+}
+
+var repairPacksOptions RepairPacksOptions
+
 func init() {
 	cmdRepair.AddCommand(cmdRepairPacks)
+
+	flags := cmdRepairPacks.Flags()
+	flags.StringVar(&repairPacksOptions.OverrideHold, "override-hold", "", "run despite an active legal hold, the `justification` is recorded in the repository")
 }
 
-func runRepairPacks(ctx context.Context, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+func runRepairPacks(ctx context.Context, opts RepairPacksOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
 	ids := restic.NewIDSet()
 	for _, arg := range args {
 		id, err := restic.ParseID(arg)
@@ -60,6 +70,13 @@ func runRepairPacks(ctx context.Context, gopts GlobalOptions, term *termstatus.T
 	}
 	defer unlock()
 
+	if err := checkHold(ctx, repo, "repair packs", opts.OverrideHold); err != nil {
+		return err
+	}
+	if !repo.CanDelete() {
+		return repository.ErrAppendOnly
+	}
+
 	printer := newTerminalProgressPrinter(gopts.verbosity, term)
 
 	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/walker"
 
@@ -51,8 +52,9 @@ Exit status is 12 if the password is incorrect.
 
 // RepairOptions collects all options for the repair command.
 type RepairOptions struct {
-	DryRun bool
-	Forget bool
+	DryRun       bool
+	Forget       bool
+	OverrideHold string
 
 	restic.SnapshotFilter
 }
@@ -65,6 +67,7 @@ func init() {
 
 	flags.BoolVarP(&repairSnapshotOptions.DryRun, "dry-run", "n", false, "do not do anything, just print what would be done")
 	flags.BoolVarP(&repairSnapshotOptions.Forget, "forget", "", false, "remove original snapshots after creating new ones")
+	flags.StringVar(&repairSnapshotOptions.OverrideHold, "override-hold", "", "run --forget despite an active legal hold, the `justification` is recorded in the repository")
 
 	initMultiSnapshotFilter(flags, &repairSnapshotOptions.SnapshotFilter, true)
 }
@@ -76,6 +79,15 @@ func runRepairSnapshots(ctx context.Context, gopts GlobalOptions, opts RepairOpt
 	}
 	defer unlock()
 
+	if opts.Forget && !opts.DryRun {
+		if err := checkHold(ctx, repo, "repair snapshots", opts.OverrideHold); err != nil {
+			return err
+		}
+		if !repo.CanDelete() {
+			return repository.ErrAppendOnly
+		}
+	}
+
 	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
 	if err != nil {
 		return err
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdReplicate = &cobra.Command{
+	Use:   "replicate [flags]",
+	Short: "Mirror a repository into another repository",
+	Long: `
+The "replicate" command keeps a one-way mirror of a source repository in the
+repository specified via --repo. All blobs referenced by snapshots which have
+not been replicated before and which are not yet present in the destination are
+transferred and re-encrypted using the keys of the destination repository.
+Afterwards, these snapshots are added to the destination.
+
+Unlike the "copy" command, "replicate" only walks the snapshots which have not
+been replicated yet, such that only new data has to be transferred. The
+destination index is updated after each batch of packs and the replication
+state after each snapshot, thus an interrupted run can be resumed by running
+the command again.
+
+With --delete, snapshots which were replicated earlier but have since been
+removed from the source repository are also removed from the destination. This
+requires an exclusive lock on the destination repository. The data of these
+snapshots is removed by running "prune" on the destination.
+
+NOTE: Files are not re-chunked. For deduplication between replicated data and
+new backups created in the destination repository, initialize the destination
+using "init --copy-chunker-params".
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+	GroupID:           cmdGroupDefault,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReplicate(cmd.Context(), replicateOptions, globalOptions, args)
+	},
+}
+
+// ReplicateOptions bundles all options for the replicate command.
+type ReplicateOptions struct {
+	secondaryRepoOptions
+	Delete       bool
+	DryRun       bool
+	OverrideHold string
+}
+
+var replicateOptions ReplicateOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdReplicate)
+
+	f := cmdReplicate.Flags()
+	initSecondaryRepoOptions(f, &replicateOptions.secondaryRepoOptions, "destination", "to replicate from")
+	f.BoolVar(&replicateOptions.Delete, "delete", false, "remove replicated snapshots which no longer exist in the source repository")
+	f.BoolVarP(&replicateOptions.DryRun, "dry-run", "n", false, "do not modify the destination repository, just print what would be done")
+	f.StringVar(&replicateOptions.OverrideHold, "override-hold", "", "run --delete despite an active legal hold, the `justification` is recorded in the repository")
+}
+
+// replicateBatchPacks is the number of source packs which are transferred
+// before the destination index is written.
+const replicateBatchPacks = 200
+
+func runReplicate(ctx context.Context, opts ReplicateOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the replicate command expects no arguments")
+	}
+
+	secondaryGopts, isFromRepo, err := fillSecondaryGlobalOpts(ctx, opts.secondaryRepoOptions, gopts, "destination")
+	if err != nil {
+		return err
+	}
+	if isFromRepo {
+		// swap global options, if the secondary repo was set via from-repo
+		gopts, secondaryGopts = secondaryGopts, gopts
+	}
+
+	ctx, srcRepo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	openDst := openWithAppendLock
+	if opts.Delete {
+		// removing snapshots must not race with other commands
+		openDst = openWithExclusiveLock
+	}
+	ctx, dstRepo, unlock, err := openDst(ctx, secondaryGopts, opts.DryRun)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if srcRepo.Config().ID == dstRepo.Config().ID {
+		return errors.Fatal("source and destination are the same repository")
+	}
+	if dstRepo.Config().Version < restic.FeaturesRepoVersion {
+		return errors.Fatalf("replicate stores its progress in the destination repository, which requires repository version %v", restic.FeaturesRepoVersion)
+	}
+
+	state, err := restic.LoadReplicationState(ctx, dstRepo)
+	if err != nil {
+		return err
+	}
+	if state.Source != "" && state.Source != srcRepo.Config().ID {
+		return errors.Fatalf("destination repository is a replica of repository %v, not of %v", state.Source, srcRepo.Config().ID)
+	}
+
+	// maps source snapshot IDs to the IDs of their replicas
+	replicas := make(map[restic.ID]restic.ID, len(state.Snapshots))
+	for _, sn := range state.Snapshots {
+		replicas[sn.Source] = sn.Replica
+	}
+
+	if opts.Delete && !opts.DryRun {
+		if err := checkHold(ctx, dstRepo, "replicate", opts.OverrideHold); err != nil {
+			return err
+		}
+	}
+
+	srcSnapshots := restic.NewIDSet()
+	err = srcRepo.List(ctx, restic.SnapshotFile, func(id restic.ID, _ int64) error {
+		srcSnapshots.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	dstSnapshots := restic.NewIDSet()
+	err = dstRepo.List(ctx, restic.SnapshotFile, func(id restic.ID, _ int64) error {
+		dstSnapshots.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	debug.Log("Loading source index")
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err := srcRepo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+	bar = newIndexProgress(gopts.Quiet, gopts.JSON)
+	debug.Log("Loading destination index")
+	if err := dstRepo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	// load all snapshots which still have to be replicated
+	var pending restic.Snapshots
+	for _, id := range srcSnapshots.List() {
+		if replica, ok := replicas[id]; ok && dstSnapshots.Has(replica) {
+			continue
+		}
+		sn, err := restic.LoadSnapshot(ctx, srcRepo, id)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, sn)
+	}
+
+	copyBlobs, packs, err := findMissingBlobs(ctx, srcRepo, dstRepo, pending, gopts.Quiet)
+	if err != nil {
+		return err
+	}
+	Verbosef("%d blobs in %d packs are missing in the destination repository\n", copyBlobs.Len(), len(packs))
+	if !opts.DryRun && len(packs) > 0 {
+		if err := replicatePacks(ctx, srcRepo, dstRepo, packs, copyBlobs, gopts.Quiet); err != nil {
+			return err
+		}
+	}
+
+	// saveState records the progress, such that an interrupted run neither
+	// duplicates nor forgets snapshots
+	saveState := func() error {
+		state := &restic.ReplicationState{Source: srcRepo.Config().ID}
+		for id, replica := range replicas {
+			state.Snapshots = append(state.Snapshots, restic.ReplicatedSnapshot{Source: id, Replica: replica})
+		}
+		return restic.SaveReplicationState(ctx, dstRepo, state)
+	}
+
+	var added, removed int
+	for _, sn := range pending {
+		id := *sn.ID()
+		added++
+		if opts.DryRun {
+			Verbosef("would replicate snapshot %s\n", id.Str())
+			continue
+		}
+
+		// the parent is only meaningful if it was replicated as well
+		if sn.Parent != nil {
+			if replica, ok := replicas[*sn.Parent]; ok {
+				sn.Parent = &replica
+			} else {
+				sn.Parent = nil
+			}
+		}
+		// Use Original as a persistent snapshot ID
+		if sn.Original == nil {
+			sn.Original = sn.ID()
+		}
+		newID, err := restic.SaveSnapshot(ctx, dstRepo, sn)
+		if err != nil {
+			return err
+		}
+		replicas[id] = newID
+		if err := saveState(); err != nil {
+			return err
+		}
+		Verbosef("snapshot %s replicated as %s\n", id.Str(), newID.Str())
+	}
+
+	for id, replica := range replicas {
+		if srcSnapshots.Has(id) {
+			continue
+		}
+		if !dstSnapshots.Has(replica) {
+			// the snapshot was removed from both repositories
+			delete(replicas, id)
+			continue
+		}
+		if !opts.Delete {
+			continue
+		}
+
+		removed++
+		if opts.DryRun {
+			Verbosef("would remove snapshot %s\n", replica.Str())
+			continue
+		}
+		if err := dstRepo.RemoveUnpacked(ctx, restic.SnapshotFile, replica); err != nil {
+			return err
+		}
+		delete(replicas, id)
+		if err := saveState(); err != nil {
+			return err
+		}
+		Verbosef("removed snapshot %s\n", replica.Str())
+	}
+
+	if !opts.DryRun {
+		// also records the source repository and drops entries of snapshots
+		// which vanished from both repositories
+		if err := saveState(); err != nil {
+			return err
+		}
+	}
+
+	if opts.DryRun {
+		Printf("would replicate %d new snapshots, remove %d snapshots\n", added, removed)
+	} else {
+		Printf("replicated %d new snapshots, removed %d snapshots\n", added, removed)
+	}
+	return ctx.Err()
+}
+
+// findMissingBlobs returns all blobs referenced by the given source snapshots
+// which do not exist in the destination repository, along with the source packs
+// which contain them. Blobs which are not referenced by any of the snapshots are
+// not transferred.
+func findMissingBlobs(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository,
+	snapshots restic.Snapshots, quiet bool) (restic.BlobSet, restic.IDSet, error) {
+
+	var trees restic.IDs
+	for _, sn := range snapshots {
+		trees = append(trees, *sn.Tree)
+	}
+
+	usedBlobs := restic.NewBlobSet()
+	bar := newProgressMax(!quiet, uint64(len(trees)), "snapshots")
+	err := restic.FindUsedBlobs(ctx, srcRepo, trees, usedBlobs, bar)
+	bar.Done()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	copyBlobs := restic.NewBlobSet()
+	packs := restic.NewIDSet()
+	for h := range usedBlobs {
+		if _, ok := dstRepo.LookupBlobSize(h.Type, h.ID); ok {
+			continue
+		}
+		pbs := srcRepo.LookupBlob(h.Type, h.ID)
+		if len(pbs) == 0 {
+			return nil, nil, errors.Fatalf("blob %v is missing in the source repository", h)
+		}
+		copyBlobs.Insert(h)
+		// blob may be stored in multiple packs, only transfer it once
+		packs.Insert(pbs[0].PackID)
+	}
+	return copyBlobs, packs, nil
+}
+
+// replicatePacks transfers the given blobs from the source packs. The packs
+// are processed in batches, each of which ends with writing the destination
+// index. Thus, an interrupted replication only loses the current batch.
+func replicatePacks(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository,
+	packs restic.IDSet, copyBlobs restic.BlobSet, quiet bool) error {
+
+	packList := packs.List()
+	sort.Sort(packList)
+
+	bar := newProgressMax(!quiet, uint64(len(packList)), "packs replicated")
+	defer bar.Done()
+
+	for len(packList) > 0 {
+		n := min(len(packList), replicateBatchPacks)
+		batch := restic.NewIDSet(packList[:n]...)
+		packList = packList[n:]
+
+		_, err := repository.Repack(ctx, srcRepo, dstRepo, batch, copyBlobs, bar)
+		if err != nil {
+			return errors.Fatal(err.Error())
+		}
+	}
+	return nil
+}
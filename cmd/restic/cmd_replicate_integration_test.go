@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunReplicate(t testing.TB, srcGopts GlobalOptions, dstGopts GlobalOptions, opts ReplicateOptions) {
+	gopts := srcGopts
+	gopts.Repo = dstGopts.Repo
+	gopts.password = dstGopts.password
+	gopts.InsecureNoPassword = dstGopts.InsecureNoPassword
+	opts.secondaryRepoOptions = secondaryRepoOptions{
+		Repo:               srcGopts.Repo,
+		password:           srcGopts.password,
+		InsecureNoPassword: srcGopts.InsecureNoPassword,
+	}
+
+	rtest.OK(t, runReplicate(context.TODO(), opts, gopts, nil))
+}
+
+func TestReplicate(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+
+	testRunInit(t, env2.gopts)
+	testRunReplicate(t, env.gopts, env2.gopts, ReplicateOptions{})
+	testListSnapshots(t, env2.gopts, 2)
+	testRunCheck(t, env2.gopts)
+
+	// a second run must neither duplicate snapshots nor data
+	packs := testRunList(t, "packs", env2.gopts)
+	testRunReplicate(t, env.gopts, env2.gopts, ReplicateOptions{})
+	testListSnapshots(t, env2.gopts, 2)
+	rtest.Equals(t, len(packs), len(testRunList(t, "packs", env2.gopts)))
+
+	// new snapshots are replicated incrementally
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, opts, env.gopts)
+	testRunReplicate(t, env.gopts, env2.gopts, ReplicateOptions{})
+	testListSnapshots(t, env2.gopts, 3)
+	testRunCheck(t, env2.gopts)
+
+	// snapshots removed from the source are only removed with --delete
+	snapshotIDs := testListSnapshots(t, env.gopts, 3)
+	testRunForget(t, env.gopts, ForgetOptions{}, snapshotIDs[0].String())
+	testRunReplicate(t, env.gopts, env2.gopts, ReplicateOptions{})
+	testListSnapshots(t, env2.gopts, 3)
+	testRunReplicate(t, env.gopts, env2.gopts, ReplicateOptions{Delete: true})
+	testListSnapshots(t, env2.gopts, 2)
+
+	// the replicated snapshots match the source snapshots
+	testRunReplicate(t, env.gopts, env2.gopts, ReplicateOptions{})
+	_, srcSnapshots := testRunSnapshots(t, env.gopts)
+	_, dstSnapshots := testRunSnapshots(t, env2.gopts)
+	for _, sn := range dstSnapshots {
+		src, ok := srcSnapshots[*sn.Original]
+		rtest.Assert(t, ok, "replicated snapshot %v has no source snapshot", sn.ID.Str())
+		rtest.Equals(t, *src.Tree, *sn.Tree)
+	}
+}
+
+func TestReplicateFromOtherSource(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+	env3, cleanup3 := withTestEnvironment(t)
+	defer cleanup3()
+
+	testRunInit(t, env.gopts)
+	testRunInit(t, env2.gopts)
+	testRunInit(t, env3.gopts)
+	testRunReplicate(t, env.gopts, env2.gopts, ReplicateOptions{})
+
+	gopts := env3.gopts
+	gopts.Repo = env2.gopts.Repo
+	err := runReplicate(context.TODO(), ReplicateOptions{
+		secondaryRepoOptions: secondaryRepoOptions{Repo: env3.gopts.Repo, password: env3.gopts.password},
+	}, gopts, nil)
+	rtest.Assert(t, err != nil, "replicating from a different source should fail")
+}
+
+func TestReplicateOnlyReferencedData(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, opts, env.gopts)
+
+	// the data of the forgotten snapshot is still contained in the source
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	testRunForget(t, env.gopts, ForgetOptions{}, snapshotIDs[0].String())
+
+	testRunInit(t, env2.gopts)
+	testRunReplicate(t, env.gopts, env2.gopts, ReplicateOptions{})
+	testListSnapshots(t, env2.gopts, 1)
+	testRunCheck(t, env2.gopts)
+
+	// prune must not find any unreferenced data in the destination
+	packs := testRunList(t, "packs", env2.gopts)
+	testRunPrune(t, env2.gopts, PruneOptions{MaxUnused: "0%"})
+	rtest.Equals(t, packs, testRunList(t, "packs", env2.gopts))
+}
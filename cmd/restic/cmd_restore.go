@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/dump"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/filter"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/restorer"
 	"github.com/restic/restic/internal/ui"
@@ -15,6 +21,7 @@ import (
 	"github.com/restic/restic/internal/ui/termstatus"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 )
 
 var cmdRestore = &cobra.Command{
@@ -30,6 +37,27 @@ repository.
 To only restore a specific subfolder, you can use the "snapshotID:subfolder"
 syntax, where "subfolder" is a path within the snapshot.
 
+With --into-snapshot, the selected files are not written to a directory.
+Instead, a new snapshot is created in the repository which only contains the
+selected files. The data of the files is not copied, the new snapshot references
+the data of the original snapshot.
+
+With --output-format, the selected files are written to a "tar" or "zip"
+archive instead of a directory. Pass "--target -" to write the archive to
+stdout. The archive is created while the data is loaded from the repository, no
+temporary files are written to the local disk.
+
+With --preflight, no data is restored. Instead, restic checks whether the
+current user can create the device nodes and set the extended attributes and
+ACLs of the selected files in the target directory, and lists each item for
+which this would fail. Owners are only checked when running as root.
+
+With --allow-block-device, the target is a block device, for example /dev/sdb.
+The selected file, for example a disk image created using "backup --stdin", is
+written to the start of the device, overwriting its previous content. Exactly
+one file must be selected, using either "snapshotID:subfolder" or --include.
+The device must not be mounted and must be at least as large as the image.
+
 EXIT STATUS
 ===========
 
@@ -54,11 +82,21 @@ type RestoreOptions struct {
 	filter.IncludePatternOptions
 	Target string
 	restic.SnapshotFilter
-	DryRun    bool
-	Sparse    bool
-	Verify    bool
-	Overwrite restorer.OverwriteBehavior
-	Delete    bool
+	DryRun         bool
+	Sparse         bool
+	Verify         bool
+	Overwrite      restorer.OverwriteBehavior
+	Delete         bool
+	IntoSnapshot   bool
+	CaseCollisions restorer.CaseCollisionBehavior
+	WriteOrder     restorer.WriteOrder
+	NoHardlinks    bool
+	ErrorManifest  string
+	OutputFormat   string
+	Preflight      bool
+	BlockDevice    bool
+	ExcludeADS     bool
+	NoWindowsACLs  bool
 }
 
 var restoreOptions RestoreOptions
@@ -77,7 +115,19 @@ func init() {
 	flags.BoolVar(&restoreOptions.Sparse, "sparse", false, "restore files as sparse")
 	flags.BoolVar(&restoreOptions.Verify, "verify", false, "verify restored files content")
 	flags.Var(&restoreOptions.Overwrite, "overwrite", "overwrite behavior, one of (always|if-changed|if-newer|never) (default: always)")
-	flags.BoolVar(&restoreOptions.Delete, "delete", false, "delete files from target directory if they do not exist in snapshot. Use '--dry-run -vv' to check what would be deleted")
+	flags.BoolVar(&restoreOptions.Delete, "delete", false, "delete files from target directory if they do not exist in snapshot. Use '--dry-run -v' to check what would be deleted")
+	flags.Var(&restoreOptions.CaseCollisions, "case-collisions", "how to restore items whose name only differs in case from another item, one of (keep|rename|skip) (default: keep)")
+	flags.Var(&restoreOptions.WriteOrder, "write-order", "order in which restored data is written, one of (any|sequential). Use 'sequential' for targets on spinning disks (default: any)")
+	flags.BoolVar(&restoreOptions.NoHardlinks, "no-hardlinks", false, "restore hard linked files as independent copies")
+	flags.StringVar(&restoreOptions.ErrorManifest, "error-manifest", "", "write a JSON list of the files which could not be fully restored to `file`")
+	flags.BoolVar(&restoreOptions.IntoSnapshot, "into-snapshot", false, "create a new snapshot containing the selected files instead of restoring them to a directory")
+	flags.StringVar(&restoreOptions.OutputFormat, "output-format", "", "write the selected files to an archive in `format` \"tar\" or \"zip\" at --target (use \"-\" for stdout)")
+	flags.BoolVar(&restoreOptions.Preflight, "preflight", false, "only check whether the metadata of the selected files can be restored to the target, do not restore any data")
+	flags.BoolVar(&restoreOptions.BlockDevice, "allow-block-device", false, "write the single selected file to the block device at --target, overwriting its content")
+	if runtime.GOOS == "windows" {
+		flags.BoolVar(&restoreOptions.ExcludeADS, "exclude-ads", false, "do not restore alternate data streams of files")
+		flags.BoolVar(&restoreOptions.NoWindowsACLs, "no-windows-acls", false, "do not restore the security descriptors (owner and ACLs) of files and directories")
+	}
 }
 
 func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
@@ -103,7 +153,54 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		return errors.Fatalf("more than one snapshot ID specified: %v", args)
 	}
 
-	if opts.Target == "" {
+	switch opts.OutputFormat {
+	case "", "tar", "zip":
+	default:
+		return errors.Fatalf("unknown archive format %q", opts.OutputFormat)
+	}
+	if opts.OutputFormat != "" {
+		if opts.IntoSnapshot {
+			return errors.Fatal("--output-format and --into-snapshot are mutually exclusive")
+		}
+		if opts.DryRun || opts.Verify || opts.Delete || opts.Sparse || opts.ErrorManifest != "" {
+			return errors.Fatal("--output-format cannot be combined with --dry-run, --verify, --delete, --sparse or --error-manifest")
+		}
+		if opts.Target == "-" {
+			if err := checkStdoutArchive(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.Preflight {
+		if opts.IntoSnapshot || opts.OutputFormat != "" {
+			return errors.Fatal("--preflight cannot be combined with --into-snapshot or --output-format")
+		}
+		if opts.DryRun || opts.Verify || opts.Delete || opts.ErrorManifest != "" {
+			return errors.Fatal("--preflight cannot be combined with --dry-run, --verify, --delete or --error-manifest")
+		}
+	}
+
+	if opts.BlockDevice {
+		if opts.IntoSnapshot || opts.OutputFormat != "" || opts.Preflight {
+			return errors.Fatal("--allow-block-device cannot be combined with --into-snapshot, --output-format or --preflight")
+		}
+		if opts.DryRun || opts.Verify || opts.Delete || opts.Sparse || opts.ErrorManifest != "" {
+			return errors.Fatal("--allow-block-device cannot be combined with --dry-run, --verify, --delete, --sparse or --error-manifest")
+		}
+	}
+	if err := checkBlockDeviceTarget(opts); err != nil {
+		return err
+	}
+
+	if opts.IntoSnapshot {
+		if opts.Target != "" {
+			return errors.Fatal("--into-snapshot and --target are mutually exclusive")
+		}
+		if opts.DryRun || opts.Verify || opts.Delete || opts.Sparse || opts.ErrorManifest != "" {
+			return errors.Fatal("--into-snapshot cannot be combined with --dry-run, --verify, --delete, --sparse or --error-manifest")
+		}
+	} else if opts.Target == "" {
 		return errors.Fatal("please specify a directory to restore to (--target)")
 	}
 
@@ -122,12 +219,24 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 
 	debug.Log("restore %v to %v", snapshotIDString, opts.Target)
 
-	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	var (
+		repo   *repository.Repository
+		unlock func()
+	)
+	if opts.IntoSnapshot {
+		ctx, repo, unlock, err = openWithAppendLock(ctx, gopts, false)
+	} else {
+		ctx, repo, unlock, err = openWithReadLock(ctx, gopts, gopts.NoLock)
+	}
 	if err != nil {
 		return err
 	}
 	defer unlock()
 
+	if err := enablePackCache(repo, gopts); err != nil {
+		return err
+	}
+
 	sn, subfolder, err := (&restic.SnapshotFilter{
 		Hosts: opts.Hosts,
 		Paths: opts.Paths,
@@ -149,26 +258,50 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 	}
 
 	msg := ui.NewMessage(term, gopts.verbosity)
-	var printer restoreui.ProgressPrinter
-	if gopts.JSON {
-		printer = restoreui.NewJSONProgress(term, gopts.verbosity)
-	} else {
-		printer = restoreui.NewTextProgress(term, gopts.verbosity)
+	var progress *restoreui.Progress
+	if !opts.IntoSnapshot && opts.OutputFormat == "" && !opts.Preflight && !opts.BlockDevice {
+		var printer restoreui.ProgressPrinter
+		if gopts.JSON {
+			printer = restoreui.NewJSONProgress(term, gopts.verbosity, opts.DryRun)
+		} else {
+			printer = restoreui.NewTextProgress(term, gopts.verbosity, opts.DryRun)
+		}
+		progress = restoreui.NewProgress(printer, calculateProgressInterval(!gopts.Quiet, gopts.JSON))
 	}
 
-	progress := restoreui.NewProgress(printer, calculateProgressInterval(!gopts.Quiet, gopts.JSON))
 	res := restorer.NewRestorer(repo, sn, restorer.Options{
-		DryRun:    opts.DryRun,
-		Sparse:    opts.Sparse,
-		Progress:  progress,
-		Overwrite: opts.Overwrite,
-		Delete:    opts.Delete,
+		DryRun:         opts.DryRun,
+		Sparse:         opts.Sparse,
+		Progress:       progress,
+		Overwrite:      opts.Overwrite,
+		Delete:         opts.Delete,
+		CaseCollisions: opts.CaseCollisions,
+		WriteOrder:     opts.WriteOrder,
+		NoHardlinks:    opts.NoHardlinks,
+		ExcludeADS:     opts.ExcludeADS,
+		NoWindowsACLs:  opts.NoWindowsACLs,
 	})
 
 	totalErrors := 0
-	res.Error = func(location string, err error) error {
-		totalErrors++
-		return progress.Error(location, err)
+	var manifest *restoreErrorManifest
+	if opts.OutputFormat != "" || opts.Preflight || opts.BlockDevice {
+		res.Error = func(location string, err error) error {
+			totalErrors++
+			Warnf("ignoring error for %s: %s\n", location, err)
+			return nil
+		}
+	} else if !opts.IntoSnapshot {
+		if opts.ErrorManifest != "" {
+			manifest = newRestoreErrorManifest()
+			res.MissingRange = manifest.MissingRange
+		}
+		res.Error = func(location string, err error) error {
+			totalErrors++
+			if manifest != nil {
+				manifest.Error(location, err)
+			}
+			return progress.Error(location, err)
+		}
 	}
 	res.Warn = func(message string) {
 		msg.E("Warning: %s\n", message)
@@ -219,11 +352,40 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		res.SelectFilter = selectIncludeFilter
 	}
 
+	if opts.Preflight {
+		return restorePreflight(ctx, res, opts, gopts, msg, &totalErrors)
+	}
+	if opts.BlockDevice {
+		return restoreToBlockDevice(ctx, res, opts, gopts, msg)
+	}
+	if opts.IntoSnapshot {
+		return restoreIntoSnapshot(ctx, repo, res, sn, gopts, msg)
+	}
+	if opts.OutputFormat != "" {
+		if err := restoreToArchive(ctx, repo, res, opts, gopts, msg); err != nil {
+			return err
+		}
+		if totalErrors > 0 {
+			return errors.Fatalf("There were %d errors\n", totalErrors)
+		}
+		return nil
+	}
+
 	if !gopts.JSON {
 		msg.P("restoring %s to %s\n", res.Snapshot(), opts.Target)
 	}
 
 	countRestoredFiles, err := res.RestoreTo(ctx, opts.Target)
+	if manifest != nil {
+		// also save the manifest if the restore was aborted
+		if errManifest := manifest.WriteFile(opts.ErrorManifest, sn.ID().String(), opts.Target); errManifest != nil {
+			if err != nil {
+				Warnf("%v\n", errManifest)
+				return err
+			}
+			return errManifest
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -257,3 +419,198 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 
 	return nil
 }
+
+// restoreIntoSnapshot saves a new snapshot which contains the files selected
+// by the restorer.
+func restoreIntoSnapshot(ctx context.Context, repo restic.Repository, res *restorer.Restorer, sn *restic.Snapshot, gopts GlobalOptions, msg *ui.Message) error {
+	if !gopts.JSON {
+		msg.P("restoring %s into a new snapshot\n", res.Snapshot())
+	}
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	repo.StartPackUploader(wgCtx, wg)
+
+	var treeID restic.ID
+	wg.Go(func() error {
+		var err error
+		treeID, err = res.RestoreToSnapshot(wgCtx)
+		if err != nil {
+			return err
+		}
+		return repo.Flush(wgCtx)
+	})
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+
+	if treeID.IsNull() {
+		return errors.Fatal("no files were selected, not creating an empty snapshot")
+	}
+
+	newSn, err := restic.NewSnapshot(sn.Paths, sn.Tags, sn.Hostname, time.Now())
+	if err != nil {
+		return err
+	}
+	newSn.Tree = &treeID
+
+	id, err := restic.SaveSnapshot(ctx, repo, newSn)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(restoreIntoSnapshotSummary{
+			MessageType: "summary",
+			SnapshotID:  id.String(),
+		})
+	}
+	msg.P("saved new snapshot %v\n", id.Str())
+	return nil
+}
+
+// restoreToArchive writes the files selected by the restorer to an archive in
+// the format opts.OutputFormat.
+func restoreToArchive(ctx context.Context, repo restic.Loader, res *restorer.Restorer, opts RestoreOptions, gopts GlobalOptions, msg *ui.Message) (err error) {
+	var w io.Writer = globalOptions.stdout
+	if opts.Target != "-" {
+		if !gopts.JSON {
+			msg.P("restoring %s to %s archive %s\n", res.Snapshot(), opts.OutputFormat, opts.Target)
+		}
+		// assign to the named return value such that the deferred function
+		// can report errors from Close
+		var file *os.File
+		file, err = os.Create(opts.Target)
+		if err != nil {
+			return errors.Fatalf("cannot write archive: %v", err)
+		}
+		defer func() {
+			// the archive is incomplete if the data could not be flushed
+			if cerr := file.Close(); cerr != nil && err == nil {
+				err = errors.Fatalf("cannot write archive: %v", cerr)
+			}
+		}()
+		w = file
+	}
+
+	// buffered to deal with variable download/write speeds
+	ch := make(chan *restic.Node, 10)
+	wg, wgCtx := errgroup.WithContext(ctx)
+	wg.Go(func() error {
+		return res.RestoreToArchive(wgCtx, ch)
+	})
+	wg.Go(func() error {
+		return dump.New(opts.OutputFormat, repo, w).DumpNodes(wgCtx, ch)
+	})
+	return wg.Wait()
+}
+
+// checkBlockDeviceTarget verifies that --allow-block-device is specified if
+// and only if the target is a block device.
+func checkBlockDeviceTarget(opts RestoreOptions) error {
+	if opts.Target == "" || opts.Target == "-" || opts.IntoSnapshot || opts.OutputFormat != "" {
+		return nil
+	}
+	fi, err := os.Stat(opts.Target)
+	// character devices also have ModeDevice set
+	isDevice := err == nil && fi.Mode()&os.ModeDevice != 0 && fi.Mode()&os.ModeCharDevice == 0
+	if isDevice && !opts.BlockDevice {
+		return errors.Fatalf("target %v is a block device, specify --allow-block-device to overwrite it with the selected file", opts.Target)
+	}
+	if !isDevice && opts.BlockDevice {
+		return errors.Fatalf("target %v is not a block device", opts.Target)
+	}
+	return nil
+}
+
+// restoreToBlockDevice writes the file selected by the restorer to the block
+// device opts.Target.
+func restoreToBlockDevice(ctx context.Context, res *restorer.Restorer, opts RestoreOptions, gopts GlobalOptions, msg *ui.Message) error {
+	if !gopts.JSON {
+		msg.P("restoring %s to block device %s\n", res.Snapshot(), opts.Target)
+	}
+	t0 := time.Now()
+	n, err := res.RestoreToBlockDevice(ctx, opts.Target)
+	if err != nil {
+		return errors.Fatalf("restoring to %v failed: %v", opts.Target, err)
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(restoreBlockDeviceSummary{
+			MessageType:  "summary",
+			BytesWritten: n,
+		})
+	}
+	msg.P("wrote %s to %s in %s\n", ui.FormatBytes(n), opts.Target, time.Since(t0).Round(time.Second))
+	return nil
+}
+
+type restoreBlockDeviceSummary struct {
+	MessageType  string `json:"message_type"` // "summary"
+	BytesWritten uint64 `json:"bytes_written"`
+}
+
+// restorePreflight checks whether the metadata of the files selected by the
+// restorer can be applied in opts.Target and prints each item that would fail.
+func restorePreflight(ctx context.Context, res *restorer.Restorer, opts RestoreOptions, gopts GlobalOptions, msg *ui.Message, totalErrors *int) error {
+	if !gopts.JSON {
+		msg.P("checking whether %s can be restored to %s\n", res.Snapshot(), opts.Target)
+	}
+
+	enc := json.NewEncoder(globalOptions.stdout)
+	failed, err := res.Preflight(ctx, opts.Target, func(location string, problems []string) {
+		if gopts.JSON {
+			err := enc.Encode(restorePreflightItem{
+				MessageType: "preflight_item",
+				Item:        location,
+				Problems:    problems,
+			})
+			if err != nil {
+				Warnf("JSON encode failed: %v\n", err)
+			}
+			return
+		}
+		Printf("%s\n", location)
+		for _, problem := range problems {
+			Printf("    %s\n", problem)
+		}
+	})
+	if err != nil {
+		return errors.Fatalf("preflight check failed: %v", err)
+	}
+
+	if gopts.JSON {
+		err := enc.Encode(restorePreflightSummary{
+			MessageType: "summary",
+			FailedItems: failed,
+		})
+		if err != nil {
+			return err
+		}
+	} else if failed == 0 {
+		msg.P("all selected items can be restored with their metadata\n")
+	}
+
+	if *totalErrors > 0 {
+		return errors.Fatalf("There were %d errors\n", *totalErrors)
+	}
+	if failed > 0 {
+		return errors.Fatalf("the metadata of %d items cannot be restored", failed)
+	}
+	return nil
+}
+
+type restorePreflightItem struct {
+	MessageType string   `json:"message_type"` // "preflight_item"
+	Item        string   `json:"item"`
+	Problems    []string `json:"problems"`
+}
+
+type restorePreflightSummary struct {
+	MessageType string `json:"message_type"` // "summary"
+	FailedItems int    `json:"failed_items"`
+}
+
+type restoreIntoSnapshotSummary struct {
+	MessageType string `json:"message_type"` // "summary"
+	SnapshotID  string `json:"snapshot_id"`
+}
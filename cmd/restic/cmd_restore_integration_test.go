@@ -1,17 +1,22 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 	"github.com/restic/restic/internal/ui/termstatus"
@@ -261,6 +266,38 @@ func TestRestore(t *testing.T) {
 	rtest.Assert(t, diff == "", "directories are not equal %v", diff)
 }
 
+func TestRestorePackCache(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for i := 0; i < 5; i++ {
+		p := filepath.Join(env.testdata, fmt.Sprintf("foo/testfile%v", i))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, uint(rand.Intn(2<<20))))
+	}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{filepath.Base(env.testdata)}, BackupOptions{}, env.gopts)
+
+	env.gopts.PackCacheSize = "64M"
+	testRunRestoreLatest(t, env.gopts, filepath.Join(env.base, "restore1"), nil, nil)
+
+	// remove the cached pack files from the repository
+	cachedPacks, err := filepath.Glob(filepath.Join(env.cache, "*", "packs", "*", "*"))
+	rtest.OK(t, err)
+	rtest.Assert(t, len(cachedPacks) > 0, "no pack files were cached")
+	for _, pack := range cachedPacks {
+		name := filepath.Base(pack)
+		rtest.OK(t, os.Remove(filepath.Join(env.repo, "data", name[:2], name)))
+	}
+
+	// the second restore must only use the cached pack files
+	restoredir := filepath.Join(env.base, "restore2")
+	testRunRestoreLatest(t, env.gopts, restoredir, nil, nil)
+	diff := directoriesContentsDiff(env.testdata, filepath.Join(restoredir, filepath.Base(env.testdata)))
+	rtest.Assert(t, diff == "", "directories are not equal %v", diff)
+}
+
 func TestRestoreLatest(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -355,6 +392,52 @@ func TestRestoreWithPermissionFailure(t *testing.T) {
 	}
 }
 
+func TestRestoreWithErrorManifest(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	p := filepath.Join(env.testdata, "testfile")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, appendRandomData(p, 2<<20))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{filepath.Base(env.testdata)}, BackupOptions{}, env.gopts)
+
+	// remove the largest pack file, which contains the file content
+	var dataPack string
+	var dataPackSize int64
+	rtest.OK(t, filepath.Walk(filepath.Join(env.repo, "data"), func(path string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() && fi.Size() > dataPackSize {
+			dataPack, dataPackSize = path, fi.Size()
+		}
+		return err
+	}))
+	rtest.OK(t, os.Remove(dataPack))
+
+	manifestFile := filepath.Join(env.base, "manifest.json")
+	opts := RestoreOptions{
+		Target:        filepath.Join(env.base, "restore"),
+		ErrorManifest: manifestFile,
+	}
+	err := withRestoreGlobalOptions(func() error {
+		globalOptions.stderr = io.Discard
+		return testRunRestoreAssumeFailure("latest", opts, env.gopts)
+	})
+	rtest.Assert(t, err != nil, "restore with missing pack file did not fail")
+
+	data, err := os.ReadFile(manifestFile)
+	rtest.OK(t, err)
+	var manifest restoreErrorManifestJSON
+	rtest.OK(t, json.Unmarshal(data, &manifest))
+	rtest.Equals(t, opts.Target, manifest.Target)
+	rtest.Equals(t, 1, len(manifest.Files))
+
+	file := manifest.Files[0]
+	rtest.Equals(t, "/"+filepath.Base(env.testdata)+"/testfile", filepath.ToSlash(file.Path))
+	rtest.Assert(t, len(file.Errors) > 0, "no errors recorded")
+	rtest.Equals(t, []restoreByteRange{{0, 2 << 20}}, file.MissingRanges)
+}
+
 func setZeroModTime(filename string) error {
 	var utimes = []syscall.Timespec{
 		syscall.NsecToTimespec(0),
@@ -420,3 +503,160 @@ func TestRestoreDefaultLayout(t *testing.T) {
 	rtest.RemoveAll(t, filepath.Join(env.base, "repo"))
 	rtest.RemoveAll(t, target)
 }
+
+func TestRestoreIntoSnapshot(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	for _, name := range []string{"a/keep.txt", "a/drop.txt", "b/other.txt"} {
+		p := filepath.Join(env.testdata, filepath.FromSlash(name))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, os.WriteFile(p, []byte(name), 0644))
+	}
+	testRunBackup(t, env.base, []string{"testdata"}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	opts := RestoreOptions{IntoSnapshot: true}
+	opts.Includes = []string{"keep.txt"}
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotIDs[0].String(), opts, env.gopts))
+
+	newIDs := testListSnapshots(t, env.gopts, 2)
+	var newID restic.ID
+	for _, id := range newIDs {
+		if id != snapshotIDs[0] {
+			newID = id
+		}
+	}
+
+	var files []string
+	for _, line := range testRunLs(t, env.gopts, newID.String()) {
+		if strings.Contains(line, "testdata") {
+			files = append(files, filepath.ToSlash(line)[strings.Index(filepath.ToSlash(line), "testdata"):])
+		}
+	}
+	rtest.Equals(t, []string{"testdata", "testdata/a", "testdata/a/keep.txt"}, files)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, newID.String())
+	buf, err := os.ReadFile(filepath.Join(restoredir, "testdata", "a", "keep.txt"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "a/keep.txt", string(buf))
+	testRunCheck(t, env.gopts)
+
+	// selecting nothing must not create a snapshot
+	opts.Includes = []string{"missing"}
+	rtest.Assert(t, testRunRestoreAssumeFailure(snapshotIDs[0].String(), opts, env.gopts) != nil, "expected error")
+	testListSnapshots(t, env.gopts, 2)
+}
+
+func TestRestoreToArchive(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	for _, name := range []string{"a/keep.txt", "a/drop.txt", "b/other.txt"} {
+		p := filepath.Join(env.testdata, filepath.FromSlash(name))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, os.WriteFile(p, []byte(name), 0644))
+	}
+	testRunBackup(t, env.base, []string{"testdata"}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	// only the selected files and directories are part of the archive
+	archive := filepath.Join(env.base, "restore.tar")
+	opts := RestoreOptions{OutputFormat: "tar", Target: archive}
+	opts.Excludes = []string{"drop.txt", "b"}
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotIDs[0].String(), opts, env.gopts))
+
+	f, err := os.Open(archive)
+	rtest.OK(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+
+	files := make(map[string]string)
+	rd := tar.NewReader(f)
+	for {
+		hdr, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		buf, err := io.ReadAll(rd)
+		rtest.OK(t, err)
+		name := hdr.Name[strings.Index(hdr.Name, "testdata"):]
+		files[name] = string(buf)
+	}
+	rtest.Equals(t, map[string]string{
+		"testdata/":           "",
+		"testdata/a/":         "",
+		"testdata/a/keep.txt": "a/keep.txt",
+	}, files)
+
+	opts.OutputFormat = "zip"
+	opts.Target = filepath.Join(env.base, "restore.zip")
+	rtest.OK(t, testRunRestoreAssumeFailure(snapshotIDs[0].String(), opts, env.gopts))
+	zrd, err := zip.OpenReader(opts.Target)
+	rtest.OK(t, err)
+	rtest.OK(t, zrd.Close())
+
+	opts.OutputFormat = "cpio"
+	rtest.Assert(t, testRunRestoreAssumeFailure(snapshotIDs[0].String(), opts, env.gopts) != nil, "unknown format was accepted")
+}
+
+func TestRestorePreflight(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	p := filepath.Join(env.testdata, "a", "file.txt")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, os.WriteFile(p, []byte("content"), 0644))
+	testRunBackup(t, env.base, []string{"testdata"}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	target := filepath.Join(env.base, "restore")
+	opts := RestoreOptions{Target: target, Preflight: true}
+	env.gopts.JSON = true
+	out, err := withCaptureStdout(func() error {
+		return testRunRestoreAssumeFailure(snapshotIDs[0].String(), opts, env.gopts)
+	})
+	rtest.OK(t, err)
+
+	var summary restorePreflightSummary
+	rtest.OK(t, json.Unmarshal(out.Bytes(), &summary))
+	rtest.Equals(t, restorePreflightSummary{MessageType: "summary", FailedItems: 0}, summary)
+
+	_, err = os.Stat(target)
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "expected no data to be restored, got %v", err)
+
+	opts.Verify = true
+	rtest.Assert(t, testRunRestoreAssumeFailure(snapshotIDs[0].String(), opts, env.gopts) != nil, "--preflight --verify was accepted")
+}
+
+func TestRestoreBlockDeviceTarget(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	p := filepath.Join(env.testdata, "disk.img")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, os.WriteFile(p, []byte("image"), 0644))
+	testRunBackup(t, env.base, []string{"testdata"}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	// a regular file, directory or character device is not a block device
+	targets := []string{p, env.base}
+	if runtime.GOOS != "windows" {
+		targets = append(targets, "/dev/null")
+	}
+	for _, target := range targets {
+		opts := RestoreOptions{Target: target, BlockDevice: true}
+		err := testRunRestoreAssumeFailure(snapshotIDs[0].String()+":testdata", opts, env.gopts)
+		rtest.Assert(t, err != nil && strings.Contains(err.Error(), "is not a block device"), "unexpected error %v", err)
+	}
+
+	opts := RestoreOptions{Target: p, BlockDevice: true, Verify: true}
+	rtest.Assert(t, testRunRestoreAssumeFailure(snapshotIDs[0].String(), opts, env.gopts) != nil, "--allow-block-device --verify was accepted")
+}
@@ -72,9 +72,9 @@ func (sma snapshotMetadataArgs) convert() (*snapshotMetadata, error) {
 
 	var timeStamp *time.Time
 	if sma.Time != "" {
-		t, err := time.ParseInLocation(TimeFormat, sma.Time, time.Local)
+		t, err := parseSnapshotTime("new-time", sma.Time, time.Now())
 		if err != nil {
-			return nil, errors.Fatalf("error in time option: %v\n", err)
+			return nil, err
 		}
 		timeStamp = &t
 	}
@@ -83,8 +83,9 @@ func (sma snapshotMetadataArgs) convert() (*snapshotMetadata, error) {
 
 // RewriteOptions collects all options for the rewrite command.
 type RewriteOptions struct {
-	Forget bool
-	DryRun bool
+	Forget       bool
+	DryRun       bool
+	OverrideHold string
 
 	Metadata snapshotMetadataArgs
 	restic.SnapshotFilter
@@ -99,6 +100,7 @@ func init() {
 	f := cmdRewrite.Flags()
 	f.BoolVarP(&rewriteOptions.Forget, "forget", "", false, "remove original snapshots after creating new ones")
 	f.BoolVarP(&rewriteOptions.DryRun, "dry-run", "n", false, "do not do anything, just print what would be done")
+	f.StringVar(&rewriteOptions.OverrideHold, "override-hold", "", "run despite an active legal hold, the `justification` is recorded in the repository")
 	f.StringVar(&rewriteOptions.Metadata.Hostname, "new-host", "", "replace hostname")
 	f.StringVar(&rewriteOptions.Metadata.Time, "new-time", "", "replace time of the backup")
 
@@ -237,6 +239,12 @@ func filterAndReplaceSnapshot(ctx context.Context, repo restic.Repository, sn *r
 
 	if newMetadata != nil && newMetadata.Time != nil {
 		Verbosef("setting time to %s\n", *newMetadata.Time)
+		// snapshots created before ingested_at was introduced still record
+		// the end of the backup in their summary
+		if sn.IngestedAt == nil && sn.Summary != nil {
+			ingestedAt := sn.Summary.BackupEnd
+			sn.IngestedAt = &ingestedAt
+		}
 		sn.Time = *newMetadata.Time
 	}
 
@@ -284,6 +292,15 @@ func runRewrite(ctx context.Context, opts RewriteOptions, gopts GlobalOptions, a
 	}
 	defer unlock()
 
+	if !opts.DryRun {
+		if err := checkHold(ctx, repo, "rewrite", opts.OverrideHold); err != nil {
+			return err
+		}
+		if opts.Forget && !repo.CanDelete() {
+			return repository.ErrAppendOnly
+		}
+	}
+
 	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
 	if err != nil {
 		return err
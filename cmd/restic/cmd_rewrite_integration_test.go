@@ -4,6 +4,7 @@ import (
 	"context"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/restic"
@@ -121,6 +122,9 @@ func testRewriteMetadata(t *testing.T, metadata snapshotMetadataArgs) {
 	if metadata.Time != "" {
 		rtest.Assert(t, newSnapshot.Time.Format(TimeFormat) == metadata.Time, "New snapshot should have time %s", metadata.Time)
 	}
+	// the time of the backup is retained
+	rtest.Assert(t, newSnapshot.IngestedAt != nil && time.Since(*newSnapshot.IngestedAt) < time.Hour,
+		"unexpected ingestion time %v", newSnapshot.IngestedAt)
 
 	if metadata.Hostname != "" {
 		rtest.Assert(t, newSnapshot.Hostname == metadata.Hostname, "New snapshot should have host %s", metadata.Hostname)
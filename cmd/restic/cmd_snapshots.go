@@ -7,6 +7,7 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
@@ -172,10 +173,12 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 			keepReasons[*id] = reasons[i]
 		}
 	}
-	// check if any snapshot contains a summary
+	// check if any snapshot contains a summary or is part of a partial chain
 	hasSize := false
+	hasStatus := false
 	for _, sn := range list {
 		hasSize = hasSize || (sn.Summary != nil)
+		hasStatus = hasStatus || sn.Partial || len(sn.Supersedes) > 0
 	}
 
 	// always sort the snapshots so that the newer ones are listed last
@@ -203,6 +206,9 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		tab.AddColumn("Time", "{{ .Timestamp }}")
 		tab.AddColumn("Host", "{{ .Hostname }}")
 		tab.AddColumn("Tags  ", `{{ join .Tags "\n" }}`)
+		if hasStatus {
+			tab.AddColumn("Status", "{{ .Status }}")
+		}
 		if hasSize {
 			tab.AddColumn("Size", `{{ .Size }}`)
 		}
@@ -211,6 +217,9 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		tab.AddColumn("Time", "{{ .Timestamp }}")
 		tab.AddColumn("Host      ", "{{ .Hostname }}")
 		tab.AddColumn("Tags      ", `{{ join .Tags "," }}`)
+		if hasStatus {
+			tab.AddColumn("Status", "{{ .Status }}")
+		}
 		if len(reasons) > 0 {
 			tab.AddColumn("Reasons", `{{ join .Reasons "\n" }}`)
 		}
@@ -228,6 +237,7 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		Reasons   []string
 		Paths     []string
 		Size      string
+		Status    string
 	}
 
 	var multiline bool
@@ -253,6 +263,12 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 			data.Size = ui.FormatBytes(sn.Summary.TotalBytesProcessed)
 		}
 
+		if sn.Partial {
+			data.Status = "partial"
+		} else if len(sn.Supersedes) > 0 {
+			data.Status = fmt.Sprintf("supersedes %d", len(sn.Supersedes))
+		}
+
 		tab.AddRow(data)
 	}
 
@@ -331,6 +347,47 @@ type Snapshot struct {
 type SnapshotGroup struct {
 	GroupKey  restic.SnapshotGroupKey `json:"group_key"`
 	Snapshots []Snapshot              `json:"snapshots"`
+	Summary   SnapshotGroupSummary    `json:"summary"`
+}
+
+// SnapshotGroupSummary contains aggregate statistics for a group of snapshots.
+// The sizes are summed up from the summaries stored in the snapshots, which
+// are missing for snapshots created by restic versions before 0.17.0.
+type SnapshotGroupSummary struct {
+	Count  int       `json:"count"`
+	Oldest time.Time `json:"oldest"`
+	Newest time.Time `json:"newest"`
+
+	TotalBytesProcessed uint64 `json:"total_bytes_processed"`
+	TotalFilesProcessed uint64 `json:"total_files_processed"`
+	DataAdded           uint64 `json:"data_added"`
+	DataAddedPacked     uint64 `json:"data_added_packed"`
+	// WithoutSummary counts the snapshots which are not included in the sizes
+	WithoutSummary int `json:"snapshots_without_summary"`
+}
+
+// summarizeSnapshotGroup computes the aggregate statistics for list.
+func summarizeSnapshotGroup(list restic.Snapshots) SnapshotGroupSummary {
+	var s SnapshotGroupSummary
+	for _, sn := range list {
+		if s.Count == 0 || sn.Time.Before(s.Oldest) {
+			s.Oldest = sn.Time
+		}
+		if s.Count == 0 || sn.Time.After(s.Newest) {
+			s.Newest = sn.Time
+		}
+		s.Count++
+
+		if sn.Summary == nil {
+			s.WithoutSummary++
+			continue
+		}
+		s.TotalBytesProcessed += sn.Summary.TotalBytesProcessed
+		s.TotalFilesProcessed += uint64(sn.Summary.TotalFilesProcessed)
+		s.DataAdded += sn.Summary.DataAdded
+		s.DataAddedPacked += sn.Summary.DataAddedPacked
+	}
+	return s
 }
 
 // printSnapshotGroupJSON writes the JSON representation of list to stdout.
@@ -360,6 +417,7 @@ func printSnapshotGroupJSON(stdout io.Writer, snGroups map[string]restic.Snapsho
 			group := SnapshotGroup{
 				GroupKey:  key,
 				Snapshots: snapshots,
+				Summary:   summarizeSnapshotGroup(list),
 			}
 			snapshotGroups = append(snapshotGroups, group)
 		}
@@ -3,7 +3,9 @@ package main
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -17,3 +19,23 @@ func TestEmptySnapshotGroupJSON(t *testing.T) {
 		rtest.Equals(t, "[]", strings.TrimSpace(w.String()))
 	}
 }
+
+func TestSummarizeSnapshotGroup(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	list := restic.Snapshots{
+		{Time: t0.Add(time.Hour), Summary: &restic.SnapshotSummary{TotalBytesProcessed: 100, TotalFilesProcessed: 3, DataAdded: 40, DataAddedPacked: 20}},
+		{Time: t0},
+		{Time: t0.Add(2 * time.Hour), Summary: &restic.SnapshotSummary{TotalBytesProcessed: 200, TotalFilesProcessed: 4, DataAdded: 10, DataAddedPacked: 5}},
+	}
+
+	rtest.Equals(t, SnapshotGroupSummary{
+		Count:               3,
+		Oldest:              t0,
+		Newest:              t0.Add(2 * time.Hour),
+		TotalBytesProcessed: 300,
+		TotalFilesProcessed: 7,
+		DataAdded:           50,
+		DataAddedPacked:     25,
+		WithoutSummary:      1,
+	}, summarizeSnapshotGroup(list))
+}
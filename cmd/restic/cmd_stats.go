@@ -10,6 +10,7 @@ import (
 
 	"github.com/restic/chunker"
 	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/restorer"
@@ -46,6 +47,10 @@ The modes are:
 
 Refer to the online manual for more details about each mode.
 
+With --history, the statistics recorded after each backup and prune run are
+printed instead. Recording the statistics requires the "stats-history" feature
+flag, see "restic features".
+
 EXIT STATUS
 ===========
 
@@ -66,6 +71,8 @@ Exit status is 12 if the password is incorrect.
 type StatsOptions struct {
 	// the mode of counting to perform (see consts for available modes)
 	countMode string
+	// print the recorded statistics history
+	History bool
 
 	restic.SnapshotFilter
 }
@@ -86,6 +93,8 @@ func init() {
 		return []string{countModeRestoreSize, countModeUniqueFilesByContents, countModeBlobsPerFile, countModeRawData}, cobra.ShellCompDirectiveDefault
 	}))
 
+	f.BoolVar(&statsOptions.History, "history", false, "print the repository statistics recorded after each backup and prune run")
+
 	initMultiSnapshotFilter(f, &statsOptions.SnapshotFilter, true)
 }
 
@@ -95,12 +104,20 @@ func runStats(ctx context.Context, opts StatsOptions, gopts GlobalOptions, args
 		return err
 	}
 
+	if opts.History && (len(args) > 0 || !opts.SnapshotFilter.Empty()) {
+		return errors.Fatal("--history cannot be combined with a snapshot selection")
+	}
+
 	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
 	if err != nil {
 		return err
 	}
 	defer unlock()
 
+	if opts.History {
+		return statsHistory(ctx, repo, gopts)
+	}
+
 	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
 	if err != nil {
 		return err
@@ -195,6 +212,60 @@ func runStats(ctx context.Context, opts StatsOptions, gopts GlobalOptions, args
 	return nil
 }
 
+func statsHistory(ctx context.Context, repo restic.StateLoader, gopts GlobalOptions) error {
+	history, err := repository.LoadStatsHistory(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		if history == nil {
+			history = []repository.StatsHistoryEntry{}
+		}
+		err = json.NewEncoder(globalOptions.stdout).Encode(history)
+		if err != nil {
+			return fmt.Errorf("encoding output: %v", err)
+		}
+		return nil
+	}
+
+	if len(history) == 0 {
+		Printf("no statistics recorded, enable the stats-history feature flag to record them\n")
+		return nil
+	}
+
+	type historyRow struct {
+		Time, Command, Packs, Blobs, Size, Uncompressed, Added, Dedup string
+	}
+	tab := table.New()
+	tab.AddColumn("Time", "{{ .Time }}")
+	tab.AddColumn("Command", "{{ .Command }}")
+	tab.AddColumn("Packs", "{{ .Packs }}")
+	tab.AddColumn("Blobs", "{{ .Blobs }}")
+	tab.AddColumn("Total Size", "{{ .Size }}")
+	tab.AddColumn("Uncompressed", "{{ .Uncompressed }}")
+	tab.AddColumn("Added", "{{ .Added }}")
+	tab.AddColumn("Dedup Ratio", "{{ .Dedup }}")
+	for _, entry := range history {
+		row := historyRow{
+			Time:         entry.Time.Local().Format(TimeFormat),
+			Command:      entry.Command,
+			Packs:        fmt.Sprintf("%d", entry.PackCount),
+			Blobs:        fmt.Sprintf("%d", entry.BlobCount),
+			Size:         ui.FormatBytes(entry.TotalSize),
+			Uncompressed: ui.FormatBytes(entry.UncompressedSize),
+		}
+		if entry.Command == "backup" {
+			row.Added = ui.FormatBytes(entry.DataAdded)
+		}
+		if entry.DedupRatio > 0 {
+			row.Dedup = fmt.Sprintf("%.2fx", entry.DedupRatio)
+		}
+		tab.AddRow(row)
+	}
+	return tab.Write(globalOptions.stdout)
+}
+
 func statsWalkSnapshot(ctx context.Context, snapshot *restic.Snapshot, repo restic.Loader, opts StatsOptions, stats *statsContainer) error {
 	if snapshot.Tree == nil {
 		return fmt.Errorf("snapshot %s has nil tree", snapshot.ID().Str())
@@ -209,7 +280,7 @@ func statsWalkSnapshot(ctx context.Context, snapshot *restic.Snapshot, repo rest
 	}
 
 	hardLinkIndex := restorer.NewHardlinkIndex[struct{}]()
-	err := walker.Walk(ctx, repo, *snapshot.Tree, walker.WalkVisitor{
+	err := walker.WalkParallel(ctx, repo, *snapshot.Tree, int(repo.Connections()), walker.WalkVisitor{
 		ProcessNode: statsWalkTree(repo, opts, stats, hardLinkIndex),
 	})
 	if err != nil {
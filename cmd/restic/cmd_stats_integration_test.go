@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/repository"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunStatsHistory(t testing.TB, gopts GlobalOptions) []repository.StatsHistoryEntry {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runStats(context.TODO(), StatsOptions{countMode: countModeRestoreSize, History: true}, gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var history []repository.StatsHistoryEntry
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &history))
+	return history
+}
+
+func TestStatsHistory(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+
+	// nothing is recorded without the feature flag
+	testRunBackup(t, env.testdata, []string{"."}, opts, env.gopts)
+	rtest.Equals(t, 0, len(testRunStatsHistory(t, env.gopts)))
+
+	defer feature.TestSetFlag(t, feature.Flag, feature.StatsHistory, true)()
+	testRunBackup(t, env.testdata, []string{"."}, opts, env.gopts)
+	testRunForget(t, env.gopts, ForgetOptions{Last: 1})
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "5%"})
+
+	history := testRunStatsHistory(t, env.gopts)
+	rtest.Equals(t, 2, len(history))
+	rtest.Equals(t, "backup", history[0].Command)
+	rtest.Assert(t, history[0].SnapshotID != nil, "snapshot ID missing")
+	rtest.Assert(t, history[0].BytesProcessed > 0, "processed bytes missing")
+	rtest.Equals(t, "prune", history[1].Command)
+	rtest.Assert(t, history[1].TotalSize > 0 && history[1].PackCount > 0, "repository size missing")
+	testRunCheck(t, env.gopts)
+}
@@ -44,6 +44,8 @@ type TagOptions struct {
 	SetTags    restic.TagLists
 	AddTags    restic.TagLists
 	RemoveTags restic.TagLists
+
+	OverrideHold string
 }
 
 var tagOptions TagOptions
@@ -55,6 +57,7 @@ func init() {
 	tagFlags.Var(&tagOptions.SetTags, "set", "`tags` which will replace the existing tags in the format `tag[,tag,...]` (can be given multiple times)")
 	tagFlags.Var(&tagOptions.AddTags, "add", "`tags` which will be added to the existing tags in the format `tag[,tag,...]` (can be given multiple times)")
 	tagFlags.Var(&tagOptions.RemoveTags, "remove", "`tags` which will be removed from the existing tags in the format `tag[,tag,...]` (can be given multiple times)")
+	tagFlags.StringVar(&tagOptions.OverrideHold, "override-hold", "", "run despite an active legal hold, the `justification` is recorded in the repository")
 	initMultiSnapshotFilter(tagFlags, &tagOptions.SnapshotFilter, true)
 }
 
@@ -114,6 +117,13 @@ func runTag(ctx context.Context, opts TagOptions, gopts GlobalOptions, args []st
 	}
 	defer unlock()
 
+	if err := checkHold(ctx, repo, "tag", opts.OverrideHold); err != nil {
+		return err
+	}
+	if !repo.CanDelete() {
+		return repository.ErrAppendOnly
+	}
+
 	changeCnt := 0
 	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
 		changed, err := changeTags(ctx, repo, sn, opts.SetTags.Flatten(), opts.AddTags.Flatten(), opts.RemoveTags.Flatten())
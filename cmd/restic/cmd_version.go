@@ -3,8 +3,13 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"runtime"
 
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/restic"
+
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +20,10 @@ var versionCmd = &cobra.Command{
 The "version" command prints detailed information about the build environment
 and the version of this software.
 
+With --json, the output additionally lists the capabilities of this restic
+binary: the compiled-in backends, the state of all feature flags, the
+cryptographic primitives and the supported repository format versions.
+
 EXIT STATUS
 ===========
 
@@ -24,23 +33,7 @@ Exit status is 1 if there was any error.
 	DisableAutoGenTag: true,
 	Run: func(_ *cobra.Command, _ []string) {
 		if globalOptions.JSON {
-			type jsonVersion struct {
-				MessageType string `json:"message_type"` // version
-				Version     string `json:"version"`
-				GoVersion   string `json:"go_version"`
-				GoOS        string `json:"go_os"`
-				GoArch      string `json:"go_arch"`
-			}
-
-			jsonS := jsonVersion{
-				MessageType: "version",
-				Version:     version,
-				GoVersion:   runtime.Version(),
-				GoOS:        runtime.GOOS,
-				GoArch:      runtime.GOARCH,
-			}
-
-			err := json.NewEncoder(globalOptions.stdout).Encode(jsonS)
+			err := printVersionJSON(globalOptions.stdout, globalOptions)
 			if err != nil {
 				Warnf("JSON encode failed: %v\n", err)
 				return
@@ -56,3 +49,79 @@ Exit status is 1 if there was any error.
 func init() {
 	cmdRoot.AddCommand(versionCmd)
 }
+
+type jsonVersion struct {
+	MessageType string           `json:"message_type"` // version
+	Version     string           `json:"version"`
+	GoVersion   string           `json:"go_version"`
+	GoOS        string           `json:"go_os"`
+	GoArch      string           `json:"go_arch"`
+	Backends    []string         `json:"backends"`
+	Features    []jsonFeature    `json:"features"`
+	Crypto      jsonCrypto       `json:"crypto"`
+	Repository  jsonRepoVersions `json:"repository"`
+}
+
+type jsonFeature struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+type jsonCrypto struct {
+	Cipher string `json:"cipher"`
+	MAC    string `json:"mac"`
+	KDF    string `json:"kdf"`
+}
+
+type jsonRepoVersions struct {
+	MinVersion     uint              `json:"min_version"`
+	MaxVersion     uint              `json:"max_version"`
+	DefaultVersion uint              `json:"default_version"`
+	Versions       []jsonRepoVersion `json:"versions"`
+}
+
+type jsonRepoVersion struct {
+	Version  uint     `json:"version"`
+	Features []string `json:"features"`
+}
+
+func printVersionJSON(w io.Writer, gopts GlobalOptions) error {
+	features := []jsonFeature{}
+	for _, flag := range feature.Flag.List() {
+		features = append(features, jsonFeature{
+			Name:    flag.Name,
+			Type:    flag.Type,
+			Enabled: feature.Flag.Enabled(feature.FlagName(flag.Name)),
+		})
+	}
+
+	versions := []jsonRepoVersion{}
+	for v := uint(restic.MinRepoVersion); v <= restic.MaxRepoVersion; v++ {
+		versions = append(versions, jsonRepoVersion{
+			Version:  v,
+			Features: restic.RepoVersionFeatures[v],
+		})
+	}
+
+	return json.NewEncoder(w).Encode(jsonVersion{
+		MessageType: "version",
+		Version:     version,
+		GoVersion:   runtime.Version(),
+		GoOS:        runtime.GOOS,
+		GoArch:      runtime.GOARCH,
+		Backends:    gopts.backends.Schemes(),
+		Features:    features,
+		Crypto: jsonCrypto{
+			Cipher: crypto.CipherName,
+			MAC:    crypto.MACName,
+			KDF:    crypto.KDFName,
+		},
+		Repository: jsonRepoVersions{
+			MinVersion:     restic.MinRepoVersion,
+			MaxVersion:     restic.MaxRepoVersion,
+			DefaultVersion: restic.StableRepoVersion,
+			Versions:       versions,
+		},
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestVersionJSON(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	rtest.OK(t, printVersionJSON(buf, globalOptions))
+
+	var v jsonVersion
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &v))
+	rtest.Equals(t, "version", v.MessageType)
+	rtest.Equals(t, globalOptions.backends.Schemes(), v.Backends)
+	rtest.Assert(t, len(v.Features) > 0, "missing feature flags")
+	rtest.Equals(t, uint(restic.MaxRepoVersion), v.Repository.MaxVersion)
+	rtest.Equals(t, int(restic.MaxRepoVersion-restic.MinRepoVersion+1), len(v.Repository.Versions))
+	last := v.Repository.Versions[len(v.Repository.Versions)-1]
+	rtest.Equals(t, restic.RepoVersionFeatures[restic.MaxRepoVersion], last.Features)
+	rtest.Assert(t, v.Crypto.Cipher != "", "missing cipher")
+}
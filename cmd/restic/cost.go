@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/restic/restic/internal/backend/cost"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// parsePriceTable returns the price table set using --price-table or nil if
+// no price table was specified.
+func parsePriceTable(gopts GlobalOptions) (*cost.PriceTable, error) {
+	if gopts.PriceTable == "" {
+		return nil, nil
+	}
+
+	prices, err := cost.ParsePriceTable(gopts.PriceTable)
+	if err != nil {
+		return nil, errors.Fatalf("invalid price table: %v", err)
+	}
+	return &prices, nil
+}
+
+// formatCostEstimate describes the estimated backend requests and transferred
+// data. If prices is not nil, the resulting costs are included. Each line
+// starts with indent.
+func formatCostEstimate(ops cost.Operations, prices *cost.PriceTable, indent string) string {
+	msg := fmt.Sprintf("%sestimated backend requests: %v, download %s, upload %s\n",
+		indent, ops, ui.FormatBytes(ops.Download), ui.FormatBytes(ops.Upload))
+	if prices != nil {
+		msg += fmt.Sprintf("%sestimated cost: %.4f\n", indent, prices.Cost(ops))
+	}
+	return msg
+}
+
+// costEstimateJSON is the JSON representation of a cost estimate.
+type costEstimateJSON struct {
+	ListRequests   uint64 `json:"list_requests"`
+	GetRequests    uint64 `json:"get_requests"`
+	PutRequests    uint64 `json:"put_requests"`
+	DeleteRequests uint64 `json:"delete_requests"`
+	DownloadBytes  uint64 `json:"download_bytes"`
+	UploadBytes    uint64 `json:"upload_bytes"`
+	// Cost is only set if a price table was specified
+	Cost *float64 `json:"cost,omitempty"`
+}
+
+func newCostEstimateJSON(ops cost.Operations, prices *cost.PriceTable) costEstimateJSON {
+	estimate := costEstimateJSON{
+		ListRequests:   ops.List,
+		GetRequests:    ops.Get,
+		PutRequests:    ops.Put,
+		DeleteRequests: ops.Delete,
+		DownloadBytes:  ops.Download,
+		UploadBytes:    ops.Upload,
+	}
+	if prices != nil {
+		c := prices.Cost(ops)
+		estimate.Cost = &c
+	}
+	return estimate
+}
+
+// printCostEstimate prints the estimate for the backend requests. Without a
+// price table, the estimate is only shown in verbose mode.
+func printCostEstimate(printer progress.Printer, ops cost.Operations, prices *cost.PriceTable) {
+	if prices != nil {
+		printer.P("%s", formatCostEstimate(ops, prices, ""))
+	} else {
+		printer.V("%s", formatCostEstimate(ops, prices, ""))
+	}
+}
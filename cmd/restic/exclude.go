@@ -1,13 +1,33 @@
 package main
 
 import (
+	"encoding/json"
+	"os"
+	"sync"
+
 	"github.com/restic/restic/internal/archiver"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/repository"
+	"github.com/spf13/pflag"
 )
 
+// addExcludeFlags adds the flags which control the excluded files. They are
+// shared by the backup and the explain-exclude command.
+func addExcludeFlags(f *pflag.FlagSet, opts *BackupOptions) {
+	opts.ExcludePatternOptions.Add(f)
+
+	f.BoolVarP(&opts.ExcludeOtherFS, "one-file-system", "x", false, "exclude other file systems, don't cross filesystem boundaries and subvolumes")
+	f.StringArrayVar(&opts.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:header]`, exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
+	f.BoolVar(&opts.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See https://bford.info/cachedir/ for the Cache Directory Tagging Standard`)
+	f.StringVar(&opts.ExcludeLargerThan, "exclude-larger-than", "", "max `size` of the files to be backed up (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.Var(&opts.ExcludeOlderThan, "exclude-older-than", "exclude files which were last modified more than `duration` (eg. 1y5m7d2h) ago")
+	f.UintSliceVar(&opts.ExcludeUIDs, "exclude-uid", nil, "exclude files owned by the user with the numeric `uid` (can be specified multiple times)")
+	f.UintSliceVar(&opts.ExcludeGIDs, "exclude-gid", nil, "exclude files owned by the group with the numeric `gid` (can be specified multiple times)")
+	f.BoolVar(&opts.ExcludeUntrackedGit, "exclude-untracked-git", false, "exclude files and directories within git working trees which are not tracked by git (requires git)")
+}
+
 // rejectResticCache returns a RejectByNameFunc that rejects the restic cache
 // directory (if set).
 func rejectResticCache(repo *repository.Repository) (archiver.RejectByNameFunc, error) {
@@ -31,3 +51,158 @@ func rejectResticCache(repo *repository.Repository) (archiver.RejectByNameFunc,
 		return false
 	}, nil
 }
+
+// rejectByNameRule is a RejectByNameFunc together with a description of the
+// option it was created from.
+type rejectByNameRule struct {
+	reject archiver.RejectByNameFunc
+	// rule describes why item was rejected, it is only called for rejected
+	// items. For patterns it includes the pattern which matched.
+	rule func(item string) string
+	// negating describes the negated pattern which includes item again, if
+	// any. It is nil for rules which do not support negation.
+	negating func(item string) string
+}
+
+// rejectRule is a RejectFunc together with a description of the option it was
+// created from.
+type rejectRule struct {
+	reject archiver.RejectFunc
+	rule   string
+}
+
+func staticRule(rule string) func(string) string {
+	return func(string) string {
+		return rule
+	}
+}
+
+// matchRejectByNameRules returns the description of the first rule which
+// rejects item, or an empty string if no rule does.
+func matchRejectByNameRules(rules []rejectByNameRule, item string) string {
+	for _, r := range rules {
+		if r.reject(item) {
+			return r.rule(item)
+		}
+	}
+	return ""
+}
+
+// matchNegatingRules returns the description of the first negated pattern
+// which includes item again, or an empty string if there is none.
+func matchNegatingRules(rules []rejectByNameRule, item string) string {
+	for _, r := range rules {
+		if r.negating == nil {
+			continue
+		}
+		if rule := r.negating(item); rule != "" {
+			return rule
+		}
+	}
+	return ""
+}
+
+// matchRejectRules returns the description of the first rule which rejects
+// item, or an empty string if no rule does.
+func matchRejectRules(rules []rejectRule, item string, fi *fs.ExtendedFileInfo, filesys fs.FS) string {
+	for _, r := range rules {
+		if r.reject(item, fi, filesys) {
+			return r.rule
+		}
+	}
+	return ""
+}
+
+func combineRejectByNameRules(rules []rejectByNameRule) archiver.SelectByNameFunc {
+	var funcs []archiver.RejectByNameFunc
+	for _, r := range rules {
+		funcs = append(funcs, r.reject)
+	}
+	return archiver.CombineRejectByNames(funcs)
+}
+
+func combineRejectRules(rules []rejectRule) archiver.SelectFunc {
+	var funcs []archiver.RejectFunc
+	for _, r := range rules {
+		funcs = append(funcs, r.reject)
+	}
+	return archiver.CombineRejects(funcs)
+}
+
+// excludedItem is a line of the file written by --log-excluded.
+type excludedItem struct {
+	Path string `json:"path"`
+	Rule string `json:"rule"`
+}
+
+// excludeLog writes each excluded item together with the rule which rejected
+// it to a file, one JSON object per line.
+type excludeLog struct {
+	m   sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+	err error
+}
+
+func newExcludeLog(filename string) (*excludeLog, error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, errors.Fatalf("unable to create exclude log: %v", err)
+	}
+	return &excludeLog{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (l *excludeLog) log(item, rule string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.err != nil {
+		return
+	}
+	l.err = l.enc.Encode(excludedItem{Path: item, Rule: rule})
+}
+
+// SelectByName returns a SelectByNameFunc which logs the items rejected by
+// one of the rules.
+func (l *excludeLog) SelectByName(rules []rejectByNameRule) archiver.SelectByNameFunc {
+	return func(item string) bool {
+		rule := matchRejectByNameRules(rules, item)
+		if rule == "" {
+			return true
+		}
+		l.log(item, rule)
+		return false
+	}
+}
+
+// Select returns a SelectFunc which logs the items rejected by one of the
+// rules.
+func (l *excludeLog) Select(rules []rejectRule) archiver.SelectFunc {
+	return func(item string, fi *fs.ExtendedFileInfo, filesys fs.FS) bool {
+		rule := matchRejectRules(rules, item, fi, filesys)
+		if rule == "" {
+			return true
+		}
+		l.log(item, rule)
+		return false
+	}
+}
+
+// Close closes the file and returns the first error which occurred while
+// writing it.
+func (l *excludeLog) Close() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.f != nil {
+		err := l.f.Close()
+		l.f = nil
+		if l.err == nil {
+			l.err = err
+		}
+	}
+	if l.err != nil {
+		return errors.Fatalf("unable to write exclude log: %v", l.err)
+	}
+	return nil
+}
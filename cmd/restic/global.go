@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -16,6 +17,7 @@ import (
 	"github.com/restic/restic/internal/backend/azure"
 	"github.com/restic/restic/internal/backend/b2"
 	"github.com/restic/restic/internal/backend/cache"
+	"github.com/restic/restic/internal/backend/gdrive"
 	"github.com/restic/restic/internal/backend/gs"
 	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/backend/local"
@@ -27,12 +29,16 @@ import (
 	"github.com/restic/restic/internal/backend/s3"
 	"github.com/restic/restic/internal/backend/sema"
 	"github.com/restic/restic/internal/backend/sftp"
+	"github.com/restic/restic/internal/backend/smb"
 	"github.com/restic/restic/internal/backend/swift"
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/metrics"
 	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/textfile"
+	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/termstatus"
 
 	"github.com/restic/restic/internal/errors"
@@ -59,30 +65,50 @@ type GlobalOptions struct {
 	RepositoryFile     string
 	PasswordFile       string
 	PasswordCommand    string
+	PasswordProvider   string
+	PasswordKeyfile    string
 	KeyHint            string
 	Quiet              bool
 	Verbose            int
 	NoLock             bool
 	RetryLock          time.Duration
+	RetryBudget        time.Duration
+	RetryMaxErrorRate  uint
 	JSON               bool
+	JSONLines          bool
 	CacheDir           string
 	NoCache            bool
+	EncryptCache       bool
 	CleanupCache       bool
+	PackCacheSize      string
 	Compression        repository.CompressionMode
 	PackSize           uint
 	NoExtraVerify      bool
 	InsecureNoPassword bool
+	PasswordRetries    uint
+	LimitFile          string
+	PriceTable         string
+	MetricsListen      string
 
 	backend.TransportOptions
 	limiter.Limits
 
 	password string
-	stdout   io.Writer
-	stderr   io.Writer
+	// passwordPrompt is set if password was entered interactively using
+	// this prompt, such that it can be asked for again if it is wrong
+	passwordPrompt string
+	stdout         io.Writer
+	stderr         io.Writer
+
+	// masterKey opens the repository without a password, see "key import"
+	masterKey *crypto.Key
 
 	backends                              *location.Registry
 	backendTestHook, backendInnerTestHook backendWrapper
 
+	// transportWrapper wraps the HTTP transport used by the backends
+	transportWrapper func(rt http.RoundTripper) http.RoundTripper
+
 	// verbosity is set as follows:
 	//  0 means: don't print any messages except errors, this is used when --quiet is specified
 	//  1 is the default: print essential messages
@@ -100,17 +126,59 @@ var globalOptions = GlobalOptions{
 	stderr: os.Stderr,
 }
 
+// jsonFlag implements the --json flag. Besides a boolean value it accepts
+// "lines", which selects JSON output with one object per line.
+type jsonFlag struct {
+	json, lines *bool
+}
+
+func (f *jsonFlag) String() string {
+	if f.json == nil {
+		return "false"
+	}
+	if *f.lines {
+		return "lines"
+	}
+	return strconv.FormatBool(*f.json)
+}
+
+func (f *jsonFlag) Set(s string) error {
+	if s == "lines" {
+		*f.json, *f.lines = true, true
+		return nil
+	}
+
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid value %q, must be a boolean or \"lines\"", s)
+	}
+	*f.json, *f.lines = v, false
+	return nil
+}
+
+func (f *jsonFlag) Type() string {
+	return "bool"
+}
+
 func init() {
 	backends := location.NewRegistry()
-	backends.Register(azure.NewFactory())
-	backends.Register(b2.NewFactory())
-	backends.Register(gs.NewFactory())
-	backends.Register(local.NewFactory())
-	backends.Register(rclone.NewFactory())
-	backends.Register(rest.NewFactory())
-	backends.Register(s3.NewFactory())
-	backends.Register(sftp.NewFactory())
-	backends.Register(swift.NewFactory())
+	for _, factory := range []location.Factory{
+		azure.NewFactory(),
+		b2.NewFactory(),
+		gdrive.NewFactory(),
+		gs.NewFactory(),
+		local.NewFactory(),
+		rclone.NewFactory(),
+		rest.NewFactory(),
+		s3.NewFactory(),
+		sftp.NewFactory(),
+		smb.NewFactory(),
+		swift.NewFactory(),
+	} {
+		backends.Register(factory)
+		// bandwidth limits can be set individually for each backend
+		options.Register(factory.Scheme(), limiter.BackendOptions{})
+	}
 	globalOptions.backends = backends
 
 	f := cmdRoot.PersistentFlags()
@@ -119,24 +187,35 @@ func init() {
 	f.StringVarP(&globalOptions.PasswordFile, "password-file", "p", "", "`file` to read the repository password from (default: $RESTIC_PASSWORD_FILE)")
 	f.StringVarP(&globalOptions.KeyHint, "key-hint", "", "", "`key` ID of key to try decrypting first (default: $RESTIC_KEY_HINT)")
 	f.StringVarP(&globalOptions.PasswordCommand, "password-command", "", "", "shell `command` to obtain the repository password from (default: $RESTIC_PASSWORD_COMMAND)")
+	f.StringVar(&globalOptions.PasswordProvider, "password-provider", "", "read the repository password from a secrets store, `provider:secret` with provider one of keychain, systemd-creds, vault or wincred (default: $RESTIC_PASSWORD_PROVIDER)")
+	f.StringVar(&globalOptions.PasswordKeyfile, "password-keyfile", "", "`file` required in addition to the password to open keys created with a keyfile (default: $RESTIC_PASSWORD_KEYFILE)")
+	f.UintVar(&globalOptions.PasswordRetries, "password-retries", 2, "ask again up to `n` times if a wrong password was entered at the interactive prompt")
 	f.BoolVarP(&globalOptions.Quiet, "quiet", "q", false, "do not output comprehensive progress report")
 	// use empty parameter name as `-v, --verbose n` instead of the correct `--verbose=n` is confusing
 	f.CountVarP(&globalOptions.Verbose, "verbose", "v", "be verbose (specify multiple times or a level using --verbose=n``, max level/times is 2)")
 	f.BoolVar(&globalOptions.NoLock, "no-lock", false, "do not lock the repository, this allows some operations on read-only repositories")
 	f.DurationVar(&globalOptions.RetryLock, "retry-lock", 0, "retry to lock the repository if it is already locked, takes a value like 5m or 2h (default: no retries)")
-	f.BoolVarP(&globalOptions.JSON, "json", "", false, "set output mode to JSON for commands that support it")
+	f.DurationVar(&globalOptions.RetryBudget, "retry-budget", 0, "stop retrying failed backend requests once no request succeeded for `duration` (default: no limit)")
+	f.UintVar(&globalOptions.RetryMaxErrorRate, "retry-max-error-rate", 0, "stop retrying failed backend requests once `percent` of the recent requests failed (default: no limit)")
+	f.Var(&jsonFlag{json: &globalOptions.JSON, lines: &globalOptions.JSONLines}, "json", "set output mode to JSON for commands that support it, use --json=lines for one JSON object per line (supported by find)")
+	f.Lookup("json").NoOptDefVal = "true"
 	f.StringVar(&globalOptions.CacheDir, "cache-dir", "", "set the cache `directory`. (default: use system default cache directory)")
 	f.BoolVar(&globalOptions.NoCache, "no-cache", false, "do not use a local cache")
+	f.BoolVar(&globalOptions.EncryptCache, "encrypt-cache", false, "store the local cache encrypted (default: $RESTIC_ENCRYPT_CACHE)")
 	f.StringSliceVar(&globalOptions.RootCertFilenames, "cacert", nil, "`file` to load root certificates from (default: use system certificates or $RESTIC_CACERT)")
 	f.StringVar(&globalOptions.TLSClientCertKeyFilename, "tls-client-cert", "", "path to a `file` containing PEM encoded TLS client certificate and private key (default: $RESTIC_TLS_CLIENT_CERT)")
 	f.BoolVar(&globalOptions.InsecureNoPassword, "insecure-no-password", false, "use an empty password for the repository, must be passed to every restic command (insecure)")
 	f.BoolVar(&globalOptions.InsecureTLS, "insecure-tls", false, "skip TLS certificate verification when connecting to the repository (insecure)")
 	f.BoolVar(&globalOptions.CleanupCache, "cleanup-cache", false, "auto remove old cache directories")
+	f.StringVar(&globalOptions.PackCacheSize, "pack-cache-size", "", "cache data pack files loaded by cat, dump and restore up to `size` (allowed suffixes: k/K, m/M, g/G, t/T) (default: $RESTIC_PACK_CACHE_SIZE)")
 	f.Var(&globalOptions.Compression, "compression", "compression mode (only available for repository format version 2), one of (auto|off|max) (default: $RESTIC_COMPRESSION)")
 	f.BoolVar(&globalOptions.NoExtraVerify, "no-extra-verify", false, "skip additional verification of data before upload (see documentation)")
 	f.IntVar(&globalOptions.Limits.UploadKb, "limit-upload", 0, "limits uploads to a maximum `rate` in KiB/s. (default: unlimited)")
 	f.IntVar(&globalOptions.Limits.DownloadKb, "limit-download", 0, "limits downloads to a maximum `rate` in KiB/s. (default: unlimited)")
+	f.StringVar(&globalOptions.LimitFile, "limit-file", "", "read bandwidth limits from `file`, reloaded on SIGUSR2 (default: $RESTIC_LIMIT_FILE)")
 	f.UintVar(&globalOptions.PackSize, "pack-size", 0, "set target pack `size` in MiB, created pack files may be larger (default: $RESTIC_PACK_SIZE)")
+	f.StringVar(&globalOptions.PriceTable, "price-table", "", "estimate the costs of prune, check and copy using the `prices` of the storage provider, for example get=0.0004,put=0.005,download=0.09 (default: $RESTIC_PRICE_TABLE)")
+	f.StringVar(&globalOptions.MetricsListen, "metrics-listen", "", "serve Prometheus metrics on `address:port`, for example :9090")
 	f.StringSliceVarP(&globalOptions.Options, "option", "o", []string{}, "set extended option (`key=value`, can be specified multiple times)")
 	f.StringVar(&globalOptions.HTTPUserAgent, "http-user-agent", "", "set a http user agent for outgoing http requests")
 	f.DurationVar(&globalOptions.StuckRequestTimeout, "stuck-request-timeout", 5*time.Minute, "`duration` after which to retry stuck requests")
@@ -148,10 +227,14 @@ func init() {
 	globalOptions.PasswordFile = os.Getenv("RESTIC_PASSWORD_FILE")
 	globalOptions.KeyHint = os.Getenv("RESTIC_KEY_HINT")
 	globalOptions.PasswordCommand = os.Getenv("RESTIC_PASSWORD_COMMAND")
+	globalOptions.PasswordProvider = os.Getenv("RESTIC_PASSWORD_PROVIDER")
+	globalOptions.PasswordKeyfile = os.Getenv("RESTIC_PASSWORD_KEYFILE")
 	if os.Getenv("RESTIC_CACERT") != "" {
 		globalOptions.RootCertFilenames = strings.Split(os.Getenv("RESTIC_CACERT"), ",")
 	}
 	globalOptions.TLSClientCertKeyFilename = os.Getenv("RESTIC_TLS_CLIENT_CERT")
+	globalOptions.LimitFile = os.Getenv("RESTIC_LIMIT_FILE")
+	globalOptions.PriceTable = os.Getenv("RESTIC_PRICE_TABLE")
 	comp := os.Getenv("RESTIC_COMPRESSION")
 	if comp != "" {
 		// ignore error as there's no good way to handle it
@@ -160,6 +243,9 @@ func init() {
 	// parse target pack size from env, on error the default value will be used
 	targetPackSize, _ := strconv.ParseUint(os.Getenv("RESTIC_PACK_SIZE"), 10, 32)
 	globalOptions.PackSize = uint(targetPackSize)
+	globalOptions.PackCacheSize = os.Getenv("RESTIC_PACK_CACHE_SIZE")
+	// on error the cache is not encrypted
+	globalOptions.EncryptCache, _ = strconv.ParseBool(os.Getenv("RESTIC_ENCRYPT_CACHE"))
 
 	if os.Getenv("RESTIC_HTTP_USER_AGENT") != "" {
 		globalOptions.HTTPUserAgent = os.Getenv("RESTIC_HTTP_USER_AGENT")
@@ -254,10 +340,16 @@ func Warnf(format string, args ...interface{}) {
 }
 
 // resolvePassword determines the password to be used for opening the repository.
-func resolvePassword(opts GlobalOptions, envStr string) (string, error) {
+func resolvePassword(ctx context.Context, opts GlobalOptions, envStr string) (string, error) {
 	if opts.PasswordFile != "" && opts.PasswordCommand != "" {
 		return "", errors.Fatalf("Password file and command are mutually exclusive options")
 	}
+	if opts.PasswordProvider != "" {
+		if opts.PasswordFile != "" || opts.PasswordCommand != "" {
+			return "", errors.Fatalf("Password provider and password file or command are mutually exclusive options")
+		}
+		return readPasswordFromProvider(ctx, opts, opts.PasswordProvider)
+	}
 	if opts.PasswordCommand != "" {
 		args, err := backend.SplitShellStrings(opts.PasswordCommand)
 		if err != nil {
@@ -292,6 +384,21 @@ func loadPasswordFromFile(pwdFile string) (string, error) {
 	return strings.TrimSpace(string(s)), errors.Wrap(err, "Readfile")
 }
 
+// loadKeyfile reads a keyfile which is used in addition to a password.
+func loadKeyfile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Fatalf("%s does not exist", filename)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadFile")
+	}
+	if len(data) == 0 {
+		return nil, errors.Fatalf("keyfile %s is empty", filename)
+	}
+	return data, nil
+}
+
 // readPassword reads the password from the given reader directly.
 func readPassword(in io.Reader) (password string, err error) {
 	sc := bufio.NewScanner(in)
@@ -433,6 +540,79 @@ func ReadRepo(opts GlobalOptions) (string, error) {
 
 const maxKeys = 20
 
+// passwordRetryBaseDelay is the delay before asking for the password again
+// after the first wrong password. It doubles after each further attempt.
+var passwordRetryBaseDelay = time.Second
+
+const maxPasswordRetryDelay = 16 * time.Second
+
+// passwordRetryDelay returns the delay before asking for the password again
+// after the given number of failed attempts. Similar to PAM, the delay slows
+// down guessing the password at the interactive prompt.
+func passwordRetryDelay(failed uint) time.Duration {
+	if failed > 16 {
+		return maxPasswordRetryDelay
+	}
+	return min(passwordRetryBaseDelay<<(failed-1), maxPasswordRetryDelay)
+}
+
+// openWithPassword reads the password and searches for a matching key. If the
+// password is entered at an interactive prompt and is wrong, it is asked for
+// again up to opts.PasswordRetries times. Passwords from all other sources
+// are only tried once.
+func openWithPassword(ctx context.Context, repo *repository.Repository, opts GlobalOptions) error {
+	prompt := opts.passwordPrompt
+	if prompt == "" && stdinIsTerminal() && opts.password == "" && !opts.InsecureNoPassword {
+		prompt = "enter password for repository: "
+	}
+
+	retries := uint(0)
+	if prompt != "" {
+		retries = opts.PasswordRetries
+	}
+	read := func() (string, error) {
+		password, err := ReadPassword(ctx, opts, prompt)
+		// ask again if the password turns out to be wrong
+		opts.password = ""
+		return password, err
+	}
+
+	return retryPassword(ctx, retries, read, func(password string) error {
+		return repo.SearchKey(ctx, password, maxKeys, opts.KeyHint)
+	})
+}
+
+// retryPassword calls tryPassword with the password returned by read. If
+// reading fails or the password is wrong, this is retried up to retries
+// times with an increasing delay.
+func retryPassword(ctx context.Context, retries uint, read func() (string, error), tryPassword func(string) error) error {
+	for failed := uint(0); ; failed++ {
+		if failed > 0 {
+			select {
+			case <-time.After(passwordRetryDelay(failed)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		password, err := read()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			err = tryPassword(password)
+			if err != nil && !errors.Is(err, repository.ErrNoKeyFound) {
+				// retrying does not help for other errors
+				return err
+			}
+		}
+		if err == nil || failed >= retries {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s. Try again\n", err)
+	}
+}
+
 // OpenRepository reads the password and opens the repository.
 func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Repository, error) {
 	repo, err := ReadRepo(opts)
@@ -446,37 +626,27 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 	}
 
 	s, err := repository.New(be, repository.Options{
-		Compression:   opts.Compression,
-		PackSize:      opts.PackSize * 1024 * 1024,
-		NoExtraVerify: opts.NoExtraVerify,
+		Compression:          opts.Compression,
+		PackSize:             opts.PackSize * 1024 * 1024,
+		NoExtraVerify:        opts.NoExtraVerify,
+		PackTransformOptions: opts.extended,
 	})
 	if err != nil {
 		return nil, errors.Fatal(err.Error())
 	}
 
-	passwordTriesLeft := 1
-	if stdinIsTerminal() && opts.password == "" && !opts.InsecureNoPassword {
-		passwordTriesLeft = 3
-	}
-
-	for ; passwordTriesLeft > 0; passwordTriesLeft-- {
-		opts.password, err = ReadPassword(ctx, opts, "enter password for repository: ")
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
-		}
-		if err != nil && passwordTriesLeft > 1 {
-			opts.password = ""
-			fmt.Printf("%s. Try again\n", err)
-		}
+	if opts.PasswordKeyfile != "" {
+		keyfile, err := loadKeyfile(opts.PasswordKeyfile)
 		if err != nil {
-			continue
+			return nil, err
 		}
+		s.UseKeyfile(keyfile)
+	}
 
-		err = s.SearchKey(ctx, opts.password, maxKeys, opts.KeyHint)
-		if err != nil && passwordTriesLeft > 1 {
-			opts.password = ""
-			fmt.Fprintf(os.Stderr, "%s. Try again\n", err)
-		}
+	if opts.masterKey != nil {
+		err = s.UseMasterKey(ctx, opts.masterKey)
+	} else {
+		err = openWithPassword(ctx, s, opts)
 	}
 	if err != nil {
 		if errors.IsFatal(err) || errors.Is(err, repository.ErrNoKeyFound) {
@@ -485,6 +655,21 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 		return nil, errors.Fatalf("%s", err)
 	}
 
+	err = repository.CheckIndexManifest(ctx, s)
+	var merr *repository.IndexManifestError
+	if errors.As(err, &merr) {
+		Warnf("Warning: %v\n"+
+			"The repository was probably copied or synchronized incompletely.\n", err)
+		if len(merr.Missing) > 0 {
+			Warnf("Missing index files: %v\n", merr.Missing)
+		}
+		if len(merr.Truncated) > 0 {
+			Warnf("Index files with wrong size: %v\n", merr.Truncated)
+		}
+	} else if err != nil {
+		Warnf("unable to verify index manifest: %v\n", err)
+	}
+
 	if stdoutIsTerminal() && !opts.JSON {
 		id := s.Config().ID
 		if len(id) > 8 {
@@ -503,7 +688,12 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 		return s, nil
 	}
 
-	c, err := cache.New(s.Config().ID, opts.CacheDir)
+	var c *cache.Cache
+	if opts.EncryptCache {
+		c, err = cache.NewEncrypted(s.Config().ID, opts.CacheDir, s.Key())
+	} else {
+		c, err = cache.New(s.Config().ID, opts.CacheDir)
+	}
 	if err != nil {
 		Warnf("unable to open cache: %v\n", err)
 		return s, nil
@@ -548,6 +738,27 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 	return s, nil
 }
 
+// enablePackCache enables the cache for data pack files if requested using
+// --pack-cache-size. This is only useful for commands which may load the same
+// data repeatedly, like restore.
+func enablePackCache(repo *repository.Repository, opts GlobalOptions) error {
+	if opts.PackCacheSize == "" {
+		return nil
+	}
+
+	size, err := ui.ParseBytes(opts.PackCacheSize)
+	if err != nil {
+		return errors.Fatalf("invalid pack cache size: %v", err)
+	}
+	if repo.Cache == nil {
+		Warnf("--pack-cache-size has no effect as the cache is disabled\n")
+		return nil
+	}
+
+	repo.Cache.EnablePackCache(size)
+	return nil
+}
+
 func parseConfig(loc location.Location, opts options.Options) (interface{}, error) {
 	cfg := loc.Config
 	if cfg, ok := cfg.(backend.ApplyEnvironmenter); ok {
@@ -571,6 +782,11 @@ func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.
 		return nil, errors.Fatalf("parsing repository location failed: %v", err)
 	}
 
+	lim, opts, err := newBackendLimiter(loc.Scheme, gopts, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg, err := parseConfig(loc, opts)
 	if err != nil {
 		return nil, err
@@ -582,8 +798,10 @@ func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.
 	}
 
 	// wrap the transport so that the throughput via HTTP is limited
-	lim := limiter.NewStaticLimiter(gopts.Limits)
 	rt = lim.Transport(rt)
+	if gopts.transportWrapper != nil {
+		rt = gopts.transportWrapper(rt)
+	}
 
 	factory := gopts.backends.Lookup(loc.Scheme)
 	if factory == nil {
@@ -604,8 +822,8 @@ func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.
 		return nil, errors.Fatalf("unable to open repository at %v: %v", location.StripPassword(gopts.backends, s), err)
 	}
 
-	// wrap with debug logging and connection limiting
-	be = logger.New(sema.NewBackend(be))
+	// wrap with debug logging, connection limiting and metrics
+	be = logger.New(sema.NewBackend(metrics.NewBackend(be)))
 
 	// wrap backend if a test specified an inner hook
 	if gopts.backendInnerTestHook != nil {
@@ -625,7 +843,11 @@ func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.
 	success := func(msg string, retries int) {
 		Warnf("%v operation successful after %d retries\n", msg, retries)
 	}
-	be = retry.New(be, 15*time.Minute, report, success)
+	rbe := retry.New(be, 15*time.Minute, report, success)
+	if gopts.RetryBudget > 0 || gopts.RetryMaxErrorRate > 0 {
+		rbe.Breaker = retry.NewBreaker(gopts.RetryBudget, float64(gopts.RetryMaxErrorRate)/100)
+	}
+	be = rbe
 
 	// wrap backend if a test specified a hook
 	if gopts.backendTestHook != nil {
@@ -647,6 +869,17 @@ func open(ctx context.Context, s string, gopts GlobalOptions, opts options.Optio
 
 	// check if config is there
 	fi, err := be.Stat(ctx, backend.Handle{Type: restic.ConfigFile})
+	if be.IsNotExist(err) {
+		// replacing the config may have been interrupted
+		recovered, rerr := repository.RecoverConfig(ctx, be)
+		if rerr != nil {
+			Warnf("unable to restore config: %v\n", rerr)
+		}
+		if recovered {
+			Warnf("restored the repository config after an interrupted update\n")
+			fi, err = be.Stat(ctx, backend.Handle{Type: restic.ConfigFile})
+		}
+	}
 	if be.IsNotExist(err) {
 		return nil, fmt.Errorf("Fatal: %w: unable to open config file: %v\nIs there a repository at the following location?\n%v", ErrNoRepository, err, location.StripPassword(gopts.backends, s))
 	}
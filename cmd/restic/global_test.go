@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -74,3 +77,68 @@ func TestReadEmptyPassword(t *testing.T) {
 	_, err = ReadPassword(context.TODO(), opts, "test")
 	rtest.Assert(t, strings.Contains(err.Error(), "must not be specified together with providing a password via a cli option or environment variable"), "unexpected error message, got %v", err)
 }
+
+func TestPasswordRetryDelay(t *testing.T) {
+	for failed, want := range map[uint]time.Duration{
+		1:   time.Second,
+		2:   2 * time.Second,
+		3:   4 * time.Second,
+		5:   16 * time.Second,
+		6:   16 * time.Second,
+		100: 16 * time.Second,
+	} {
+		rtest.Equals(t, want, passwordRetryDelay(failed), fmt.Sprintf("failed %d", failed))
+	}
+}
+
+func TestRetryPassword(t *testing.T) {
+	defer func(d time.Duration) { passwordRetryBaseDelay = d }(passwordRetryBaseDelay)
+	passwordRetryBaseDelay = 0
+
+	backendErr := errors.New("backend error")
+	for _, test := range []struct {
+		name      string
+		retries   uint
+		passwords []string
+		tryErr    error
+		reads     int
+		err       error
+	}{
+		{"correct", 2, []string{"secret"}, nil, 1, nil},
+		{"retry", 2, []string{"wrong", "wrong", "secret"}, nil, 3, nil},
+		{"exhausted", 2, []string{"wrong", "wrong", "wrong", "secret"}, nil, 3, repository.ErrNoKeyFound},
+		{"no retries", 0, []string{"wrong", "secret"}, nil, 1, repository.ErrNoKeyFound},
+		{"other error", 2, []string{"secret"}, backendErr, 1, backendErr},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			reads := 0
+			read := func() (string, error) {
+				reads++
+				return test.passwords[reads-1], nil
+			}
+			err := retryPassword(context.TODO(), test.retries, read, func(password string) error {
+				if test.tryErr != nil {
+					return test.tryErr
+				}
+				if password != "secret" {
+					return repository.ErrNoKeyFound
+				}
+				return nil
+			})
+			rtest.Assert(t, errors.Is(err, test.err), "unexpected error %v, want %v", err, test.err)
+			rtest.Equals(t, test.reads, reads)
+		})
+	}
+}
+
+func TestRetryPasswordCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := retryPassword(ctx, 2, func() (string, error) {
+		return "wrong", nil
+	}, func(string) error {
+		return repository.ErrNoKeyFound
+	})
+	rtest.Assert(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+}
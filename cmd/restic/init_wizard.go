@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// initWizard asks the questions of "init --wizard".
+type initWizard struct {
+	in  *bufio.Reader
+	out io.Writer
+	// readSecret reads a password or another secret without echoing it
+	readSecret func(prompt string) (string, error)
+	// terminal is set if the answers are entered interactively
+	terminal bool
+}
+
+func newInitWizard(ctx context.Context, in io.Reader, out io.Writer) *initWizard {
+	w := &initWizard{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+	w.readSecret = w.ask
+	if f, ok := in.(*os.File); ok && f == os.Stdin && stdinIsTerminal() {
+		w.terminal = true
+		w.readSecret = func(prompt string) (string, error) {
+			return readPasswordTerminal(ctx, os.Stdin, os.Stderr, prompt+": ")
+		}
+	}
+	return w
+}
+
+// ask prints prompt and returns the line entered by the user.
+func (w *initWizard) ask(prompt string) (string, error) {
+	_, _ = fmt.Fprintf(w.out, "%v: ", prompt)
+	line, err := w.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", errors.Fatal("unexpected end of input")
+	}
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "ReadString")
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// askDefault asks until a non-empty answer was entered, an empty answer
+// selects def if it is not empty.
+func (w *initWizard) askDefault(prompt string, def string) (string, error) {
+	if def != "" {
+		prompt = fmt.Sprintf("%v [%v]", prompt, def)
+	}
+	for {
+		answer, err := w.ask(prompt)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+		if answer != "" {
+			return answer, nil
+		}
+	}
+}
+
+// choose lists choices and returns the index of the selected one, an empty
+// answer selects the first choice.
+func (w *initWizard) choose(prompt string, choices []string) (int, error) {
+	_, _ = fmt.Fprintf(w.out, "\n%v\n", prompt)
+	for i, choice := range choices {
+		_, _ = fmt.Fprintf(w.out, "  %d) %v\n", i+1, choice)
+	}
+	for {
+		answer, err := w.askDefault("Choice", "1")
+		if err != nil {
+			return 0, err
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= 1 && n <= len(choices) {
+			return n - 1, nil
+		}
+		_, _ = fmt.Fprintf(w.out, "please enter a number between 1 and %d\n", len(choices))
+	}
+}
+
+// confirm asks a yes/no question, an empty answer selects no.
+func (w *initWizard) confirm(prompt string) (bool, error) {
+	for {
+		answer, err := w.ask(prompt + " [y/N]")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "", "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// wizardField is a value which the wizard asks for when setting up a
+// backend. Fields with env set are credentials, which are passed to the
+// backend using the environment variable env.
+type wizardField struct {
+	prompt string
+	def    string
+	env    string
+	secret bool
+}
+
+// wizardBackend describes how to set up a repository on a backend.
+type wizardBackend struct {
+	description string
+	fields      []wizardField
+	// location returns the repository location, values contains the answers
+	// for all fields without env
+	location func(values []string) (string, error)
+}
+
+var wizardBackends = []wizardBackend{
+	{
+		description: "local directory or mounted drive",
+		fields:      []wizardField{{prompt: "Directory for the repository"}},
+		location: func(v []string) (string, error) {
+			return filepath.Abs(v[0])
+		},
+	},
+	{
+		description: "SFTP server",
+		fields: []wizardField{
+			{prompt: "Server, for example user@host"},
+			{prompt: "Directory for the repository on the server"},
+		},
+		location: func(v []string) (string, error) {
+			return "sftp:" + v[0] + ":" + v[1], nil
+		},
+	},
+	{
+		description: "REST server",
+		fields:      []wizardField{{prompt: "Server URL", def: "http://localhost:8000/"}},
+		location: func(v []string) (string, error) {
+			return "rest:" + v[0], nil
+		},
+	},
+	{
+		description: "Amazon S3 or a compatible service",
+		fields: []wizardField{
+			{prompt: "Endpoint", def: "s3.amazonaws.com"},
+			{prompt: "Bucket"},
+			{prompt: "Directory within the bucket", def: "restic"},
+			{prompt: "Access key ID", env: "AWS_ACCESS_KEY_ID"},
+			{prompt: "Secret access key", env: "AWS_SECRET_ACCESS_KEY", secret: true},
+		},
+		location: func(v []string) (string, error) {
+			return "s3:" + path.Join(v[0], v[1], v[2]), nil
+		},
+	},
+	{
+		description: "Backblaze B2",
+		fields: []wizardField{
+			{prompt: "Bucket"},
+			{prompt: "Directory within the bucket", def: "restic"},
+			{prompt: "Application key ID", env: "B2_ACCOUNT_ID"},
+			{prompt: "Application key", env: "B2_ACCOUNT_KEY", secret: true},
+		},
+		location: func(v []string) (string, error) {
+			return "b2:" + v[0] + ":" + path.Join("/", v[1]), nil
+		},
+	},
+	{
+		description: "Azure Blob Storage",
+		fields: []wizardField{
+			{prompt: "Container"},
+			{prompt: "Directory within the container", def: "restic"},
+			{prompt: "Account name", env: "AZURE_ACCOUNT_NAME"},
+			{prompt: "Account key", env: "AZURE_ACCOUNT_KEY", secret: true},
+		},
+		location: func(v []string) (string, error) {
+			return "azure:" + v[0] + ":" + path.Join("/", v[1]), nil
+		},
+	},
+	{
+		description: "Google Cloud Storage",
+		fields: []wizardField{
+			{prompt: "Bucket"},
+			{prompt: "Directory within the bucket", def: "restic"},
+			{prompt: "Project ID", env: "GOOGLE_PROJECT_ID"},
+			{prompt: "Service account credentials file", env: "GOOGLE_APPLICATION_CREDENTIALS"},
+		},
+		location: func(v []string) (string, error) {
+			return "gs:" + v[0] + ":" + path.Join("/", v[1]), nil
+		},
+	},
+}
+
+// wizardEnv is an environment variable written to the configuration file.
+type wizardEnv struct {
+	name  string
+	value string
+}
+
+// initWizardResult contains the answers collected by the wizard.
+type initWizardResult struct {
+	Repo        string
+	Credentials []wizardEnv
+	Password    string
+	// PasswordProvider is the provider:secret the password is stored in, if
+	// it is empty the password is stored in PasswordFile
+	PasswordProvider string
+	PasswordFile     string
+	Compression      string
+	PackSize         uint
+	BackupPath       string
+	ConfigFile       string
+}
+
+// localPasswordProvider returns the provider of the operating system which
+// can store passwords, if there is one.
+func localPasswordProvider() (name string, description string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "keychain", "macOS keychain"
+	case "windows":
+		return "wincred", "Windows Credential Manager"
+	}
+	return "", ""
+}
+
+// run asks all questions of the wizard.
+func (w *initWizard) run() (*initWizardResult, error) {
+	res := &initWizardResult{}
+
+	var descriptions []string
+	for _, be := range wizardBackends {
+		descriptions = append(descriptions, be.description)
+	}
+	n, err := w.choose("Where do you want to store the repository?", descriptions)
+	if err != nil {
+		return nil, err
+	}
+	be := wizardBackends[n]
+
+	var values []string
+	for _, field := range be.fields {
+		var answer string
+		if field.secret {
+			answer, err = w.readSecret(field.prompt)
+			if err == nil && answer == "" {
+				err = errors.Fatalf("%v must not be empty", strings.ToLower(field.prompt))
+			}
+		} else {
+			answer, err = w.askDefault(field.prompt, field.def)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if field.env != "" {
+			res.Credentials = append(res.Credentials, wizardEnv{name: field.env, value: answer})
+		} else {
+			values = append(values, answer)
+		}
+	}
+	res.Repo, err = be.location(values)
+	if err != nil {
+		return nil, errors.Fatalf("invalid repository location: %v", err)
+	}
+
+	res.Password, err = w.readSecret("Enter a password for the new repository")
+	if err != nil {
+		return nil, err
+	}
+	if res.Password == "" {
+		return nil, errors.Fatal("an empty password is not allowed")
+	}
+	if w.terminal {
+		again, err := w.readSecret("Enter the password again")
+		if err != nil {
+			return nil, err
+		}
+		if again != res.Password {
+			return nil, errors.Fatal("passwords do not match")
+		}
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	} else {
+		dir = filepath.Join(dir, "restic")
+	}
+	res.ConfigFile, err = w.askDefault("\nSave the configuration to", filepath.Join(dir, "default.env"))
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(res.ConfigFile), filepath.Ext(res.ConfigFile))
+
+	// prefer the password store of the system, there is nothing to choose if
+	// there is none
+	useStore := false
+	provider, description := localPasswordProvider()
+	if provider != "" {
+		n, err = w.choose("Where should the password be stored?", []string{
+			"in the " + description + " (recommended)",
+			"in a file which only you can read",
+		})
+		if err != nil {
+			return nil, err
+		}
+		useStore = n == 0
+	}
+	if useStore {
+		res.PasswordProvider = provider + ":restic-" + name
+	} else {
+		res.PasswordFile = strings.TrimSuffix(res.ConfigFile, filepath.Ext(res.ConfigFile)) + ".password"
+	}
+
+	compressed, err := w.confirm("\nDo you mostly back up photos, videos, music or other already compressed files?")
+	if err != nil {
+		return nil, err
+	}
+	if compressed {
+		res.Compression = "off"
+	} else {
+		small, err := w.confirm("Do you prefer a smaller repository over faster backups?")
+		if err != nil {
+			return nil, err
+		}
+		if small {
+			res.Compression = "max"
+		}
+	}
+
+	n, err = w.choose("How much data do you expect to back up?", []string{
+		"less than 100 GiB",
+		"between 100 GiB and 1 TiB",
+		"more than 1 TiB",
+	})
+	if err != nil {
+		return nil, err
+	}
+	// larger pack files reduce the number of files in large repositories
+	res.PackSize = []uint{0, 32, 64}[n]
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = ""
+	}
+	res.BackupPath, err = w.askDefault("\nDirectory to back up", home)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// apply configures gopts to create the repository chosen in the wizard.
+func (res *initWizardResult) apply(gopts GlobalOptions) (GlobalOptions, error) {
+	gopts.Repo = res.Repo
+	gopts.password = res.Password
+	if res.Compression != "" {
+		if err := gopts.Compression.Set(res.Compression); err != nil {
+			return gopts, err
+		}
+	}
+	if res.PackSize != 0 {
+		gopts.PackSize = res.PackSize
+	}
+	// the backends read their credentials from the environment
+	for _, env := range res.Credentials {
+		if err := os.Setenv(env.name, env.value); err != nil {
+			return gopts, errors.WithStack(err)
+		}
+	}
+	return gopts, nil
+}
+
+// save stores the password and writes the configuration file.
+func (res *initWizardResult) save(ctx context.Context, gopts GlobalOptions) error {
+	if err := os.MkdirAll(filepath.Dir(res.ConfigFile), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	vars := []wizardEnv{{"RESTIC_REPOSITORY", res.Repo}}
+	if res.PasswordProvider != "" {
+		name, secret, _ := strings.Cut(res.PasswordProvider, ":")
+		p, err := newPasswordProvider(name, gopts)
+		if err != nil {
+			return err
+		}
+		store, ok := p.(passwordStore)
+		if !ok {
+			return errors.Fatalf("password provider %v cannot store passwords", name)
+		}
+		if err := store.StorePassword(ctx, secret, res.Password); err != nil {
+			return errors.Fatalf("storing the password failed: %v", err)
+		}
+		vars = append(vars, wizardEnv{"RESTIC_PASSWORD_PROVIDER", res.PasswordProvider})
+	} else {
+		if err := writePrivateFile(res.PasswordFile, res.Password+"\n"); err != nil {
+			return err
+		}
+		vars = append(vars, wizardEnv{"RESTIC_PASSWORD_FILE", res.PasswordFile})
+	}
+	if res.Compression != "" {
+		vars = append(vars, wizardEnv{"RESTIC_COMPRESSION", res.Compression})
+	}
+	if res.PackSize != 0 {
+		vars = append(vars, wizardEnv{"RESTIC_PACK_SIZE", strconv.FormatUint(uint64(res.PackSize), 10)})
+	}
+	vars = append(vars, res.Credentials...)
+
+	var buf strings.Builder
+	buf.WriteString("# restic configuration created by \"restic init --wizard\"\n")
+	for _, v := range vars {
+		fmt.Fprintf(&buf, "%v=%v\n", v.name, shellQuote(v.value))
+	}
+	// the file contains the credentials of the backend
+	if err := writePrivateFile(res.ConfigFile, buf.String()); err != nil {
+		return err
+	}
+
+	Printf("\nsaved the configuration to %v\n", res.ConfigFile)
+	if len(res.Credentials) > 0 {
+		Warnf("Warning: %v contains the credentials of the storage service in plaintext, anyone who can read it can access the repository data\n", res.ConfigFile)
+	}
+	if runtime.GOOS == "windows" {
+		Printf("set the environment variables listed in this file, then create a backup using:\n\n")
+	} else {
+		Printf("create a backup using:\n\n")
+		Printf("    set -a; . %v; set +a\n", shellQuote(res.ConfigFile))
+	}
+	Printf("    restic backup %v\n", shellQuote(res.BackupPath))
+	return nil
+}
+
+// writePrivateFile writes data to filename, which is only readable by the
+// current user. The permissions of an existing file are restricted as well.
+func writePrivateFile(filename string, data string) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// the mode passed to OpenFile is only used for new files
+	if err := f.Chmod(0600); err != nil && runtime.GOOS != "windows" {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	if _, err := f.WriteString(data); err != nil {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+// shellQuote quotes s for a POSIX shell, if necessary.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:@+=,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -65,7 +65,8 @@ func newOrderedListOnceBackend(be backend.Backend) *listOnceBackend {
 }
 
 func (be *listOnceBackend) List(ctx context.Context, t restic.FileType, fn func(backend.FileInfo) error) error {
-	if t != restic.LockFile && be.listedFileType[t] {
+	// state files are already listed when opening the repository
+	if t != restic.LockFile && t != restic.StateFile && be.listedFileType[t] {
 		return errors.Errorf("tried listing type %v the second time", t)
 	}
 	if be.strictOrder && t == restic.SnapshotFile && be.listedFileType[restic.IndexFile] {
@@ -75,6 +76,10 @@ func (be *listOnceBackend) List(ctx context.Context, t restic.FileType, fn func(
 	return be.Backend.List(ctx, t, fn)
 }
 
+func (be *listOnceBackend) Unwrap() backend.Backend {
+	return be.Backend
+}
+
 func TestListOnce(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
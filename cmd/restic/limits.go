@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// backendLimit tracks the rate limiter of an opened backend such that its
+// limits can be reloaded from the limit file while restic is running.
+type backendLimit struct {
+	scheme    string
+	limits    limiter.Limits
+	opts      options.Options
+	limitFile string
+	lim       *limiter.DynamicLimiter
+}
+
+var activeLimits struct {
+	sync.Mutex
+	list []backendLimit
+	once sync.Once
+}
+
+// newBackendLimiter returns the rate limiter for a backend with the given
+// scheme. The limits are taken from --limit-upload and --limit-download,
+// the backend specific extended options and the limit file, in increasing
+// order of precedence. The returned options no longer contain the limit
+// options.
+func newBackendLimiter(scheme string, gopts GlobalOptions, opts options.Options) (*limiter.DynamicLimiter, options.Options, error) {
+	bl := backendLimit{
+		scheme:    scheme,
+		limits:    gopts.Limits,
+		opts:      opts,
+		limitFile: gopts.LimitFile,
+	}
+
+	limits, rest, err := limiter.ApplyBackendOptions(scheme, opts, gopts.Limits)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bl.limitFile != "" {
+		fileOpts, err := readLimitFile(bl.limitFile)
+		if err != nil {
+			return nil, nil, errors.Fatalf("%v", err)
+		}
+		limits, err = bl.resolve(fileOpts)
+		if err != nil {
+			return nil, nil, errors.Fatalf("%v", err)
+		}
+	}
+
+	bl.lim = limiter.NewDynamicLimiter(limits)
+	debug.Log("limits for %v backend: %+v", scheme, limits)
+
+	if bl.limitFile != "" {
+		activeLimits.Lock()
+		activeLimits.list = append(activeLimits.list, bl)
+		activeLimits.Unlock()
+
+		activeLimits.once.Do(func() {
+			ch := make(chan os.Signal, 1)
+			notifyLimitReload(ch)
+			go func() {
+				for range ch {
+					reloadLimits()
+				}
+			}()
+		})
+	}
+
+	return bl.lim, rest, nil
+}
+
+// resolve computes the limits of the backend after applying the options from
+// the limit file on top of the command line options.
+func (bl backendLimit) resolve(fileOpts options.Options) (limiter.Limits, error) {
+	global := bl.limits
+	var err error
+	if v, ok := fileOpts["limit-upload"]; ok {
+		global.UploadKb, err = limiter.ParseRate(v)
+		if err != nil {
+			return limiter.Limits{}, fmt.Errorf("invalid limit-upload in %v: %w", bl.limitFile, err)
+		}
+	}
+	if v, ok := fileOpts["limit-download"]; ok {
+		global.DownloadKb, err = limiter.ParseRate(v)
+		if err != nil {
+			return limiter.Limits{}, fmt.Errorf("invalid limit-download in %v: %w", bl.limitFile, err)
+		}
+	}
+
+	merged := make(options.Options)
+	for k, v := range bl.opts {
+		merged[k] = v
+	}
+	for k, v := range fileOpts {
+		if strings.Contains(k, ".") {
+			merged[k] = v
+		}
+	}
+
+	limits, _, err := limiter.ApplyBackendOptions(bl.scheme, merged, global)
+	return limits, err
+}
+
+// reloadLimits reads the limit file again and updates the limits of all
+// backends.
+func reloadLimits() {
+	activeLimits.Lock()
+	defer activeLimits.Unlock()
+
+	for _, bl := range activeLimits.list {
+		fileOpts, err := readLimitFile(bl.limitFile)
+		if err != nil {
+			Warnf("unable to reload limits: %v\n", err)
+			return
+		}
+		limits, err := bl.resolve(fileOpts)
+		if err != nil {
+			Warnf("unable to reload limits: %v\n", err)
+			return
+		}
+
+		bl.lim.SetLimits(limits)
+		debug.Log("new limits for %v backend: %+v", bl.scheme, limits)
+	}
+	Warnf("reloaded bandwidth limits from %v\n", activeLimits.list[0].limitFile)
+}
+
+// readLimitFile parses a limit file. Each line contains an option in the form
+// `limit-upload=1M`, `s3.limit-download=5M`. Empty lines and lines starting
+// with `#` are ignored.
+func readLimitFile(filename string) (options.Options, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read limit file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, _, _ := strings.Cut(line, "=")
+		if name != "limit-upload" && name != "limit-download" &&
+			!strings.HasSuffix(name, ".limit-upload") && !strings.HasSuffix(name, ".limit-download") {
+			return nil, fmt.Errorf("invalid option %q in limit file %v", name, filename)
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read limit file: %w", err)
+	}
+
+	return options.Parse(lines)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/options"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestLimitFile(t *testing.T) {
+	limitFile := filepath.Join(t.TempDir(), "limits")
+	rtest.OK(t, os.WriteFile(limitFile, []byte("# work hours\nlimit-upload=1M\n\ns3.limit-download=5M\n"), 0o600))
+
+	gopts := GlobalOptions{
+		Limits:    limiter.Limits{UploadKb: 10, DownloadKb: 20},
+		LimitFile: limitFile,
+	}
+	opts := options.Options{"s3.limit-download": "100", "s3.limit-upload": "200", "s3.connections": "2"}
+
+	lim, rest, err := newBackendLimiter("s3", gopts, opts)
+	rtest.OK(t, err)
+	rtest.Equals(t, options.Options{"s3.connections": "2"}, rest)
+	// the backend specific option from the command line takes precedence over
+	// the global limit from the file
+	rtest.Equals(t, limiter.Limits{UploadKb: 200, DownloadKb: 5 * 1024}, lim.Limits())
+
+	lim, _, err = newBackendLimiter("local", gopts, opts)
+	rtest.OK(t, err)
+	rtest.Equals(t, limiter.Limits{UploadKb: 1024, DownloadKb: 20}, lim.Limits())
+
+	rtest.OK(t, os.WriteFile(limitFile, []byte("limit-upload=0\nlocal.limit-download=1\n"), 0o600))
+	reloadLimits()
+	rtest.Equals(t, limiter.Limits{UploadKb: 0, DownloadKb: 1}, lim.Limits())
+
+	rtest.OK(t, os.WriteFile(limitFile, []byte("connections=5\n"), 0o600))
+	_, err = readLimitFile(limitFile)
+	rtest.Assert(t, err != nil, "expected error for invalid option")
+}
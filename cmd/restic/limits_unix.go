@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyLimitReload relays SIGUSR2 to ch, which triggers a reload of the
+// limit file.
+func notifyLimitReload(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGUSR2)
+}
@@ -0,0 +1,7 @@
+package main
+
+import "os"
+
+// notifyLimitReload does nothing, as Windows has no signal to trigger a
+// reload of the limit file.
+func notifyLimitReload(_ chan<- os.Signal) {}
@@ -14,9 +14,11 @@ import (
 	"github.com/spf13/cobra"
 	"go.uber.org/automaxprocs/maxprocs"
 
+	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/logging"
 	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
@@ -29,6 +31,13 @@ func init() {
 
 var ErrOK = errors.New("ok")
 
+// isPolicyError returns true if the storage service refused an operation
+// because of its access policy.
+func isPolicyError(err error) bool {
+	var perr *backend.PolicyError
+	return errors.As(err, &perr)
+}
+
 // cmdRoot is the base command when no other command has been specified.
 var cmdRoot = &cobra.Command{
 	Use:   "restic",
@@ -49,6 +58,9 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 		if globalOptions.Quiet && globalOptions.Verbose > 0 {
 			return errors.Fatal("--quiet and --verbose cannot be specified at the same time")
 		}
+		if globalOptions.RetryMaxErrorRate > 100 {
+			return errors.Fatal("--retry-max-error-rate must be a percentage between 0 and 100")
+		}
 
 		switch {
 		case globalOptions.Verbose >= 2:
@@ -65,10 +77,15 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 			return err
 		}
 		globalOptions.extended = opts
+		if globalOptions.MetricsListen != "" {
+			if err := startMetricsServer(globalOptions.MetricsListen, c.Name()); err != nil {
+				return err
+			}
+		}
 		if !needsPassword(c.Name()) {
 			return nil
 		}
-		pwd, err := resolvePassword(globalOptions, "RESTIC_PASSWORD")
+		pwd, err := resolvePassword(c.Context(), globalOptions, "RESTIC_PASSWORD")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Resolving password failed: %v\n", err)
 			Exit(1)
@@ -120,18 +137,66 @@ func tweakGoGC() {
 	}
 }
 
-func printExitError(code int, message string) {
+// jsonBackendError describes the backend operation which caused an error.
+type jsonBackendError struct {
+	Operation string  `json:"operation"`
+	FileType  string  `json:"file_type"`
+	FileName  string  `json:"file_name,omitempty"`
+	Offset    int64   `json:"offset,omitempty"`
+	Length    int     `json:"length,omitempty"`
+	Attempts  int     `json:"attempts"`
+	Elapsed   float64 `json:"elapsed_seconds"`
+
+	CircuitBreaker *jsonCircuitBreaker `json:"circuit_breaker,omitempty"`
+}
+
+// jsonCircuitBreaker describes why restic stopped retrying backend requests.
+type jsonCircuitBreaker struct {
+	Reason      string  `json:"reason"`
+	FailureTime float64 `json:"failing_seconds"`
+	ErrorRate   float64 `json:"error_rate"`
+}
+
+func newJSONBackendError(err error) *jsonBackendError {
+	var operr *backend.OperationError
+	if !errors.As(err, &operr) {
+		return nil
+	}
+	jerr := &jsonBackendError{
+		Operation: operr.Op,
+		FileType:  operr.Handle.Type.String(),
+		FileName:  operr.Handle.Name,
+		Offset:    operr.Offset,
+		Length:    operr.Length,
+		Attempts:  operr.Attempts,
+		Elapsed:   operr.Elapsed.Seconds(),
+	}
+
+	var cerr *backend.CircuitOpenError
+	if errors.As(err, &cerr) {
+		jerr.CircuitBreaker = &jsonCircuitBreaker{
+			Reason:      cerr.Reason,
+			FailureTime: cerr.FailureTime.Seconds(),
+			ErrorRate:   cerr.ErrorRate,
+		}
+	}
+	return jerr
+}
+
+func printExitError(code int, message string, err error) {
 	if globalOptions.JSON {
 		type jsonExitError struct {
-			MessageType string `json:"message_type"` // exit_error
-			Code        int    `json:"code"`
-			Message     string `json:"message"`
+			MessageType  string            `json:"message_type"` // exit_error
+			Code         int               `json:"code"`
+			Message      string            `json:"message"`
+			BackendError *jsonBackendError `json:"backend_error,omitempty"`
 		}
 
 		jsonS := jsonExitError{
-			MessageType: "exit_error",
-			Code:        code,
-			Message:     message,
+			MessageType:  "exit_error",
+			Code:         code,
+			Message:      message,
+			BackendError: newJSONBackendError(err),
 		}
 
 		err := json.NewEncoder(globalOptions.stderr).Encode(jsonS)
@@ -159,6 +224,16 @@ func main() {
 		Exit(1)
 	}
 
+	// the log file is not buffered and thus needs no explicit close
+	logOpts, err := logging.OptionsFromEnv()
+	if err == nil {
+		_, err = logging.Setup(logOpts)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		Exit(1)
+	}
+
 	debug.Log("main %#v", os.Args)
 	debug.Log("restic %s compiled with %v on %v/%v",
 		version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
@@ -177,8 +252,12 @@ func main() {
 	switch {
 	case restic.IsAlreadyLocked(err):
 		exitMessage = fmt.Sprintf("%v\nthe `unlock` command can be used to remove stale locks", err)
-	case err == ErrInvalidSourceData:
+	case err == ErrInvalidSourceData, err == ErrBackupInterrupted:
 		exitMessage = fmt.Sprintf("Warning: %v", err)
+	case err == ErrBelowChangeThreshold, err == ErrFilterMismatch:
+		exitMessage = err.Error()
+	case isPolicyError(err):
+		exitMessage = fmt.Sprintf("Fatal: %v\nthe server refused to modify the repository. If it is append-only, commands that remove data such as `forget` and `prune` must be run against a server without the append-only restriction", err)
 	case errors.IsFatal(err):
 		exitMessage = err.Error()
 	case errors.Is(err, repository.ErrNoKeyFound):
@@ -195,26 +274,36 @@ func main() {
 		}
 	}
 
-	var exitCode int
+	exitCode := exitCodeFor(err)
+	if exitCode != 0 {
+		printExitError(exitCode, exitMessage, err)
+	}
+	Exit(exitCode)
+}
+
+// exitCodeFor returns the exit status of restic for err, see the EXIT STATUS
+// section of the command help.
+func exitCodeFor(err error) int {
 	switch {
 	case err == nil:
-		exitCode = 0
+		return 0
 	case err == ErrInvalidSourceData:
-		exitCode = 3
+		return 3
+	case err == ErrBelowChangeThreshold:
+		return 4
+	case err == ErrFilterMismatch:
+		return 5
 	case errors.Is(err, ErrNoRepository):
-		exitCode = 10
+		return 10
 	case restic.IsAlreadyLocked(err):
-		exitCode = 11
+		return 11
 	case errors.Is(err, repository.ErrNoKeyFound):
-		exitCode = 12
-	case errors.Is(err, context.Canceled):
-		exitCode = 130
+		return 12
+	case isPolicyError(err):
+		return 13
+	case err == ErrBackupInterrupted, errors.Is(err, context.Canceled):
+		return 130
 	default:
-		exitCode = 1
+		return 1
 	}
-
-	if exitCode != 0 {
-		printExitError(exitCode, exitMessage)
-	}
-	Exit(exitCode)
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/metrics"
+)
+
+var resticInfo = metrics.NewGaugeVec("restic_info", "Version of restic and the running command.", "version", "command")
+
+// startMetricsServer serves the Prometheus metrics at /metrics on addr until
+// restic exits.
+func startMetricsServer(addr string, command string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Fatalf("unable to serve metrics: %v", err)
+	}
+	debug.Log("serving metrics on %v", l.Addr())
+	resticInfo.With(version, command).Set(1)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.DefaultRegistry.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		err := srv.Serve(l)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			Warnf("metrics server failed: %v\n", err)
+		}
+	}()
+	return nil
+}
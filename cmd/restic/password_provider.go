@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// passwordProvider obtains the repository password from a secrets store.
+type passwordProvider interface {
+	// Password returns the password stored under the name secret. The format
+	// of secret depends on the provider.
+	Password(ctx context.Context, secret string) (string, error)
+}
+
+// passwordStore is implemented by providers which can also store a password,
+// this is used by "init --wizard".
+type passwordStore interface {
+	// StorePassword stores password under the name secret, replacing an
+	// existing password.
+	StorePassword(ctx context.Context, secret string, password string) error
+}
+
+// passwordProviderNames lists the providers supported by --password-provider.
+var passwordProviderNames = []string{"keychain", "systemd-creds", "vault", "wincred"}
+
+func newPasswordProvider(name string, opts GlobalOptions) (passwordProvider, error) {
+	switch name {
+	case "keychain":
+		return keychainProvider{}, nil
+	case "systemd-creds":
+		return systemdCredsProvider{}, nil
+	case "vault":
+		rt, err := backend.Transport(opts.TransportOptions)
+		if err != nil {
+			return nil, err
+		}
+		return vaultProvider{client: &http.Client{Transport: rt}}, nil
+	case "wincred":
+		return wincredProvider{}, nil
+	}
+	return nil, errors.Fatalf("unknown password provider %q, valid providers are: %v", name, strings.Join(passwordProviderNames, ", "))
+}
+
+// readPasswordFromProvider resolves a password specification of the form
+// "provider:secret".
+func readPasswordFromProvider(ctx context.Context, opts GlobalOptions, spec string) (string, error) {
+	name, secret, _ := strings.Cut(spec, ":")
+	p, err := newPasswordProvider(name, opts)
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", errors.Fatalf("password provider %v requires the name of a secret, for example %v:restic", name, name)
+	}
+
+	debug.Log("reading password %q from provider %v", secret, name)
+	pwd, err := p.Password(ctx, secret)
+	if err != nil {
+		return "", errors.Fatalf("password provider %v: %v", name, err)
+	}
+	if pwd == "" {
+		return "", errors.Fatalf("password provider %v returned an empty password for %q", name, secret)
+	}
+	return pwd, nil
+}
+
+// systemdCredsProvider reads a credential passed to a systemd service using
+// LoadCredential= or LoadCredentialEncrypted=. systemd decrypts the credential
+// before the service starts.
+type systemdCredsProvider struct{}
+
+func (systemdCredsProvider) Password(_ context.Context, secret string) (string, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", errors.New("$CREDENTIALS_DIRECTORY is not set, restic must be started by a systemd unit using LoadCredential= or LoadCredentialEncrypted=")
+	}
+	if strings.ContainsAny(secret, `/\`) {
+		return "", errors.Errorf("invalid credential name %q", secret)
+	}
+	return loadPasswordFromFile(filepath.Join(dir, secret))
+}
+
+// keychainProvider reads a generic password from the macOS keychain. The
+// secret is either "service" or "service/account".
+type keychainProvider struct{}
+
+func (keychainProvider) Password(ctx context.Context, secret string) (string, error) {
+	if runtime.GOOS != "darwin" {
+		return "", errors.New("the keychain is only supported on macOS")
+	}
+
+	service, account, _ := strings.Cut(secret, "/")
+	args := []string{"find-generic-password", "-s", service, "-w"}
+	if account != "" {
+		args = append(args, "-a", account)
+	}
+	cmd := exec.CommandContext(ctx, "security", args...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (keychainProvider) StorePassword(ctx context.Context, secret string, password string) error {
+	if runtime.GOOS != "darwin" {
+		return errors.New("the keychain is only supported on macOS")
+	}
+
+	if strings.ContainsAny(password, "\r\n") {
+		return errors.New("the keychain cannot store passwords containing line breaks")
+	}
+
+	service, account, _ := strings.Cut(secret, "/")
+	args := []string{"add-generic-password", "-U", "-s", service}
+	if account != "" {
+		args = append(args, "-a", account)
+	}
+	// Passing the password as the value of -w would expose it to other users
+	// via the process list. Without a value, -w must be the last option and
+	// the tool reads the password and its confirmation from stdin.
+	args = append(args, "-w")
+	cmd := exec.CommandContext(ctx, "security", args...)
+	cmd.Stdin = strings.NewReader(password + "\n" + password + "\n")
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// vaultProvider reads a secret from HashiCorp Vault using the token in
+// $VAULT_TOKEN or ~/.vault-token. The secret is "path#field", the field
+// defaults to "password". Both the KV version 1 and 2 secrets engines are
+// supported, for version 2 the path must include "data/", for example
+// "secret/data/restic".
+type vaultProvider struct {
+	client *http.Client
+}
+
+func (p vaultProvider) Password(ctx context.Context, secret string) (string, error) {
+	path, field, _ := strings.Cut(secret, "#")
+	if field == "" {
+		field = "password"
+	}
+
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		addr = "https://127.0.0.1:8200"
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return "", err
+	}
+
+	url := strings.TrimRight(addr, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return "", errors.Errorf("reading %v failed: %v", path, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.Errorf("decoding response failed: %v", err)
+	}
+
+	data := body.Data
+	// the KV version 2 engine nests the secret in a second data object
+	if raw, ok := data["data"]; ok && data["metadata"] != nil {
+		data = nil
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return "", errors.Errorf("decoding response failed: %v", err)
+		}
+	}
+
+	raw, ok := data[field]
+	if !ok {
+		return "", errors.Errorf("secret %v has no field %q", path, field)
+	}
+	var pwd string
+	if err := json.Unmarshal(raw, &pwd); err != nil {
+		return "", errors.Errorf("field %q of secret %v is not a string", field, path)
+	}
+	return pwd, nil
+}
+
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("$VAULT_TOKEN is not set")
+	}
+	buf, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errors.New("$VAULT_TOKEN is not set and ~/.vault-token does not exist")
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading token failed: %w", err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
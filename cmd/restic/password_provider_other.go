@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// wincredProvider reads a generic credential from the Windows Credential
+// Manager.
+type wincredProvider struct{}
+
+func (wincredProvider) Password(_ context.Context, _ string) (string, error) {
+	return "", errors.New("the Windows Credential Manager is only supported on Windows")
+}
+
+func (wincredProvider) StorePassword(_ context.Context, _ string, _ string) error {
+	return errors.New("the Windows Credential Manager is only supported on Windows")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestPasswordProviderSystemdCreds(t *testing.T) {
+	dir := rtest.TempDir(t)
+	rtest.OK(t, os.WriteFile(filepath.Join(dir, "restic"), []byte("secret\n"), 0600))
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	pwd, err := readPasswordFromProvider(context.TODO(), GlobalOptions{}, "systemd-creds:restic")
+	rtest.OK(t, err)
+	rtest.Equals(t, "secret", pwd)
+
+	_, err = readPasswordFromProvider(context.TODO(), GlobalOptions{}, "systemd-creds:missing")
+	rtest.Assert(t, err != nil, "missing credential did not return an error")
+	_, err = readPasswordFromProvider(context.TODO(), GlobalOptions{}, "systemd-creds:../restic")
+	rtest.Assert(t, err != nil, "credential outside of the credentials directory was accepted")
+}
+
+func TestPasswordProviderVault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/restic":
+			_, _ = w.Write([]byte(`{"data":{"password":"v1secret","other":"other"}}`))
+		case "/v1/secret/data/restic":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"v2secret"},"metadata":{"version":1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	for _, test := range []struct {
+		spec     string
+		password string
+	}{
+		{"vault:kv/restic", "v1secret"},
+		{"vault:kv/restic#other", "other"},
+		{"vault:secret/data/restic", "v2secret"},
+	} {
+		pwd, err := readPasswordFromProvider(context.TODO(), GlobalOptions{}, test.spec)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.password, pwd)
+	}
+
+	for _, spec := range []string{"vault:kv/missing", "vault:kv/restic#missing"} {
+		_, err := readPasswordFromProvider(context.TODO(), GlobalOptions{}, spec)
+		rtest.Assert(t, err != nil, "%v did not return an error", spec)
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	_, err := readPasswordFromProvider(context.TODO(), GlobalOptions{}, "vault:kv/restic")
+	rtest.Assert(t, err != nil, "wrong token did not return an error")
+}
+
+func TestPasswordProviderInvalid(t *testing.T) {
+	for _, spec := range []string{"unknown:restic", "vault", "vault:"} {
+		_, err := readPasswordFromProvider(context.TODO(), GlobalOptions{}, spec)
+		rtest.Assert(t, err != nil, "%v did not return an error", spec)
+	}
+
+	_, err := resolvePassword(context.TODO(), GlobalOptions{PasswordProvider: "vault:kv/restic", PasswordFile: "file"}, "RESTIC_PASSWORD")
+	rtest.Assert(t, err != nil, "password provider and file were accepted together")
+}
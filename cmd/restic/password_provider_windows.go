@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"syscall"
+	"unsafe"
+
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modadvapi32    = windows.NewLazySystemDLL("advapi32.dll")
+	procCredReadW  = modadvapi32.NewProc("CredReadW")
+	procCredWriteW = modadvapi32.NewProc("CredWriteW")
+	procCredFree   = modadvapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+// credential is the CREDENTIALW structure used by CredReadW and CredWriteW.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// wincredProvider reads a generic credential from the Windows Credential
+// Manager, the secret is the target name of the credential.
+type wincredProvider struct{}
+
+func (wincredProvider) Password(_ context.Context, secret string) (string, error) {
+	target, err := windows.UTF16PtrFromString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return "", errors.Errorf("credential %q not found", secret)
+		}
+		return "", errors.Errorf("CredRead: %v", err)
+	}
+	defer func() {
+		_, _, _ = procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	}()
+
+	// the Credential Manager stores passwords as UTF-16
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	pwd := make([]uint16, len(blob)/2)
+	for i := range pwd {
+		pwd[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+	}
+	return syscall.UTF16ToString(pwd), nil
+}
+
+func (wincredProvider) StorePassword(_ context.Context, secret string, password string) error {
+	target, err := windows.UTF16PtrFromString(secret)
+	if err != nil {
+		return err
+	}
+	pwd, err := windows.UTF16FromString(password)
+	if err != nil {
+		return err
+	}
+
+	// store the password as UTF-16 without the terminating null character
+	blob := make([]byte, 0, 2*len(pwd))
+	for _, c := range pwd[:len(pwd)-1] {
+		blob = append(blob, byte(c), byte(c>>8))
+	}
+	cred := credential{
+		Type:       credTypeGeneric,
+		TargetName: target,
+		Persist:    credPersistLocalMachine,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlobSize = uint32(len(blob))
+		cred.CredentialBlob = &blob[0]
+	}
+
+	r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return errors.Errorf("CredWrite: %v", err)
+	}
+	return nil
+}
@@ -110,6 +110,15 @@ func newIndexTerminalProgress(quiet bool, json bool, term *termstatus.Terminal)
 	return newTerminalProgressMax(!quiet && !json && stdoutIsTerminal(), 0, "index files loaded", term)
 }
 
+// newIndexPrinterProgress returns the progress for loading the index. With
+// --json, it is reported using printer.
+func newIndexPrinterProgress(gopts GlobalOptions, printer progress.Printer, term *termstatus.Terminal) *progress.Counter {
+	if gopts.JSON {
+		return printer.NewCounter("index files loaded")
+	}
+	return newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+}
+
 type terminalProgressPrinter struct {
 	term *termstatus.Terminal
 	ui.Message
@@ -120,6 +129,15 @@ func (t *terminalProgressPrinter) NewCounter(description string) *progress.Count
 	return newTerminalProgressMax(t.show, 0, description, t.term)
 }
 
+// newProgressPrinter returns a printer which emits JSON messages if json is
+// set and prints to the terminal otherwise.
+func newProgressPrinter(json bool, verbosity uint, term *termstatus.Terminal) progress.Printer {
+	if json {
+		return progress.NewJSONPrinter(term, verbosity, calculateProgressInterval(verbosity > 0, true))
+	}
+	return newTerminalProgressPrinter(verbosity, term)
+}
+
 func newTerminalProgressPrinter(verbosity uint, term *termstatus.Terminal) progress.Printer {
 	return &terminalProgressPrinter{
 		term:    term,
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// restoreErrorManifest collects the files which could not be fully restored.
+// It is safe for concurrent use.
+type restoreErrorManifest struct {
+	m     sync.Mutex
+	files map[string]*restoreErrorFile
+}
+
+type restoreErrorFile struct {
+	Path          string             `json:"path"`
+	Errors        []string           `json:"errors"`
+	MissingRanges []restoreByteRange `json:"missing_ranges,omitempty"`
+}
+
+// restoreByteRange is the range of bytes from Start up to, but not including,
+// End.
+type restoreByteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+type restoreErrorManifestJSON struct {
+	SnapshotID string             `json:"snapshot_id"`
+	Target     string             `json:"target"`
+	Files      []restoreErrorFile `json:"files"`
+}
+
+func newRestoreErrorManifest() *restoreErrorManifest {
+	return &restoreErrorManifest{files: make(map[string]*restoreErrorFile)}
+}
+
+func (m *restoreErrorManifest) file(location string) *restoreErrorFile {
+	f, ok := m.files[location]
+	if !ok {
+		f = &restoreErrorFile{Path: location, Errors: []string{}}
+		m.files[location] = f
+	}
+	return f
+}
+
+// Error records an error for the item at location.
+func (m *restoreErrorManifest) Error(location string, err error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	f := m.file(location)
+	f.Errors = append(f.Errors, err.Error())
+}
+
+// MissingRange records that length bytes starting at offset are missing in
+// the file at location.
+func (m *restoreErrorManifest) MissingRange(location string, offset, length int64) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	f := m.file(location)
+	f.MissingRanges = append(f.MissingRanges, restoreByteRange{Start: offset, End: offset + length})
+}
+
+// Files returns the recorded files sorted by path. Overlapping and adjacent
+// missing ranges are merged.
+func (m *restoreErrorManifest) Files() []restoreErrorFile {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	files := make([]restoreErrorFile, 0, len(m.files))
+	for _, f := range m.files {
+		files = append(files, restoreErrorFile{
+			Path:          f.Path,
+			Errors:        f.Errors,
+			MissingRanges: mergeByteRanges(f.MissingRanges),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+func mergeByteRanges(ranges []restoreByteRange) []restoreByteRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sorted := make([]restoreByteRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	merged := sorted[:1]
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// WriteFile saves the manifest as JSON to filename.
+func (m *restoreErrorManifest) WriteFile(filename string, snapshotID string, target string) error {
+	data, err := json.MarshalIndent(restoreErrorManifestJSON{
+		SnapshotID: snapshotID,
+		Target:     target,
+		Files:      m.Files(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filename, append(data, '\n'), 0600)
+	if err != nil {
+		return errors.Fatalf("unable to write error manifest: %v", err)
+	}
+	return nil
+}
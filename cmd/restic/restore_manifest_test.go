@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestMergeByteRanges(t *testing.T) {
+	rtest.Equals(t, []restoreByteRange(nil), mergeByteRanges(nil))
+	rtest.Equals(t, []restoreByteRange{{0, 30}, {40, 50}}, mergeByteRanges([]restoreByteRange{
+		{40, 50},
+		{10, 20},
+		{0, 10},
+		{15, 30},
+		{42, 45},
+	}))
+}
+
+func TestRestoreErrorManifestFile(t *testing.T) {
+	m := newRestoreErrorManifest()
+	m.Error("/dir/b", errors.New("load failed"))
+	m.MissingRange("/dir/b", 100, 50)
+	m.MissingRange("/dir/b", 0, 100)
+	m.Error("/a", errors.New("permission denied"))
+
+	filename := filepath.Join(rtest.TempDir(t), "manifest.json")
+	rtest.OK(t, m.WriteFile(filename, "1234", "/target"))
+
+	data, err := os.ReadFile(filename)
+	rtest.OK(t, err)
+	var manifest restoreErrorManifestJSON
+	rtest.OK(t, json.Unmarshal(data, &manifest))
+
+	rtest.Equals(t, restoreErrorManifestJSON{
+		SnapshotID: "1234",
+		Target:     "/target",
+		Files: []restoreErrorFile{
+			{Path: "/a", Errors: []string{"permission denied"}},
+			{Path: "/dir/b", Errors: []string{"load failed"}, MissingRanges: []restoreByteRange{{0, 150}}},
+		},
+	}, manifest)
+}
@@ -75,6 +75,8 @@ func fillSecondaryGlobalOpts(ctx context.Context, opts secondaryRepoOptions, gop
 
 	var err error
 	dstGopts := gopts
+	// the password provider only applies to the main repository
+	dstGopts.PasswordProvider = ""
 	var pwdEnv string
 
 	if hasFromRepo {
@@ -110,12 +112,16 @@ func fillSecondaryGlobalOpts(ctx context.Context, opts secondaryRepoOptions, gop
 	if opts.password != "" {
 		dstGopts.password = opts.password
 	} else {
-		dstGopts.password, err = resolvePassword(dstGopts, pwdEnv)
+		dstGopts.password, err = resolvePassword(ctx, dstGopts, pwdEnv)
 		if err != nil {
 			return GlobalOptions{}, false, err
 		}
 	}
-	dstGopts.password, err = ReadPassword(ctx, dstGopts, "enter password for "+repoPrefix+" repository: ")
+	prompt := "enter password for " + repoPrefix + " repository: "
+	if dstGopts.password == "" && !dstGopts.InsecureNoPassword && stdinIsTerminal() {
+		dstGopts.passwordPrompt = prompt
+	}
+	dstGopts.password, err = ReadPassword(ctx, dstGopts, prompt)
 	if err != nil {
 		return GlobalOptions{}, false, err
 	}
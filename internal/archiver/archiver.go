@@ -9,12 +9,14 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/logging"
 	"github.com/restic/restic/internal/restic"
 	"golang.org/x/sync/errgroup"
 )
@@ -54,6 +56,74 @@ type Summary struct {
 	Files, Dirs    ChangeStats
 	ProcessedBytes uint64
 	ItemStats
+
+	// CaseCollisions contains the items whose name only differs in case from
+	// the name of another item in the same directory. These items overwrite
+	// each other when restored to a case-insensitive file system. At most
+	// maxCaseCollisions items are listed, NumCaseCollisions is the total
+	// number of such items.
+	CaseCollisions    []string
+	NumCaseCollisions uint
+
+	// Interrupted is set if the backup was stopped using Interrupt, the
+	// snapshot then only contains the items processed so far.
+	Interrupted bool
+
+	// ProcessedBlobs is the number of data blobs referenced by the processed
+	// files, including blobs which were already stored in the repository.
+	ProcessedBlobs uint64
+
+	// RemovedItems is the number of files and directories of the parent
+	// snapshot which are no longer contained in the backup. It is only
+	// counted if requested by SnapshotOptions, see CountRemoved. For the
+	// change thresholds alone, counting stops once they are reached.
+	RemovedItems uint
+	// ChangedBytes is the size of all new, changed and removed files. Removed
+	// files are included to the same extent as in RemovedItems.
+	ChangedBytes uint64
+	// BelowChangeThreshold is set if no snapshot was created because the
+	// changes did not reach the thresholds set in SnapshotOptions.
+	BelowChangeThreshold bool
+
+	// SpaceRemaining is the space which is still available for the
+	// repository after the backup, nil if unknown. It is not set by the
+	// archiver but by the caller.
+	SpaceRemaining *uint64
+}
+
+// ChangedFiles returns the number of new and changed files and of removed
+// files and directories.
+func (s *Summary) ChangedFiles() uint {
+	return s.Files.New + s.Files.Changed + s.RemovedItems
+}
+
+// DataBlobsReused returns the number of data blob references of the processed
+// files which did not have to be added to the repository. This includes blobs
+// which occur multiple times within the backup.
+func (s *Summary) DataBlobsReused() uint64 {
+	if s.ProcessedBlobs < uint64(s.DataBlobs) {
+		return 0
+	}
+	return s.ProcessedBlobs - uint64(s.DataBlobs)
+}
+
+// DedupRatio returns the size of the processed files divided by the size of
+// the new data. It returns zero if no new data was added.
+func (s *Summary) DedupRatio() float64 {
+	if s.DataSize == 0 {
+		return 0
+	}
+	return float64(s.ProcessedBytes) / float64(s.DataSize)
+}
+
+// CompressionRatio returns the size of the new data and metadata divided by
+// the size stored in the repository. It returns zero if nothing was added.
+func (s *Summary) CompressionRatio() float64 {
+	stored := s.DataSizeInRepo + s.TreeSizeInRepo
+	if stored == 0 {
+		return 0
+	}
+	return float64(s.DataSize+s.TreeSize) / float64(stored)
 }
 
 // Add adds other to the current ItemStats.
@@ -81,6 +151,8 @@ type archiverRepo interface {
 	Flush(ctx context.Context) error
 }
 
+var logger = logging.Logger("archiver")
+
 // Archiver saves a directory structure to the repo.
 //
 // An Archiver has a number of worker goroutines handling saving the different
@@ -88,8 +160,10 @@ type archiverRepo interface {
 // fileSaver, blobSaver, and treeSaver types.
 //
 // The main goroutine (the one calling Snapshot()) traverses the directory tree
-// and delegates all work to these worker pools. They return a futureNode which
-// can be resolved later, by calling Wait() on it.
+// and delegates all work to these worker pools. Entries of a directory are
+// inspected by a bounded number of additional scan goroutines, which allows
+// walking sibling directories concurrently. All of them return a futureNode
+// which can be resolved later, by calling Wait() on it.
 type Archiver struct {
 	Repo         archiverRepo
 	SelectByName SelectByNameFunc
@@ -100,8 +174,14 @@ type Archiver struct {
 	blobSaver *blobSaver
 	fileSaver *fileSaver
 	treeSaver *treeSaver
+	scanSem   chan struct{}
 	mu        sync.Mutex
 	summary   *Summary
+	// snapshotOpts are the options of the running snapshot, they control
+	// whether removed items are counted.
+	snapshotOpts SnapshotOptions
+
+	interrupted atomic.Bool
 
 	// Error is called for all errors that occur during backup.
 	Error ErrorFunc
@@ -154,6 +234,15 @@ type Options struct {
 	// SaveTreeConcurrency sets how many trees are marshalled and saved to the
 	// repo concurrently.
 	SaveTreeConcurrency uint
+
+	// ScanConcurrency sets how many directory entries are inspected
+	// concurrently while walking the directory tree. If it's set to zero,
+	// the default is the number of CPUs available in the system.
+	ScanConcurrency uint
+
+	// ChunkerProfile selects the chunk sizes for each file. If it's nil,
+	// DefaultChunkerParams are used for all files.
+	ChunkerProfile ChunkerProfile
 }
 
 // ApplyDefaults returns a copy of o with the default options set for all unset
@@ -181,6 +270,12 @@ func (o Options) ApplyDefaults() Options {
 		o.SaveTreeConcurrency = uint(runtime.GOMAXPROCS(0)) + o.ReadConcurrency
 	}
 
+	if o.ScanConcurrency == 0 {
+		// scanning is mostly latency bound due to lstat and readdir calls,
+		// which benefit from running several of them in parallel
+		o.ScanConcurrency = uint(runtime.GOMAXPROCS(0))
+	}
+
 	return o
 }
 
@@ -201,6 +296,17 @@ func New(repo archiverRepo, filesystem fs.FS, opts Options) *Archiver {
 	return arch
 }
 
+// PartialSnapshotTag is added to snapshots of interrupted backups.
+const PartialSnapshotTag = "partial"
+
+// Interrupt stops a running backup gracefully. Files and directories which
+// have not been started yet are skipped, the items currently being processed
+// are completed and saved as a partial snapshot. Interrupt can be called
+// concurrently with Snapshot.
+func (arch *Archiver) Interrupt() {
+	arch.interrupted.Store(true)
+}
+
 // error calls arch.Error if it is set and the error is different from context.Canceled.
 func (arch *Archiver) error(item string, err error) error {
 	if arch.Error == nil || err == nil {
@@ -215,6 +321,7 @@ func (arch *Archiver) error(item string, err error) error {
 	if !strings.Contains(err.Error(), item) {
 		err = fmt.Errorf("%v: %w", item, err)
 	}
+	logger.Warn("error while saving item", "path", item, "err", err)
 
 	errf := arch.Error(item, err)
 	if err != errf {
@@ -233,6 +340,9 @@ func (arch *Archiver) trackItem(item string, previous, current *restic.Node, s I
 
 	if current != nil {
 		arch.summary.ProcessedBytes += current.Size
+		if current.Type == restic.NodeTypeFile {
+			arch.summary.ProcessedBlobs += uint64(len(current.Content))
+		}
 	} else {
 		// last item or an error occurred
 		return
@@ -253,14 +363,102 @@ func (arch *Archiver) trackItem(item string, previous, current *restic.Node, s I
 		switch {
 		case previous == nil:
 			arch.summary.Files.New++
+			arch.summary.ChangedBytes += current.Size
 		case previous.Equals(*current):
 			arch.summary.Files.Unchanged++
 		default:
 			arch.summary.Files.Changed++
+			arch.summary.ChangedBytes += current.Size
 		}
 	}
 }
 
+// trackRemoved counts the nodes of the previous tree which are not contained
+// in included as removed. For a removed directory, all items below it are
+// counted as well. Nothing is counted unless requested by the snapshot
+// options, as this requires loading all removed trees.
+func (arch *Archiver) trackRemoved(ctx context.Context, previous *restic.Tree, included map[string]struct{}) {
+	if previous == nil || !arch.snapshotOpts.countsRemoved() {
+		return
+	}
+
+	for _, node := range previous.Nodes {
+		if _, ok := included[node.Name]; ok {
+			continue
+		}
+		if !arch.countRemoved(ctx, node) {
+			return
+		}
+	}
+}
+
+// countRemoved counts node and all items in its subtree as removed. It returns
+// false once the remaining removed items need not be counted.
+func (arch *Archiver) countRemoved(ctx context.Context, node *restic.Node) bool {
+	var size uint64
+	if node.Type == restic.NodeTypeFile {
+		size = node.Size
+	}
+	if !arch.addRemoved(size) {
+		return false
+	}
+	if node.Type != restic.NodeTypeDir || node.Subtree == nil {
+		return true
+	}
+
+	tree, err := restic.LoadTree(ctx, arch.Repo, *node.Subtree)
+	if err != nil {
+		// only affects the statistics, the tree is not part of the new snapshot
+		debug.Log("unable to load removed tree %v: %v", node.Subtree.Str(), err)
+		return true
+	}
+
+	for _, child := range tree.Nodes {
+		if !arch.countRemoved(ctx, child) {
+			return false
+		}
+	}
+	return true
+}
+
+// addRemoved counts a removed item of the given size. It returns false if the
+// removed items are only counted for the change thresholds and those are
+// already reached.
+func (arch *Archiver) addRemoved(size uint64) bool {
+	arch.mu.Lock()
+	defer arch.mu.Unlock()
+	arch.summary.RemovedItems++
+	arch.summary.ChangedBytes += size
+	return arch.snapshotOpts.CountRemoved || arch.snapshotOpts.belowChangeThreshold(arch.summary)
+}
+
+// maxCaseCollisions is the maximum number of items listed in
+// Summary.CaseCollisions.
+const maxCaseCollisions = 100
+
+// checkCaseCollision records the item name in the directory snPath as case
+// collision if its name only differs in case from a name in folded. folded
+// contains the case-folded names of the items checked before in the same
+// directory and is updated to include name.
+func (arch *Archiver) checkCaseCollision(folded map[string]struct{}, snPath, name string) {
+	key := fs.FoldCase(name)
+	if _, ok := folded[key]; !ok {
+		folded[key] = struct{}{}
+		return
+	}
+
+	item := join(snPath, name)
+	debug.Log("%v collides with another item on case-insensitive file systems", item)
+
+	arch.mu.Lock()
+	defer arch.mu.Unlock()
+
+	arch.summary.NumCaseCollisions++
+	if len(arch.summary.CaseCollisions) < maxCaseCollisions {
+		arch.summary.CaseCollisions = append(arch.summary.CaseCollisions, item)
+	}
+}
+
 // nodeFromFileInfo returns the restic node from an os.FileInfo.
 func (arch *Archiver) nodeFromFileInfo(snPath, filename string, meta ToNoder, ignoreXattrListError bool) (*restic.Node, error) {
 	node, err := meta.ToNode(ignoreXattrListError)
@@ -321,9 +519,16 @@ func (arch *Archiver) saveDir(ctx context.Context, snPath string, dir string, me
 		return futureNode{}, err
 	}
 
-	nodes := make([]futureNode, 0, len(names))
+	// Entries are inspected concurrently if a scan worker is available. The
+	// results are stored by index such that the order of the nodes in the
+	// tree does not depend on the order in which the entries were processed.
+	results := make([]saveResult, len(names))
+	var wg sync.WaitGroup
+	// wait for all spawned goroutines before returning, as these may still
+	// queue work for the file and tree savers
+	defer wg.Wait()
 
-	for _, name := range names {
+	for i, name := range names {
 		// test if context has been cancelled
 		if ctx.Err() != nil {
 			debug.Log("context has been cancelled, aborting")
@@ -333,11 +538,39 @@ func (arch *Archiver) saveDir(ctx context.Context, snPath string, dir string, me
 		pathname := arch.FS.Join(dir, name)
 		oldNode := previous.Find(name)
 		snItem := join(snPath, name)
-		fn, excluded, err := arch.save(ctx, snItem, pathname, oldNode)
+
+		if arch.tryAcquireScanWorker() {
+			wg.Add(1)
+			go func(res *saveResult) {
+				defer wg.Done()
+				defer arch.releaseScanWorker()
+				res.fn, res.excluded, res.err = arch.save(ctx, snItem, pathname, oldNode)
+			}(&results[i])
+			continue
+		}
+
+		res := &results[i]
+		res.fn, res.excluded, res.err = arch.save(ctx, snItem, pathname, oldNode)
 
 		// return error early if possible
-		if err != nil {
-			err = arch.error(pathname, err)
+		if res.err != nil {
+			res.err = arch.error(pathname, res.err)
+			if res.err != nil {
+				return futureNode{}, res.err
+			}
+			// ignore error
+			res.excluded = true
+		}
+	}
+
+	wg.Wait()
+
+	nodes := make([]futureNode, 0, len(names))
+	folded := make(map[string]struct{}, len(names))
+	included := make(map[string]struct{}, len(names))
+	for i, res := range results {
+		if res.err != nil {
+			err = arch.error(arch.FS.Join(dir, names[i]), res.err)
 			if err == nil {
 				// ignore error
 				continue
@@ -346,18 +579,44 @@ func (arch *Archiver) saveDir(ctx context.Context, snPath string, dir string, me
 			return futureNode{}, err
 		}
 
-		if excluded {
+		if res.excluded {
 			continue
 		}
 
-		nodes = append(nodes, fn)
+		arch.checkCaseCollision(folded, snPath, names[i])
+		included[names[i]] = struct{}{}
+		nodes = append(nodes, res.fn)
 	}
+	arch.trackRemoved(ctx, previous, included)
 
 	fn := arch.treeSaver.Save(ctx, snPath, dir, treeNode, nodes, complete)
 
 	return fn, nil
 }
 
+// saveResult holds the return values of Archiver.save.
+type saveResult struct {
+	fn       futureNode
+	excluded bool
+	err      error
+}
+
+// tryAcquireScanWorker returns true if an additional goroutine may be started
+// to inspect a directory entry. It never blocks, such that a directory which
+// is processed by a scan worker can always make progress on its own.
+func (arch *Archiver) tryAcquireScanWorker() bool {
+	select {
+	case arch.scanSem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (arch *Archiver) releaseScanWorker() {
+	<-arch.scanSem
+}
+
 func (arch *Archiver) dirToNodeAndEntries(snPath, dir string, meta fs.File) (node *restic.Node, names []string, err error) {
 	err = meta.MakeReadable()
 	if err != nil {
@@ -470,9 +729,14 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 		}
 		return err
 	}
+	if arch.interrupted.Load() {
+		debug.Log("%v is skipped, backup was interrupted", target)
+		return futureNode{}, true, nil
+	}
+
 	// exclude files by path before running Lstat to reduce number of lstat calls
 	if !arch.SelectByName(abstarget) {
-		debug.Log("%v is excluded by path", target)
+		logger.Debug("item excluded by path", "path", target)
 		return futureNode{}, true, nil
 	}
 
@@ -500,7 +764,7 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 		return filterError(filterNotExist(err))
 	}
 	if !arch.Select(abstarget, fi, arch.FS) {
-		debug.Log("%v is excluded", target)
+		logger.Debug("item excluded", "path", target)
 		return futureNode{}, true, nil
 	}
 
@@ -672,6 +936,8 @@ func (arch *Archiver) saveTree(ctx context.Context, snPath string, atree *tree,
 	debug.Log("%v (%v nodes), parent %v", snPath, len(atree.Nodes), previous)
 	nodeNames := atree.NodeNames()
 	nodes := make([]futureNode, 0, len(nodeNames))
+	folded := make(map[string]struct{}, len(nodeNames))
+	included := make(map[string]struct{}, len(nodeNames))
 
 	// iterate over the nodes of atree in lexicographic (=deterministic) order
 	for _, name := range nodeNames {
@@ -700,6 +966,8 @@ func (arch *Archiver) saveTree(ctx context.Context, snPath string, atree *tree,
 			}
 
 			if !excluded {
+				arch.checkCaseCollision(folded, snPath, name)
+				included[name] = struct{}{}
 				nodes = append(nodes, fn)
 			}
 			continue
@@ -724,8 +992,11 @@ func (arch *Archiver) saveTree(ctx context.Context, snPath string, atree *tree,
 		if err != nil {
 			return futureNode{}, 0, err
 		}
+		arch.checkCaseCollision(folded, snPath, name)
+		included[name] = struct{}{}
 		nodes = append(nodes, fn)
 	}
+	arch.trackRemoved(ctx, previous, included)
 
 	fn := arch.treeSaver.Save(ctx, snPath, atree.FileInfoPath, node, nodes, complete)
 	return fn, len(nodes), nil
@@ -800,8 +1071,53 @@ type SnapshotOptions struct {
 	Time           time.Time
 	ParentSnapshot *restic.Snapshot
 	ProgramVersion string
-	// SkipIfUnchanged omits the snapshot creation if it is identical to the parent snapshot.
+	// SkipIfUnchanged omits the snapshot creation if it is identical to the
+	// latest snapshot, see restic.Snapshot.IsIdentical.
 	SkipIfUnchanged bool
+	// LatestSnapshot is the latest snapshot of the snapshot group, it is used
+	// by SkipIfUnchanged. If it is nil, ParentSnapshot is used instead.
+	LatestSnapshot *restic.Snapshot
+	// MinChangeFiles and MinChangeBytes omit the snapshot creation if neither
+	// the number of changed files nor their size compared to the parent
+	// snapshot reach the respective threshold, see Summary.ChangedFiles and
+	// Summary.ChangedBytes. A threshold of zero is ignored. Without a parent
+	// snapshot, the snapshot is always created.
+	MinChangeFiles uint
+	MinChangeBytes uint64
+	// CountRemoved counts all items of the parent snapshot which are no
+	// longer contained in the backup, see Summary.RemovedItems. Otherwise,
+	// removed items are only counted as far as required by MinChangeFiles
+	// and MinChangeBytes.
+	CountRemoved bool
+	// Supersedes lists the chain of partial snapshots which a complete
+	// snapshot supersedes. It is ignored if the backup is interrupted.
+	Supersedes restic.IDs
+}
+
+// hasChangeThreshold returns true if the snapshot creation depends on the
+// number or size of the changes.
+func (opts *SnapshotOptions) hasChangeThreshold() bool {
+	return opts.ParentSnapshot != nil && (opts.MinChangeFiles > 0 || opts.MinChangeBytes > 0)
+}
+
+// countsRemoved returns true if the removed items must be counted.
+func (opts *SnapshotOptions) countsRemoved() bool {
+	return opts.CountRemoved || opts.hasChangeThreshold()
+}
+
+// belowChangeThreshold returns true if the changes in s do not reach the
+// thresholds.
+func (opts *SnapshotOptions) belowChangeThreshold(s *Summary) bool {
+	if !opts.hasChangeThreshold() {
+		return false
+	}
+	if opts.MinChangeFiles > 0 && s.ChangedFiles() >= opts.MinChangeFiles {
+		return false
+	}
+	if opts.MinChangeBytes > 0 && s.ChangedBytes >= opts.MinChangeBytes {
+		return false
+	}
+	return true
 }
 
 // loadParentTree loads a tree referenced by snapshot id. If id is null, nil is returned.
@@ -835,8 +1151,17 @@ func (arch *Archiver) runWorkers(ctx context.Context, wg *errgroup.Group) {
 		arch.Options.ReadConcurrency, arch.Options.SaveBlobConcurrency)
 	arch.fileSaver.CompleteBlob = arch.CompleteBlob
 	arch.fileSaver.NodeFromFileInfo = arch.nodeFromFileInfo
+	arch.fileSaver.ChunkerProfile = arch.Options.ChunkerProfile
 
 	arch.treeSaver = newTreeSaver(ctx, wg, arch.Options.SaveTreeConcurrency, arch.blobSaver.Save, arch.Error)
+
+	// the calling goroutine also scans, thus only start additional workers
+	// if more than one is requested
+	if arch.Options.ScanConcurrency > 1 {
+		arch.scanSem = make(chan struct{}, arch.Options.ScanConcurrency-1)
+	} else {
+		arch.scanSem = nil
+	}
 }
 
 func (arch *Archiver) stopWorkers() {
@@ -853,7 +1178,9 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	arch.summary = &Summary{
 		BackupStart: opts.BackupStart,
 	}
+	arch.snapshotOpts = opts
 
+	logger.Info("starting backup", "targets", targets)
 	cleanTargets, err := resolveRelativeTargets(arch.FS, targets)
 	if err != nil {
 		return nil, restic.ID{}, nil, err
@@ -916,25 +1243,43 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	if err != nil {
 		return nil, restic.ID{}, nil, err
 	}
+	arch.summary.Interrupted = arch.interrupted.Load()
+
+	sn, err := restic.NewSnapshot(targets, opts.Tags, opts.Hostname, opts.Time)
+	if err != nil {
+		return nil, restic.ID{}, nil, err
+	}
+	sn.Tree = &rootTreeID
 
-	if opts.ParentSnapshot != nil && opts.SkipIfUnchanged {
-		ps := opts.ParentSnapshot
-		if ps.Tree != nil && rootTreeID.Equal(*ps.Tree) {
+	if opts.SkipIfUnchanged && !arch.summary.Interrupted {
+		latest := opts.LatestSnapshot
+		if latest == nil {
+			latest = opts.ParentSnapshot
+		}
+		if latest != nil && sn.IsIdentical(latest) {
 			return nil, restic.ID{}, arch.summary, nil
 		}
 	}
 
-	sn, err := restic.NewSnapshot(targets, opts.Tags, opts.Hostname, opts.Time)
-	if err != nil {
-		return nil, restic.ID{}, nil, err
+	if !arch.summary.Interrupted && opts.belowChangeThreshold(arch.summary) {
+		logger.Info("changes below threshold, skipping snapshot", "files_changed", arch.summary.ChangedFiles(),
+			"bytes_changed", arch.summary.ChangedBytes)
+		arch.summary.BelowChangeThreshold = true
+		arch.summary.BackupEnd = time.Now()
+		return nil, restic.ID{}, arch.summary, nil
 	}
 
 	sn.ProgramVersion = opts.ProgramVersion
 	sn.Excludes = opts.Excludes
+	if arch.summary.Interrupted {
+		sn.AddTags([]string{PartialSnapshotTag})
+		sn.Partial = true
+	} else {
+		sn.Supersedes = opts.Supersedes
+	}
 	if opts.ParentSnapshot != nil {
 		sn.Parent = opts.ParentSnapshot.ID()
 	}
-	sn.Tree = &rootTreeID
 	arch.summary.BackupEnd = time.Now()
 	sn.Summary = &restic.SnapshotSummary{
 		BackupStart: arch.summary.BackupStart,
@@ -958,6 +1303,9 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	if err != nil {
 		return nil, restic.ID{}, nil, err
 	}
+	logger.Info("snapshot saved", "id", id.Str(), "files_new", sn.Summary.FilesNew,
+		"files_changed", sn.Summary.FilesChanged, "data_added", sn.Summary.DataAdded,
+		"duration", arch.summary.BackupEnd.Sub(arch.summary.BackupStart))
 
 	return sn, id, arch.summary, nil
 }
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -1648,6 +1649,82 @@ func TestArchiverSnapshotSelect(t *testing.T) {
 	}
 }
 
+func TestArchiverDedupStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	data := string(rtest.Random(888, 2*1024*1024+5000))
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"file": TestFile{Content: data},
+		"copy": TestFile{Content: data},
+	})
+
+	arch := New(repo, fs.Track{FS: fs.Local{}}, Options{})
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	_, _, summary, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+	rtest.Equals(t, 3, summary.DataBlobs)
+	rtest.Equals(t, uint64(6), summary.ProcessedBlobs)
+	rtest.Equals(t, uint64(3), summary.DataBlobsReused())
+	rtest.Equals(t, 2.0, summary.DedupRatio())
+	rtest.Assert(t, summary.CompressionRatio() > 0, "missing compression ratio")
+
+	// all blobs are known for the second backup
+	_, _, summary, err = arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, summary.DataBlobs)
+	rtest.Equals(t, uint64(6), summary.DataBlobsReused())
+	rtest.Equals(t, 0.0, summary.DedupRatio())
+}
+
+func TestArchiverInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"a": TestFile{Content: "file a"},
+		"b": TestFile{Content: "file b"},
+		"c": TestFile{Content: "file c"},
+	})
+
+	arch := New(repo, fs.Track{FS: fs.Local{}}, Options{})
+	arch.Select = func(item string, _ *fs.ExtendedFileInfo, _ fs.FS) bool {
+		// targets are processed in order, interrupt while saving "b"
+		if filepath.Base(item) == "b" {
+			arch.Interrupt()
+		}
+		return true
+	}
+
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	sn, snapshotID, summary, err := arch.Snapshot(ctx, []string{"a", "b", "c"}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+	rtest.Assert(t, summary.Interrupted, "summary is not marked as interrupted")
+	rtest.Equals(t, []string{PartialSnapshotTag}, sn.Tags)
+	rtest.Assert(t, sn.Partial, "snapshot is not marked as partial")
+
+	TestEnsureSnapshot(t, repo, snapshotID, TestDir{
+		"a": TestFile{Content: "file a"},
+		"b": TestFile{Content: "file b"},
+	})
+
+	// a complete backup records the partial snapshots it supersedes
+	arch = New(repo, fs.Track{FS: fs.Local{}}, Options{})
+	sn, _, summary, err = arch.Snapshot(ctx, []string{"a", "b", "c"}, SnapshotOptions{
+		Time:           time.Now(),
+		ParentSnapshot: sn,
+		Supersedes:     restic.IDs{snapshotID},
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, !summary.Interrupted && !sn.Partial, "snapshot is marked as partial")
+	rtest.Equals(t, restic.IDs{snapshotID}, sn.Supersedes)
+	checker.TestCheckRepo(t, repo, false)
+}
+
 // MockFS keeps track which files are read.
 type MockFS struct {
 	fs.FS
@@ -1846,6 +1923,129 @@ func TestArchiverParent(t *testing.T) {
 	}
 }
 
+func TestArchiverChangeThreshold(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"a": TestFile{Content: string(rtest.Random(1, 100))},
+		"b": TestFile{Content: string(rtest.Random(2, 200))},
+		"dir": TestDir{
+			"c": TestFile{Content: string(rtest.Random(3, 300))},
+		},
+		"removed": TestDir{
+			"d": TestFile{Content: string(rtest.Random(5, 1000))},
+			"sub": TestDir{
+				"e": TestFile{Content: string(rtest.Random(6, 2000))},
+			},
+		},
+	})
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	arch := New(repo, fs.Track{FS: fs.Local{}}, Options{})
+	parent, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), MinChangeFiles: 100})
+	rtest.OK(t, err)
+	rtest.Assert(t, parent != nil, "snapshot without parent was skipped")
+
+	rtest.OK(t, os.RemoveAll(filepath.Join(tempdir, "removed")))
+
+	// removed items are not counted without a threshold
+	_, _, summary, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: parent})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(0), summary.RemovedItems)
+	rtest.Equals(t, uint64(0), summary.ChangedBytes)
+
+	// counting stops once the threshold is reached
+	_, _, summary, err = arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: parent, MinChangeFiles: 2})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(2), summary.RemovedItems)
+	rtest.Assert(t, !summary.BelowChangeThreshold, "snapshot was skipped")
+
+	// removing a directory counts all items and bytes below it
+	parent, _, summary, err = arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: parent, CountRemoved: true})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(4), summary.RemovedItems)
+	rtest.Equals(t, uint64(3000), summary.ChangedBytes)
+
+	// one changed file of 150 bytes and one removed file of 300 bytes
+	save(t, filepath.Join(tempdir, "a"), rtest.Random(4, 150))
+	remove(t, filepath.Join(tempdir, "dir", "c"))
+
+	for _, test := range []struct {
+		minFiles uint
+		minBytes uint64
+		skipped  bool
+	}{
+		{2, 0, false},
+		{3, 0, true},
+		{0, 450, false},
+		{0, 451, true},
+		{3, 450, false},
+		{3, 451, true},
+	} {
+		sn, id, summary, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{
+			Time:           time.Now(),
+			ParentSnapshot: parent,
+			MinChangeFiles: test.minFiles,
+			MinChangeBytes: test.minBytes,
+		})
+		rtest.OK(t, err)
+		rtest.Equals(t, uint(2), summary.ChangedFiles())
+		rtest.Equals(t, uint64(450), summary.ChangedBytes)
+		rtest.Equals(t, test.skipped, summary.BelowChangeThreshold, fmt.Sprintf("thresholds %v", test))
+		rtest.Equals(t, test.skipped, sn == nil, fmt.Sprintf("thresholds %v", test))
+		rtest.Equals(t, test.skipped, id.IsNull(), fmt.Sprintf("thresholds %v", test))
+	}
+}
+
+func TestArchiverCaseCollisions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"README": TestFile{Content: "README"},
+		"dir": TestDir{
+			"file": TestFile{Content: "file"},
+		},
+	})
+	// the collisions can only be created on case-sensitive file systems
+	if err := os.WriteFile(filepath.Join(tempdir, "Readme"), []byte("Readme"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tempdir, "dir", "FILE"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	names, err := fs.Readdirnames(fs.Local{}, tempdir, fs.O_NOFOLLOW)
+	rtest.OK(t, err)
+	if len(names) != 3 {
+		t.Skip("file system is not case-sensitive")
+	}
+
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	arch := New(repo, fs.Track{FS: fs.Local{}}, Options{})
+	_, _, summary, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+
+	sort.Strings(summary.CaseCollisions)
+	rtest.Equals(t, []string{"/Readme", "/dir/file"}, summary.CaseCollisions)
+	rtest.Equals(t, uint(2), summary.NumCaseCollisions)
+}
+
+func TestArchiverCaseCollisionsLimit(t *testing.T) {
+	arch := &Archiver{summary: &Summary{}}
+	folded := make(map[string]struct{})
+	for i := 0; i < maxCaseCollisions+10; i++ {
+		arch.checkCaseCollision(folded, "/", fmt.Sprintf("file%d", i))
+		arch.checkCaseCollision(folded, "/", fmt.Sprintf("FILE%d", i))
+	}
+
+	rtest.Equals(t, maxCaseCollisions, len(arch.summary.CaseCollisions))
+	rtest.Equals(t, uint(maxCaseCollisions+10), arch.summary.NumCaseCollisions)
+}
+
 func TestArchiverErrorReporting(t *testing.T) {
 	ignoreErrorForBasename := func(basename string) ErrorFunc {
 		return func(item string, err error) error {
@@ -2534,3 +2734,35 @@ func TestDisappearedFile(t *testing.T) {
 		rtest.Assert(t, excluded, "testfile should have been excluded")
 	}
 }
+
+func TestArchiverScanConcurrencyDeterministic(t *testing.T) {
+	src := TestDir{}
+	for i := 0; i < 8; i++ {
+		sub := TestDir{}
+		for j := 0; j < 8; j++ {
+			sub[fmt.Sprintf("file%d", j)] = TestFile{Content: fmt.Sprintf("content %d %d", i, j)}
+			sub[fmt.Sprintf("dir%d", j)] = TestDir{
+				"nested": TestFile{Content: fmt.Sprintf("nested %d %d", i, j)},
+			}
+		}
+		src[fmt.Sprintf("dir%d", i)] = sub
+	}
+
+	tempdir, repo := prepareTempdirRepoSrc(t, src)
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	var treeIDs restic.IDs
+	for _, concurrency := range []uint{1, 2, 16} {
+		arch := New(repo, fs.Track{FS: fs.Local{}}, Options{ScanConcurrency: concurrency})
+		sn, id, _, err := arch.Snapshot(context.TODO(), []string{"."}, SnapshotOptions{Time: time.Now()})
+		rtest.OK(t, err)
+		treeIDs = append(treeIDs, *sn.Tree)
+
+		TestEnsureSnapshot(t, repo, id, src)
+	}
+
+	for _, id := range treeIDs[1:] {
+		rtest.Equals(t, treeIDs[0], id)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/metrics"
 	"github.com/restic/restic/internal/restic"
 	"golang.org/x/sync/errgroup"
 )
@@ -14,6 +15,8 @@ type saver interface {
 	SaveBlob(ctx context.Context, t restic.BlobType, data []byte, id restic.ID, storeDuplicate bool) (restic.ID, bool, int, error)
 }
 
+var blobQueueDepth = metrics.NewGauge("restic_archiver_blob_queue_depth", "Number of blobs waiting to be saved during a backup.")
+
 // blobSaver concurrently saves incoming blobs to the repo.
 type blobSaver struct {
 	repo saver
@@ -45,9 +48,11 @@ func (s *blobSaver) TriggerShutdown() {
 // Save stores a blob in the repo. It checks the index and the known blobs
 // before saving anything. It takes ownership of the buffer passed in.
 func (s *blobSaver) Save(ctx context.Context, t restic.BlobType, buf *buffer, filename string, cb func(res saveBlobResponse)) {
+	blobQueueDepth.Inc()
 	select {
 	case s.ch <- saveBlobJob{BlobType: t, buf: buf, fn: filename, cb: cb}:
 	case <-ctx.Done():
+		blobQueueDepth.Dec()
 		debug.Log("not sending job, context is cancelled")
 	}
 }
@@ -93,6 +98,7 @@ func (s *blobSaver) worker(ctx context.Context, jobs <-chan saveBlobJob) error {
 				return nil
 			}
 		}
+		blobQueueDepth.Dec()
 
 		res, err := s.saveBlob(ctx, job.BlobType, job.buf.Data)
 		if err != nil {
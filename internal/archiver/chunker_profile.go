@@ -0,0 +1,154 @@
+package archiver
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/filter"
+	"github.com/restic/restic/internal/options"
+)
+
+// ChunkerParams configures the sizes of the chunks a file is split into. The
+// chunker polynomial of the repository is used regardless of the parameters.
+type ChunkerParams struct {
+	MinSize, MaxSize uint
+	// AverageBits sets the probability of a cut point to 2^-AverageBits per
+	// byte. As cut points are only searched for after MinSize bytes, chunks
+	// are MinSize plus 2^AverageBits bytes on average, unless limited by
+	// MaxSize.
+	AverageBits int
+	// FixedSize splits files into chunks of exactly FixedSize bytes instead
+	// of searching for content defined cut points, if it is not zero. Only
+	// the last chunk of a file may be smaller. The other fields are ignored.
+	FixedSize uint
+}
+
+var (
+	// DefaultChunkerParams are the chunk sizes used by restic unless
+	// configured otherwise, with chunks of about 1.5 MiB on average.
+	DefaultChunkerParams = ChunkerParams{MinSize: chunker.MinSize, MaxSize: chunker.MaxSize, AverageBits: 20}
+
+	// LargeChunkerParams use chunks of about 4 MiB on average. This is
+	// suitable for compressed files, which hardly deduplicate on a finer
+	// granularity.
+	LargeChunkerParams = ChunkerParams{MinSize: 2 * 1024 * 1024, MaxSize: chunker.MaxSize, AverageBits: 21}
+
+	// SmallChunkerParams use chunks of about 320 KiB on average. This is
+	// suitable for VM and disk images, which often contain small changes.
+	SmallChunkerParams = ChunkerParams{MinSize: 64 * 1024, MaxSize: 2 * 1024 * 1024, AverageBits: 18}
+
+	// FixedChunkerParams use chunks of exactly 4 MiB. This skips the rolling
+	// hash for large files which never deduplicate internally, like media
+	// files, at the cost of losing deduplication if data is inserted.
+	FixedChunkerParams = ChunkerParams{FixedSize: 4 * 1024 * 1024}
+)
+
+// ChunkerProfile returns the chunker parameters for the file with the given
+// name.
+type ChunkerProfile func(filename string) ChunkerParams
+
+// largeChunkExtensions contains the extensions of file types which are
+// already compressed.
+var largeChunkExtensions = map[string]struct{}{
+	".7z": {}, ".avi": {}, ".bz2": {}, ".flac": {}, ".gif": {}, ".gz": {},
+	".heic": {}, ".jpeg": {}, ".jpg": {}, ".m4a": {}, ".m4v": {}, ".mkv": {},
+	".mov": {}, ".mp3": {}, ".mp4": {}, ".ogg": {}, ".png": {}, ".rar": {},
+	".webm": {}, ".webp": {}, ".xz": {}, ".zip": {}, ".zst": {},
+}
+
+// smallChunkExtensions contains the extensions of VM and disk images.
+var smallChunkExtensions = map[string]struct{}{
+	".img": {}, ".qcow2": {}, ".raw": {}, ".vdi": {}, ".vhd": {}, ".vhdx": {},
+	".vmdk": {},
+}
+
+// AutoChunkerProfile selects the chunker parameters based on the file
+// extension.
+func AutoChunkerProfile(filename string) ChunkerParams {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if _, ok := largeChunkExtensions[ext]; ok {
+		return LargeChunkerParams
+	}
+	if _, ok := smallChunkExtensions[ext]; ok {
+		return SmallChunkerParams
+	}
+	return DefaultChunkerParams
+}
+
+// ChunkerConfig holds the extended options for the chunker.
+type ChunkerConfig struct {
+	Profile   string `option:"profile" help:"chunk sizes used for new files, one of default, auto, large or small (default: default)" details:"The large profile reduces the number of blobs for big media files, the small profile improves deduplication for virtual machine images. The auto profile selects the profile based on the file extension. Files which were chunked using a different profile do not deduplicate with new data."`
+	Fixed     string `option:"fixed" help:"comma-separated list of patterns, matching files are split into fixed-size chunks" details:"Files matching one of the patterns are split into chunks of the same size without computing the rolling hash, which saves CPU time for files that never deduplicate internally, like large media files. The patterns use the same syntax as --exclude. The setting takes precedence over chunker.profile."`
+	FixedSize uint   `option:"fixed-size" help:"chunk size in MiB for files matching chunker.fixed, between 1 and 8 (default: 4)"`
+}
+
+func init() {
+	options.Register("chunker", ChunkerConfig{})
+}
+
+// ParseChunkerConfig parses the chunker extended options and returns the
+// selected profile. For the default profile, nil is returned.
+func ParseChunkerConfig(o options.Options) (ChunkerProfile, error) {
+	var cfg ChunkerConfig
+	o = o.Extract("chunker")
+	if err := o.Apply("chunker", &cfg); err != nil {
+		return nil, err
+	}
+
+	var profile ChunkerProfile
+	switch cfg.Profile {
+	case "", "default":
+	case "auto":
+		profile = AutoChunkerProfile
+	case "large":
+		profile = func(string) ChunkerParams { return LargeChunkerParams }
+	case "small":
+		profile = func(string) ChunkerParams { return SmallChunkerParams }
+	default:
+		return nil, errors.Fatalf("invalid chunker.profile %q, must be one of default, auto, large or small", cfg.Profile)
+	}
+
+	if cfg.FixedSize != 0 && cfg.Fixed == "" {
+		return nil, errors.Fatal("chunker.fixed-size requires chunker.fixed")
+	}
+	if cfg.Fixed == "" {
+		return profile, nil
+	}
+	return fixedChunkerProfile(strings.Split(cfg.Fixed, ","), cfg.FixedSize, profile)
+}
+
+// fixedChunkerProfile returns a profile which uses fixed-size chunks of
+// sizeMiB for files matching one of patterns, and base for all other files.
+func fixedChunkerProfile(patterns []string, sizeMiB uint, base ChunkerProfile) (ChunkerProfile, error) {
+	fixed := FixedChunkerParams
+	if sizeMiB != 0 {
+		if sizeMiB*1024*1024 > chunker.MaxSize {
+			return nil, errors.Fatalf("invalid chunker.fixed-size %d, must be between 1 and %d", sizeMiB, chunker.MaxSize/1024/1024)
+		}
+		fixed.FixedSize = sizeMiB * 1024 * 1024
+	}
+
+	var trimmed []string
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" {
+			trimmed = append(trimmed, p)
+		}
+	}
+	if err := filter.ValidatePatterns(trimmed); err != nil {
+		return nil, errors.Fatalf("invalid chunker.fixed: %v", err)
+	}
+	parsed := filter.ParsePatterns(trimmed)
+
+	return func(filename string) ChunkerParams {
+		// patterns were validated above
+		if match, _ := filter.List(parsed, filename); match {
+			return fixed
+		}
+		if base == nil {
+			return DefaultChunkerParams
+		}
+		return base(filename)
+	}, nil
+}
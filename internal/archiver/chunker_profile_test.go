@@ -0,0 +1,167 @@
+package archiver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestAutoChunkerProfile(t *testing.T) {
+	for _, test := range []struct {
+		filename string
+		params   ChunkerParams
+	}{
+		{"/home/user/file.txt", DefaultChunkerParams},
+		{"/home/user/Makefile", DefaultChunkerParams},
+		{"/home/user/photo.JPG", LargeChunkerParams},
+		{"/home/user/movie.mkv", LargeChunkerParams},
+		{"/home/user/archive.tar.gz", LargeChunkerParams},
+		{"/var/lib/libvirt/images/vm.qcow2", SmallChunkerParams},
+		{"/vms/disk.vmdk", SmallChunkerParams},
+	} {
+		rtest.Equals(t, test.params, AutoChunkerProfile(test.filename), test.filename)
+	}
+}
+
+func TestParseChunkerConfig(t *testing.T) {
+	profile, err := ParseChunkerConfig(options.Options{})
+	rtest.OK(t, err)
+	rtest.Assert(t, profile == nil, "expected default profile")
+
+	profile, err = ParseChunkerConfig(options.Options{"chunker.profile": "default"})
+	rtest.OK(t, err)
+	rtest.Assert(t, profile == nil, "expected default profile")
+
+	profile, err = ParseChunkerConfig(options.Options{"chunker.profile": "large"})
+	rtest.OK(t, err)
+	rtest.Equals(t, LargeChunkerParams, profile("file.txt"))
+
+	profile, err = ParseChunkerConfig(options.Options{"chunker.profile": "small"})
+	rtest.OK(t, err)
+	rtest.Equals(t, SmallChunkerParams, profile("file.txt"))
+
+	profile, err = ParseChunkerConfig(options.Options{"chunker.profile": "auto"})
+	rtest.OK(t, err)
+	rtest.Equals(t, SmallChunkerParams, profile("disk.img"))
+
+	_, err = ParseChunkerConfig(options.Options{"chunker.profile": "huge"})
+	rtest.Assert(t, err != nil, "invalid profile was accepted")
+}
+
+func TestParseChunkerConfigFixed(t *testing.T) {
+	profile, err := ParseChunkerConfig(options.Options{"chunker.fixed": "*.mkv, /srv/media"})
+	rtest.OK(t, err)
+	rtest.Equals(t, FixedChunkerParams, profile("/home/user/movie.mkv"))
+	rtest.Equals(t, FixedChunkerParams, profile("/srv/media/photo.jpg"))
+	rtest.Equals(t, DefaultChunkerParams, profile("/home/user/photo.jpg"))
+
+	profile, err = ParseChunkerConfig(options.Options{
+		"chunker.profile":    "auto",
+		"chunker.fixed":      "*.mkv",
+		"chunker.fixed-size": "8",
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, ChunkerParams{FixedSize: 8 * 1024 * 1024}, profile("/home/user/movie.mkv"))
+	rtest.Equals(t, LargeChunkerParams, profile("/home/user/photo.jpg"))
+
+	for _, opts := range []options.Options{
+		{"chunker.fixed": "*.mkv", "chunker.fixed-size": "9"},
+		{"chunker.fixed-size": "2"},
+		{"chunker.fixed": "[.mkv"},
+	} {
+		_, err = ParseChunkerConfig(opts)
+		rtest.Assert(t, err != nil, "invalid options %v were accepted", opts)
+	}
+}
+
+// saveWithProfile saves the file with the given chunker parameters and
+// returns the length of all chunks.
+func saveWithProfile(t *testing.T, filename string, params ChunkerParams) []int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg, ctx := errgroup.WithContext(ctx)
+
+	var m sync.Mutex
+	var lengths []int
+	saveBlob := func(ctx context.Context, tpe restic.BlobType, buf *buffer, _ string, cb func(saveBlobResponse)) {
+		m.Lock()
+		lengths = append(lengths, len(buf.Data))
+		m.Unlock()
+		cb(saveBlobResponse{
+			id:         restic.Hash(buf.Data),
+			length:     len(buf.Data),
+			sizeInRepo: len(buf.Data),
+		})
+	}
+
+	pol, err := chunker.RandomPolynomial()
+	rtest.OK(t, err)
+	s := newFileSaver(ctx, wg, saveBlob, pol, 1, 1)
+	s.NodeFromFileInfo = func(snPath, filename string, meta ToNoder, ignoreXattrListError bool) (*restic.Node, error) {
+		return meta.ToNode(ignoreXattrListError)
+	}
+	s.ChunkerProfile = func(string) ChunkerParams { return params }
+
+	f, err := fs.Local{}.OpenFile(filename, os.O_RDONLY, false)
+	rtest.OK(t, err)
+	fn := s.Save(ctx, filename, filename, f, func() {}, func() {}, func(*restic.Node, ItemStats) {})
+	fnr := fn.take(ctx)
+	rtest.OK(t, fnr.err)
+
+	s.TriggerShutdown()
+	rtest.OK(t, wg.Wait())
+	return lengths
+}
+
+func TestFileSaverChunkerProfile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file")
+	rtest.OK(t, os.WriteFile(filename, rtest.Random(23, 16*1024*1024), 0600))
+
+	small := saveWithProfile(t, filename, SmallChunkerParams)
+	for _, l := range small {
+		rtest.Assert(t, l <= int(SmallChunkerParams.MaxSize), "chunk of %d bytes is too large", l)
+	}
+
+	large := saveWithProfile(t, filename, LargeChunkerParams)
+	for _, l := range large[:len(large)-1] {
+		rtest.Assert(t, l >= int(LargeChunkerParams.MinSize), "chunk of %d bytes is too small", l)
+	}
+
+	rtest.Assert(t, len(small) > len(large), "expected more chunks for small profile, got %d and %d", len(small), len(large))
+}
+
+func TestFileSaverFixedChunks(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file")
+	rtest.OK(t, os.WriteFile(filename, rtest.Random(23, 10*1024*1024+123), 0600))
+
+	lengths := saveWithProfile(t, filename, ChunkerParams{FixedSize: 1024 * 1024})
+	rtest.Equals(t, 11, len(lengths))
+	total := 0
+	for _, l := range lengths {
+		total += l
+	}
+	rtest.Equals(t, 10*1024*1024+123, total)
+	// chunks may be saved out of order, only one of them is shorter
+	short := 0
+	for _, l := range lengths {
+		if l != 1024*1024 {
+			rtest.Equals(t, 123, l)
+			short++
+		}
+	}
+	rtest.Equals(t, 1, short)
+
+	// an empty file has no chunks
+	rtest.OK(t, os.WriteFile(filename, nil, 0600))
+	lengths = saveWithProfile(t, filename, FixedChunkerParams)
+	rtest.Equals(t, 0, len(lengths))
+}
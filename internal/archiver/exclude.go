@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
@@ -316,3 +317,78 @@ func RejectBySize(maxSize int64) (RejectFunc, error) {
 		return false
 	}, nil
 }
+
+// RejectOlderThan returns a RejectFunc which rejects files which were last
+// modified before cutoff. Directories are never rejected.
+func RejectOlderThan(cutoff time.Time) RejectFunc {
+	return func(item string, fi *fs.ExtendedFileInfo, _ fs.FS) bool {
+		if fi.Mode.IsDir() {
+			return false
+		}
+
+		if fi.ModTime.Before(cutoff) {
+			debug.Log("file %s was last modified at %v", item, fi.ModTime)
+			return true
+		}
+		return false
+	}
+}
+
+// RejectByOwner returns a RejectFunc which rejects files owned by one of the
+// given users or groups. Directories are never rejected.
+func RejectByOwner(uids, gids []uint32) RejectFunc {
+	return func(item string, fi *fs.ExtendedFileInfo, _ fs.FS) bool {
+		if fi.Mode.IsDir() {
+			return false
+		}
+
+		for _, uid := range uids {
+			if fi.UID == uid {
+				debug.Log("file %s is owned by uid %d", item, uid)
+				return true
+			}
+		}
+		for _, gid := range gids {
+			if fi.GID == gid {
+				debug.Log("file %s is owned by gid %d", item, gid)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// DirExcluder decides which entries of a single directory are excluded from
+// the backup.
+type DirExcluder interface {
+	// Exclude returns true if the directory entry with the given name and
+	// file info should be excluded.
+	Exclude(name string, fi *fs.ExtendedFileInfo) bool
+}
+
+// DirExclusionProvider returns the DirExcluder for the directory dir. It is
+// called at most once per directory and never concurrently. If nil is
+// returned, no entries of the directory are excluded.
+type DirExclusionProvider func(dir string, fs fs.FS) DirExcluder
+
+// RejectByDirExclusion returns a RejectFunc which excludes the entries of a
+// directory according to the DirExcluder returned by provider for that
+// directory.
+func RejectByDirExclusion(provider DirExclusionProvider) RejectFunc {
+	var m sync.Mutex
+	excluders := make(map[string]DirExcluder)
+
+	return func(item string, fi *fs.ExtendedFileInfo, fs fs.FS) bool {
+		dir := fs.Dir(item)
+
+		m.Lock()
+		excluder, ok := excluders[dir]
+		if !ok {
+			excluder = provider(dir, fs)
+			excluders[dir] = excluder
+		}
+		m.Unlock()
+
+		return excluder != nil && excluder.Exclude(fs.Base(item), fi)
+	}
+}
@@ -0,0 +1,175 @@
+package archiver
+
+import (
+	"bytes"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// RejectUntrackedGit returns a RejectFunc which excludes all files and
+// directories within git working trees which are not tracked by git, for
+// example build artifacts. The tracked files of a working tree are listed
+// using `git ls-files` once the working tree is first visited. The .git
+// directory of a working tree is always included. Nested repositories and
+// submodules are handled as separate working trees.
+func RejectUntrackedGit(warnf func(msg string, args ...interface{})) RejectFunc {
+	p := &gitExclusionProvider{
+		roots:     make(map[string]string),
+		repos:     make(map[string]*gitRepo),
+		listFiles: gitListFiles,
+		warnf:     warnf,
+	}
+	return RejectByDirExclusion(p.excluderFor)
+}
+
+// gitRepo contains the tracked files of a git working tree.
+type gitRepo struct {
+	// files and dirs contain slash-separated paths relative to the root of
+	// the working tree. dirs contains all directories with tracked files.
+	files map[string]struct{}
+	dirs  map[string]struct{}
+}
+
+func newGitRepo(files []string) *gitRepo {
+	repo := &gitRepo{
+		files: make(map[string]struct{}, len(files)),
+		dirs:  make(map[string]struct{}),
+	}
+	for _, file := range files {
+		repo.files[file] = struct{}{}
+		for dir := path.Dir(file); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if _, ok := repo.dirs[dir]; ok {
+				break
+			}
+			repo.dirs[dir] = struct{}{}
+		}
+	}
+	return repo
+}
+
+// gitDirExcluder excludes the untracked entries of a directory in a git
+// working tree.
+type gitDirExcluder struct {
+	repo *gitRepo
+	// dir is the slash-separated path of the directory relative to the root
+	// of the working tree, or empty for the root itself
+	dir string
+}
+
+func (e *gitDirExcluder) Exclude(name string, fi *fs.ExtendedFileInfo) bool {
+	if e.dir == "" && name == ".git" {
+		return false
+	}
+
+	p := path.Join(e.dir, name)
+	if _, ok := e.repo.files[p]; ok {
+		return false
+	}
+	if fi.Mode.IsDir() {
+		if _, ok := e.repo.dirs[p]; ok {
+			return false
+		}
+	}
+	debug.Log("rejecting %v, not tracked by git", p)
+	return true
+}
+
+// gitExclusionProvider detects git working trees and returns a DirExcluder
+// for directories within them. As a DirExclusionProvider is never called
+// concurrently, it needs no locking.
+type gitExclusionProvider struct {
+	// roots maps directories to the root of the working tree they are part
+	// of, or to the empty string if they are not part of a working tree
+	roots map[string]string
+	// repos contains the working trees, nil if listing the files failed
+	repos map[string]*gitRepo
+
+	listFiles func(root string) ([]string, error)
+	warnf     func(msg string, args ...interface{})
+}
+
+func (p *gitExclusionProvider) excluderFor(dir string, fsInst fs.FS) DirExcluder {
+	root := p.findRoot(dir, fsInst)
+	if root == "" {
+		return nil
+	}
+
+	repo, ok := p.repos[root]
+	if !ok {
+		files, err := p.listFiles(root)
+		if err != nil {
+			p.warnf("unable to list files tracked by git in %v, not excluding untracked files: %v\n", root, err)
+		} else {
+			debug.Log("git working tree %v contains %d tracked files", root, len(files))
+			repo = newGitRepo(files)
+		}
+		p.repos[root] = repo
+	}
+	if repo == nil {
+		return nil
+	}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+	if rel == ".git" || strings.HasPrefix(rel, ".git/") {
+		// the contents of the .git directory are never tracked
+		return nil
+	}
+	return &gitDirExcluder{repo: repo, dir: rel}
+}
+
+// findRoot returns the root of the git working tree which contains dir, or
+// the empty string if dir is not part of a working tree.
+func (p *gitExclusionProvider) findRoot(dir string, fsInst fs.FS) string {
+	if root, ok := p.roots[dir]; ok {
+		return root
+	}
+
+	var root string
+	// .git is a directory, or a file for submodules and linked working trees
+	if _, err := fsInst.Lstat(fsInst.Join(dir, ".git")); err == nil {
+		root = dir
+	} else if parent := fsInst.Dir(dir); parent != dir {
+		root = p.findRoot(parent, fsInst)
+	}
+	p.roots[dir] = root
+	return root
+}
+
+// gitListFiles returns the files tracked by git in the working tree at root.
+func gitListFiles(root string) ([]string, error) {
+	// disable the file system monitor, which could run arbitrary commands
+	// configured in the repository
+	cmd := exec.Command("git", "-c", "core.fsmonitor=false", "-C", root, "ls-files", "-z")
+	// the error message of git is included in the returned error, writing it
+	// to stderr would interfere with the progress output
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Errorf("git ls-files: %v: %v", err, msg)
+		}
+		return nil, errors.Wrap(err, "git ls-files")
+	}
+
+	var files []string
+	for _, file := range bytes.Split(out, []byte{0}) {
+		if len(file) > 0 {
+			files = append(files, string(file))
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,153 @@
+package archiver
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// createFiles creates the given files and directories, the latter are marked
+// by a trailing slash.
+func createFiles(t *testing.T, root string, names ...string) {
+	for _, name := range names {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			rtest.OK(t, os.MkdirAll(p, 0700))
+			continue
+		}
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0700))
+		rtest.OK(t, os.WriteFile(p, []byte(name), 0600))
+	}
+}
+
+func checkRejected(t *testing.T, reject RejectFunc, root string, expected map[string]bool) {
+	t.Helper()
+	for name, rejected := range expected {
+		item := filepath.Join(root, filepath.FromSlash(name))
+		fi, err := fs.Local{}.Lstat(item)
+		rtest.OK(t, err)
+		rtest.Equals(t, rejected, reject(item, fi, fs.Local{}), name)
+	}
+}
+
+func TestRejectUntrackedGitFake(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	nested := filepath.Join(repo, "vendor", "lib")
+	createFiles(t, root,
+		"outside",
+		"repo/.git/config",
+		"repo/.git/objects/",
+		"repo/main.go",
+		"repo/build/main.o",
+		"repo/src/pkg/util.go",
+		"repo/src/pkg/util.o",
+		"repo/src/empty/",
+		"repo/vendor/lib/.git",
+		"repo/vendor/lib/lib.go",
+		"repo/vendor/lib/lib.o",
+		"broken/.git/config",
+		"broken/file",
+	)
+
+	var listed []string
+	var warnings int
+	p := &gitExclusionProvider{
+		roots: make(map[string]string),
+		repos: make(map[string]*gitRepo),
+		listFiles: func(dir string) ([]string, error) {
+			listed = append(listed, dir)
+			switch dir {
+			case repo:
+				return []string{"main.go", "src/pkg/util.go", "vendor/lib"}, nil
+			case nested:
+				return []string{"lib.go"}, nil
+			}
+			return nil, errors.New("not a git repository")
+		},
+		warnf: func(string, ...interface{}) { warnings++ },
+	}
+	reject := RejectByDirExclusion(p.excluderFor)
+
+	checkRejected(t, reject, root, map[string]bool{
+		"outside":                false,
+		"repo":                   false,
+		"repo/.git":              false,
+		"repo/.git/config":       false,
+		"repo/.git/objects":      false,
+		"repo/main.go":           false,
+		"repo/build":             true,
+		"repo/src":               false,
+		"repo/src/pkg":           false,
+		"repo/src/pkg/util.go":   false,
+		"repo/src/pkg/util.o":    true,
+		"repo/src/empty":         true,
+		"repo/vendor":            false,
+		"repo/vendor/lib":        false,
+		"repo/vendor/lib/.git":   false,
+		"repo/vendor/lib/lib.go": false,
+		"repo/vendor/lib/lib.o":  true,
+		"broken/file":            false,
+	})
+
+	// each working tree is listed only once
+	rtest.Equals(t, 3, len(listed))
+	rtest.Equals(t, 1, warnings)
+}
+
+func TestRejectUntrackedGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	root := t.TempDir()
+	createFiles(t, root,
+		"repo/.gitignore",
+		"repo/tracked",
+		"repo/ignored",
+		"repo/untracked",
+		"repo/dir/tracked file",
+	)
+	rtest.OK(t, os.WriteFile(filepath.Join(root, "repo", ".gitignore"), []byte("ignored\n"), 0600))
+
+	repo := filepath.Join(root, "repo")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", ".gitignore", "tracked", "dir/tracked file"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		out, err := cmd.CombinedOutput()
+		rtest.Assert(t, err == nil, "git %v failed: %v\n%s", args, err, out)
+	}
+
+	reject := RejectUntrackedGit(func(msg string, args ...interface{}) {
+		t.Errorf(msg, args...)
+	})
+	checkRejected(t, reject, root, map[string]bool{
+		"repo/.git":             false,
+		"repo/.git/config":      false,
+		"repo/.gitignore":       false,
+		"repo/tracked":          false,
+		"repo/ignored":          true,
+		"repo/untracked":        true,
+		"repo/dir":              false,
+		"repo/dir/tracked file": false,
+	})
+}
+
+func TestGitListFilesError(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	// the error message of git is part of the returned error
+	_, err := gitListFiles(filepath.Join(t.TempDir(), "missing"))
+	rtest.Assert(t, err != nil, "expected error for missing directory")
+	rtest.Assert(t, strings.Contains(err.Error(), "missing"), "error does not contain the message of git: %v", err)
+}
@@ -1,9 +1,11 @@
 package archiver
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/test"
@@ -211,6 +213,41 @@ func TestIsExcludedByFileSize(t *testing.T) {
 	}
 }
 
+func TestRejectOlderThan(t *testing.T) {
+	now := time.Now()
+	reject := RejectOlderThan(now.Add(-time.Hour))
+
+	for _, item := range []struct {
+		fi       fs.ExtendedFileInfo
+		rejected bool
+	}{
+		{fs.ExtendedFileInfo{ModTime: now}, false},
+		{fs.ExtendedFileInfo{ModTime: now.Add(-59 * time.Minute)}, false},
+		{fs.ExtendedFileInfo{ModTime: now.Add(-2 * time.Hour)}, true},
+		{fs.ExtendedFileInfo{ModTime: now.Add(-2 * time.Hour), Mode: os.ModeDir}, false},
+		{fs.ExtendedFileInfo{ModTime: now.Add(-2 * time.Hour), Mode: os.ModeSymlink}, true},
+	} {
+		test.Equals(t, item.rejected, reject("item", &item.fi, nil), fmt.Sprintf("%v", item.fi))
+	}
+}
+
+func TestRejectByOwner(t *testing.T) {
+	reject := RejectByOwner([]uint32{1000, 1001}, []uint32{50})
+
+	for _, item := range []struct {
+		fi       fs.ExtendedFileInfo
+		rejected bool
+	}{
+		{fs.ExtendedFileInfo{UID: 0, GID: 0}, false},
+		{fs.ExtendedFileInfo{UID: 1000, GID: 0}, true},
+		{fs.ExtendedFileInfo{UID: 1001, GID: 100}, true},
+		{fs.ExtendedFileInfo{UID: 1002, GID: 50}, true},
+		{fs.ExtendedFileInfo{UID: 1000, GID: 50, Mode: os.ModeDir}, false},
+	} {
+		test.Equals(t, item.rejected, reject("item", &item.fi, nil), fmt.Sprintf("%v", item.fi))
+	}
+}
+
 func TestDeviceMap(t *testing.T) {
 	deviceMap := deviceMap{
 		filepath.FromSlash("/"):          1,
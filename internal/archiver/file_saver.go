@@ -29,6 +29,8 @@ type fileSaver struct {
 	CompleteBlob func(bytes uint64)
 
 	NodeFromFileInfo func(snPath, filename string, meta ToNoder, ignoreXattrListError bool) (*restic.Node, error)
+
+	ChunkerProfile ChunkerProfile
 }
 
 // newFileSaver returns a new file saver. A worker pool with fileWorkers is
@@ -166,15 +168,26 @@ func (s *fileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 		return
 	}
 
-	// reuse the chunker
-	chnker.Reset(f, s.pol)
+	params := DefaultChunkerParams
+	if s.ChunkerProfile != nil {
+		params = s.ChunkerProfile(target)
+	}
+
+	next := chnker.Next
+	if params.FixedSize > 0 {
+		next = fixedChunker(f, params.FixedSize)
+	} else {
+		// reuse the chunker
+		chnker.ResetWithBoundaries(f, s.pol, params.MinSize, params.MaxSize)
+		chnker.SetAverageBits(params.AverageBits)
+	}
 
 	node.Content = []restic.ID{}
 	node.Size = 0
 	var idx int
 	for {
 		buf := s.saveFilePool.Get()
-		chunk, err := chnker.Next(buf.Data)
+		chunk, err := next(buf.Data)
 		if err == io.EOF {
 			buf.Release()
 			break
@@ -243,6 +256,29 @@ func (s *fileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 	completeBlob()
 }
 
+// fixedChunker returns a function which splits rd into chunks of size bytes,
+// only the last chunk may be smaller. It returns io.EOF once all data was
+// read, like chunker.Next.
+func fixedChunker(rd io.Reader, size uint) func(buf []byte) (chunker.Chunk, error) {
+	var start uint
+	return func(buf []byte) (chunker.Chunk, error) {
+		if uint(cap(buf)) < size {
+			buf = make([]byte, size)
+		}
+		n, err := io.ReadFull(rd, buf[:size])
+		if err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		if err != nil {
+			return chunker.Chunk{}, err
+		}
+
+		chunk := chunker.Chunk{Start: start, Length: uint(n), Data: buf[:n]}
+		start += uint(n)
+		return chunk, nil
+	}
+}
+
 func (s *fileSaver) worker(ctx context.Context, jobs <-chan saveFileJob) {
 	// a worker has one chunker which is reused for each file (because it contains a rather large buffer)
 	chnker := chunker.New(nil, s.pol)
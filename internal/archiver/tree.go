@@ -2,7 +2,9 @@ package archiver
 
 import (
 	"fmt"
+	"os"
 	"sort"
+	"strings"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
@@ -269,13 +271,45 @@ func unrollTree(f fs.FS, t *tree) error {
 	return nil
 }
 
-// newTree creates a Tree from the target files/directories.
-func newTree(fs fs.FS, targets []string) (*tree, error) {
-	debug.Log("targets: %v", targets)
-	tree := &tree{}
-	seen := make(map[string]struct{})
+// overlapTarget is a target with its absolute path and root directory.
+type overlapTarget struct {
+	target string
+	abs    string
+	root   string
+	// key is abs with the separator replaced by a zero byte, such that
+	// sorting by key sorts all paths within a directory directly after the
+	// directory itself.
+	key  string
+	drop bool
+}
+
+// containsPath returns true if the path p is equal to or within dir.
+func containsPath(dir, p string, sep byte) bool {
+	if !strings.HasPrefix(p, dir) {
+		return false
+	}
+	return len(p) == len(dir) || dir[len(dir)-1] == sep || p[len(dir)] == sep
+}
+
+// resolveOverlappingTargets removes targets which are contained in another
+// target with a different root directory, for example "file" and "../dir"
+// when run in dir. Otherwise, they would be saved twice in separate subtrees
+// of the snapshot. Overlapping targets with the same root are merged by tree
+// instead. Targets are only removed if there is no symlink between both
+// targets, as saving the outer target would not follow it. The remaining
+// targets are cleaned, unique and kept in their original order, and which
+// targets are removed does not depend on the order.
+func resolveOverlappingTargets(filesys fs.FS, targets []string) ([]string, error) {
+	// determine the working directory only once, as it is expensive
+	wd, err := filesys.Abs(".")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]overlapTarget, 0, len(targets))
+	seen := make(map[string]struct{}, len(targets))
 	for _, target := range targets {
-		target = fs.Clean(target)
+		target = filesys.Clean(target)
 
 		// skip duplicate targets
 		if _, ok := seen[target]; ok {
@@ -283,6 +317,95 @@ func newTree(fs fs.FS, targets []string) (*tree, error) {
 		}
 		seen[target] = struct{}{}
 
+		abs := target
+		if !filesys.IsAbs(target) {
+			abs = filesys.Join(wd, target)
+		}
+		// drive letters are case-insensitive, "c:\dir" and "C:\dir" must
+		// be detected as overlapping
+		vol := filesys.VolumeName(abs)
+		abs = strings.ToUpper(vol) + abs[len(vol):]
+		entries = append(entries, overlapTarget{
+			target: target,
+			abs:    abs,
+			root:   rootDirectory(filesys, target),
+			key:    strings.ReplaceAll(abs, filesys.Separator(), "\x00"),
+		})
+	}
+
+	sep := filesys.Separator()[0]
+	sorted := make([]*overlapTarget, len(entries))
+	for i := range entries {
+		sorted[i] = &entries[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].key != sorted[j].key {
+			return sorted[i].key < sorted[j].key
+		}
+		return sorted[i].target < sorted[j].target
+	})
+
+	isSymlink := make(map[string]bool)
+	// symlinkBetween returns true if dir or a directory between dir and p is
+	// a symlink, or its type is unknown.
+	symlinkBetween := func(dir, p string) bool {
+		for p = filesys.Dir(p); len(p) >= len(dir); p = filesys.Dir(p) {
+			symlink, ok := isSymlink[p]
+			if !ok {
+				fi, err := filesys.Lstat(p)
+				symlink = err != nil || fi.Mode&os.ModeSymlink != 0
+				isSymlink[p] = symlink
+			}
+			if symlink {
+				return true
+			}
+			if p == filesys.Dir(p) {
+				break
+			}
+		}
+		return false
+	}
+
+	// stack contains the kept targets which contain the current target,
+	// innermost last
+	var stack []*overlapTarget
+	for _, e := range sorted {
+		for len(stack) > 0 && !containsPath(stack[len(stack)-1].abs, e.abs, sep) {
+			stack = stack[:len(stack)-1]
+		}
+
+		for i := len(stack) - 1; i >= 0; i-- {
+			outer := stack[i]
+			if outer.root != e.root && !symlinkBetween(outer.abs, e.abs) {
+				debug.Log("skipping target %v, it is contained in target %v", e.target, outer.target)
+				e.drop = true
+				break
+			}
+		}
+		if !e.drop {
+			stack = append(stack, e)
+		}
+	}
+
+	result := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.drop {
+			result = append(result, e.target)
+		}
+	}
+	return result, nil
+}
+
+// newTree creates a Tree from the target files/directories.
+func newTree(fs fs.FS, targets []string) (*tree, error) {
+	debug.Log("targets: %v", targets)
+	targets, err := resolveOverlappingTargets(fs, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &tree{}
+	for _, target := range targets {
 		err := tree.Add(fs, target)
 		if err != nil {
 			return nil, err
@@ -290,7 +413,7 @@ func newTree(fs fs.FS, targets []string) (*tree, error) {
 	}
 
 	debug.Log("before unroll:\n%v", tree)
-	err := unrollTree(fs, tree)
+	err = unrollTree(fs, tree)
 	if err != nil {
 		return nil, err
 	}
@@ -2,8 +2,11 @@ package archiver
 
 import (
 	"fmt"
+	"math/rand"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -464,3 +467,117 @@ func TestTree(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveOverlappingTargets(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	TestCreateFiles(t, tempdir, TestDir{
+		"data": TestDir{
+			"file": TestFile{Content: "file"},
+			"sub": TestDir{
+				"other": TestFile{Content: "other"},
+			},
+		},
+		"link": TestSymlink{Target: filepath.FromSlash("data/sub")},
+		"foo":  TestFile{Content: "foo"},
+	})
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	abs := func(p string) string {
+		return filepath.Join(tempdir, filepath.FromSlash(p))
+	}
+
+	var tests = []struct {
+		targets []string
+		want    []string
+	}{
+		{
+			targets: []string{"data", "data/file", "data/sub"},
+			want:    []string{"data", filepath.FromSlash("data/file"), filepath.FromSlash("data/sub")},
+		},
+		{
+			targets: []string{"data/file", abs("data")},
+			want:    []string{abs("data")},
+		},
+		{
+			// data/sub has the same root as data and is merged by the tree
+			targets: []string{abs("data/sub/other"), "data", "foo", "./data/", "data/sub"},
+			want:    []string{"data", "foo", filepath.FromSlash("data/sub")},
+		},
+		{
+			targets: []string{abs("data"), "data"},
+			want:    []string{abs("data")},
+		},
+		{
+			// targets within symlinks are not contained in the outer target
+			targets: []string{abs("link/other"), "."},
+			want:    []string{abs("link/other"), "data", "foo", "link"},
+		},
+	}
+
+	if runtime.GOOS == "windows" {
+		// the case of the drive letter is ignored
+		vol := filepath.VolumeName(tempdir)
+		lower := strings.ToLower(vol) + abs("data")[len(vol):]
+		tests = append(tests, struct {
+			targets []string
+			want    []string
+		}{
+			targets: []string{"data/file", lower},
+			want:    []string{lower},
+		})
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			targets, err := resolveRelativeTargets(fs.Local{}, test.targets)
+			rtest.OK(t, err)
+			result, err := resolveOverlappingTargets(fs.Local{}, targets)
+			rtest.OK(t, err)
+			rtest.Equals(t, test.want, result)
+
+			// the same targets are kept regardless of the order
+			for i := 0; i < 5; i++ {
+				rand.Shuffle(len(targets), func(i, j int) {
+					targets[i], targets[j] = targets[j], targets[i]
+				})
+				shuffled, err := resolveOverlappingTargets(fs.Local{}, targets)
+				rtest.OK(t, err)
+				sort.Strings(shuffled)
+				want := append([]string(nil), test.want...)
+				sort.Strings(want)
+				rtest.Equals(t, want, shuffled)
+			}
+		})
+	}
+}
+
+func TestResolveOverlappingTargetsLarge(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	data := TestDir{}
+	for i := 0; i < 1000; i++ {
+		data[fmt.Sprintf("dir%d", i)] = TestDir{}
+	}
+	TestCreateFiles(t, tempdir, TestDir{"data": data})
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	// a list with a million entries of both relative and absolute paths, in
+	// which all relative paths are contained in the absolute target
+	const n = 1000000
+	targets := make([]string, 0, n+2)
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			targets = append(targets, filepath.Join(tempdir, "data", fmt.Sprintf("dir%d", i%1000), fmt.Sprintf("file%d", i)))
+		} else {
+			targets = append(targets, filepath.Join("data", fmt.Sprintf("dir%d", i%1000), fmt.Sprintf("file%d", i)))
+		}
+	}
+	targets = append(targets, filepath.Join(tempdir, "data"), targets[0])
+
+	result, err := resolveOverlappingTargets(fs.Local{}, targets)
+	rtest.OK(t, err)
+	rtest.Equals(t, n/2+1, len(result))
+	rtest.Equals(t, targets[0], result[0])
+	rtest.Equals(t, filepath.Join(tempdir, "data"), result[len(result)-1])
+}
@@ -221,6 +221,12 @@ func (be *Backend) Connections() uint {
 	return be.connections
 }
 
+// ParallelRangeRequests implements backend.ParallelRangeBackend. All
+// connections can be used to load different parts of a single file.
+func (be *Backend) ParallelRangeRequests() uint {
+	return be.Connections()
+}
+
 // Hasher may return a hash function for calculating a content hash for the backend
 func (be *Backend) Hasher() hash.Hash {
 	return md5.New()
@@ -34,7 +34,7 @@ func NewConfig() Config {
 }
 
 func init() {
-	options.Register("azure", Config{})
+	options.Register("azure", NewConfig())
 }
 
 // ParseConfig parses the string s and extracts the azure config. The
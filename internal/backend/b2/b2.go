@@ -30,6 +30,7 @@ type b2Backend struct {
 	layout.Layout
 
 	canDelete bool
+	quota     uint64
 }
 
 var errTooShort = fmt.Errorf("file is too short")
@@ -39,6 +40,7 @@ const defaultListMaxItems = 10 * 1000
 
 // ensure statically that *b2Backend implements backend.Backend.
 var _ backend.Backend = &b2Backend{}
+var _ backend.SpaceBackend = &b2Backend{}
 
 func NewFactory() location.Factory {
 	return location.NewHTTPBackendFactory("b2", ParseConfig, location.NoPassword, Create, Open)
@@ -91,6 +93,11 @@ func newClient(ctx context.Context, cfg Config, rt http.RoundTripper) (*b2.Clien
 func Open(ctx context.Context, cfg Config, rt http.RoundTripper) (backend.Backend, error) {
 	debug.Log("cfg %#v", cfg)
 
+	quota, err := util.ParseQuota(cfg.Quota)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -113,6 +120,7 @@ func Open(ctx context.Context, cfg Config, rt http.RoundTripper) (backend.Backen
 		Layout:       layout.NewDefaultLayout(cfg.Prefix, path.Join),
 		listMaxItems: defaultListMaxItems,
 		canDelete:    true,
+		quota:        quota,
 	}
 
 	return be, nil
@@ -123,6 +131,11 @@ func Open(ctx context.Context, cfg Config, rt http.RoundTripper) (backend.Backen
 func Create(ctx context.Context, cfg Config, rt http.RoundTripper) (backend.Backend, error) {
 	debug.Log("cfg %#v", cfg)
 
+	quota, err := util.ParseQuota(cfg.Quota)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -145,6 +158,7 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper) (backend.Back
 		cfg:          cfg,
 		Layout:       layout.NewDefaultLayout(cfg.Prefix, path.Join),
 		listMaxItems: defaultListMaxItems,
+		quota:        quota,
 	}
 	return be, nil
 }
@@ -154,10 +168,22 @@ func (be *b2Backend) SetListMaxItems(i int) {
 	be.listMaxItems = i
 }
 
+// Space returns the configured quota. The B2 API does not report the storage
+// available to an account, thus the free space is always unknown.
+func (be *b2Backend) Space(_ context.Context) (backend.SpaceInfo, error) {
+	return backend.SpaceInfo{Quota: be.quota}, nil
+}
+
 func (be *b2Backend) Connections() uint {
 	return be.cfg.Connections
 }
 
+// ParallelRangeRequests implements backend.ParallelRangeBackend. All
+// connections can be used to load different parts of a single file.
+func (be *b2Backend) ParallelRangeRequests() uint {
+	return be.Connections()
+}
+
 // Hasher may return a hash function for calculating a content hash for the backend
 func (be *b2Backend) Hasher() hash.Hash {
 	return nil
@@ -20,6 +20,8 @@ type Config struct {
 	Prefix    string
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+
+	Quota string `option:"quota" help:"maximum size of the repository (e.g. 500G), checked by the backup command before it starts"`
 }
 
 // NewConfig returns a new config with default options applied.
@@ -30,7 +32,7 @@ func NewConfig() Config {
 }
 
 func init() {
-	options.Register("b2", Config{})
+	options.Register("b2", NewConfig())
 }
 
 var bucketName = regexp.MustCompile("^[a-zA-Z0-9-]+$")
@@ -5,10 +5,100 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"time"
 )
 
 var ErrNoRepository = fmt.Errorf("repository does not exist")
 
+// RetentionError is returned by Remove if a file cannot be deleted because it
+// is protected by a retention period set by the storage service. Until is zero
+// if the end of the retention period is unknown.
+type RetentionError struct {
+	Handle Handle
+	Until  time.Time
+}
+
+func (e *RetentionError) Error() string {
+	if e.Until.IsZero() {
+		return fmt.Sprintf("%v is protected by a retention period", e.Handle)
+	}
+	return fmt.Sprintf("%v is protected by a retention period until %v", e.Handle, e.Until.Format(time.RFC3339))
+}
+
+// PolicyError is returned if the storage service refuses to modify a file
+// because of its access policy, for example because the repository is
+// append-only.
+type PolicyError struct {
+	Handle Handle
+	Reason string
+	Err    error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Handle, e.Reason)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// OperationError is returned by a backend which retries failed operations
+// once all attempts of an operation have failed. It records which operation
+// failed for which file, such that the error can be reported with context.
+type OperationError struct {
+	Op     string // Save, Load, Stat, Remove or List
+	Handle Handle // only the type is set for List
+	Length int    // only set for Load
+	Offset int64  // only set for Load
+
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+func (e *OperationError) Error() string {
+	var target string
+	switch e.Op {
+	case "List":
+		target = e.Handle.Type.String()
+	case "Load":
+		target = fmt.Sprintf("%v (offset %d, length %d)", e.Handle, e.Offset, e.Length)
+	default:
+		target = e.Handle.String()
+	}
+
+	attempts := "1 attempt"
+	if e.Attempts != 1 {
+		attempts = fmt.Sprintf("%d attempts", e.Attempts)
+	}
+	return fmt.Sprintf("%v %v failed after %v in %v: %v", e.Op, target, attempts, e.Elapsed.Round(time.Millisecond), e.Err)
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// CircuitOpenError is returned by a backend which retries failed operations
+// once it has given up on retrying any further operation, because the
+// storage service appears to be unusable.
+type CircuitOpenError struct {
+	Reason      string        // why the circuit breaker opened
+	FailureTime time.Duration // time since the first failure not followed by a successful request
+	ErrorRate   float64       // fraction of recently failed requests
+	Err         error         // last error of the operation, nil if it was not attempted
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("circuit breaker open, %v", e.Reason)
+	}
+	return fmt.Sprintf("circuit breaker open, %v: %v", e.Reason, e.Err)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return e.Err
+}
+
 // Backend is used to store and access data.
 //
 // Backend operations that return an error will be retried when a Backend is
@@ -107,6 +197,93 @@ type FreezeBackend interface {
 	Unfreeze()
 }
 
+// ParallelRangeBackend is a backend for which requesting different parts of
+// a file concurrently is faster than requesting them one after another, as
+// each request has a high latency.
+type ParallelRangeBackend interface {
+	Backend
+	// ParallelRangeRequests returns the maximum number of concurrent range
+	// requests for a single file.
+	ParallelRangeRequests() uint
+}
+
+// Range is a section of a file.
+type Range struct {
+	Offset int64
+	Length int
+}
+
+// MultiRangeBackend is a backend which can load several sections of a file
+// using a single request. Wrappers implement it by passing the call on to the
+// wrapped backend using LoadRanges.
+type MultiRangeBackend interface {
+	Backend
+	// MaxRanges returns the maximum number of ranges which are loaded using a
+	// single request, or zero if the storage does not support it.
+	MaxRanges() int
+	// LoadRanges runs fn with a reader for each of the ranges of the file at
+	// h, in the order of the ranges. The ranges must be sorted by offset and
+	// must not overlap. If the request is retried, fn may be called again for
+	// the same range.
+	LoadRanges(ctx context.Context, h Handle, ranges []Range, fn func(i int, rd io.Reader) error) error
+}
+
+// MaxRanges returns the maximum number of ranges which be loads using a single
+// request. Wrappers are not unwrapped, as calling LoadRanges on the wrapped
+// backend would bypass them.
+func MaxRanges(be Backend) int {
+	if mr, ok := be.(MultiRangeBackend); ok {
+		return mr.MaxRanges()
+	}
+	return 0
+}
+
+// LoadRanges loads the ranges of the file at h using be.LoadRanges if be
+// implements MultiRangeBackend, and using a separate Load for each range
+// otherwise.
+func LoadRanges(ctx context.Context, be Backend, h Handle, ranges []Range, fn func(i int, rd io.Reader) error) error {
+	if mr, ok := be.(MultiRangeBackend); ok {
+		return mr.LoadRanges(ctx, h, ranges, fn)
+	}
+	for i, r := range ranges {
+		err := be.Load(ctx, h, r.Length, r.Offset, func(rd io.Reader) error {
+			return fn(i, rd)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetentionBackend is a backend which protects files from being deleted until
+// their retention period expires.
+type RetentionBackend interface {
+	Backend
+	// ListRetained runs fn for each file of type t which cannot be deleted
+	// yet, together with the end of its retention period.
+	ListRetained(ctx context.Context, t FileType, fn func(name string, until time.Time) error) error
+}
+
+// SpaceBackend is a backend which can report the space available for the
+// repository.
+type SpaceBackend interface {
+	Backend
+	// Space returns the quota configured for the repository and, if the
+	// backend is able to determine it, the free space of the storage.
+	Space(ctx context.Context) (SpaceInfo, error)
+}
+
+// SpaceInfo describes the space available for a repository.
+type SpaceInfo struct {
+	// Quota is the maximum size of the repository set using the quota option,
+	// zero if no quota is configured.
+	Quota uint64
+	// Free is the free space of the storage, only valid if FreeKnown is set.
+	Free      uint64
+	FreeKnown bool
+}
+
 // FileInfo is contains information about a file in the backend.
 type FileInfo struct {
 	Size int64
@@ -1,9 +1,12 @@
 package backend_test
 
 import (
+	"context"
+	"io"
 	"testing"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
 	"github.com/restic/restic/internal/test"
 )
 
@@ -36,3 +39,21 @@ func TestAsBackend(t *testing.T) {
 	wrapper.Backend = other
 	test.Assert(t, backend.AsBackend[*testBackend](wrapper) == nil, "a wrapped otherTestBackend is not a testBackend")
 }
+
+func TestLoadRanges(t *testing.T) {
+	be := mem.New()
+	h := backend.Handle{Type: backend.PackFile, Name: "foo"}
+	test.OK(t, be.Save(context.TODO(), h, backend.NewByteReader([]byte("0123456789"), be.Hasher())))
+
+	// the memory backend does not support multi-range requests
+	test.Equals(t, 0, backend.MaxRanges(be))
+
+	var got []string
+	err := backend.LoadRanges(context.TODO(), be, h, []backend.Range{{Offset: 1, Length: 2}, {Offset: 5, Length: 3}}, func(i int, rd io.Reader) error {
+		buf, err := io.ReadAll(rd)
+		got = append(got, string(buf))
+		return err
+	})
+	test.OK(t, err)
+	test.Equals(t, []string{"12", "567"}, got)
+}
@@ -26,6 +26,7 @@ type Backend struct {
 
 // ensure Backend implements backend.Backend
 var _ backend.Backend = &Backend{}
+var _ backend.MultiRangeBackend = &Backend{}
 
 func newBackend(be backend.Backend, c *Cache) *Backend {
 	return &Backend{
@@ -44,6 +45,10 @@ func (b *Backend) Remove(ctx context.Context, h backend.Handle) error {
 	}
 
 	_, err = b.Cache.remove(h)
+	if err != nil {
+		return err
+	}
+	_, err = b.Cache.removePack(h)
 	return err
 }
 
@@ -119,15 +124,20 @@ func (b *Backend) cacheFile(ctx context.Context, h backend.Handle) error {
 		b.inProgressMutex.Unlock()
 	}()
 
+	has, save, remove := b.Cache.Has, b.Cache.save, b.Cache.remove
+	if b.Cache.cachesPack(h) {
+		has, save, remove = b.Cache.hasPack, b.Cache.savePack, b.Cache.removePack
+	}
+
 	// test again, maybe the file was cached in the meantime
-	if !b.Cache.Has(h) {
+	if !has(h) {
 		// nope, it's still not in the cache, pull it from the repo and save it
 		err := b.Backend.Load(ctx, h, 0, 0, func(rd io.Reader) error {
-			return b.Cache.save(h, rd)
+			return save(h, rd)
 		})
 		if err != nil {
 			// try to remove from the cache, ignore errors
-			_, _ = b.Cache.remove(h)
+			_, _ = remove(h)
 		}
 		return err
 	}
@@ -138,6 +148,10 @@ func (b *Backend) cacheFile(ctx context.Context, h backend.Handle) error {
 // loadFromCache will try to load the file from the cache.
 func (b *Backend) loadFromCache(h backend.Handle, length int, offset int64, consumer func(rd io.Reader) error) (bool, error) {
 	rd, inCache, err := b.Cache.load(h, length, offset)
+	if !inCache && b.Cache.cachesPack(h) {
+		// pack files which contain metadata are stored in the regular cache
+		rd, inCache, err = b.Cache.loadPack(h, length, offset)
+	}
 	if err != nil {
 		return inCache, err
 	}
@@ -173,7 +187,7 @@ func (b *Backend) Load(ctx context.Context, h backend.Handle, length int, offset
 	}
 
 	// if we don't automatically cache this file type, fall back to the backend
-	if !autoCacheTypes(h) {
+	if !autoCacheTypes(h) && !b.Cache.cachesPack(h) {
 		debug.Log("Load(%v, %v, %v): delegating to backend", h, length, offset)
 		return b.Backend.Load(ctx, h, length, offset, consumer)
 	}
@@ -196,6 +210,29 @@ func (b *Backend) Load(ctx context.Context, h backend.Handle, length int, offset
 	return b.Backend.Load(ctx, h, length, offset, consumer)
 }
 
+// MaxRanges implements backend.MultiRangeBackend.
+func (b *Backend) MaxRanges() int {
+	return backend.MaxRanges(b.Backend)
+}
+
+// LoadRanges implements backend.MultiRangeBackend. Files which are stored in
+// the cache or are added to it automatically are loaded using Load.
+func (b *Backend) LoadRanges(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+	if !autoCacheTypes(h) && !b.Cache.cachesPack(h) && !b.Cache.Has(h) {
+		return backend.LoadRanges(ctx, b.Backend, h, ranges, fn)
+	}
+
+	for i, r := range ranges {
+		err := b.Load(ctx, h, r.Length, r.Offset, func(rd io.Reader) error {
+			return fn(i, rd)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Stat tests whether the backend has a file. If it does not exist but still
 // exists in the cache, it is removed from the cache.
 func (b *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
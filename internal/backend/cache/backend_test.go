@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"math/rand"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -313,3 +314,88 @@ func TestAutomaticCacheClearInvalidFilename(t *testing.T) {
 	// list all files in the backend
 	list(t, wbe, func(_ backend.FileInfo) error { return nil })
 }
+
+func TestPackCache(t *testing.T) {
+	be := mem.New()
+	c := TestNewCache(t)
+	wbe := c.Wrap(be)
+
+	const size = 10000
+	c.EnablePackCache(2*size + size/2)
+
+	var handles []backend.Handle
+	var contents [][]byte
+	for i := 0; i < 3; i++ {
+		h, data := randomData(size)
+		h.Type = backend.PackFile
+		save(t, be, h, data)
+		handles = append(handles, h)
+		contents = append(contents, data)
+	}
+
+	loadPart := func(i int) {
+		buf := make([]byte, 100)
+		err := wbe.Load(context.TODO(), handles[i], len(buf), 50, func(rd io.Reader) error {
+			_, err := io.ReadFull(rd, buf)
+			return err
+		})
+		test.OK(t, err)
+		test.Equals(t, contents[i][50:150], buf)
+	}
+
+	// partial loads cache the complete pack file
+	loadPart(0)
+	test.Assert(t, c.Has(handles[0]), "pack file not cached after load")
+	loadPart(1)
+
+	// cached pack files are used even if they were removed from the backend
+	remove(t, be, handles[0])
+	loadPart(0)
+
+	// the least recently used pack file is removed first
+	loadPart(2)
+	test.Assert(t, c.Has(handles[0]), "recently used pack file was removed from the cache")
+	test.Assert(t, !c.Has(handles[1]), "least recently used pack file was not removed from the cache")
+	test.Assert(t, c.Has(handles[2]), "pack file not cached after load")
+
+	// pack files are removed from the cache when they are deleted
+	remove(t, wbe, handles[2])
+	test.Assert(t, !c.Has(handles[2]), "pack file still cached after remove")
+
+	// the pack cache is not used once disabled
+	c.EnablePackCache(0)
+	test.Assert(t, !c.Has(handles[0]), "disabled pack cache still used")
+}
+
+func TestPackCacheExistingFiles(t *testing.T) {
+	be := mem.New()
+	c := TestNewCache(t)
+
+	const size = 10000
+	var handles []backend.Handle
+	for i := 0; i < 3; i++ {
+		h, data := randomData(size)
+		h.Type = backend.PackFile
+		save(t, be, h, data)
+		handles = append(handles, h)
+	}
+
+	load := func(c *Cache, h backend.Handle) {
+		_, err := backendtest.LoadAll(context.TODO(), c.Wrap(be), h)
+		test.OK(t, err)
+	}
+
+	c.EnablePackCache(10 * size)
+	load(c, handles[0])
+	load(c, handles[1])
+
+	// pack files cached by a previous process are also expired
+	c2, err := New(filepath.Base(c.path), filepath.Dir(c.path))
+	test.OK(t, err)
+	c2.EnablePackCache(2*size + size/2)
+	load(c2, handles[2])
+
+	test.Assert(t, !c2.Has(handles[0]), "least recently used pack file was not removed from the cache")
+	test.Assert(t, c2.Has(handles[1]), "pack file was removed from the cache")
+	test.Assert(t, c2.Has(handles[2]), "pack file not cached after load")
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/restic"
 )
@@ -21,7 +22,17 @@ type Cache struct {
 	Base    string
 	Created bool
 
+	// cipher is set if the cached files are encrypted
+	cipher *cacheCipher
+
 	forgotten sync.Map
+
+	packCacheSize  int64
+	packCacheMutex sync.Mutex
+	// packIndex contains the files in the pack cache, it is created on first
+	// use to avoid scanning the pack cache directory for each saved file
+	packIndex map[string]cachedPack
+	packTotal int64
 }
 
 const dirMode = 0700
@@ -77,6 +88,18 @@ func writeCachedirTag(dir string) error {
 // For partial files, the complete file is loaded and stored in the cache when
 // performReadahead returns true.
 func New(id string, basedir string) (c *Cache, err error) {
+	return newCache(id, basedir, nil)
+}
+
+// NewEncrypted returns a new cache like New, but all files are stored
+// encrypted with a key derived from the master key of the repository. Files
+// are decrypted while they are read from the cache. The memory-mapped index is
+// not used with an encrypted cache.
+func NewEncrypted(id string, basedir string, key *crypto.Key) (c *Cache, err error) {
+	return newCache(id, basedir, newCacheCipher(key))
+}
+
+func newCache(id string, basedir string, cipher *cacheCipher) (c *Cache, err error) {
 	if basedir == "" {
 		basedir, err = DefaultDir()
 		if err != nil {
@@ -142,11 +165,60 @@ func New(id string, basedir string) (c *Cache, err error) {
 		path:    cachedir,
 		Base:    basedir,
 		Created: created,
+		cipher:  cipher,
+	}
+
+	if err = c.switchEncryption(); err != nil {
+		return nil, err
 	}
 
 	return c, nil
 }
 
+// encryptedMarker is the name of the file which marks an encrypted cache.
+const encryptedMarker = "encrypted"
+
+// switchEncryption removes all cached files if they were stored with a
+// different encryption setting than the one used by c.
+func (c *Cache) switchEncryption() error {
+	marker := filepath.Join(c.path, encryptedMarker)
+	_, err := os.Stat(marker)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.WithStack(err)
+	}
+	encrypted := err == nil
+	if encrypted == (c.cipher != nil) {
+		return nil
+	}
+
+	debug.Log("encryption of cache %v changed, removing cached files", c.path)
+	dirs := []string{packCacheDir}
+	for _, p := range cacheLayoutPaths {
+		dirs = append(dirs, p)
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(filepath.Join(c.path, dir)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, p := range cacheLayoutPaths {
+		if err := os.MkdirAll(filepath.Join(c.path, p), dirMode); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	// the memory-mapped index contains the index in plaintext
+	err = os.Remove(filepath.Join(c.path, mappedIndexFilename))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.WithStack(err)
+	}
+
+	if c.cipher == nil {
+		return errors.WithStack(os.Remove(marker))
+	}
+	return errors.WithStack(os.WriteFile(marker, nil, fileMode))
+}
+
 // updateTimestamp sets the modification timestamp (mtime and atime) for the
 // directory d to the current time.
 func updateTimestamp(d string) error {
@@ -245,3 +317,30 @@ func (c *Cache) Wrap(be backend.Backend) backend.Backend {
 func (c *Cache) BaseDir() string {
 	return c.Base
 }
+
+const mappedIndexFilename = "index.mapped"
+const snapshotCacheFilename = "snapshots.cache"
+const progressHistoryDir = "progress"
+
+// MappedIndexFilename returns the name of the file which holds the
+// memory-mapped copy of the repository index. As the mapped index cannot be
+// encrypted, the empty string is returned for an encrypted cache.
+func (c *Cache) MappedIndexFilename() string {
+	if c.cipher != nil {
+		return ""
+	}
+	return filepath.Join(c.path, mappedIndexFilename)
+}
+
+// SnapshotCacheFilename returns the name of the file which holds the decrypted
+// snapshot files. The repository is responsible for encrypting its content.
+func (c *Cache) SnapshotCacheFilename() string {
+	return filepath.Join(c.path, snapshotCacheFilename)
+}
+
+// ProgressHistoryFilename returns the name of the file which holds the
+// statistics of previous backups identified by key. They are used to estimate
+// the remaining time of a backup.
+func (c *Cache) ProgressHistoryFilename(key string) string {
+	return filepath.Join(c.path, progressHistoryDir, key)
+}
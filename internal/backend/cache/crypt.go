@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/restic/restic/internal/crypto"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encrypted cache files start with a header consisting of a magic value and a
+// random salt. The salt is used to derive a key specific to the file from the
+// cache key. The plaintext is split into chunks of encryptedChunkSize bytes,
+// which are encrypted using AES-256-GCM. The nonce of a chunk contains its
+// index and marks the last chunk of a file, which prevents reordering and
+// truncating the chunks. This allows decrypting arbitrary parts of a file
+// without reading it completely.
+const (
+	encryptedMagic      = "RCACHE01"
+	encryptedSaltSize   = 16
+	encryptedHeaderSize = len(encryptedMagic) + encryptedSaltSize
+	encryptedChunkSize  = 64 * 1024
+)
+
+// cacheCipher encrypts and decrypts cache files.
+type cacheCipher struct {
+	key [32]byte
+}
+
+// newCacheCipher derives the cache key from the master key of the repository.
+// The cache key only exists in memory.
+func newCacheCipher(key *crypto.Key) *cacheCipher {
+	secret := make([]byte, 0, len(key.EncryptionKey)+len(key.MACKey.K)+len(key.MACKey.R))
+	secret = append(secret, key.EncryptionKey[:]...)
+	secret = append(secret, key.MACKey.K[:]...)
+	secret = append(secret, key.MACKey.R[:]...)
+
+	c := &cacheCipher{}
+	kdf := hkdf.New(sha256.New, secret, nil, []byte("restic cache encryption"))
+	if _, err := io.ReadFull(kdf, c.key[:]); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// fileAEAD returns the cipher for the file with the given salt.
+func (c *cacheCipher) fileAEAD(salt []byte) (cipher.AEAD, error) {
+	var key [32]byte
+	kdf := hkdf.New(sha256.New, c.key[:], salt, []byte("restic cache file"))
+	if _, err := io.ReadFull(kdf, key[:]); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(aead cipher.AEAD, chunk uint64, last bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce, chunk)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// isEncryptedFile reports whether f starts with the header of an encrypted
+// cache file.
+func isEncryptedFile(f *os.File) (bool, error) {
+	magic := make([]byte, len(encryptedMagic))
+	_, err := f.ReadAt(magic, 0)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return string(magic) == encryptedMagic, nil
+}
+
+// encryptedPlaintextSize returns the size of the plaintext stored in an
+// encrypted file of the given size.
+func encryptedPlaintextSize(size int64, overhead int) (int64, error) {
+	l := size - int64(encryptedHeaderSize)
+	full := int64(encryptedChunkSize + overhead)
+	if l < int64(overhead) {
+		return 0, errors.New("encrypted file is truncated")
+	}
+
+	chunks := (l + full - 1) / full
+	if l-(chunks-1)*full < int64(overhead) {
+		return 0, errors.New("encrypted file has an invalid size")
+	}
+	return l - chunks*int64(overhead), nil
+}
+
+// encryptingWriter encrypts all data written to it. Close must be called to
+// write the last chunk, it does not close the underlying writer.
+type encryptingWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	buf   []byte
+	out   []byte
+	chunk uint64
+}
+
+func newEncryptingWriter(w io.Writer, c *cacheCipher) (*encryptingWriter, error) {
+	header := make([]byte, encryptedHeaderSize)
+	copy(header, encryptedMagic)
+	if _, err := rand.Read(header[len(encryptedMagic):]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	aead, err := c.fileAEAD(header[len(encryptedMagic):])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptingWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, encryptedChunkSize),
+		out:  make([]byte, 0, encryptedChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (w *encryptingWriter) seal(last bool) error {
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.aead, w.chunk, last), w.buf, nil)
+	w.buf = w.buf[:0]
+	w.chunk++
+	_, err := w.w.Write(w.out)
+	return err
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is only written once it is known that it is not the
+		// last chunk
+		if len(w.buf) == encryptedChunkSize {
+			if err := w.seal(false); err != nil {
+				return n, err
+			}
+		}
+
+		l := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+l]
+		p = p[l:]
+		n += l
+	}
+	return n, nil
+}
+
+// Close writes the last chunk.
+func (w *encryptingWriter) Close() error {
+	return w.seal(true)
+}
+
+// decryptingReader decrypts a part of an encrypted file.
+type decryptingReader struct {
+	f    *os.File
+	aead cipher.AEAD
+
+	size     int64
+	pos, end int64
+
+	chunk    int64
+	buf, raw []byte
+}
+
+// newDecryptingReader returns a reader for the plaintext in the encrypted file
+// f. The reader takes ownership of f, it is not closed if an error is returned.
+func newDecryptingReader(f *os.File, c *cacheCipher) (*decryptingReader, error) {
+	header := make([]byte, encryptedHeaderSize)
+	if _, err := io.ReadFull(io.NewSectionReader(f, 0, int64(encryptedHeaderSize)), header); err != nil {
+		return nil, errors.Wrap(err, "read header")
+	}
+	if string(header[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, errors.New("file is not encrypted")
+	}
+
+	aead, err := c.fileAEAD(header[len(encryptedMagic):])
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	size, err := encryptedPlaintextSize(fi.Size(), aead.Overhead())
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptingReader{
+		f:     f,
+		aead:  aead,
+		size:  size,
+		end:   size,
+		chunk: -1,
+	}, nil
+}
+
+// limit restricts the reader to length bytes starting at offset. If length is
+// zero or negative, the plaintext is read until the end.
+func (r *decryptingReader) limit(length int, offset int64) {
+	r.pos = offset
+	if length > 0 {
+		r.end = offset + int64(length)
+	}
+}
+
+func (r *decryptingReader) chunks() int64 {
+	return (r.size + encryptedChunkSize - 1) / encryptedChunkSize
+}
+
+func (r *decryptingReader) load(chunk int64) error {
+	overhead := int64(r.aead.Overhead())
+	last := chunk == r.chunks()-1 || r.size == 0
+	length := int64(encryptedChunkSize)
+	if last {
+		length = r.size - chunk*encryptedChunkSize
+	}
+
+	if r.raw == nil {
+		r.raw = make([]byte, encryptedChunkSize+overhead)
+		r.buf = make([]byte, 0, encryptedChunkSize)
+	}
+	raw := r.raw[:length+overhead]
+	_, err := r.f.ReadAt(raw, int64(encryptedHeaderSize)+chunk*(encryptedChunkSize+overhead))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	r.buf, err = r.aead.Open(r.buf[:0], chunkNonce(r.aead, uint64(chunk), last), raw, nil)
+	if err != nil {
+		r.chunk = -1
+		return errors.Wrapf(err, "decrypt chunk %d", chunk)
+	}
+	r.chunk = chunk
+	return nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if r.pos >= r.end {
+		return 0, io.EOF
+	}
+
+	chunk := r.pos / encryptedChunkSize
+	if chunk != r.chunk {
+		if err := r.load(chunk); err != nil {
+			return 0, err
+		}
+	}
+
+	start := r.pos - chunk*encryptedChunkSize
+	end := int64(len(r.buf))
+	if rest := r.end - chunk*encryptedChunkSize; rest < end {
+		end = rest
+	}
+	n := copy(p, r.buf[start:end])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func writeEncryptedFile(t *testing.T, c *cacheCipher, data []byte) string {
+	filename := filepath.Join(t.TempDir(), "file")
+	f, err := os.Create(filename)
+	rtest.OK(t, err)
+	wr, err := newEncryptingWriter(f, c)
+	rtest.OK(t, err)
+	_, err = wr.Write(data)
+	rtest.OK(t, err)
+	rtest.OK(t, wr.Close())
+	rtest.OK(t, f.Close())
+	return filename
+}
+
+func readEncryptedFile(t *testing.T, c *cacheCipher, filename string, length int, offset int64) ([]byte, error) {
+	f, err := os.Open(filename)
+	rtest.OK(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+
+	rd, err := newDecryptingReader(f, c)
+	if err != nil {
+		return nil, err
+	}
+	rd.limit(length, offset)
+	return io.ReadAll(rd)
+}
+
+func TestEncryptedFile(t *testing.T) {
+	c := newCacheCipher(crypto.NewRandomKey())
+
+	for _, size := range []int{0, 1, 1000, encryptedChunkSize - 1, encryptedChunkSize, encryptedChunkSize + 1, 5*encryptedChunkSize + 17} {
+		data := rtest.Random(size, size)
+		filename := writeEncryptedFile(t, c, data)
+
+		fi, err := os.Stat(filename)
+		rtest.OK(t, err)
+		plaintextSize, err := encryptedPlaintextSize(fi.Size(), 16)
+		rtest.OK(t, err)
+		rtest.Equals(t, int64(size), plaintextSize)
+
+		ciphertext, err := os.ReadFile(filename)
+		rtest.OK(t, err)
+		rtest.Assert(t, size < 16 || !bytes.Contains(ciphertext, data[:16]), "file contains plaintext")
+
+		buf, err := readEncryptedFile(t, c, filename, 0, 0)
+		rtest.OK(t, err)
+		rtest.Assert(t, bytes.Equal(data, buf), "wrong data returned for size %d", size)
+
+		for _, part := range []struct {
+			length int
+			offset int64
+		}{
+			{0, int64(size / 2)},
+			{size / 3, int64(size / 3)},
+			{1, int64(size - 1)},
+			{encryptedChunkSize, 10},
+		} {
+			if part.offset+int64(part.length) > int64(size) || part.offset < 0 {
+				continue
+			}
+			buf, err := readEncryptedFile(t, c, filename, part.length, part.offset)
+			rtest.OK(t, err)
+			end := len(data)
+			if part.length > 0 {
+				end = int(part.offset) + part.length
+			}
+			rtest.Assert(t, bytes.Equal(data[part.offset:end], buf), "wrong data returned for size %d, part %v", size, part)
+		}
+	}
+}
+
+func TestEncryptedFileInvalid(t *testing.T) {
+	key := crypto.NewRandomKey()
+	c := newCacheCipher(key)
+	data := rtest.Random(23, 3*encryptedChunkSize)
+	filename := writeEncryptedFile(t, c, data)
+	ciphertext, err := os.ReadFile(filename)
+	rtest.OK(t, err)
+	chunk := encryptedChunkSize + 16
+
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"truncated header", ciphertext[:10]},
+		{"missing last chunk", ciphertext[:encryptedHeaderSize+2*chunk]},
+		{"truncated chunk", ciphertext[:len(ciphertext)-1]},
+		{"modified", func() []byte {
+			buf := bytes.Clone(ciphertext)
+			buf[encryptedHeaderSize+chunk+5] ^= 1
+			return buf
+		}()},
+		{"reordered", func() []byte {
+			buf := bytes.Clone(ciphertext[:encryptedHeaderSize])
+			buf = append(buf, ciphertext[encryptedHeaderSize+chunk:encryptedHeaderSize+2*chunk]...)
+			buf = append(buf, ciphertext[encryptedHeaderSize:encryptedHeaderSize+chunk]...)
+			return append(buf, ciphertext[encryptedHeaderSize+2*chunk:]...)
+		}()},
+		{"unencrypted", data},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rtest.OK(t, os.WriteFile(filename, test.data, 0600))
+			_, err := readEncryptedFile(t, c, filename, 0, 0)
+			rtest.Assert(t, err != nil, "invalid file was accepted")
+		})
+	}
+
+	// a different master key cannot decrypt the file
+	rtest.OK(t, os.WriteFile(filename, ciphertext, 0600))
+	_, err = readEncryptedFile(t, newCacheCipher(crypto.NewRandomKey()), filename, 0, 0)
+	rtest.Assert(t, err != nil, "file was decrypted with a different key")
+	// the cache key is derived deterministically
+	_, err = readEncryptedFile(t, newCacheCipher(key), filename, 0, 0)
+	rtest.OK(t, err)
+}
+
+func TestEncryptedCache(t *testing.T) {
+	basedir := rtest.TempDir(t)
+	id := restic.NewRandomID().String()
+	key := crypto.NewRandomKey()
+
+	saveRandomFile := func(c *Cache) (backend.Handle, []byte) {
+		data := rtest.Random(5, 1<<19)
+		h := backend.Handle{Type: restic.SnapshotFile, Name: restic.Hash(data).String()}
+		rtest.OK(t, c.save(h, bytes.NewReader(data)))
+		return h, data
+	}
+
+	c, err := New(id, basedir)
+	rtest.OK(t, err)
+	plainHandle, _ := saveRandomFile(c)
+	rtest.OK(t, os.WriteFile(filepath.Join(c.path, mappedIndexFilename), []byte("plaintext index"), fileMode))
+
+	// switching to an encrypted cache removes all plaintext files
+	c, err = NewEncrypted(id, basedir, key)
+	rtest.OK(t, err)
+	rtest.Assert(t, !c.Has(plainHandle), "unencrypted file still cached")
+	rtest.Equals(t, "", c.MappedIndexFilename())
+	_, err = os.Stat(filepath.Join(c.path, mappedIndexFilename))
+	rtest.Assert(t, os.IsNotExist(err), "mapped index was not removed: %v", err)
+
+	h, data := saveRandomFile(c)
+	rtest.Equals(t, data, load(t, c, h))
+	ciphertext, err := os.ReadFile(c.filename(h))
+	rtest.OK(t, err)
+	rtest.Assert(t, !bytes.Contains(ciphertext, data[:32]), "cached file contains plaintext")
+
+	rd, _, err := c.load(h, 100, 1000)
+	rtest.OK(t, err)
+	buf, err := io.ReadAll(rd)
+	rtest.OK(t, err)
+	rtest.OK(t, rd.Close())
+	rtest.Equals(t, data[1000:1100], buf)
+
+	_, _, err = c.load(h, 100, int64(len(data)))
+	rtest.Assert(t, err != nil, "reading beyond the end of the file was accepted")
+
+	// reopening the encrypted cache keeps the files
+	c, err = NewEncrypted(id, basedir, key)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, load(t, c, h))
+
+	// disabling encryption removes the encrypted files
+	c, err = New(id, basedir)
+	rtest.OK(t, err)
+	rtest.Assert(t, !c.Has(h), "encrypted file still cached")
+	rtest.Assert(t, c.MappedIndexFilename() != "", "mapped index not available")
+}
+
+func TestEncryptedCacheMixedFiles(t *testing.T) {
+	basedir := rtest.TempDir(t)
+	id := restic.NewRandomID().String()
+
+	// two processes use the same cache with different encryption settings
+	plain, err := New(id, basedir)
+	rtest.OK(t, err)
+	encrypted, err := NewEncrypted(id, basedir, crypto.NewRandomKey())
+	rtest.OK(t, err)
+
+	for _, test := range []struct {
+		name   string
+		wr, rd *Cache
+	}{
+		{"plaintext read as encrypted", plain, encrypted},
+		{"encrypted read as plaintext", encrypted, plain},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data := rtest.Random(23, 1<<16)
+			h := backend.Handle{Type: restic.SnapshotFile, Name: restic.Hash(data).String()}
+			rtest.OK(t, test.wr.save(h, bytes.NewReader(data)))
+
+			_, inCache, err := test.rd.load(h, 0, 0)
+			rtest.Assert(t, err != nil, "file with a different encryption setting was loaded")
+			rtest.Assert(t, !inCache, "file with a different encryption setting is reported as cached")
+			rtest.Assert(t, !test.rd.Has(h), "file with a different encryption setting was not removed")
+		})
+	}
+}
@@ -43,11 +43,37 @@ func (c *Cache) load(h backend.Handle, length int, offset int64) (io.ReadCloser,
 		return nil, false, errors.New("cannot be cached")
 	}
 
-	f, err := os.Open(c.filename(h))
+	return c.loadFile(c.filename(h), h, length, offset)
+}
+
+// loadFile returns a reader for the cached file filename, see load.
+func (c *Cache) loadFile(filename string, h backend.Handle, length int, offset int64) (io.ReadCloser, bool, error) {
+	f, err := os.Open(filename)
 	if err != nil {
 		return nil, false, errors.WithStack(err)
 	}
 
+	// A process using a different encryption setting may have written the
+	// file, check the header of the file instead of trusting the cache-wide
+	// marker. Such files are removed and fetched again.
+	encrypted, err := isEncryptedFile(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, true, err
+	}
+	if encrypted != (c.cipher != nil) {
+		_ = f.Close()
+		debug.Log("cached file %v uses a different encryption setting, removing", h)
+		if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, true, errors.WithStack(err)
+		}
+		return nil, false, errors.Errorf("cached file %v uses a different encryption setting", h)
+	}
+
+	if c.cipher != nil {
+		return loadEncryptedFile(f, c.cipher, h, length, offset)
+	}
+
 	fi, err := f.Stat()
 	if err != nil {
 		_ = f.Close()
@@ -78,6 +104,29 @@ func (c *Cache) load(h backend.Handle, length int, offset int64) (io.ReadCloser,
 	return util.LimitReadCloser(f, int64(length)), true, nil
 }
 
+// loadEncryptedFile returns a reader which decrypts the requested part of the
+// encrypted cached file f while it is read.
+func loadEncryptedFile(f *os.File, c *cacheCipher, h backend.Handle, length int, offset int64) (io.ReadCloser, bool, error) {
+	rd, err := newDecryptingReader(f, c)
+	if err != nil {
+		_ = f.Close()
+		return nil, true, errors.Wrapf(err, "cached file %v", h)
+	}
+
+	if rd.size <= int64(crypto.CiphertextLength(0)) {
+		_ = f.Close()
+		return nil, true, errors.Errorf("cached file %v is truncated", h)
+	}
+
+	if rd.size < offset+int64(length) {
+		_ = f.Close()
+		return nil, true, errors.Errorf("cached file %v is too short", h)
+	}
+
+	rd.limit(length, offset)
+	return rd, true, nil
+}
+
 // save saves a file in the cache.
 func (c *Cache) save(h backend.Handle, rd io.Reader) error {
 	debug.Log("Save to cache: %v", h)
@@ -88,7 +137,11 @@ func (c *Cache) save(h backend.Handle, rd io.Reader) error {
 		return errors.New("cannot be cached")
 	}
 
-	finalname := c.filename(h)
+	return c.saveFile(c.filename(h), h, rd)
+}
+
+// saveFile stores the content of rd as the cached file finalname, see save.
+func (c *Cache) saveFile(finalname string, h backend.Handle, rd io.Reader) error {
 	dir := filepath.Dir(finalname)
 	err := os.Mkdir(dir, 0700)
 	if err != nil && !errors.Is(err, os.ErrExist) {
@@ -102,7 +155,7 @@ func (c *Cache) save(h backend.Handle, rd io.Reader) error {
 		return err
 	}
 
-	n, err := io.Copy(f, rd)
+	n, err := c.copyToFile(f, rd)
 	if err != nil {
 		_ = f.Close()
 		_ = os.Remove(f.Name())
@@ -139,6 +192,24 @@ func (c *Cache) save(h backend.Handle, rd io.Reader) error {
 	return errors.WithStack(err)
 }
 
+// copyToFile writes the content of rd to f, which is encrypted if the cache
+// is encrypted. The number of plaintext bytes is returned.
+func (c *Cache) copyToFile(f *os.File, rd io.Reader) (int64, error) {
+	if c.cipher == nil {
+		return io.Copy(f, rd)
+	}
+
+	wr, err := newEncryptingWriter(f, c.cipher)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(wr, rd)
+	if err != nil {
+		return n, err
+	}
+	return n, wr.Close()
+}
+
 func (c *Cache) Forget(h backend.Handle) error {
 	h.IsMetadata = false
 
@@ -149,6 +220,11 @@ func (c *Cache) Forget(h backend.Handle) error {
 	}
 
 	removed, err := c.remove(h)
+	if err == nil {
+		var removedPack bool
+		removedPack, err = c.removePack(h)
+		removed = removed || removedPack
+	}
 	if removed {
 		c.forgotten.Store(h, struct{}{})
 	}
@@ -240,5 +316,5 @@ func (c *Cache) Has(h backend.Handle) bool {
 	}
 
 	_, err := os.Stat(c.filename(h))
-	return err == nil
+	return err == nil || (c.cachesPack(h) && c.hasPack(h))
 }
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+)
+
+// packCacheDir is the subdirectory of the cache in which data pack files are
+// stored. Unlike the other cache directories, it is not synchronized with the
+// repository, instead the least recently used pack files are removed as soon
+// as the configured size is exceeded.
+const packCacheDir = "packs"
+
+// EnablePackCache enables caching of data pack files which are loaded from the
+// repository. This speeds up repeatedly loading the same files, for example
+// when restoring them several times. The total size of the cached data pack
+// files is limited to maxSize, the least recently used pack files are removed
+// first. A maxSize of zero disables the cache.
+func (c *Cache) EnablePackCache(maxSize int64) {
+	debug.Log("enable pack cache with size %d", maxSize)
+	c.packCacheSize = maxSize
+}
+
+// cachesPack returns true if h is a data pack file which is stored in the pack
+// cache.
+func (c *Cache) cachesPack(h backend.Handle) bool {
+	return c != nil && c.packCacheSize > 0 && h.Type == backend.PackFile && !h.IsMetadata
+}
+
+func (c *Cache) packFilename(h backend.Handle) string {
+	if len(h.Name) < 2 {
+		panic("Name is empty or too short")
+	}
+	return filepath.Join(c.path, packCacheDir, h.Name[:2], h.Name)
+}
+
+// loadPack returns a reader for the cached pack file, see load. The pack file
+// is marked as recently used.
+func (c *Cache) loadPack(h backend.Handle, length int, offset int64) (io.ReadCloser, bool, error) {
+	debug.Log("Load(%v, %v, %v) from pack cache", h, length, offset)
+	filename := c.packFilename(h)
+
+	// the modification time is used to determine the least recently used files
+	// by later restic processes
+	now := time.Now()
+	if err := os.Chtimes(filename, now, now); err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	c.packCacheMutex.Lock()
+	if pack, ok := c.packIndex[filename]; ok {
+		pack.modTime = now
+		c.packIndex[filename] = pack
+	}
+	c.packCacheMutex.Unlock()
+
+	return c.loadFile(filename, h, length, offset)
+}
+
+// hasPack returns true if the pack file is cached.
+func (c *Cache) hasPack(h backend.Handle) bool {
+	_, err := os.Stat(c.packFilename(h))
+	return err == nil
+}
+
+// savePack stores a pack file in the cache and removes the least recently
+// used pack files if the cache is too large afterwards.
+func (c *Cache) savePack(h backend.Handle, rd io.Reader) error {
+	debug.Log("Save to pack cache: %v", h)
+	err := os.Mkdir(filepath.Join(c.path, packCacheDir), dirMode)
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	filename := c.packFilename(h)
+	err = c.saveFile(filename, h, rd)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		// truncated files are not cached
+		return nil
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	c.packCacheMutex.Lock()
+	defer c.packCacheMutex.Unlock()
+
+	if err := c.loadPackIndex(); err != nil {
+		return err
+	}
+	c.addPack(cachedPack{name: filename, size: fi.Size(), modTime: fi.ModTime()})
+	return c.expirePacks()
+}
+
+// removePack deletes a pack file from the pack cache. When the file is not
+// cached, no error is returned.
+func (c *Cache) removePack(h backend.Handle) (bool, error) {
+	if h.Type != backend.PackFile {
+		return false, nil
+	}
+
+	filename := c.packFilename(h)
+	c.packCacheMutex.Lock()
+	c.removePackFromIndex(filename)
+	c.packCacheMutex.Unlock()
+
+	err := os.Remove(filename)
+	removed := err == nil
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return removed, err
+}
+
+type cachedPack struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// loadPackIndex scans the pack cache directory once to find the pack files
+// which were cached by previous restic processes. The caller must hold
+// packCacheMutex.
+func (c *Cache) loadPackIndex() error {
+	if c.packIndex != nil {
+		return nil
+	}
+
+	index := make(map[string]cachedPack)
+	var total int64
+	err := filepath.Walk(filepath.Join(c.path, packCacheDir), func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// ignore ErrNotExist to gracefully handle multiple processes using the cache
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return errors.Wrap(err, "Walk")
+		}
+
+		if !isFile(fi) {
+			return nil
+		}
+		if _, err := restic.ParseID(filepath.Base(name)); err != nil {
+			// ignore temporary files
+			return nil
+		}
+
+		index[name] = cachedPack{name: name, size: fi.Size(), modTime: fi.ModTime()}
+		total += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.packIndex = index
+	c.packTotal = total
+	return nil
+}
+
+// addPack adds a pack file to the index or updates it. The caller must hold
+// packCacheMutex.
+func (c *Cache) addPack(pack cachedPack) {
+	c.removePackFromIndex(pack.name)
+	c.packIndex[pack.name] = pack
+	c.packTotal += pack.size
+}
+
+// removePackFromIndex removes a pack file from the index, if it is contained
+// in it. The caller must hold packCacheMutex.
+func (c *Cache) removePackFromIndex(name string) {
+	if pack, ok := c.packIndex[name]; ok {
+		delete(c.packIndex, name)
+		c.packTotal -= pack.size
+	}
+}
+
+// expirePacks removes the least recently used pack files until the total size
+// of the pack cache does not exceed the maximum size. Only the index is
+// consulted, pack files cached by concurrent restic processes are not
+// considered. The caller must hold packCacheMutex.
+func (c *Cache) expirePacks() error {
+	if c.packTotal <= c.packCacheSize {
+		return nil
+	}
+
+	packs := make([]cachedPack, 0, len(c.packIndex))
+	for _, pack := range c.packIndex {
+		packs = append(packs, pack)
+	}
+
+	sort.Slice(packs, func(i, j int) bool {
+		return packs[i].modTime.Before(packs[j].modTime)
+	})
+
+	for _, pack := range packs {
+		if c.packTotal <= c.packCacheSize {
+			break
+		}
+
+		debug.Log("removing %v from pack cache", pack.name)
+		// ignore ErrNotExist to gracefully handle multiple processes removing files
+		if err := os.Remove(pack.name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		c.removePackFromIndex(pack.name)
+	}
+	return nil
+}
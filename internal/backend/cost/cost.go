@@ -0,0 +1,108 @@
+// Package cost estimates the number of backend requests and the resulting
+// costs of repository operations for storage providers which charge per
+// request and for transferred data.
+package cost
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Operations counts the backend requests and the amount of transferred data
+// of a repository operation.
+type Operations struct {
+	List   uint64
+	Get    uint64
+	Put    uint64
+	Delete uint64
+
+	// Download and Upload are the transferred data in bytes.
+	Download uint64
+	Upload   uint64
+}
+
+// Add adds the requests and transferred data of other to ops.
+func (ops *Operations) Add(other Operations) {
+	ops.List += other.List
+	ops.Get += other.Get
+	ops.Put += other.Put
+	ops.Delete += other.Delete
+	ops.Download += other.Download
+	ops.Upload += other.Upload
+}
+
+// Requests returns the total number of requests.
+func (ops Operations) Requests() uint64 {
+	return ops.List + ops.Get + ops.Put + ops.Delete
+}
+
+// String returns a short summary of the requests.
+func (ops Operations) String() string {
+	return fmt.Sprintf("%d LIST, %d GET, %d PUT, %d DELETE requests", ops.List, ops.Get, ops.Put, ops.Delete)
+}
+
+// PriceTable contains the prices charged by a storage provider. Requests are
+// priced per 1000 requests, transferred data per GiB. The currency is not
+// relevant, all estimated costs use the same currency as the price table.
+type PriceTable struct {
+	List   float64
+	Get    float64
+	Put    float64
+	Delete float64
+
+	Download float64
+	Upload   float64
+}
+
+// ParsePriceTable parses a comma-separated list of prices like
+// "get=0.0004,put=0.005,download=0.09". Valid keys are list, get, put and
+// delete, which are priced per 1000 requests, as well as download and upload,
+// which are priced per GiB. Prices which are not specified are zero.
+func ParsePriceTable(s string) (PriceTable, error) {
+	var p PriceTable
+	prices := map[string]*float64{
+		"list":     &p.List,
+		"get":      &p.Get,
+		"put":      &p.Put,
+		"delete":   &p.Delete,
+		"download": &p.Download,
+		"upload":   &p.Upload,
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(entry, "=")
+		if !found {
+			return PriceTable{}, errors.Errorf("invalid price %q, expected key=value", entry)
+		}
+		price, ok := prices[strings.ToLower(strings.TrimSpace(key))]
+		if !ok {
+			return PriceTable{}, errors.Errorf("unknown price %q, valid keys are list, get, put, delete, download and upload", key)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return PriceTable{}, errors.Errorf("invalid price %q for %v", value, key)
+		}
+		*price = v
+	}
+	return p, nil
+}
+
+// Cost returns the estimated cost of ops.
+func (p PriceTable) Cost(ops Operations) float64 {
+	const gib = 1 << 30
+	return (float64(ops.List)*p.List+
+		float64(ops.Get)*p.Get+
+		float64(ops.Put)*p.Put+
+		float64(ops.Delete)*p.Delete)/1000 +
+		float64(ops.Download)/gib*p.Download +
+		float64(ops.Upload)/gib*p.Upload
+}
@@ -0,0 +1,47 @@
+package cost
+
+import (
+	"math"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParsePriceTable(t *testing.T) {
+	for _, test := range []struct {
+		input  string
+		prices PriceTable
+	}{
+		{"", PriceTable{}},
+		{"get=0.0004", PriceTable{Get: 0.0004}},
+		{"list=0.005, GET=0.0004,put=0.005,delete=0,download=0.09,upload=0.01,",
+			PriceTable{List: 0.005, Get: 0.0004, Put: 0.005, Download: 0.09, Upload: 0.01}},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			prices, err := ParsePriceTable(test.input)
+			rtest.OK(t, err)
+			rtest.Equals(t, test.prices, prices)
+		})
+	}
+
+	for _, input := range []string{"get", "get=", "get=-1", "get=inf", "head=0.1", "get=0.1;put=0.2"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParsePriceTable(input)
+			rtest.Assert(t, err != nil, "invalid price table %q accepted", input)
+		})
+	}
+}
+
+func TestCost(t *testing.T) {
+	prices := PriceTable{List: 5, Get: 0.4, Put: 5, Delete: 1, Download: 0.09, Upload: 0.02}
+
+	var ops Operations
+	ops.Add(Operations{List: 2, Get: 1000, Download: 1 << 30})
+	ops.Add(Operations{Put: 200, Delete: 100, Upload: 2 << 30})
+	rtest.Equals(t, Operations{List: 2, Get: 1000, Put: 200, Delete: 100, Download: 1 << 30, Upload: 2 << 30}, ops)
+	rtest.Equals(t, uint64(1302), ops.Requests())
+
+	expected := (2*5+1000*0.4+200*5+100*1)/1000.0 + 0.09 + 2*0.02
+	rtest.Assert(t, math.Abs(prices.Cost(ops)-expected) < 1e-9, "unexpected cost %v, want %v", prices.Cost(ops), expected)
+	rtest.Equals(t, 0.0, PriceTable{}.Cost(ops))
+}
@@ -0,0 +1,162 @@
+package gdrive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+)
+
+// defaultTokenFile returns the location of the token file if none is
+// configured.
+func defaultTokenFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "UserConfigDir")
+	}
+	return filepath.Join(dir, "restic", "gdrive-token.json"), nil
+}
+
+// loadToken reads a token from filename.
+func loadToken(filename string) (*oauth2.Token, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tok := &oauth2.Token{}
+	if err := json.Unmarshal(buf, tok); err != nil {
+		return nil, errors.Wrapf(err, "invalid token file %v", filename)
+	}
+	return tok, nil
+}
+
+// saveToken stores the token in filename, which is only readable by the
+// current user.
+func saveToken(filename string, tok *oauth2.Token) error {
+	buf, err := json.Marshal(tok)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// write to a temporary file first, such that the token file is never
+	// left behind incomplete
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, filename))
+}
+
+// savingTokenSource stores each new token returned by src in a file. This
+// preserves refreshed access tokens and rotated refresh tokens.
+type savingTokenSource struct {
+	src      oauth2.TokenSource
+	filename string
+
+	m    sync.Mutex
+	last string
+}
+
+func (ts *savingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := ts.src.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	ts.m.Lock()
+	defer ts.m.Unlock()
+	if tok.AccessToken != ts.last {
+		ts.last = tok.AccessToken
+		if err := saveToken(ts.filename, tok); err != nil {
+			// the token is still usable, it just has to be refreshed again
+			debug.Log("unable to save token: %v", err)
+		}
+	}
+	return tok, nil
+}
+
+// authorize runs the OAuth device flow. The user has to visit the
+// verification URL and enter the displayed code to grant access.
+func authorize(ctx context.Context, conf *oauth2.Config) (*oauth2.Token, error) {
+	resp, err := conf.DeviceAuth(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "DeviceAuth")
+	}
+
+	fmt.Fprintf(os.Stderr, "To allow restic to access Google Drive, visit %v and enter the code %v\n",
+		resp.VerificationURI, resp.UserCode)
+
+	tok, err := conf.DeviceAccessToken(ctx, resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "DeviceAccessToken")
+	}
+	return tok, nil
+}
+
+// tokenSource returns the token source used to access Google Drive. If an
+// access token is configured, it is used as-is.
+// Otherwise, the token is read from the token file. If the file does not
+// exist yet, the user is asked to authorize restic.
+//
+// ctx must carry the HTTP client used for token requests and must stay valid
+// as long as the token source is used.
+func tokenSource(ctx context.Context, cfg Config) (oauth2.TokenSource, error) {
+	if token := cfg.AccessToken.Unwrap(); token != "" {
+		return oauth2.StaticTokenSource(&oauth2.Token{
+			AccessToken: token,
+			TokenType:   "Bearer",
+		}), nil
+	}
+
+	if cfg.ClientID == "" || cfg.ClientSecret.Unwrap() == "" {
+		return nil, errors.Fatal("unable to open Google Drive backend: client ID ($GOOGLE_DRIVE_CLIENT_ID) or client secret ($GOOGLE_DRIVE_CLIENT_SECRET) is empty")
+	}
+
+	conf := &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret.Unwrap(),
+		Endpoint:     google.Endpoint,
+		// the device flow only supports access to files created by restic
+		Scopes: []string{drive.DriveFileScope},
+	}
+
+	filename := cfg.TokenFile
+	if filename == "" {
+		var err error
+		filename, err = defaultTokenFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tok, err := loadToken(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		tok, err = authorize(ctx, conf)
+		if err == nil {
+			err = saveToken(filename, tok)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &savingTokenSource{
+		src:      conf.TokenSource(ctx, tok),
+		filename: filename,
+		last:     tok.AccessToken,
+	}, nil
+}
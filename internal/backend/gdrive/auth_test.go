@@ -0,0 +1,58 @@
+package gdrive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/oauth2"
+)
+
+func TestSaveLoadToken(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "restic", "token.json")
+	tok := &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		Expiry:       time.Unix(1700000000, 0).UTC(),
+	}
+	rtest.OK(t, saveToken(filename, tok))
+
+	fi, err := os.Stat(filename)
+	rtest.OK(t, err)
+	if os.PathSeparator == '/' {
+		rtest.Equals(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	loaded, err := loadToken(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, tok.AccessToken, loaded.AccessToken)
+	rtest.Equals(t, tok.RefreshToken, loaded.RefreshToken)
+	rtest.Assert(t, tok.Expiry.Equal(loaded.Expiry), "expiry mismatch: %v != %v", tok.Expiry, loaded.Expiry)
+}
+
+func TestSavingTokenSource(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "token.json")
+	ts := &savingTokenSource{
+		src:      oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "new"}),
+		filename: filename,
+		last:     "old",
+	}
+
+	tok, err := ts.Token()
+	rtest.OK(t, err)
+	rtest.Equals(t, "new", tok.AccessToken)
+
+	// the refreshed token is stored in the file
+	loaded, err := loadToken(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, "new", loaded.AccessToken)
+
+	// the token is only written again if it changes
+	rtest.OK(t, os.Remove(filename))
+	_, err = ts.Token()
+	rtest.OK(t, err)
+	_, err = os.Stat(filename)
+	rtest.Assert(t, os.IsNotExist(err), "token file was written again")
+}
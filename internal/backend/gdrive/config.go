@@ -0,0 +1,73 @@
+package gdrive
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// Config contains all configuration necessary to access a repository stored
+// in a folder on Google Drive.
+type Config struct {
+	Path         string
+	ClientID     string
+	ClientSecret options.SecretString
+	AccessToken  options.SecretString
+
+	TokenFile   string `option:"token-file" help:"file to store the OAuth token in (default: $GOOGLE_DRIVE_TOKEN_FILE or gdrive-token.json in the restic config directory)"`
+	ChunkSize   uint   `option:"chunk-size" help:"upload files in chunks of this many MiB (default: 8, 0 disables chunked uploads)"`
+	Connections uint   `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+}
+
+// NewConfig returns a new Config with the default values filled in.
+func NewConfig() Config {
+	return Config{
+		ChunkSize:   8,
+		Connections: 5,
+	}
+}
+
+func init() {
+	options.Register("gdrive", NewConfig())
+}
+
+// ParseConfig parses the string s and extracts the Google Drive config. The
+// supported configuration format is gdrive:path/to/folder, the path is
+// relative to the root folder of the drive ("My Drive").
+func ParseConfig(s string) (*Config, error) {
+	p, ok := strings.CutPrefix(s, "gdrive:")
+	if !ok {
+		return nil, errors.New("gdrive: invalid format")
+	}
+
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil, errors.New("gdrive: invalid format, folder path not found")
+	}
+
+	cfg := NewConfig()
+	cfg.Path = p
+	return &cfg, nil
+}
+
+var _ backend.ApplyEnvironmenter = &Config{}
+
+// ApplyEnvironment saves values from the environment to the config.
+func (cfg *Config) ApplyEnvironment(prefix string) {
+	if cfg.ClientID == "" {
+		cfg.ClientID = os.Getenv(prefix + "GOOGLE_DRIVE_CLIENT_ID")
+	}
+	if cfg.ClientSecret.String() == "" {
+		cfg.ClientSecret = options.NewSecretString(os.Getenv(prefix + "GOOGLE_DRIVE_CLIENT_SECRET"))
+	}
+	if cfg.AccessToken.String() == "" {
+		cfg.AccessToken = options.NewSecretString(os.Getenv(prefix + "GOOGLE_DRIVE_ACCESS_TOKEN"))
+	}
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = os.Getenv(prefix + "GOOGLE_DRIVE_TOKEN_FILE")
+	}
+}
@@ -0,0 +1,38 @@
+package gdrive
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/backend/test"
+)
+
+var configTests = []test.ConfigTestData[Config]{
+	{S: "gdrive:restic", Cfg: Config{
+		Path:        "restic",
+		ChunkSize:   8,
+		Connections: 5,
+	}},
+	{S: "gdrive:/backups/restic/", Cfg: Config{
+		Path:        "backups/restic",
+		ChunkSize:   8,
+		Connections: 5,
+	}},
+	{S: "gdrive:backups//../restic", Cfg: Config{
+		Path:        "restic",
+		ChunkSize:   8,
+		Connections: 5,
+	}},
+}
+
+func TestParseConfig(t *testing.T) {
+	test.ParseConfigTester(t, ParseConfig, configTests)
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	for _, s := range []string{"gdrive:", "gdrive:/", "gdrive:..", "gs:bucket:/"} {
+		_, err := ParseConfig(s)
+		if err == nil {
+			t.Errorf("ParseConfig(%q) did not return an error", s)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+// Package gdrive provides a restic backend for Google Drive.
+package gdrive
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/layout"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/util"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// Backend stores data in a folder on Google Drive.
+//
+// Google Drive identifies files by an ID, names need not be unique. The
+// backend resolves the paths of the repository layout by looking up each
+// path element in its parent folder. The IDs of folders are cached.
+type Backend struct {
+	service      *drive.Service
+	connections  uint
+	chunkSize    int
+	listMaxItems int64
+	layout.Layout
+
+	// rateLimitTimeout is the maximum time spent retrying a request which
+	// failed because a rate limit was exceeded.
+	rateLimitTimeout time.Duration
+
+	folderMutex sync.Mutex
+	folders     map[string]string
+}
+
+// Ensure that *Backend implements backend.Backend.
+var _ backend.Backend = &Backend{}
+
+func NewFactory() location.Factory {
+	return location.NewHTTPBackendFactory("gdrive", ParseConfig, location.NoPassword, Create, Open)
+}
+
+const defaultListMaxItems = 1000
+
+// clientOptions are additional options for the Drive client. Tests use them
+// to connect to a fake server.
+var clientOptions []option.ClientOption
+
+func open(ctx context.Context, cfg Config, rt http.RoundTripper) (*Backend, error) {
+	debug.Log("open, config %#v", cfg)
+
+	// the token source uses the context for refreshing tokens later on, thus
+	// it must not be canceled when the backend is opened
+	authCtx := context.WithValue(context.WithoutCancel(ctx), oauth2.HTTPClient, &http.Client{Transport: rt})
+
+	ts, err := tokenSource(authCtx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append([]option.ClientOption{option.WithHTTPClient(oauth2.NewClient(authCtx, ts))}, clientOptions...)
+	service, err := drive.NewService(authCtx, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "drive.NewService")
+	}
+
+	be := &Backend{
+		service:          service,
+		connections:      cfg.Connections,
+		chunkSize:        int(cfg.ChunkSize) * 1024 * 1024,
+		listMaxItems:     defaultListMaxItems,
+		Layout:           layout.NewDefaultLayout(cfg.Path, path.Join),
+		rateLimitTimeout: 5 * time.Minute,
+		folders:          map[string]string{".": "root"},
+	}
+
+	return be, nil
+}
+
+// Open opens the repository in the folder on Google Drive.
+func Open(ctx context.Context, cfg Config, rt http.RoundTripper) (backend.Backend, error) {
+	be, err := open(ctx, cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = be.folderID(ctx, cfg.Path, false)
+	if be.IsNotExist(err) {
+		return nil, backend.ErrNoRepository
+	}
+	if err != nil {
+		return nil, err
+	}
+	return be, nil
+}
+
+// Create opens the backend and creates the folder of the repository if it
+// does not exist yet.
+func Create(ctx context.Context, cfg Config, rt http.RoundTripper) (backend.Backend, error) {
+	be, err := open(ctx, cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := be.folderID(ctx, cfg.Path, true); err != nil {
+		return nil, err
+	}
+	return be, nil
+}
+
+// SetListMaxItems sets the number of list items to load per request.
+func (be *Backend) SetListMaxItems(i int) {
+	be.listMaxItems = int64(i)
+}
+
+// quote returns s as a string literal for a Drive search query.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// isRateLimit returns true if the error was caused by exceeding a rate limit.
+func isRateLimit(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+
+	if gerr.Code == http.StatusTooManyRequests {
+		return true
+	}
+	if gerr.Code == http.StatusForbidden {
+		for _, item := range gerr.Errors {
+			if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withBackoff runs fn until it does not fail because of a rate limit. The
+// time between attempts increases exponentially. Other errors are returned
+// immediately, those are retried by the retry backend. fn must not return a
+// backoff.PermanentError.
+func (be *Backend) withBackoff(ctx context.Context, fn func() error) error {
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = time.Second
+	bo.MaxInterval = time.Minute
+	bo.MaxElapsedTime = be.rateLimitTimeout
+
+	return backoff.Retry(func() error {
+		err := fn()
+		if err != nil && !isRateLimit(err) {
+			return backoff.Permanent(err)
+		}
+		if err != nil {
+			debug.Log("rate limit exceeded: %v", err)
+		}
+		return err
+	}, backoff.WithContext(bo, ctx))
+}
+
+// findFiles returns the files and folders with the given name in the parent
+// folder.
+func (be *Backend) findFiles(ctx context.Context, parent string, name string) ([]*drive.File, error) {
+	query := fmt.Sprintf("name = %v and %v in parents and trashed = false", quote(name), quote(parent))
+
+	var files []*drive.File
+	err := be.withBackoff(ctx, func() error {
+		files = nil
+		return be.service.Files.List().Q(query).
+			Fields("nextPageToken", "files(id, name, size, mimeType)").
+			PageSize(be.listMaxItems).
+			Pages(ctx, func(list *drive.FileList) error {
+				files = append(files, list.Files...)
+				return nil
+			})
+	})
+	return files, err
+}
+
+// folderID returns the ID of the folder at dir. If the folder does not exist
+// and create is set, it is created. Otherwise, an error is returned which is
+// recognized by IsNotExist.
+func (be *Backend) folderID(ctx context.Context, dir string, create bool) (string, error) {
+	dir = path.Clean(dir)
+
+	// serialize all lookups so that concurrent calls do not create the
+	// same folder twice
+	be.folderMutex.Lock()
+	defer be.folderMutex.Unlock()
+
+	return be.lookupFolder(ctx, dir, create)
+}
+
+func (be *Backend) lookupFolder(ctx context.Context, dir string, create bool) (string, error) {
+	if id, ok := be.folders[dir]; ok {
+		return id, nil
+	}
+
+	parent, err := be.lookupFolder(ctx, path.Dir(dir), create)
+	if err != nil {
+		return "", err
+	}
+
+	name := path.Base(dir)
+	files, err := be.findFiles(ctx, parent, name)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if f.MimeType == folderMimeType {
+			be.folders[dir] = f.Id
+			return f.Id, nil
+		}
+	}
+
+	if !create {
+		return "", &os.PathError{Op: "open", Path: dir, Err: os.ErrNotExist}
+	}
+
+	var folder *drive.File
+	err = be.withBackoff(ctx, func() error {
+		folder, err = be.service.Files.Create(&drive.File{
+			Name:     name,
+			MimeType: folderMimeType,
+			Parents:  []string{parent},
+		}).Fields("id").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "Create")
+	}
+
+	be.folders[dir] = folder.Id
+	return folder.Id, nil
+}
+
+// findFile returns the file for the handle.
+func (be *Backend) findFile(ctx context.Context, h backend.Handle) (*drive.File, error) {
+	files, err := be.findAll(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, &os.PathError{Op: "open", Path: be.Filename(h), Err: os.ErrNotExist}
+	}
+	return files[0], nil
+}
+
+// findAll returns all files with the name of the handle. Usually there is at
+// most one file, however Drive does not prevent creating files with the same
+// name.
+func (be *Backend) findAll(ctx context.Context, h backend.Handle) ([]*drive.File, error) {
+	parent, err := be.folderID(ctx, be.Dirname(h), false)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := be.findFiles(ctx, parent, path.Base(be.Filename(h)))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*drive.File
+	for _, f := range files {
+		if f.MimeType != folderMimeType {
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+// IsNotExist returns true if the error is caused by a not existing file.
+func (be *Backend) IsNotExist(err error) bool {
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusNotFound
+}
+
+func (be *Backend) IsPermanentError(err error) bool {
+	if be.IsNotExist(err) {
+		return true
+	}
+
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		if gerr.Code == http.StatusRequestedRangeNotSatisfiable || gerr.Code == http.StatusUnauthorized {
+			return true
+		}
+		if gerr.Code == http.StatusForbidden && !isRateLimit(err) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (be *Backend) Connections() uint {
+	return be.connections
+}
+
+// ParallelRangeRequests implements backend.ParallelRangeBackend. All
+// connections can be used to load different parts of a single file.
+func (be *Backend) ParallelRangeRequests() uint {
+	return be.Connections()
+}
+
+// Hasher may return a hash function for calculating a content hash for the backend
+func (be *Backend) Hasher() hash.Hash {
+	return nil
+}
+
+// HasAtomicReplace returns whether Save() can atomically replace files
+func (be *Backend) HasAtomicReplace() bool {
+	return false
+}
+
+// Save stores data in the backend at the handle. Files larger than the chunk
+// size are uploaded using a resumable upload in chunks.
+func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	parent, err := be.folderID(ctx, be.Dirname(h), true)
+	if err != nil {
+		return err
+	}
+
+	var f *drive.File
+	err = be.withBackoff(ctx, func() error {
+		if err := rd.Rewind(); err != nil {
+			return err
+		}
+
+		f, err = be.service.Files.Create(&drive.File{
+			Name:    path.Base(be.Filename(h)),
+			Parents: []string{parent},
+		}).Media(rd, googleapi.ChunkSize(be.chunkSize), googleapi.ContentType("application/octet-stream")).
+			Fields("id", "size").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// sanity check
+	if f.Size != rd.Length() {
+		return errors.Errorf("wrote %d bytes instead of the expected %d bytes", f.Size, rd.Length())
+	}
+	return nil
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	return util.DefaultLoad(ctx, h, length, offset, be.openReader, fn)
+}
+
+func (be *Backend) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+	f, err := be.findFile(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > f.Size || (length > 0 && f.Size < offset+int64(length)) {
+		return nil, &googleapi.Error{Code: http.StatusRequestedRangeNotSatisfiable, Message: "restic-file-too-short"}
+	}
+	if offset == f.Size {
+		// Drive rejects requests for an empty range
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	var resp *http.Response
+	err = be.withBackoff(ctx, func() error {
+		call := be.service.Files.Get(f.Id).Context(ctx)
+		if length > 0 {
+			call.Header().Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(length)-1))
+		} else if offset > 0 {
+			call.Header().Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		resp, err = call.Download()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat returns information about a blob.
+func (be *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	f, err := be.findFile(ctx, h)
+	if err != nil {
+		return backend.FileInfo{}, err
+	}
+
+	return backend.FileInfo{Size: f.Size, Name: h.Name}, nil
+}
+
+// Remove removes all files with the given name and type.
+func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
+	files, err := be.findAll(ctx, h)
+	if be.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		err := be.withBackoff(ctx, func() error {
+			return be.service.Files.Delete(f.Id).Context(ctx).Do()
+		})
+		if err != nil && !be.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// listFolder runs fn for all entries of the folder with the given ID.
+func (be *Backend) listFolder(ctx context.Context, id string, fn func(*drive.File) error) error {
+	query := fmt.Sprintf("%v in parents and trashed = false", quote(id))
+
+	// fn must only be called once for each file, thus only the first page
+	// request is retried on rate limits
+	started := false
+	return be.withBackoff(ctx, func() error {
+		if started {
+			return errors.New("listing was interrupted by a rate limit")
+		}
+		return be.service.Files.List().Q(query).
+			Fields("nextPageToken", "files(id, name, size, mimeType)").
+			PageSize(be.listMaxItems).
+			Pages(ctx, func(list *drive.FileList) error {
+				started = true
+				for _, f := range list.Files {
+					if err := fn(f); err != nil {
+						return err
+					}
+				}
+				return ctx.Err()
+			})
+	})
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it.
+func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	basedir, subdirs := be.Basedir(t)
+	id, err := be.folderID(ctx, basedir, false)
+	if be.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var folders []string
+	err = be.listFolder(ctx, id, func(f *drive.File) error {
+		if f.MimeType == folderMimeType {
+			if subdirs {
+				folders = append(folders, f.Id)
+			}
+			return nil
+		}
+		return fn(backend.FileInfo{Name: f.Name, Size: f.Size})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range folders {
+		err = be.listFolder(ctx, folder, func(f *drive.File) error {
+			if f.MimeType == folderMimeType {
+				return nil
+			}
+			return fn(backend.FileInfo{Name: f.Name, Size: f.Size})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Delete removes all restic files in the folder. It will not remove the
+// folder itself.
+func (be *Backend) Delete(ctx context.Context) error {
+	return util.DefaultDelete(ctx, be)
+}
+
+// Close does nothing.
+func (be *Backend) Close() error { return nil }
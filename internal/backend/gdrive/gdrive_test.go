@@ -0,0 +1,345 @@
+package gdrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+	rtest "github.com/restic/restic/internal/test"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// fakeDrive implements the subset of the Drive API used by the backend.
+type fakeDrive struct {
+	m       sync.Mutex
+	nextID  int
+	files   map[string]*drive.File
+	data    map[string][]byte
+	uploads map[string]*fakeUpload
+	// chunks is the number of chunks received by resumable uploads
+	chunks int
+
+	// rateLimited is the number of requests which fail because of a rate
+	// limit before the next request is processed.
+	rateLimited int
+}
+
+type fakeUpload struct {
+	file *drive.File
+	data []byte
+}
+
+func newFakeDrive() *fakeDrive {
+	return &fakeDrive{
+		files:   make(map[string]*drive.File),
+		data:    make(map[string][]byte),
+		uploads: make(map[string]*fakeUpload),
+	}
+}
+
+var (
+	queryNameInParent = regexp.MustCompile(`^name = '((?:[^'\\]|\\.)*)' and '([^']*)' in parents and trashed = false$`)
+	queryParent       = regexp.MustCompile(`^'([^']*)' in parents and trashed = false$`)
+)
+
+func unquote(s string) string {
+	s = strings.ReplaceAll(s, `\'`, `'`)
+	return strings.ReplaceAll(s, `\\`, `\`)
+}
+
+func writeError(w http.ResponseWriter, code int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": reason,
+			"errors":  []map[string]string{{"reason": reason, "message": reason}},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (d *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.rateLimited > 0 {
+		d.rateLimited--
+		writeError(w, http.StatusForbidden, "userRateLimitExceeded")
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/drive/v3/files" && r.Method == http.MethodGet:
+		d.list(w, r)
+	case r.URL.Path == "/drive/v3/files" && r.Method == http.MethodPost:
+		f := &drive.File{}
+		if err := json.NewDecoder(r.Body).Decode(f); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, d.create(f, nil))
+	case r.URL.Path == "/upload/drive/v3/files":
+		d.upload(w, r)
+	case strings.HasPrefix(r.URL.Path, "/upload/session/"):
+		d.uploadChunk(w, r, strings.TrimPrefix(r.URL.Path, "/upload/session/"))
+	case strings.HasPrefix(r.URL.Path, "/drive/v3/files/"):
+		id := strings.TrimPrefix(r.URL.Path, "/drive/v3/files/")
+		f, ok := d.files[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "notFound")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			http.ServeContent(w, r, f.Name, time.Time{}, bytes.NewReader(d.data[id]))
+		case http.MethodDelete:
+			delete(d.files, id)
+			delete(d.data, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "badMethod")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "notFound")
+	}
+}
+
+func (d *fakeDrive) create(f *drive.File, data []byte) *drive.File {
+	d.nextID++
+	f.Id = fmt.Sprintf("id%d", d.nextID)
+	f.Size = int64(len(data))
+	d.files[f.Id] = f
+	d.data[f.Id] = data
+	return f
+}
+
+func (d *fakeDrive) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	var match func(f *drive.File) bool
+	if m := queryNameInParent.FindStringSubmatch(q); m != nil {
+		name, parent := unquote(m[1]), m[2]
+		match = func(f *drive.File) bool {
+			return f.Name == name && len(f.Parents) == 1 && f.Parents[0] == parent
+		}
+	} else if m := queryParent.FindStringSubmatch(q); m != nil {
+		match = func(f *drive.File) bool {
+			return len(f.Parents) == 1 && f.Parents[0] == m[1]
+		}
+	} else {
+		writeError(w, http.StatusBadRequest, "invalidQuery")
+		return
+	}
+
+	var result []*drive.File
+	for _, f := range d.files {
+		if match(f) {
+			result = append(result, f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+
+	// paginate the result
+	start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	list := &drive.FileList{Files: result[start:]}
+	if size > 0 && len(list.Files) > size {
+		list.Files = list.Files[:size]
+		list.NextPageToken = strconv.Itoa(start + size)
+	}
+	writeJSON(w, list)
+}
+
+func (d *fakeDrive) upload(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("uploadType") {
+	case "multipart":
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		var parts [][]byte
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			buf, _ := io.ReadAll(p)
+			parts = append(parts, buf)
+		}
+		if len(parts) != 2 {
+			writeError(w, http.StatusBadRequest, "invalidMultipart")
+			return
+		}
+		f := &drive.File{}
+		if err := json.Unmarshal(parts[0], f); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, d.create(f, parts[1]))
+
+	case "resumable":
+		f := &drive.File{}
+		if err := json.NewDecoder(r.Body).Decode(f); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		d.nextID++
+		session := strconv.Itoa(d.nextID)
+		d.uploads[session] = &fakeUpload{file: f}
+		w.Header().Set("Location", "http://"+r.Host+"/upload/session/"+session)
+
+	default:
+		writeError(w, http.StatusBadRequest, "invalidUploadType")
+	}
+}
+
+func (d *fakeDrive) uploadChunk(w http.ResponseWriter, r *http.Request, session string) {
+	up, ok := d.uploads[session]
+	if !ok {
+		writeError(w, http.StatusNotFound, "notFound")
+		return
+	}
+
+	buf, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	up.data = append(up.data, buf...)
+	d.chunks++
+
+	// the total size is only known for the final chunk
+	if strings.HasSuffix(r.Header.Get("Content-Range"), "/*") {
+		w.Header().Set("X-Http-Status-Code-Override", "308")
+		return
+	}
+
+	delete(d.uploads, session)
+	writeJSON(w, d.create(up.file, up.data))
+}
+
+func newTestServer(t testing.TB) *fakeDrive {
+	fake := newFakeDrive()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("GOOGLE_DRIVE_ACCESS_TOKEN", "token")
+	old := clientOptions
+	clientOptions = []option.ClientOption{option.WithEndpoint(srv.URL + "/drive/v3/")}
+	t.Cleanup(func() { clientOptions = old })
+	return fake
+}
+
+func newFakeDriveTestSuite(chunkSize uint) *test.Suite[Config] {
+	return &test.Suite[Config]{
+		MinimalData: true,
+		NewConfig: func() (*Config, error) {
+			cfg, err := ParseConfig("gdrive:backups/restic")
+			if err != nil {
+				return nil, err
+			}
+			cfg.ApplyEnvironment("")
+			cfg.ChunkSize = chunkSize
+			return cfg, nil
+		},
+		Factory: NewFactory(),
+	}
+}
+
+func TestBackendFakeDrive(t *testing.T) {
+	newTestServer(t)
+	newFakeDriveTestSuite(0).RunTests(t)
+}
+
+func TestBackendFakeDriveChunked(t *testing.T) {
+	fake := newTestServer(t)
+	newFakeDriveTestSuite(1).RunTests(t)
+	rtest.Assert(t, fake.chunks > 0, "no chunked uploads were used")
+}
+
+func TestOpenMissingRepository(t *testing.T) {
+	newTestServer(t)
+
+	cfg, err := ParseConfig("gdrive:missing")
+	rtest.OK(t, err)
+	cfg.ApplyEnvironment("")
+	_, err = Open(context.TODO(), *cfg, http.DefaultTransport)
+	rtest.Assert(t, errors.Is(err, backend.ErrNoRepository), "unexpected error %v", err)
+}
+
+func TestRateLimitBackoff(t *testing.T) {
+	fake := newTestServer(t)
+
+	cfg, err := ParseConfig("gdrive:restic")
+	rtest.OK(t, err)
+	cfg.ApplyEnvironment("")
+	be, err := Create(context.TODO(), *cfg, http.DefaultTransport)
+	rtest.OK(t, err)
+
+	h := backend.Handle{Type: backend.ConfigFile}
+	data := []byte("config")
+	fake.m.Lock()
+	fake.rateLimited = 2
+	fake.m.Unlock()
+	rtest.OK(t, be.Save(context.TODO(), h, backend.NewByteReader(data, be.Hasher())))
+
+	fake.m.Lock()
+	rtest.Equals(t, 0, fake.rateLimited)
+	fake.m.Unlock()
+
+	fi, err := be.Stat(context.TODO(), h)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len(data)), fi.Size)
+}
+
+func TestIsRateLimit(t *testing.T) {
+	for _, test := range []struct {
+		err       error
+		rateLimit bool
+	}{
+		{&googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}, true},
+		{errors.Wrap(&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, "List"), true},
+		{&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "insufficientFilePermissions"}}}, false},
+		{&googleapi.Error{Code: http.StatusNotFound}, false},
+		{errors.New("other"), false},
+	} {
+		rtest.Equals(t, test.rateLimit, isRateLimit(test.err))
+	}
+}
+
+func TestQuote(t *testing.T) {
+	rtest.Equals(t, `'restic'`, quote("restic"))
+	rtest.Equals(t, `'it\'s'`, quote("it's"))
+	rtest.Equals(t, `'a\\b'`, quote(`a\b`))
+}
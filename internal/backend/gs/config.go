@@ -20,6 +20,7 @@ type Config struct {
 
 	Connections uint   `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
 	Region      string `option:"region" help:"region to create the bucket in (default: us)"`
+	Labels      string `option:"labels" help:"set custom metadata on uploaded files, e.g. team=infra,purpose=backup"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -31,7 +32,7 @@ func NewConfig() Config {
 }
 
 func init() {
-	options.Register("gs", Config{})
+	options.Register("gs", NewConfig())
 }
 
 // ParseConfig parses the string s and extracts the gcs config. The
@@ -43,6 +43,7 @@ type Backend struct {
 	bucket       *storage.BucketHandle
 	prefix       string
 	listMaxItems int
+	labels       map[string]string
 	layout.Layout
 }
 
@@ -99,6 +100,11 @@ const defaultListMaxItems = 1000
 func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 	debug.Log("open, config %#v", cfg)
 
+	labels, err := backend.ParseTags(cfg.Labels)
+	if err != nil {
+		return nil, errors.Fatalf("%v", err)
+	}
+
 	gcsClient, err := getStorageClient(rt)
 	if err != nil {
 		return nil, errors.Wrap(err, "getStorageClient")
@@ -114,6 +120,7 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 		prefix:       cfg.Prefix,
 		Layout:       layout.NewDefaultLayout(cfg.Prefix, path.Join),
 		listMaxItems: defaultListMaxItems,
+		labels:       labels,
 	}
 
 	return be, nil
@@ -190,6 +197,12 @@ func (be *Backend) Connections() uint {
 	return be.connections
 }
 
+// ParallelRangeRequests implements backend.ParallelRangeBackend. All
+// connections can be used to load different parts of a single file.
+func (be *Backend) ParallelRangeRequests() uint {
+	return be.Connections()
+}
+
 // Hasher may return a hash function for calculating a content hash for the backend
 func (be *Backend) Hasher() hash.Hash {
 	return md5.New()
@@ -237,6 +250,7 @@ func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 	w := be.bucket.Object(objName).NewWriter(ctx)
 	w.ChunkSize = 0
 	w.MD5 = rd.Hash()
+	w.Metadata = be.labels
 	wbytes, err := io.Copy(w, rd)
 	cerr := w.Close()
 	if err == nil {
@@ -21,6 +21,7 @@ var defaultLayoutPaths = map[backend.FileType]string{
 	backend.IndexFile:    "index",
 	backend.LockFile:     "locks",
 	backend.KeyFile:      "keys",
+	backend.StateFile:    "state",
 }
 
 func NewDefaultLayout(path string, join func(...string) string) *DefaultLayout {
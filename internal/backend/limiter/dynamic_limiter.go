@@ -0,0 +1,57 @@
+package limiter
+
+import (
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// DynamicLimiter is a Limiter whose upload and download limits can be changed
+// while it is in use.
+type DynamicLimiter struct {
+	staticLimiter
+
+	m      sync.Mutex
+	limits Limits
+}
+
+// NewDynamicLimiter constructs a Limiter with the initial limits l.
+func NewDynamicLimiter(l Limits) *DynamicLimiter {
+	d := &DynamicLimiter{
+		staticLimiter: staticLimiter{
+			upstream:   rate.NewLimiter(rate.Inf, 0),
+			downstream: rate.NewLimiter(rate.Inf, 0),
+		},
+	}
+	d.SetLimits(l)
+	return d
+}
+
+// SetLimits changes the limits. Transfers which are currently in progress
+// use the new limits for all data transferred from now on.
+func (d *DynamicLimiter) SetLimits(l Limits) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.limits = l
+	setBucketRate(d.upstream, l.UploadKb)
+	setBucketRate(d.downstream, l.DownloadKb)
+}
+
+// Limits returns the currently active limits.
+func (d *DynamicLimiter) Limits() Limits {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	return d.limits
+}
+
+func setBucketRate(bucket *rate.Limiter, kb int) {
+	if kb <= 0 {
+		bucket.SetLimit(rate.Inf)
+		return
+	}
+
+	bucket.SetBurst(int(toByteRate(kb)))
+	bucket.SetLimit(rate.Limit(toByteRate(kb)))
+}
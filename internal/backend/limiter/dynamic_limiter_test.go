@@ -0,0 +1,30 @@
+package limiter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/restic/restic/internal/test"
+	"golang.org/x/time/rate"
+)
+
+func TestDynamicLimiterSetLimits(t *testing.T) {
+	lim := NewDynamicLimiter(Limits{})
+	test.Equals(t, rate.Inf, lim.upstream.Limit())
+	test.Equals(t, rate.Inf, lim.downstream.Limit())
+
+	// unlimited transfers must not block
+	n, err := io.Copy(io.Discard, lim.Downstream(bytes.NewReader(make([]byte, 1<<20))))
+	test.OK(t, err)
+	test.Equals(t, int64(1<<20), n)
+
+	lim.SetLimits(Limits{UploadKb: 42, DownloadKb: 23})
+	test.Equals(t, Limits{UploadKb: 42, DownloadKb: 23}, lim.Limits())
+	test.Equals(t, rate.Limit(42*1024), lim.upstream.Limit())
+	test.Equals(t, 42*1024, lim.upstream.Burst())
+	test.Equals(t, rate.Limit(23*1024), lim.downstream.Limit())
+
+	lim.SetLimits(Limits{UploadKb: 42})
+	test.Equals(t, rate.Inf, lim.downstream.Limit())
+}
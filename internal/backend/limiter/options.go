@@ -0,0 +1,97 @@
+package limiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// BackendOptions are the extended options which set the limits for a single
+// backend, for example `-o s3.limit-upload=5M`.
+type BackendOptions struct {
+	UploadLimit   string `option:"limit-upload" help:"limits uploads to a maximum rate in KiB/s or with a unit suffix like 5M (default: --limit-upload)"`
+	DownloadLimit string `option:"limit-download" help:"limits downloads to a maximum rate in KiB/s or with a unit suffix like 5M (default: --limit-download)"`
+}
+
+var backendOptionNames = []string{"limit-upload", "limit-download"}
+
+// ApplyBackendOptions applies the limit options for the backend with the
+// given scheme to l. It returns the resulting limits and a copy of opts
+// without the limit options, which can then be passed to the backend.
+func ApplyBackendOptions(scheme string, opts options.Options, l Limits) (Limits, options.Options, error) {
+	rest := make(options.Options, len(opts))
+	limitOpts := make(options.Options)
+	for k, v := range opts {
+		if isBackendOption(scheme, k) {
+			limitOpts[strings.TrimPrefix(k, scheme+".")] = v
+		} else {
+			rest[k] = v
+		}
+	}
+
+	var cfg BackendOptions
+	if err := limitOpts.Apply(scheme, &cfg); err != nil {
+		return Limits{}, nil, err
+	}
+
+	var err error
+	if cfg.UploadLimit != "" {
+		l.UploadKb, err = ParseRate(cfg.UploadLimit)
+		if err != nil {
+			return Limits{}, nil, errors.Fatalf("invalid %v.limit-upload: %v", scheme, err)
+		}
+	}
+	if cfg.DownloadLimit != "" {
+		l.DownloadKb, err = ParseRate(cfg.DownloadLimit)
+		if err != nil {
+			return Limits{}, nil, errors.Fatalf("invalid %v.limit-download: %v", scheme, err)
+		}
+	}
+	return l, rest, nil
+}
+
+func isBackendOption(scheme string, key string) bool {
+	for _, name := range backendOptionNames {
+		if key == scheme+"."+name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRate parses a transfer rate and returns it in KiB/s. Values without
+// a unit are interpreted as KiB/s, the suffixes K, M and G select KiB/s,
+// MiB/s and GiB/s, respectively. Zero means unlimited.
+func ParseRate(s string) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty rate")
+	}
+
+	unit := 1
+	num := s
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		num = s[:len(s)-1]
+	case 'm', 'M':
+		unit = 1024
+		num = s[:len(s)-1]
+	case 'g', 'G':
+		unit = 1024 * 1024
+		num = s[:len(s)-1]
+	}
+
+	v, err := strconv.Atoi(num)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("rate %q must not be negative", s)
+	}
+	if v > (1<<31-1)/unit {
+		return 0, fmt.Errorf("rate %q is too large", s)
+	}
+	return v * unit, nil
+}
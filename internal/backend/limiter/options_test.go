@@ -0,0 +1,63 @@
+package limiter
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/test"
+)
+
+func TestParseRate(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want int
+		err  bool
+	}{
+		{"0", 0, false},
+		{"500", 500, false},
+		{"500k", 500, false},
+		{"5M", 5 * 1024, false},
+		{"2G", 2 * 1024 * 1024, false},
+		{"", 0, true},
+		{"M", 0, true},
+		{"-5", 0, true},
+		{"5T", 0, true},
+		{"4096G", 0, true},
+	} {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseRate(tt.s)
+			if tt.err {
+				test.Assert(t, err != nil, "expected error for %q", tt.s)
+				return
+			}
+			test.OK(t, err)
+			test.Equals(t, tt.want, got)
+		})
+	}
+}
+
+func TestApplyBackendOptions(t *testing.T) {
+	opts := options.Options{
+		"s3.limit-upload":   "1M",
+		"s3.connections":    "10",
+		"b2.limit-upload":   "2M",
+		"sftp.command":      "ssh",
+		"s3.limit-download": "0",
+	}
+
+	limits, rest, err := ApplyBackendOptions("s3", opts, Limits{UploadKb: 10, DownloadKb: 20})
+	test.OK(t, err)
+	test.Equals(t, Limits{UploadKb: 1024, DownloadKb: 0}, limits)
+	test.Equals(t, options.Options{
+		"s3.connections":  "10",
+		"b2.limit-upload": "2M",
+		"sftp.command":    "ssh",
+	}, rest)
+
+	limits, _, err = ApplyBackendOptions("local", opts, Limits{UploadKb: 10, DownloadKb: 20})
+	test.OK(t, err)
+	test.Equals(t, Limits{UploadKb: 10, DownloadKb: 20}, limits)
+
+	_, _, err = ApplyBackendOptions("s3", options.Options{"s3.limit-upload": "fast"}, Limits{})
+	test.Assert(t, err != nil, "expected error for invalid rate")
+}
@@ -136,6 +136,11 @@ func (w *rateLimitedWriter) Write(buf []byte) (int, error) {
 }
 
 func consumeTokens(tokens int, bucket *rate.Limiter) error {
+	// a DynamicLimiter uses an infinite rate to disable the limit
+	if bucket.Limit() == rate.Inf {
+		return nil
+	}
+
 	// bucket allows waiting for at most Burst() tokens at once
 	maxWait := bucket.Burst()
 	for tokens > maxWait {
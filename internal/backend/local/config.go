@@ -12,6 +12,11 @@ type Config struct {
 	Path string
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent operations (default: 2)"`
+
+	Immutable       bool   `option:"immutable" help:"make pack and snapshot files immutable after writing them (chattr +i or chflags schg)"`
+	ImmutableHelper string `option:"immutable-helper" help:"run '<command> lock|unlock <file>' to change the immutable flag of a file, for example using sudo"`
+
+	Quota string `option:"quota" help:"maximum size of the repository (e.g. 500G), checked by the backup command before it starts"`
 }
 
 // NewConfig returns a new config with default options applied.
@@ -22,7 +27,7 @@ func NewConfig() Config {
 }
 
 func init() {
-	options.Register("local", Config{})
+	options.Register("local", NewConfig())
 }
 
 // ParseConfig parses a local backend config.
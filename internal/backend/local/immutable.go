@@ -0,0 +1,56 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// isImmutableType reports whether files of type t are made immutable in
+// immutable mode. Other files, like locks and index files, have to be
+// modified or removed during normal operation.
+func isImmutableType(t backend.FileType) bool {
+	return t == backend.PackFile || t == backend.SnapshotFile
+}
+
+// setImmutable sets or clears the immutable flag of the file fn, either
+// directly or by running the configured helper command.
+func (b *Local) setImmutable(ctx context.Context, fn string, immutable bool) error {
+	if b.ImmutableHelper == "" {
+		if err := setFileImmutable(fn, immutable); err != nil {
+			return errors.Wrap(err, "setting immutable flag")
+		}
+		return nil
+	}
+
+	args, err := backend.SplitShellStrings(b.ImmutableHelper)
+	if err != nil {
+		return errors.Wrap(err, "parsing immutable-helper")
+	}
+	if len(args) == 0 {
+		return errors.New("immutable-helper is empty")
+	}
+	action := "unlock"
+	if immutable {
+		action = "lock"
+	}
+	args = append(args, action, fn)
+
+	debug.Log("running immutable helper %v", args)
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return errors.Errorf("immutable-helper %v %v failed: %v: %v", action, fn, err, msg)
+		}
+		return errors.Errorf("immutable-helper %v %v failed: %v", action, fn, err)
+	}
+	return nil
+}
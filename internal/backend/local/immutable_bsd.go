@@ -0,0 +1,34 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package local
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// sfImmutable is SF_IMMUTABLE from sys/stat.h, which has the same value on
+// all BSDs and macOS.
+const sfImmutable = 0x00020000
+
+// setFileImmutable sets or clears the system immutable flag of a file like
+// `chflags schg` or `chflags noschg`. Only the superuser can change this flag.
+func setFileImmutable(fn string, immutable bool) error {
+	var st unix.Stat_t
+	if err := unix.Lstat(fn, &st); err != nil {
+		return &os.PathError{Op: "lstat", Path: fn, Err: err}
+	}
+	flags := int(st.Flags) &^ sfImmutable
+	if immutable {
+		flags |= sfImmutable
+	}
+	if flags == int(st.Flags) {
+		return nil
+	}
+	if err := unix.Chflags(fn, flags); err != nil {
+		return &os.PathError{Op: "chflags", Path: fn, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package local
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsImmutableFl is FS_IMMUTABLE_FL from linux/fs.h.
+const fsImmutableFl = 0x00000010
+
+// setFileImmutable sets or clears the immutable attribute of a file like
+// `chattr +i` or `chattr -i`. This requires the CAP_LINUX_IMMUTABLE
+// capability.
+func setFileImmutable(fn string, immutable bool) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	flags, err := unix.IoctlGetUint32(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return &os.PathError{Op: "getflags", Path: fn, Err: err}
+	}
+	newFlags := flags &^ fsImmutableFl
+	if immutable {
+		newFlags |= fsImmutableFl
+	}
+	if newFlags == flags {
+		return nil
+	}
+	if err := unix.IoctlSetPointerInt(int(f.Fd()), unix.FS_IOC_SETFLAGS, int(newFlags)); err != nil {
+		return &os.PathError{Op: "setflags", Path: fn, Err: err}
+	}
+	return nil
+}
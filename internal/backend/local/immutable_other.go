@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package local
+
+import (
+	"github.com/restic/restic/internal/errors"
+)
+
+// setFileImmutable is not supported on this platform, an immutable-helper
+// must be used instead.
+func setFileImmutable(_ string, _ bool) error {
+	return errors.New("immutable files are not supported on this platform, use the immutable-helper option")
+}
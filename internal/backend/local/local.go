@@ -26,10 +26,13 @@ type Local struct {
 	Config
 	layout.Layout
 	util.Modes
+
+	quota uint64
 }
 
 // ensure statically that *Local implements backend.Backend.
 var _ backend.Backend = &Local{}
+var _ backend.SpaceBackend = &Local{}
 
 var errTooShort = fmt.Errorf("file is too short")
 
@@ -44,10 +47,16 @@ func open(cfg Config) (*Local, error) {
 	m := util.DeriveModesFromFileInfo(fi, err)
 	debug.Log("using (%03O file, %03O dir) permissions", m.File, m.Dir)
 
+	quota, err := util.ParseQuota(cfg.Quota)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Local{
 		Config: cfg,
 		Layout: l,
 		Modes:  m,
+		quota:  quota,
 	}, nil
 }
 
@@ -98,6 +107,18 @@ func (b *Local) HasAtomicReplace() bool {
 	return true
 }
 
+// Space returns the configured quota and the free space of the file system
+// containing the repository.
+func (b *Local) Space(_ context.Context) (backend.SpaceInfo, error) {
+	info := backend.SpaceInfo{Quota: b.quota}
+	free, ok, err := freeSpace(b.Path)
+	if err != nil {
+		return backend.SpaceInfo{}, errors.WithStack(err)
+	}
+	info.Free, info.FreeKnown = free, ok
+	return info, nil
+}
+
 // IsNotExist returns true if the error is caused by a non existing file.
 func (b *Local) IsNotExist(err error) bool {
 	return errors.Is(err, os.ErrNotExist)
@@ -108,7 +129,7 @@ func (b *Local) IsPermanentError(err error) bool {
 }
 
 // Save stores data in the backend at the handle.
-func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReader) (err error) {
+func (b *Local) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) (err error) {
 	finalname := b.Filename(h)
 	dir := filepath.Dir(finalname)
 
@@ -199,6 +220,14 @@ func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReade
 		return errors.WithStack(err)
 	}
 
+	if b.Immutable && isImmutableType(h.Type) {
+		// unlike the read-only flag, failing to protect the file is an error
+		err = b.setImmutable(ctx, finalname, true)
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+	}
+
 	return nil
 }
 
@@ -254,9 +283,19 @@ func (b *Local) Stat(_ context.Context, h backend.Handle) (backend.FileInfo, err
 }
 
 // Remove removes the blob with the given name and type.
-func (b *Local) Remove(_ context.Context, h backend.Handle) error {
+func (b *Local) Remove(ctx context.Context, h backend.Handle) error {
 	fn := b.Filename(h)
 
+	if b.Immutable && isImmutableType(h.Type) {
+		// the helper cannot report missing files in a way we understand
+		if _, err := os.Lstat(fn); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := b.setImmutable(ctx, fn, false); err != nil {
+			return backoff.Permanent(err)
+		}
+	}
+
 	// reset read-only flag
 	err := os.Chmod(fn, 0666)
 	if err != nil && !os.IsPermission(err) {
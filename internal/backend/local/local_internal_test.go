@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"syscall"
 	"testing"
 
@@ -40,3 +43,85 @@ func TestNoSpacePermanent(t *testing.T) {
 	rtest.Assert(t, errors.Is(err, syscall.ENOSPC),
 		"could not recover original ENOSPC error")
 }
+
+func TestImmutableHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a POSIX shell")
+	}
+
+	dir := rtest.TempDir(t)
+	log := filepath.Join(dir, "helper.log")
+	script := filepath.Join(dir, "helper.sh")
+	rtest.OK(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$1 $2\" >> "+log+"\n"), 0o755))
+
+	repo := filepath.Join(dir, "repo")
+	be, err := Create(context.Background(), Config{Path: repo, Connections: 2, Immutable: true, ImmutableHelper: script})
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, be.Close())
+	}()
+
+	pack := backend.Handle{Type: backend.PackFile, Name: strings.Repeat("a", 64)}
+	lock := backend.Handle{Type: backend.LockFile, Name: strings.Repeat("b", 64)}
+	for _, h := range []backend.Handle{pack, lock} {
+		rtest.OK(t, be.Save(context.TODO(), h, backend.NewByteReader([]byte("data"), nil)))
+	}
+	for _, h := range []backend.Handle{pack, lock} {
+		rtest.OK(t, be.Remove(context.TODO(), h))
+	}
+	// removing a missing file must be reported as such
+	err = be.Remove(context.TODO(), pack)
+	rtest.Assert(t, be.IsNotExist(err), "unexpected error %v", err)
+
+	data, err := os.ReadFile(log)
+	rtest.OK(t, err)
+	fn := be.Filename(pack)
+	rtest.Equals(t, "lock "+fn+"\nunlock "+fn+"\n", string(data))
+
+	// a failing helper must fail the upload
+	be.ImmutableHelper = "false"
+	err = be.Save(context.TODO(), pack, backend.NewByteReader([]byte("data"), nil))
+	_, ok := err.(*backoff.PermanentError)
+	rtest.Assert(t, ok, "error type should be backoff.PermanentError, got %T: %v", err, err)
+}
+
+func TestSetFileImmutable(t *testing.T) {
+	fn := filepath.Join(rtest.TempDir(t), "file")
+	rtest.OK(t, os.WriteFile(fn, []byte("data"), 0o600))
+
+	if err := setFileImmutable(fn, true); err != nil {
+		t.Skipf("cannot set immutable flag: %v", err)
+	}
+	defer func() {
+		_ = setFileImmutable(fn, false)
+	}()
+	err := os.Remove(fn)
+	rtest.Assert(t, err != nil, "immutable file could be removed")
+
+	rtest.OK(t, setFileImmutable(fn, false))
+	// clearing the flag twice is a no-op
+	rtest.OK(t, setFileImmutable(fn, false))
+	rtest.OK(t, os.Remove(fn))
+}
+
+func TestSpace(t *testing.T) {
+	dir := rtest.TempDir(t)
+
+	be, err := Open(context.Background(), Config{Path: dir, Connections: 2, Quota: "500G"})
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, be.Close())
+	}()
+
+	info, err := be.Space(context.Background())
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(500*1024*1024*1024), info.Quota)
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "windows":
+		rtest.Assert(t, info.FreeKnown, "free space is unknown")
+		rtest.Assert(t, info.Free > 0, "no free space reported")
+	}
+
+	_, err = Open(context.Background(), Config{Path: dir, Connections: 2, Quota: "foo"})
+	rtest.Assert(t, err != nil, "invalid quota was accepted")
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!windows
+
+package local
+
+// freeSpace is not supported on this platform.
+func freeSpace(_ string) (uint64, bool, error) {
+	return 0, false, nil
+}
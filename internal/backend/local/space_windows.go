@@ -0,0 +1,21 @@
+package local
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// freeSpace returns the space available to the current user on the volume
+// containing dir.
+func freeSpace(dir string) (uint64, bool, error) {
+	dirp, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, false, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(dirp, &free, &total, &totalFree); err != nil {
+		return 0, false, &os.PathError{Op: "GetDiskFreeSpaceEx", Path: dir, Err: err}
+	}
+	return free, true, nil
+}
@@ -3,6 +3,7 @@ package location
 import (
 	"context"
 	"net/http"
+	"sort"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/limiter"
@@ -29,6 +30,16 @@ func (r *Registry) Lookup(scheme string) Factory {
 	return r.factories[scheme]
 }
 
+// Schemes returns the sorted list of schemes of all registered backends.
+func (r *Registry) Schemes() []string {
+	schemes := make([]string, 0, len(r.factories))
+	for scheme := range r.factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
 type Factory interface {
 	Scheme() string
 	ParseConfig(s string) (interface{}, error)
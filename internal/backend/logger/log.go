@@ -2,77 +2,102 @@ package logger
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
-	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/logging"
 )
 
+var logger = logging.Logger("backend")
+
 type Backend struct {
 	backend.Backend
 }
 
 // statically ensure that Backend implements backend.Backend.
 var _ backend.Backend = &Backend{}
+var _ backend.MultiRangeBackend = &Backend{}
 
 func New(be backend.Backend) *Backend {
 	return &Backend{Backend: be}
 }
 
+// logResult logs the result of an operation which started at start.
+func logResult(op string, start time.Time, err error, args ...any) {
+	args = append(args, "duration", time.Since(start))
+	if err != nil {
+		args = append(args, "err", err)
+	}
+	logger.Debug(op, args...)
+}
+
 func (be *Backend) IsNotExist(err error) bool {
 	isNotExist := be.Backend.IsNotExist(err)
-	debug.Log("IsNotExist(%T, %#v, %v)", err, err, isNotExist)
+	logger.Debug("IsNotExist", "err", err, "type", fmt.Sprintf("%T", err), "result", isNotExist)
 	return isNotExist
 }
 
 // Save adds new Data to the backend.
 func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
-	debug.Log("Save(%v, %v)", h, rd.Length())
+	start := time.Now()
 	err := be.Backend.Save(ctx, h, rd)
-	debug.Log("  save err %v", err)
+	logResult("Save", start, err, "handle", h.String(), "length", rd.Length())
 	return err
 }
 
 // Remove deletes a file from the backend.
 func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
-	debug.Log("Remove(%v)", h)
+	start := time.Now()
 	err := be.Backend.Remove(ctx, h)
-	debug.Log("  remove err %v", err)
+	logResult("Remove", start, err, "handle", h.String())
 	return err
 }
 
 func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(io.Reader) error) error {
-	debug.Log("Load(%v, length %v, offset %v)", h, length, offset)
+	start := time.Now()
 	err := be.Backend.Load(ctx, h, length, offset, fn)
-	debug.Log("  load err %v", err)
+	logResult("Load", start, err, "handle", h.String(), "length", length, "offset", offset)
+	return err
+}
+
+func (be *Backend) MaxRanges() int {
+	return backend.MaxRanges(be.Backend)
+}
+
+func (be *Backend) LoadRanges(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+	start := time.Now()
+	err := backend.LoadRanges(ctx, be.Backend, h, ranges, fn)
+	logResult("LoadRanges", start, err, "handle", h.String(), "ranges", len(ranges))
 	return err
 }
 
 func (be *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
-	debug.Log("Stat(%v)", h)
+	start := time.Now()
 	fi, err := be.Backend.Stat(ctx, h)
-	debug.Log("  stat err %v", err)
+	logResult("Stat", start, err, "handle", h.String())
 	return fi, err
 }
 
 func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
-	debug.Log("List(%v)", t)
+	start := time.Now()
 	err := be.Backend.List(ctx, t, fn)
-	debug.Log("  list err %v", err)
+	logResult("List", start, err, "type", t.String())
 	return err
 }
 
 func (be *Backend) Delete(ctx context.Context) error {
-	debug.Log("Delete()")
+	start := time.Now()
 	err := be.Backend.Delete(ctx)
-	debug.Log("  delete err %v", err)
+	logResult("Delete", start, err)
 	return err
 }
 
 func (be *Backend) Close() error {
-	debug.Log("Close()")
+	start := time.Now()
 	err := be.Backend.Close()
-	debug.Log("  close err %v", err)
+	logResult("Close", start, err)
 	return err
 }
 
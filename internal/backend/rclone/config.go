@@ -25,7 +25,7 @@ var defaultConfig = Config{
 }
 
 func init() {
-	options.Register("rclone", Config{})
+	options.Register("rclone", NewConfig())
 }
 
 // NewConfig returns a new Config with the default values filled in.
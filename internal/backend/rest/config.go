@@ -14,16 +14,26 @@ import (
 type Config struct {
 	URL         *url.URL
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+
+	ResumableThreshold uint `option:"resumable-threshold" help:"upload pack files larger than this size in MiB in resumable chunks if supported by the server, 0 disables resumable uploads (default: 64)"`
+	ResumableChunkSize uint `option:"resumable-chunk-size" help:"size of the chunks in MiB used for resumable uploads (default: 16)"`
+
+	MaxRanges uint `option:"max-ranges" help:"load up to this many parts of a file using a single request if supported by the server, 0 disables it (default: 32)"`
 }
 
 func init() {
-	options.Register("rest", Config{})
+	options.Register("rest", NewConfig())
 }
 
 // NewConfig returns a new Config with the default values filled in.
 func NewConfig() Config {
 	return Config{
 		Connections: 5,
+
+		ResumableThreshold: 64,
+		ResumableChunkSize: 16,
+
+		MaxRanges: 32,
 	}
 }
 
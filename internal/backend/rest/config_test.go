@@ -22,6 +22,11 @@ var configTests = []test.ConfigTestData[Config]{
 		Cfg: Config{
 			URL:         parseURL("http://localhost:1234/"),
 			Connections: 5,
+
+			ResumableThreshold: 64,
+			ResumableChunkSize: 16,
+
+			MaxRanges: 32,
 		},
 	},
 	{
@@ -29,6 +34,11 @@ var configTests = []test.ConfigTestData[Config]{
 		Cfg: Config{
 			URL:         parseURL("http://localhost:1234/"),
 			Connections: 5,
+
+			ResumableThreshold: 64,
+			ResumableChunkSize: 16,
+
+			MaxRanges: 32,
 		},
 	},
 	{
@@ -36,6 +46,11 @@ var configTests = []test.ConfigTestData[Config]{
 		Cfg: Config{
 			URL:         parseURL("http+unix:///tmp/rest.socket:/my_backup_repo/"),
 			Connections: 5,
+
+			ResumableThreshold: 64,
+			ResumableChunkSize: 16,
+
+			MaxRanges: 32,
 		},
 	},
 }
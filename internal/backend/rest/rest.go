@@ -6,9 +6,14 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"math"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/layout"
@@ -21,6 +26,7 @@ import (
 
 // make sure the rest backend implements backend.Backend
 var _ backend.Backend = &Backend{}
+var _ backend.MultiRangeBackend = &Backend{}
 
 // Backend uses the REST protocol to access data stored on a server.
 type Backend struct {
@@ -28,6 +34,17 @@ type Backend struct {
 	connections uint
 	client      http.Client
 	layout.Layout
+
+	// pack files larger than resumableThreshold bytes are uploaded in chunks
+	// of resumableChunkSize bytes, see saveResumable
+	resumableThreshold   int64
+	resumableChunkSize   int64
+	resumableUnsupported atomic.Bool
+
+	// up to maxRanges ranges are requested at once by LoadRanges, see
+	// loadRanges
+	maxRanges             int
+	multiRangeUnsupported atomic.Bool
 }
 
 // restError is returned whenever the server returns a non-successful HTTP status.
@@ -62,11 +79,20 @@ func Open(_ context.Context, cfg Config, rt http.RoundTripper) (*Backend, error)
 		url = url[:len(url)-1]
 	}
 
+	if cfg.ResumableThreshold > 0 && cfg.ResumableChunkSize == 0 {
+		return nil, errors.Fatal("rest.resumable-chunk-size must be larger than zero")
+	}
+
 	be := &Backend{
 		url:         cfg.URL,
 		client:      http.Client{Transport: rt},
 		Layout:      layout.NewRESTLayout(url),
 		connections: cfg.Connections,
+
+		resumableThreshold: int64(cfg.ResumableThreshold) * 1024 * 1024,
+		resumableChunkSize: int64(cfg.ResumableChunkSize) * 1024 * 1024,
+
+		maxRanges: int(cfg.MaxRanges),
 	}
 
 	return be, nil
@@ -120,6 +146,12 @@ func (b *Backend) Connections() uint {
 	return b.connections
 }
 
+// ParallelRangeRequests implements backend.ParallelRangeBackend. All
+// connections can be used to load different parts of a single file.
+func (b *Backend) ParallelRangeRequests() uint {
+	return b.Connections()
+}
+
 // Hasher may return a hash function for calculating a content hash for the backend
 func (b *Backend) Hasher() hash.Hash {
 	return nil
@@ -133,6 +165,19 @@ func (b *Backend) HasAtomicReplace() bool {
 
 // Save stores data in the backend at the handle.
 func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if h.Type == backend.PackFile && b.resumableThreshold > 0 && rd.Length() > b.resumableThreshold && !b.resumableUnsupported.Load() {
+		err := b.saveResumable(ctx, h, rd)
+		if !errors.Is(err, errResumableUnsupported) {
+			return err
+		}
+
+		debug.Log("server does not support resumable uploads, using a single request")
+		b.resumableUnsupported.Store(true)
+		if err := rd.Rewind(); err != nil {
+			return err
+		}
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -164,6 +209,9 @@ func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindR
 		return err
 	}
 
+	if resp.StatusCode == http.StatusForbidden {
+		return b.forbiddenError(ctx, h, &restError{h, resp.StatusCode, resp.Status}, "server does not allow overwriting files, the repository is probably append-only")
+	}
 	if resp.StatusCode != http.StatusOK {
 		return &restError{h, resp.StatusCode, resp.Status}
 	}
@@ -171,6 +219,136 @@ func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindR
 	return nil
 }
 
+// forbiddenError returns the error for a request for h which the server
+// refused with "403 Forbidden". rest-server uses this status both for
+// violations of the append-only mode and for missing access permissions. Only
+// if the server still allows reading the file, the request was refused due to
+// a policy and a PolicyError is returned.
+func (b *Backend) forbiddenError(ctx context.Context, h backend.Handle, rerr *restError, reason string) error {
+	if _, err := b.Stat(ctx, h); err != nil {
+		debug.Log("request for %v was forbidden, Stat failed too: %v", h, err)
+		return rerr
+	}
+	return &backend.PolicyError{Handle: h, Reason: reason, Err: rerr}
+}
+
+// errResumableUnsupported is returned by saveResumable if the server does not
+// support resumable uploads.
+var errResumableUnsupported = errors.New("server does not support resumable uploads")
+
+// saveResumable uploads the file in chunks using PATCH requests. The server
+// keeps the chunks received so far, such that an interrupted upload continues
+// at the last chunk stored by the server, even if Save is called again by the
+// retry backend.
+func (b *Backend) saveResumable(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	total := rd.Length()
+
+	// ask the server how much data it has already received, this also
+	// detects whether the server supports resumable uploads at all. Servers
+	// and proxies which do not allow PATCH requests may also respond with
+	// "403 Forbidden", the regular upload then reports the actual error.
+	offset, done, err := b.patch(ctx, h, fmt.Sprintf("bytes */%d", total), nil, 0)
+	var rerr *restError
+	if errors.As(err, &rerr) {
+		switch rerr.StatusCode {
+		case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return errResumableUnsupported
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	cr := &countingReader{RewindReader: rd}
+	if err := cr.Rewind(); err != nil {
+		return err
+	}
+	for !done {
+		if offset < 0 || offset >= total {
+			return errors.Errorf("server returned invalid upload offset %d for %v", offset, h)
+		}
+		if offset != cr.pos {
+			debug.Log("continuing upload of %v at offset %d", h, offset)
+			if err := cr.Rewind(); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(io.Discard, cr, offset); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		n := min(b.resumableChunkSize, total-offset)
+		contentRange := fmt.Sprintf("bytes %d-%d/%d", offset, offset+n-1, total)
+		var next int64
+		next, done, err = b.patch(ctx, h, contentRange, io.LimitReader(cr, n), n)
+		if errors.As(err, &rerr) && rerr.StatusCode == http.StatusForbidden {
+			return b.forbiddenError(ctx, h, rerr, "server does not allow overwriting files, the repository is probably append-only")
+		}
+		if err != nil {
+			return err
+		}
+		if !done && next == offset {
+			return errors.Errorf("upload of %v made no progress at offset %d", h, offset)
+		}
+		offset = next
+	}
+
+	return nil
+}
+
+// patch sends a single PATCH request with the given Content-Range header. It
+// returns the number of bytes received by the server so far, and whether the
+// server has stored the complete file.
+func (b *Backend) patch(ctx context.Context, h backend.Handle, contentRange string, body io.Reader, length int64) (offset int64, done bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, b.Filename(h), body)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", contentRange)
+	req.Header.Set("Accept", ContentTypeV2)
+	req.ContentLength = length
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	if err := drainAndClose(resp); err != nil {
+		return 0, false, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return 0, true, nil
+	case http.StatusNoContent, http.StatusRequestedRangeNotSatisfiable:
+		offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil {
+			return 0, false, errors.Errorf("invalid Upload-Offset header in response for %v: %w", h, err)
+		}
+		return offset, false, nil
+	default:
+		return 0, false, &restError{h, resp.StatusCode, resp.Status}
+	}
+}
+
+// countingReader tracks the position in the underlying RewindReader.
+type countingReader struct {
+	backend.RewindReader
+	pos int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.RewindReader.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *countingReader) Rewind() error {
+	r.pos = 0
+	return r.RewindReader.Rewind()
+}
+
 // IsNotExist returns true if the error was caused by a non-existing file.
 func (b *Backend) IsNotExist(err error) bool {
 	var e *restError
@@ -223,6 +401,177 @@ func (b *Backend) Load(ctx context.Context, h backend.Handle, length int, offset
 	return err
 }
 
+// MaxRanges implements backend.MultiRangeBackend. It returns zero once the
+// server has answered a request for several ranges with the whole file.
+func (b *Backend) MaxRanges() int {
+	if b.multiRangeUnsupported.Load() {
+		return 0
+	}
+	return b.maxRanges
+}
+
+// LoadRanges implements backend.MultiRangeBackend. Up to maxRanges ranges are
+// requested using a single multi-range request.
+func (b *Backend) LoadRanges(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+	if len(ranges) == 1 || b.MaxRanges() < 2 {
+		for i, r := range ranges {
+			err := b.Load(ctx, h, r.Length, r.Offset, func(rd io.Reader) error {
+				return fn(i, rd)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for first := 0; first < len(ranges); first += b.maxRanges {
+		last := min(first+b.maxRanges, len(ranges))
+		err := b.loadRanges(ctx, h, ranges[first:last], func(i int, rd io.Reader) error {
+			return fn(first+i, rd)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRanges requests all ranges using a single request. The server may
+// answer with a multipart response containing one part per range, with a
+// single range covering all of them, or with the whole file. The latter
+// means that the server does not support multi-range requests, such that
+// later calls to LoadRanges load each range separately.
+func (b *Backend) loadRanges(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.Filename(h), nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	byteRanges := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		byteRanges = append(byteRanges, fmt.Sprintf("%d-%d", r.Offset, r.Offset+int64(r.Length)-1))
+	}
+	req.Header.Set("Range", "bytes="+strings.Join(byteRanges, ","))
+	req.Header.Set("Accept", ContentTypeV2)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "client.Do")
+	}
+	// the body is not drained, as the server may send the whole file
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	next := 0
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		mediaType, params, perr := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if perr != nil || mediaType != "multipart/byteranges" {
+			// the server merged the ranges
+			start, end, err := parseContentRange(resp.Header.Get("Content-Range"))
+			if err != nil {
+				return err
+			}
+			next, err = readRanges(resp.Body, start, end, ranges, next, fn)
+			if err != nil {
+				return err
+			}
+			break
+		}
+
+		mr := multipart.NewReader(resp.Body, params["boundary"])
+		for next < len(ranges) {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return errors.Wrap(err, "NextPart")
+			}
+			start, end, err := parseContentRange(part.Header.Get("Content-Range"))
+			if err != nil {
+				return err
+			}
+			next, err = readRanges(part, start, end, ranges, next, fn)
+			if err != nil {
+				return err
+			}
+		}
+
+	case http.StatusOK:
+		debug.Log("server returned the whole file %v, loading ranges separately from now on", h)
+		b.multiRangeUnsupported.Store(true)
+		next, err = readRanges(resp.Body, 0, math.MaxInt64, ranges, next, fn)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return &restError{h, resp.StatusCode, resp.Status}
+	}
+
+	if next < len(ranges) {
+		r := ranges[next]
+		return errors.Errorf("server did not return range %d-%d of %v", r.Offset, r.Offset+int64(r.Length)-1, h)
+	}
+	return nil
+}
+
+// readRanges passes the ranges starting with ranges[next], which rd contains,
+// to fn. rd yields the data from offset start up to end. It returns the index
+// of the first range which is not contained in rd.
+func readRanges(rd io.Reader, start, end int64, ranges []backend.Range, next int, fn func(i int, rd io.Reader) error) (int, error) {
+	pos := start
+	for ; next < len(ranges); next++ {
+		r := ranges[next]
+		if r.Offset < pos || r.Offset+int64(r.Length) > end {
+			break
+		}
+
+		if _, err := io.CopyN(io.Discard, rd, r.Offset-pos); err != nil {
+			return next, errors.WithStack(err)
+		}
+		lr := io.LimitReader(rd, int64(r.Length))
+		if err := fn(next, lr); err != nil {
+			return next, err
+		}
+		// skip the data not read by fn
+		if _, err := io.Copy(io.Discard, lr); err != nil {
+			return next, errors.WithStack(err)
+		}
+		pos = r.Offset + int64(r.Length)
+	}
+	return next, nil
+}
+
+// parseContentRange parses a Content-Range header of the form "bytes
+// start-last/size" and returns the offsets of the first and after the last
+// byte.
+func parseContentRange(s string) (start, end int64, err error) {
+	spec, ok := strings.CutPrefix(s, "bytes ")
+	if ok {
+		spec, _, ok = strings.Cut(spec, "/")
+	}
+	var first, last string
+	if ok {
+		first, last, ok = strings.Cut(spec, "-")
+	}
+	if !ok {
+		return 0, 0, errors.Errorf("invalid Content-Range header %q", s)
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err == nil {
+		end, err = strconv.ParseInt(last, 10, 64)
+	}
+	if err != nil || start < 0 || end < start {
+		return 0, 0, errors.Errorf("invalid Content-Range header %q", s)
+	}
+	return start, end + 1, nil
+}
+
 func (b *Backend) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", b.Filename(h), nil)
 	if err != nil {
@@ -303,6 +652,10 @@ func (b *Backend) Remove(ctx context.Context, h backend.Handle) error {
 		return err
 	}
 
+	// rest-server refuses to delete files other than locks in append-only mode
+	if resp.StatusCode == http.StatusForbidden {
+		return b.forbiddenError(ctx, h, &restError{h, resp.StatusCode, resp.Status}, "server does not allow deleting files, the repository is probably append-only")
+	}
 	if resp.StatusCode != http.StatusOK {
 		return &restError{h, resp.StatusCode, resp.Status}
 	}
@@ -1,17 +1,23 @@
 package rest_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/rest"
+	"github.com/restic/restic/internal/test"
 )
 
 func TestListAPI(t *testing.T) {
@@ -148,3 +154,292 @@ func TestListAPI(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendOnlyError(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		readable bool
+	}{
+		{"append-only", true},
+		{"access-denied", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testAppendOnlyError(t, test.readable)
+		})
+	}
+}
+
+func testAppendOnlyError(t *testing.T, readable bool) {
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case "DELETE", "POST":
+			res.WriteHeader(http.StatusForbidden)
+		case "HEAD":
+			if readable {
+				res.Header().Set("Content-Length", "3")
+				res.WriteHeader(http.StatusOK)
+			} else {
+				res.WriteHeader(http.StatusForbidden)
+			}
+		default:
+			t.Errorf("unhandled request %v %v", req.Method, req.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	be, err := rest.Open(context.TODO(), rest.Config{Connections: 1, URL: srvURL}, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := backend.Handle{Type: backend.SnapshotFile, Name: "1122e6749358b057fa1ac6b580a0fbe7a9a5fbc92e82743ee21aaf829624a985"}
+	for _, err := range []error{
+		be.Remove(context.TODO(), h),
+		be.Save(context.TODO(), h, backend.NewByteReader([]byte("foo"), nil)),
+	} {
+		var perr *backend.PolicyError
+		if errors.As(err, &perr) != readable {
+			t.Fatalf("unexpected error %v, PolicyError expected: %v", err, readable)
+		}
+		if !be.IsPermanentError(err) {
+			t.Fatalf("error %v is not permanent", err)
+		}
+	}
+}
+
+func TestResumableUpload(t *testing.T) {
+	data := test.Random(23, 7*1024*1024/2)
+	var received []byte
+	var uploaded int64
+	failChunk := true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPatch {
+			t.Errorf("unhandled request %v %v", req.Method, req.URL.Path)
+			return
+		}
+
+		var start, end, total int64
+		if _, err := fmt.Sscanf(req.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+			// status request
+			res.Header().Set("Upload-Offset", strconv.Itoa(len(received)))
+			res.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if start != int64(len(received)) {
+			res.Header().Set("Upload-Offset", strconv.Itoa(len(received)))
+			res.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		buf, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploaded += int64(len(buf))
+		if start > 0 && failChunk {
+			// the connection broke while uploading the second chunk
+			failChunk = false
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		received = append(received, buf...)
+		if int64(len(received)) == total {
+			res.WriteHeader(http.StatusOK)
+			return
+		}
+		res.Header().Set("Upload-Offset", strconv.Itoa(len(received)))
+		res.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := rest.Config{Connections: 1, URL: srvURL, ResumableThreshold: 1, ResumableChunkSize: 1}
+	be, err := rest.Open(context.TODO(), cfg, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := backend.Handle{Type: backend.PackFile, Name: "1122e6749358b057fa1ac6b580a0fbe7a9a5fbc92e82743ee21aaf829624a985"}
+	rd := backend.NewByteReader(data, nil)
+	if err := be.Save(context.TODO(), h, rd); err == nil {
+		t.Fatal("expected error for interrupted upload")
+	}
+	// the retry backend calls Save again, which must continue the upload
+	if err := be.Save(context.TODO(), h, rd); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(received, data) {
+		t.Fatal("uploaded data does not match")
+	}
+	// only the interrupted chunk is uploaded twice
+	if uploaded != int64(len(data))+1024*1024 {
+		t.Fatalf("unexpected number of uploaded bytes, want %d, got %d", len(data)+1024*1024, uploaded)
+	}
+}
+
+func TestResumableUploadUnsupported(t *testing.T) {
+	for _, status := range []int{http.StatusMethodNotAllowed, http.StatusForbidden} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			testResumableUploadUnsupported(t, status)
+		})
+	}
+}
+
+func testResumableUploadUnsupported(t *testing.T, status int) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.Method)
+		switch req.Method {
+		case http.MethodPatch:
+			res.WriteHeader(status)
+		case http.MethodHead:
+			res.WriteHeader(http.StatusNotFound)
+		case http.MethodPost:
+			_, _ = io.Copy(io.Discard, req.Body)
+		default:
+			t.Errorf("unhandled request %v %v", req.Method, req.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := rest.Config{Connections: 1, URL: srvURL, ResumableThreshold: 1, ResumableChunkSize: 1}
+	be, err := rest.Open(context.TODO(), cfg, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := backend.Handle{Type: backend.PackFile, Name: "1122e6749358b057fa1ac6b580a0fbe7a9a5fbc92e82743ee21aaf829624a985"}
+	for i := 0; i < 2; i++ {
+		if err := be.Save(context.TODO(), h, backend.NewByteReader(test.Random(i, 2*1024*1024), nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the server is only asked once whether it supports resumable uploads
+	if !reflect.DeepEqual(requests, []string{"PATCH", "POST", "POST"}) {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+}
+
+func TestLoadRanges(t *testing.T) {
+	data := test.Random(42, 100000)
+	ranges := []backend.Range{{Offset: 10, Length: 100}, {Offset: 5000, Length: 1}, {Offset: 5001, Length: 2000}, {Offset: 99000, Length: 1000}}
+
+	for _, mode := range []string{"multipart", "merged", "whole file"} {
+		t.Run(mode, func(t *testing.T) {
+			var requests []string
+			srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				requests = append(requests, req.Header.Get("Range"))
+				multiRange := strings.Contains(req.Header.Get("Range"), ",")
+				switch {
+				case mode == "merged" && multiRange:
+					// return a single range from the start of the first to the end of the last range
+					specs := strings.Split(strings.TrimPrefix(req.Header.Get("Range"), "bytes="), ",")
+					var start, first, end int64
+					if _, err := fmt.Sscanf(specs[0], "%d-", &start); err != nil {
+						t.Error(err)
+					}
+					if _, err := fmt.Sscanf(specs[len(specs)-1], "%d-%d", &first, &end); err != nil {
+						t.Error(err)
+					}
+					res.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+					res.WriteHeader(http.StatusPartialContent)
+					_, _ = res.Write(data[start : end+1])
+				case mode == "whole file" && multiRange:
+					_, _ = res.Write(data)
+				default:
+					http.ServeContent(res, req, "", time.Time{}, bytes.NewReader(data))
+				}
+			}))
+			defer srv.Close()
+
+			srvURL, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			be, err := rest.Open(context.TODO(), rest.Config{Connections: 1, URL: srvURL, MaxRanges: 3}, http.DefaultTransport)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			h := backend.Handle{Type: backend.PackFile, Name: "1122e6749358b057fa1ac6b580a0fbe7a9a5fbc92e82743ee21aaf829624a985"}
+			for i := 0; i < 2; i++ {
+				var got []int
+				err = be.LoadRanges(context.TODO(), h, ranges, func(i int, rd io.Reader) error {
+					got = append(got, i)
+					buf, err := io.ReadAll(rd)
+					if err != nil {
+						return err
+					}
+					r := ranges[i]
+					if !bytes.Equal(buf, data[r.Offset:r.Offset+int64(r.Length)]) {
+						t.Errorf("wrong data for range %v", r)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+					t.Fatalf("unexpected ranges %v", got)
+				}
+			}
+
+			want := []string{"bytes=10-109,5000-5000,5001-7000", "bytes=99000-99999"}
+			if mode == "whole file" {
+				// after the first response, each range is requested separately
+				want = []string{"bytes=10-109,5000-5000,5001-7000", "bytes=99000-99999",
+					"bytes=10-109", "bytes=5000-5000", "bytes=5001-7000", "bytes=99000-99999"}
+			} else {
+				want = append(want, want...)
+			}
+			if !reflect.DeepEqual(requests, want) {
+				t.Fatalf("unexpected requests %v", requests)
+			}
+		})
+	}
+}
+
+func TestLoadRangesMissing(t *testing.T) {
+	data := test.Random(42, 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		// only return the first range
+		res.Header().Set("Content-Range", fmt.Sprintf("bytes 0-99/%d", len(data)))
+		res.WriteHeader(http.StatusPartialContent)
+		_, _ = res.Write(data[:100])
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	be, err := rest.Open(context.TODO(), rest.Config{Connections: 1, URL: srvURL, MaxRanges: 32}, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := backend.Handle{Type: backend.PackFile, Name: "1122e6749358b057fa1ac6b580a0fbe7a9a5fbc92e82743ee21aaf829624a985"}
+	err = be.LoadRanges(context.TODO(), h, []backend.Range{{Offset: 0, Length: 100}, {Offset: 500, Length: 100}}, func(i int, rd io.Reader) error {
+		_, err := io.Copy(io.Discard, rd)
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "did not return range 500-599") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
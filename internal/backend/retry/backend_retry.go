@@ -12,6 +12,8 @@ import (
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/logging"
+	"github.com/restic/restic/internal/metrics"
 )
 
 // Backend retries operations on the backend in case of an error with a
@@ -21,12 +23,16 @@ type Backend struct {
 	MaxElapsedTime time.Duration
 	Report         func(string, error, time.Duration)
 	Success        func(string, int)
+	// Breaker stops retrying all operations once the backend appears to be
+	// unusable. If it is nil, each operation is retried independently.
+	Breaker *Breaker
 
 	failedLoads sync.Map
 }
 
 // statically ensure that RetryBackend implements backend.Backend.
 var _ backend.Backend = &Backend{}
+var _ backend.MultiRangeBackend = &Backend{}
 
 // New wraps be with a backend that retries operations after a
 // backoff. report is called with a description and the error, if one occurred.
@@ -94,7 +100,30 @@ func (b *retryAtLeastOnce) Reset() {
 
 var fastRetries = false
 
-func (be *Backend) retry(ctx context.Context, msg string, f func() error) error {
+var logger = logging.Logger("backend/retry")
+
+var retries = metrics.NewCounter("restic_backend_retries_total", "Number of retried backend operations.")
+
+// operation describes a backend operation for error reporting.
+type operation struct {
+	name   string
+	h      backend.Handle
+	length int
+	offset int64
+}
+
+func (op operation) String() string {
+	switch op.name {
+	case "List":
+		return fmt.Sprintf("List(%v)", op.h.Type)
+	case "Load":
+		return fmt.Sprintf("Load(%v, %v, %v)", op.h, op.length, op.offset)
+	default:
+		return fmt.Sprintf("%v(%v)", op.name, op.h)
+	}
+}
+
+func (be *Backend) retry(ctx context.Context, op operation, f func() error) error {
 	// Don't do anything when called with an already cancelled context. There would be
 	// no retries in that case either, so be consistent and abort always.
 	// This enforces a strict contract for backend methods: Using a cancelled context
@@ -127,35 +156,83 @@ func (be *Backend) retry(ctx context.Context, msg string, f func() error) error
 		b = backoff.WithMaxRetries(b, 10)
 	}
 
+	msg := op.String()
+	start := time.Now()
+	attempts := 0
+
+	// lock files must remain accessible to refresh and remove the locks
+	breaker := be.Breaker
+	if op.h.Type == backend.LockFile {
+		breaker = nil
+	}
+
 	err := retryNotifyErrorWithSuccess(
 		func() error {
-			err := f()
+			probe, err := breaker.allow()
+			if err != nil {
+				return backoff.Permanent(err)
+			}
+
+			attempts++
+			err = f()
+			permanent := err != nil && (errors.Is(err, &backoff.PermanentError{}) || be.Backend.IsPermanentError(err))
+
+			// only requests which the storage service failed to handle count
+			// towards the breaker, canceled requests are ignored
+			if ctx.Err() == nil {
+				failed := err != nil && !permanent
+				if berr := breaker.record(probe, failed, err); berr != nil && failed {
+					return backoff.Permanent(berr)
+				}
+			} else if probe {
+				breaker.abortProbe()
+			}
+
 			// don't retry permanent errors as those very likely cannot be fixed by retrying
 			// TODO remove IsNotExist(err) special cases when removing the feature flag
-			if feature.Flag.Enabled(feature.BackendErrorRedesign) && !errors.Is(err, &backoff.PermanentError{}) && be.Backend.IsPermanentError(err) {
+			if feature.Flag.Enabled(feature.BackendErrorRedesign) && !errors.Is(err, &backoff.PermanentError{}) && permanent {
 				return backoff.Permanent(err)
 			}
 			return err
 		},
 		backoff.WithContext(b, ctx),
 		func(err error, d time.Duration) {
+			if d < 0 {
+				logger.Error("operation failed", "op", msg, "err", err)
+			} else {
+				logger.Warn("operation failed, retrying", "op", msg, "err", err, "delay", d)
+				retries.Inc()
+			}
 			if be.Report != nil {
 				be.Report(msg, err, d)
 			}
 		},
 		func(retries int) {
+			logger.Info("operation succeeded after retrying", "op", msg, "retries", retries)
 			if be.Success != nil {
 				be.Success(msg, retries)
 			}
 		},
 	)
 
+	if err != nil && ctx.Err() == nil {
+		// attach the context of the failed operation, unless it was canceled
+		return &backend.OperationError{
+			Op:       op.name,
+			Handle:   op.h,
+			Length:   op.length,
+			Offset:   op.offset,
+			Attempts: attempts,
+			Elapsed:  time.Since(start),
+			Err:      err,
+		}
+	}
 	return err
 }
 
 // Save stores the data in the backend under the given handle.
 func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
-	return be.retry(ctx, fmt.Sprintf("Save(%v)", h), func() error {
+	return be.retry(ctx, operation{name: "Save", h: h}, func() error {
 		err := rd.Rewind()
 		if err != nil {
 			return err
@@ -204,7 +281,7 @@ func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offse
 		}
 	}
 
-	err = be.retry(ctx, fmt.Sprintf("Load(%v, %v, %v)", h, length, offset),
+	err = be.retry(ctx, operation{name: "Load", h: h, length: length, offset: offset},
 		func() error {
 			return be.Backend.Load(ctx, h, length, offset, consumer)
 		})
@@ -219,13 +296,44 @@ func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offse
 	return err
 }
 
+// MaxRanges implements backend.MultiRangeBackend.
+func (be *Backend) MaxRanges() int {
+	return backend.MaxRanges(be.Backend)
+}
+
+// LoadRanges implements backend.MultiRangeBackend. The circuit breaker for
+// failed files is shared with Load.
+func (be *Backend) LoadRanges(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+	key := h
+	key.IsMetadata = false
+
+	if v, ok := be.failedLoads.Load(key); ok {
+		if time.Since(v.(time.Time)) > failedLoadExpiry {
+			be.failedLoads.Delete(key)
+		} else {
+			return fmt.Errorf("circuit breaker open for file %v", h)
+		}
+	}
+
+	err := be.retry(ctx, operation{name: "LoadRanges", h: h},
+		func() error {
+			return backend.LoadRanges(ctx, be.Backend, h, ranges, fn)
+		})
+
+	if feature.Flag.Enabled(feature.BackendErrorRedesign) && err != nil && ctx.Err() == nil && !be.IsPermanentError(err) {
+		be.failedLoads.LoadOrStore(key, time.Now())
+	}
+
+	return err
+}
+
 // Stat returns information about the File identified by h.
 func (be *Backend) Stat(ctx context.Context, h backend.Handle) (fi backend.FileInfo, err error) {
 	// see the call to `cancel()` below for why this context exists
 	statCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	err = be.retry(statCtx, fmt.Sprintf("Stat(%v)", h),
+	err = be.retry(statCtx, operation{name: "Stat", h: h},
 		func() error {
 			var innerError error
 			fi, innerError = be.Backend.Stat(ctx, h)
@@ -244,7 +352,7 @@ func (be *Backend) Stat(ctx context.Context, h backend.Handle) (fi backend.FileI
 
 // Remove removes a File with type t and name.
 func (be *Backend) Remove(ctx context.Context, h backend.Handle) (err error) {
-	return be.retry(ctx, fmt.Sprintf("Remove(%v)", h), func() error {
+	return be.retry(ctx, operation{name: "Remove", h: h}, func() error {
 		return be.Backend.Remove(ctx, h)
 	})
 }
@@ -262,7 +370,7 @@ func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend
 	listed := make(map[string]struct{}) // remember for which files we already ran fn
 	var innerErr error                  // remember when fn returned an error, so we can return that to the caller
 
-	err := be.retry(listCtx, fmt.Sprintf("List(%v)", t), func() error {
+	err := be.retry(listCtx, operation{name: "List", h: backend.Handle{Type: t}}, func() error {
 		return be.Backend.List(ctx, t, func(fi backend.FileInfo) error {
 			if _, ok := listed[fi.Name]; ok {
 				return nil
@@ -204,7 +204,7 @@ func TestBackendListRetryErrorBackend(t *testing.T) {
 		return nil
 	})
 
-	if err != ErrBackendTest {
+	if !errors.Is(err, ErrBackendTest) {
 		t.Fatalf("wrong error returned, want %v, got %v", ErrBackendTest, err)
 	}
 
@@ -306,6 +306,45 @@ func TestBackendLoadNotExists(t *testing.T) {
 	test.Equals(t, 1, attempt)
 }
 
+func TestBackendOperationError(t *testing.T) {
+	otherError := errors.New("connection reset by peer")
+	attempt := 0
+
+	be := mock.NewBackend()
+	be.OpenReaderFn = func(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+		attempt++
+		return nil, otherError
+	}
+
+	TestFastRetries(t)
+	retryBackend := New(be, 10, nil, nil)
+
+	h := backend.Handle{Type: backend.PackFile, Name: "0123456789abcdef"}
+	err := retryBackend.Load(context.TODO(), h, 42, 23, func(rd io.Reader) (err error) {
+		return nil
+	})
+	test.Assert(t, errors.Is(err, otherError), "unexpected error %v", err)
+
+	var operr *backend.OperationError
+	test.Assert(t, errors.As(err, &operr), "missing operation context in error %v", err)
+	test.Equals(t, "Load", operr.Op)
+	test.Equals(t, h, operr.Handle)
+	test.Equals(t, 42, operr.Length)
+	test.Equals(t, int64(23), operr.Offset)
+	test.Equals(t, attempt, operr.Attempts)
+	test.Assert(t, strings.HasPrefix(err.Error(), "Load <data/0123456789> (offset 23, length 42) failed after "),
+		"unexpected error message %q", err)
+
+	// canceled operations are not wrapped
+	ctx, cancel := context.WithCancel(context.TODO())
+	be.RemoveFn = func(ctx context.Context, h backend.Handle) error {
+		cancel()
+		return otherError
+	}
+	err = retryBackend.Remove(ctx, h)
+	test.Assert(t, !errors.As(err, &operr), "unexpected operation context in error %v", err)
+}
+
 func TestBackendLoadCircuitBreaker(t *testing.T) {
 	// retry should not retry if the error matches IsPermanentError
 	notFound := errors.New("not found")
@@ -328,7 +367,7 @@ func TestBackendLoadCircuitBreaker(t *testing.T) {
 	retryBackend := New(be, 2, nil, nil)
 	// trip the circuit breaker for file "other"
 	err := retryBackend.Load(context.TODO(), backend.Handle{Name: "other"}, 0, 0, nilRd)
-	test.Equals(t, otherError, err, "unexpected error")
+	test.Assert(t, errors.Is(err, otherError), "unexpected error %v", err)
 	test.Equals(t, 2, attempt)
 
 	attempt = 0
@@ -342,9 +381,9 @@ func TestBackendLoadCircuitBreaker(t *testing.T) {
 		return nil, notFound
 	}
 	err = retryBackend.Load(context.TODO(), backend.Handle{Name: "notfound"}, 0, 0, nilRd)
-	test.Equals(t, notFound, err, "expected circuit breaker to only affect other file, got %v")
+	test.Assert(t, errors.Is(err, notFound), "expected circuit breaker to only affect other file, got %v", err)
 	err = retryBackend.Load(context.TODO(), backend.Handle{Name: "notfound"}, 0, 0, nilRd)
-	test.Equals(t, notFound, err, "persistent error must not trigger circuit breaker, got %v")
+	test.Assert(t, errors.Is(err, notFound), "persistent error must not trigger circuit breaker, got %v", err)
 
 	// wait for circuit breaker to expire
 	time.Sleep(5 * time.Millisecond)
@@ -354,7 +393,7 @@ func TestBackendLoadCircuitBreaker(t *testing.T) {
 	}()
 	failedLoadExpiry = 3 * time.Millisecond
 	err = retryBackend.Load(context.TODO(), backend.Handle{Name: "other"}, 0, 0, nilRd)
-	test.Equals(t, notFound, err, "expected circuit breaker to reset, got %v")
+	test.Assert(t, errors.Is(err, notFound), "expected circuit breaker to reset, got %v", err)
 }
 
 func TestBackendLoadCircuitBreakerCancel(t *testing.T) {
@@ -423,7 +462,7 @@ func TestBackendRetryPermanent(t *testing.T) {
 
 	TestFastRetries(t)
 	retryBackend := New(be, 2, nil, nil)
-	err := retryBackend.retry(context.TODO(), "test", func() error {
+	err := retryBackend.retry(context.TODO(), operation{name: "test"}, func() error {
 		attempt++
 		return notFound
 	})
@@ -432,7 +471,7 @@ func TestBackendRetryPermanent(t *testing.T) {
 	test.Equals(t, 1, attempt)
 
 	attempt = 0
-	err = retryBackend.retry(context.TODO(), "test", func() error {
+	err = retryBackend.retry(context.TODO(), operation{name: "test"}, func() error {
 		attempt++
 		return errors.New("something")
 	})
@@ -441,6 +480,140 @@ func TestBackendRetryPermanent(t *testing.T) {
 
 }
 
+func TestBackendBreakerErrorRate(t *testing.T) {
+	otherError := errors.New("something")
+	notFound := errors.New("not found")
+	attempt := 0
+
+	be := mock.NewBackend()
+	be.IsPermanentErrorFn = func(err error) bool {
+		return errors.Is(err, notFound)
+	}
+
+	TestFastRetries(t)
+	retryBackend := New(be, 2, nil, nil)
+	retryBackend.Breaker = NewBreaker(0, 0.5)
+
+	// permanent errors must not open the breaker
+	for i := 0; i < breakerWindow; i++ {
+		err := retryBackend.retry(context.TODO(), operation{name: "test"}, func() error {
+			return notFound
+		})
+		test.Assert(t, errors.Is(err, notFound), "unexpected error %v", err)
+	}
+	test.OK(t, retryBackend.Breaker.Err())
+
+	var err error
+	for i := 0; i < breakerWindow && err == nil; i++ {
+		err = retryBackend.retry(context.TODO(), operation{name: "test"}, func() error {
+			attempt++
+			if attempt%2 == 0 {
+				return nil
+			}
+			return otherError
+		})
+	}
+	var cerr *backend.CircuitOpenError
+	test.Assert(t, errors.As(err, &cerr), "expected circuit breaker error, got %v", err)
+	test.Assert(t, errors.Is(err, otherError), "missing last error in %v", err)
+	test.Equals(t, 0.5, cerr.ErrorRate)
+
+	// further operations fail without being attempted
+	attempt = 0
+	err = retryBackend.retry(context.TODO(), operation{name: "test"}, func() error {
+		attempt++
+		return nil
+	})
+	test.Equals(t, 0, attempt)
+	test.Assert(t, errors.As(err, &cerr), "expected circuit breaker error, got %v", err)
+	test.Assert(t, cerr.Err == nil, "unexpected wrapped error %v", cerr.Err)
+
+	var operr *backend.OperationError
+	test.Assert(t, errors.As(err, &operr), "missing operation context in error %v", err)
+	test.Equals(t, 0, operr.Attempts)
+}
+
+func TestBackendBreakerFailureTime(t *testing.T) {
+	attempt := 0
+
+	TestFastRetries(t)
+	be := mock.NewBackend()
+	be.RemoveFn = func(context.Context, backend.Handle) error { return nil }
+	retryBackend := New(be, time.Minute, nil, nil)
+	retryBackend.Breaker = NewBreaker(5*time.Millisecond, 0)
+
+	// a successful request in between resets the failure time
+	for i := 0; i < 5; i++ {
+		err := retryBackend.retry(context.TODO(), operation{name: "test"}, func() error {
+			attempt++
+			if attempt%2 == 0 {
+				return nil
+			}
+			time.Sleep(2 * time.Millisecond)
+			return errors.New("something")
+		})
+		test.OK(t, err)
+	}
+	test.OK(t, retryBackend.Breaker.Err())
+
+	attempt = 0
+	err := retryBackend.retry(context.TODO(), operation{name: "test"}, func() error {
+		attempt++
+		return errors.New("something")
+	})
+	var cerr *backend.CircuitOpenError
+	test.Assert(t, errors.As(err, &cerr), "expected circuit breaker error, got %v", err)
+	test.Assert(t, cerr.FailureTime > 5*time.Millisecond, "unexpected failure time %v", cerr.FailureTime)
+	test.Assert(t, strings.Contains(err.Error(), "no request succeeded for more than 5ms"), "unexpected error message %q", err)
+	test.Assert(t, attempt > 1, "expected retries, got %d attempts", attempt)
+
+	// successful operations also fail once the breaker is open
+	err = retryBackend.Remove(context.TODO(), backend.Handle{Type: backend.PackFile, Name: "foo"})
+	test.Assert(t, errors.As(err, &cerr), "expected circuit breaker error, got %v", err)
+
+	// lock files remain accessible
+	test.OK(t, retryBackend.Remove(context.TODO(), backend.Handle{Type: backend.LockFile, Name: "foo"}))
+
+	// once the probe interval has passed, a successful request closes the breaker
+	retryBackend.Breaker.m.Lock()
+	retryBackend.Breaker.probeInterval = 0
+	retryBackend.Breaker.m.Unlock()
+	test.OK(t, retryBackend.Remove(context.TODO(), backend.Handle{Type: backend.PackFile, Name: "foo"}))
+	test.OK(t, retryBackend.Breaker.Err())
+}
+
+func TestBackendBreakerProbe(t *testing.T) {
+	b := NewBreaker(0, 0.5)
+	for i := 0; i < breakerMinRequests; i++ {
+		_ = b.record(false, true, errors.New("something"))
+	}
+	test.Assert(t, b.Err() != nil, "breaker should be open")
+
+	// only a single probe is allowed at a time
+	b.probeInterval = 0
+	probe, err := b.allow()
+	test.OK(t, err)
+	test.Assert(t, probe, "expected a probe")
+	_, err = b.allow()
+	test.Assert(t, err != nil, "second probe was allowed")
+
+	// a failed probe keeps the breaker open
+	test.Assert(t, b.record(true, true, errors.New("something")) != nil, "failed probe closed the breaker")
+	b.probeInterval = time.Hour
+	_, err = b.allow()
+	test.Assert(t, err != nil, "request allowed before the probe interval passed")
+
+	b.probeInterval = 0
+	probe, err = b.allow()
+	test.OK(t, err)
+	test.Assert(t, probe, "expected a probe")
+	test.OK(t, b.record(true, false, nil))
+	test.OK(t, b.Err())
+	probe, err = b.allow()
+	test.OK(t, err)
+	test.Assert(t, !probe, "unexpected probe for closed breaker")
+}
+
 func assertIsCanceled(t *testing.T, err error) {
 	test.Assert(t, err == context.Canceled, "got unexpected err %v", err)
 }
@@ -0,0 +1,179 @@
+package retry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+)
+
+const (
+	// breakerWindow is the number of recent requests considered for the error rate
+	breakerWindow = 50
+	// breakerMinRequests is the number of requests required before the error rate is evaluated
+	breakerMinRequests = 20
+	// breakerProbeInterval is the time after which an open breaker lets a
+	// single request through to check whether the backend has recovered
+	breakerProbeInterval = time.Minute
+)
+
+// Breaker limits the retries of all operations on a backend. Once requests
+// kept failing for too long without any successful request in between, or
+// too many of the recent requests have failed, the breaker opens and all
+// further operations fail immediately. While the breaker is open, a single
+// request is attempted from time to time. If it succeeds, the breaker closes
+// again. A nil Breaker never opens.
+type Breaker struct {
+	maxFailureTime time.Duration
+	maxErrorRate   float64
+	probeInterval  time.Duration
+
+	m            sync.Mutex
+	failingSince time.Time           // first failure not followed by a successful request
+	results      [breakerWindow]bool // true for failed requests
+	requests     int
+	failures     int
+	err          *backend.CircuitOpenError
+	openedAt     time.Time
+	probing      bool
+}
+
+// NewBreaker returns a breaker which opens once the requests kept failing for
+// longer than maxFailureTime, measured from the first failure after the last
+// successful request, or once the fraction of failed requests among the recent
+// requests reaches maxErrorRate. A value of zero disables the respective limit.
+func NewBreaker(maxFailureTime time.Duration, maxErrorRate float64) *Breaker {
+	return &Breaker{
+		maxFailureTime: maxFailureTime,
+		maxErrorRate:   maxErrorRate,
+		probeInterval:  breakerProbeInterval,
+	}
+}
+
+// Err returns a *backend.CircuitOpenError if the breaker is open and nil
+// otherwise.
+func (b *Breaker) Err() error {
+	if b == nil {
+		return nil
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.err == nil {
+		return nil
+	}
+	return b.openError(nil)
+}
+
+// allow returns an error if a request must not be attempted as the breaker is
+// open. If the request is attempted to probe whether the backend has
+// recovered, probe is true and the result must be passed to record.
+func (b *Breaker) allow() (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.err == nil {
+		return false, nil
+	}
+	if !b.probing && time.Since(b.openedAt) >= b.probeInterval {
+		b.probing = true
+		return true, nil
+	}
+	return false, b.openError(nil)
+}
+
+// record stores the result of a request. If the breaker is open afterwards,
+// an error wrapping err is returned.
+func (b *Breaker) record(probe bool, failed bool, err error) error {
+	if b == nil {
+		return nil
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if probe {
+		b.probing = false
+		if failed {
+			b.openedAt = time.Now()
+			return b.openError(err)
+		}
+		b.reset()
+		return nil
+	}
+
+	slot := b.requests % breakerWindow
+	if b.requests >= breakerWindow && b.results[slot] {
+		b.failures--
+	}
+	b.results[slot] = failed
+	if failed {
+		b.failures++
+		if b.failingSince.IsZero() {
+			b.failingSince = time.Now()
+		}
+	} else {
+		b.failingSince = time.Time{}
+	}
+	b.requests++
+
+	if b.err == nil {
+		rate := b.errorRate()
+		switch {
+		case b.maxFailureTime > 0 && !b.failingSince.IsZero() && time.Since(b.failingSince) > b.maxFailureTime:
+			b.open(fmt.Sprintf("no request succeeded for more than %v", b.maxFailureTime))
+		case b.maxErrorRate > 0 && b.requests >= breakerMinRequests && rate >= b.maxErrorRate:
+			b.open(fmt.Sprintf("%.0f%% of the last %d requests failed", rate*100, min(b.requests, breakerWindow)))
+		}
+	}
+
+	if b.err == nil {
+		return nil
+	}
+	return b.openError(err)
+}
+
+// abortProbe allows another probe after a probe request was canceled.
+func (b *Breaker) abortProbe() {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.probing = false
+}
+
+// open opens the breaker. b.m must be held.
+func (b *Breaker) open(reason string) {
+	b.err = &backend.CircuitOpenError{Reason: reason}
+	b.openedAt = time.Now()
+}
+
+// reset closes the breaker and forgets all previous requests. b.m must be held.
+func (b *Breaker) reset() {
+	b.err = nil
+	b.failingSince = time.Time{}
+	b.results = [breakerWindow]bool{}
+	b.requests = 0
+	b.failures = 0
+}
+
+func (b *Breaker) errorRate() float64 {
+	if b.requests == 0 {
+		return 0
+	}
+	return float64(b.failures) / float64(min(b.requests, breakerWindow))
+}
+
+// openError returns a copy of the error which opened the breaker, wrapping
+// err and the current statistics. b.m must be held.
+func (b *Breaker) openError(err error) *backend.CircuitOpenError {
+	var failureTime time.Duration
+	if !b.failingSince.IsZero() {
+		failureTime = time.Since(b.failingSince)
+	}
+	return &backend.CircuitOpenError{
+		Reason:      b.err.Reason,
+		FailureTime: failureTime,
+		ErrorRate:   b.errorRate(),
+		Err:         err,
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/errors"
@@ -21,7 +23,7 @@ type Config struct {
 	Bucket       string
 	Prefix       string
 	Layout       string `option:"layout" help:"use this backend layout (default: auto-detect) (deprecated)"`
-	StorageClass string `option:"storage-class" help:"set S3 storage class (STANDARD, STANDARD_IA, ONEZONE_IA, INTELLIGENT_TIERING or REDUCED_REDUNDANCY)"`
+	StorageClass string `option:"storage-class" help:"set S3 storage class (STANDARD, STANDARD_IA, ONEZONE_IA, INTELLIGENT_TIERING or REDUCED_REDUNDANCY)" details:"The storage class is used for all files. For the archive storage classes GLACIER and DEEP_ARCHIVE, it is only used for data pack files, as the metadata must remain instantly accessible."`
 
 	Connections         uint   `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
 	MaxRetries          uint   `option:"retries" help:"set the number of retries attempted"`
@@ -29,6 +31,16 @@ type Config struct {
 	BucketLookup        string `option:"bucket-lookup" help:"bucket lookup style: 'auto', 'dns', or 'path'"`
 	ListObjectsV1       bool   `option:"list-objects-v1" help:"use deprecated V1 api for ListObjects calls"`
 	UnsafeAnonymousAuth bool   `option:"unsafe-anonymous-auth" help:"use anonymous authentication"`
+
+	TransferAcceleration bool `option:"transfer-acceleration" help:"use the Amazon S3 Transfer Acceleration endpoint, requires a bucket with transfer acceleration enabled"`
+	DualStack            bool `option:"dual-stack" help:"use IPv6 dual-stack endpoints for Amazon S3 (default: true)"`
+
+	ObjectLockRetention string `option:"object-lock-retention" help:"set object lock retention period for data, index and snapshot files (e.g. 30d or 720h), requires a bucket with object lock enabled"`
+	ObjectLockMode      string `option:"object-lock-mode" help:"object lock mode: 'governance' or 'compliance' (default: governance)"`
+
+	Tags string `option:"tags" help:"set object tags on uploaded files, e.g. team=infra,purpose=backup"`
+
+	Quota string `option:"quota" help:"maximum size of the repository (e.g. 500G), checked by the backup command before it starts"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -36,11 +48,12 @@ func NewConfig() Config {
 	return Config{
 		Connections:   5,
 		ListObjectsV1: false,
+		DualStack:     true,
 	}
 }
 
 func init() {
-	options.Register("s3", Config{})
+	options.Register("s3", NewConfig())
 }
 
 // ParseConfig parses the string s and extracts the s3 config. The two
@@ -109,3 +122,59 @@ func (cfg *Config) ApplyEnvironment(prefix string) {
 		cfg.Region = os.Getenv(prefix + "AWS_DEFAULT_REGION")
 	}
 }
+
+// maximum number and length of object tags supported by S3
+const (
+	maxTags           = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// parseTags parses the object tags and checks them against the limits of S3.
+func parseTags(s string) (map[string]string, error) {
+	tags, err := backend.ParseTags(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > maxTags {
+		return nil, errors.Errorf("too many object tags, at most %d are supported", maxTags)
+	}
+	for key, value := range tags {
+		if len(key) > maxTagKeyLength {
+			return nil, errors.Errorf("object tag key %q is longer than %d characters", key, maxTagKeyLength)
+		}
+		if len(value) > maxTagValueLength {
+			return nil, errors.Errorf("value of object tag %q is longer than %d characters", key, maxTagValueLength)
+		}
+	}
+	return tags, nil
+}
+
+// parseRetention parses the object lock retention period. In addition to the
+// units understood by time.ParseDuration, a number of days can be specified
+// using the suffix "d", for example "30d".
+func parseRetention(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseUint(days, 10, 32)
+		if err != nil {
+			return 0, errors.Errorf("invalid object lock retention %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, errors.Errorf("invalid object lock retention %q", s)
+		}
+	}
+
+	if d <= 0 {
+		return 0, errors.Errorf("object lock retention %q must be positive", s)
+	}
+	return d, nil
+}
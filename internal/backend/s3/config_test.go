@@ -1,8 +1,11 @@
 package s3
 
 import (
+	"fmt"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/backend/test"
 )
@@ -13,78 +16,91 @@ var configTests = []test.ConfigTestData[Config]{
 		Bucket:      "bucketname",
 		Prefix:      "",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3://eu-central-1/bucketname/", Cfg: Config{
 		Endpoint:    "eu-central-1",
 		Bucket:      "bucketname",
 		Prefix:      "",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3://eu-central-1/bucketname/prefix/directory", Cfg: Config{
 		Endpoint:    "eu-central-1",
 		Bucket:      "bucketname",
 		Prefix:      "prefix/directory",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3://eu-central-1/bucketname/prefix/directory/", Cfg: Config{
 		Endpoint:    "eu-central-1",
 		Bucket:      "bucketname",
 		Prefix:      "prefix/directory",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:eu-central-1/foobar", Cfg: Config{
 		Endpoint:    "eu-central-1",
 		Bucket:      "foobar",
 		Prefix:      "",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:eu-central-1/foobar/", Cfg: Config{
 		Endpoint:    "eu-central-1",
 		Bucket:      "foobar",
 		Prefix:      "",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:eu-central-1/foobar/prefix/directory", Cfg: Config{
 		Endpoint:    "eu-central-1",
 		Bucket:      "foobar",
 		Prefix:      "prefix/directory",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:eu-central-1/foobar/prefix/directory/", Cfg: Config{
 		Endpoint:    "eu-central-1",
 		Bucket:      "foobar",
 		Prefix:      "prefix/directory",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:hostname.foo/foobar", Cfg: Config{
 		Endpoint:    "hostname.foo",
 		Bucket:      "foobar",
 		Prefix:      "",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:hostname.foo/foobar/prefix/directory", Cfg: Config{
 		Endpoint:    "hostname.foo",
 		Bucket:      "foobar",
 		Prefix:      "prefix/directory",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:https://hostname/foobar", Cfg: Config{
 		Endpoint:    "hostname",
 		Bucket:      "foobar",
 		Prefix:      "",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:https://hostname:9999/foobar", Cfg: Config{
 		Endpoint:    "hostname:9999",
 		Bucket:      "foobar",
 		Prefix:      "",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:https://hostname:9999/foobar/", Cfg: Config{
 		Endpoint:    "hostname:9999",
 		Bucket:      "foobar",
 		Prefix:      "",
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:http://hostname:9999/foobar", Cfg: Config{
 		Endpoint:    "hostname:9999",
@@ -92,6 +108,7 @@ var configTests = []test.ConfigTestData[Config]{
 		Prefix:      "",
 		UseHTTP:     true,
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:http://hostname:9999/foobar/", Cfg: Config{
 		Endpoint:    "hostname:9999",
@@ -99,6 +116,7 @@ var configTests = []test.ConfigTestData[Config]{
 		Prefix:      "",
 		UseHTTP:     true,
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:http://hostname:9999/bucket/prefix/directory", Cfg: Config{
 		Endpoint:    "hostname:9999",
@@ -106,6 +124,7 @@ var configTests = []test.ConfigTestData[Config]{
 		Prefix:      "prefix/directory",
 		UseHTTP:     true,
 		Connections: 5,
+		DualStack:   true,
 	}},
 	{S: "s3:http://hostname:9999/bucket/prefix/directory/", Cfg: Config{
 		Endpoint:    "hostname:9999",
@@ -113,6 +132,7 @@ var configTests = []test.ConfigTestData[Config]{
 		Prefix:      "prefix/directory",
 		UseHTTP:     true,
 		Connections: 5,
+		DualStack:   true,
 	}},
 }
 
@@ -130,3 +150,97 @@ func TestParseError(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRetention(t *testing.T) {
+	for _, test := range []struct {
+		s   string
+		d   time.Duration
+		err bool
+	}{
+		{"", 0, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"720h", 720 * time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"0d", 0, true},
+		{"-5h", 0, true},
+		{"xd", 0, true},
+		{"30", 0, true},
+	} {
+		d, err := parseRetention(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", test.s, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.s, err)
+		}
+		if d != test.d {
+			t.Errorf("%q: want %v, got %v", test.s, test.d, d)
+		}
+	}
+}
+
+func TestTransferAcceleration(t *testing.T) {
+	for _, test := range []struct {
+		endpoint, bucket string
+		dualStack        bool
+		accelerate       string
+		err              bool
+	}{
+		{"s3.amazonaws.com", "bucket", true, "s3-accelerate.dualstack.amazonaws.com", false},
+		{"s3.eu-central-1.amazonaws.com", "bucket", false, "s3-accelerate.amazonaws.com", false},
+		{"s3.amazonaws.com", "bucket.example.com", true, "", true},
+		{"localhost:9000", "bucket", true, "", true},
+	} {
+		cfg := NewConfig()
+		cfg.Endpoint = test.endpoint
+		cfg.Bucket = test.bucket
+		cfg.DualStack = test.dualStack
+		cfg.TransferAcceleration = true
+
+		u, err := url.Parse("https://" + test.endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		endpoint, err := accelerateEndpoint(cfg, u)
+		if test.err {
+			if err == nil {
+				t.Errorf("%v/%v: expected error, got endpoint %q", test.endpoint, test.bucket, endpoint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v/%v: unexpected error %v", test.endpoint, test.bucket, err)
+		}
+		if endpoint != test.accelerate {
+			t.Errorf("%v/%v: wrong endpoint, want %q, got %q", test.endpoint, test.bucket, test.accelerate, endpoint)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := parseTags("team=infra,purpose=backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags["team"] != "infra" || tags["purpose"] != "backup" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	var many []string
+	for i := 0; i <= maxTags; i++ {
+		many = append(many, fmt.Sprintf("key%d=value", i))
+	}
+	for _, s := range []string{
+		"team",
+		strings.Join(many, ","),
+		strings.Repeat("k", maxTagKeyLength+1) + "=value",
+		"key=" + strings.Repeat("v", maxTagValueLength+1),
+	} {
+		if _, err := parseTags(s); err == nil {
+			t.Errorf("%.20q: expected error", s)
+		}
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"hash"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strings"
+	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/layout"
 	"github.com/restic/restic/internal/backend/location"
@@ -20,6 +23,8 @@ import (
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+	"golang.org/x/sync/errgroup"
 )
 
 // Backend stores data on an S3 endpoint.
@@ -27,10 +32,17 @@ type Backend struct {
 	client *minio.Client
 	cfg    Config
 	layout.Layout
+
+	lockRetention time.Duration
+	lockMode      minio.RetentionMode
+	tags          map[string]string
+	quota         uint64
 }
 
 // make sure that *Backend implements backend.Backend
 var _ backend.Backend = &Backend{}
+var _ backend.SpaceBackend = &Backend{}
+var _ backend.RetentionBackend = &Backend{}
 
 func NewFactory() location.Factory {
 	return location.NewHTTPBackendFactory("s3", ParseConfig, location.NoPassword, Create, Open)
@@ -39,6 +51,52 @@ func NewFactory() location.Factory {
 func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 	debug.Log("open, config %#v", cfg)
 
+	lockRetention, err := parseRetention(cfg.ObjectLockRetention)
+	if err != nil {
+		return nil, errors.Fatalf("%v", err)
+	}
+
+	var lockMode minio.RetentionMode
+	switch strings.ToLower(cfg.ObjectLockMode) {
+	case "", "governance":
+		lockMode = minio.Governance
+	case "compliance":
+		lockMode = minio.Compliance
+	default:
+		return nil, fmt.Errorf(`bad object lock mode %q must be "governance" or "compliance"`, cfg.ObjectLockMode)
+	}
+
+	tags, err := parseTags(cfg.Tags)
+	if err != nil {
+		return nil, errors.Fatalf("%v", err)
+	}
+
+	quota, err := util.ParseQuota(cfg.Quota)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := Connect(cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	be := &Backend{
+		client:        client,
+		cfg:           cfg,
+		Layout:        layout.NewDefaultLayout(cfg.Prefix, path.Join),
+		lockRetention: lockRetention,
+		lockMode:      lockMode,
+		tags:          tags,
+		quota:         quota,
+	}
+
+	return be, nil
+}
+
+// Connect returns a client for the S3 server described by the config without
+// accessing a repository.
+func Connect(cfg Config, rt http.RoundTripper) (*minio.Client, error) {
 	if cfg.KeyID == "" && cfg.Secret.String() != "" {
 		return nil, errors.Fatalf("unable to open S3 backend: Key ID ($AWS_ACCESS_KEY_ID) is empty")
 	} else if cfg.KeyID != "" && cfg.Secret.String() == "" {
@@ -77,13 +135,33 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 		return nil, errors.Wrap(err, "minio.New")
 	}
 
-	be := &Backend{
-		client: client,
-		cfg:    cfg,
-		Layout: layout.NewDefaultLayout(cfg.Prefix, path.Join),
+	// both settings only have an effect for Amazon S3 endpoints
+	client.SetS3EnableDualstack(cfg.DualStack)
+	if cfg.TransferAcceleration {
+		endpoint, err := accelerateEndpoint(cfg, client.EndpointURL())
+		if err != nil {
+			return nil, err
+		}
+		client.SetS3TransferAccelerate(endpoint)
 	}
+	return client, nil
+}
 
-	return be, nil
+// accelerateEndpoint returns the Transfer Acceleration endpoint to use for
+// the configured bucket.
+func accelerateEndpoint(cfg Config, endpointURL *url.URL) (string, error) {
+	if !s3utils.IsAmazonEndpoint(*endpointURL) {
+		return "", errors.Fatalf("s3.transfer-acceleration is only supported for Amazon S3 endpoints, not %q", cfg.Endpoint)
+	}
+	// the accelerate endpoints use virtual-hosted-style requests
+	if strings.Contains(cfg.Bucket, ".") {
+		return "", errors.Fatalf("s3.transfer-acceleration is not supported for bucket names containing dots (%q)", cfg.Bucket)
+	}
+
+	if cfg.DualStack {
+		return "s3-accelerate.dualstack.amazonaws.com", nil
+	}
+	return "s3-accelerate.amazonaws.com", nil
 }
 
 // getCredentials -- runs through the various credential types and returns the first one that works.
@@ -238,6 +316,11 @@ func (be *Backend) IsPermanentError(err error) bool {
 		return true
 	}
 
+	var rerr *backend.RetentionError
+	if errors.As(err, &rerr) {
+		return true
+	}
+
 	var merr minio.ErrorResponse
 	if errors.As(err, &merr) {
 		if merr.Code == "InvalidRange" || merr.Code == "AccessDenied" {
@@ -248,10 +331,22 @@ func (be *Backend) IsPermanentError(err error) bool {
 	return false
 }
 
+// Space returns the configured quota. S3 has no API to query the free space
+// of a bucket, thus it is always unknown.
+func (be *Backend) Space(_ context.Context) (backend.SpaceInfo, error) {
+	return backend.SpaceInfo{Quota: be.quota}, nil
+}
+
 func (be *Backend) Connections() uint {
 	return be.cfg.Connections
 }
 
+// ParallelRangeRequests implements backend.ParallelRangeBackend. All
+// connections can be used to load different parts of a single file.
+func (be *Backend) ParallelRangeRequests() uint {
+	return be.Connections()
+}
+
 // Hasher may return a hash function for calculating a content hash for the backend
 func (be *Backend) Hasher() hash.Hash {
 	return nil
@@ -276,6 +371,21 @@ func (be *Backend) useStorageClass(h backend.Handle) bool {
 	return isDataFile || notArchiveClass
 }
 
+// useObjectLock returns whether a retention period should be set for the file.
+// Lock files and keys must remain removable, thus only data, index and snapshot
+// files are protected.
+func (be *Backend) useObjectLock(h backend.Handle) bool {
+	if be.lockRetention == 0 {
+		return false
+	}
+	switch h.Type {
+	case backend.PackFile, backend.IndexFile, backend.SnapshotFile:
+		return true
+	default:
+		return false
+	}
+}
+
 // Save stores data in the backend at the handle.
 func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
 	objName := be.Filename(h)
@@ -286,10 +396,15 @@ func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 		SendContentMd5: true,
 		// only use multipart uploads for very large files
 		PartSize: 200 * 1024 * 1024,
+		UserTags: be.tags,
 	}
 	if be.useStorageClass(h) {
 		opts.StorageClass = be.cfg.StorageClass
 	}
+	if be.useObjectLock(h) {
+		opts.Mode = be.lockMode
+		opts.RetainUntilDate = time.Now().Add(be.lockRetention).UTC()
+	}
 
 	info, err := be.client.PutObject(ctx, be.cfg.Bucket, objName, io.NopCloser(rd), int64(rd.Length()), opts)
 
@@ -378,6 +493,9 @@ func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
 	if be.IsNotExist(err) {
 		err = nil
 	}
+	if err != nil && be.useObjectLock(h) && isAccessDenied(err) {
+		return backoff.Permanent(&backend.RetentionError{Handle: h})
+	}
 
 	return errors.Wrap(err, "client.RemoveObject")
 }
@@ -438,6 +556,89 @@ func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend
 	return ctx.Err()
 }
 
+// ListRetained runs fn for each file of type t whose object lock retention
+// period has not expired yet. Only files modified within the configured
+// retention period are candidates, the end of their retention period is read
+// from the object. Older files which were uploaded using a longer retention
+// are not detected. On a versioned bucket, removing such a file only hides it
+// while its data is retained.
+func (be *Backend) ListRetained(ctx context.Context, t backend.FileType, fn func(name string, until time.Time) error) error {
+	if !be.useObjectLock(backend.Handle{Type: t}) {
+		return nil
+	}
+	prefix, recursive := be.Basedir(t)
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	now := time.Now()
+	var candidates []string
+	listresp := be.client.ListObjects(ctx, be.cfg.Bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: recursive,
+		UseV1:     be.cfg.ListObjectsV1,
+	})
+	for obj := range listresp {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if obj.Key == prefix || !obj.LastModified.Add(be.lockRetention).After(now) {
+			continue
+		}
+		candidates = append(candidates, obj.Key)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	retained := make([]*time.Time, len(candidates))
+	wg, wgCtx := errgroup.WithContext(ctx)
+	wg.SetLimit(int(be.Connections()))
+	for i, key := range candidates {
+		i, key := i, key
+		wg.Go(func() error {
+			until, err := be.retainUntil(wgCtx, key)
+			if err != nil {
+				return err
+			}
+			if until != nil && until.After(now) {
+				retained[i] = until
+			}
+			return nil
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+
+	for i, key := range candidates {
+		if retained[i] == nil {
+			continue
+		}
+		if err := fn(path.Base(key), *retained[i]); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// retainUntil returns the end of the retention period of the object, nil if
+// the object has no retention.
+func (be *Backend) retainUntil(ctx context.Context, objName string) (*time.Time, error) {
+	_, until, err := be.client.GetObjectRetention(ctx, be.cfg.Bucket, objName, "")
+	var e minio.ErrorResponse
+	if errors.As(err, &e) && (e.Code == "NoSuchObjectLockConfiguration" || e.Code == "NoSuchKey") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "client.GetObjectRetention")
+	}
+	return until, nil
+}
+
 // Delete removes all restic keys in the bucket. It will not remove the bucket itself.
 func (be *Backend) Delete(ctx context.Context) error {
 	return util.DefaultDelete(ctx, be)
@@ -12,6 +12,7 @@ import (
 
 // make sure that connectionLimitedBackend implements backend.Backend
 var _ backend.Backend = &connectionLimitedBackend{}
+var _ backend.MultiRangeBackend = &connectionLimitedBackend{}
 
 // connectionLimitedBackend limits the number of concurrent operations.
 type connectionLimitedBackend struct {
@@ -95,6 +96,35 @@ func (be *connectionLimitedBackend) Load(ctx context.Context, h backend.Handle,
 	return be.Backend.Load(ctx, h, length, offset, fn)
 }
 
+// MaxRanges implements backend.MultiRangeBackend.
+func (be *connectionLimitedBackend) MaxRanges() int {
+	return backend.MaxRanges(be.Backend)
+}
+
+// LoadRanges implements backend.MultiRangeBackend. A single connection is
+// used for all ranges.
+func (be *connectionLimitedBackend) LoadRanges(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+	if err := h.Valid(); err != nil {
+		return backoff.Permanent(err)
+	}
+	for _, r := range ranges {
+		if r.Offset < 0 {
+			return backoff.Permanent(errors.New("offset is negative"))
+		}
+		if r.Length <= 0 {
+			return backoff.Permanent(errors.Errorf("invalid length %d", r.Length))
+		}
+	}
+
+	defer be.typeDependentLimit(h.Type)()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return backend.LoadRanges(ctx, be.Backend, h, ranges, fn)
+}
+
 // Stat returns information about a file in the backend.
 func (be *connectionLimitedBackend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
 	if err := h.Valid(); err != nil {
@@ -17,6 +17,10 @@ type Config struct {
 	Args    string `option:"args"    help:"specify arguments for ssh"`
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+
+	Quota string `option:"quota" help:"maximum size of the repository (e.g. 500G), checked by the backup command before it starts"`
+
+	Preset string `option:"preset" help:"apply the settings required by a storage provider (hetzner, rsync.net)" details:"The hetzner preset uses port 23 unless a port is specified, limits the number of connections to 10 and enables SSH keepalive messages. The rsync.net preset enables SSH keepalive messages. The keepalive messages are not sent if sftp.command is set."`
 }
 
 // NewConfig returns a new config with default options applied.
@@ -27,7 +31,7 @@ func NewConfig() Config {
 }
 
 func init() {
-	options.Register("sftp", Config{})
+	options.Register("sftp", NewConfig())
 }
 
 // ParseConfig parses the string s and extracts the sftp config. The
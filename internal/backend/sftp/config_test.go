@@ -92,3 +92,45 @@ func TestParseConfigInvalid(t *testing.T) {
 		}
 	}
 }
+
+var presetTests = []struct {
+	cfg      Config
+	expected Config
+}{
+	{
+		Config{Host: "host", Connections: 5},
+		Config{Host: "host", Connections: 5},
+	},
+	{
+		Config{Host: "host", Connections: 20, Preset: "hetzner"},
+		Config{Host: "host", Port: "23", Connections: 10, Preset: "hetzner",
+			Args: "-o ServerAliveInterval=60 -o ServerAliveCountMax=240"},
+	},
+	{
+		Config{Host: "host", Port: "22", Connections: 5, Preset: "Hetzner", Args: "-i key"},
+		Config{Host: "host", Port: "22", Connections: 5, Preset: "Hetzner",
+			Args: "-i key -o ServerAliveInterval=60 -o ServerAliveCountMax=240"},
+	},
+	{
+		Config{Host: "host", Connections: 20, Preset: "rsync.net", Command: "ssh host -s sftp"},
+		Config{Host: "host", Connections: 20, Preset: "rsync.net", Command: "ssh host -s sftp"},
+	},
+}
+
+func TestApplyPreset(t *testing.T) {
+	for i, test := range presetTests {
+		cfg, err := applyPreset(test.cfg)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if cfg != test.expected {
+			t.Errorf("test %d: wrong config, want:\n  %#v\ngot:\n  %#v", i, test.expected, cfg)
+		}
+	}
+
+	_, err := applyPreset(Config{Preset: "unknown"})
+	if err == nil {
+		t.Error("unknown preset did not return an error")
+	}
+}
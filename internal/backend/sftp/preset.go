@@ -0,0 +1,76 @@
+package sftp
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// preset collects the settings required by a particular sftp provider.
+type preset struct {
+	// port is used if no port was specified
+	port string
+	// maxConnections limits the number of concurrent connections, the server
+	// refuses additional connections
+	maxConnections uint
+	// sshArgs are appended to the arguments of the ssh command. As ssh uses
+	// the first value of an option, those set using sftp.args take
+	// precedence.
+	sshArgs []string
+}
+
+// keepaliveArgs prevent the server from closing the connection while restic
+// processes a large amount of unchanged data.
+var keepaliveArgs = []string{"-o", "ServerAliveInterval=60", "-o", "ServerAliveCountMax=240"}
+
+var presets = map[string]preset{
+	// Hetzner Storage Box: only port 23 supports key authentication and
+	// at most ten connections are allowed for each account.
+	"hetzner": {
+		port:           "23",
+		maxConnections: 10,
+		sshArgs:        keepaliveArgs,
+	},
+	"rsync.net": {
+		sshArgs: keepaliveArgs,
+	},
+}
+
+func presetNames() []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPreset returns cfg with the settings of cfg.Preset applied.
+func applyPreset(cfg Config) (Config, error) {
+	if cfg.Preset == "" {
+		return cfg, nil
+	}
+
+	p, ok := presets[strings.ToLower(cfg.Preset)]
+	if !ok {
+		return cfg, errors.Fatalf("unknown sftp.preset %q, valid values are: %v", cfg.Preset, strings.Join(presetNames(), ", "))
+	}
+
+	if cfg.Port == "" {
+		cfg.Port = p.port
+	}
+	if p.maxConnections > 0 && cfg.Connections > p.maxConnections {
+		debug.Log("preset %v limits connections from %v to %v", cfg.Preset, cfg.Connections, p.maxConnections)
+		cfg.Connections = p.maxConnections
+	}
+	if cfg.Command == "" && len(p.sshArgs) > 0 {
+		args := strings.Join(p.sshArgs, " ")
+		if cfg.Args != "" {
+			args = cfg.Args + " " + args
+		}
+		cfg.Args = args
+	}
+	return cfg, nil
+}
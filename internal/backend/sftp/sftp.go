@@ -36,6 +36,7 @@ type SFTP struct {
 	result <-chan error
 
 	posixRename bool
+	quota       uint64
 
 	layout.Layout
 	Config
@@ -43,6 +44,7 @@ type SFTP struct {
 }
 
 var _ backend.Backend = &SFTP{}
+var _ backend.SpaceBackend = &SFTP{}
 
 var errTooShort = fmt.Errorf("file is too short")
 
@@ -128,6 +130,16 @@ func startClient(cfg Config) (*SFTP, error) {
 	}, nil
 }
 
+// Connect starts an SFTP session as described by the config without accessing
+// a repository. The returned function closes the session.
+func Connect(cfg Config) (*sftp.Client, func() error, error) {
+	r, err := startClient(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.c, r.Close, nil
+}
+
 // clientError returns an error if the client has exited. Otherwise, nil is
 // returned immediately.
 func (r *SFTP) clientError() error {
@@ -146,6 +158,11 @@ func (r *SFTP) clientError() error {
 func Open(_ context.Context, cfg Config) (*SFTP, error) {
 	debug.Log("open backend with config %#v", cfg)
 
+	cfg, err := applyPreset(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	sftp, err := startClient(cfg)
 	if err != nil {
 		debug.Log("unable to start program: %v", err)
@@ -156,6 +173,12 @@ func Open(_ context.Context, cfg Config) (*SFTP, error) {
 }
 
 func open(sftp *SFTP, cfg Config) (*SFTP, error) {
+	quota, err := util.ParseQuota(cfg.Quota)
+	if err != nil {
+		_ = sftp.Close()
+		return nil, err
+	}
+
 	fi, err := sftp.c.Stat(sftp.Layout.Filename(backend.Handle{Type: backend.ConfigFile}))
 	m := util.DeriveModesFromFileInfo(fi, err)
 	debug.Log("using (%03O file, %03O dir) permissions", m.File, m.Dir)
@@ -163,6 +186,7 @@ func open(sftp *SFTP, cfg Config) (*SFTP, error) {
 	sftp.Config = cfg
 	sftp.p = cfg.Path
 	sftp.Modes = m
+	sftp.quota = quota
 	return sftp, nil
 }
 
@@ -241,6 +265,11 @@ func buildSSHCommand(cfg Config) (cmd string, args []string, err error) {
 // Create creates an sftp backend as described by the config by running "ssh"
 // with the appropriate arguments (or cfg.Command, if set).
 func Create(ctx context.Context, cfg Config) (*SFTP, error) {
+	cfg, err := applyPreset(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	sftp, err := startClient(cfg)
 	if err != nil {
 		debug.Log("unable to start program: %v", err)
@@ -363,6 +392,27 @@ func (r *SFTP) Save(_ context.Context, h backend.Handle, rd backend.RewindReader
 	return errors.Wrap(err, "Rename")
 }
 
+// Space returns the configured quota and, if the server supports the
+// statvfs@openssh.com extension, the free space of the remote file system.
+func (r *SFTP) Space(_ context.Context) (backend.SpaceInfo, error) {
+	if err := r.clientError(); err != nil {
+		return backend.SpaceInfo{}, err
+	}
+
+	info := backend.SpaceInfo{Quota: r.quota}
+	if _, ok := r.c.HasExtension("statvfs@openssh.com"); !ok {
+		return info, nil
+	}
+
+	fsinfo, err := r.c.StatVFS(r.p)
+	if err != nil {
+		return backend.SpaceInfo{}, errors.Wrap(err, "StatVFS")
+	}
+	info.Free = fsinfo.Frsize * fsinfo.Bavail
+	info.FreeKnown = true
+	return info, nil
+}
+
 // checkNoSpace checks if err was likely caused by lack of available space
 // on the remote, and if so, makes it permanent.
 func (r *SFTP) checkNoSpace(dir string, size int64, origErr error) error {
@@ -0,0 +1,99 @@
+package smb
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// Config contains all configuration necessary to connect to a SMB share.
+type Config struct {
+	Host      string
+	Port      string
+	ShareName string
+	Path      string
+
+	User     string               `option:"user"     help:"set the user name for authentication (default: $RESTIC_SMB_USER)"`
+	Password options.SecretString `option:"password" help:"set the password for authentication (default: $RESTIC_SMB_PASSWORD)"`
+	Domain   string               `option:"domain"   help:"set the domain for authentication (default: $RESTIC_SMB_DOMAIN or WORKGROUP)"`
+
+	Connections           uint          `option:"connections"             help:"set a limit for the number of concurrent connections (default: 2)"`
+	IdleTimeout           time.Duration `option:"idle-timeout"            help:"close connections which were unused for the given duration (default: 60s)"`
+	RequireMessageSigning bool          `option:"require-message-signing" help:"require the server to sign all messages"`
+}
+
+// NewConfig returns a new config with default options applied.
+func NewConfig() Config {
+	return Config{
+		Port:        "445",
+		Connections: 2,
+		IdleTimeout: 60 * time.Second,
+	}
+}
+
+func init() {
+	options.Register("smb", NewConfig())
+}
+
+// ParseConfig parses the string s and extracts the smb config. The supported
+// configuration format is smb://[user@]host[:port]/sharename[/path]. If no
+// path is given, the repository is stored in the root directory of the share.
+func ParseConfig(s string) (*Config, error) {
+	if !strings.HasPrefix(s, "smb://") {
+		return nil, errors.New("invalid format, want: smb://[user@]host[:port]/sharename[/path]")
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	cfg := NewConfig()
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return nil, errors.New("smb: the password must not be part of the URL, use $RESTIC_SMB_PASSWORD instead")
+		}
+		cfg.User = u.User.Username()
+	}
+
+	cfg.Host = u.Hostname()
+	if cfg.Host == "" {
+		return nil, errors.Errorf("invalid backend %q, no host specified", s)
+	}
+	if u.Port() != "" {
+		cfg.Port = u.Port()
+	}
+
+	share, dir, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if share == "" {
+		return nil, errors.Errorf("invalid backend %q, no share name specified", s)
+	}
+	cfg.ShareName = share
+	cfg.Path = strings.TrimPrefix(path.Clean("/"+dir), "/")
+	if cfg.Path == "" {
+		cfg.Path = "."
+	}
+
+	return &cfg, nil
+}
+
+var _ backend.ApplyEnvironmenter = &Config{}
+
+// ApplyEnvironment saves values from the environment to the config.
+func (cfg *Config) ApplyEnvironment(prefix string) {
+	if cfg.User == "" {
+		cfg.User = os.Getenv(prefix + "RESTIC_SMB_USER")
+	}
+	if cfg.Password.String() == "" {
+		cfg.Password = options.NewSecretString(os.Getenv(prefix + "RESTIC_SMB_PASSWORD"))
+	}
+	if cfg.Domain == "" {
+		cfg.Domain = os.Getenv(prefix + "RESTIC_SMB_DOMAIN")
+	}
+}
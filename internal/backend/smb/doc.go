@@ -0,0 +1,3 @@
+// Package smb implements repository storage in a directory on a SMB/CIFS
+// network share.
+package smb
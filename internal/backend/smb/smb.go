@@ -0,0 +1,543 @@
+package smb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/layout"
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/util"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+
+	"github.com/hirochachacha/go-smb2"
+)
+
+// SMB is a backend which stores the data in a directory on a SMB share.
+type SMB struct {
+	dialer *smb2.Dialer
+
+	// idle connections, the most recently used connection is last
+	poolMu sync.Mutex
+	pool   []*conn
+	// closed is set by Close, connections returned afterwards are closed
+	closed bool
+
+	layout.Layout
+	Config
+}
+
+// conn is a session with the share mounted.
+type conn struct {
+	tcp       net.Conn
+	session   *smb2.Session
+	share     *smb2.Share
+	idleSince time.Time
+}
+
+// ensure statically that *SMB implements backend.Backend.
+var _ backend.Backend = &SMB{}
+
+var errTooShort = fmt.Errorf("file is too short")
+
+// dialTimeout limits the time to establish a TCP connection to the server.
+const dialTimeout = 30 * time.Second
+
+func NewFactory() location.Factory {
+	return location.NewLimitedBackendFactory("smb", ParseConfig, location.NoPassword, limiter.WrapBackendConstructor(Create), limiter.WrapBackendConstructor(Open))
+}
+
+func open(ctx context.Context, cfg Config) (*SMB, error) {
+	debug.Log("open, config %#v", cfg)
+
+	if cfg.User == "" {
+		return nil, errors.Fatal("unable to open SMB backend: user ($RESTIC_SMB_USER) is empty")
+	}
+
+	domain := cfg.Domain
+	if domain == "" {
+		domain = "WORKGROUP"
+	}
+
+	be := &SMB{
+		dialer: &smb2.Dialer{
+			Negotiator: smb2.Negotiator{
+				RequireMessageSigning: cfg.RequireMessageSigning,
+			},
+			Initiator: &smb2.NTLMInitiator{
+				User:     cfg.User,
+				Password: cfg.Password.Unwrap(),
+				Domain:   domain,
+			},
+		},
+		Layout: layout.NewDefaultLayout(cfg.Path, path.Join),
+		Config: cfg,
+	}
+
+	// connect once to report unreachable servers and invalid credentials
+	// right away
+	c, err := be.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	be.putConn(c, nil)
+
+	return be, nil
+}
+
+// Open opens the SMB backend at the share and path specified by cfg.
+func Open(ctx context.Context, cfg Config) (*SMB, error) {
+	return open(ctx, cfg)
+}
+
+// Create creates all the necessary directories for a new repository on the
+// share.
+func Create(ctx context.Context, cfg Config) (*SMB, error) {
+	be, err := open(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = be.withShare(ctx, func(share *smb2.Share) error {
+		// test if config file already exists
+		_, err := share.Lstat(be.Filename(backend.Handle{Type: backend.ConfigFile}))
+		if err == nil {
+			return errors.New("config file already exists")
+		}
+
+		// create paths for data and refs
+		for _, d := range be.Paths() {
+			if err := share.MkdirAll(d, 0755); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = be.Close()
+		return nil, err
+	}
+
+	return be, nil
+}
+
+// dial establishes a new session and mounts the share.
+func (b *SMB) dial(ctx context.Context) (*conn, error) {
+	addr := net.JoinHostPort(b.Host, b.Port)
+	debug.Log("connecting to %v", addr)
+
+	d := net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}
+	tcp, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "Dial")
+	}
+
+	session, err := b.dialer.DialContext(ctx, tcp)
+	if err != nil {
+		_ = tcp.Close()
+		return nil, errors.Wrap(err, "SessionSetup")
+	}
+
+	share, err := session.WithContext(ctx).Mount(b.ShareName)
+	if err != nil {
+		_ = session.Logoff()
+		_ = tcp.Close()
+		return nil, errors.Wrap(err, "Mount")
+	}
+
+	return &conn{tcp: tcp, session: session, share: share}, nil
+}
+
+func (c *conn) close() {
+	// the server may be unreachable, don't wait for a response
+	_ = c.tcp.SetDeadline(time.Now().Add(time.Second))
+	if err := c.share.Umount(); err != nil {
+		debug.Log("Umount returned error %v", err)
+	}
+	if err := c.session.Logoff(); err != nil {
+		debug.Log("Logoff returned error %v", err)
+	}
+	_ = c.tcp.Close()
+}
+
+// getConn returns an idle connection or establishes a new one. Connections
+// which were idle for longer than the configured idle timeout are closed.
+func (b *SMB) getConn(ctx context.Context) (*conn, error) {
+	var expired []*conn
+
+	b.poolMu.Lock()
+	var c *conn
+	for len(b.pool) > 0 {
+		c = b.pool[len(b.pool)-1]
+		b.pool = b.pool[:len(b.pool)-1]
+		if time.Since(c.idleSince) <= b.IdleTimeout {
+			break
+		}
+		expired = append(expired, c)
+		c = nil
+	}
+	b.poolMu.Unlock()
+
+	for _, e := range expired {
+		e.close()
+	}
+
+	if c != nil {
+		return c, nil
+	}
+	return b.dial(ctx)
+}
+
+// putConn returns the connection to the pool. If err indicates that the
+// connection is broken, it is closed instead. Flaky servers usually drop all
+// connections at once, thus the idle connections are discarded as well.
+func (b *SMB) putConn(c *conn, err error) {
+	if isConnError(err) {
+		debug.Log("discarding connections after error %v", err)
+
+		b.poolMu.Lock()
+		pool := b.pool
+		b.pool = nil
+		b.poolMu.Unlock()
+
+		c.close()
+		for _, c := range pool {
+			c.close()
+		}
+		return
+	}
+
+	c.idleSince = time.Now()
+	b.poolMu.Lock()
+	if b.closed {
+		b.poolMu.Unlock()
+		c.close()
+		return
+	}
+	b.pool = append(b.pool, c)
+	b.poolMu.Unlock()
+}
+
+// isConnError returns true if err indicates that the connection to the
+// server is no longer usable.
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var transportErr *smb2.TransportError
+	var responseErr *smb2.InvalidResponseError
+	var contextErr *smb2.ContextError
+	var netErr net.Error
+	return errors.As(err, &transportErr) ||
+		errors.As(err, &responseErr) ||
+		errors.As(err, &contextErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// withShare runs fn with a connection from the pool. A broken connection is
+// not reused, such that a retried operation establishes a new connection.
+func (b *SMB) withShare(ctx context.Context, fn func(share *smb2.Share) error) error {
+	c, err := b.getConn(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(c.share.WithContext(ctx))
+	b.putConn(c, err)
+	return err
+}
+
+// Connections returns the maximum number of concurrent connections.
+func (b *SMB) Connections() uint {
+	return b.Config.Connections
+}
+
+// Hasher may return a hash function for calculating a content hash for the backend
+func (b *SMB) Hasher() hash.Hash {
+	return nil
+}
+
+// HasAtomicReplace returns whether Save() can atomically replace files
+func (b *SMB) HasAtomicReplace() bool {
+	return false
+}
+
+// IsNotExist returns true if the error is caused by a not existing file.
+func (b *SMB) IsNotExist(err error) bool {
+	return errors.Is(err, os.ErrNotExist)
+}
+
+func (b *SMB) IsPermanentError(err error) bool {
+	return b.IsNotExist(err) || errors.Is(err, errTooShort) || errors.Is(err, os.ErrPermission)
+}
+
+// tempSuffix generates a random string suffix that should be sufficiently long
+// to avoid accidental conflicts
+func tempSuffix() string {
+	var nonce [16]byte
+	_, err := rand.Read(nonce[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(nonce[:])
+}
+
+// Save stores data in the backend at the handle.
+func (b *SMB) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	filename := b.Filename(h)
+	tmpFilename := filename + "-restic-temp-" + tempSuffix()
+
+	return b.withShare(ctx, func(share *smb2.Share) (err error) {
+		// create new file
+		f, err := share.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if b.IsNotExist(err) {
+			// error is caused by a missing directory, try to create it
+			mkdirErr := share.MkdirAll(b.Dirname(h), 0755)
+			if mkdirErr != nil {
+				debug.Log("error creating dir %v: %v", b.Dirname(h), mkdirErr)
+			} else {
+				// try again
+				f, err = share.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+			}
+		}
+		if err != nil {
+			return errors.Wrap(err, "OpenFile")
+		}
+
+		defer func() {
+			if err == nil || isConnError(err) {
+				return
+			}
+
+			// Try not to leave a partial file behind.
+			rmErr := share.Remove(tmpFilename)
+			if rmErr != nil {
+				debug.Log("failed to remove broken file %v: %v", tmpFilename, rmErr)
+			}
+		}()
+
+		wbytes, err := io.Copy(f, rd)
+		if err != nil {
+			_ = f.Close()
+			return errors.Wrap(err, "Write")
+		}
+
+		// sanity check
+		if wbytes != rd.Length() {
+			_ = f.Close()
+			return errors.Errorf("wrote %d bytes instead of the expected %d bytes", wbytes, rd.Length())
+		}
+
+		if err = f.Sync(); err != nil {
+			_ = f.Close()
+			return errors.Wrap(err, "Sync")
+		}
+
+		if err = f.Close(); err != nil {
+			return errors.Wrap(err, "Close")
+		}
+
+		err = share.Rename(tmpFilename, filename)
+		return errors.Wrap(err, "Rename")
+	})
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (b *SMB) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	return util.DefaultLoad(ctx, h, length, offset, b.openReader, func(rd io.Reader) error {
+		if length == 0 || !feature.Flag.Enabled(feature.BackendErrorRedesign) {
+			return fn(rd)
+		}
+
+		// there is no direct way to efficiently check whether the file is too short
+		// rd is already a LimitedReader which can be used to track the number of bytes read
+		err := fn(rd)
+
+		// check the underlying reader to be agnostic to however fn() handles the returned error
+		_, rderr := rd.Read([]byte{0})
+		if rderr == io.EOF && rd.(*util.LimitedReadCloser).N != 0 {
+			// file is too short
+			return fmt.Errorf("%w: %v", errTooShort, err)
+		}
+
+		return err
+	})
+}
+
+// connReader reads from a file and returns the connection to the pool once
+// it is closed.
+type connReader struct {
+	f   *smb2.File
+	be  *SMB
+	c   *conn
+	err error
+}
+
+func (rd *connReader) Read(p []byte) (int, error) {
+	n, err := rd.f.Read(p)
+	if err != nil && err != io.EOF {
+		rd.err = err
+	}
+	return n, err
+}
+
+func (rd *connReader) Close() error {
+	err := rd.f.Close()
+	if rd.err == nil {
+		rd.err = err
+	}
+	rd.be.putConn(rd.c, rd.err)
+	return err
+}
+
+func (b *SMB) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+	c, err := b.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.share.WithContext(ctx).Open(b.Filename(h))
+	if err != nil {
+		b.putConn(c, err)
+		return nil, err
+	}
+
+	if offset > 0 {
+		_, err = f.Seek(offset, io.SeekStart)
+		if err != nil {
+			_ = f.Close()
+			b.putConn(c, err)
+			return nil, err
+		}
+	}
+
+	rd := &connReader{f: f, be: b, c: c}
+	if length > 0 {
+		return util.LimitReadCloser(rd, int64(length)), nil
+	}
+
+	return rd, nil
+}
+
+// Stat returns information about a blob.
+func (b *SMB) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	var fi os.FileInfo
+	err := b.withShare(ctx, func(share *smb2.Share) (err error) {
+		fi, err = share.Lstat(b.Filename(h))
+		return err
+	})
+	if err != nil {
+		return backend.FileInfo{}, errors.Wrap(err, "Lstat")
+	}
+
+	return backend.FileInfo{Size: fi.Size(), Name: h.Name}, nil
+}
+
+// Remove removes the content stored at name.
+func (b *SMB) Remove(ctx context.Context, h backend.Handle) error {
+	return b.withShare(ctx, func(share *smb2.Share) error {
+		return share.Remove(b.Filename(h))
+	})
+}
+
+// readDir returns the entries of the directory dir.
+func (b *SMB) readDir(ctx context.Context, dir string) ([]os.FileInfo, error) {
+	var entries []os.FileInfo
+	err := b.withShare(ctx, func(share *smb2.Share) (err error) {
+		entries, err = share.ReadDir(dir)
+		return err
+	})
+	return entries, err
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it.
+func (b *SMB) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	basedir, subdirs := b.Basedir(t)
+
+	// the directory entries are read before calling fn, such that the
+	// connection is not blocked while fn is running
+	var listDir func(dir string, recurse bool) error
+	listDir = func(dir string, recurse bool) error {
+		entries, err := b.readDir(ctx, dir)
+		if err != nil {
+			if b.IsNotExist(err) {
+				debug.Log("ignoring non-existing directory")
+				return nil
+			}
+			return err
+		}
+
+		for _, fi := range entries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if fi.IsDir() {
+				if recurse {
+					if err := listDir(path.Join(dir, fi.Name()), false); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+
+			debug.Log("send %v\n", fi.Name())
+
+			err := fn(backend.FileInfo{
+				Name: fi.Name(),
+				Size: fi.Size(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := listDir(basedir, subdirs); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Delete removes all restic files in the repository.
+func (b *SMB) Delete(ctx context.Context) error {
+	return util.DefaultDelete(ctx, b)
+}
+
+// Close closes all idle connections to the server. Connections which are
+// still in use are closed once they are returned.
+func (b *SMB) Close() error {
+	b.poolMu.Lock()
+	pool := b.pool
+	b.pool = nil
+	b.closed = true
+	b.poolMu.Unlock()
+
+	for _, c := range pool {
+		c.close()
+	}
+	return nil
+}
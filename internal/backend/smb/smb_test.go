@@ -0,0 +1,106 @@
+package smb_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend/smb"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newSMBTestSuite() *test.Suite[smb.Config] {
+	return &test.Suite[smb.Config]{
+		// do not use excessive data
+		MinimalData: true,
+
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (*smb.Config, error) {
+			cfg, err := smb.ParseConfig(os.Getenv("RESTIC_TEST_SMB_REPOSITORY"))
+			if err != nil {
+				return nil, err
+			}
+
+			cfg.ApplyEnvironment("RESTIC_TEST_")
+			cfg.Path = path.Join(cfg.Path, fmt.Sprintf("test-%d", time.Now().UnixNano()))
+			return cfg, nil
+		},
+
+		Factory: smb.NewFactory(),
+	}
+}
+
+func testVars(t testing.TB) {
+	vars := []string{
+		"RESTIC_TEST_SMB_REPOSITORY",
+		"RESTIC_TEST_RESTIC_SMB_USER",
+		"RESTIC_TEST_RESTIC_SMB_PASSWORD",
+	}
+
+	for _, v := range vars {
+		if os.Getenv(v) == "" {
+			t.Skipf("environment variable %v not set", v)
+			return
+		}
+	}
+}
+
+func TestBackendSMB(t *testing.T) {
+	defer func() {
+		if t.Skipped() {
+			rtest.SkipDisallowed(t, "restic/backend/smb.TestBackendSMB")
+		}
+	}()
+
+	testVars(t)
+	newSMBTestSuite().RunTests(t)
+}
+
+func BenchmarkBackendSMB(t *testing.B) {
+	testVars(t)
+	newSMBTestSuite().RunBenchmarks(t)
+}
+
+func TestOpenUnreachable(t *testing.T) {
+	// reserve a port which refuses connections
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	rtest.OK(t, err)
+	addr := l.Addr().String()
+	rtest.OK(t, l.Close())
+
+	cfg, err := smb.ParseConfig("smb://user@" + addr + "/share")
+	rtest.OK(t, err)
+	_, err = smb.Open(context.TODO(), *cfg)
+	rtest.Assert(t, err != nil, "expected error for unreachable server")
+}
+
+func TestOpenClosedConnection(t *testing.T) {
+	// a server which closes connections immediately
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	rtest.OK(t, err)
+	defer func() { _ = l.Close() }()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = io.CopyN(io.Discard, c, 1)
+			_ = c.Close()
+		}
+	}()
+
+	cfg, err := smb.ParseConfig("smb://user@" + l.Addr().String() + "/share")
+	rtest.OK(t, err)
+	_, err = smb.Open(context.TODO(), *cfg)
+	rtest.Assert(t, err != nil, "expected error for closed connection")
+	rtest.Assert(t, !errors.Is(err, os.ErrNotExist), "unexpected error %v", err)
+}
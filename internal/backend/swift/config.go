@@ -40,7 +40,7 @@ type Config struct {
 }
 
 func init() {
-	options.Register("swift", Config{})
+	options.Register("swift", NewConfig())
 }
 
 // NewConfig returns a new config with the default values filled in.
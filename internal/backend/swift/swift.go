@@ -115,6 +115,12 @@ func (be *beSwift) Connections() uint {
 	return be.connections
 }
 
+// ParallelRangeRequests implements backend.ParallelRangeBackend. All
+// connections can be used to load different parts of a single file.
+func (be *beSwift) ParallelRangeRequests() uint {
+	return be.Connections()
+}
+
 // Hasher may return a hash function for calculating a content hash for the backend
 func (be *beSwift) Hasher() hash.Hash {
 	return md5.New()
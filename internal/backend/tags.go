@@ -0,0 +1,30 @@
+package backend
+
+import (
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// ParseTags parses a comma-separated list of key=value pairs, for example
+// "team=infra,purpose=backup", as used by the options which attach tags to
+// uploaded files. An empty string yields a nil map.
+func ParseTags(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, errors.Errorf("invalid tag %q, must be key=value", pair)
+		}
+		if _, ok := tags[key]; ok {
+			return nil, errors.Errorf("duplicate tag %q", key)
+		}
+		tags[key] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		tags map[string]string
+		err  bool
+	}{
+		{"", nil, false},
+		{"team=infra", map[string]string{"team": "infra"}, false},
+		{"team=infra,purpose=backup", map[string]string{"team": "infra", "purpose": "backup"}, false},
+		{" team = infra , empty=", map[string]string{"team": "infra", "empty": ""}, false},
+		{"team", nil, true},
+		{"=infra", nil, true},
+		{"team=infra,", nil, true},
+		{"team=a,team=b", nil, true},
+	} {
+		tags, err := ParseTags(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", test.s, tags)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.s, err)
+		}
+		if !reflect.DeepEqual(tags, test.tags) {
+			t.Errorf("%q: want %v, got %v", test.s, test.tags, tags)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// EnvelopeConfig contains the options for the envelope transform.
+type EnvelopeConfig struct {
+	WrapCommand   string `option:"wrap-command" help:"command which encrypts a data key read from stdin, for example using a cloud KMS"`
+	UnwrapCommand string `option:"unwrap-command" help:"command which decrypts a data key read from stdin"`
+}
+
+func init() {
+	register("envelope", EnvelopeConfig{}, newEnvelope)
+}
+
+const (
+	envelopeMagic          = "RTE1"
+	envelopeDataKeySize    = 32
+	envelopeMaxWrappedSize = 1024
+	envelopeNonceSize      = 12
+	envelopeHeaderSize     = len(envelopeMagic) + 2 + envelopeMaxWrappedSize + envelopeNonceSize
+)
+
+// envelope encrypts pack files with a data key, which is itself encrypted by
+// an external command, usually a key management service. The encrypted data
+// key is stored in the header of each pack file. A single data key is used
+// for all pack files uploaded by one restic process.
+type envelope struct {
+	cfg EnvelopeConfig
+
+	m         sync.Mutex
+	key       []byte
+	wrapped   []byte
+	unwrapped map[string][]byte
+}
+
+func newEnvelope(opts options.Options) (Transform, error) {
+	var cfg EnvelopeConfig
+	if err := opts.Apply("transform.envelope", &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.WrapCommand == "" || cfg.UnwrapCommand == "" {
+		return nil, errors.New("both -o transform.envelope.wrap-command and -o transform.envelope.unwrap-command must be specified")
+	}
+	return &envelope{
+		cfg:       cfg,
+		unwrapped: make(map[string][]byte),
+	}, nil
+}
+
+// runKeyCommand runs the command with data as its standard input and returns
+// the standard output.
+func runKeyCommand(ctx context.Context, command string, data []byte) ([]byte, error) {
+	args, err := backend.SplitShellStrings(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("command is empty")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("command %v failed: %w: %v", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// dataKey returns the data key used for new pack files and its wrapped form.
+func (e *envelope) dataKey(ctx context.Context) ([]byte, []byte, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.key != nil {
+		return e.key, e.wrapped, nil
+	}
+
+	key := make([]byte, envelopeDataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := runKeyCommand(ctx, e.cfg.WrapCommand, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(wrapped) == 0 || len(wrapped) > envelopeMaxWrappedSize {
+		return nil, nil, errors.Errorf("wrapped data key has invalid size %d, at most %d bytes are supported", len(wrapped), envelopeMaxWrappedSize)
+	}
+
+	e.key, e.wrapped = key, wrapped
+	e.unwrapped[string(wrapped)] = key
+	return key, wrapped, nil
+}
+
+// unwrap returns the data key for a wrapped key, the result is cached.
+func (e *envelope) unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if key, ok := e.unwrapped[string(wrapped)]; ok {
+		return key, nil
+	}
+
+	key, err := runKeyCommand(ctx, e.cfg.UnwrapCommand, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != envelopeDataKeySize {
+		return nil, errors.Errorf("unwrapped data key has invalid size %d", len(key))
+	}
+	e.unwrapped[string(wrapped)] = key
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (e *envelope) Apply(ctx context.Context, data []byte) ([]byte, error) {
+	key, wrapped, err := e.dataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(data)+aead.Overhead())
+	copy(buf, envelopeMagic)
+	binary.LittleEndian.PutUint16(buf[len(envelopeMagic):], uint16(len(wrapped)))
+	copy(buf[len(envelopeMagic)+2:], wrapped)
+	nonce := buf[envelopeHeaderSize-envelopeNonceSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	// the header is authenticated as additional data
+	return aead.Seal(buf, nonce, data, buf[:envelopeHeaderSize]), nil
+}
+
+func (e *envelope) Revert(ctx context.Context, data []byte) ([]byte, error) {
+	if len(data) < envelopeHeaderSize || string(data[:len(envelopeMagic)]) != envelopeMagic {
+		return nil, errors.New("invalid envelope header")
+	}
+	l := int(binary.LittleEndian.Uint16(data[len(envelopeMagic):]))
+	if l == 0 || l > envelopeMaxWrappedSize {
+		return nil, errors.New("invalid envelope header")
+	}
+	wrapped := data[len(envelopeMagic)+2 : len(envelopeMagic)+2+l]
+
+	key, err := e.unwrap(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := data[envelopeHeaderSize-envelopeNonceSize : envelopeHeaderSize]
+	plaintext, err := aead.Open(nil, nonce, data[envelopeHeaderSize:], data[:envelopeHeaderSize])
+	if err != nil {
+		return nil, errors.New("envelope decryption failed")
+	}
+	return plaintext, nil
+}
+
+func (e *envelope) Overhead() int64 {
+	// AES-GCM adds a 16 byte authentication tag
+	return int64(envelopeHeaderSize) + 16
+}
@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// SignConfig contains the options for the sign transform.
+type SignConfig struct {
+	KeyFile string `option:"key-file" help:"file containing the secret key used to sign pack files"`
+}
+
+func init() {
+	register("sign", SignConfig{}, newSign)
+}
+
+// sign appends a HMAC-SHA-256 of the content to each pack file. The key is
+// independent of the repository keys, such that pack files can be verified
+// by parties who cannot decrypt the repository.
+type sign struct {
+	key []byte
+}
+
+func newSign(opts options.Options) (Transform, error) {
+	var cfg SignConfig
+	if err := opts.Apply("transform.sign", &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.KeyFile == "" {
+		return nil, errors.New("no key file specified, use -o transform.sign.key-file=<file>")
+	}
+
+	key, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < 16 {
+		return nil, errors.Errorf("key in %v is too short, at least 16 bytes are required", cfg.KeyFile)
+	}
+	return &sign{key: key}, nil
+}
+
+func (s *sign) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+func (s *sign) Apply(_ context.Context, data []byte) ([]byte, error) {
+	return append(data, s.mac(data)...), nil
+}
+
+func (s *sign) Revert(_ context.Context, data []byte) ([]byte, error) {
+	if len(data) < sha256.Size {
+		return nil, errors.New("signature missing")
+	}
+	content, sig := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(sig, s.mac(content)) {
+		return nil, errors.New("invalid signature")
+	}
+	return content, nil
+}
+
+func (s *sign) Overhead() int64 {
+	return sha256.Size
+}
@@ -0,0 +1,242 @@
+// Package transform implements a pipeline of optional steps which modify pack
+// files before they are uploaded and revert the modification when the pack
+// files are loaded again.
+package transform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// Transform modifies the content of pack files.
+type Transform interface {
+	// Apply returns the data to store for a pack file.
+	Apply(ctx context.Context, data []byte) ([]byte, error)
+	// Revert returns the original content of a pack file from the stored data.
+	Revert(ctx context.Context, data []byte) ([]byte, error)
+	// Overhead returns the number of bytes added by Apply.
+	Overhead() int64
+}
+
+// Factory returns a new Transform configured using opts.
+type Factory func(opts options.Options) (Transform, error)
+
+var factories = make(map[string]Factory)
+
+// register adds a transform with the given name. The options of the
+// transform are defined by cfg and use the namespace "transform.<name>".
+func register(name string, cfg interface{}, factory Factory) {
+	factories[name] = factory
+	options.Register("transform."+name, cfg)
+}
+
+// Names returns the names of all available transforms.
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load returns the transforms with the given names in the same order. Each
+// transform is configured using the options in the namespace
+// "transform.<name>".
+func Load(names []string, opts options.Options) ([]Transform, error) {
+	transforms := make([]Transform, 0, len(names))
+	for _, name := range names {
+		factory, ok := factories[name]
+		if !ok {
+			return nil, errors.Fatalf("unknown pack transform %q, available transforms: %v", name, Names())
+		}
+
+		t, err := factory(opts.Extract("transform." + name))
+		if err != nil {
+			return nil, errors.Fatalf("pack transform %v: %v", name, err)
+		}
+		transforms = append(transforms, t)
+	}
+	return transforms, nil
+}
+
+var errTooShort = errors.New("file is too short")
+
+// Backend applies the transforms to all pack files saved to the underlying
+// backend and reverts them on load. The sizes of pack files returned by List()
+// and Stat() are those of the original content. As the stored data can only be
+// reverted as a whole, Load() always downloads complete pack files. Callers
+// which need several parts of a pack file should use LoadRanges(), which
+// downloads the file only once.
+type Backend struct {
+	backend.Backend
+	transforms []Transform
+	overhead   int64
+}
+
+// maxRanges is the number of ranges served from one download of a pack file.
+// The repository additionally limits the total size of the ranges.
+const maxRanges = 4096
+
+// statically ensure that Backend implements backend.Backend.
+var _ backend.Backend = &Backend{}
+var _ backend.MultiRangeBackend = &Backend{}
+
+// New returns a backend which applies the transforms in the given order.
+func New(be backend.Backend, transforms ...Transform) *Backend {
+	var overhead int64
+	for _, t := range transforms {
+		overhead += t.Overhead()
+	}
+
+	return &Backend{
+		Backend:    be,
+		transforms: transforms,
+		overhead:   overhead,
+	}
+}
+
+// Hasher returns nil, as the content hash of a pack file can only be
+// calculated after applying the transforms. Save calculates the hash for all
+// other files.
+func (b *Backend) Hasher() hash.Hash {
+	return nil
+}
+
+func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if h.Type != backend.PackFile && (rd.Hash() != nil || b.Backend.Hasher() == nil) {
+		return b.Backend.Save(ctx, h, rd)
+	}
+
+	buf := make([]byte, rd.Length())
+	_, err := io.ReadFull(rd, buf)
+	if err != nil {
+		return errors.Wrap(err, "ReadFull")
+	}
+
+	if h.Type != backend.PackFile {
+		return b.Backend.Save(ctx, h, backend.NewByteReader(buf, b.Backend.Hasher()))
+	}
+
+	for _, t := range b.transforms {
+		buf, err = t.Apply(ctx, buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.Backend.Save(ctx, h, backend.NewByteReader(buf, b.Backend.Hasher()))
+}
+
+func (b *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	if h.Type != backend.PackFile {
+		return b.Backend.Load(ctx, h, length, offset, fn)
+	}
+
+	buf, err := b.loadReverted(ctx, h)
+	if err != nil {
+		return err
+	}
+	buf, err = section(buf, length, offset)
+	if err != nil {
+		return err
+	}
+	return fn(bytes.NewReader(buf))
+}
+
+// MaxRanges implements backend.MultiRangeBackend.
+func (b *Backend) MaxRanges() int {
+	return maxRanges
+}
+
+// LoadRanges implements backend.MultiRangeBackend. Pack files are downloaded
+// and reverted once for all ranges.
+func (b *Backend) LoadRanges(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+	if h.Type != backend.PackFile {
+		return backend.LoadRanges(ctx, b.Backend, h, ranges, fn)
+	}
+
+	buf, err := b.loadReverted(ctx, h)
+	if err != nil {
+		return err
+	}
+	for i, r := range ranges {
+		part, err := section(buf, r.Length, r.Offset)
+		if err != nil {
+			return err
+		}
+		if err := fn(i, bytes.NewReader(part)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadReverted downloads the complete file at h and reverts the transforms.
+func (b *Backend) loadReverted(ctx context.Context, h backend.Handle) ([]byte, error) {
+	var buf []byte
+	err := b.Backend.Load(ctx, h, 0, 0, func(rd io.Reader) error {
+		var err error
+		buf, err = io.ReadAll(rd)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(b.transforms) - 1; i >= 0; i-- {
+		buf, err = b.transforms[i].Revert(ctx, buf)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", h, err)
+		}
+	}
+	return buf, nil
+}
+
+// section returns length bytes of buf starting at offset. A length of zero
+// selects everything up to the end of buf.
+func section(buf []byte, length int, offset int64) ([]byte, error) {
+	if offset > int64(len(buf)) || (length > 0 && offset+int64(length) > int64(len(buf))) {
+		return nil, errTooShort
+	}
+	buf = buf[offset:]
+	if length > 0 {
+		buf = buf[:length]
+	}
+	return buf, nil
+}
+
+func (b *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	fi, err := b.Backend.Stat(ctx, h)
+	if err == nil && h.Type == backend.PackFile {
+		fi.Size -= b.overhead
+	}
+	return fi, err
+}
+
+func (b *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	if t != backend.PackFile {
+		return b.Backend.List(ctx, t, fn)
+	}
+
+	return b.Backend.List(ctx, t, func(fi backend.FileInfo) error {
+		fi.Size -= b.overhead
+		return fn(fi)
+	})
+}
+
+func (b *Backend) IsPermanentError(err error) bool {
+	return errors.Is(err, errTooShort) || b.Backend.IsPermanentError(err)
+}
+
+func (b *Backend) Unwrap() backend.Backend {
+	return b.Backend
+}
@@ -0,0 +1,157 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testLoadTransforms(t testing.TB, names ...string) []Transform {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	rtest.OK(t, os.WriteFile(keyFile, rtest.Random(23, 32), 0600))
+
+	transforms, err := Load(names, options.Options{
+		"transform.sign.key-file":           keyFile,
+		"transform.envelope.wrap-command":   "base64",
+		"transform.envelope.unwrap-command": "base64 -d",
+	})
+	rtest.OK(t, err)
+	return transforms
+}
+
+func load(t testing.TB, be backend.Backend, h backend.Handle, length int, offset int64) ([]byte, error) {
+	var buf []byte
+	err := be.Load(context.TODO(), h, length, offset, func(rd io.Reader) error {
+		var err error
+		buf, err = io.ReadAll(rd)
+		return err
+	})
+	return buf, err
+}
+
+func TestBackend(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip(err)
+	}
+	ctx := context.TODO()
+
+	for _, names := range [][]string{{"sign"}, {"envelope"}, {"envelope", "sign"}} {
+		transforms := testLoadTransforms(t, names...)
+		raw := mem.New()
+		be := New(raw, transforms...)
+
+		data := rtest.Random(42, 5000)
+		h := backend.Handle{Type: backend.PackFile, Name: restic.Hash(data).String()}
+		rtest.OK(t, be.Save(ctx, h, backend.NewByteReader(data, nil)))
+
+		stored, err := load(t, raw, h, 0, 0)
+		rtest.OK(t, err)
+		rtest.Assert(t, !bytes.Equal(data, stored), "%v: data was not transformed", names)
+
+		buf, err := load(t, be, h, 0, 0)
+		rtest.OK(t, err)
+		rtest.Equals(t, data, buf)
+
+		buf, err = load(t, be, h, 100, 4900)
+		rtest.OK(t, err)
+		rtest.Equals(t, data[4900:], buf)
+
+		_, err = load(t, be, h, 100, 4901)
+		rtest.Assert(t, err != nil && be.IsPermanentError(err), "%v: expected permanent error, got %v", names, err)
+
+		fi, err := be.Stat(ctx, h)
+		rtest.OK(t, err)
+		rtest.Equals(t, int64(len(data)), fi.Size)
+		rtest.OK(t, be.List(ctx, backend.PackFile, func(fi backend.FileInfo) error {
+			rtest.Equals(t, int64(len(data)), fi.Size)
+			return nil
+		}))
+
+		// other file types are not modified
+		other := backend.Handle{Type: backend.SnapshotFile, Name: h.Name}
+		rtest.OK(t, be.Save(ctx, other, backend.NewByteReader(data, raw.Hasher())))
+		buf, err = load(t, raw, other, 0, 0)
+		rtest.OK(t, err)
+		rtest.Equals(t, data, buf)
+
+		// modifications are detected
+		stored[len(stored)-1] ^= 1
+		rtest.OK(t, raw.Remove(ctx, h))
+		rtest.OK(t, raw.Save(ctx, h, backend.NewByteReader(stored, raw.Hasher())))
+		_, err = load(t, be, h, 0, 0)
+		rtest.Assert(t, err != nil, "%v: modification not detected", names)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load([]string{"missing"}, options.Options{})
+	rtest.Assert(t, err != nil, "unknown transform accepted")
+
+	_, err = Load([]string{"sign"}, options.Options{})
+	rtest.Assert(t, err != nil, "missing key file not detected")
+
+	_, err = Load([]string{"envelope"}, options.Options{"transform.envelope.wrap-command": "cat"})
+	rtest.Assert(t, err != nil, "missing unwrap command not detected")
+}
+
+type countingBackend struct {
+	backend.Backend
+	loads int
+}
+
+func (be *countingBackend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	be.loads++
+	return be.Backend.Load(ctx, h, length, offset, fn)
+}
+
+func TestBackendLoadRanges(t *testing.T) {
+	ctx := context.TODO()
+	raw := &countingBackend{Backend: mem.New()}
+	be := New(raw, testLoadTransforms(t, "sign")...)
+
+	data := rtest.Random(23, 5000)
+	h := backend.Handle{Type: backend.PackFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, be.Save(ctx, h, backend.NewByteReader(data, nil)))
+
+	ranges := []backend.Range{{Offset: 0, Length: 10}, {Offset: 100, Length: 200}, {Offset: 4990, Length: 10}}
+	var parts [][]byte
+	rtest.OK(t, backend.LoadRanges(ctx, be, h, ranges, func(i int, rd io.Reader) error {
+		buf, err := io.ReadAll(rd)
+		rtest.Equals(t, len(parts), i)
+		parts = append(parts, buf)
+		return err
+	}))
+	rtest.Equals(t, 1, raw.loads)
+	for i, r := range ranges {
+		rtest.Equals(t, data[r.Offset:r.Offset+int64(r.Length)], parts[i])
+	}
+
+	err := backend.LoadRanges(ctx, be, h, []backend.Range{{Offset: 4990, Length: 11}}, func(_ int, _ io.Reader) error {
+		return nil
+	})
+	rtest.Assert(t, err != nil && be.IsPermanentError(err), "expected permanent error, got %v", err)
+}
+
+func TestBackendSaveOtherHash(t *testing.T) {
+	raw := mem.New()
+	be := New(raw, testLoadTransforms(t, "sign")...)
+
+	// the hash for files other than pack files is calculated by Save
+	data := rtest.Random(42, 100)
+	h := backend.Handle{Type: backend.KeyFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, be.Save(context.TODO(), h, backend.NewByteReader(data, be.Hasher())))
+
+	buf, err := load(t, raw, h, 0, 0)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+}
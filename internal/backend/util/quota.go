@@ -0,0 +1,19 @@
+package util
+
+import (
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/ui"
+)
+
+// ParseQuota parses the value of the quota option of a backend, for example
+// "500G". An empty string means that no quota is configured.
+func ParseQuota(s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	quota, err := ui.ParseBytes(s)
+	if err != nil {
+		return 0, errors.Fatalf("invalid quota %q: %v", s, err)
+	}
+	return uint64(quota), nil
+}
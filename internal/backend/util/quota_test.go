@@ -0,0 +1,29 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/backend/util"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseQuota(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		quota uint64
+	}{
+		{"", 0},
+		{"1024", 1024},
+		{"500G", 500 * 1024 * 1024 * 1024},
+		{"2T", 2 * 1024 * 1024 * 1024 * 1024},
+	} {
+		quota, err := util.ParseQuota(test.input)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.quota, quota)
+	}
+
+	for _, input := range []string{"foo", "-1G", "10X"} {
+		_, err := util.ParseQuota(input)
+		rtest.Assert(t, err != nil, "expected error for %q", input)
+	}
+}
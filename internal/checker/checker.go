@@ -276,7 +276,13 @@ func (c *Checker) checkTreeWorker(ctx context.Context, trees <-chan restic.TreeI
 }
 
 func loadSnapshotTreeIDs(ctx context.Context, lister restic.Lister, repo restic.LoaderUnpacked) (ids restic.IDs, errs []error) {
-	err := restic.ForAllSnapshots(ctx, lister, repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
+	var m sync.Mutex
+	// load the snapshot files directly instead of using restic.ForAllSnapshots,
+	// which would skip damaged files that are contained in the snapshot cache
+	err := restic.ParallelList(ctx, lister, restic.SnapshotFile, repo.Connections(), func(ctx context.Context, id restic.ID, _ int64) error {
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		m.Lock()
+		defer m.Unlock()
 		if err != nil {
 			errs = append(errs, err)
 			return nil
@@ -23,6 +23,13 @@ const (
 
 	// Extension is the number of bytes a plaintext is enlarged by encrypting it.
 	Extension = ivSize + macSize
+
+	// CipherName is the name of the cipher used to encrypt data.
+	CipherName = "AES-256-CTR"
+	// MACName is the name of the message authentication code.
+	MACName = "Poly1305-AES"
+	// KDFName is the name of the key derivation function used for keys.
+	KDFName = "scrypt"
 )
 
 var (
@@ -36,6 +43,17 @@ var (
 type Key struct {
 	MACKey        `json:"mac"`
 	EncryptionKey `json:"encrypt"`
+
+	// Previous is the master key which was replaced by this key. It is only
+	// set while the repository data is re-encrypted after a key rotation.
+	// Data which cannot be authenticated using this key is then opened using
+	// the previous key. New data is always sealed using this key.
+	Previous *Key `json:"previous,omitempty"`
+}
+
+// Current returns a copy of k without the previous key.
+func (k *Key) Current() *Key {
+	return &Key{MACKey: k.MACKey, EncryptionKey: k.EncryptionKey}
 }
 
 // EncryptionKey is key used for encryption
@@ -283,6 +301,9 @@ func (k *Key) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 //
 // Even if the function fails, the contents of dst, up to its capacity,
 // may be overwritten.
+//
+// If the ciphertext cannot be authenticated and k has a previous key, then
+// the ciphertext is opened using the previous key instead.
 func (k *Key) Open(dst, nonce, ciphertext, _ []byte) ([]byte, error) {
 	if !k.Valid() {
 		return nil, errors.New("invalid key")
@@ -307,6 +328,9 @@ func (k *Key) Open(dst, nonce, ciphertext, _ []byte) ([]byte, error) {
 
 	// verify mac
 	if !poly1305Verify(ct, nonce, &k.MACKey, mac) {
+		if k.Previous != nil {
+			return k.Previous.Open(dst, nonce, ciphertext, nil)
+		}
 		return nil, ErrUnauthenticated
 	}
 
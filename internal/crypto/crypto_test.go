@@ -43,6 +43,28 @@ func TestEncryptDecrypt(t *testing.T) {
 	}
 }
 
+func TestOpenPreviousKey(t *testing.T) {
+	previous := crypto.NewRandomKey()
+	k := crypto.NewRandomKey()
+	k.Previous = previous
+
+	data := rtest.Random(23, 1000)
+	nonce := crypto.NewRandomNonce()
+	ciphertext := previous.Seal(nil, nonce, data, nil)
+
+	plaintext, err := k.Open(nil, nonce, ciphertext, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, plaintext)
+
+	_, err = k.Current().Open(nil, nonce, ciphertext, nil)
+	rtest.Assert(t, err == crypto.ErrUnauthenticated, "expected ErrUnauthenticated, got %v", err)
+
+	// data is always sealed using the new key
+	ciphertext = k.Seal(nil, nonce, data, nil)
+	_, err = previous.Open(nil, nonce, ciphertext, nil)
+	rtest.Assert(t, err == crypto.ErrUnauthenticated, "expected ErrUnauthenticated, got %v", err)
+}
+
 func TestSmallBuffer(t *testing.T) {
 	k := crypto.NewRandomKey()
 
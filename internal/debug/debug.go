@@ -124,18 +124,17 @@ func goroutineNum() int {
 	return num
 }
 
-// taken from https://github.com/VividCortex/trace
-func getPosition() (fn, dir, file string, line int) {
-	pc, file, line, ok := runtime.Caller(2)
-	if !ok {
+// getPosition returns the function, directory, file and line for the program
+// counter pc as returned by runtime.Callers.
+func getPosition(pc uintptr) (fn, dir, file string, line int) {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.PC == 0 {
 		return "", "", "", 0
 	}
 
-	dirname, filename := filepath.Base(filepath.Dir(file)), filepath.Base(file)
-
-	Func := runtime.FuncForPC(pc)
+	dirname, filename := filepath.Base(filepath.Dir(frame.File)), filepath.Base(frame.File)
 
-	return path.Base(Func.Name()), dirname, filename, line
+	return path.Base(frame.Function), dirname, filename, frame.Line
 }
 
 func checkFilter(filter map[string]bool, key string) bool {
@@ -159,13 +158,34 @@ func checkFilter(filter map[string]bool, key string) bool {
 	return false
 }
 
+// Enabled returns true if debug logging is enabled.
+func Enabled() bool {
+	return opts.isEnabled
+}
+
 // Log prints a message to the debug log (if debug is enabled).
 func Log(f string, args ...interface{}) {
 	if !opts.isEnabled {
 		return
 	}
 
-	fn, dir, file, line := getPosition()
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	logAt(pcs[0], f, args...)
+}
+
+// LogAt is like Log, but reports the position of the program counter pc as
+// returned by runtime.Callers instead of the position of the caller.
+func LogAt(pc uintptr, f string, args ...interface{}) {
+	if !opts.isEnabled {
+		return
+	}
+
+	logAt(pc, f, args...)
+}
+
+func logAt(pc uintptr, f string, args ...interface{}) {
+	fn, dir, file, line := getPosition(pc)
 	goroutine := goroutineNum()
 
 	if len(f) == 0 || f[len(f)-1] != '\n' {
@@ -37,6 +37,13 @@ func (d *Dumper) DumpTree(ctx context.Context, tree *restic.Tree, rootPath strin
 	ch := make(chan *restic.Node, 10)
 	go sendTrees(ctx, d.repo, tree, rootPath, ch)
 
+	return d.DumpNodes(ctx, ch)
+}
+
+// DumpNodes writes all nodes received from ch to the archive until ch is
+// closed. The Path of each node must be set to the location of the node
+// within the archive.
+func (d *Dumper) DumpNodes(ctx context.Context, ch <-chan *restic.Node) error {
 	switch d.format {
 	case "tar":
 		return d.dumpTar(ctx, ch)
@@ -0,0 +1,108 @@
+package escrow
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/restic/restic/internal/errors"
+)
+
+// Escrow files use the age file encryption format (https://age-encryption.org/v1)
+// with X25519 recipients. They can be decrypted using the age command line
+// tool and vice versa.
+
+// ErrNoMatchingIdentity is returned by Decrypt if none of the identities can
+// decrypt the file.
+var ErrNoMatchingIdentity = errors.New("no identity matched any of the recipients")
+
+// Recipient is an X25519 public key data can be encrypted to.
+type Recipient = age.X25519Recipient
+
+// Identity is an X25519 private key which can decrypt data encrypted to the
+// corresponding Recipient.
+type Identity = age.X25519Identity
+
+// ParseRecipient parses a recipient in the "age1..." format.
+func ParseRecipient(s string) (*Recipient, error) {
+	return age.ParseX25519Recipient(s)
+}
+
+// GenerateIdentity returns a new random identity.
+func GenerateIdentity() (*Identity, error) {
+	return age.GenerateX25519Identity()
+}
+
+// ParseIdentity parses an identity in the "AGE-SECRET-KEY-1..." format.
+func ParseIdentity(s string) (*Identity, error) {
+	return age.ParseX25519Identity(s)
+}
+
+// ParseIdentities reads identities from an identity file as written by
+// age-keygen. Empty lines and lines starting with "#" are ignored.
+func ParseIdentities(rd io.Reader) ([]*Identity, error) {
+	var ids []*Identity
+	sc := bufio.NewScanner(rd)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, err := ParseIdentity(line)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no secret keys found")
+	}
+	return ids, nil
+}
+
+// Encrypt returns plaintext encrypted to all recipients.
+func Encrypt(plaintext []byte, recipients ...*Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	rs := make([]age.Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		rs = append(rs, r)
+	}
+
+	var buf bytes.Buffer
+	wr, err := age.Encrypt(&buf, rs...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := wr.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := wr.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts data encrypted to one of the identities.
+func Decrypt(data []byte, identities ...*Identity) ([]byte, error) {
+	ids := make([]age.Identity, 0, len(identities))
+	for _, id := range identities {
+		ids = append(ids, id)
+	}
+
+	rd, err := age.Decrypt(bytes.NewReader(data), ids...)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, ErrNoMatchingIdentity
+	}
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(rd)
+}
@@ -0,0 +1,64 @@
+// Package escrow exports the master key of a repository into an escrow file.
+// The file is encrypted to one or more recipients using the age file format
+// and allows recovering access to the repository if all passwords were lost.
+package escrow
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/errors"
+)
+
+// keyFileVersion is the current version of the escrow file content.
+const keyFileVersion = 1
+
+// KeyFile is the decrypted content of an escrow file.
+type KeyFile struct {
+	Version    int         `json:"version"`
+	Repository string      `json:"repository_id"`
+	Created    time.Time   `json:"created"`
+	MasterKey  *crypto.Key `json:"master_key"`
+}
+
+// Export returns an escrow file containing the master key of the repository
+// with the given ID. The file is encrypted to all recipients.
+func Export(repositoryID string, key *crypto.Key, recipients []*Recipient) ([]byte, error) {
+	if !key.Valid() {
+		return nil, errors.New("invalid master key")
+	}
+
+	buf, err := json.Marshal(KeyFile{
+		Version:    keyFileVersion,
+		Repository: repositoryID,
+		Created:    time.Now(),
+		MasterKey:  key,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Marshal")
+	}
+
+	return Encrypt(buf, recipients...)
+}
+
+// Import decrypts an escrow file using one of the identities.
+func Import(data []byte, identities []*Identity) (*KeyFile, error) {
+	buf, err := Decrypt(data, identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	var kf KeyFile
+	err = json.Unmarshal(buf, &kf)
+	if err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+	if kf.Version != keyFileVersion {
+		return nil, errors.Errorf("unsupported escrow file version %d", kf.Version)
+	}
+	if kf.MasterKey == nil || !kf.MasterKey.Valid() {
+		return nil, errors.New("escrow file contains an invalid master key")
+	}
+	return &kf, nil
+}
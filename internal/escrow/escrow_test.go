@@ -0,0 +1,84 @@
+package escrow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/crypto"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestIdentityEncoding(t *testing.T) {
+	id, err := GenerateIdentity()
+	rtest.OK(t, err)
+
+	rtest.Assert(t, strings.HasPrefix(id.String(), "AGE-SECRET-KEY-1"), "unexpected identity %v", id)
+	rtest.Assert(t, strings.HasPrefix(id.Recipient().String(), "age1"), "unexpected recipient %v", id.Recipient())
+
+	parsed, err := ParseIdentity(id.String())
+	rtest.OK(t, err)
+	rtest.Equals(t, id.Recipient().String(), parsed.Recipient().String())
+
+	r, err := ParseRecipient(id.Recipient().String())
+	rtest.OK(t, err)
+	rtest.Equals(t, id.Recipient().String(), r.String())
+
+	_, err = ParseRecipient(id.String())
+	rtest.Assert(t, err != nil, "identity accepted as recipient")
+
+	ids, err := ParseIdentities(strings.NewReader("# created: today\n# public key: " + id.Recipient().String() + "\n" + id.String() + "\n"))
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(ids))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	id1, err := GenerateIdentity()
+	rtest.OK(t, err)
+	id2, err := GenerateIdentity()
+	rtest.OK(t, err)
+	other, err := GenerateIdentity()
+	rtest.OK(t, err)
+
+	// the payload is encrypted in chunks of 64 KiB
+	const chunkSize = 64 * 1024
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize} {
+		plaintext := rtest.Random(size, size)
+
+		data, err := Encrypt(plaintext, id1.Recipient(), id2.Recipient())
+		rtest.OK(t, err)
+
+		for _, id := range []*Identity{id1, id2} {
+			buf, err := Decrypt(data, id)
+			rtest.OK(t, err)
+			rtest.Assert(t, bytes.Equal(plaintext, buf), "wrong plaintext for size %d", size)
+		}
+
+		_, err = Decrypt(data, other)
+		rtest.Equals(t, ErrNoMatchingIdentity, err)
+
+		// modifying the payload must be detected
+		if size > 0 {
+			modified := append([]byte{}, data...)
+			modified[len(modified)-1] ^= 1
+			_, err = Decrypt(modified, id1)
+			rtest.Assert(t, err != nil, "modified payload not detected for size %d", size)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	id, err := GenerateIdentity()
+	rtest.OK(t, err)
+
+	key := crypto.NewRandomKey()
+	data, err := Export("repo-id", key, []*Recipient{id.Recipient()})
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.HasPrefix(data, []byte("age-encryption.org/v1\n")), "missing age header")
+
+	kf, err := Import(data, []*Identity{id})
+	rtest.OK(t, err)
+	rtest.Equals(t, "repo-id", kf.Repository)
+	rtest.Equals(t, key.EncryptionKey, kf.MasterKey.EncryptionKey)
+	rtest.Equals(t, key.MACKey, kf.MasterKey.MACKey)
+}
@@ -6,16 +6,24 @@ var Flag = New()
 // flag names are written in kebab-case
 const (
 	BackendErrorRedesign    FlagName = "backend-error-redesign"
+	BirthTime               FlagName = "birth-time"
 	DeviceIDForHardlinks    FlagName = "device-id-for-hardlinks"
 	ExplicitS3AnonymousAuth FlagName = "explicit-s3-anonymous-auth"
+	IndexManifest           FlagName = "index-manifest"
+	MappedIndex             FlagName = "mapped-index"
 	SafeForgetKeepTags      FlagName = "safe-forget-keep-tags"
+	StatsHistory            FlagName = "stats-history"
 )
 
 func init() {
 	Flag.SetFlags(map[FlagName]FlagDesc{
 		BackendErrorRedesign:    {Type: Beta, Description: "enforce timeouts for stuck HTTP requests and use new backend error handling design."},
+		BirthTime:               {Type: Alpha, Description: "store the birth time of files during backup and restore it on operating systems which support this"},
 		DeviceIDForHardlinks:    {Type: Alpha, Description: "store deviceID only for hardlinks to reduce metadata changes for example when using btrfs subvolumes. Will be removed in a future restic version after repository format 3 is available"},
 		ExplicitS3AnonymousAuth: {Type: Beta, Description: "forbid anonymous S3 authentication unless `-o s3.unsafe-anonymous-auth=true` is set"},
+		IndexManifest:           {Type: Alpha, Description: "store a manifest of all index files after modifying the index to detect incomplete copies of a repository"},
+		MappedIndex:             {Type: Alpha, Description: "keep the repository index in a memory-mapped file in the local cache to reduce the memory usage for large repositories"},
 		SafeForgetKeepTags:      {Type: Beta, Description: "prevent deleting all snapshots if the tag passed to `forget --keep-tags tagname` does not exist"},
+		StatsHistory:            {Type: Alpha, Description: "record repository statistics after each backup and prune run, which can be shown using `stats --history`"},
 	})
 }
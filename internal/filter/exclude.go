@@ -115,7 +115,37 @@ func (opts *ExcludePatternOptions) Empty() bool {
 }
 
 func (opts ExcludePatternOptions) CollectPatterns(warnf func(msg string, args ...interface{})) ([]RejectByNameFunc, error) {
+	rules, err := opts.CollectPatternRules(warnf)
+	if err != nil {
+		return nil, err
+	}
+
 	var fs []RejectByNameFunc
+	for _, rule := range rules {
+		fs = append(fs, rule.Reject)
+	}
+	return fs, nil
+}
+
+// PatternRule rejects files which match the patterns of an exclude option.
+type PatternRule struct {
+	// Option is the name of the option without leading dashes, either
+	// "exclude" or "iexclude". Patterns read from files are included in the
+	// rule of the corresponding option.
+	Option string
+	Reject RejectByNameFunc
+	// Matching returns the pattern which rejects item, or an empty string if
+	// item is not rejected.
+	Matching func(item string) string
+	// Negating returns the negated pattern which includes item again although
+	// it is matched by another pattern, or an empty string if there is none.
+	Negating func(item string) string
+}
+
+// CollectPatternRules returns one rule for the case sensitive and one for the
+// case insensitive patterns, if there are any.
+func (opts ExcludePatternOptions) CollectPatternRules(warnf func(msg string, args ...interface{})) ([]PatternRule, error) {
+	var rules []PatternRule
 	// add patterns from file
 	if len(opts.ExcludeFiles) > 0 {
 		excludePatterns, err := readPatternsFromFiles(opts.ExcludeFiles)
@@ -148,7 +178,21 @@ func (opts ExcludePatternOptions) CollectPatterns(warnf func(msg string, args ..
 			return nil, errors.Fatalf("--iexclude: %s", err)
 		}
 
-		fs = append(fs, RejectByInsensitivePattern(opts.InsensitiveExcludes, warnf))
+		// RejectByInsensitivePattern converts the patterns to lower case
+		reject := RejectByInsensitivePattern(opts.InsensitiveExcludes, warnf)
+		parsed := ParsePatterns(opts.InsensitiveExcludes)
+		rules = append(rules, PatternRule{
+			Option: "iexclude",
+			Reject: reject,
+			Matching: func(item string) string {
+				pattern, _ := MatchingPattern(parsed, strings.ToLower(item))
+				return pattern
+			},
+			Negating: func(item string) string {
+				pattern, _ := NegatingPattern(parsed, strings.ToLower(item))
+				return pattern
+			},
+		})
 	}
 
 	if len(opts.Excludes) > 0 {
@@ -156,7 +200,19 @@ func (opts ExcludePatternOptions) CollectPatterns(warnf func(msg string, args ..
 			return nil, errors.Fatalf("--exclude: %s", err)
 		}
 
-		fs = append(fs, RejectByPattern(opts.Excludes, warnf))
+		parsed := ParsePatterns(opts.Excludes)
+		rules = append(rules, PatternRule{
+			Option: "exclude",
+			Reject: RejectByPattern(opts.Excludes, warnf),
+			Matching: func(item string) string {
+				pattern, _ := MatchingPattern(parsed, item)
+				return pattern
+			},
+			Negating: func(item string) string {
+				pattern, _ := NegatingPattern(parsed, item)
+				return pattern
+			},
+		})
 	}
-	return fs, nil
+	return rules, nil
 }
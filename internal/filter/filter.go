@@ -14,6 +14,63 @@ var ErrBadString = errors.New("filter.Match: string is empty")
 type patternPart struct {
 	pattern  string // First is "/" for absolute pattern; "" for "**".
 	isSimple bool
+
+	// kind and literal allow matching the frequent wildcard patterns "*",
+	// "*literal", "literal*" and "*literal*" without filepath.Match.
+	kind    partKind
+	literal string
+}
+
+type partKind uint8
+
+const (
+	partGlob partKind = iota
+	partAny
+	partSuffix
+	partPrefix
+	partContains
+)
+
+// starPart is the expansion of a single level of "**".
+var starPart = newPatternPart("*")
+
+func newPatternPart(part string) patternPart {
+	if !strings.ContainsAny(part, "\\[]*?") {
+		return patternPart{pattern: part, isSimple: true}
+	}
+
+	p := patternPart{pattern: part}
+	if strings.ContainsAny(part, "\\[]?") {
+		return p
+	}
+	switch stars := strings.Count(part, "*"); {
+	case part == "*":
+		p.kind = partAny
+	case stars == 1 && part[0] == '*':
+		p.kind, p.literal = partSuffix, part[1:]
+	case stars == 1 && part[len(part)-1] == '*':
+		p.kind, p.literal = partPrefix, part[:len(part)-1]
+	case stars == 2 && len(part) > 2 && part[0] == '*' && part[len(part)-1] == '*':
+		p.kind, p.literal = partContains, part[1:len(part)-1]
+	}
+	return p
+}
+
+// matchPart matches a single path component against the pattern part.
+func matchPart(part patternPart, str string) (bool, error) {
+	switch {
+	case part.isSimple:
+		return part.pattern == str, nil
+	case part.kind == partAny:
+		return true, nil
+	case part.kind == partSuffix:
+		return strings.HasSuffix(str, part.literal), nil
+	case part.kind == partPrefix:
+		return strings.HasPrefix(str, part.literal), nil
+	case part.kind == partContains:
+		return strings.Contains(str, part.literal), nil
+	}
+	return filepath.Match(part.pattern, str)
 }
 
 // Pattern represents a preparsed filter pattern
@@ -43,13 +100,13 @@ func preparePattern(patternStr string) Pattern {
 	pathParts := splitPath(filepath.Clean(patternStr))
 	parts := make([]patternPart, len(pathParts))
 	for i, part := range pathParts {
-		isSimple := !strings.ContainsAny(part, "\\[]*?")
 		// Replace "**" with the empty string to get faster comparisons
 		// (length-check only) in hasDoubleWildcard.
 		if part == "**" {
-			part = ""
+			parts[i] = patternPart{pattern: ""}
+			continue
 		}
-		parts[i] = patternPart{part, isSimple}
+		parts[i] = newPatternPart(part)
 	}
 
 	return Pattern{originalPattern, parts, negate}
@@ -158,7 +215,7 @@ func match(pattern Pattern, strs []string) (matched bool, err error) {
 			newPat := newPat[:pos+i]
 			// in the first iteration the wildcard expands to nothing
 			if i > 0 {
-				newPat[pos+i-1] = patternPart{"*", false}
+				newPat[pos+i-1] = starPart
 			}
 			newPat = append(newPat, pattern.parts[pos+1:]...)
 
@@ -198,14 +255,9 @@ func match(pattern Pattern, strs []string) (matched bool, err error) {
 		for offset := maxOffset; offset >= minOffset; offset-- {
 
 			for i := len(pattern.parts) - 1; i >= 0; i-- {
-				var ok bool
-				if pattern.parts[i].isSimple {
-					ok = pattern.parts[i].pattern == strs[offset+i]
-				} else {
-					ok, err = filepath.Match(pattern.parts[i].pattern, strs[offset+i])
-					if err != nil {
-						return false, errors.Wrap(err, "Match")
-					}
+				ok, err := matchPart(pattern.parts[i], strs[offset+i])
+				if err != nil {
+					return false, errors.Wrap(err, "Match")
 				}
 
 				if !ok {
@@ -272,6 +324,73 @@ func List(patterns []Pattern, str string) (matched bool, err error) {
 	return matched, err
 }
 
+// MatchingPattern returns the pattern which causes str to match the list of
+// patterns, following the same rules as List. If str does not match, an empty
+// string is returned.
+func MatchingPattern(patterns []Pattern, str string) (string, error) {
+	if len(patterns) == 0 {
+		return "", nil
+	}
+
+	strs, err := prepareStr(str)
+	if err != nil {
+		return "", err
+	}
+
+	matching := ""
+	for _, pat := range patterns {
+		m, err := match(pat, strs)
+		if err != nil {
+			return "", err
+		}
+		if !m {
+			continue
+		}
+
+		if pat.isNegated {
+			matching = ""
+		} else if matching == "" {
+			matching = pat.original
+		}
+	}
+	return matching, nil
+}
+
+// NegatingPattern returns the negated pattern which causes str to not match
+// the list of patterns, although a preceding pattern matches it. If str
+// matches or no pattern matches it at all, an empty string is returned.
+func NegatingPattern(patterns []Pattern, str string) (string, error) {
+	if len(patterns) == 0 {
+		return "", nil
+	}
+
+	strs, err := prepareStr(str)
+	if err != nil {
+		return "", err
+	}
+
+	matched := false
+	negating := ""
+	for _, pat := range patterns {
+		m, err := match(pat, strs)
+		if err != nil {
+			return "", err
+		}
+		if !m {
+			continue
+		}
+
+		if !pat.isNegated {
+			matched = true
+			negating = ""
+		} else if matched {
+			matched = false
+			negating = pat.original
+		}
+	}
+	return negating, nil
+}
+
 // ListWithChild returns true if str matches one of the patterns. Empty patterns are ignored.
 func ListWithChild(patterns []Pattern, str string) (matched bool, childMayMatch bool, err error) {
 	return list(patterns, true, str)
@@ -290,11 +409,21 @@ func list(patterns []Pattern, checkChildMatches bool, str string) (matched bool,
 		return false, false, err
 	}
 
-	hasNegatedPattern := false
+	return listPrepared(patterns, hasNegatedPattern(patterns), checkChildMatches, strs)
+}
+
+func hasNegatedPattern(patterns []Pattern) bool {
 	for _, pat := range patterns {
-		hasNegatedPattern = hasNegatedPattern || pat.isNegated
+		if pat.isNegated {
+			return true
+		}
 	}
+	return false
+}
 
+// listPrepared implements list for a path which was already split into its
+// components.
+func listPrepared(patterns []Pattern, hasNegatedPattern bool, checkChildMatches bool, strs []string) (matched bool, childMayMatch bool, err error) {
 	for _, pat := range patterns {
 		m, err := match(pat, strs)
 		if err != nil {
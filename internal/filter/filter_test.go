@@ -303,6 +303,68 @@ func TestList(t *testing.T) {
 			t.Errorf("test %d: filter.ListWithChild(%q, %q): expected %v, %v, got %v, %v",
 				i, test.patterns, test.path, test.match, test.childMatch, match, childMatch)
 		}
+
+		pattern, err := filter.MatchingPattern(patterns, test.path)
+		if err != nil {
+			t.Errorf("test %d failed: expected no error for patterns %q, but error returned: %v",
+				i, test.patterns, err)
+			continue
+		}
+
+		if (pattern != "") != test.match {
+			t.Errorf("test %d: filter.MatchingPattern(%q, %q): expected match %v, got pattern %q",
+				i, test.patterns, test.path, test.match, pattern)
+		}
+	}
+}
+
+func TestMatchingPattern(t *testing.T) {
+	var tests = []struct {
+		patterns []string
+		path     string
+		pattern  string
+	}{
+		{[]string{"*.c", "*.go"}, "/foo/bar.go", "*.go"},
+		{[]string{"/foo", "*.go"}, "/foo/bar.go", "/foo"},
+		{[]string{"*.go", "!/foo/bar.go", "/foo/*"}, "/foo/bar.go", "/foo/*"},
+		{[]string{"*.go", "!/foo/bar.go"}, "/foo/bar.go", ""},
+		{[]string{"*.go"}, "/foo/bar.c", ""},
+	}
+
+	for i, test := range tests {
+		pattern, err := filter.MatchingPattern(filter.ParsePatterns(test.patterns), test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pattern != test.pattern {
+			t.Errorf("test %d: filter.MatchingPattern(%q, %q): expected %q, got %q",
+				i, test.patterns, test.path, test.pattern, pattern)
+		}
+	}
+}
+
+func TestNegatingPattern(t *testing.T) {
+	var tests = []struct {
+		patterns []string
+		path     string
+		pattern  string
+	}{
+		{[]string{"*.go", "!/foo/bar.go"}, "/foo/bar.go", "!/foo/bar.go"},
+		{[]string{"*.go", "!/foo/*", "!/foo/bar.go"}, "/foo/bar.go", "!/foo/*"},
+		{[]string{"*.go", "!/foo/bar.go", "/foo/*"}, "/foo/bar.go", ""},
+		{[]string{"!/foo/bar.go", "*.c"}, "/foo/bar.go", ""},
+		{[]string{"*.go"}, "/foo/bar.go", ""},
+	}
+
+	for i, test := range tests {
+		pattern, err := filter.NegatingPattern(filter.ParsePatterns(test.patterns), test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pattern != test.pattern {
+			t.Errorf("test %d: filter.NegatingPattern(%q, %q): expected %q, got %q",
+				i, test.patterns, test.path, test.pattern, pattern)
+		}
 	}
 }
 
@@ -0,0 +1,277 @@
+package filter
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxAlternatives limits the number of patterns a single pattern may expand
+// to using braces.
+const maxAlternatives = 1024
+
+// escapeChars is true if a backslash escapes the next character in a pattern,
+// on Windows it is a path separator instead.
+var escapeChars = runtime.GOOS != "windows"
+
+// PatternError describes a syntax error in a pattern passed to Compile.
+type PatternError struct {
+	Pattern string
+	// Pos is the byte offset of the error in Pattern.
+	Pos int
+	Msg string
+}
+
+func (e *PatternError) Error() string {
+	return fmt.Sprintf("invalid pattern %q: %s at position %d", e.Pattern, e.Msg, e.Pos)
+}
+
+// Matcher matches paths against a list of patterns which were parsed and
+// validated once by Compile. It is safe for concurrent use.
+type Matcher struct {
+	patterns   []Pattern
+	hasNegated bool
+}
+
+// Compile parses the patterns for matching them against many paths. The
+// patterns follow the same rules as for List. In addition, a pattern may
+// contain alternatives in braces, for example "*.{jpg,png}" matches both
+// "*.jpg" and "*.png". Braces can be nested and are matched literally when
+// escaped using a backslash. Empty patterns are ignored.
+//
+// All patterns are validated, the first invalid pattern is reported as a
+// *PatternError.
+func Compile(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, pat := range patterns {
+		if pat == "" {
+			continue
+		}
+
+		if err := checkSyntax(pat); err != nil {
+			return nil, err
+		}
+		if err := checkBraces(pat); err != nil {
+			return nil, err
+		}
+		expanded, ok := expandBraces(pat, nil)
+		if !ok {
+			return nil, &PatternError{Pattern: pat, Pos: strings.IndexByte(pat, '{'), Msg: fmt.Sprintf("braces expand to more than %d patterns", maxAlternatives)}
+		}
+
+		for _, exp := range expanded {
+			if exp == "" || exp == "!" {
+				return nil, &PatternError{Pattern: pat, Pos: strings.IndexByte(pat, '{'), Msg: "braces expand to an empty pattern"}
+			}
+			p := preparePattern(exp)
+			// report the pattern as it was passed to Compile
+			p.original = pat
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	m.hasNegated = hasNegatedPattern(m.patterns)
+	return m, nil
+}
+
+// Match returns true if str matches the patterns, following the same rules
+// as List.
+func (m *Matcher) Match(str string) (bool, error) {
+	matched, _, err := m.match(false, str)
+	return matched, err
+}
+
+// MatchWithChild is like Match, but also returns whether children of str
+// may match the patterns, following the same rules as ListWithChild.
+func (m *Matcher) MatchWithChild(str string) (matched bool, childMayMatch bool, err error) {
+	return m.match(true, str)
+}
+
+// MatchingPattern returns the pattern as passed to Compile which causes str
+// to match, following the same rules as the function MatchingPattern.
+func (m *Matcher) MatchingPattern(str string) (string, error) {
+	return MatchingPattern(m.patterns, str)
+}
+
+func (m *Matcher) match(checkChildMatches bool, str string) (matched bool, childMayMatch bool, err error) {
+	if len(m.patterns) == 0 {
+		return false, false, nil
+	}
+
+	strs, err := prepareStr(str)
+	if err != nil {
+		return false, false, err
+	}
+	return listPrepared(m.patterns, m.hasNegated, checkChildMatches, strs)
+}
+
+// checkSyntax validates the wildcards and character classes of the pattern
+// in the same way as filepath.Match, but reports the position of the error.
+func checkSyntax(pattern string) error {
+	fail := func(pos int, msg string) error {
+		return &PatternError{Pattern: pattern, Pos: pos, Msg: msg}
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if escapeChars {
+				if i+1 == len(pattern) {
+					return fail(i, "trailing backslash")
+				}
+				i++
+			}
+		case '[':
+			start := i
+			i++
+			if i < len(pattern) && pattern[i] == '^' {
+				i++
+			}
+			for first := true; ; first = false {
+				if i >= len(pattern) {
+					return fail(start, "unterminated character class")
+				}
+				if pattern[i] == ']' && !first {
+					break
+				}
+
+				lo, n, ok := classChar(pattern[i:])
+				if !ok {
+					return fail(i, "invalid character in character class")
+				}
+				i += n
+				if i < len(pattern) && pattern[i] == '-' {
+					hi, n, ok := classChar(pattern[i+1:])
+					if !ok {
+						return fail(i+1, "invalid end of character range")
+					}
+					if hi < lo {
+						return fail(i-1, "character range is out of order")
+					}
+					i += 1 + n
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// classChar returns the first, possibly escaped, character of a character
+// class and its length in s.
+func classChar(s string) (r rune, n int, ok bool) {
+	if len(s) == 0 || s[0] == '-' || s[0] == ']' {
+		return 0, 0, false
+	}
+	if s[0] == '\\' && escapeChars {
+		if len(s) == 1 {
+			return 0, 0, false
+		}
+		r, size := utf8.DecodeRuneInString(s[1:])
+		return r, 1 + size, true
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return r, size, true
+}
+
+// checkBraces validates that all braces in the pattern are balanced.
+func checkBraces(pattern string) error {
+	var open []int
+	err := scanBraces(pattern, func(i int, c byte) bool {
+		switch c {
+		case '{':
+			open = append(open, i)
+		case '}':
+			if len(open) == 0 {
+				return false
+			}
+			open = open[:len(open)-1]
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if len(open) > 0 {
+		return &PatternError{Pattern: pattern, Pos: open[0], Msg: "unclosed brace"}
+	}
+	return nil
+}
+
+// scanBraces calls fn for each brace and comma in pattern which is neither
+// escaped nor part of a character class. If fn returns false, an error for an
+// unmatched closing brace at this position is returned.
+func scanBraces(pattern string, fn func(i int, c byte) bool) error {
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '\\':
+			if escapeChars {
+				i++
+			}
+		case '[':
+			// skip the character class, checkSyntax ensures that it is terminated
+			i++
+			if i < len(pattern) && pattern[i] == '^' {
+				i++
+			}
+			for first := true; i < len(pattern) && (first || pattern[i] != ']'); first = false {
+				if pattern[i] == '\\' && escapeChars {
+					i++
+				}
+				i++
+			}
+		case '{', '}', ',':
+			if !fn(i, c) {
+				return &PatternError{Pattern: pattern, Pos: i, Msg: "unmatched closing brace"}
+			}
+		}
+	}
+	return nil
+}
+
+// expandBraces appends all alternatives described by the braces in pattern
+// to result. The braces must be balanced. It returns false if result would
+// grow beyond maxAlternatives.
+func expandBraces(pattern string, result []string) ([]string, bool) {
+	open, end, depth := -1, -1, 0
+	var commas []int
+	_ = scanBraces(pattern, func(i int, c byte) bool {
+		if end >= 0 {
+			return true
+		}
+		switch c {
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		}
+		return true
+	})
+	if open < 0 {
+		if len(result) >= maxAlternatives {
+			return result, false
+		}
+		return append(result, pattern), true
+	}
+
+	start := open + 1
+	for _, sep := range append(commas, end) {
+		// the suffix can contain further braces
+		var ok bool
+		result, ok = expandBraces(pattern[:open]+pattern[start:sep]+pattern[end+1:], result)
+		if !ok {
+			return result, false
+		}
+		start = sep + 1
+	}
+	return result, true
+}
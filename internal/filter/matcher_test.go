@@ -0,0 +1,195 @@
+package filter_test
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/restic/restic/internal/filter"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestCompileBraces(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.{jpg,png}", "/home/user/photo.jpg", true},
+		{"*.{jpg,png}", "/home/user/photo.png", true},
+		{"*.{jpg,png}", "/home/user/photo.gif", false},
+		{"*.{jpg,png}", "/home/user/photo.{jpg,png}", false},
+		{"/home/{alice,bob}/.cache", "/home/bob/.cache", true},
+		{"/home/{alice,bob}/.cache", "/home/carol/.cache", false},
+		{"/{usr,opt}/{lib,share/{doc,man}}", "/usr/share/man", true},
+		{"/{usr,opt}/{lib,share/{doc,man}}", "/opt/lib", true},
+		{"/{usr,opt}/{lib,share/{doc,man}}", "/opt/share", false},
+		{"file{,.bak}", "/dir/file", true},
+		{"file{,.bak}", "/dir/file.bak", true},
+		{"file{,.bak}", "/dir/file.old", false},
+		{"{a,b}/**/c", "/x/b/y/z/c", true},
+		{"[{]a,b[}]", "/x/{a,b}", true},
+		{"[{]a,b[}]", "/x/a", false},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, []struct {
+			pattern string
+			path    string
+			match   bool
+		}{
+			{`\{a,b\}`, "/x/{a,b}", true},
+			{`\{a,b\}`, "/x/a", false},
+			{`{a\,b,c}`, "/x/a,b", true},
+			{`{a\,b,c}`, "/x/c", true},
+		}...)
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v/%v", test.pattern, test.path), func(t *testing.T) {
+			m, err := filter.Compile([]string{test.pattern})
+			rtest.OK(t, err)
+			match, err := m.Match(test.path)
+			rtest.OK(t, err)
+			rtest.Equals(t, test.match, match)
+
+			if match {
+				// the matching pattern is reported as passed to Compile
+				pattern, err := m.MatchingPattern(test.path)
+				rtest.OK(t, err)
+				rtest.Equals(t, test.pattern, pattern)
+			}
+		})
+	}
+}
+
+func TestCompileNegatedBraces(t *testing.T) {
+	m, err := filter.Compile([]string{"*.{jpg,png,gif}", "!keep.{jpg,png}"})
+	rtest.OK(t, err)
+	for path, want := range map[string]bool{
+		"/a/photo.jpg": true,
+		"/a/keep.jpg":  false,
+		"/a/keep.png":  false,
+		"/a/keep.gif":  true,
+	} {
+		match, err := m.Match(path)
+		rtest.OK(t, err)
+		rtest.Equals(t, want, match, fmt.Sprintf("path %v", path))
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		pos     int
+		msg     string
+	}{
+		{"/foo/[a", 5, "unterminated character class"},
+		{"*.[]", 3, "invalid character in character class"},
+		{"x[z-a]", 2, "character range is out of order"},
+		{"x[a-]", 4, "invalid end of character range"},
+		{"*.{jpg,png", 2, "unclosed brace"},
+		{"a{b{c}", 1, "unclosed brace"},
+		{"a,b}", 3, "unmatched closing brace"},
+		{"{a,b}}", 5, "unmatched closing brace"},
+		{"{,}", 0, "braces expand to an empty pattern"},
+		{"{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}", 0, "braces expand to more than 1024 patterns"},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, struct {
+			pattern string
+			pos     int
+			msg     string
+		}{`foo\`, 3, "trailing backslash"})
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			_, err := filter.Compile([]string{"*.go", test.pattern})
+			var perr *filter.PatternError
+			rtest.Assert(t, errors.As(err, &perr), "expected PatternError, got %v", err)
+			rtest.Equals(t, test.pattern, perr.Pattern)
+			rtest.Equals(t, test.pos, perr.Pos)
+			rtest.Equals(t, test.msg, perr.Msg)
+		})
+	}
+}
+
+func containsBrace(s string) bool {
+	for _, c := range s {
+		if c == '{' || c == '}' {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCompileConsistency checks that patterns without braces, especially
+// those using "**", match exactly like with Match, ChildMatch and List.
+func TestCompileConsistency(t *testing.T) {
+	for _, test := range matchTests {
+		if test.pattern == "" || containsBrace(test.pattern) {
+			continue
+		}
+		m, err := filter.Compile([]string{test.pattern})
+		rtest.OK(t, err)
+		match, err := m.Match(test.path)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.match, match, fmt.Sprintf("pattern %q, path %q", test.pattern, test.path))
+	}
+
+	for _, test := range childMatchTests {
+		if test.pattern == "" || containsBrace(test.pattern) {
+			continue
+		}
+		m, err := filter.Compile([]string{test.pattern})
+		rtest.OK(t, err)
+		_, childMatch, err := m.MatchWithChild(test.path)
+		rtest.OK(t, err)
+		want, err := filter.ChildMatch(test.pattern, test.path)
+		rtest.OK(t, err)
+		rtest.Equals(t, want, childMatch, fmt.Sprintf("pattern %q, path %q", test.pattern, test.path))
+	}
+
+	for _, test := range filterListTests {
+		m, err := filter.Compile(test.patterns)
+		rtest.OK(t, err)
+		match, childMatch, err := m.MatchWithChild(test.path)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.match, match, fmt.Sprintf("patterns %v, path %q", test.patterns, test.path))
+		rtest.Equals(t, test.childMatch, childMatch, fmt.Sprintf("patterns %v, path %q", test.patterns, test.path))
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	lines := extractTestLines(b)
+	tests := []struct {
+		name     string
+		patterns []string
+	}{
+		{"Extensions", []string{"*.{jpg,png,gif,tmp,bak,o,a}"}},
+		{"Prefix", []string{"tmp*", "cache*", "build*"}},
+		{"Wildcard", []string{"/usr/**/{doc,man}/*.{html,txt}", "/home/**/test"}},
+		{"Negated", []string{"*.html", "!*vars.html"}},
+	}
+
+	for _, test := range tests {
+		m, err := filter.Compile(test.patterns)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for _, line := range lines {
+					if _, err := m.Match(line); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
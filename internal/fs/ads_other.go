@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+// appendAlternateDataStreams returns names unchanged, alternate data streams
+// only exist on Windows.
+func appendAlternateDataStreams(_ string, names []string) []string {
+	return names
+}
+
+// SupportsAlternateDataStreams returns false, alternate data streams only
+// exist on Windows.
+func SupportsAlternateDataStreams(_ string) bool {
+	return false
+}
+
+// SupportsSecurityDescriptors returns false, security descriptors only exist
+// on Windows.
+func SupportsSecurityDescriptors(_ string) bool {
+	return false
+}
@@ -0,0 +1,116 @@
+package fs
+
+import (
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modkernel32          = windows.NewLazySystemDLL("kernel32.dll")
+	procFindFirstStreamW = modkernel32.NewProc("FindFirstStreamW")
+	procFindNextStreamW  = modkernel32.NewProc("FindNextStreamW")
+)
+
+// findStreamInfoStandard is the FindStreamInfoStandard info level of FindFirstStreamW.
+const findStreamInfoStandard = 0
+
+// win32FindStreamData is the WIN32_FIND_STREAM_DATA structure.
+type win32FindStreamData struct {
+	StreamSize int64
+	StreamName [windows.MAX_PATH + 36]uint16
+}
+
+// alternateDataStreams returns the names of the alternate data streams of
+// the file or directory at path. The unnamed default data stream is not
+// included.
+func alternateDataStreams(path string) ([]string, error) {
+	pathp, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var data win32FindStreamData
+	r, _, err := procFindFirstStreamW.Call(uintptr(unsafe.Pointer(pathp)), findStreamInfoStandard, uintptr(unsafe.Pointer(&data)), 0)
+	handle := windows.Handle(r)
+	if handle == windows.InvalidHandle {
+		if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+			// no streams at all, for example for most directories
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = windows.FindClose(handle)
+	}()
+
+	var streams []string
+	for {
+		// stream names have the format ":name:$DATA", the default stream is "::$DATA"
+		name := strings.TrimSuffix(strings.TrimPrefix(windows.UTF16ToString(data.StreamName[:]), ":"), ":$DATA")
+		if name != "" {
+			streams = append(streams, name)
+		}
+
+		r, _, err = procFindNextStreamW.Call(uintptr(handle), uintptr(unsafe.Pointer(&data)))
+		if r == 0 {
+			if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+				return streams, nil
+			}
+			return streams, err
+		}
+	}
+}
+
+// appendAlternateDataStreams adds an entry "name:stream" for each alternate
+// data stream of the entries of the directory dir. Entries whose streams
+// cannot be listed are kept without their streams.
+func appendAlternateDataStreams(dir string, names []string) []string {
+	result := names
+	for _, name := range names {
+		streams, err := alternateDataStreams(filepath.Join(dir, name))
+		if err != nil {
+			debug.Log("listing alternate data streams of %v failed: %v", filepath.Join(dir, name), err)
+			continue
+		}
+		for _, stream := range streams {
+			result = append(result, name+":"+stream)
+		}
+	}
+	return result
+}
+
+// SupportsAlternateDataStreams returns whether the volume containing path can
+// store alternate data streams.
+func SupportsAlternateDataStreams(path string) bool {
+	return volumeHasFlag(path, windows.FILE_NAMED_STREAMS)
+}
+
+// SupportsSecurityDescriptors returns whether the volume containing path can
+// store security descriptors.
+func SupportsSecurityDescriptors(path string) bool {
+	return volumeHasFlag(path, windows.FILE_PERSISTENT_ACLS)
+}
+
+func volumeHasFlag(path string, flag uint32) bool {
+	volumeName, err := getVolumePathName(path)
+	if err != nil {
+		debug.Log("getting volume name of %v failed: %v", path, err)
+		return false
+	}
+	utf16Path, err := windows.UTF16PtrFromString(volumeName + `\`)
+	if err != nil {
+		return false
+	}
+	var fileSystemFlags uint32
+	err = windows.GetVolumeInformation(utf16Path, nil, 0, nil, nil, &fileSystemFlags, nil, 0)
+	if err != nil {
+		debug.Log("getting volume information of %v failed: %v", volumeName, err)
+		return false
+	}
+	return fileSystemFlags&flag != 0
+}
@@ -0,0 +1,46 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestLocalAlternateDataStreams(t *testing.T) {
+	tempDir := t.TempDir()
+	if !SupportsAlternateDataStreams(tempDir) {
+		t.Skip("the file system does not support alternate data streams")
+	}
+	rtest.OK(t, os.WriteFile(filepath.Join(tempDir, "file"), []byte("content"), 0o600))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempDir, "file:stream"), []byte("stream"), 0o600))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempDir, "other"), []byte("other"), 0o600))
+
+	streams, err := alternateDataStreams(filepath.Join(tempDir, "file"))
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"stream"}, streams)
+
+	for _, listStreams := range []bool{false, true} {
+		names, err := Readdirnames(Local{AlternateDataStreams: listStreams}, tempDir, O_NOFOLLOW)
+		rtest.OK(t, err)
+		sort.Strings(names)
+		if listStreams {
+			rtest.Equals(t, []string{"file", "file:stream", "other"}, names)
+		} else {
+			rtest.Equals(t, []string{"file", "other"}, names)
+		}
+	}
+
+	// the stream is marked as such
+	f, err := Local{}.OpenFile(filepath.Join(tempDir, "file:stream"), O_NOFOLLOW, true)
+	rtest.OK(t, err)
+	node, err := f.ToNode(false)
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+	rtest.Assert(t, node.IsAlternateDataStream(), "stream is not marked as alternate data stream")
+}
@@ -0,0 +1,25 @@
+package fs
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/restic/restic/internal/restic"
+	"golang.org/x/sys/unix"
+)
+
+// birthTime returns the birth time reported by stat.
+func birthTime(_ string, fi *ExtendedFileInfo) (time.Time, bool) {
+	return fi.BirthTime, fi.BirthTime.Unix() > 0
+}
+
+// restoreBirthTime sets the creation time of path using setattrlist.
+func restoreBirthTime(path string, btime time.Time, _ restic.NodeType) error {
+	attrs := unix.Attrlist{
+		Bitmapcount: unix.ATTR_BIT_MAP_COUNT,
+		Commonattr:  unix.ATTR_CMN_CRTIME,
+	}
+	ts := unix.NsecToTimespec(btime.UnixNano())
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&ts)), unsafe.Sizeof(ts))
+	return unix.Setattrlist(path, &attrs, buf, unix.FSOPT_NOFOLLOW)
+}
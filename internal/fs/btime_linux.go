@@ -0,0 +1,25 @@
+package fs
+
+import (
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	"golang.org/x/sys/unix"
+)
+
+// birthTime returns the birth time of the file at path. os.Stat does not
+// report it on Linux, thus query it using statx. The second return value is
+// false if the filesystem does not record a birth time.
+func birthTime(path string, _ *ExtendedFileInfo) (time.Time, bool) {
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &stx)
+	if err != nil || stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), true
+}
+
+// restoreBirthTime is a no-op, Linux does not allow setting the birth time.
+func restoreBirthTime(_ string, _ time.Time, _ restic.NodeType) error {
+	return nil
+}
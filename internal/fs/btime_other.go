@@ -0,0 +1,22 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package fs
+
+import (
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// birthTime returns the birth time reported by stat. Filesystems which do
+// not record a birth time report a non-positive timestamp.
+func birthTime(_ string, fi *ExtendedFileInfo) (time.Time, bool) {
+	return fi.BirthTime, fi.BirthTime.Unix() > 0
+}
+
+// restoreBirthTime is a no-op, there is no portable way to set the birth
+// time on this operating system.
+func restoreBirthTime(_ string, _ time.Time, _ restic.NodeType) error {
+	return nil
+}
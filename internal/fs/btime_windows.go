@@ -0,0 +1,19 @@
+package fs
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// birthTime returns the creation time reported by stat.
+func birthTime(_ string, fi *ExtendedFileInfo) (time.Time, bool) {
+	return fi.BirthTime, !fi.BirthTime.IsZero()
+}
+
+// restoreBirthTime sets the creation time of path.
+func restoreBirthTime(path string, btime time.Time, _ restic.NodeType) error {
+	ft := syscall.NsecToFiletime(btime.UnixNano())
+	return restoreCreationTime(path, &ft)
+}
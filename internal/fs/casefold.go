@@ -0,0 +1,12 @@
+package fs
+
+import "strings"
+
+// FoldCase returns a representation of name which is the same for all names
+// that only differ in case. Such names refer to the same file on
+// case-insensitive file systems, like the default file systems on Windows and
+// macOS.
+func FoldCase(name string) string {
+	// NTFS also compares the uppercase versions of filenames
+	return strings.ToUpper(name)
+}
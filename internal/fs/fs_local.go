@@ -3,12 +3,31 @@ package fs
 import (
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/restic"
 )
 
 // Local is the local file system. Most methods are just passed on to the stdlib.
-type Local struct{}
+//
+// On Linux, files are read with the O_NOATIME flag if possible, which prevents
+// the kernel from updating their access time.
+type Local struct {
+	// NoAtime also preserves the access time of files and directories for
+	// which O_NOATIME cannot be used, by restoring it after they are closed.
+	// This requires permission to change the timestamps of the file and
+	// updates its change time.
+	NoAtime bool
+	// AlternateDataStreams lists the alternate data streams of the entries of
+	// a directory in addition to the entries themselves. The streams are named
+	// "file:stream" and can be opened like regular files. This is only
+	// supported on Windows.
+	AlternateDataStreams bool
+	// NoSecurityDescriptors skips reading the security descriptors of files
+	// and directories on Windows.
+	NoSecurityDescriptors bool
+}
 
 // statically ensure that Local implements FS.
 var _ FS = &Local{}
@@ -29,7 +48,7 @@ func (fs Local) VolumeName(path string) string {
 //
 // Only the O_NOFOLLOW and O_DIRECTORY flags are supported.
 func (fs Local) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
-	return newLocalFile(name, flag, metadataOnly)
+	return newLocalFile(name, flag, metadataOnly, fs)
 }
 
 // Lstat returns the FileInfo structure describing the named file.
@@ -88,27 +107,39 @@ func (fs Local) Dir(path string) string {
 type localFile struct {
 	name string
 	flag int
+	opts Local
 	f    *os.File
 	fi   *ExtendedFileInfo
+	// access time to restore in Close, zero if not needed
+	atime time.Time
 }
 
 // See the File interface for a description of each method
 var _ File = &localFile{}
 
-func newLocalFile(name string, flag int, metadataOnly bool) (*localFile, error) {
+func newLocalFile(name string, flag int, metadataOnly bool, opts Local) (*localFile, error) {
 	var f *os.File
+	var atime time.Time
 	if !metadataOnly {
 		var err error
 		f, err = os.OpenFile(fixpath(name), flag, 0)
 		if err != nil {
 			return nil, err
 		}
-		_ = setFlags(f)
+		err = setFlags(f)
+		if opts.NoAtime && (err != nil || !hasNoatimeFlag) {
+			// fall back to restoring the access time in Close
+			if fi, err := f.Stat(); err == nil {
+				atime = ExtendedStat(fi).AccessTime
+			}
+		}
 	}
 	return &localFile{
-		name: name,
-		flag: flag,
-		f:    f,
+		name:  name,
+		flag:  flag,
+		opts:  opts,
+		f:     f,
+		atime: atime,
 	}, nil
 }
 
@@ -117,7 +148,7 @@ func (f *localFile) MakeReadable() error {
 		panic("file is already readable")
 	}
 
-	newF, err := newLocalFile(f.name, f.flag, false)
+	newF, err := newLocalFile(f.name, f.flag, false, f.opts)
 	if err != nil {
 		return err
 	}
@@ -156,7 +187,7 @@ func (f *localFile) ToNode(ignoreXattrListError bool) (*restic.Node, error) {
 	if err := f.cacheFI(); err != nil {
 		return nil, err
 	}
-	return nodeFromFileInfo(f.name, f.fi, ignoreXattrListError)
+	return nodeFromFileInfo(f.name, f.fi, ignoreXattrListError, f.opts.NoSecurityDescriptors)
 }
 
 func (f *localFile) Read(p []byte) (n int, err error) {
@@ -164,12 +195,34 @@ func (f *localFile) Read(p []byte) (n int, err error) {
 }
 
 func (f *localFile) Readdirnames(n int) ([]string, error) {
-	return f.f.Readdirnames(n)
+	names, err := f.f.Readdirnames(n)
+	if err != nil || !f.opts.AlternateDataStreams {
+		return names, err
+	}
+	return appendAlternateDataStreams(f.name, names), nil
 }
 
 func (f *localFile) Close() error {
-	if f.f != nil {
+	if f.f == nil {
+		return nil
+	}
+	if f.atime.IsZero() {
 		return f.f.Close()
 	}
-	return nil
+
+	// only restore the access time if it was changed, as this also updates
+	// the change time of the file
+	var mtime time.Time
+	if fi, err := f.f.Stat(); err == nil {
+		if efi := ExtendedStat(fi); !efi.AccessTime.Equal(f.atime) {
+			mtime = efi.ModTime
+		}
+	}
+	err := f.f.Close()
+	if !mtime.IsZero() {
+		if err := os.Chtimes(fixpath(f.name), f.atime, mtime); err != nil {
+			debug.Log("cannot restore access time of %v: %v", f.name, err)
+		}
+	}
+	return err
 }
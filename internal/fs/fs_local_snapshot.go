@@ -0,0 +1,474 @@
+package fs
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// FSSnapshotConfig holds extended options for filesystem snapshots on Linux.
+type FSSnapshotConfig struct {
+	Provider string `option:"provider" help:"snapshot provider, one of btrfs, zfs or lvm (default: detect from the filesystem type)"`
+	MountDir string `option:"mount-dir" help:"directory in which btrfs and LVM snapshots are mounted (default: temporary directory)"`
+	LVMSize  string `option:"lvm-size" help:"size of snapshots of thick LVM volumes, as accepted by lvcreate --size or --extents (default: 10%ORIGIN)"`
+}
+
+// defaultLVMSize is the size of snapshots of thick logical volumes.
+const defaultLVMSize = "10%ORIGIN"
+
+func init() {
+	if runtime.GOOS == "linux" {
+		options.Register("fssnapshot", FSSnapshotConfig{})
+	}
+}
+
+// ParseFSSnapshotConfig parses the fssnapshot extended options.
+func ParseFSSnapshotConfig(o options.Options) (FSSnapshotConfig, error) {
+	var cfg FSSnapshotConfig
+	o = o.Extract("fssnapshot")
+	if err := o.Apply("fssnapshot", &cfg); err != nil {
+		return FSSnapshotConfig{}, err
+	}
+
+	switch cfg.Provider {
+	case "", "btrfs", "zfs", "lvm":
+	default:
+		return FSSnapshotConfig{}, errors.Fatalf("invalid fssnapshot.provider %q, must be one of btrfs, zfs or lvm", cfg.Provider)
+	}
+	return cfg, nil
+}
+
+// mountInfo describes a mounted filesystem.
+type mountInfo struct {
+	// Root is the directory of the filesystem which is mounted.
+	Root       string
+	MountPoint string
+	FSType     string
+	Source     string
+}
+
+// unescapeMountInfo replaces the octal escape sequences used for spaces and
+// other special characters in /proc/self/mountinfo.
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// parseMountInfo parses the format of /proc/self/mountinfo, see proc(5).
+func parseMountInfo(rd io.Reader) ([]mountInfo, error) {
+	var mounts []mountInfo
+	sc := bufio.NewScanner(rd)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		// the optional fields are terminated by a single hyphen
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || len(fields) < sep+3 {
+			return nil, errors.Errorf("invalid mountinfo line %q", sc.Text())
+		}
+
+		mounts = append(mounts, mountInfo{
+			Root:       unescapeMountInfo(fields[3]),
+			MountPoint: unescapeMountInfo(fields[4]),
+			FSType:     fields[sep+1],
+			Source:     unescapeMountInfo(fields[sep+2]),
+		})
+	}
+	return mounts, sc.Err()
+}
+
+// findMount returns the mount which contains path. Later mounts hide earlier
+// mounts on the same mount point.
+func findMount(mounts []mountInfo, path string) (mountInfo, bool) {
+	var found mountInfo
+	ok := false
+	for _, mnt := range mounts {
+		if !HasPathPrefix(mnt.MountPoint, path) {
+			continue
+		}
+		if !ok || len(mnt.MountPoint) >= len(found.MountPoint) {
+			found = mnt
+			ok = true
+		}
+	}
+	return found, ok
+}
+
+// fsSnapshot is a snapshot of a mounted filesystem.
+type fsSnapshot struct {
+	// root contains the content of the mount point within the snapshot.
+	root    string
+	cleanup []func() error
+}
+
+// Delete removes the snapshot.
+func (s *fsSnapshot) Delete() error {
+	var firstErr error
+	for i := len(s.cleanup) - 1; i >= 0; i-- {
+		if err := s.cleanup[i](); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.cleanup = nil
+	return firstErr
+}
+
+// commandRunner runs an external command and returns its output.
+type commandRunner func(name string, args ...string) ([]byte, error)
+
+func runCommand(name string, args ...string) ([]byte, error) {
+	debug.Log("running %v %v", name, args)
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return nil, errors.Errorf("%v %v failed: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// fsSnapshotter creates snapshots using the tools of the filesystems.
+type fsSnapshotter struct {
+	cfg FSSnapshotConfig
+	run commandRunner
+}
+
+// providerFor returns the name of the provider used for a mount, or an empty
+// string if the filesystem cannot be snapshotted.
+func (s *fsSnapshotter) providerFor(mnt mountInfo) string {
+	switch {
+	case mnt.FSType == "btrfs" && (s.cfg.Provider == "" || s.cfg.Provider == "btrfs"):
+		return "btrfs"
+	case mnt.FSType == "zfs" && (s.cfg.Provider == "" || s.cfg.Provider == "zfs"):
+		return "zfs"
+	case s.cfg.Provider == "lvm" && strings.HasPrefix(mnt.Source, "/dev/"):
+		return "lvm"
+	case s.cfg.Provider == "" && strings.HasPrefix(mnt.Source, "/dev/mapper/"):
+		// device mapper is also used for LUKS, multipath and other devices
+		if _, err := s.findLogicalVolume(mnt.Source); err != nil {
+			debug.Log("%v is not a logical volume: %v", mnt.Source, err)
+			return ""
+		}
+		return "lvm"
+	}
+	return ""
+}
+
+// logicalVolume describes a logical volume.
+type logicalVolume struct {
+	vg, lv string
+	thin   bool
+}
+
+// findLogicalVolume returns the logical volume of the device, an error is
+// returned if the device is not a logical volume.
+func (s *fsSnapshotter) findLogicalVolume(device string) (logicalVolume, error) {
+	out, err := s.run("lvs", "--noheadings", "-o", "vg_name,lv_name,segtype", device)
+	if err != nil {
+		return logicalVolume{}, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 3 {
+		return logicalVolume{}, errors.Errorf("unable to determine logical volume of %v: unexpected output %q", device, out)
+	}
+	return logicalVolume{vg: fields[0], lv: fields[1], thin: fields[2] == "thin"}, nil
+}
+
+// create creates a snapshot of the mount using the given provider.
+func (s *fsSnapshotter) create(provider string, mnt mountInfo, name string) (*fsSnapshot, error) {
+	snapshot := &fsSnapshot{}
+	var err error
+	switch provider {
+	case "btrfs":
+		err = s.createBtrfs(snapshot, mnt, name)
+	case "zfs":
+		err = s.createZFS(snapshot, mnt, name)
+	case "lvm":
+		err = s.createLVM(snapshot, mnt, name)
+	default:
+		err = errors.Errorf("unknown snapshot provider %q", provider)
+	}
+
+	if err != nil {
+		// remove the parts of the snapshot which were already created
+		if derr := snapshot.Delete(); derr != nil {
+			debug.Log("cleanup of failed snapshot returned %v", derr)
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// mountTemp mounts device in a new temporary directory and returns its path.
+func (s *fsSnapshotter) mountTemp(snapshot *fsSnapshot, name string, device string, fsType string, options string) (string, error) {
+	dir, err := os.MkdirTemp(s.cfg.MountDir, "restic-"+name+"-")
+	if err != nil {
+		return "", err
+	}
+	snapshot.cleanup = append(snapshot.cleanup, func() error {
+		return os.Remove(dir)
+	})
+
+	if _, err := s.run("mount", "-t", fsType, "-o", options, device, dir); err != nil {
+		return "", err
+	}
+	snapshot.cleanup = append(snapshot.cleanup, func() error {
+		_, err := s.run("umount", dir)
+		return err
+	})
+	return dir, nil
+}
+
+func (s *fsSnapshotter) createBtrfs(snapshot *fsSnapshot, mnt mountInfo, name string) error {
+	// A snapshot must be stored on the same filesystem. It is created in the
+	// top-level subvolume mounted in a temporary directory, such that it does
+	// not appear within the mounted subvolume, which is being backed up.
+	top, err := s.mountTemp(snapshot, name, mnt.Source, "btrfs", "subvolid=5")
+	if err != nil {
+		return err
+	}
+
+	root := filepath.Join(top, "."+name)
+	if _, err := s.run("btrfs", "subvolume", "snapshot", "-r", filepath.Join(top, mnt.Root), root); err != nil {
+		return err
+	}
+	snapshot.cleanup = append(snapshot.cleanup, func() error {
+		_, err := s.run("btrfs", "subvolume", "delete", root)
+		return err
+	})
+	snapshot.root = root
+	return nil
+}
+
+func (s *fsSnapshotter) createZFS(snapshot *fsSnapshot, mnt mountInfo, name string) error {
+	dataset := mnt.Source + "@" + name
+	if _, err := s.run("zfs", "snapshot", dataset); err != nil {
+		return err
+	}
+	snapshot.cleanup = append(snapshot.cleanup, func() error {
+		_, err := s.run("zfs", "destroy", dataset)
+		return err
+	})
+	snapshot.root = filepath.Join(mnt.MountPoint, ".zfs", "snapshot", name, mnt.Root)
+	return nil
+}
+
+func (s *fsSnapshotter) createLVM(snapshot *fsSnapshot, mnt mountInfo, name string) error {
+	vol, err := s.findLogicalVolume(mnt.Source)
+	if err != nil {
+		return err
+	}
+	snapshotLV := vol.lv + "-" + name
+
+	// thin snapshots are skipped on activation by default, which is disabled
+	// here
+	args := []string{"--snapshot", "--setactivationskip", "n", "--name", snapshotLV}
+	if !vol.thin {
+		// snapshots of thick volumes need space for the changed blocks
+		size := s.cfg.LVMSize
+		if size == "" {
+			size = defaultLVMSize
+		}
+		if strings.Contains(size, "%") {
+			args = append(args, "--extents", size)
+		} else {
+			args = append(args, "--size", size)
+		}
+	}
+	args = append(args, vol.vg+"/"+vol.lv)
+	if _, err := s.run("lvcreate", args...); err != nil {
+		return err
+	}
+	snapshot.cleanup = append(snapshot.cleanup, func() error {
+		_, err := s.run("lvremove", "--force", vol.vg+"/"+snapshotLV)
+		return err
+	})
+
+	mountOptions := "ro"
+	if mnt.FSType == "xfs" {
+		// the snapshot has the same filesystem UUID as the origin
+		mountOptions += ",nouuid"
+	}
+	dir, err := s.mountTemp(snapshot, name, filepath.Join("/dev", vol.vg, snapshotLV), mnt.FSType, mountOptions)
+	if err != nil {
+		return err
+	}
+
+	snapshot.root = filepath.Join(dir, mnt.Root)
+	return nil
+}
+
+// LocalFSSnapshot is a wrapper around the local file system which reads all
+// files from snapshots of the mounted filesystems on Linux. Snapshots are
+// created using btrfs, zfs or LVM the first time a file of a filesystem is
+// accessed. Files on filesystems which cannot be snapshotted are read
+// directly.
+type LocalFSSnapshot struct {
+	FS
+	mounts      []mountInfo
+	snapshotter fsSnapshotter
+	name        string
+
+	mutex      sync.RWMutex
+	snapshots  map[string]*fsSnapshot
+	unsnapshot map[string]struct{}
+
+	msgError   ErrorHandler
+	msgMessage MessageHandler
+}
+
+// statically ensure that LocalFSSnapshot implements FS.
+var _ FS = &LocalFSSnapshot{}
+
+// NewLocalFSSnapshot creates a new wrapper around the local filesystem which
+// reads files from filesystem snapshots.
+func NewLocalFSSnapshot(msgError ErrorHandler, msgMessage MessageHandler, cfg FSSnapshotConfig) (*LocalFSSnapshot, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list mounted filesystems")
+	}
+	mounts, err := parseMountInfo(f)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return newLocalFSSnapshot(mounts, fsSnapshotter{cfg: cfg, run: runCommand}, msgError, msgMessage)
+}
+
+func newLocalFSSnapshot(mounts []mountInfo, snapshotter fsSnapshotter, msgError ErrorHandler, msgMessage MessageHandler) (*LocalFSSnapshot, error) {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return nil, err
+	}
+
+	return &LocalFSSnapshot{
+		FS:          Local{},
+		mounts:      mounts,
+		snapshotter: snapshotter,
+		name:        "restic-" + hex.EncodeToString(buf),
+		snapshots:   make(map[string]*fsSnapshot),
+		unsnapshot:  make(map[string]struct{}),
+		msgError:    msgError,
+		msgMessage:  msgMessage,
+	}, nil
+}
+
+// DeleteSnapshots deletes all snapshots that were created.
+func (fs *LocalFSSnapshot) DeleteSnapshots() {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	for mountPoint, snapshot := range fs.snapshots {
+		if err := snapshot.Delete(); err != nil {
+			fs.msgError(mountPoint, errors.Errorf("failed to delete snapshot: %s", err))
+			continue
+		}
+		delete(fs.snapshots, mountPoint)
+	}
+}
+
+// OpenFile wraps the OpenFile method of the underlying file system.
+func (fs *LocalFSSnapshot) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	return fs.FS.OpenFile(fs.snapshotPath(name), flag, metadataOnly)
+}
+
+// Lstat wraps the Lstat method of the underlying file system.
+func (fs *LocalFSSnapshot) Lstat(name string) (*ExtendedFileInfo, error) {
+	return fs.FS.Lstat(fs.snapshotPath(name))
+}
+
+// snapshotPath returns the path of a file within the snapshot of its
+// filesystem, the snapshot is created if necessary. If the filesystem cannot
+// be snapshotted, the original path is returned.
+func (fs *LocalFSSnapshot) snapshotPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	mnt, ok := findMount(fs.mounts, abs)
+	if !ok {
+		return path
+	}
+
+	fs.mutex.RLock()
+	snapshot, ok := fs.snapshots[mnt.MountPoint]
+	fs.mutex.RUnlock()
+	if !ok {
+		fs.mutex.Lock()
+		snapshot = fs.createSnapshot(mnt)
+		fs.mutex.Unlock()
+	}
+
+	if snapshot == nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(mnt.MountPoint, abs)
+	if err != nil {
+		return path
+	}
+	return filepath.Join(snapshot.root, rel)
+}
+
+// createSnapshot creates a snapshot for the mount, it returns nil if this is
+// not possible. fs.mutex must be held.
+func (fs *LocalFSSnapshot) createSnapshot(mnt mountInfo) *fsSnapshot {
+	// the snapshot may have been created while waiting for the lock
+	if snapshot, ok := fs.snapshots[mnt.MountPoint]; ok {
+		return snapshot
+	}
+	if _, ok := fs.unsnapshot[mnt.MountPoint]; ok {
+		return nil
+	}
+	// never try again
+	fs.unsnapshot[mnt.MountPoint] = struct{}{}
+
+	provider := fs.snapshotter.providerFor(mnt)
+	if provider == "" {
+		fs.msgMessage("no snapshot support for %s filesystem at [%s], reading files directly\n", mnt.FSType, mnt.MountPoint)
+		return nil
+	}
+
+	fs.msgMessage("creating %s snapshot for [%s]\n", provider, mnt.MountPoint)
+	snapshot, err := fs.snapshotter.create(provider, mnt, fs.name)
+	if err != nil {
+		fs.msgError(mnt.MountPoint, fmt.Errorf("failed to create snapshot for [%s]: %w", mnt.MountPoint, err))
+		return nil
+	}
+
+	delete(fs.unsnapshot, mnt.MountPoint)
+	fs.snapshots[mnt.MountPoint] = snapshot
+	fs.msgMessage("successfully created snapshot for [%s]\n", mnt.MountPoint)
+	return snapshot
+}
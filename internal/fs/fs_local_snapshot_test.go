@@ -0,0 +1,224 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/options"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+const testMountInfo = `22 1 0:21 / / rw,relatime shared:1 - btrfs /dev/sda2 rw,subvol=/@
+23 22 0:22 / /proc rw,nosuid,nodev,noexec,relatime shared:5 - proc proc rw
+24 22 0:21 /@home /home rw,relatime shared:2 - btrfs /dev/sda2 rw,subvol=/@home
+25 24 0:40 / /home/user/my\040data rw,relatime - zfs tank/data rw,xattr
+26 22 253:1 /srv /srv rw,relatime shared:3 - xfs /dev/mapper/vg0-srv rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(testMountInfo))
+	rtest.OK(t, err)
+	rtest.Equals(t, []mountInfo{
+		{Root: "/", MountPoint: "/", FSType: "btrfs", Source: "/dev/sda2"},
+		{Root: "/", MountPoint: "/proc", FSType: "proc", Source: "proc"},
+		{Root: "/@home", MountPoint: "/home", FSType: "btrfs", Source: "/dev/sda2"},
+		{Root: "/", MountPoint: "/home/user/my data", FSType: "zfs", Source: "tank/data"},
+		{Root: "/srv", MountPoint: "/srv", FSType: "xfs", Source: "/dev/mapper/vg0-srv"},
+	}, mounts)
+
+	_, err = parseMountInfo(strings.NewReader("22 1 0:21 / / rw\n"))
+	rtest.Assert(t, err != nil, "invalid line was accepted")
+}
+
+func TestFindMount(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(testMountInfo))
+	rtest.OK(t, err)
+
+	for _, test := range []struct {
+		path, mountPoint string
+	}{
+		{"/", "/"},
+		{"/etc/passwd", "/"},
+		{"/proc/self", "/proc"},
+		{"/home", "/home"},
+		{"/homes", "/"},
+		{"/home/user/file", "/home"},
+		{"/home/user/my data/file", "/home/user/my data"},
+	} {
+		mnt, ok := findMount(mounts, test.path)
+		rtest.Assert(t, ok, "no mount found for %v", test.path)
+		rtest.Equals(t, test.mountPoint, mnt.MountPoint, test.path)
+	}
+}
+
+func TestProviderFor(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(testMountInfo))
+	rtest.OK(t, err)
+
+	// device mapper devices which are no logical volumes are not detected
+	mounts = append(mounts, mountInfo{Root: "/", MountPoint: "/data", FSType: "ext4", Source: "/dev/mapper/luks-data"})
+	run := func(name string, args ...string) ([]byte, error) {
+		if name == "lvs" && args[len(args)-1] == "/dev/mapper/vg0-srv" {
+			return []byte("  vg0 srv linear\n"), nil
+		}
+		return nil, fmt.Errorf("%v failed", name)
+	}
+
+	for _, test := range []struct {
+		provider string
+		want     []string
+	}{
+		{"", []string{"btrfs", "", "btrfs", "zfs", "lvm", ""}},
+		{"btrfs", []string{"btrfs", "", "btrfs", "", "", ""}},
+		{"lvm", []string{"lvm", "", "lvm", "", "lvm", "lvm"}},
+	} {
+		s := fsSnapshotter{cfg: FSSnapshotConfig{Provider: test.provider}, run: run}
+		for i, mnt := range mounts {
+			rtest.Equals(t, test.want[i], s.providerFor(mnt), fmt.Sprintf("provider %q, mount %v", test.provider, mnt.MountPoint))
+		}
+	}
+}
+
+func TestParseFSSnapshotConfig(t *testing.T) {
+	cfg, err := ParseFSSnapshotConfig(options.Options{"fssnapshot.provider": "zfs"})
+	rtest.OK(t, err)
+	rtest.Equals(t, "zfs", cfg.Provider)
+
+	_, err = ParseFSSnapshotConfig(options.Options{"fssnapshot.provider": "ext4"})
+	rtest.Assert(t, err != nil, "invalid provider was accepted")
+}
+
+func TestLocalFSSnapshot(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	live := filepath.Join(tempdir, "live")
+	other := filepath.Join(tempdir, "other")
+	rtest.OK(t, os.MkdirAll(filepath.Join(live, "dir"), 0o700))
+	rtest.OK(t, os.MkdirAll(other, 0o700))
+	rtest.OK(t, os.WriteFile(filepath.Join(live, "dir", "file"), []byte("live"), 0o600))
+	rtest.OK(t, os.WriteFile(filepath.Join(other, "file"), []byte("other"), 0o600))
+
+	mounts := []mountInfo{
+		{Root: "/", MountPoint: live, FSType: "btrfs", Source: "/dev/sda2"},
+		{Root: "/", MountPoint: other, FSType: "tmpfs", Source: "tmpfs"},
+	}
+
+	mountDir := filepath.Join(tempdir, "mnt")
+	rtest.OK(t, os.MkdirAll(mountDir, 0o700))
+
+	var commands []string
+	run := func(name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		switch {
+		case name == "btrfs" && args[1] == "snapshot":
+			// simulate the snapshot using a modified copy
+			dst := args[len(args)-1]
+			rtest.OK(t, os.MkdirAll(filepath.Join(dst, "dir"), 0o700))
+			rtest.OK(t, os.WriteFile(filepath.Join(dst, "dir", "file"), []byte("snapshot"), 0o600))
+		case name == "btrfs" && args[1] == "delete":
+			rtest.OK(t, os.RemoveAll(args[len(args)-1]))
+		}
+		return nil, nil
+	}
+
+	var messages []string
+	fs, err := newLocalFSSnapshot(mounts, fsSnapshotter{cfg: FSSnapshotConfig{MountDir: mountDir}, run: run},
+		func(item string, err error) { t.Errorf("unexpected error for %v: %v", item, err) },
+		func(msg string, args ...interface{}) { messages = append(messages, fmt.Sprintf(msg, args...)) },
+	)
+	rtest.OK(t, err)
+
+	readFile := func(name string) string {
+		f, err := fs.OpenFile(name, O_RDONLY, false)
+		rtest.OK(t, err)
+		defer func() {
+			rtest.OK(t, f.Close())
+		}()
+		buf := make([]byte, 100)
+		n, err := f.Read(buf)
+		rtest.OK(t, err)
+		return string(buf[:n])
+	}
+
+	rtest.Equals(t, "snapshot", readFile(filepath.Join(live, "dir", "file")))
+	rtest.Equals(t, "snapshot", readFile(filepath.Join(live, "dir", "file")))
+	rtest.Equals(t, "other", readFile(filepath.Join(other, "file")))
+	fi, err := fs.Lstat(filepath.Join(live, "dir"))
+	rtest.OK(t, err)
+	rtest.Assert(t, fi.Mode.IsDir(), "snapshot directory is not a directory")
+
+	// the snapshot is only created once, outside of the snapshotted subvolume
+	rtest.Equals(t, 2, len(commands))
+	rtest.Assert(t, strings.HasPrefix(commands[0], "mount -t btrfs -o subvolid=5 /dev/sda2 "+mountDir+"/restic-"), "unexpected command %q", commands[0])
+	rtest.Assert(t, strings.HasPrefix(commands[1], "btrfs subvolume snapshot -r "+mountDir+"/restic-"), "unexpected command %q", commands[1])
+	rtest.Equals(t, 3, len(messages))
+
+	fs.DeleteSnapshots()
+	rtest.Equals(t, 4, len(commands))
+	rtest.Assert(t, strings.HasPrefix(commands[2], "btrfs subvolume delete "+mountDir+"/restic-"), "unexpected command %q", commands[2])
+	rtest.Assert(t, strings.HasPrefix(commands[3], "umount "+mountDir+"/restic-"), "unexpected command %q", commands[3])
+	entries, err := os.ReadDir(mountDir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(entries))
+}
+
+func TestFSSnapshotCleanupOnError(t *testing.T) {
+	var commands []string
+	run := func(name string, args ...string) ([]byte, error) {
+		commands = append(commands, name)
+		switch name {
+		case "lvs":
+			return []byte("  vg0 srv linear\n"), nil
+		case "mount":
+			return nil, fmt.Errorf("mount failed")
+		}
+		return nil, nil
+	}
+
+	s := fsSnapshotter{cfg: FSSnapshotConfig{MountDir: rtest.TempDir(t)}, run: run}
+	mnt := mountInfo{Root: "/", MountPoint: "/srv", FSType: "xfs", Source: "/dev/mapper/vg0-srv"}
+	_, err := s.create("lvm", mnt, "restic-test")
+	rtest.Assert(t, err != nil, "mount error was not returned")
+	rtest.Equals(t, []string{"lvs", "lvcreate", "mount", "lvremove"}, commands)
+
+	entries, err := os.ReadDir(s.cfg.MountDir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(entries))
+}
+
+func TestFSSnapshotLVMSize(t *testing.T) {
+	for _, test := range []struct {
+		segtype, size string
+		args          []string
+	}{
+		{"thin", "", nil},
+		{"linear", "", []string{"--extents", "10%ORIGIN"}},
+		{"linear", "5G", []string{"--size", "5G"}},
+		{"linear", "20%FREE", []string{"--extents", "20%FREE"}},
+	} {
+		var lvcreate []string
+		run := func(name string, args ...string) ([]byte, error) {
+			switch name {
+			case "lvs":
+				return []byte("  vg0 srv " + test.segtype + "\n"), nil
+			case "lvcreate":
+				lvcreate = args
+			}
+			return nil, nil
+		}
+
+		s := fsSnapshotter{cfg: FSSnapshotConfig{MountDir: rtest.TempDir(t), LVMSize: test.size}, run: run}
+		mnt := mountInfo{Root: "/", MountPoint: "/srv", FSType: "ext4", Source: "/dev/mapper/vg0-srv"}
+		snapshot, err := s.create("lvm", mnt, "restic-test")
+		rtest.OK(t, err)
+		rtest.OK(t, snapshot.Delete())
+
+		want := append([]string{"--snapshot", "--setactivationskip", "n", "--name", "srv-restic-test"}, test.args...)
+		rtest.Equals(t, append(want, "vg0/srv"), lvcreate, fmt.Sprintf("%v %q", test.segtype, test.size))
+	}
+}
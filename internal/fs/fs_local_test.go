@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -157,6 +158,38 @@ func testFSLocalReaddir(t *testing.T, makeReadable bool) {
 	rtest.OK(t, f.Close())
 }
 
+func TestFSLocalNoAtime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "item")
+	rtest.OK(t, os.WriteFile(path, []byte("example"), 0o600))
+	// an access time older than the modification time is updated on read
+	// even with relatime
+	atime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rtest.OK(t, os.Chtimes(path, atime, mtime))
+
+	checkTimes := func() {
+		fi, err := os.Stat(path)
+		rtest.OK(t, err)
+		rtest.Equals(t, atime, ExtendedStat(fi).AccessTime.UTC(), "access time mismatch")
+		rtest.Equals(t, mtime, fi.ModTime().UTC(), "modification time mismatch")
+	}
+
+	f, err := Local{NoAtime: true}.OpenFile(path, O_NOFOLLOW, false)
+	rtest.OK(t, err)
+	_, err = io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+	checkTimes()
+
+	// simulate a read for which O_NOATIME was not available
+	lf, err := newLocalFile(path, O_NOFOLLOW, false, Local{NoAtime: true})
+	rtest.OK(t, err)
+	lf.atime = atime
+	rtest.OK(t, os.Chtimes(path, time.Now(), mtime))
+	rtest.OK(t, lf.Close())
+	checkTimes()
+}
+
 func TestFSLocalReadableRace(t *testing.T) {
 	tmp := t.TempDir()
 	path := filepath.Join(tmp, "item")
@@ -21,7 +21,7 @@ type VSSConfig struct {
 
 func init() {
 	if runtime.GOOS == "windows" {
-		options.Register("vss", VSSConfig{})
+		options.Register("vss", NewVSSConfig())
 	}
 }
 
@@ -96,6 +96,20 @@ func (fp *CommandReader) Close() error {
 	if fp.waitHandled {
 		return nil
 	}
+	fp.waitHandled = true
 
 	return fp.wait()
 }
+
+// Kill terminates the command if it is still running and waits for it to
+// exit. It does nothing if the command has already been waited for by Read()
+// or Close().
+func (fp *CommandReader) Kill() {
+	if fp.waitHandled {
+		return
+	}
+	fp.waitHandled = true
+
+	_ = fp.cmd.Process.Kill()
+	_ = fp.cmd.Wait()
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/test"
@@ -51,3 +52,23 @@ func TestCommandReaderOutput(t *testing.T) {
 
 	test.Equals(t, "hello world", strings.TrimSpace(buf.String()))
 }
+
+func TestCommandReaderKill(t *testing.T) {
+	reader, err := fs.NewCommandReader(context.TODO(), []string{"sleep", "60"}, io.Discard)
+	test.OK(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		reader.Kill()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Kill did not terminate the command")
+	}
+
+	// the command was already waited for
+	test.OK(t, reader.Close())
+}
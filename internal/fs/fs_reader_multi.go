@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"syscall"
+)
+
+// MultiReader is a file system which provides the files of several Readers.
+// As for Reader, each file can only be opened once. The names of all Readers
+// must be distinct.
+type MultiReader struct {
+	Readers []*Reader
+}
+
+// statically ensure that MultiReader implements FS.
+var _ FS = &MultiReader{}
+
+// NewMultiReader returns a MultiReader for the given readers. An error is
+// returned if two readers use the same name.
+func NewMultiReader(readers ...*Reader) (*MultiReader, error) {
+	names := make(map[string]struct{}, len(readers))
+	for _, rd := range readers {
+		name := path.Clean(rd.Name)
+		if _, ok := names[name]; ok {
+			return nil, fmt.Errorf("duplicate filename %q", rd.Name)
+		}
+		names[name] = struct{}{}
+	}
+	return &MultiReader{Readers: readers}, nil
+}
+
+// VolumeName returns leading volume name, for the MultiReader file system
+// it's always the empty string.
+func (fs *MultiReader) VolumeName(_ string) string {
+	return ""
+}
+
+func (fs *MultiReader) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	for _, rd := range fs.Readers {
+		if rd.Name == name {
+			return rd.OpenFile(name, flag, metadataOnly)
+		}
+	}
+
+	if flag & ^(O_RDONLY|O_NOFOLLOW|O_DIRECTORY) != 0 {
+		return nil, pathError("open", name,
+			fmt.Errorf("invalid combination of flags 0x%x", flag))
+	}
+
+	// directories containing the files only list their direct children
+	dir := path.Clean(name)
+	if dir == "." {
+		dir = "/"
+	}
+	var entries []string
+	for _, rd := range fs.Readers {
+		for p := path.Join("/", rd.Name); p != "/"; p = path.Dir(p) {
+			if path.Dir(p) == dir {
+				if !slices.Contains(entries, path.Base(p)) {
+					entries = append(entries, path.Base(p))
+				}
+				break
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil, pathError("open", name, syscall.ENOENT)
+	}
+
+	fi, err := fs.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	return fakeDir{
+		entries: entries,
+		fakeFile: fakeFile{
+			name: name,
+			fi:   fi,
+		},
+	}, nil
+}
+
+// Lstat returns the FileInfo structure describing the named file or one of
+// the directories containing it.
+func (fs *MultiReader) Lstat(name string) (*ExtendedFileInfo, error) {
+	for _, rd := range fs.Readers {
+		fi, err := rd.Lstat(name)
+		if err == nil {
+			return fi, nil
+		}
+	}
+
+	return nil, pathError("lstat", name, os.ErrNotExist)
+}
+
+// Join joins any number of path elements into a single path.
+func (fs *MultiReader) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Separator returns the OS and FS dependent separator for dirs/subdirs/files.
+func (fs *MultiReader) Separator() string {
+	return "/"
+}
+
+// IsAbs reports whether the path is absolute. For the MultiReader, this is
+// always the case.
+func (fs *MultiReader) IsAbs(_ string) bool {
+	return true
+}
+
+// Abs returns an absolute representation of path. For the MultiReader, all
+// paths are absolute.
+func (fs *MultiReader) Abs(p string) (string, error) {
+	return path.Clean(p), nil
+}
+
+// Clean returns the cleaned path. For details, see filepath.Clean.
+func (fs *MultiReader) Clean(p string) string {
+	return path.Clean(p)
+}
+
+// Base returns the last element of p.
+func (fs *MultiReader) Base(p string) string {
+	return path.Base(p)
+}
+
+// Dir returns p without the last element.
+func (fs *MultiReader) Dir(p string) string {
+	return path.Dir(p)
+}
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/test"
+)
+
+func TestFSMultiReader(t *testing.T) {
+	now := time.Now()
+	newReader := func(name, data string) *Reader {
+		return &Reader{
+			Name:       name,
+			ReadCloser: io.NopCloser(strings.NewReader(data)),
+			Mode:       0644,
+			Size:       int64(len(data)),
+			ModTime:    now,
+		}
+	}
+
+	fs, err := NewMultiReader(
+		newReader("/db/first.sql", "first"),
+		newReader("/db/second.sql", "second"),
+		newReader("/other", "other"),
+	)
+	test.OK(t, err)
+
+	verifyDirectoryContents(t, fs, "/", []string{"db", "other"})
+	verifyDirectoryContents(t, fs, ".", []string{"db", "other"})
+	verifyDirectoryContents(t, fs, "/db", []string{"first.sql", "second.sql"})
+
+	fi, err := fs.Lstat("/db")
+	test.OK(t, err)
+	checkFileInfo(t, fi, "/db", time.Time{}, os.ModeDir|0755, true)
+
+	fi, err = fs.Lstat("/db/second.sql")
+	test.OK(t, err)
+	test.Equals(t, os.FileMode(0644), fi.Mode)
+	test.Equals(t, int64(len("second")), fi.Size)
+
+	verifyFileContentOpenFile(t, fs, "/db/first.sql", []byte("first"))
+	verifyFileContentOpenFile(t, fs, "/db/second.sql", []byte("second"))
+	verifyFileContentOpenFile(t, fs, "/other", []byte("other"))
+
+	// files can only be opened once
+	_, err = fs.OpenFile("/other", O_RDONLY, false)
+	test.Assert(t, err != nil, "file opened twice")
+
+	_, err = fs.OpenFile("/missing", O_RDONLY, false)
+	test.Assert(t, os.IsNotExist(err), "unexpected error %v", err)
+	_, err = fs.Lstat("/db/missing")
+	test.Assert(t, os.IsNotExist(err), "unexpected error %v", err)
+
+	_, err = NewMultiReader(newReader("/foo", "a"), newReader("/foo", "b"))
+	test.Assert(t, err != nil, "duplicate filename not detected")
+}
@@ -0,0 +1,295 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// S3 is a read-only file system which represents the objects stored in an S3
+// bucket. The root directory of the file system is the root of the bucket,
+// object keys are interpreted as paths using forward slashes as separator.
+// Directories are derived from the common prefixes of the keys, objects whose
+// key ends with a slash are treated as directory markers.
+//
+// Objects do not have an owner, permissions or more than one timestamp. Files
+// are reported with mode 0644 and directories with mode 0755. Access and
+// change time are set to the modification time of an object, directories
+// have a zero modification time.
+type S3 struct {
+	ctx    context.Context
+	client *minio.Client
+	bucket string
+}
+
+// statically ensure that S3 implements FS.
+var _ FS = &S3{}
+
+// NewS3 returns a file system which uses client to access the objects in
+// bucket. All requests are made using ctx.
+func NewS3(ctx context.Context, client *minio.Client, bucket string) *S3 {
+	return &S3{ctx: ctx, client: client, bucket: bucket}
+}
+
+// s3Key returns the object key for the path name.
+func s3Key(name string) string {
+	key := path.Clean("/" + name)
+	return strings.TrimPrefix(key, "/")
+}
+
+// s3DirPrefix returns the prefix of all keys contained in the directory with
+// the given key.
+func s3DirPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+// s3EntryName returns the name of the directory entry for the listed key,
+// which must start with prefix. For directory markers the empty string is
+// returned.
+func s3EntryName(prefix, key string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(key, prefix), "/")
+}
+
+// VolumeName returns leading volume name, for the S3 file system it's always
+// the empty string.
+func (fs *S3) VolumeName(_ string) string {
+	return ""
+}
+
+// OpenFile opens a file or directory for reading.
+//
+// Only the O_NOFOLLOW and O_DIRECTORY flags are supported. As the file system
+// does not contain symlinks, O_NOFOLLOW has no effect.
+func (fs *S3) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	if flag & ^(O_RDONLY|O_NOFOLLOW|O_DIRECTORY) != 0 {
+		return nil, pathError("open", name,
+			fmt.Errorf("invalid combination of flags 0x%x", flag))
+	}
+
+	f := &s3File{
+		fs:   fs,
+		name: name,
+		flag: flag,
+	}
+	if !metadataOnly {
+		if err := f.open(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Lstat returns the FileInfo structure describing the named file.
+func (fs *S3) Lstat(name string) (*ExtendedFileInfo, error) {
+	key := s3Key(name)
+	if key == "" {
+		return s3DirInfo("/"), nil
+	}
+
+	obj, err := fs.client.StatObject(fs.ctx, fs.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return &ExtendedFileInfo{
+			Name:       path.Base(key),
+			Mode:       0644,
+			Size:       obj.Size,
+			Links:      1,
+			ModTime:    obj.LastModified,
+			AccessTime: obj.LastModified,
+			ChangeTime: obj.LastModified,
+		}, nil
+	}
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return nil, pathError("lstat", name, err)
+	}
+
+	// the key may be the common prefix of other objects
+	ctx, cancel := context.WithCancel(fs.ctx)
+	defer cancel()
+	for obj := range fs.client.ListObjects(ctx, fs.bucket, minio.ListObjectsOptions{Prefix: s3DirPrefix(key), MaxKeys: 1}) {
+		if obj.Err != nil {
+			return nil, pathError("lstat", name, obj.Err)
+		}
+		return s3DirInfo(path.Base(key)), nil
+	}
+	return nil, pathError("lstat", name, os.ErrNotExist)
+}
+
+func s3DirInfo(name string) *ExtendedFileInfo {
+	return &ExtendedFileInfo{
+		Name:  name,
+		Mode:  os.ModeDir | 0755,
+		Links: 1,
+	}
+}
+
+// Join joins any number of path elements into a single path, adding a
+// Separator if necessary. Join calls Clean on the result; in particular, all
+// empty strings are ignored.
+func (fs *S3) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Separator returns the OS and FS dependent separator for dirs/subdirs/files.
+func (fs *S3) Separator() string {
+	return "/"
+}
+
+// IsAbs reports whether the path is absolute.
+func (fs *S3) IsAbs(p string) bool {
+	return path.IsAbs(p)
+}
+
+// Abs returns an absolute representation of path. Relative paths are
+// interpreted relative to the root of the bucket. Abs calls Clean on the
+// result.
+func (fs *S3) Abs(p string) (string, error) {
+	return path.Clean("/" + p), nil
+}
+
+// Clean returns the cleaned path. For details, see path.Clean.
+func (fs *S3) Clean(p string) string {
+	return path.Clean(p)
+}
+
+// Base returns the last element of p.
+func (fs *S3) Base(p string) string {
+	return path.Base(p)
+}
+
+// Dir returns p without the last element.
+func (fs *S3) Dir(p string) string {
+	return path.Dir(p)
+}
+
+type s3File struct {
+	fs   *S3
+	name string
+	flag int
+
+	obj *minio.Object
+	fi  *ExtendedFileInfo
+}
+
+// See the File interface for a description of each method
+var _ File = &s3File{}
+
+// open opens the file for reading. Directories are not opened, their entries
+// are listed by Readdirnames.
+func (f *s3File) open() error {
+	if err := f.cacheFI(); err != nil {
+		return err
+	}
+
+	switch {
+	case f.fi.Mode.IsDir():
+		return nil
+	case f.flag&O_DIRECTORY != 0:
+		return pathError("open", f.name, syscall.ENOTDIR)
+	}
+
+	obj, err := f.fs.client.GetObject(f.fs.ctx, f.fs.bucket, s3Key(f.name), minio.GetObjectOptions{})
+	if err != nil {
+		return pathError("open", f.name, err)
+	}
+	f.obj = obj
+	return nil
+}
+
+func (f *s3File) MakeReadable() error {
+	if f.obj != nil {
+		panic("file is already readable")
+	}
+
+	// reset cached FileInfo
+	f.fi = nil
+	return f.open()
+}
+
+func (f *s3File) cacheFI() error {
+	if f.fi != nil {
+		return nil
+	}
+
+	fi, err := f.fs.Lstat(f.name)
+	if err != nil {
+		return err
+	}
+	f.fi = fi
+	return nil
+}
+
+func (f *s3File) Stat() (*ExtendedFileInfo, error) {
+	err := f.cacheFI()
+	// the call to cacheFI MUST happen before reading from f.fi
+	return f.fi, err
+}
+
+func (f *s3File) ToNode(_ bool) (*restic.Node, error) {
+	if err := f.cacheFI(); err != nil {
+		return nil, err
+	}
+
+	node := buildBasicNode(f.name, f.fi)
+	node.Links = f.fi.Links
+	node.AccessTime = f.fi.AccessTime
+	node.ChangeTime = f.fi.ChangeTime
+	return node, nil
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.obj == nil {
+		return 0, pathError("read", f.name, os.ErrInvalid)
+	}
+	n, err := f.obj.Read(p)
+	if err != nil && err != io.EOF {
+		err = pathError("read", f.name, err)
+	}
+	return n, err
+}
+
+func (f *s3File) Readdirnames(n int) ([]string, error) {
+	if n > 0 {
+		return nil, pathError("readdirnames", f.name, errors.New("not implemented"))
+	}
+
+	prefix := s3DirPrefix(s3Key(f.name))
+	ctx, cancel := context.WithCancel(f.fs.ctx)
+	defer cancel()
+
+	var names []string
+	seen := make(map[string]struct{})
+	for obj := range f.fs.client.ListObjects(ctx, f.fs.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, pathError("readdirnames", f.name, obj.Err)
+		}
+
+		name := s3EntryName(prefix, obj.Key)
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f *s3File) Close() error {
+	if f.obj != nil {
+		return f.obj.Close()
+	}
+	return nil
+}
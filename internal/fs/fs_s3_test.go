@@ -0,0 +1,216 @@
+package fs
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// fakeS3 is a minimal S3 server which serves the objects of a single bucket.
+type fakeS3 struct {
+	objects map[string][]byte
+	mtime   time.Time
+}
+
+type fakeS3ListResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Name           string
+	Prefix         string
+	IsTruncated    bool
+	Contents       []fakeS3Object
+	CommonPrefixes []fakeS3Prefix
+}
+
+type fakeS3Object struct {
+	Key          string
+	Size         int64
+	LastModified string
+}
+
+type fakeS3Prefix struct {
+	Prefix string
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	switch {
+	case query.Has("location"):
+		_, _ = io.WriteString(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`)
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, query)
+	default:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method != http.MethodHead {
+				_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", s.mtime.UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	}
+}
+
+func (s *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	result := fakeS3ListResult{Name: "bucket", Prefix: prefix}
+
+	var keys []string
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(rest, delimiter); i >= 0 {
+				p := prefix + rest[:i+1]
+				if !seen[p] {
+					seen[p] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, fakeS3Prefix{Prefix: p})
+				}
+				continue
+			}
+		}
+		result.Contents = append(result.Contents, fakeS3Object{
+			Key:          key,
+			Size:         int64(len(s.objects[key])),
+			LastModified: s.mtime.UTC().Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func testS3(t *testing.T, objects map[string][]byte) *S3 {
+	srv := httptest.NewServer(&fakeS3{objects: objects, mtime: time.Unix(1700000000, 0)})
+	t.Cleanup(srv.Close)
+
+	client, err := minio.New(strings.TrimPrefix(srv.URL, "http://"), &minio.Options{
+		Creds:        credentials.NewStaticV4("key", "secret", ""),
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	})
+	rtest.OK(t, err)
+	return NewS3(context.TODO(), client, "bucket")
+}
+
+func TestS3FS(t *testing.T) {
+	data := rtest.Random(23, 12345)
+	fs := testS3(t, map[string][]byte{
+		"file":          []byte("foo"),
+		"dir/":          nil,
+		"dir/sub/file":  data,
+		"dir/sub/file2": []byte("bar"),
+	})
+
+	names, err := Readdirnames(fs, "/", O_NOFOLLOW)
+	rtest.OK(t, err)
+	sort.Strings(names)
+	rtest.Equals(t, []string{"dir", "file"}, names)
+
+	names, err = Readdirnames(fs, "/dir/sub", O_NOFOLLOW)
+	rtest.OK(t, err)
+	sort.Strings(names)
+	rtest.Equals(t, []string{"file", "file2"}, names)
+
+	fi, err := fs.Lstat("/dir/sub")
+	rtest.OK(t, err)
+	rtest.Equals(t, "sub", fi.Name)
+	rtest.Assert(t, fi.Mode.IsDir(), "expected directory, got mode %v", fi.Mode)
+
+	fi, err = fs.Lstat("/dir/sub/file")
+	rtest.OK(t, err)
+	rtest.Equals(t, "file", fi.Name)
+	rtest.Equals(t, int64(len(data)), fi.Size)
+	rtest.Equals(t, time.Unix(1700000000, 0).Unix(), fi.ModTime.Unix())
+
+	_, err = fs.Lstat("/missing")
+	rtest.Assert(t, os.IsNotExist(err), "unexpected error %v", err)
+
+	f, err := fs.OpenFile("/dir/sub/file", O_NOFOLLOW, false)
+	rtest.OK(t, err)
+	buf, err := io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+	node, err := f.ToNode(false)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(len(data)), node.Size)
+	rtest.OK(t, f.Close())
+
+	_, err = fs.OpenFile("/file", O_DIRECTORY, false)
+	rtest.Assert(t, err != nil, "opening a file as directory succeeded")
+}
+
+func TestS3Key(t *testing.T) {
+	for _, test := range []struct {
+		name, key, prefix string
+	}{
+		{"/", "", ""},
+		{"", "", ""},
+		{"/foo", "foo", "foo/"},
+		{"foo/bar/", "foo/bar", "foo/bar/"},
+		{"/foo/../bar//baz", "bar/baz", "bar/baz/"},
+	} {
+		key := s3Key(test.name)
+		rtest.Equals(t, test.key, key)
+		rtest.Equals(t, test.prefix, s3DirPrefix(key))
+	}
+}
+
+func TestS3EntryName(t *testing.T) {
+	for _, test := range []struct {
+		prefix, key, name string
+	}{
+		{"", "file", "file"},
+		{"", "dir/", "dir"},
+		{"dir/", "dir/file", "file"},
+		{"dir/", "dir/sub/", "sub"},
+		// directory marker of the listed directory itself
+		{"dir/", "dir/", ""},
+	} {
+		rtest.Equals(t, test.name, s3EntryName(test.prefix, test.key))
+	}
+}
+
+func TestS3Abs(t *testing.T) {
+	fs := &S3{}
+	for _, test := range []struct {
+		path, abs string
+	}{
+		{"foo", "/foo"},
+		{"/foo/bar/", "/foo/bar"},
+		{".", "/"},
+	} {
+		abs, err := fs.Abs(test.path)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.abs, abs)
+	}
+}
@@ -0,0 +1,265 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// SFTP is a file system on a remote host which is accessed using an SFTP
+// session. All paths use forward slashes as separator, relative paths are
+// resolved against the working directory of the session, which usually is the
+// home directory of the user.
+//
+// The SFTP protocol does not provide inode numbers, device IDs, extended
+// attributes or the change time, these are not set for nodes returned by
+// ToNode(). The change time is set to the modification time instead.
+type SFTP struct {
+	client *sftp.Client
+}
+
+// statically ensure that SFTP implements FS.
+var _ FS = &SFTP{}
+
+// NewSFTP returns a file system which uses client to access files.
+func NewSFTP(client *sftp.Client) *SFTP {
+	return &SFTP{client: client}
+}
+
+// VolumeName returns leading volume name, for the SFTP file system it's
+// always the empty string.
+func (fs *SFTP) VolumeName(_ string) string {
+	return ""
+}
+
+// OpenFile opens a file or directory for reading.
+//
+// Only the O_NOFOLLOW and O_DIRECTORY flags are supported. As the SFTP
+// protocol cannot open files without following symlinks, O_NOFOLLOW is
+// implemented by checking the file type before opening it.
+func (fs *SFTP) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	if flag & ^(O_RDONLY|O_NOFOLLOW|O_DIRECTORY) != 0 {
+		return nil, pathError("open", name,
+			fmt.Errorf("invalid combination of flags 0x%x", flag))
+	}
+
+	f := &sftpFile{
+		client: fs.client,
+		name:   name,
+		flag:   flag,
+	}
+	if !metadataOnly {
+		if err := f.open(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Lstat returns the FileInfo structure describing the named file.
+// If the file is a symbolic link, the returned FileInfo
+// describes the symbolic link.  Lstat makes no attempt to follow the link.
+func (fs *SFTP) Lstat(name string) (*ExtendedFileInfo, error) {
+	fi, err := fs.client.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	return sftpExtendedStat(fi), nil
+}
+
+// Join joins any number of path elements into a single path, adding a
+// Separator if necessary. Join calls Clean on the result; in particular, all
+// empty strings are ignored.
+func (fs *SFTP) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Separator returns the OS and FS dependent separator for dirs/subdirs/files.
+func (fs *SFTP) Separator() string {
+	return "/"
+}
+
+// IsAbs reports whether the path is absolute.
+func (fs *SFTP) IsAbs(p string) bool {
+	return path.IsAbs(p)
+}
+
+// Abs returns an absolute representation of path. If the path is not absolute
+// it will be joined with the working directory of the SFTP session to turn it
+// into an absolute path. Abs calls Clean on the result.
+func (fs *SFTP) Abs(p string) (string, error) {
+	if path.IsAbs(p) {
+		return path.Clean(p), nil
+	}
+
+	wd, err := fs.client.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(wd, p), nil
+}
+
+// Clean returns the cleaned path. For details, see path.Clean.
+func (fs *SFTP) Clean(p string) string {
+	return path.Clean(p)
+}
+
+// Base returns the last element of p.
+func (fs *SFTP) Base(p string) string {
+	return path.Base(p)
+}
+
+// Dir returns p without the last element.
+func (fs *SFTP) Dir(p string) string {
+	return path.Dir(p)
+}
+
+// sftpExtendedStat converts the FileInfo returned by the SFTP client.
+func sftpExtendedStat(fi os.FileInfo) *ExtendedFileInfo {
+	efi := &ExtendedFileInfo{
+		Name:       fi.Name(),
+		Mode:       fi.Mode(),
+		Size:       fi.Size(),
+		Links:      1,
+		ModTime:    fi.ModTime(),
+		AccessTime: fi.ModTime(),
+		ChangeTime: fi.ModTime(),
+	}
+
+	if s, ok := fi.Sys().(*sftp.FileStat); ok {
+		efi.UID = s.UID
+		efi.GID = s.GID
+		efi.AccessTime = time.Unix(int64(s.Atime), 0)
+	}
+	return efi
+}
+
+type sftpFile struct {
+	client *sftp.Client
+	name   string
+	flag   int
+
+	f  *sftp.File
+	fi *ExtendedFileInfo
+}
+
+// See the File interface for a description of each method
+var _ File = &sftpFile{}
+
+// open opens the file for reading. Directories are not opened, their entries
+// are listed by Readdirnames.
+func (f *sftpFile) open() error {
+	if err := f.cacheFI(); err != nil {
+		return err
+	}
+
+	switch {
+	case f.fi.Mode.IsDir():
+		return nil
+	case f.flag&O_DIRECTORY != 0:
+		return pathError("open", f.name, syscall.ENOTDIR)
+	case f.fi.Mode&os.ModeSymlink != 0:
+		return pathError("open", f.name, syscall.ELOOP)
+	}
+
+	file, err := f.client.Open(f.name)
+	if err != nil {
+		return err
+	}
+	f.f = file
+	return nil
+}
+
+func (f *sftpFile) MakeReadable() error {
+	if f.f != nil {
+		panic("file is already readable")
+	}
+
+	// reset cached FileInfo
+	f.fi = nil
+	return f.open()
+}
+
+func (f *sftpFile) cacheFI() error {
+	if f.fi != nil {
+		return nil
+	}
+
+	var fi os.FileInfo
+	var err error
+	if f.flag&O_NOFOLLOW != 0 {
+		fi, err = f.client.Lstat(f.name)
+	} else {
+		fi, err = f.client.Stat(f.name)
+	}
+	if err != nil {
+		return err
+	}
+	f.fi = sftpExtendedStat(fi)
+	return nil
+}
+
+func (f *sftpFile) Stat() (*ExtendedFileInfo, error) {
+	err := f.cacheFI()
+	// the call to cacheFI MUST happen before reading from f.fi
+	return f.fi, err
+}
+
+func (f *sftpFile) ToNode(_ bool) (*restic.Node, error) {
+	if err := f.cacheFI(); err != nil {
+		return nil, err
+	}
+
+	node := buildBasicNode(f.name, f.fi)
+	node.UID = f.fi.UID
+	node.GID = f.fi.GID
+	node.Links = f.fi.Links
+	node.AccessTime = f.fi.AccessTime
+	node.ChangeTime = f.fi.ChangeTime
+
+	if node.Type == restic.NodeTypeSymlink {
+		target, err := f.client.ReadLink(f.name)
+		if err != nil {
+			return nil, err
+		}
+		node.LinkTarget = target
+	}
+	return node, nil
+}
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	if f.f == nil {
+		return 0, pathError("read", f.name, os.ErrInvalid)
+	}
+	return f.f.Read(p)
+}
+
+func (f *sftpFile) Readdirnames(n int) ([]string, error) {
+	if n > 0 {
+		return nil, pathError("readdirnames", f.name, errors.New("not implemented"))
+	}
+
+	entries, err := f.client.ReadDir(f.name)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, fi := range entries {
+		names = append(names, fi.Name())
+	}
+	return names, nil
+}
+
+func (f *sftpFile) Close() error {
+	if f.f != nil {
+		return f.f.Close()
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package fs
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// testSFTP returns an SFTP file system which accesses the local file system
+// using an SFTP server running in the same process.
+func testSFTP(t *testing.T) *SFTP {
+	if runtime.GOOS == "windows" {
+		t.Skip("the SFTP server does not support windows paths")
+	}
+
+	clientConn, serverConn := net.Pipe()
+	server, err := sftp.NewServer(serverConn)
+	rtest.OK(t, err)
+	go func() {
+		_ = server.Serve()
+	}()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	rtest.OK(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return NewSFTP(client)
+}
+
+func TestSFTPFS(t *testing.T) {
+	fs := testSFTP(t)
+
+	dir := t.TempDir()
+	data := rtest.Random(23, 12345)
+	rtest.OK(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	rtest.OK(t, os.WriteFile(filepath.Join(dir, "sub", "file"), data, 0o640))
+	rtest.OK(t, os.Symlink("sub/file", filepath.Join(dir, "link")))
+
+	names, err := Readdirnames(fs, dir, O_NOFOLLOW)
+	rtest.OK(t, err)
+	sort.Strings(names)
+	rtest.Equals(t, []string{"link", "sub"}, names)
+
+	filename := fs.Join(dir, "sub", "file")
+	fi, err := fs.Lstat(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, "file", fi.Name)
+	rtest.Equals(t, int64(len(data)), fi.Size)
+	rtest.Equals(t, os.FileMode(0o640), fi.Mode)
+
+	// open metadata only and make readable afterwards
+	f, err := fs.OpenFile(filename, O_RDONLY|O_NOFOLLOW, true)
+	rtest.OK(t, err)
+	node, err := f.ToNode(false)
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.NodeTypeFile, node.Type)
+	rtest.Equals(t, uint64(len(data)), node.Size)
+	rtest.Equals(t, uint32(os.Getuid()), node.UID)
+
+	rtest.OK(t, f.MakeReadable())
+	buf, err := io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+	rtest.OK(t, f.Close())
+
+	// symlinks are not followed
+	linkname := fs.Join(dir, "link")
+	_, err = fs.OpenFile(linkname, O_RDONLY|O_NOFOLLOW, false)
+	rtest.Assert(t, err != nil, "opening symlink with O_NOFOLLOW did not fail")
+
+	f, err = fs.OpenFile(linkname, O_RDONLY|O_NOFOLLOW, true)
+	rtest.OK(t, err)
+	node, err = f.ToNode(false)
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.NodeTypeSymlink, node.Type)
+	rtest.Equals(t, "sub/file", node.LinkTarget)
+	rtest.OK(t, f.Close())
+
+	_, err = fs.OpenFile(filename, O_RDONLY|O_DIRECTORY, false)
+	rtest.Assert(t, err != nil, "opening file with O_DIRECTORY did not fail")
+
+	_, err = fs.Lstat(fs.Join(dir, "missing"))
+	rtest.Assert(t, os.IsNotExist(err), "unexpected error for missing file: %v", err)
+}
+
+func TestSFTPAbs(t *testing.T) {
+	fs := testSFTP(t)
+
+	wd, err := os.Getwd()
+	rtest.OK(t, err)
+
+	p, err := fs.Abs("foo/../bar")
+	rtest.OK(t, err)
+	rtest.Equals(t, filepath.ToSlash(filepath.Join(wd, "bar")), p)
+
+	p, err = fs.Abs("/foo/../bar")
+	rtest.OK(t, err)
+	rtest.Equals(t, "/bar", p)
+}
@@ -0,0 +1,196 @@
+package fs
+
+import (
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/restic"
+)
+
+// SourceConfig holds extended options for reading the files to back up.
+type SourceConfig struct {
+	IOTimeout time.Duration `option:"io-timeout" help:"skip a file if a single read or stat operation takes longer than the given duration, e.g. on a hanging network filesystem (default: disabled)" details:"Skipped files are reported as errors, such that the backup exits with exit code 3. The hanging operation keeps running in the background until it returns."`
+}
+
+func init() {
+	options.Register("source", SourceConfig{})
+}
+
+// ParseSourceConfig parses the source extended options.
+func ParseSourceConfig(o options.Options) (SourceConfig, error) {
+	var cfg SourceConfig
+	o = o.Extract("source")
+	if err := o.Apply("source", &cfg); err != nil {
+		return SourceConfig{}, err
+	}
+
+	if cfg.IOTimeout < 0 {
+		return SourceConfig{}, errors.Fatalf("invalid source.io-timeout %v, must not be negative", cfg.IOTimeout)
+	}
+	return cfg, nil
+}
+
+// ErrIOTimeout is returned if a filesystem operation did not complete in
+// time.
+var ErrIOTimeout = errors.New("I/O operation timed out")
+
+// Timeout is a wrapper around another file system which aborts each
+// operation that accesses the file system if it does not complete within the
+// timeout. An aborted operation keeps running in the background, as a
+// blocked system call cannot be interrupted. After an operation on a file has
+// timed out, all further operations on that file fail immediately.
+type Timeout struct {
+	FS
+	Timeout time.Duration
+}
+
+// statically ensure that Timeout implements FS.
+var _ FS = &Timeout{}
+
+// NewTimeout returns a file system which limits the duration of each
+// operation on fs to timeout.
+func NewTimeout(fs FS, timeout time.Duration) *Timeout {
+	return &Timeout{FS: fs, Timeout: timeout}
+}
+
+// withTimeout runs fn in a separate goroutine and waits at most timeout for it
+// to complete. If fn completes successfully after the timeout, its result is
+// passed to cleanup, unless cleanup is nil.
+func withTimeout[T any](timeout time.Duration, op, name string, fn func() (T, error), cleanup func(T)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	// buffered such that the goroutine can exit after a timeout
+	ch := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		ch <- result{v, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-ch:
+		return res.v, res.err
+	case <-timer.C:
+		debug.Log("%v %v timed out after %v", op, name, timeout)
+		if cleanup != nil {
+			go func() {
+				res := <-ch
+				if res.err == nil {
+					cleanup(res.v)
+				}
+			}()
+		}
+		var zero T
+		return zero, pathError(op, name, ErrIOTimeout)
+	}
+}
+
+// OpenFile wraps the OpenFile method of the underlying file system.
+func (fs *Timeout) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	f, err := withTimeout(fs.Timeout, "open", name, func() (File, error) {
+		return fs.FS.OpenFile(name, flag, metadataOnly)
+	}, func(f File) {
+		// nobody else will close a file which was opened too late
+		_ = f.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &timeoutFile{f: f, name: name, timeout: fs.Timeout}, nil
+}
+
+// Lstat wraps the Lstat method of the underlying file system.
+func (fs *Timeout) Lstat(name string) (*ExtendedFileInfo, error) {
+	return withTimeout(fs.Timeout, "lstat", name, func() (*ExtendedFileInfo, error) {
+		return fs.FS.Lstat(name)
+	}, nil)
+}
+
+type timeoutFile struct {
+	f       File
+	name    string
+	timeout time.Duration
+
+	// buf is only accessed by the goroutine running the current read
+	buf []byte
+	// failed is set once an operation has timed out. The operation may still
+	// be running and access f and buf.
+	failed bool
+}
+
+// See the File interface for a description of each method
+var _ File = &timeoutFile{}
+
+// do runs fn with a timeout. Once an operation has timed out, do fails
+// immediately.
+func do[T any](f *timeoutFile, op string, fn func() (T, error)) (T, error) {
+	if f.failed {
+		var zero T
+		return zero, pathError(op, f.name, ErrIOTimeout)
+	}
+
+	v, err := withTimeout(f.timeout, op, f.name, fn, nil)
+	if errors.Is(err, ErrIOTimeout) {
+		f.failed = true
+	}
+	return v, err
+}
+
+func (f *timeoutFile) MakeReadable() error {
+	_, err := do(f, "open", func() (struct{}, error) {
+		return struct{}{}, f.f.MakeReadable()
+	})
+	return err
+}
+
+func (f *timeoutFile) Read(p []byte) (int, error) {
+	// read into a separate buffer, as a timed out read may still write to it
+	// after Read has returned
+	if cap(f.buf) < len(p) {
+		f.buf = make([]byte, len(p))
+	}
+	buf := f.buf[:len(p)]
+
+	n, err := do(f, "read", func() (int, error) {
+		return f.f.Read(buf)
+	})
+	copy(p, buf[:n])
+	return n, err
+}
+
+func (f *timeoutFile) Readdirnames(n int) ([]string, error) {
+	return do(f, "readdirnames", func() ([]string, error) {
+		return f.f.Readdirnames(n)
+	})
+}
+
+func (f *timeoutFile) Stat() (*ExtendedFileInfo, error) {
+	return do(f, "stat", f.f.Stat)
+}
+
+func (f *timeoutFile) ToNode(ignoreXattrListError bool) (*restic.Node, error) {
+	return do(f, "stat", func() (*restic.Node, error) {
+		return f.f.ToNode(ignoreXattrListError)
+	})
+}
+
+func (f *timeoutFile) Close() error {
+	if f.failed {
+		// closing may block as well, don't wait for it
+		go func() {
+			_ = f.f.Close()
+		}()
+		return nil
+	}
+
+	_, err := do(f, "close", func() (struct{}, error) {
+		return struct{}{}, f.f.Close()
+	})
+	return err
+}
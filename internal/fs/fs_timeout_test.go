@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/options"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// hangingFS blocks all reads from the file named hang until release is
+// closed. Opening the file named hangOpen blocks until release is closed,
+// closing that file closes the channel closed.
+type hangingFS struct {
+	FS
+	hang     string
+	hangOpen string
+	release  chan struct{}
+	closed   chan struct{}
+}
+
+func (fs *hangingFS) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	if name == fs.hangOpen {
+		<-fs.release
+	}
+	f, err := fs.FS.OpenFile(name, flag, metadataOnly)
+	if err != nil {
+		return f, err
+	}
+	if name == fs.hangOpen {
+		return &notifyCloseFile{File: f, closed: fs.closed}, nil
+	}
+	if name != fs.hang {
+		return f, err
+	}
+	return &hangingFile{File: f, release: fs.release}, nil
+}
+
+type hangingFile struct {
+	File
+	release chan struct{}
+}
+
+func (f *hangingFile) Read(p []byte) (int, error) {
+	<-f.release
+	return f.File.Read(p)
+}
+
+// notifyCloseFile closes the channel closed when the file is closed.
+type notifyCloseFile struct {
+	File
+	closed chan struct{}
+}
+
+func (f *notifyCloseFile) Close() error {
+	close(f.closed)
+	return f.File.Close()
+}
+
+func TestTimeoutFS(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	bad := filepath.Join(dir, "bad")
+	rtest.OK(t, os.WriteFile(good, []byte("good data"), 0600))
+	rtest.OK(t, os.WriteFile(bad, []byte("bad data"), 0600))
+
+	hfs := &hangingFS{FS: Local{}, hang: bad, release: make(chan struct{})}
+	defer close(hfs.release)
+	fs := NewTimeout(hfs, 50*time.Millisecond)
+
+	verifyFileContentOpenFile(t, fs, good, []byte("good data"))
+
+	fi, err := fs.Lstat(bad)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(8), fi.Size)
+
+	f, err := fs.OpenFile(bad, O_RDONLY, false)
+	rtest.OK(t, err)
+
+	start := time.Now()
+	_, err = f.Read(make([]byte, 10))
+	rtest.Assert(t, errors.Is(err, ErrIOTimeout), "unexpected error %v", err)
+	rtest.Assert(t, time.Since(start) < 10*time.Second, "read was not aborted")
+
+	// all further operations fail immediately
+	_, err = io.ReadAll(f)
+	rtest.Assert(t, errors.Is(err, ErrIOTimeout), "unexpected error %v", err)
+	_, err = f.Stat()
+	rtest.Assert(t, errors.Is(err, ErrIOTimeout), "unexpected error %v", err)
+	rtest.OK(t, f.Close())
+
+	// other files are not affected
+	verifyFileContentOpenFile(t, fs, good, []byte("good data"))
+}
+
+func TestTimeoutFSCloseLateOpen(t *testing.T) {
+	dir := t.TempDir()
+	slow := filepath.Join(dir, "slow")
+	rtest.OK(t, os.WriteFile(slow, []byte("data"), 0600))
+
+	hfs := &hangingFS{FS: Local{}, hangOpen: slow, release: make(chan struct{}), closed: make(chan struct{})}
+	fs := NewTimeout(hfs, 50*time.Millisecond)
+
+	_, err := fs.OpenFile(slow, O_RDONLY, false)
+	rtest.Assert(t, errors.Is(err, ErrIOTimeout), "unexpected error %v", err)
+
+	// the file opened after the timeout must be closed
+	close(hfs.release)
+	select {
+	case <-hfs.closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("file opened after the timeout was not closed")
+	}
+}
+
+func TestParseSourceConfig(t *testing.T) {
+	cfg, err := ParseSourceConfig(options.Options{})
+	rtest.OK(t, err)
+	rtest.Equals(t, time.Duration(0), cfg.IOTimeout)
+
+	cfg, err = ParseSourceConfig(options.Options{"source.io-timeout": "60s"})
+	rtest.OK(t, err)
+	rtest.Equals(t, 60*time.Second, cfg.IOTimeout)
+
+	_, err = ParseSourceConfig(options.Options{"source.io-timeout": "-1s"})
+	rtest.Assert(t, err != nil, "negative timeout was accepted")
+
+	_, err = ParseSourceConfig(options.Options{"source.unknown": "1"})
+	rtest.Assert(t, err != nil, "unknown option was accepted")
+}
@@ -0,0 +1,172 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// MetadataProbe checks whether the metadata of a node could be applied to a
+// file system without restoring it. The checks are run against probe items
+// within a temporary directory and their results are cached, such that each
+// device type, owner and extended attribute is only tried once.
+type MetadataProbe struct {
+	dir    string
+	probes map[restic.NodeType]string
+	cache  map[string]error
+}
+
+// NewMetadataProbe creates a probe for the file system that will contain
+// target. The target does not have to exist yet, the checks are then run
+// within its nearest existing parent directory. An error is returned if no
+// temporary directory can be created there.
+func NewMetadataProbe(target string) (*MetadataProbe, error) {
+	dir, err := filepath.Abs(target)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for {
+		fi, err := os.Stat(fixpath(dir))
+		if err == nil && fi.IsDir() {
+			break
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, errors.WithStack(err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, errors.Errorf("no existing parent directory for %v", target)
+		}
+		dir = parent
+	}
+
+	tmp, err := os.MkdirTemp(dir, ".restic-preflight-")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot write to target")
+	}
+
+	return &MetadataProbe{
+		dir:    tmp,
+		probes: make(map[restic.NodeType]string),
+		cache:  make(map[string]error),
+	}, nil
+}
+
+// Close removes the temporary directory of the probe.
+func (p *MetadataProbe) Close() error {
+	return os.RemoveAll(p.dir)
+}
+
+// Check returns a description of each part of the metadata of node which
+// could not be applied. Timestamps and permissions are not checked, as the
+// owner of a file can always change them. The owner is only checked when
+// running as root.
+func (p *MetadataProbe) Check(node *restic.Node) []string {
+	var problems []string
+
+	switch node.Type {
+	case restic.NodeTypeDev, restic.NodeTypeCharDev, restic.NodeTypeFifo:
+		err := p.cached("create:"+string(node.Type), func() error {
+			return NodeCreateAt(node, filepath.Join(p.dir, "create-"+string(node.Type)))
+		})
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("cannot create %v: %v", nodeTypeDescription(node.Type), unwrapPathError(err)))
+			// without the item itself there is nothing to apply the metadata to
+			return problems
+		}
+	}
+
+	probe, err := p.probe(node.Type)
+	if err != nil {
+		return append(problems, fmt.Sprintf("cannot create probe item: %v", unwrapPathError(err)))
+	}
+
+	// restore ignores permission errors when not running as root, as
+	// regular users usually cannot change the owner of files
+	if os.Geteuid() <= 0 {
+		owner := fmt.Sprintf("%d:%d", node.UID, node.GID)
+		err = p.cached("owner:"+owner+":"+probe, func() error {
+			return lchown(probe, int(node.UID), int(node.GID))
+		})
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("cannot set owner %v: %v", owner, unwrapPathError(err)))
+		}
+	}
+
+	for _, attr := range node.ExtendedAttributes {
+		err := p.cached("xattr:"+attr.Name+":"+probe, func() error {
+			return probeExtendedAttribute(probe, attr.Name, attr.Value)
+		})
+		if err == nil {
+			continue
+		}
+		what := "extended attribute"
+		if strings.HasPrefix(attr.Name, "system.posix_acl_") {
+			what = "ACL"
+		}
+		problems = append(problems, fmt.Sprintf("cannot set %v %v: %v", what, attr.Name, unwrapPathError(err)))
+	}
+
+	return problems
+}
+
+func (p *MetadataProbe) cached(key string, fn func() error) error {
+	if err, ok := p.cache[key]; ok {
+		return err
+	}
+	err := fn()
+	p.cache[key] = err
+	return err
+}
+
+// probe returns the path of an item of a type similar to typ, on which the
+// owner and extended attributes can be tried.
+func (p *MetadataProbe) probe(typ restic.NodeType) (string, error) {
+	switch typ {
+	case restic.NodeTypeDir, restic.NodeTypeSymlink:
+	default:
+		typ = restic.NodeTypeFile
+	}
+	if path, ok := p.probes[typ]; ok {
+		return path, nil
+	}
+
+	path := filepath.Join(p.dir, "probe-"+string(typ))
+	node := &restic.Node{Type: typ, Mode: 0700 | os.ModeDir, LinkTarget: "probe"}
+	if err := NodeCreateAt(node, path); err != nil {
+		return "", err
+	}
+	p.probes[typ] = path
+	return path, nil
+}
+
+func nodeTypeDescription(typ restic.NodeType) string {
+	switch typ {
+	case restic.NodeTypeDev:
+		return "block device"
+	case restic.NodeTypeCharDev:
+		return "character device"
+	case restic.NodeTypeFifo:
+		return "named pipe"
+	}
+	return string(typ)
+}
+
+// unwrapPathError strips the path of the probe item from err, as it is
+// meaningless to the user.
+func unwrapPathError(err error) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return pe.Err
+	}
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		return le.Err
+	}
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !(darwin || freebsd || netbsd || linux || solaris)
+// +build !darwin,!freebsd,!netbsd,!linux,!solaris
+
+package fs
+
+// probeExtendedAttribute is a no-op, as extended attributes are either not
+// restored or stored in a different way on this platform.
+func probeExtendedAttribute(_, _ string, _ []byte) error {
+	return nil
+}
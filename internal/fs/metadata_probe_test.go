@@ -0,0 +1,77 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestMetadataProbe(t *testing.T) {
+	tempdir := t.TempDir()
+
+	// the target does not exist yet, the probe must use its existing parent
+	probe, err := NewMetadataProbe(filepath.Join(tempdir, "missing", "target"))
+	rtest.OK(t, err)
+
+	entries, err := os.ReadDir(tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(entries))
+	rtest.Assert(t, strings.HasPrefix(entries[0].Name(), ".restic-preflight-"), "unexpected probe directory %v", entries[0].Name())
+
+	for _, typ := range []restic.NodeType{restic.NodeTypeFile, restic.NodeTypeDir, restic.NodeTypeSymlink} {
+		node := &restic.Node{
+			Type:       typ,
+			Mode:       0755,
+			UID:        uint32(os.Getuid()),
+			GID:        uint32(os.Getgid()),
+			LinkTarget: "target",
+		}
+		rtest.Equals(t, []string(nil), probe.Check(node), "unexpected problems for "+string(typ))
+	}
+
+	if runtime.GOOS == "linux" {
+		// xattrs outside of the known namespaces are always rejected
+		node := &restic.Node{
+			Type: restic.NodeTypeFile,
+			UID:  uint32(os.Getuid()),
+			GID:  uint32(os.Getgid()),
+			ExtendedAttributes: []restic.ExtendedAttribute{
+				{Name: "restic.invalid", Value: []byte("x")},
+			},
+		}
+		problems := probe.Check(node)
+		rtest.Equals(t, 1, len(problems))
+		rtest.Assert(t, strings.HasPrefix(problems[0], "cannot set extended attribute restic.invalid: "), "unexpected problem %q", problems[0])
+		// the result is cached
+		rtest.Equals(t, problems, probe.Check(node))
+	}
+
+	rtest.OK(t, probe.Close())
+	entries, err = os.ReadDir(tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(entries))
+}
+
+func TestMetadataProbeOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("owners are not restored on Windows")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root can set any owner")
+	}
+
+	probe, err := NewMetadataProbe(t.TempDir())
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, probe.Close())
+	}()
+
+	// restore does not report that the owner cannot be set for regular users
+	problems := probe.Check(&restic.Node{Type: restic.NodeTypeFile, UID: 0, GID: 0})
+	rtest.Equals(t, []string(nil), problems)
+}
@@ -0,0 +1,21 @@
+//go:build darwin || freebsd || netbsd || linux || solaris
+// +build darwin freebsd netbsd linux solaris
+
+package fs
+
+import (
+	"github.com/restic/restic/internal/errors"
+
+	"github.com/pkg/xattr"
+)
+
+// probeExtendedAttribute tries to set an extended attribute on path. Unlike
+// setxattr, unsupported attributes are reported as an error.
+func probeExtendedAttribute(path, name string, value []byte) error {
+	err := xattr.LSet(path, name, value)
+	var xerr *xattr.Error
+	if errors.As(err, &xerr) {
+		return xerr.Err
+	}
+	return err
+}
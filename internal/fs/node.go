@@ -10,19 +10,20 @@ import (
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/restic"
 )
 
 // nodeFromFileInfo returns a new node from the given path and FileInfo. It
 // returns the first error that is encountered, together with a node.
-func nodeFromFileInfo(path string, fi *ExtendedFileInfo, ignoreXattrListError bool) (*restic.Node, error) {
+func nodeFromFileInfo(path string, fi *ExtendedFileInfo, ignoreXattrListError bool, noSecurityDescriptor bool) (*restic.Node, error) {
 	node := buildBasicNode(path, fi)
 
 	if err := nodeFillExtendedStat(node, path, fi); err != nil {
 		return node, err
 	}
 
-	err := nodeFillGenericAttributes(node, path, fi)
+	err := nodeFillGenericAttributes(node, path, fi, noSecurityDescriptor)
 	err = errors.Join(err, nodeFillExtendedAttributes(node, path, ignoreXattrListError))
 	return node, err
 }
@@ -71,6 +72,11 @@ func nodeFillExtendedStat(node *restic.Node, path string, stat *ExtendedFileInfo
 	node.DeviceID = stat.DeviceID
 	node.ChangeTime = stat.ChangeTime
 	node.AccessTime = stat.AccessTime
+	if feature.Flag.Enabled(feature.BirthTime) {
+		if btime, ok := birthTime(path, stat); ok {
+			node.BirthTime = &btime
+		}
+	}
 
 	node.UID = stat.UID
 	node.GID = stat.GID
@@ -295,5 +301,11 @@ func nodeRestoreTimestamps(node *restic.Node, path string) error {
 	if err := utimesNano(fixpath(path), atime, mtime, node.Type); err != nil {
 		return fmt.Errorf("failed to restore timestamp of %q: %w", path, err)
 	}
+
+	if node.BirthTime != nil && feature.Flag.Enabled(feature.BirthTime) {
+		if err := restoreBirthTime(path, *node.BirthTime, node.Type); err != nil {
+			return fmt.Errorf("failed to restore birth time of %q: %w", path, err)
+		}
+	}
 	return nil
 }
@@ -2,10 +2,13 @@ package fs
 
 import (
 	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -17,3 +20,21 @@ func TestRestoreSymlinkTimestampsError(t *testing.T) {
 	rtest.Assert(t, errors.Is(err, fs.ErrNotExist), "want ErrNotExist, got %q", err)
 	rtest.Assert(t, strings.Contains(err.Error(), d), "filename not in %q", err)
 }
+
+func TestNodeBirthTime(t *testing.T) {
+	defer feature.TestSetFlag(t, feature.Flag, feature.BirthTime, true)()
+
+	filename := filepath.Join(t.TempDir(), "file")
+	rtest.OK(t, os.WriteFile(filename, []byte("foo"), 0o600))
+
+	meta, err := (&Local{}).OpenFile(filename, O_NOFOLLOW, true)
+	rtest.OK(t, err)
+	node, err := meta.ToNode(false)
+	rtest.OK(t, err)
+	rtest.OK(t, meta.Close())
+
+	if node.BirthTime == nil {
+		t.Skip("filesystem does not record birth times")
+	}
+	rtest.Assert(t, !node.BirthTime.After(node.ModTime), "birth time %v is after mtime %v", node.BirthTime, node.ModTime)
+}
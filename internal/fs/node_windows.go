@@ -335,11 +335,17 @@ func decryptFile(pathPointer *uint16) error {
 }
 
 // nodeFillGenericAttributes fills in the generic attributes for windows like File Attributes,
-// Created time and Security Descriptors.
-func nodeFillGenericAttributes(node *restic.Node, path string, stat *ExtendedFileInfo) error {
+// Created time and Security Descriptors. Reading the security descriptor is
+// skipped if noSecurityDescriptor is set.
+func nodeFillGenericAttributes(node *restic.Node, path string, stat *ExtendedFileInfo, noSecurityDescriptor bool) error {
 	if strings.Contains(filepath.Base(path), ":") {
-		// Do not process for Alternate Data Streams in Windows
-		return nil
+		// Alternate Data Streams share all other attributes with their file
+		isADS := true
+		var err error
+		node.GenericAttributes, err = restic.WindowsAttrsToGenericAttributes(restic.WindowsAttributes{
+			AlternateDataStream: &isADS,
+		})
+		return err
 	}
 
 	isVolume, err := isVolumePath(path)
@@ -355,7 +361,7 @@ func nodeFillGenericAttributes(node *restic.Node, path string, stat *ExtendedFil
 	}
 
 	var sd *[]byte
-	if node.Type == restic.NodeTypeFile || node.Type == restic.NodeTypeDir {
+	if !noSecurityDescriptor && (node.Type == restic.NodeTypeFile || node.Type == restic.NodeTypeDir) {
 		if sd, err = getSecurityDescriptor(path); err != nil {
 			return err
 		}
@@ -6,6 +6,9 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// hasNoatimeFlag is set if setFlags can prevent access time updates.
+const hasNoatimeFlag = true
+
 // SetFlags tries to set the O_NOATIME flag on f, which prevents the kernel
 // from updating the atime on a read call.
 //
@@ -5,6 +5,9 @@ package fs
 
 import "os"
 
+// hasNoatimeFlag is set if setFlags can prevent access time updates.
+const hasNoatimeFlag = false
+
 // OS-specific replacements of setFlags can set file status flags
 // that improve I/O performance.
 func setFlags(*os.File) error {
@@ -24,6 +24,7 @@ type ExtendedFileInfo struct {
 	AccessTime time.Time // last access time stamp
 	ModTime    time.Time // last (content) modification time stamp
 	ChangeTime time.Time // last status change time stamp
+	BirthTime  time.Time // file creation time stamp, zero if not available
 
 	// nolint:unused // only used on Windows
 	sys any // Value returned by os.FileInfo.Sys()
@@ -31,6 +31,9 @@ func extendedStat(fi os.FileInfo) *ExtendedFileInfo {
 	mtime := syscall.NsecToTimespec(s.LastWriteTime.Nanoseconds())
 	extFI.ModTime = time.Unix(mtime.Unix())
 
+	btime := syscall.NsecToTimespec(s.CreationTime.Nanoseconds())
+	extFI.BirthTime = time.Unix(btime.Unix())
+
 	// Windows does not have the concept of a "change time" in the sense Unix uses it, so we're using the LastWriteTime here.
 	extFI.ChangeTime = extFI.ModTime
 
@@ -24,6 +24,12 @@ var _ = fs.NodeForgetter(&dir{})
 var _ = fs.NodeGetxattrer(&dir{})
 var _ = fs.NodeListxattrer(&dir{})
 var _ = fs.NodeStringLookuper(&dir{})
+var _ = fs.NodeCreater(&dir{})
+var _ = fs.NodeMkdirer(&dir{})
+var _ = fs.NodeRemover(&dir{})
+var _ = fs.NodeRenamer(&dir{})
+var _ = fs.NodeSetattrer(&dir{})
+var _ = fs.NodeSymlinker(&dir{})
 
 type dir struct {
 	root        *Root
@@ -34,6 +40,25 @@ type dir struct {
 	node        *restic.Node
 	m           sync.Mutex
 	cache       treeCache
+
+	// lower contains the entries of the snapshot. If the mount is writable,
+	// items additionally contains the entries of the staging directory.
+	lower  map[string]*restic.Node
+	opaque bool
+	// staging is the staging directory of the snapshot if d is the root
+	// directory of a snapshot, otherwise entry locates d in the staging
+	// directory. Both are unset for a read-only mount.
+	staging string
+	entry   *stagingEntry
+}
+
+// stagingPath returns the path of the directory in the staging directory, or
+// an empty string if the mount is read-only.
+func (d *dir) stagingPath() string {
+	if d.entry == nil {
+		return d.staging
+	}
+	return d.entry.path()
 }
 
 func cleanupNodeName(name string) string {
@@ -84,7 +109,7 @@ func replaceSpecialNodes(ctx context.Context, repo restic.BlobLoader, node *rest
 
 func newDirFromSnapshot(root *Root, forget forgetFn, inode uint64, snapshot *restic.Snapshot) (*dir, error) {
 	debug.Log("new dir for snapshot %v (%v)", snapshot.ID(), snapshot.Tree)
-	return &dir{
+	d := &dir{
 		root:   root,
 		forget: forget,
 		node: &restic.Node{
@@ -96,55 +121,115 @@ func newDirFromSnapshot(root *Root, forget forgetFn, inode uint64, snapshot *res
 		},
 		inode: inode,
 		cache: *newTreeCache(),
-	}, nil
+	}
+	if root.cfg.StagingDir != "" {
+		d.node.Mode = os.ModeDir | 0755
+		d.staging = filepath.Join(root.cfg.StagingDir, snapshot.ID().String())
+	}
+	return d, nil
 }
 
-func (d *dir) open(ctx context.Context) error {
+// open loads the entries of the directory unless they are already cached and
+// returns them. The returned map must not be modified.
+func (d *dir) open(ctx context.Context) (map[string]*restic.Node, error) {
 	d.m.Lock()
 	defer d.m.Unlock()
 
 	if d.items != nil {
-		return nil
+		return d.items, nil
+	}
+
+	if d.lower == nil {
+		debug.Log("open dir %v (%v)", d.node.Name, d.node.Subtree)
+
+		lower, err := d.loadTree(ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.lower = lower
+	}
+
+	staging := d.stagingPath()
+	if staging == "" {
+		d.items = d.lower
+		return d.items, nil
+	}
+
+	entries, whiteouts, opaque, err := readStagingDir(staging)
+	if err != nil {
+		debug.Log("  error reading staging dir %v: %v", staging, err)
+		return nil, err
+	}
+
+	items := make(map[string]*restic.Node)
+	if !opaque {
+		for name, node := range d.lower {
+			if _, ok := whiteouts[name]; !ok {
+				items[name] = node
+			}
+		}
+	}
+	for name, node := range entries {
+		// directories present in both the snapshot and the staging
+		// directory are merged
+		if lower, ok := items[name]; ok && lower.Type == restic.NodeTypeDir && node.Type == restic.NodeTypeDir {
+			continue
+		}
+		items[name] = node
 	}
+	d.items = items
+	d.opaque = opaque
+	return d.items, nil
+}
 
-	debug.Log("open dir %v (%v)", d.node.Name, d.node.Subtree)
+// loadTree returns the entries of the snapshot tree for the directory.
+// Directories which only exist in the staging directory have no tree.
+func (d *dir) loadTree(ctx context.Context) (map[string]*restic.Node, error) {
+	items := make(map[string]*restic.Node)
+	if d.node.Subtree == nil {
+		return items, nil
+	}
 
 	tree, err := restic.LoadTree(ctx, d.root.repo, *d.node.Subtree)
 	if err != nil {
 		debug.Log("  error loading tree %v: %v", d.node.Subtree, err)
-		return unwrapCtxCanceled(err)
+		return nil, unwrapCtxCanceled(err)
 	}
-	items := make(map[string]*restic.Node)
 	for _, n := range tree.Nodes {
 		if ctx.Err() != nil {
-			return ctx.Err()
+			return nil, ctx.Err()
 		}
 
 		nodes, err := replaceSpecialNodes(ctx, d.root.repo, n)
 		if err != nil {
 			debug.Log("  replaceSpecialNodes(%v) failed: %v", n, err)
-			return err
+			return nil, err
 		}
 		for _, node := range nodes {
 			items[cleanupNodeName(node.Name)] = node
 		}
 	}
-	d.items = items
-	return nil
+	return items, nil
 }
 
 func (d *dir) Attr(_ context.Context, a *fuse.Attr) error {
 	debug.Log("Attr()")
+	if staging := d.stagingPath(); staging != "" && pathExists(staging) {
+		err := stagedAttr(d.root, d.inode, staging, a)
+		a.Nlink = d.calcNumberOfLinks()
+		return err
+	}
+
 	a.Inode = d.inode
 	a.Mode = os.ModeDir | d.node.Mode
 
-	if !d.root.cfg.OwnerIsRoot {
-		a.Uid = d.node.UID
-		a.Gid = d.node.GID
-	}
+	d.root.setOwner(a, d.node.UID, d.node.GID)
 	a.Atime = d.node.AccessTime
 	a.Ctime = d.node.ChangeTime
 	a.Mtime = d.node.ModTime
+	if d.node.BirthTime != nil {
+		a.Crtime = *d.node.BirthTime
+	}
 
 	a.Nlink = d.calcNumberOfLinks()
 
@@ -154,6 +239,9 @@ func (d *dir) Attr(_ context.Context, a *fuse.Attr) error {
 func (d *dir) calcNumberOfLinks() uint32 {
 	// a directory d has 2 hardlinks + the number
 	// of directories contained by d
+	d.m.Lock()
+	defer d.m.Unlock()
+
 	count := uint32(2)
 	for _, node := range d.items {
 		if node.Type == restic.NodeTypeDir {
@@ -165,11 +253,11 @@ func (d *dir) calcNumberOfLinks() uint32 {
 
 func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	debug.Log("ReadDirAll()")
-	err := d.open(ctx)
+	items, err := d.open(ctx)
 	if err != nil {
 		return nil, err
 	}
-	ret := make([]fuse.Dirent, 0, len(d.items)+2)
+	ret := make([]fuse.Dirent, 0, len(items)+2)
 
 	ret = append(ret, fuse.Dirent{
 		Inode: d.inode,
@@ -183,7 +271,7 @@ func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 		Type:  fuse.DT_Dir,
 	})
 
-	for _, node := range d.items {
+	for _, node := range items {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
@@ -212,23 +300,47 @@ func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 func (d *dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	debug.Log("Lookup(%v)", name)
 
-	err := d.open(ctx)
-	if err != nil {
-		return nil, err
-	}
-
 	return d.cache.lookupOrCreate(name, func(forget forgetFn) (fs.Node, error) {
-		node, ok := d.items[name]
+		// the entries must be loaded while holding the cache lock, such
+		// that a concurrent invalidate cannot leave a stale node behind
+		items, err := d.open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		node, ok := items[name]
 		if !ok {
 			debug.Log("  Lookup(%v) -> not found", name)
 			return nil, syscall.ENOENT
 		}
 		inode := inodeFromNode(d.inode, node)
+
+		var entry *stagingEntry
+		if d.stagingPath() != "" {
+			entry = newStagingEntry(d, name)
+		}
+		if node.Type == restic.NodeTypeDir {
+			child, err := newDir(d.root, forget, inode, d.inode, node)
+			if err == nil {
+				child.entry = entry
+			}
+			return child, err
+		}
+		if path := entry.path(); path != "" && pathExists(path) {
+			switch node.Type {
+			case restic.NodeTypeFile:
+				return newStagedFile(d.root, forget, inode, entry)
+			case restic.NodeTypeSymlink:
+				return newStagedLink(d.root, forget, inode, entry)
+			}
+		}
+
 		switch node.Type {
-		case restic.NodeTypeDir:
-			return newDir(d.root, forget, inode, d.inode, node)
 		case restic.NodeTypeFile:
-			return newFile(d.root, forget, inode, node)
+			f, err := newFile(d.root, forget, inode, node)
+			if err == nil {
+				f.staging = entry
+			}
+			return f, err
 		case restic.NodeTypeSymlink:
 			return newLink(d.root, forget, inode, node)
 		case restic.NodeTypeDev, restic.NodeTypeCharDev, restic.NodeTypeFifo, restic.NodeTypeSocket:
@@ -252,3 +364,336 @@ func (d *dir) Getxattr(_ context.Context, req *fuse.GetxattrRequest, resp *fuse.
 func (d *dir) Forget() {
 	d.forget()
 }
+
+// invalidate discards the cached entries of the directory after the entry
+// name was modified in the staging directory.
+func (d *dir) invalidate(name string) {
+	d.m.Lock()
+	d.items = nil
+	d.m.Unlock()
+	d.cache.remove(name)
+}
+
+// ensureStaging creates the staging directory for d and its parents and
+// returns its path.
+func (d *dir) ensureStaging() (string, error) {
+	staging := d.stagingPath()
+	if staging == "" {
+		return "", syscall.EROFS
+	}
+	if pathExists(staging) {
+		return staging, nil
+	}
+
+	if d.entry == nil {
+		return staging, os.MkdirAll(staging, 0755)
+	}
+	if _, err := d.entry.parent().ensureStaging(); err != nil {
+		return "", err
+	}
+	err := os.Mkdir(staging, d.node.Mode.Perm()|0700)
+	if os.IsExist(err) {
+		// created concurrently
+		err = nil
+	}
+	return staging, err
+}
+
+// prepareEntry creates the staging directory and removes a whiteout for
+// name. It returns the path of the staging directory and reports whether a
+// whiteout was removed.
+func (d *dir) prepareEntry(ctx context.Context, name string) (string, bool, error) {
+	if err := checkStagingName(name); err != nil {
+		return "", false, err
+	}
+	staging, err := d.ensureStaging()
+	if err != nil {
+		return "", false, err
+	}
+	items, err := d.open(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if _, exists := items[name]; exists {
+		return "", false, syscall.EEXIST
+	}
+
+	err = os.Remove(whiteoutPath(staging, name))
+	if os.IsNotExist(err) {
+		return staging, false, nil
+	}
+	return staging, err == nil, err
+}
+
+// lowerEntry returns the snapshot entry name if it is not hidden by the
+// staging directory.
+func (d *dir) lowerEntry(ctx context.Context, name string) (*restic.Node, error) {
+	items, err := d.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	if _, ok := items[name]; !ok {
+		return nil, syscall.ENOENT
+	}
+	if d.opaque {
+		return nil, nil
+	}
+	return d.lower[name], nil
+}
+
+// isEmpty reports whether the directory has no entries.
+func (d *dir) isEmpty(ctx context.Context) (bool, error) {
+	items, err := d.open(ctx)
+	return len(items) == 0, err
+}
+
+func (d *dir) Create(ctx context.Context, req *fuse.CreateRequest, _ *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	debug.Log("Create(%v)", req.Name)
+	staging, _, err := d.prepareEntry(ctx, req.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flags := os.O_CREATE | int(req.Flags)&(syscall.O_ACCMODE|syscall.O_EXCL|syscall.O_TRUNC)
+	f, err := os.OpenFile(filepath.Join(staging, req.Name), flags, req.Mode.Perm()&^req.Umask.Perm())
+	if err != nil {
+		return nil, nil, err
+	}
+	d.invalidate(req.Name)
+
+	node, err := d.Lookup(ctx, req.Name)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	return node, &stagedHandle{f: f}, nil
+}
+
+func (d *dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	debug.Log("Mkdir(%v)", req.Name)
+	staging, removedWhiteout, err := d.prepareEntry(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(staging, req.Name)
+	err = os.Mkdir(path, 0700)
+	if err != nil {
+		return nil, err
+	}
+	// hide the content of a deleted snapshot directory with the same name
+	if removedWhiteout {
+		err = os.WriteFile(filepath.Join(path, opaqueMarker), nil, 0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = os.Chmod(path, req.Mode.Perm()&^req.Umask.Perm())
+	if err != nil {
+		return nil, err
+	}
+	d.invalidate(req.Name)
+
+	return d.Lookup(ctx, req.Name)
+}
+
+func (d *dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	debug.Log("Symlink(%v -> %v)", req.NewName, req.Target)
+	staging, _, err := d.prepareEntry(ctx, req.NewName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Symlink(req.Target, filepath.Join(staging, req.NewName))
+	if err != nil {
+		return nil, err
+	}
+	d.invalidate(req.NewName)
+
+	return d.Lookup(ctx, req.NewName)
+}
+
+func (d *dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	debug.Log("Remove(%v)", req.Name)
+	if d.stagingPath() == "" {
+		return syscall.EROFS
+	}
+
+	lower, err := d.lowerEntry(ctx, req.Name)
+	if err != nil {
+		return err
+	}
+
+	child, err := d.Lookup(ctx, req.Name)
+	if err != nil {
+		return err
+	}
+	childDir, isDir := child.(*dir)
+	if req.Dir && !isDir {
+		return syscall.ENOTDIR
+	}
+	if !req.Dir && isDir {
+		return syscall.EISDIR
+	}
+	if isDir {
+		empty, err := childDir.isEmpty(ctx)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return syscall.ENOTEMPTY
+		}
+	}
+
+	staging, err := d.ensureStaging()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(staging, req.Name)
+	if pathExists(path) {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	if lower != nil {
+		if err := os.WriteFile(whiteoutPath(staging, req.Name), nil, 0600); err != nil {
+			return err
+		}
+	}
+	stagingEntryOf(child).detach()
+	d.invalidate(req.Name)
+	return nil
+}
+
+func (d *dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	debug.Log("Rename(%v -> %v)", req.OldName, req.NewName)
+	target, ok := newDir.(*dir)
+	if !ok || d.stagingPath() == "" || target.stagingPath() == "" {
+		return syscall.EXDEV
+	}
+	if err := checkStagingName(req.NewName); err != nil {
+		return err
+	}
+
+	lower, err := d.lowerEntry(ctx, req.OldName)
+	if err != nil {
+		return err
+	}
+	if target == d && req.OldName == req.NewName {
+		return nil
+	}
+	// Directories from the snapshot cannot be moved without copying their
+	// whole content. Returning EXDEV makes tools like mv fall back to
+	// copying the directory.
+	if lower != nil && lower.Type == restic.NodeTypeDir {
+		return syscall.EXDEV
+	}
+
+	node, err := d.Lookup(ctx, req.OldName)
+	if err != nil {
+		return err
+	}
+	_, isDir := node.(*dir)
+
+	// an existing target is replaced, a directory only if it is empty
+	replaced, err := target.Lookup(ctx, req.NewName)
+	if err != nil && err != syscall.ENOENT {
+		return err
+	}
+	replacedDir, replacesDir := replaced.(*dir)
+	switch {
+	case replaced == nil:
+	case isDir && !replacesDir:
+		return syscall.ENOTDIR
+	case !isDir && replacesDir:
+		return syscall.EISDIR
+	case replacesDir:
+		empty, err := replacedDir.isEmpty(ctx)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return syscall.ENOTEMPTY
+		}
+	}
+
+	staging, err := d.ensureStaging()
+	if err != nil {
+		return err
+	}
+	src := filepath.Join(staging, req.OldName)
+	if lower != nil {
+		switch lower.Type {
+		case restic.NodeTypeFile:
+			err = stageFile(ctx, d.root, lower, src)
+		case restic.NodeTypeSymlink:
+			if !pathExists(src) {
+				err = os.Symlink(lower.LinkTarget, src)
+			}
+		default:
+			err = syscall.EXDEV
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	targetStaging, err := target.ensureStaging()
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(targetStaging, req.NewName)
+	removedWhiteout := true
+	if err := os.Remove(whiteoutPath(targetStaging, req.NewName)); os.IsNotExist(err) {
+		removedWhiteout = false
+	} else if err != nil {
+		return err
+	}
+	if replacesDir {
+		// only whiteouts remain of the replaced directory
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	// hide the content of a directory from the snapshot which was replaced
+	// or removed before
+	if isDir && (replacesDir || removedWhiteout) {
+		if err := os.WriteFile(filepath.Join(dst, opaqueMarker), nil, 0600); err != nil {
+			return err
+		}
+	}
+	if lower != nil {
+		if err := os.WriteFile(whiteoutPath(staging, req.OldName), nil, 0600); err != nil {
+			return err
+		}
+	}
+
+	// the kernel continues to use the node under the new name
+	if replaced != nil {
+		stagingEntryOf(replaced).detach()
+	}
+	stagingEntryOf(node).move(target, req.NewName)
+	d.invalidate(req.OldName)
+	target.invalidate(req.NewName)
+	if stagingEntryOf(node) != nil {
+		target.cache.add(req.NewName, node)
+	}
+	return nil
+}
+
+func (d *dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	staging, err := d.ensureStaging()
+	if err != nil {
+		return err
+	}
+	if err := setStagedAttr(staging, req); err != nil {
+		return err
+	}
+	return d.Attr(ctx, &resp.Attr)
+}
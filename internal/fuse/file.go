@@ -6,6 +6,7 @@ package fuse
 import (
 	"context"
 	"sort"
+	"syscall"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
@@ -24,12 +25,18 @@ var _ = fs.NodeForgetter(&file{})
 var _ = fs.NodeGetxattrer(&file{})
 var _ = fs.NodeListxattrer(&file{})
 var _ = fs.NodeOpener(&file{})
+var _ = fs.NodeFsyncer(&file{})
+var _ = fs.NodeSetattrer(&file{})
 
 type file struct {
 	root   *Root
 	forget forgetFn
 	node   *restic.Node
 	inode  uint64
+
+	// staging is the location of the file in the staging directory. The
+	// file is copied there before it is modified.
+	staging *stagingEntry
 }
 
 type openFile struct {
@@ -50,6 +57,10 @@ func newFile(root *Root, forget forgetFn, inode uint64, node *restic.Node) (fuse
 
 func (f *file) Attr(_ context.Context, a *fuse.Attr) error {
 	debug.Log("Attr(%v)", f.node.Name)
+	if path := f.staging.path(); path != "" && pathExists(path) {
+		return stagedAttr(f.root, f.inode, path, a)
+	}
+
 	a.Inode = f.inode
 	a.Mode = f.node.Mode
 	a.Size = f.node.Size
@@ -57,21 +68,51 @@ func (f *file) Attr(_ context.Context, a *fuse.Attr) error {
 	a.BlockSize = blockSize
 	a.Nlink = uint32(f.node.Links)
 
-	if !f.root.cfg.OwnerIsRoot {
-		a.Uid = f.node.UID
-		a.Gid = f.node.GID
-	}
+	f.root.setOwner(a, f.node.UID, f.node.GID)
 	a.Atime = f.node.AccessTime
 	a.Ctime = f.node.ChangeTime
 	a.Mtime = f.node.ModTime
+	if f.node.BirthTime != nil {
+		a.Crtime = *f.node.BirthTime
+	}
 
 	return nil
 
 }
 
-func (f *file) Open(ctx context.Context, _ *fuse.OpenRequest, _ *fuse.OpenResponse) (fs.Handle, error) {
+// stage copies the file to the staging directory unless it is already there
+// and returns its path.
+func (f *file) stage(ctx context.Context) (string, error) {
+	parent := f.staging.parent()
+	if parent == nil {
+		return "", syscall.EROFS
+	}
+	if _, err := parent.ensureStaging(); err != nil {
+		return "", err
+	}
+	path := f.staging.path()
+	if path == "" {
+		return "", syscall.EROFS
+	}
+	return path, stageFile(ctx, f.root, f.node, path)
+}
+
+func (f *file) Open(ctx context.Context, req *fuse.OpenRequest, _ *fuse.OpenResponse) (fs.Handle, error) {
 	debug.Log("open file %v with %d blobs", f.node.Name, len(f.node.Content))
 
+	if path := f.staging.path(); path != "" {
+		if !req.Flags.IsReadOnly() {
+			var err error
+			path, err = f.stage(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if pathExists(path) {
+			return openStaged(path, req.Flags)
+		}
+	}
+
 	var bytes uint64
 	cumsize := make([]uint64, 1+len(f.node.Content))
 	for i, id := range f.node.Content {
@@ -176,6 +217,21 @@ func (f *file) Getxattr(_ context.Context, req *fuse.GetxattrRequest, resp *fuse
 	return nodeGetXattr(f.node, req, resp)
 }
 
+func (f *file) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	path, err := f.stage(ctx)
+	if err != nil {
+		return err
+	}
+	if err := setStagedAttr(path, req); err != nil {
+		return err
+	}
+	return f.Attr(ctx, &resp.Attr)
+}
+
+func (f *file) Fsync(_ context.Context, _ *fuse.FsyncRequest) error {
+	return nil
+}
+
 func (f *file) Forget() {
 	f.forget()
 }
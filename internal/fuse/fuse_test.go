@@ -6,6 +6,7 @@ package fuse
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"math/rand"
 	"os"
 	"strings"
@@ -182,11 +183,12 @@ func TestTopUIDGID(t *testing.T) {
 	repo := repository.TestRepository(t)
 	restic.TestCreateSnapshot(t, repo, time.Unix(1460289341, 207401672), 0)
 
-	testTopUIDGID(t, Config{}, repo, uint32(os.Getuid()), uint32(os.Getgid()))
-	testTopUIDGID(t, Config{OwnerIsRoot: true}, repo, 0, 0)
+	testTopUIDGID(t, Config{}, repo, uint32(os.Getuid()), uint32(os.Getgid()), 0, 0)
+	testTopUIDGID(t, Config{OwnerIsRoot: true}, repo, 0, 0, 0, 0)
+	testTopUIDGID(t, Config{OwnerIsCurrentUser: true}, repo, uint32(os.Getuid()), uint32(os.Getgid()), uint32(os.Getuid()), uint32(os.Getgid()))
 }
 
-func testTopUIDGID(t *testing.T, cfg Config, repo restic.Repository, uid, gid uint32) {
+func testTopUIDGID(t *testing.T, cfg Config, repo restic.Repository, uid, gid, snapshotUID, snapshotGID uint32) {
 	t.Helper()
 
 	ctx := context.Background()
@@ -210,11 +212,50 @@ func testTopUIDGID(t *testing.T, cfg Config, repo restic.Repository, uid, gid ui
 	snapshotdir, err := idsdir.(fs.NodeStringLookuper).Lookup(ctx, snapID)
 	rtest.OK(t, err)
 
-	// restic.TestCreateSnapshot does not set the UID/GID thus it must be always zero
+	// restic.TestCreateSnapshot does not set the UID/GID thus it is zero
+	// unless the owner is mapped to the current user
 	err = snapshotdir.Attr(ctx, &attr)
 	rtest.OK(t, err)
-	rtest.Equals(t, uint32(0), attr.Uid)
-	rtest.Equals(t, uint32(0), attr.Gid)
+	rtest.Equals(t, snapshotUID, attr.Uid)
+	rtest.Equals(t, snapshotGID, attr.Gid)
+}
+
+func TestCurrentUserMode(t *testing.T) {
+	for _, test := range []struct {
+		mode, want os.FileMode
+	}{
+		{0644, 0600},
+		{0600, 0600},
+		{0400, 0400},
+		{0040, 0400},
+		{0000, 0400},
+		{0755, 0700},
+		{0750, 0700},
+		{0004 | 0001, 0500},
+		{os.ModeSetuid | 0755, 0700},
+		{os.ModeDir | 0750, os.ModeDir | 0700},
+		{os.ModeDir | 0000, os.ModeDir | 0500},
+		{os.ModeDir | os.ModeSticky | 0777, os.ModeDir | 0700},
+		{os.ModeSymlink | 0777, os.ModeSymlink | 0777},
+		{os.ModeNamedPipe | 0620, os.ModeNamedPipe | 0600},
+	} {
+		rtest.Equals(t, test.want, currentUserMode(test.mode), fmt.Sprintf("mode %v", test.mode))
+	}
+}
+
+func TestFuseDirCurrentUser(t *testing.T) {
+	repo := repository.TestRepository(t)
+	root := &Root{repo: repo, blobCache: bloblru.New(blobCacheSize), cfg: Config{OwnerIsCurrentUser: true}, uid: 1000, gid: 100}
+
+	node := &restic.Node{Mode: 0750, UID: 42, GID: 43}
+	d, err := newDir(root, func() {}, inodeFromName(1, "foo"), inodeFromName(0, "parent"), node)
+	rtest.OK(t, err)
+
+	attr := fuse.Attr{}
+	rtest.OK(t, d.Attr(context.TODO(), &attr))
+	rtest.Equals(t, uint32(1000), attr.Uid)
+	rtest.Equals(t, uint32(100), attr.Gid)
+	rtest.Equals(t, os.ModeDir|0700, attr.Mode)
 }
 
 // The Lookup method must return the same Node object unless it was forgotten in the meantime
@@ -324,6 +365,32 @@ func TestLink(t *testing.T) {
 	rtest.Assert(t, err != nil, "missing error on reading invalid xattr")
 }
 
+func TestOtherXattr(t *testing.T) {
+	acl := []byte{2, 0, 0, 0, 1, 0, 6, 0, 255, 255, 255, 255}
+	node := &restic.Node{Name: "null", Type: restic.NodeTypeCharDev, Links: 1, ExtendedAttributes: []restic.ExtendedAttribute{
+		{Name: "security.selinux", Value: []byte("system_u:object_r:null_device_t:s0")},
+		{Name: "system.posix_acl_access", Value: acl},
+	}}
+
+	other, err := newOther(&Root{}, func() {}, 42, node)
+	rtest.OK(t, err)
+
+	exp := &fuse.ListxattrResponse{}
+	exp.Append("security.selinux", "system.posix_acl_access")
+	resp := &fuse.ListxattrResponse{}
+	rtest.OK(t, other.Listxattr(context.TODO(), &fuse.ListxattrRequest{}, resp))
+	rtest.Equals(t, exp.Xattr, resp.Xattr)
+
+	for _, attr := range node.ExtendedAttributes {
+		getResp := &fuse.GetxattrResponse{}
+		rtest.OK(t, other.Getxattr(context.TODO(), &fuse.GetxattrRequest{Name: attr.Name}, getResp))
+		rtest.Equals(t, attr.Value, getResp.Xattr)
+	}
+
+	err = other.Getxattr(context.TODO(), &fuse.GetxattrRequest{Name: "user.invalid"}, nil)
+	rtest.Assert(t, err == fuse.ErrNoXattr, "unexpected error %v", err)
+}
+
 var sink uint64
 
 func BenchmarkInode(b *testing.B) {
@@ -13,6 +13,8 @@ import (
 
 // Statically ensure that *other implements the given interface
 var _ = fs.NodeForgetter(&other{})
+var _ = fs.NodeGetxattrer(&other{})
+var _ = fs.NodeListxattrer(&other{})
 var _ = fs.NodeReadlinker(&other{})
 
 type other struct {
@@ -34,10 +36,7 @@ func (l *other) Attr(_ context.Context, a *fuse.Attr) error {
 	a.Inode = l.inode
 	a.Mode = l.node.Mode
 
-	if !l.root.cfg.OwnerIsRoot {
-		a.Uid = l.node.UID
-		a.Gid = l.node.GID
-	}
+	l.root.setOwner(a, l.node.UID, l.node.GID)
 	a.Atime = l.node.AccessTime
 	a.Ctime = l.node.ChangeTime
 	a.Mtime = l.node.ModTime
@@ -47,6 +46,15 @@ func (l *other) Attr(_ context.Context, a *fuse.Attr) error {
 	return nil
 }
 
+func (l *other) Listxattr(_ context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	nodeToXattrList(l.node, req, resp)
+	return nil
+}
+
+func (l *other) Getxattr(_ context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	return nodeGetXattr(l.node, req, resp)
+}
+
 func (l *other) Forget() {
 	l.forget()
 }
@@ -5,20 +5,31 @@ package fuse
 
 import (
 	"os"
+	"sync"
 
 	"github.com/restic/restic/internal/bloblru"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/restic"
 
+	"github.com/anacrolix/fuse"
 	"github.com/anacrolix/fuse/fs"
 )
 
 // Config holds settings for the fuse mount.
 type Config struct {
-	OwnerIsRoot   bool
+	OwnerIsRoot bool
+	// OwnerIsCurrentUser presents all files and directories as owned by the
+	// user running the mount, with permissions which allow this user to read
+	// them. It takes precedence over OwnerIsRoot.
+	OwnerIsCurrentUser bool
+
 	Filter        restic.SnapshotFilter
 	TimeTemplate  string
 	PathTemplates []string
+
+	// StagingDir enables writes to snapshots, which are stored in the
+	// given directory. The mount is read-only if it is empty.
+	StagingDir string
 }
 
 // Root is the root node of the fuse mount of a repository.
@@ -26,6 +37,8 @@ type Root struct {
 	repo      restic.Repository
 	cfg       Config
 	blobCache *bloblru.Cache
+	// stageMu serializes copying files to the staging directory
+	stageMu sync.Mutex
 
 	*SnapshotsDir
 
@@ -51,7 +64,7 @@ func NewRoot(repo restic.Repository, cfg Config) *Root {
 		blobCache: bloblru.New(blobCacheSize),
 	}
 
-	if !cfg.OwnerIsRoot {
+	if !cfg.OwnerIsRoot || cfg.OwnerIsCurrentUser {
 		root.uid = uint32(os.Getuid())
 		root.gid = uint32(os.Getgid())
 	}
@@ -63,6 +76,7 @@ func NewRoot(repo restic.Repository, cfg Config) *Root {
 			"snapshots/%T",
 			"hosts/%h/%T",
 			"tags/%t/%T",
+			"aliases/%a",
 		}
 	}
 
@@ -76,3 +90,39 @@ func (r *Root) Root() (fs.Node, error) {
 	debug.Log("Root()")
 	return r, nil
 }
+
+// setOwner sets the owner of a, whose Mode must already be set, for an item
+// owned by uid and gid according to the configuration.
+func (r *Root) setOwner(a *fuse.Attr, uid, gid uint32) {
+	switch {
+	case r.cfg.OwnerIsCurrentUser:
+		a.Uid = r.uid
+		a.Gid = r.gid
+		a.Mode = currentUserMode(a.Mode)
+	case r.cfg.OwnerIsRoot:
+		// keep the zero values
+	default:
+		a.Uid = uid
+		a.Gid = gid
+	}
+}
+
+// currentUserMode returns the mode presented for an item which is mapped to
+// the current user. The owner may read all files and traverse all
+// directories, write and execute permissions are granted if any of owner,
+// group or others had them. All other permissions are removed.
+func currentUserMode(mode os.FileMode) os.FileMode {
+	if mode&os.ModeSymlink != 0 {
+		return mode
+	}
+
+	perm := mode.Perm()
+	userPerm := os.FileMode(0400)
+	if perm&0222 != 0 {
+		userPerm |= 0200
+	}
+	if mode.IsDir() || perm&0111 != 0 {
+		userPerm |= 0100
+	}
+	return mode&^(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) | userPerm
+}
@@ -55,9 +55,9 @@ func NewSnapshotsDirStructure(root *Root, pathTemplates []string, timeTemplate s
 }
 
 // pathsFromSn generates the paths from pathTemplate and timeTemplate
-// where the variables are replaced by the snapshot data.
+// where the variables are replaced by the snapshot data and its aliases.
 // The time is given as suffix if the pathTemplate ends with "%T".
-func pathsFromSn(pathTemplate string, timeTemplate string, sn *restic.Snapshot) (paths []string, timeSuffix string) {
+func pathsFromSn(pathTemplate string, timeTemplate string, sn *restic.Snapshot, aliases []string) (paths []string, timeSuffix string) {
 	timeformat := sn.Time.Format(timeTemplate)
 
 	inVerb := false
@@ -90,23 +90,27 @@ func pathsFromSn(pathTemplate string, timeTemplate string, sn *restic.Snapshot)
 			writeTime = true
 			continue
 
-		case 't':
-			if len(sn.Tags) == 0 {
+		case 't', 'a':
+			names := sn.Tags
+			if c == 'a' {
+				names = aliases
+			}
+			if len(names) == 0 {
 				return nil, ""
 			}
-			if len(sn.Tags) != 1 {
+			if len(names) != 1 {
 				// needs special treatment: Rebuild the string builders
-				newout := make([]strings.Builder, len(out)*len(sn.Tags))
-				for i, tag := range sn.Tags {
-					tag = filenameFromTag(tag)
+				newout := make([]strings.Builder, len(out)*len(names))
+				for i, name := range names {
+					name = filenameFromTag(name)
 					for j := range out {
-						newout[i*len(out)+j].WriteString(out[j].String() + tag)
+						newout[i*len(out)+j].WriteString(out[j].String() + name)
 					}
 				}
 				out = newout
 				continue
 			}
-			repl = sn.Tags[0]
+			repl = names[0]
 
 		case 'i':
 			repl = sn.ID().Str()
@@ -207,7 +211,7 @@ func uniqueName(entries map[string]*MetaDirData, prefix, name string) string {
 // makeDirs inserts all paths generated from pathTemplates and
 // TimeTemplate for all given snapshots into d.names.
 // Also adds d.latest links if "%T" is at end of a path template
-func (d *SnapshotsDirStructure) makeDirs(snapshots restic.Snapshots) {
+func (d *SnapshotsDirStructure) makeDirs(snapshots restic.Snapshots, aliases restic.SnapshotAliases) {
 	entries := make(map[string]*MetaDirData)
 
 	type mountData struct {
@@ -260,7 +264,7 @@ func (d *SnapshotsDirStructure) makeDirs(snapshots restic.Snapshots) {
 	latestTime := make(map[string]time.Time)
 	for _, sn := range snapshots {
 		for _, templ := range d.pathTemplates {
-			paths, timeSuffix := pathsFromSn(templ, d.timeTemplate, sn)
+			paths, timeSuffix := pathsFromSn(templ, d.timeTemplate, sn, aliases.Names(*sn.ID()))
 			for _, p := range paths {
 				if p != "" {
 					p = "/" + p
@@ -314,10 +318,20 @@ func (d *SnapshotsDirStructure) updateSnapshots(ctx context.Context) error {
 		return si.Time.Before(sj.Time)
 	})
 
-	// We update the snapshots when the hash of their id's changes.
+	aliases, err := restic.LoadSnapshotAliases(ctx, d.root.repo)
+	if err != nil {
+		return err
+	}
+
+	// We update the snapshots when the hash of their id's or their aliases
+	// changes.
 	h := sha256.New()
 	for _, sn := range snapshots {
 		h.Write(sn.ID()[:])
+		for _, name := range aliases.Names(*sn.ID()) {
+			h.Write([]byte(name))
+			h.Write([]byte{0})
+		}
 	}
 	var hash [sha256.Size]byte
 	h.Sum(hash[:0])
@@ -334,7 +348,7 @@ func (d *SnapshotsDirStructure) updateSnapshots(ctx context.Context) error {
 
 	d.lastCheck = time.Now()
 	d.hash = hash
-	d.makeDirs(snapshots)
+	d.makeDirs(snapshots, aliases)
 	return nil
 }
 
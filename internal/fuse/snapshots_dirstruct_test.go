@@ -21,37 +21,45 @@ func TestPathsFromSn(t *testing.T) {
 	var p []string
 	var s string
 
-	p, s = pathsFromSn("ids/%i", "2006-01-02T15:04:05", sn1)
+	p, s = pathsFromSn("ids/%i", "2006-01-02T15:04:05", sn1, nil)
 	test.Equals(t, []string{"ids/12345678"}, p)
 	test.Equals(t, "", s)
 
-	p, s = pathsFromSn("snapshots/%T", "2006-01-02T15:04:05", sn1)
+	p, s = pathsFromSn("snapshots/%T", "2006-01-02T15:04:05", sn1, nil)
 	test.Equals(t, []string{"snapshots/"}, p)
 	test.Equals(t, "2021-01-01T00:00:01", s)
 
-	p, s = pathsFromSn("hosts/%h/%T", "2006-01-02T15:04:05", sn1)
+	p, s = pathsFromSn("hosts/%h/%T", "2006-01-02T15:04:05", sn1, nil)
 	test.Equals(t, []string{"hosts/host/"}, p)
 	test.Equals(t, "2021-01-01T00:00:01", s)
 
-	p, s = pathsFromSn("tags/%t/%T", "2006-01-02T15:04:05", sn1)
+	p, s = pathsFromSn("tags/%t/%T", "2006-01-02T15:04:05", sn1, nil)
 	test.Equals(t, []string{"tags/tag1/", "tags/tag2/"}, p)
 	test.Equals(t, "2021-01-01T00:00:01", s)
 
-	p, s = pathsFromSn("users/%u/%T", "2006-01-02T15:04:05", sn1)
+	p, s = pathsFromSn("users/%u/%T", "2006-01-02T15:04:05", sn1, nil)
 	test.Equals(t, []string{"users/user/"}, p)
 	test.Equals(t, "2021-01-01T00:00:01", s)
 
-	p, s = pathsFromSn("longids/%I", "2006-01-02T15:04:05", sn1)
+	p, s = pathsFromSn("longids/%I", "2006-01-02T15:04:05", sn1, nil)
 	test.Equals(t, []string{"longids/1234567812345678123456781234567812345678123456781234567812345678"}, p)
 	test.Equals(t, "", s)
 
-	p, s = pathsFromSn("%T/%h", "2006/01/02", sn1)
+	p, s = pathsFromSn("%T/%h", "2006/01/02", sn1, nil)
 	test.Equals(t, []string{"2021/01/01/host"}, p)
 	test.Equals(t, "", s)
 
-	p, s = pathsFromSn("%T/%i", "2006/01", sn1)
+	p, s = pathsFromSn("%T/%i", "2006/01", sn1, nil)
 	test.Equals(t, []string{"2021/01/12345678"}, p)
 	test.Equals(t, "", s)
+
+	p, s = pathsFromSn("aliases/%a", "2006-01-02T15:04:05", sn1, nil)
+	test.Equals(t, []string(nil), p)
+	test.Equals(t, "", s)
+
+	p, s = pathsFromSn("aliases/%a", "2006-01-02T15:04:05", sn1, []string{"current", "golden-master"})
+	test.Equals(t, []string{"aliases/current", "aliases/golden-master"}, p)
+	test.Equals(t, "", s)
 }
 
 func TestMakeDirs(t *testing.T) {
@@ -85,7 +93,7 @@ func TestMakeDirs(t *testing.T) {
 	sn3 := &restic.Snapshot{Hostname: "host", Username: "user2", Tags: []string{}, Time: time3}
 	restic.TestSetSnapshotID(t, sn3, id3)
 
-	sds.makeDirs(restic.Snapshots{sn0, sn1, sn2, sn3})
+	sds.makeDirs(restic.Snapshots{sn0, sn1, sn2, sn3}, nil)
 
 	expNames := make(map[string]*restic.Snapshot)
 	expLatest := make(map[string]string)
@@ -257,7 +265,7 @@ func TestMakeEmptyDirs(t *testing.T) {
 		pathTemplates: pathTemplates,
 		timeTemplate:  timeTemplate,
 	}
-	sds.makeDirs(restic.Snapshots{})
+	sds.makeDirs(restic.Snapshots{}, nil)
 
 	expNames := make(map[string]*restic.Snapshot)
 	expLatest := make(map[string]string)
@@ -0,0 +1,418 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package fuse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
+)
+
+// Writes to a snapshot are stored in a staging directory which is layered
+// over the snapshot. Each snapshot uses a separate subdirectory named after
+// the snapshot ID. Entries in the staging directory take precedence over the
+// snapshot content. Deleted entries are recorded using whiteout files, a
+// directory containing the opaque marker hides all snapshot entries.
+const (
+	whiteoutPrefix = ".wh."
+	opaqueMarker   = whiteoutPrefix + whiteoutPrefix + ".opq"
+	// stagingTempDir is the directory below the staging directory in which
+	// files are copied before they are moved to their final location
+	stagingTempDir = ".tmp"
+)
+
+// Statically ensure that the staged nodes implement the given interfaces
+var _ = fs.HandleReader(&stagedHandle{})
+var _ = fs.HandleReleaser(&stagedHandle{})
+var _ = fs.HandleWriter(&stagedHandle{})
+var _ = fs.NodeForgetter(&stagedFile{})
+var _ = fs.NodeFsyncer(&stagedFile{})
+var _ = fs.NodeOpener(&stagedFile{})
+var _ = fs.NodeSetattrer(&stagedFile{})
+var _ = fs.NodeForgetter(&stagedLink{})
+var _ = fs.NodeReadlinker(&stagedLink{})
+
+func whiteoutPath(dir, name string) string {
+	return filepath.Join(dir, whiteoutPrefix+name)
+}
+
+// checkStagingName returns an error if name cannot be used for a new entry,
+// as it would be mistaken for a whiteout.
+func checkStagingName(name string) error {
+	if strings.HasPrefix(name, whiteoutPrefix) {
+		return syscall.EINVAL
+	}
+	return nil
+}
+
+type stagingRef struct {
+	parent *dir
+	name   string
+}
+
+// stagingEntry is the location of a node in the staging directory, relative
+// to its parent directory. The location is updated when the node is renamed,
+// as the kernel continues to use the node under its new name.
+type stagingEntry struct {
+	ref atomic.Pointer[stagingRef]
+}
+
+func newStagingEntry(parent *dir, name string) *stagingEntry {
+	e := &stagingEntry{}
+	e.move(parent, name)
+	return e
+}
+
+// stagingEntryOf returns the location of n in the staging directory, or nil
+// if n cannot be staged.
+func stagingEntryOf(n fs.Node) *stagingEntry {
+	switch n := n.(type) {
+	case *dir:
+		return n.entry
+	case *file:
+		return n.staging
+	case *stagedFile:
+		return n.entry
+	case *stagedLink:
+		return n.entry
+	}
+	return nil
+}
+
+// move changes the location of the node after it was renamed.
+func (e *stagingEntry) move(parent *dir, name string) {
+	if e != nil {
+		e.ref.Store(&stagingRef{parent: parent, name: name})
+	}
+}
+
+// detach marks the node as removed, it no longer refers to any path in the
+// staging directory.
+func (e *stagingEntry) detach() {
+	if e != nil {
+		e.ref.Store(&stagingRef{})
+	}
+}
+
+// parent returns the directory containing the node, or nil if the node was
+// removed.
+func (e *stagingEntry) parent() *dir {
+	if e == nil {
+		return nil
+	}
+	return e.ref.Load().parent
+}
+
+// path returns the path of the node in the staging directory. It returns an
+// empty string if e is nil, that is if the mount is read-only, or if the
+// node was removed.
+func (e *stagingEntry) path() string {
+	if e == nil {
+		return ""
+	}
+	ref := e.ref.Load()
+	if ref.parent == nil {
+		return ""
+	}
+	parent := ref.parent.stagingPath()
+	if parent == "" {
+		return ""
+	}
+	return filepath.Join(parent, ref.name)
+}
+
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// stagedNode returns a node describing the file at path.
+func stagedNode(path string, name string) (*restic.Node, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &restic.Node{
+		Name:       name,
+		Mode:       fi.Mode(),
+		ModTime:    fi.ModTime(),
+		AccessTime: fi.ModTime(),
+		ChangeTime: fi.ModTime(),
+		Links:      1,
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		node.UID = stat.Uid
+		node.GID = stat.Gid
+	}
+
+	switch {
+	case fi.IsDir():
+		node.Type = restic.NodeTypeDir
+	case fi.Mode()&os.ModeSymlink != 0:
+		node.Type = restic.NodeTypeSymlink
+		node.LinkTarget, err = os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+	case fi.Mode().IsRegular():
+		node.Type = restic.NodeTypeFile
+		node.Size = uint64(fi.Size())
+	default:
+		node.Type = restic.NodeTypeFifo
+	}
+	return node, nil
+}
+
+// readStagingDir returns the entries of the staging directory and the names
+// of the entries hidden by a whiteout. opaque reports whether the directory
+// hides all entries of the snapshot.
+func readStagingDir(path string) (entries map[string]*restic.Node, whiteouts map[string]struct{}, opaque bool, err error) {
+	entries = make(map[string]*restic.Node)
+	whiteouts = make(map[string]struct{})
+
+	dirents, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return entries, whiteouts, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	for _, dirent := range dirents {
+		name := dirent.Name()
+		if name == opaqueMarker {
+			opaque = true
+			continue
+		}
+		if strings.HasPrefix(name, whiteoutPrefix) {
+			whiteouts[strings.TrimPrefix(name, whiteoutPrefix)] = struct{}{}
+			continue
+		}
+
+		node, err := stagedNode(filepath.Join(path, name), name)
+		if os.IsNotExist(err) {
+			// removed in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, nil, false, err
+		}
+		entries[name] = node
+	}
+	return entries, whiteouts, opaque, nil
+}
+
+// stageFile copies the content of a file from the snapshot to path, unless
+// path already exists. The file is only moved to path once it is complete,
+// such that concurrent lookups never see a partial copy.
+func stageFile(ctx context.Context, root *Root, node *restic.Node, path string) error {
+	root.stageMu.Lock()
+	defer root.stageMu.Unlock()
+	if pathExists(path) {
+		return nil
+	}
+	debug.Log("stage %v to %v", node.Name, path)
+
+	tempDir := filepath.Join(root.cfg.StagingDir, stagingTempDir)
+	if err := os.MkdirAll(tempDir, 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(tempDir, "stage-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// no-op once the file was moved
+		_ = os.Remove(f.Name())
+	}()
+
+	for _, id := range node.Content {
+		buf, err := root.blobCache.GetOrCompute(id, func() ([]byte, error) {
+			return root.repo.LoadBlob(ctx, restic.DataBlob, id, nil)
+		})
+		if err == nil {
+			_, err = f.Write(buf)
+		}
+		if err != nil {
+			_ = f.Close()
+			return unwrapCtxCanceled(err)
+		}
+	}
+
+	if err := f.Chmod(node.Mode.Perm() | 0600); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(f.Name(), node.AccessTime, node.ModTime); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// setStagedAttr applies the attribute changes of req to the file at path.
+func setStagedAttr(path string, req *fuse.SetattrRequest) error {
+	if req.Valid.Size() {
+		if err := os.Truncate(path, int64(req.Size)); err != nil {
+			return err
+		}
+	}
+	if req.Valid.Mode() {
+		if err := os.Chmod(path, req.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	if req.Valid.Atime() || req.Valid.Mtime() {
+		fi, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		atime, mtime := fi.ModTime(), fi.ModTime()
+		if req.Valid.Atime() {
+			atime = req.Atime
+		}
+		if req.Valid.Mtime() {
+			mtime = req.Mtime
+		}
+		if err := os.Chtimes(path, atime, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stagedAttr fills a with the attributes of the file at path.
+func stagedAttr(root *Root, inode uint64, path string, a *fuse.Attr) error {
+	node, err := stagedNode(path, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	a.Inode = inode
+	a.Mode = node.Mode
+	a.Size = node.Size
+	if node.Type == restic.NodeTypeSymlink {
+		a.Size = uint64(len(node.LinkTarget))
+	}
+	a.Blocks = (a.Size + blockSize - 1) / blockSize
+	a.BlockSize = blockSize
+	a.Nlink = 1
+
+	root.setOwner(a, node.UID, node.GID)
+	a.Atime = node.AccessTime
+	a.Ctime = node.ChangeTime
+	a.Mtime = node.ModTime
+	return nil
+}
+
+// openStaged opens the file at path for the given flags.
+func openStaged(path string, flags fuse.OpenFlags) (*stagedHandle, error) {
+	// offsets for appending writes are passed by the kernel, WriteAt refuses
+	// to work with files opened using O_APPEND
+	mode := int(flags) & (syscall.O_ACCMODE | syscall.O_TRUNC)
+	f, err := os.OpenFile(path, mode, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &stagedHandle{f: f}, nil
+}
+
+// stagedFile is a regular file stored in the staging directory.
+type stagedFile struct {
+	root   *Root
+	forget forgetFn
+	inode  uint64
+	entry  *stagingEntry
+}
+
+func newStagedFile(root *Root, forget forgetFn, inode uint64, entry *stagingEntry) (*stagedFile, error) {
+	return &stagedFile{root: root, forget: forget, inode: inode, entry: entry}, nil
+}
+
+func (f *stagedFile) Attr(_ context.Context, a *fuse.Attr) error {
+	return stagedAttr(f.root, f.inode, f.entry.path(), a)
+}
+
+func (f *stagedFile) Open(_ context.Context, req *fuse.OpenRequest, _ *fuse.OpenResponse) (fs.Handle, error) {
+	path := f.entry.path()
+	debug.Log("open staged file %v", path)
+	return openStaged(path, req.Flags)
+}
+
+func (f *stagedFile) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if err := setStagedAttr(f.entry.path(), req); err != nil {
+		return err
+	}
+	return f.Attr(ctx, &resp.Attr)
+}
+
+func (f *stagedFile) Fsync(_ context.Context, _ *fuse.FsyncRequest) error {
+	return nil
+}
+
+func (f *stagedFile) Forget() {
+	f.forget()
+}
+
+type stagedHandle struct {
+	f *os.File
+}
+
+func (h *stagedHandle) Read(_ context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	buf := resp.Data[:req.Size]
+	n, err := h.f.ReadAt(buf, req.Offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	resp.Data = buf[:n]
+	return nil
+}
+
+func (h *stagedHandle) Write(_ context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	n, err := h.f.WriteAt(req.Data, req.Offset)
+	resp.Size = n
+	return err
+}
+
+func (h *stagedHandle) Release(_ context.Context, _ *fuse.ReleaseRequest) error {
+	return h.f.Close()
+}
+
+// stagedLink is a symlink stored in the staging directory.
+type stagedLink struct {
+	root   *Root
+	forget forgetFn
+	inode  uint64
+	entry  *stagingEntry
+}
+
+func newStagedLink(root *Root, forget forgetFn, inode uint64, entry *stagingEntry) (*stagedLink, error) {
+	return &stagedLink{root: root, forget: forget, inode: inode, entry: entry}, nil
+}
+
+func (l *stagedLink) Attr(_ context.Context, a *fuse.Attr) error {
+	return stagedAttr(l.root, l.inode, l.entry.path(), a)
+}
+
+func (l *stagedLink) Readlink(_ context.Context, _ *fuse.ReadlinkRequest) (string, error) {
+	return os.Readlink(l.entry.path())
+}
+
+func (l *stagedLink) Forget() {
+	l.forget()
+}
@@ -0,0 +1,226 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package fuse
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testDirNames(t testing.TB, d fs.Node) []string {
+	t.Helper()
+	entries, err := d.(fs.HandleReadDirAller).ReadDirAll(context.TODO())
+	rtest.OK(t, err)
+
+	var names []string
+	for _, e := range entries {
+		if e.Name != "." && e.Name != ".." {
+			names = append(names, e.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testReadAll(t testing.TB, n fs.Node) []byte {
+	t.Helper()
+	h, err := n.(fs.NodeOpener).Open(context.TODO(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, nil)
+	rtest.OK(t, err)
+
+	var attr fuse.Attr
+	rtest.OK(t, n.Attr(context.TODO(), &attr))
+
+	buf := make([]byte, attr.Size)
+	testRead(t, h, 0, int(attr.Size), buf)
+	if r, ok := h.(fs.HandleReleaser); ok {
+		rtest.OK(t, r.Release(context.TODO(), nil))
+	}
+	return buf
+}
+
+func testWrite(t testing.TB, h fs.Handle, offset int64, data string) {
+	t.Helper()
+	resp := &fuse.WriteResponse{}
+	rtest.OK(t, h.(fs.HandleWriter).Write(context.TODO(), &fuse.WriteRequest{Offset: offset, Data: []byte(data)}, resp))
+	rtest.Equals(t, len(data), resp.Size)
+}
+
+func testRemoveContent(t testing.TB, d *dir) {
+	t.Helper()
+	for _, name := range testDirNames(t, d) {
+		n, err := d.Lookup(context.TODO(), name)
+		rtest.OK(t, err)
+		sub, isDir := n.(*dir)
+		if isDir {
+			testRemoveContent(t, sub)
+		}
+		rtest.OK(t, d.Remove(context.TODO(), &fuse.RemoveRequest{Name: name, Dir: isDir}))
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStagingOverlay(t *testing.T) {
+	ctx := context.TODO()
+	repo := repository.TestRepository(t)
+	restic.TestCreateSnapshot(t, repo, time.Unix(1460289341, 207401672), 2)
+	sn := loadFirstSnapshot(t, repo)
+
+	staging := t.TempDir()
+	root := NewRoot(repo, Config{StagingDir: staging})
+	snapshotDir, err := newDirFromSnapshot(root, func() {}, 2, sn)
+	rtest.OK(t, err)
+
+	names := testDirNames(t, snapshotDir)
+	rtest.Assert(t, contains(names, "dir-0"), "missing dir-0 in %v", names)
+
+	subdir, err := snapshotDir.Lookup(ctx, "dir-0")
+	rtest.OK(t, err)
+	d := subdir.(*dir)
+	original, err := d.Lookup(ctx, "file-2")
+	rtest.OK(t, err)
+	content := testReadAll(t, original)
+
+	// modifying a snapshot file stores a copy in the staging directory
+	h, err := original.(fs.NodeOpener).Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadWrite}, nil)
+	rtest.OK(t, err)
+	testWrite(t, h, 0, "modified")
+	rtest.OK(t, h.(fs.HandleReleaser).Release(ctx, nil))
+
+	stagedPath := filepath.Join(staging, sn.ID().String(), "dir-0", "file-2")
+	buf, err := os.ReadFile(stagedPath)
+	rtest.OK(t, err)
+	rtest.Equals(t, append([]byte("modified"), content[len("modified"):]...), buf)
+	rtest.Equals(t, buf, testReadAll(t, original))
+
+	// create a new file
+	node, h, err := d.Create(ctx, &fuse.CreateRequest{Name: "new", Flags: fuse.OpenReadWrite, Mode: 0644}, &fuse.CreateResponse{})
+	rtest.OK(t, err)
+	testWrite(t, h, 0, "hello")
+	rtest.OK(t, h.(fs.HandleReleaser).Release(ctx, nil))
+	rtest.Equals(t, []byte("hello"), testReadAll(t, node))
+	rtest.Assert(t, contains(testDirNames(t, d), "new"), "missing new file")
+
+	_, _, err = d.Create(ctx, &fuse.CreateRequest{Name: "file-2", Mode: 0644}, &fuse.CreateResponse{})
+	rtest.Assert(t, err == syscall.EEXIST, "expected EEXIST, got %v", err)
+
+	// rename a file from the snapshot
+	rtest.OK(t, d.Rename(ctx, &fuse.RenameRequest{OldName: "file-2", NewName: "renamed"}, d))
+	names = testDirNames(t, d)
+	rtest.Assert(t, !contains(names, "file-2") && contains(names, "renamed"), "unexpected entries %v", names)
+
+	// remove a file from the snapshot
+	var removed string
+	for _, name := range names {
+		if name != "new" && name != "renamed" {
+			n, err := d.Lookup(ctx, name)
+			rtest.OK(t, err)
+			if _, ok := n.(*file); ok {
+				removed = name
+				break
+			}
+		}
+	}
+	rtest.Assert(t, removed != "", "no file to remove in %v", names)
+	rtest.OK(t, d.Remove(ctx, &fuse.RemoveRequest{Name: removed}))
+	rtest.Assert(t, !contains(testDirNames(t, d), removed), "removed file %v still exists", removed)
+	_, err = d.Lookup(ctx, removed)
+	rtest.Assert(t, err == syscall.ENOENT, "expected ENOENT, got %v", err)
+
+	// removing and recreating a snapshot directory hides its old content
+	rtest.Equals(t, syscall.ENOTEMPTY, snapshotDir.Remove(ctx, &fuse.RemoveRequest{Name: "dir-0", Dir: true}))
+	testRemoveContent(t, d)
+	rtest.OK(t, snapshotDir.Remove(ctx, &fuse.RemoveRequest{Name: "dir-0", Dir: true}))
+	rtest.Assert(t, !contains(testDirNames(t, snapshotDir), "dir-0"), "dir-0 still exists")
+
+	newDir, err := snapshotDir.Mkdir(ctx, &fuse.MkdirRequest{Name: "dir-0", Mode: os.ModeDir | 0755})
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(testDirNames(t, newDir)))
+
+	// changes are kept when mounting the staging directory again
+	root = NewRoot(repo, Config{StagingDir: staging})
+	snapshotDir, err = newDirFromSnapshot(root, func() {}, 2, sn)
+	rtest.OK(t, err)
+	subdir, err = snapshotDir.Lookup(ctx, "dir-0")
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(testDirNames(t, subdir)))
+
+	// without a staging directory, the snapshot cannot be modified
+	root = NewRoot(repo, Config{})
+	snapshotDir, err = newDirFromSnapshot(root, func() {}, 2, sn)
+	rtest.OK(t, err)
+	_, err = snapshotDir.Mkdir(ctx, &fuse.MkdirRequest{Name: "foo", Mode: os.ModeDir | 0755})
+	rtest.Equals(t, syscall.EROFS, err)
+	rtest.Assert(t, contains(testDirNames(t, snapshotDir), "dir-0"), "missing dir-0")
+}
+
+func TestStagingRename(t *testing.T) {
+	ctx := context.TODO()
+	repo := repository.TestRepository(t)
+	restic.TestCreateSnapshot(t, repo, time.Unix(1460289341, 207401672), 2)
+	sn := loadFirstSnapshot(t, repo)
+
+	root := NewRoot(repo, Config{StagingDir: t.TempDir()})
+	snapshotDir, err := newDirFromSnapshot(root, func() {}, 2, sn)
+	rtest.OK(t, err)
+
+	// names which would be mistaken for whiteouts are rejected
+	_, err = snapshotDir.Mkdir(ctx, &fuse.MkdirRequest{Name: ".wh.dir-0", Mode: os.ModeDir | 0755})
+	rtest.Equals(t, syscall.EINVAL, err)
+	_, _, err = snapshotDir.Create(ctx, &fuse.CreateRequest{Name: ".wh.dir-0", Mode: 0644}, &fuse.CreateResponse{})
+	rtest.Equals(t, syscall.EINVAL, err)
+
+	// a renamed node remains usable under its new name
+	node, h, err := snapshotDir.Create(ctx, &fuse.CreateRequest{Name: "a", Flags: fuse.OpenReadWrite, Mode: 0644}, &fuse.CreateResponse{})
+	rtest.OK(t, err)
+	testWrite(t, h, 0, "hello")
+	rtest.OK(t, h.(fs.HandleReleaser).Release(ctx, nil))
+	rtest.OK(t, snapshotDir.Rename(ctx, &fuse.RenameRequest{OldName: "a", NewName: "b"}, snapshotDir))
+	rtest.Equals(t, []byte("hello"), testReadAll(t, node))
+	_, err = snapshotDir.Lookup(ctx, "a")
+	rtest.Equals(t, syscall.ENOENT, err)
+	rtest.Equals(t, syscall.EINVAL, snapshotDir.Rename(ctx, &fuse.RenameRequest{OldName: "b", NewName: ".wh.b"}, snapshotDir))
+
+	// a directory only replaces an empty directory of the snapshot
+	moved, err := snapshotDir.Mkdir(ctx, &fuse.MkdirRequest{Name: "new", Mode: os.ModeDir | 0755})
+	rtest.OK(t, err)
+	rtest.Equals(t, syscall.ENOTEMPTY, snapshotDir.Rename(ctx, &fuse.RenameRequest{OldName: "new", NewName: "dir-0"}, snapshotDir))
+	rtest.Equals(t, syscall.ENOTDIR, snapshotDir.Rename(ctx, &fuse.RenameRequest{OldName: "new", NewName: "b"}, snapshotDir))
+	lower, err := snapshotDir.Lookup(ctx, "dir-0")
+	rtest.OK(t, err)
+	testRemoveContent(t, lower.(*dir))
+	rtest.OK(t, snapshotDir.Rename(ctx, &fuse.RenameRequest{OldName: "new", NewName: "dir-0"}, snapshotDir))
+
+	names := testDirNames(t, snapshotDir)
+	rtest.Assert(t, !contains(names, "new") && contains(names, "dir-0"), "unexpected entries %v", names)
+	replaced, err := snapshotDir.Lookup(ctx, "dir-0")
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(testDirNames(t, replaced)))
+
+	// entries created using the moved node appear at the new location
+	_, err = moved.(*dir).Mkdir(ctx, &fuse.MkdirRequest{Name: "inner", Mode: os.ModeDir | 0755})
+	rtest.OK(t, err)
+	rtest.Equals(t, moved, replaced)
+	rtest.Equals(t, []string{"inner"}, testDirNames(t, replaced))
+}
@@ -30,11 +30,15 @@ func (t *treeCache) lookupOrCreate(name string, create func(forget forgetFn) (fs
 		return node, nil
 	}
 
+	var node fs.Node
 	node, err := create(func() {
 		t.m.Lock()
 		defer t.m.Unlock()
 
-		delete(t.nodes, name)
+		// the node may have been moved to another name in the meantime
+		if t.nodes[name] == node {
+			delete(t.nodes, name)
+		}
 	})
 	if err != nil {
 		return nil, err
@@ -43,3 +47,20 @@ func (t *treeCache) lookupOrCreate(name string, create func(forget forgetFn) (fs
 	t.nodes[name] = node
 	return node, nil
 }
+
+// remove drops the node for name from the cache, for example after the
+// entry was replaced.
+func (t *treeCache) remove(name string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	delete(t.nodes, name)
+}
+
+// add stores node for name, for example after the node was moved to name.
+func (t *treeCache) add(name string, node fs.Node) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	t.nodes[name] = node
+}
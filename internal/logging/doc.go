@@ -0,0 +1,3 @@
+// Package logging provides leveled and structured logging, which can be
+// enabled separately for each module.
+package logging
@@ -0,0 +1,268 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/ui"
+)
+
+// Options configure the log output.
+type Options struct {
+	// Levels contains a comma-separated list of log levels, either for a
+	// module, e.g. "repository=debug", or as the default for all other
+	// modules, e.g. "info". Logging is disabled if Levels is empty.
+	Levels string
+	// File is the log file, the log is written to stderr if File is empty.
+	File string
+	// JSON selects JSON instead of text output.
+	JSON bool
+	// MaxSize is the size in bytes at which the log file is rotated, zero
+	// disables rotation.
+	MaxSize int64
+	// MaxFiles is the number of rotated log files which are kept.
+	MaxFiles int
+}
+
+// DefaultMaxFiles is the number of rotated log files kept by default.
+const DefaultMaxFiles = 5
+
+// OptionsFromEnv returns the options set in the environment variables
+// RESTIC_LOG, RESTIC_LOG_FILE, RESTIC_LOG_FORMAT, RESTIC_LOG_MAX_SIZE and
+// RESTIC_LOG_MAX_FILES.
+func OptionsFromEnv() (Options, error) {
+	opts := Options{
+		Levels:   os.Getenv("RESTIC_LOG"),
+		File:     os.Getenv("RESTIC_LOG_FILE"),
+		MaxFiles: DefaultMaxFiles,
+	}
+
+	switch format := os.Getenv("RESTIC_LOG_FORMAT"); format {
+	case "", "text":
+	case "json":
+		opts.JSON = true
+	default:
+		return Options{}, errors.Fatalf("invalid RESTIC_LOG_FORMAT %q, must be text or json", format)
+	}
+
+	if s := os.Getenv("RESTIC_LOG_MAX_SIZE"); s != "" {
+		size, err := ui.ParseBytes(s)
+		if err != nil || size < 0 {
+			return Options{}, errors.Fatalf("invalid RESTIC_LOG_MAX_SIZE %q", s)
+		}
+		opts.MaxSize = size
+	}
+
+	if s := os.Getenv("RESTIC_LOG_MAX_FILES"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return Options{}, errors.Fatalf("invalid RESTIC_LOG_MAX_FILES %q, must be a positive number", s)
+		}
+		opts.MaxFiles = n
+	}
+
+	return opts, nil
+}
+
+// levels contains the configured log levels.
+type levels struct {
+	modules map[string]slog.Level
+	// fallback is the level for all modules not contained in modules
+	fallback slog.Level
+}
+
+// disabled is higher than all log levels
+const disabled = slog.Level(1 << 20)
+
+func parseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	case "off", "none":
+		return disabled, nil
+	}
+	return 0, errors.Fatalf("invalid log level %q, must be one of debug, info, warn, error or off", s)
+}
+
+func parseLevels(s string) (levels, error) {
+	l := levels{modules: make(map[string]slog.Level), fallback: disabled}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		module, levelName, found := strings.Cut(item, "=")
+		if !found {
+			module, levelName = "", item
+		}
+		level, err := parseLevel(strings.TrimSpace(levelName))
+		if err != nil {
+			return levels{}, err
+		}
+
+		module = strings.Trim(strings.TrimSpace(module), "/")
+		if module == "" || module == "*" {
+			l.fallback = level
+		} else {
+			l.modules[module] = level
+		}
+	}
+
+	return l, nil
+}
+
+// level returns the log level of a module. Modules are hierarchical, thus
+// the level of "backend" also applies to "backend/s3", unless it is
+// configured separately.
+func (l levels) level(module string) slog.Level {
+	for {
+		if level, ok := l.modules[module]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(module, '/')
+		if i < 0 {
+			return l.fallback
+		}
+		module = module[:i]
+	}
+}
+
+type config struct {
+	levels  levels
+	handler slog.Handler
+}
+
+var current atomic.Pointer[config]
+
+// Setup configures the log output of all loggers, including those created
+// before Setup was called. The returned function closes the log file.
+func Setup(opts Options) (close func() error, err error) {
+	levels, err := parseLevels(opts.Levels)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = os.Stderr
+	close = func() error { return nil }
+	if opts.File != "" {
+		f, err := newRotatingFile(opts.File, opts.MaxSize, opts.MaxFiles)
+		if err != nil {
+			return nil, err
+		}
+		w, close = f, f.Close
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler
+	if opts.JSON {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+
+	current.Store(&config{levels: levels, handler: handler})
+	return close, nil
+}
+
+// Logger returns the logger for a module. Submodules are separated by a
+// slash, e.g. "backend/s3". Logging has no effect unless it is enabled for
+// the module using Setup or the debug log is enabled.
+func Logger(module string) *slog.Logger {
+	return slog.New(&moduleHandler{module: module})
+}
+
+// moduleHandler passes records to the handler configured by Setup if the
+// level is enabled for the module, and to the debug log if it is enabled.
+type moduleHandler struct {
+	module string
+	// goas contains the groups and attributes added via WithGroup and
+	// WithAttrs, in order.
+	goas []groupOrAttrs
+}
+
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+func (h *moduleHandler) enabled(level slog.Level) bool {
+	cfg := current.Load()
+	return cfg != nil && level >= cfg.levels.level(h.module)
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return debug.Enabled() || h.enabled(level)
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if debug.Enabled() {
+		var buf bytes.Buffer
+		th := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				// the debug log contains its own timestamp
+				if len(groups) == 0 && a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})
+		_ = h.apply(th).Handle(ctx, r)
+		debug.LogAt(r.PC, "%s", strings.TrimSuffix(buf.String(), "\n"))
+	}
+
+	if !h.enabled(r.Level) {
+		return nil
+	}
+	return h.apply(current.Load().handler).Handle(ctx, r)
+}
+
+// apply returns a handler which adds the module name and the groups and
+// attributes of h to all records.
+func (h *moduleHandler) apply(handler slog.Handler) slog.Handler {
+	handler = handler.WithAttrs([]slog.Attr{slog.String("module", h.module)})
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			handler = handler.WithGroup(goa.group)
+		} else {
+			handler = handler.WithAttrs(goa.attrs)
+		}
+	}
+	return handler
+}
+
+func (h *moduleHandler) with(goa groupOrAttrs) *moduleHandler {
+	h2 := *h
+	h2.goas = append(h.goas[:len(h.goas):len(h.goas)], goa)
+	return &h2
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseLevels(t *testing.T) {
+	l, err := parseLevels("warn, repository=debug,backend=info,backend/s3=off")
+	rtest.OK(t, err)
+	for module, level := range map[string]slog.Level{
+		"archiver":          slog.LevelWarn,
+		"repository":        slog.LevelDebug,
+		"repository/index":  slog.LevelDebug,
+		"backend":           slog.LevelInfo,
+		"backend/retry":     slog.LevelInfo,
+		"backend/s3":        disabled,
+		"backend/s3/upload": disabled,
+		"backends":          slog.LevelWarn,
+	} {
+		rtest.Equals(t, level, l.level(module), module)
+	}
+
+	l, err = parseLevels("")
+	rtest.OK(t, err)
+	rtest.Equals(t, disabled, l.level("repository"))
+
+	for _, s := range []string{"verbose", "repository=trace", "repository="} {
+		_, err = parseLevels(s)
+		rtest.Assert(t, err != nil, "invalid levels %q were accepted", s)
+	}
+}
+
+func setup(t *testing.T, opts Options) {
+	closeLog, err := Setup(opts)
+	rtest.OK(t, err)
+	t.Cleanup(func() {
+		current.Store(nil)
+		rtest.OK(t, closeLog())
+	})
+}
+
+func readRecords(t *testing.T, filename string) []map[string]interface{} {
+	f, err := os.Open(filename)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, f.Close())
+	}()
+
+	var records []map[string]interface{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var record map[string]interface{}
+		rtest.OK(t, json.Unmarshal(sc.Bytes(), &record))
+		records = append(records, record)
+	}
+	rtest.OK(t, sc.Err())
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "restic.log")
+	// loggers work even if they are created before Setup was called
+	repoLog := Logger("repository/index")
+	backendLog := Logger("backend").With("backend", "s3").WithGroup("request")
+
+	setup(t, Options{Levels: "repository=debug,backend=warn", File: filename, JSON: true})
+
+	repoLog.Debug("loaded index", "files", 3)
+	backendLog.Info("request finished")
+	backendLog.Warn("request failed", "attempt", 2)
+	Logger("archiver").Error("not logged")
+
+	records := readRecords(t, filename)
+	rtest.Equals(t, 2, len(records))
+
+	rtest.Equals(t, "DEBUG", records[0]["level"])
+	rtest.Equals(t, "repository/index", records[0]["module"])
+	rtest.Equals(t, "loaded index", records[0]["msg"])
+	rtest.Equals(t, float64(3), records[0]["files"])
+
+	rtest.Equals(t, "WARN", records[1]["level"])
+	rtest.Equals(t, "backend", records[1]["module"])
+	rtest.Equals(t, "s3", records[1]["backend"])
+	rtest.Equals(t, map[string]interface{}{"attempt": float64(2)}, records[1]["request"])
+}
+
+func TestLoggerText(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "restic.log")
+	setup(t, Options{Levels: "info", File: filename})
+
+	Logger("archiver").Info("file skipped", "path", "/home/user/file")
+
+	data, err := os.ReadFile(filename)
+	rtest.OK(t, err)
+	line := string(data)
+	for _, s := range []string{"level=INFO", "module=archiver", `msg="file skipped"`, "path=/home/user/file"} {
+		rtest.Assert(t, strings.Contains(line, s), "log line %q does not contain %q", line, s)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "restic.log")
+	f, err := newRotatingFile(filename, 10, 2)
+	rtest.OK(t, err)
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		_, err := f.Write([]byte(line))
+		rtest.OK(t, err)
+	}
+	rtest.OK(t, f.Close())
+
+	for name, content := range map[string]string{
+		filename:        "line 4\n",
+		filename + ".1": "line 3\n",
+		filename + ".2": "line 2\n",
+	} {
+		data, err := os.ReadFile(name)
+		rtest.OK(t, err)
+		rtest.Equals(t, content, string(data))
+	}
+	_, err = os.Stat(filename + ".3")
+	rtest.Assert(t, os.IsNotExist(err), "too many log files were kept: %v", err)
+
+	// an existing log file is appended to
+	f, err = newRotatingFile(filename, 10, 2)
+	rtest.OK(t, err)
+	_, err = f.Write([]byte("a\n"))
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+	data, err := os.ReadFile(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, "line 4\na\n", string(data))
+}
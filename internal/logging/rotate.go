@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// rotatingFile is a log file which is renamed to filename.1 once it reaches
+// maxSize bytes. Older log files are renamed to filename.2 and so on, at
+// most maxFiles rotated log files are kept.
+type rotatingFile struct {
+	filename string
+	maxSize  int64
+	maxFiles int
+
+	m    sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func newRotatingFile(filename string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{filename: filename, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "open log file")
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "stat log file")
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotatedName(i int) string {
+	return fmt.Sprintf("%s.%d", r.filename, i)
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+
+	// the oldest log file is replaced
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		err := os.Rename(r.rotatedName(i), r.rotatedName(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(r.filename, r.rotatedName(1)); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.f == nil {
+		// rotating failed before, try to reopen the log file
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil && r.f == nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+)
+
+var (
+	backendRequestDuration = NewHistogramVec("restic_backend_request_duration_seconds",
+		"Duration of backend requests.", DefaultDurationBuckets, "operation", "type")
+	backendRequestErrors = NewCounterVec("restic_backend_request_errors_total",
+		"Number of failed backend requests.", "operation", "type")
+	backendUploadedBytes = NewCounter("restic_backend_uploaded_bytes_total",
+		"Number of bytes uploaded to the backend.")
+	backendDownloadedBytes = NewCounter("restic_backend_downloaded_bytes_total",
+		"Number of bytes downloaded from the backend.")
+)
+
+// Backend records metrics about the requests to the wrapped backend.
+type Backend struct {
+	backend.Backend
+}
+
+// statically ensure that Backend implements backend.Backend.
+var _ backend.Backend = &Backend{}
+var _ backend.MultiRangeBackend = &Backend{}
+
+// NewBackend wraps be with a backend which records metrics.
+func NewBackend(be backend.Backend) *Backend {
+	return &Backend{Backend: be}
+}
+
+// observe records the duration and the result of a request which started at
+// start.
+func observe(op string, t backend.FileType, start time.Time, err error) {
+	tpe := t.String()
+	backendRequestDuration.With(op, tpe).Observe(time.Since(start).Seconds())
+	if err != nil {
+		backendRequestErrors.With(op, tpe).Inc()
+	}
+}
+
+func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	start := time.Now()
+	err := be.Backend.Save(ctx, h, rd)
+	observe("save", h.Type, start, err)
+	if err == nil {
+		backendUploadedBytes.Add(uint64(rd.Length()))
+	}
+	return err
+}
+
+func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
+	start := time.Now()
+	err := be.Backend.Remove(ctx, h)
+	observe("remove", h.Type, start, err)
+	return err
+}
+
+// countingReader counts the bytes read from the backend.
+type countingReader struct {
+	io.Reader
+}
+
+func (rd countingReader) Read(p []byte) (int, error) {
+	n, err := rd.Reader.Read(p)
+	backendDownloadedBytes.Add(uint64(n))
+	return n, err
+}
+
+func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(io.Reader) error) error {
+	start := time.Now()
+	err := be.Backend.Load(ctx, h, length, offset, func(rd io.Reader) error {
+		return fn(countingReader{rd})
+	})
+	observe("load", h.Type, start, err)
+	return err
+}
+
+func (be *Backend) MaxRanges() int {
+	return backend.MaxRanges(be.Backend)
+}
+
+func (be *Backend) LoadRanges(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+	start := time.Now()
+	err := backend.LoadRanges(ctx, be.Backend, h, ranges, func(i int, rd io.Reader) error {
+		return fn(i, countingReader{rd})
+	})
+	observe("load", h.Type, start, err)
+	return err
+}
+
+func (be *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	start := time.Now()
+	fi, err := be.Backend.Stat(ctx, h)
+	observe("stat", h.Type, start, err)
+	return fi, err
+}
+
+func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	start := time.Now()
+	err := be.Backend.List(ctx, t, fn)
+	observe("list", t, start, err)
+	return err
+}
+
+func (be *Backend) Unwrap() backend.Backend { return be.Backend }
@@ -0,0 +1,3 @@
+// Package metrics collects metrics about restic operations and exports them
+// in the Prometheus text format.
+package metrics
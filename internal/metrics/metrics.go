@@ -0,0 +1,330 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a metric which only increases.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Add increases the counter by n.
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+// Inc increases the counter by one.
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+func (c *Counter) writeSamples(w io.Writer, name, labels string) {
+	fmt.Fprintf(w, "%s%s %d\n", name, wrapLabels(labels), c.Value())
+}
+
+// Gauge is a metric which can increase and decrease.
+type Gauge struct {
+	v atomic.Int64
+}
+
+// Add adds n to the gauge, n may be negative.
+func (g *Gauge) Add(n int64) {
+	g.v.Add(n)
+}
+
+// Inc increases the gauge by one.
+func (g *Gauge) Inc() {
+	g.v.Add(1)
+}
+
+// Dec decreases the gauge by one.
+func (g *Gauge) Dec() {
+	g.v.Add(-1)
+}
+
+// Set sets the gauge to n.
+func (g *Gauge) Set(n int64) {
+	g.v.Store(n)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() int64 {
+	return g.v.Load()
+}
+
+func (g *Gauge) writeSamples(w io.Writer, name, labels string) {
+	fmt.Fprintf(w, "%s%s %d\n", name, wrapLabels(labels), g.Value())
+}
+
+// Histogram counts observed values in buckets.
+type Histogram struct {
+	// bounds contains the upper bounds of the buckets in increasing order.
+	bounds []float64
+	// counts contains the number of values per bucket, the last bucket
+	// contains all values larger than the largest bound.
+	counts []atomic.Uint64
+	// sum contains the bits of the float64 sum of all values
+	sum atomic.Uint64
+}
+
+// DefaultDurationBuckets are histogram buckets for durations in seconds.
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+// Observe adds the value v to the histogram.
+func (h *Histogram) Observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, v)].Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Count returns the number of observed values.
+func (h *Histogram) Count() uint64 {
+	var count uint64
+	for i := range h.counts {
+		count += h.counts[i].Load()
+	}
+	return count
+}
+
+func (h *Histogram) writeSamples(w io.Writer, name, labels string) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+
+	var count uint64
+	for i := range h.counts {
+		count += h.counts[i].Load()
+		le := "+Inf"
+		if i < len(h.bounds) {
+			le = strconv.FormatFloat(h.bounds[i], 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s%sle=%q} %d\n", name, labels, sep, le, count)
+	}
+	sum := math.Float64frombits(h.sum.Load())
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, wrapLabels(labels), strconv.FormatFloat(sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, wrapLabels(labels), count)
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+type sample interface {
+	writeSamples(w io.Writer, name, labels string)
+}
+
+// Vec is a family of metrics with the same name and different label values.
+type Vec[T any] struct {
+	name, help, typ string
+	labels          []string
+	newMetric       func() *T
+
+	m       sync.Mutex
+	metrics map[string]*T
+}
+
+// With returns the metric with the given label values, which must be passed
+// in the order of the label names of the Vec.
+func (v *Vec[T]) With(values ...string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric %v expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+
+	var sb strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, "%s=\"%s\"", v.labels[i], escapeLabelValue(value))
+	}
+	key := sb.String()
+
+	v.m.Lock()
+	defer v.m.Unlock()
+	metric, ok := v.metrics[key]
+	if !ok {
+		metric = v.newMetric()
+		v.metrics[key] = metric
+	}
+	return metric
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func (v *Vec[T]) write(w io.Writer) {
+	v.m.Lock()
+	keys := make([]string, 0, len(v.metrics))
+	for key := range v.metrics {
+		keys = append(keys, key)
+	}
+	metrics := make(map[string]*T, len(v.metrics))
+	for key, metric := range v.metrics {
+		metrics[key] = metric
+	}
+	v.m.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, strings.ReplaceAll(v.help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.typ)
+	for _, key := range keys {
+		any(metrics[key]).(sample).writeSamples(w, v.name, key)
+	}
+}
+
+func (v *Vec[T]) metricName() string {
+	return v.name
+}
+
+type family interface {
+	metricName() string
+	write(w io.Writer)
+}
+
+// Registry contains metrics.
+type Registry struct {
+	m        sync.Mutex
+	families map[string]family
+}
+
+// NewRegistry returns a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// DefaultRegistry contains the metrics created using the package-level
+// functions.
+var DefaultRegistry = NewRegistry()
+
+func newVec[T any](r *Registry, name, help, typ string, labels []string, newMetric func() *T) *Vec[T] {
+	v := &Vec[T]{
+		name:      name,
+		help:      help,
+		typ:       typ,
+		labels:    labels,
+		newMetric: newMetric,
+		metrics:   make(map[string]*T),
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	if _, ok := r.families[name]; ok {
+		panic(fmt.Sprintf("metric %v registered twice", name))
+	}
+	r.families[name] = v
+	return v
+}
+
+// NewCounterVec returns a family of counters with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *Vec[Counter] {
+	return newVec(r, name, help, "counter", labels, func() *Counter { return &Counter{} })
+}
+
+// NewCounter returns a counter without labels.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// NewGaugeVec returns a family of gauges with the given label names.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *Vec[Gauge] {
+	return newVec(r, name, help, "gauge", labels, func() *Gauge { return &Gauge{} })
+}
+
+// NewGauge returns a gauge without labels.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// NewHistogramVec returns a family of histograms with the given bucket upper
+// bounds, in increasing order, and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *Vec[Histogram] {
+	return newVec(r, name, help, "histogram", labels, func() *Histogram { return newHistogram(buckets) })
+}
+
+// NewCounterVec returns a family of counters in the default registry.
+func NewCounterVec(name, help string, labels ...string) *Vec[Counter] {
+	return DefaultRegistry.NewCounterVec(name, help, labels...)
+}
+
+// NewCounter returns a counter in the default registry.
+func NewCounter(name, help string) *Counter {
+	return DefaultRegistry.NewCounter(name, help)
+}
+
+// NewGaugeVec returns a family of gauges in the default registry.
+func NewGaugeVec(name, help string, labels ...string) *Vec[Gauge] {
+	return DefaultRegistry.NewGaugeVec(name, help, labels...)
+}
+
+// NewGauge returns a gauge in the default registry.
+func NewGauge(name, help string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help)
+}
+
+// NewHistogramVec returns a family of histograms in the default registry.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *Vec[Histogram] {
+	return DefaultRegistry.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// Write writes all metrics in the Prometheus text format to w, sorted by
+// name.
+func (r *Registry) Write(w io.Writer) error {
+	r.m.Lock()
+	families := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.m.Unlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].metricName() < families[j].metricName()
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler returns an HTTP handler which serves the metrics of r.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
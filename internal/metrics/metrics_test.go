@@ -0,0 +1,94 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/metrics"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := metrics.NewRegistry()
+	counter := r.NewCounter("test_counter_total", "A counter.")
+	gauges := r.NewGaugeVec("test_gauge", "A gauge\nwith labels.", "name")
+	histogram := r.NewHistogramVec("test_duration_seconds", "A histogram.", []float64{0.1, 1}, "op").With("load")
+	r.NewCounterVec("test_unused_total", "Not written without labels.", "name")
+
+	counter.Add(3)
+	counter.Inc()
+	gauges.With("b").Set(-2)
+	gauges.With(`a"\`).Inc()
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	rtest.Equals(t, uint64(3), histogram.Count())
+
+	var buf bytes.Buffer
+	rtest.OK(t, r.Write(&buf))
+	rtest.Equals(t, `# HELP test_counter_total A counter.
+# TYPE test_counter_total counter
+test_counter_total 4
+# HELP test_duration_seconds A histogram.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="load",le="0.1"} 1
+test_duration_seconds_bucket{op="load",le="1"} 2
+test_duration_seconds_bucket{op="load",le="+Inf"} 3
+test_duration_seconds_sum{op="load"} 5.55
+test_duration_seconds_count{op="load"} 3
+# HELP test_gauge A gauge with labels.
+# TYPE test_gauge gauge
+test_gauge{name="a\"\\"} 1
+test_gauge{name="b"} -2
+`, buf.String())
+}
+
+func TestRegistryHandler(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewGauge("test_gauge", "A gauge.").Set(42)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	rtest.Equals(t, 200, rec.Code)
+	rtest.Assert(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"), "unexpected content type %q", rec.Header().Get("Content-Type"))
+	rtest.Assert(t, strings.Contains(rec.Body.String(), "test_gauge 42\n"), "metric missing in %q", rec.Body.String())
+}
+
+// metricValue returns the value of the sample with the given name and labels
+// in the default registry.
+func metricValue(t *testing.T, sample string) string {
+	var buf bytes.Buffer
+	rtest.OK(t, metrics.DefaultRegistry.Write(&buf))
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, sample+" "); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func TestBackend(t *testing.T) {
+	be := metrics.NewBackend(mem.New())
+	h := backend.Handle{Type: backend.PackFile, Name: "foo"}
+	data := []byte("some data")
+	rtest.OK(t, be.Save(context.TODO(), h, backend.NewByteReader(data, be.Hasher())))
+	rtest.OK(t, be.Load(context.TODO(), h, 0, 0, func(rd io.Reader) error {
+		_, err := io.ReadAll(rd)
+		return err
+	}))
+	_, err := be.Stat(context.TODO(), backend.Handle{Type: backend.PackFile, Name: "missing"})
+	rtest.Assert(t, err != nil, "missing file was found")
+
+	rtest.Equals(t, "9", metricValue(t, "restic_backend_uploaded_bytes_total"))
+	rtest.Equals(t, "9", metricValue(t, "restic_backend_downloaded_bytes_total"))
+	rtest.Equals(t, "1", metricValue(t, `restic_backend_request_duration_seconds_count{operation="save",type="data"}`))
+	rtest.Equals(t, "1", metricValue(t, `restic_backend_request_errors_total{operation="stat",type="data"}`))
+	rtest.Equals(t, "", metricValue(t, `restic_backend_request_errors_total{operation="save",type="data"}`))
+	rtest.Assert(t, backend.AsBackend[*mem.MemoryBackend](be) != nil, "unable to unwrap backend")
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+func init() {
+	register(&AppendOnly{})
+}
+
+type AppendOnly struct{}
+
+func (*AppendOnly) Name() string {
+	return "append-only"
+}
+
+func (*AppendOnly) Desc() string {
+	return "only allow the current key and keys added with --allow-delete to remove data and snapshots"
+}
+
+func (*AppendOnly) Check(_ context.Context, repo restic.Repository) (bool, string, error) {
+	if repo.Config().AppendOnly {
+		return false, "repository is already append-only", nil
+	}
+	return true, "", nil
+}
+
+func (*AppendOnly) RepoCheck() bool {
+	return false
+}
+
+func (*AppendOnly) Apply(ctx context.Context, repo restic.Repository) error {
+	r := repo.(*repository.Repository)
+	// Check is skipped by --force
+	if r.Config().AppendOnly && !r.CanDelete() {
+		return repository.ErrAppendOnly
+	}
+	return repository.EnableAppendOnly(ctx, r)
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestAppendOnlyRestrictedKey(t *testing.T) {
+	repository.TestUseLowSecurityKDFParameters(t)
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	ctx := context.Background()
+
+	m := &AppendOnly{}
+	rtest.OK(t, m.Apply(ctx, repo))
+
+	_, err := repository.AddKey(ctx, repo, "restricted", "", "", repo.Key(), nil, nil)
+	rtest.OK(t, err)
+	restricted, err := repository.New(be, repository.Options{})
+	rtest.OK(t, err)
+	rtest.OK(t, restricted.SearchKey(ctx, "restricted", 0, ""))
+
+	ok, _, err := m.Check(ctx, restricted)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "check passed for append-only repository")
+
+	// applying the migration anyway, as with --force, must not grant the
+	// delete capability to the key
+	err = m.Apply(ctx, restricted)
+	rtest.Assert(t, err == repository.ErrAppendOnly, "unexpected error %v", err)
+	rtest.Assert(t, !restricted.CanDelete(), "restricted key can delete")
+
+	err = repository.EnableAppendOnly(ctx, restricted)
+	rtest.Assert(t, err == repository.ErrAppendOnly, "unexpected error %v", err)
+
+	reopened, err := repository.New(be, repository.Options{})
+	rtest.OK(t, err)
+	rtest.OK(t, reopened.SearchKey(ctx, "restricted", 0, ""))
+	rtest.Assert(t, !reopened.CanDelete(), "delete capability was saved for restricted key")
+}
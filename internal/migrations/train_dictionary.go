@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+func init() {
+	register(&TrainDictionary{})
+}
+
+type TrainDictionary struct{}
+
+func (*TrainDictionary) Name() string {
+	return "train-dictionary"
+}
+
+func (*TrainDictionary) Desc() string {
+	return "train a compression dictionary to improve the compression of small files"
+}
+
+func (*TrainDictionary) Check(_ context.Context, repo restic.Repository) (bool, string, error) {
+	if repo.Config().Version < restic.FeaturesRepoVersion {
+		return false, fmt.Sprintf("compression dictionaries require repository version %v, the repository has version %v", restic.FeaturesRepoVersion, repo.Config().Version), nil
+	}
+	return true, "", nil
+}
+
+func (*TrainDictionary) RepoCheck() bool {
+	return false
+}
+
+func (*TrainDictionary) Apply(ctx context.Context, repo restic.Repository) error {
+	_, err := repository.TrainCompressionDictionary(ctx, repo.(*repository.Repository))
+	return err
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+func init() {
+	register(&UpgradeRepoV3{})
+}
+
+type UpgradeRepoV3 struct{}
+
+func (*UpgradeRepoV3) Name() string {
+	return "upgrade_repo_v3"
+}
+
+func (*UpgradeRepoV3) Desc() string {
+	return "upgrade a repository to version 3"
+}
+
+func (*UpgradeRepoV3) Check(_ context.Context, repo restic.Repository) (bool, string, error) {
+	isV2 := repo.Config().Version == 2
+	reason := ""
+	if !isV2 {
+		reason = fmt.Sprintf("only repositories with version 2 can be upgraded, the repository has version %v", repo.Config().Version)
+	}
+	return isV2, reason, nil
+}
+
+func (*UpgradeRepoV3) RepoCheck() bool {
+	return true
+}
+
+func (m *UpgradeRepoV3) Apply(ctx context.Context, repo restic.Repository) error {
+	return repository.UpgradeRepoV3(ctx, repo.(*repository.Repository))
+}
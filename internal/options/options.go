@@ -1,6 +1,7 @@
 package options
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
@@ -16,6 +17,7 @@ type Options map[string]string
 var opts []Help
 
 // Register allows registering options so that they can be listed with List.
+// Fields of cfg which are not zero are reported as default value.
 func Register(ns string, cfg interface{}) {
 	opts = appendAllOptions(opts, ns, cfg)
 }
@@ -27,6 +29,18 @@ func List() (list []Help) {
 	return list
 }
 
+// Lookup returns the registered option with the given key, which consists of
+// the namespace and the name of the option, e.g. "s3.storage-class".
+func Lookup(key string) (Help, bool) {
+	key = strings.ToLower(key)
+	for _, opt := range opts {
+		if opt.Namespace+"."+opt.Name == key {
+			return opt, true
+		}
+	}
+	return Help{}, false
+}
+
 // appendAllOptions appends all options in cfg to opts, sorted by namespace.
 func appendAllOptions(opts []Help, ns string, cfg interface{}) []Help {
 	for _, opt := range listOptions(cfg) {
@@ -47,14 +61,21 @@ func listOptions(cfg interface{}) (opts []Help) {
 		f := v.Type().Field(i)
 
 		h := Help{
-			Name: f.Tag.Get("option"),
-			Text: f.Tag.Get("help"),
+			Name:    f.Tag.Get("option"),
+			Text:    f.Tag.Get("help"),
+			Details: f.Tag.Get("details"),
+			Type:    strings.ToLower(f.Type.Name()),
 		}
 
 		if h.Name == "" {
 			continue
 		}
 
+		// the registered config contains the default values
+		if fv := v.Field(i); !fv.IsZero() {
+			h.Default = fmt.Sprint(fv.Interface())
+		}
+
 		opts = append(opts, h)
 	}
 
@@ -66,6 +87,13 @@ type Help struct {
 	Namespace string
 	Name      string
 	Text      string
+	// Details contains an extended description of the option.
+	Details string
+	// Type is the type of the option value, e.g. "string" or "duration".
+	Type string
+	// Default is the default value of the option, it is empty if the option
+	// is not set by default.
+	Default string
 }
 
 type helpList []Help
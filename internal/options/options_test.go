@@ -255,7 +255,7 @@ func TestListOptions(t *testing.T) {
 				Foo string `option:"foo" help:"bar text help"`
 			}{},
 			[]Help{
-				{Name: "foo", Text: "bar text help"},
+				{Name: "foo", Text: "bar text help", Type: "string"},
 			},
 		},
 		{
@@ -264,8 +264,8 @@ func TestListOptions(t *testing.T) {
 				Bar string `option:"bar" help:"bar text help"`
 			}{},
 			[]Help{
-				{Name: "foo", Text: "bar text help"},
-				{Name: "bar", Text: "bar text help"},
+				{Name: "foo", Text: "bar text help", Type: "string"},
+				{Name: "bar", Text: "bar text help", Type: "string"},
 			},
 		},
 		{
@@ -274,14 +274,14 @@ func TestListOptions(t *testing.T) {
 				Foo string `option:"foo" help:"bar text help"`
 			}{},
 			[]Help{
-				{Name: "bar", Text: "bar text help"},
-				{Name: "foo", Text: "bar text help"},
+				{Name: "bar", Text: "bar text help", Type: "string"},
+				{Name: "foo", Text: "bar text help", Type: "string"},
 			},
 		},
 		{
 			&teststruct,
 			[]Help{
-				{Name: "foo", Text: "bar text help"},
+				{Name: "foo", Text: "bar text help", Type: "string"},
 			},
 		},
 	}
@@ -296,6 +296,24 @@ func TestListOptions(t *testing.T) {
 	}
 }
 
+func TestListOptionsDetails(t *testing.T) {
+	cfg := struct {
+		Foo     string        `option:"foo" help:"foo help" details:"more about foo"`
+		Timeout time.Duration `option:"timeout" help:"timeout help"`
+		Enabled bool          `option:"enabled" help:"enabled help"`
+	}{Timeout: time.Minute}
+
+	opts := listOptions(cfg)
+	want := []Help{
+		{Name: "foo", Text: "foo help", Details: "more about foo", Type: "string"},
+		{Name: "timeout", Text: "timeout help", Type: "duration", Default: "1m0s"},
+		{Name: "enabled", Text: "enabled help", Type: "bool"},
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Fatalf("wrong opts, want:\n  %v\ngot:\n  %v", want, opts)
+	}
+}
+
 func TestAppendAllOptions(t *testing.T) {
 	tests := []struct {
 		cfgs map[string]interface{}
@@ -312,9 +330,9 @@ func TestAppendAllOptions(t *testing.T) {
 				}{},
 			},
 			[]Help{
-				{Namespace: "local", Name: "foo", Text: "bar text help"},
-				{Namespace: "sftp", Name: "bar", Text: "bar text help"},
-				{Namespace: "sftp", Name: "foo", Text: "bar text help2"},
+				{Namespace: "local", Name: "foo", Text: "bar text help", Type: "string"},
+				{Namespace: "sftp", Name: "bar", Text: "bar text help", Type: "string"},
+				{Namespace: "sftp", Name: "foo", Text: "bar text help2", Type: "string"},
 			},
 		},
 	}
@@ -0,0 +1,243 @@
+// Package policy implements retention policies which apply different expire
+// policies to snapshots depending on their host, tags and paths.
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"gopkg.in/yaml.v3"
+)
+
+// file is the content of a policy file.
+type file struct {
+	Rules []fileRule `yaml:"rules"`
+}
+
+type fileRule struct {
+	Name  string   `yaml:"name"`
+	Hosts []string `yaml:"hosts"`
+	Tags  []string `yaml:"tags"`
+	Paths []string `yaml:"paths"`
+	Keep  fileKeep `yaml:"keep"`
+}
+
+type fileKeep struct {
+	Last          int      `yaml:"last"`
+	Hourly        int      `yaml:"hourly"`
+	Daily         int      `yaml:"daily"`
+	Weekly        int      `yaml:"weekly"`
+	Monthly       int      `yaml:"monthly"`
+	Yearly        int      `yaml:"yearly"`
+	Within        string   `yaml:"within"`
+	WithinHourly  string   `yaml:"within-hourly"`
+	WithinDaily   string   `yaml:"within-daily"`
+	WithinWeekly  string   `yaml:"within-weekly"`
+	WithinMonthly string   `yaml:"within-monthly"`
+	WithinYearly  string   `yaml:"within-yearly"`
+	Tags          []string `yaml:"tags"`
+}
+
+// Rule applies an expire policy to all snapshots which match its hosts, tags
+// and paths.
+type Rule struct {
+	Name  string
+	Hosts []string
+	Tags  restic.TagLists
+	Paths []string
+	Keep  restic.ExpirePolicy
+}
+
+// Matches returns true if the snapshot is handled by the rule.
+func (r *Rule) Matches(sn *restic.Snapshot) bool {
+	return sn.HasHostname(r.Hosts) && sn.HasTagList(r.Tags) && sn.HasPaths(r.Paths)
+}
+
+func (r *Rule) String() string {
+	var conds []string
+	if len(r.Hosts) > 0 {
+		conds = append(conds, fmt.Sprintf("hosts %v", strings.Join(r.Hosts, ", ")))
+	}
+	if len(r.Tags) > 0 {
+		conds = append(conds, fmt.Sprintf("tags %v", r.Tags))
+	}
+	if len(r.Paths) > 0 {
+		conds = append(conds, fmt.Sprintf("paths %v", strings.Join(r.Paths, ", ")))
+	}
+	if len(conds) == 0 {
+		conds = append(conds, "all snapshots")
+	}
+	return fmt.Sprintf("%v (%v): %v", r.Name, strings.Join(conds, ", "), r.Keep)
+}
+
+// Policy is an ordered list of rules. Each snapshot is handled by the first
+// rule which matches it, snapshots which match no rule are kept.
+type Policy struct {
+	Rules []Rule
+}
+
+// Load reads the policy from the YAML file filename.
+func Load(filename string) (*Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Fatalf("unable to read policy file: %v", err)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, errors.Fatalf("invalid policy file %v: %v", filename, err)
+	}
+	return p, nil
+}
+
+// Parse parses a policy in YAML format.
+func Parse(data []byte) (*Policy, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var f file
+	err := dec.Decode(&f)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(f.Rules) == 0 {
+		return nil, errors.New("no rules defined")
+	}
+
+	p := &Policy{}
+	for i, fr := range f.Rules {
+		name := fr.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+		r, err := parseRule(name, fr)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+		p.Rules = append(p.Rules, r)
+	}
+	return p, nil
+}
+
+func parseRule(name string, fr fileRule) (Rule, error) {
+	r := Rule{
+		Name:  name,
+		Hosts: fr.Hosts,
+		Paths: fr.Paths,
+	}
+	for _, tags := range fr.Tags {
+		var l restic.TagList
+		if err := l.Set(tags); err != nil {
+			return Rule{}, err
+		}
+		r.Tags = append(r.Tags, l)
+	}
+
+	k := fr.Keep
+	for _, n := range []int{k.Last, k.Hourly, k.Daily, k.Weekly, k.Monthly, k.Yearly} {
+		if n < -1 {
+			return Rule{}, errors.New("negative values other than -1 are not allowed for keep counts")
+		}
+	}
+	r.Keep = restic.ExpirePolicy{
+		Last:    k.Last,
+		Hourly:  k.Hourly,
+		Daily:   k.Daily,
+		Weekly:  k.Weekly,
+		Monthly: k.Monthly,
+		Yearly:  k.Yearly,
+	}
+
+	for _, d := range []struct {
+		value  string
+		target *restic.Duration
+	}{
+		{k.Within, &r.Keep.Within},
+		{k.WithinHourly, &r.Keep.WithinHourly},
+		{k.WithinDaily, &r.Keep.WithinDaily},
+		{k.WithinWeekly, &r.Keep.WithinWeekly},
+		{k.WithinMonthly, &r.Keep.WithinMonthly},
+		{k.WithinYearly, &r.Keep.WithinYearly},
+	} {
+		if d.value == "" {
+			continue
+		}
+		dur, err := restic.ParseDuration(d.value)
+		if err != nil {
+			return Rule{}, err
+		}
+		if dur.Hours < 0 || dur.Days < 0 || dur.Months < 0 || dur.Years < 0 {
+			return Rule{}, errors.New("durations containing negative values are not allowed")
+		}
+		*d.target = dur
+	}
+
+	for _, tags := range k.Tags {
+		var l restic.TagList
+		if err := l.Set(tags); err != nil {
+			return Rule{}, err
+		}
+		r.Keep.Tags = append(r.Keep.Tags, l)
+	}
+
+	if r.Keep.Empty() {
+		return Rule{}, errors.New("no keep options specified")
+	}
+	return r, nil
+}
+
+func (p *Policy) String() string {
+	var lines []string
+	for _, r := range p.Rules {
+		lines = append(lines, "  "+r.String())
+	}
+	return "\n" + strings.Join(lines, "\n")
+}
+
+// Apply returns the snapshots from list that are to be kept and removed
+// according to the policy. The results are sorted like the results of
+// restic.ApplyPolicy. reasons contains the reasons to keep each snapshot, it
+// is in the same order as keep.
+func (p *Policy) Apply(list restic.Snapshots) (keep, remove restic.Snapshots, reasons []restic.KeepReason) {
+	// sort newest snapshots first
+	sort.Stable(list)
+
+	lists := make([]restic.Snapshots, len(p.Rules))
+	keepReasons := make(map[*restic.Snapshot]restic.KeepReason)
+	for _, sn := range list {
+		matched := false
+		for i := range p.Rules {
+			if p.Rules[i].Matches(sn) {
+				lists[i] = append(lists[i], sn)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			keepReasons[sn] = restic.KeepReason{Snapshot: sn, Matches: []string{"no matching rule"}}
+		}
+	}
+
+	for i, rule := range p.Rules {
+		_, _, ruleReasons := restic.ApplyPolicy(lists[i], rule.Keep)
+		for _, reason := range ruleReasons {
+			reason.Matches = append([]string{rule.Name}, reason.Matches...)
+			keepReasons[reason.Snapshot] = reason
+		}
+	}
+
+	for _, sn := range list {
+		if reason, ok := keepReasons[sn]; ok {
+			keep = append(keep, sn)
+			reasons = append(reasons, reason)
+		} else {
+			remove = append(remove, sn)
+		}
+	}
+	return keep, remove, reasons
+}
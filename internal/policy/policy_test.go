@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+const testPolicy = `
+rules:
+  - name: databases
+    tags: [db]
+    keep:
+      monthly: 12
+  - name: server x
+    hosts: [x]
+    keep:
+      last: 3
+      tags: ["keep,forever"]
+`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(p.Rules))
+
+	rtest.Equals(t, "databases", p.Rules[0].Name)
+	rtest.Equals(t, restic.TagLists{{"db"}}, p.Rules[0].Tags)
+	rtest.Equals(t, restic.ExpirePolicy{Monthly: 12}, p.Rules[0].Keep)
+
+	rtest.Equals(t, []string{"x"}, p.Rules[1].Hosts)
+	rtest.Equals(t, restic.ExpirePolicy{Last: 3, Tags: restic.TagLists{{"keep", "forever"}}}, p.Rules[1].Keep)
+
+	p, err = Parse([]byte("rules:\n  - keep:\n      within: 1y2m\n"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "rule 1", p.Rules[0].Name)
+	rtest.Equals(t, restic.Duration{Years: 1, Months: 2}, p.Rules[0].Keep.Within)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"rules: []",
+		"rules:\n  - hosts: [x]\n",
+		"rules:\n  - keep:\n      last: -2\n",
+		"rules:\n  - keep:\n      within: 1x\n",
+		"rules:\n  - keep:\n      lats: 1\n",
+		"rules:\n  - host: x\n    keep:\n      last: 1\n",
+	} {
+		_, err := Parse([]byte(data))
+		rtest.Assert(t, err != nil, "invalid policy %q was accepted", data)
+	}
+}
+
+func newSnapshot(t *testing.T, host string, tags []string, tm time.Time) *restic.Snapshot {
+	sn, err := restic.NewSnapshot([]string{"/data"}, tags, host, tm)
+	rtest.OK(t, err)
+	id := restic.NewRandomID()
+	sn.Tree = &id
+	return sn
+}
+
+func TestApply(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	rtest.OK(t, err)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var list restic.Snapshots
+	want := make(map[*restic.Snapshot]bool)
+	add := func(sn *restic.Snapshot, keep bool) {
+		list = append(list, sn)
+		want[sn] = keep
+	}
+
+	// daily database snapshots for three months, the last one of each month is kept
+	for d := 0; d < 90; d++ {
+		tm := start.AddDate(0, 0, d)
+		add(newSnapshot(t, "db", []string{"db"}, tm), tm.AddDate(0, 0, 1).Day() == 1 || d == 0 || d == 89)
+	}
+	// only the last three snapshots and tagged snapshots of host x are kept
+	for d := 0; d < 10; d++ {
+		var tags []string
+		if d == 2 {
+			tags = []string{"forever", "keep"}
+		}
+		add(newSnapshot(t, "x", tags, start.AddDate(0, 0, d)), d >= 7 || d == 2)
+	}
+	// snapshots which match no rule are kept
+	add(newSnapshot(t, "y", nil, start), true)
+
+	keep, remove, reasons := p.Apply(list)
+	rtest.Equals(t, len(list), len(keep)+len(remove))
+	rtest.Equals(t, len(keep), len(reasons))
+	for i, sn := range keep {
+		rtest.Assert(t, want[sn], "snapshot %v of %v was kept", sn.Time, sn.Hostname)
+		rtest.Equals(t, sn, reasons[i].Snapshot)
+	}
+	for _, sn := range remove {
+		rtest.Assert(t, !want[sn], "snapshot %v of %v was removed", sn.Time, sn.Hostname)
+	}
+
+	for i := 1; i < len(keep); i++ {
+		rtest.Assert(t, !keep[i].Time.After(keep[i-1].Time), "keep list is not sorted")
+	}
+}
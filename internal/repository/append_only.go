@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// ErrAppendOnly is returned when trying to remove files from an append-only
+// repository using a key without the delete capability.
+var ErrAppendOnly = errors.Fatal("the repository is append-only, this operation requires a key with the delete capability")
+
+// EnableAppendOnly marks the repository as append-only. The key currently used
+// to access the repository receives the delete capability, such that it can
+// still be used to forget snapshots and to prune the repository. If the
+// repository is already append-only, the capabilities are not changed.
+func EnableAppendOnly(ctx context.Context, repo *Repository) error {
+	if repo.KeyID().IsNull() {
+		return errors.New("enabling append-only mode requires opening the repository using a key file")
+	}
+
+	if repo.Config().AppendOnly {
+		// a key without the delete capability must not be able to grant
+		// it to itself
+		if !repo.CanDelete() {
+			return ErrAppendOnly
+		}
+		return nil
+	}
+
+	if !slices.Contains(repo.keyCapabilities, KeyCapabilityDelete) {
+		if err := setKeyCapabilities(ctx, repo, append(slices.Clone(repo.keyCapabilities), KeyCapabilityDelete)); err != nil {
+			return err
+		}
+	}
+
+	cfg := repo.Config()
+	cfg.AppendOnly = true
+	return replaceConfig(ctx, repo, cfg)
+}
@@ -0,0 +1,51 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestAppendOnly(t *testing.T) {
+	repository.TestUseLowSecurityKDFParameters(t)
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	ctx := context.TODO()
+
+	snID, err := repo.SaveUnpacked(ctx, restic.SnapshotFile, []byte("{}"))
+	rtest.OK(t, err)
+
+	rtest.OK(t, repository.EnableAppendOnly(ctx, repo))
+	rtest.Assert(t, repo.Config().AppendOnly, "repository not append-only")
+	rtest.Assert(t, repo.CanDelete(), "current key cannot delete")
+	adminKeyID := repo.KeyID()
+
+	// the flag and the capability are persisted in the repository
+	admin := repository.TestOpenBackend(t, be)
+	rtest.Assert(t, admin.Config().AppendOnly, "append-only flag was not saved")
+	rtest.Assert(t, admin.CanDelete(), "delete capability was not saved")
+
+	_, err = repository.AddKey(ctx, repo, "restricted", "", "", repo.Key(), nil, nil)
+	rtest.OK(t, err)
+	restricted, err := repository.New(be, repository.Options{})
+	rtest.OK(t, err)
+	rtest.OK(t, restricted.SearchKey(ctx, "restricted", 0, ""))
+	rtest.Assert(t, !restricted.CanDelete(), "restricted key can delete")
+
+	err = restricted.RemoveUnpacked(ctx, restic.SnapshotFile, snID)
+	rtest.Assert(t, err == repository.ErrAppendOnly, "unexpected error removing snapshot: %v", err)
+	err = restricted.RemoveUnpacked(ctx, restic.PackFile, restic.NewRandomID())
+	rtest.Assert(t, err == repository.ErrAppendOnly, "unexpected error removing pack: %v", err)
+	err = repository.RemoveKey(ctx, restricted, adminKeyID)
+	rtest.Assert(t, err == repository.ErrAppendOnly, "unexpected error removing key: %v", err)
+	_, err = repository.AddKey(ctx, restricted, "other", "", "", restricted.Key(), []string{repository.KeyCapabilityDelete}, nil)
+	rtest.Assert(t, err != nil, "restricted key could add key with delete capability")
+
+	// the restricted key can still write new snapshots
+	_, err = restricted.SaveUnpacked(ctx, restic.SnapshotFile, []byte("{}"))
+	rtest.OK(t, err)
+
+	rtest.OK(t, admin.RemoveUnpacked(ctx, restic.SnapshotFile, snID))
+}
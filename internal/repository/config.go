@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// pendingConfig holds a copy of the new config while the config is replaced
+// on a backend which cannot atomically replace files. The name is not a valid
+// ID, thus the file is ignored when listing state files.
+var pendingConfig = backend.Handle{Type: backend.StateFile, Name: "config.pending"}
+
+// replaceConfig saves cfg as the new repository config. Backends which cannot
+// atomically replace files require removing the old config first. Similar to
+// changing a key, the new config is therefore first stored as a separate file,
+// which RecoverConfig uses to restore the config if restic is interrupted
+// before the new config was saved.
+func replaceConfig(ctx context.Context, repo *Repository, cfg restic.Config) error {
+	if repo.be.HasAtomicReplace() {
+		if err := restic.SaveConfig(ctx, repo, cfg); err != nil {
+			return fmt.Errorf("save new config file failed: %w", err)
+		}
+		repo.setConfig(cfg)
+		return nil
+	}
+
+	plaintext, err := json.Marshal(cfg)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	ciphertext, err := repo.sealUnpacked(restic.ConfigFile, plaintext)
+	if err != nil {
+		return err
+	}
+
+	// remove leftovers of an interrupted replacement, the config is intact
+	if err := repo.be.Remove(ctx, pendingConfig); err != nil && !repo.be.IsNotExist(err) {
+		return fmt.Errorf("remove pending config failed: %w", err)
+	}
+	if err := repo.be.Save(ctx, pendingConfig, backend.NewByteReader(ciphertext, repo.be.Hasher())); err != nil {
+		return fmt.Errorf("save pending config failed: %w", err)
+	}
+
+	h := backend.Handle{Type: restic.ConfigFile}
+	if err := repo.be.Remove(ctx, h); err != nil {
+		return fmt.Errorf("remove config failed: %w", err)
+	}
+	if err := repo.be.Save(ctx, h, backend.NewByteReader(ciphertext, repo.be.Hasher())); err != nil {
+		return fmt.Errorf("save new config file failed, the config will be restored when the repository is opened the next time: %w", err)
+	}
+	repo.setConfig(cfg)
+
+	if err := repo.be.Remove(ctx, pendingConfig); err != nil {
+		debug.Log("unable to remove pending config: %v", err)
+	}
+	return nil
+}
+
+// RecoverConfig restores the config of a repository if replacing it was
+// interrupted after the old config was removed. It returns whether the config
+// was restored. The pending config is left in place if the config exists.
+func RecoverConfig(ctx context.Context, be backend.Backend) (bool, error) {
+	h := backend.Handle{Type: restic.ConfigFile}
+	_, err := be.Stat(ctx, h)
+	if err == nil || !be.IsNotExist(err) {
+		return false, err
+	}
+
+	var buf []byte
+	err = be.Load(ctx, pendingConfig, 0, 0, func(rd io.Reader) error {
+		var err error
+		buf, err = io.ReadAll(rd)
+		return err
+	})
+	if be.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("load pending config failed: %w", err)
+	}
+
+	debug.Log("restoring config from pending config")
+	if err := be.Save(ctx, h, backend.NewByteReader(buf, be.Hasher())); err != nil {
+		return false, fmt.Errorf("restore config failed: %w", err)
+	}
+	if err := be.Remove(ctx, pendingConfig); err != nil {
+		debug.Log("unable to remove pending config: %v", err)
+	}
+	return true, nil
+}
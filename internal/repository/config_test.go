@@ -0,0 +1,49 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// configSaveFailBackend fails to save the config, as if restic was
+// interrupted right after removing the old config.
+type configSaveFailBackend struct {
+	backend.Backend
+}
+
+func (be *configSaveFailBackend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if h.Type == backend.ConfigFile {
+		return errors.New("failure induced for testing")
+	}
+	return be.Backend.Save(ctx, h, rd)
+}
+
+func TestReplaceConfigInterrupted(t *testing.T) {
+	_, be := repository.TestRepositoryWithVersion(t, 0)
+	ctx := context.TODO()
+	rtest.Assert(t, !be.HasAtomicReplace(), "test requires a backend without atomic replace")
+
+	repo := repository.TestOpenBackend(t, &configSaveFailBackend{Backend: be})
+	err := repository.EnableAppendOnly(ctx, repo)
+	rtest.Assert(t, err != nil, "saving the config did not fail")
+
+	_, err = be.Stat(ctx, backend.Handle{Type: restic.ConfigFile})
+	rtest.Assert(t, be.IsNotExist(err), "config was not removed: %v", err)
+
+	recovered, err := repository.RecoverConfig(ctx, be)
+	rtest.OK(t, err)
+	rtest.Assert(t, recovered, "config was not recovered")
+	repo = repository.TestOpenBackend(t, be)
+	rtest.Assert(t, repo.Config().AppendOnly, "recovered config is not the new config")
+
+	// nothing to do for an intact repository
+	recovered, err = repository.RecoverConfig(ctx, be)
+	rtest.OK(t, err)
+	rtest.Assert(t, !recovered, "intact config was recovered")
+}
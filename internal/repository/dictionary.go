@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+const (
+	// dictionarySize is the maximum size of a compression dictionary. This
+	// matches the default of the zstd command line tool.
+	dictionarySize = 110 * 1024
+	// dictionaryMaxSamples is the number of data blobs used for training.
+	dictionaryMaxSamples = 2000
+	// dictionaryMinSamples is the minimum number of small data blobs
+	// required to train a dictionary.
+	dictionaryMinSamples = 10
+	// dictionaryMaxSampleSize is the size up to which data blobs are
+	// considered for training. Large blobs profit little from a dictionary.
+	dictionaryMaxSampleSize = 128 * 1024
+)
+
+// DictionaryStats summarizes how well a compression dictionary performs on
+// the sampled blobs.
+type DictionaryStats struct {
+	Samples            int
+	UncompressedSize   uint64
+	CompressedSize     uint64
+	DictCompressedSize uint64
+}
+
+// TrainCompressionDictionary trains a zstd dictionary using a random sample of
+// the small data blobs in the repository and adds it to the repository config.
+// Afterwards, new blobs are compressed using the dictionary. Existing blobs are
+// not modified, they can still be decompressed as before. If the dictionary
+// does not improve the compression of the sampled blobs, an error is returned
+// and the config is not modified.
+func TrainCompressionDictionary(ctx context.Context, repo *Repository) (DictionaryStats, error) {
+	if repo.Config().Version < restic.FeaturesRepoVersion {
+		return DictionaryStats{}, errors.Errorf("compression dictionaries require at least repository format version %v", restic.FeaturesRepoVersion)
+	}
+
+	if err := repo.LoadIndex(ctx, nil); err != nil {
+		return DictionaryStats{}, err
+	}
+
+	var candidates []restic.ID
+	err := repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		if pb.Type == restic.DataBlob && pb.DataLength() <= dictionaryMaxSampleSize {
+			candidates = append(candidates, pb.ID)
+		}
+	})
+	if err != nil {
+		return DictionaryStats{}, err
+	}
+	// blobs can be stored in multiple pack files
+	slices.SortFunc(candidates, func(a, b restic.ID) int { return bytes.Compare(a[:], b[:]) })
+	candidates = slices.Compact(candidates)
+
+	if len(candidates) < dictionaryMinSamples {
+		return DictionaryStats{}, errors.Errorf("found only %d small data blobs, at least %d are required to train a dictionary", len(candidates), dictionaryMinSamples)
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > dictionaryMaxSamples {
+		candidates = candidates[:dictionaryMaxSamples]
+	}
+
+	samples := make([][]byte, 0, len(candidates))
+	for _, id := range candidates {
+		buf, err := repo.LoadBlob(ctx, restic.DataBlob, id, nil)
+		if err != nil {
+			return DictionaryStats{}, err
+		}
+		samples = append(samples, buf)
+	}
+
+	dict, err := buildDictionary(samples, repo.zstdEncoderLevel())
+	if err != nil {
+		return DictionaryStats{}, err
+	}
+
+	stats, err := evaluateDictionary(dict, samples, repo.zstdEncoderOptions())
+	if err != nil {
+		return DictionaryStats{}, err
+	}
+	debug.Log("dictionary stats: %+v", stats)
+	if stats.DictCompressedSize >= stats.CompressedSize {
+		return stats, errors.New("the dictionary does not improve the compression of the sampled data blobs")
+	}
+
+	cfg := repo.Config()
+	cfg.CompressionDictionaries = append(slices.Clone(cfg.CompressionDictionaries), dict)
+	if err := replaceConfig(ctx, repo, cfg); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// buildDictionary builds a zstd dictionary from the samples. The raw content of
+// the dictionary consists of an equally sized part of each sample.
+func buildDictionary(samples [][]byte, level zstd.EncoderLevel) ([]byte, error) {
+	perSample := dictionarySize / len(samples)
+	if perSample < 64 {
+		perSample = 64
+	}
+	history := make([]byte, 0, dictionarySize)
+	for _, sample := range samples {
+		n := len(sample)
+		if n > perSample {
+			n = perSample
+		}
+		if n > dictionarySize-len(history) {
+			n = dictionarySize - len(history)
+		}
+		history = append(history, sample[:n]...)
+		if len(history) == dictionarySize {
+			break
+		}
+	}
+
+	// dictionary IDs below 32768 and above 2^31 are reserved
+	id := uint32(32768 + rand.Int63n(1<<31-32768))
+	return zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: samples,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+		Level:    level,
+	})
+}
+
+// evaluateDictionary compresses the samples with and without the dictionary.
+func evaluateDictionary(dict []byte, samples [][]byte, opts []zstd.EOption) (DictionaryStats, error) {
+	plain, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return DictionaryStats{}, err
+	}
+	defer plain.Close()
+
+	withDict, err := zstd.NewWriter(nil, append(slices.Clone(opts), zstd.WithEncoderDict(dict))...)
+	if err != nil {
+		return DictionaryStats{}, fmt.Errorf("invalid dictionary: %w", err)
+	}
+	defer withDict.Close()
+
+	stats := DictionaryStats{Samples: len(samples)}
+	var buf []byte
+	for _, sample := range samples {
+		stats.UncompressedSize += uint64(len(sample))
+		buf = plain.EncodeAll(sample, buf[:0])
+		stats.CompressedSize += uint64(len(buf))
+		buf = withDict.EncodeAll(sample, buf[:0])
+		stats.DictCompressedSize += uint64(len(buf))
+	}
+	return stats, nil
+}
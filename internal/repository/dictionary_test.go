@@ -0,0 +1,72 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+// similarBlob returns a small JSON document, such that the documents for
+// different i only differ in a few places.
+func similarBlob(i int) []byte {
+	return []byte(fmt.Sprintf(`{"id": %d, "name": "document-%d", "description": "a small document which is compressed much better using a dictionary", "tags": ["alpha", "beta", "gamma"], "owner": {"user": "restic", "group": "backup"}, "size": %d}`, i, i*7, i*13))
+}
+
+func saveBlobs(t *testing.T, repo restic.Repository, blobs [][]byte) (ids restic.IDs, size int) {
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	for _, buf := range blobs {
+		id, _, n, err := repo.SaveBlob(context.TODO(), restic.DataBlob, buf, restic.ID{}, false)
+		rtest.OK(t, err)
+		ids = append(ids, id)
+		size += n
+	}
+	rtest.OK(t, repo.Flush(context.Background()))
+	return ids, size
+}
+
+func TestTrainCompressionDictionary(t *testing.T) {
+	repo, be := repository.TestRepositoryWithVersion(t, 3)
+
+	var oldBlobs, newBlobs [][]byte
+	for i := 0; i < 200; i++ {
+		oldBlobs = append(oldBlobs, similarBlob(i))
+		newBlobs = append(newBlobs, similarBlob(1000+i))
+	}
+	oldIDs, oldSize := saveBlobs(t, repo, oldBlobs)
+
+	_, err := repository.TrainCompressionDictionary(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(repo.Config().CompressionDictionaries))
+
+	newIDs, newSize := saveBlobs(t, repo, newBlobs)
+	rtest.Assert(t, newSize < oldSize, "dictionary did not improve compression, old size %v, new size %v", oldSize, newSize)
+
+	// blobs compressed with and without the dictionary can be loaded
+	repo = repository.TestOpenBackend(t, be)
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+	rtest.Equals(t, 1, len(repo.Config().CompressionDictionaries))
+	for i, id := range append(oldIDs, newIDs...) {
+		buf, err := repo.LoadBlob(context.TODO(), restic.DataBlob, id, nil)
+		rtest.OK(t, err)
+		rtest.Equals(t, append(oldBlobs, newBlobs...)[i], buf)
+	}
+}
+
+func TestTrainCompressionDictionaryErrors(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 2)
+	saveBlobs(t, repo, [][]byte{similarBlob(0)})
+	_, err := repository.TrainCompressionDictionary(context.TODO(), repo)
+	rtest.Assert(t, err != nil, "training a dictionary for a v2 repository did not fail")
+
+	repo, _ = repository.TestRepositoryWithVersion(t, 3)
+	saveBlobs(t, repo, [][]byte{similarBlob(0)})
+	_, err = repository.TrainCompressionDictionary(context.TODO(), repo)
+	rtest.Assert(t, err != nil, "training a dictionary with too few blobs did not fail")
+	rtest.Equals(t, 0, len(repo.Config().CompressionDictionaries))
+}
@@ -0,0 +1,669 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// A MappedIndex holds index entries in a file, usually stored in the local
+// cache, which is memory-mapped instead of being loaded into memory. For
+// repositories with tens of millions of blobs, the in-memory index requires
+// several GB of memory. For a MappedIndex, the operating system only keeps
+// the recently accessed pages of the file in memory and can drop them again
+// at any time.
+//
+// All integers in the file are stored in little endian byte order. The file
+// consists of the following sections:
+//
+//	header     magic, format version (uint32), zero (uint32), followed by
+//	           the number of index files, packs and blobs of each blob type
+//	           (uint64 each)
+//	index IDs  IDs of all index files contained in the mapped index, sorted
+//	packs      IDs of all pack files, sorted
+//	for each blob type:
+//	  fanout   256 uint32, entry i is the number of blobs whose ID starts
+//	           with a byte less than or equal to i
+//	  blobs    blob ID, pack number, offset, length and uncompressed length
+//	           (uint32 each), sorted by blob ID
+//	mac        HMAC-SHA256 of all preceding data
+//
+// As all sections are sorted, a lookup is a binary search which only touches
+// a few pages of the file. The MAC key is derived from the master key of the
+// repository, such that a modified file or one written for a different
+// repository is rejected instead of returning wrong pack locations.
+type MappedIndex struct {
+	// m is held by background goroutines which access data
+	m     sync.RWMutex
+	data  []byte
+	unmap func() error
+
+	ids    []byte
+	packs  []byte
+	fanout [restic.NumBlobTypes][]byte
+	blobs  [restic.NumBlobTypes][]byte
+}
+
+const (
+	mappedIndexMagic   = "RSTCIDXM"
+	mappedIndexVersion = 2
+
+	mappedIDSize     = len(restic.ID{})
+	mappedHeaderSize = len(mappedIndexMagic) + 2*4 + (2+int(restic.NumBlobTypes))*8
+	mappedFanoutSize = 256 * 4
+	mappedEntrySize  = mappedIDSize + 4*4
+	mappedMACSize    = sha256.Size
+)
+
+// mappedEntry is the decoded form of a blob entry of a MappedIndex.
+type mappedEntry struct {
+	id                 restic.ID
+	packIndex          uint32
+	offset             uint32
+	length             uint32
+	uncompressedLength uint32
+}
+
+func (e *mappedEntry) encode(buf []byte) {
+	copy(buf, e.id[:])
+	binary.LittleEndian.PutUint32(buf[mappedIDSize:], e.packIndex)
+	binary.LittleEndian.PutUint32(buf[mappedIDSize+4:], e.offset)
+	binary.LittleEndian.PutUint32(buf[mappedIDSize+8:], e.length)
+	binary.LittleEndian.PutUint32(buf[mappedIDSize+12:], e.uncompressedLength)
+}
+
+func decodeMappedEntry(buf []byte) (e mappedEntry) {
+	copy(e.id[:], buf)
+	e.packIndex = binary.LittleEndian.Uint32(buf[mappedIDSize:])
+	e.offset = binary.LittleEndian.Uint32(buf[mappedIDSize+4:])
+	e.length = binary.LittleEndian.Uint32(buf[mappedIDSize+8:])
+	e.uncompressedLength = binary.LittleEndian.Uint32(buf[mappedIDSize+12:])
+	return e
+}
+
+// compareMappedEntries orders entries by blob ID. Entries with the same blob
+// ID are ordered by their remaining fields, such that exact duplicates are
+// adjacent.
+func compareMappedEntries(a, b mappedEntry) int {
+	if c := bytes.Compare(a.id[:], b.id[:]); c != 0 {
+		return c
+	}
+	for _, c := range [...]int{
+		cmpUint32(a.packIndex, b.packIndex),
+		cmpUint32(a.offset, b.offset),
+		cmpUint32(a.length, b.length),
+		cmpUint32(a.uncompressedLength, b.uncompressedLength),
+	} {
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func cmpUint32(a, b uint32) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// OpenMappedIndex opens the mapped index stored in filename and verifies it
+// using key. As the whole file is read once, opening it takes time
+// proportional to its size.
+func OpenMappedIndex(filename string, key []byte) (*MappedIndex, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer func() {
+		// the mapping stays valid after the file is closed
+		_ = f.Close()
+	}()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if fi.Size() < int64(mappedHeaderSize+mappedMACSize) || fi.Size() > math.MaxInt {
+		return nil, errors.Errorf("mapped index %v has invalid size %d", filename, fi.Size())
+	}
+
+	data, unmap, err := mmapFile(f, int(fi.Size()))
+	if err != nil {
+		return nil, errors.Wrap(err, "mmap")
+	}
+
+	m := &MappedIndex{data: data, unmap: unmap}
+	err = m.verify(key)
+	if err == nil {
+		err = m.parse()
+	}
+	if err == nil {
+		err = m.check()
+	}
+	if err != nil {
+		_ = unmap()
+		return nil, fmt.Errorf("invalid mapped index %v: %w", filename, err)
+	}
+	return m, nil
+}
+
+// verify checks the MAC at the end of the file.
+func (m *MappedIndex) verify(key []byte) error {
+	content := m.data[:len(m.data)-mappedMACSize]
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(content)
+	if !hmac.Equal(mac.Sum(nil), m.data[len(content):]) {
+		return errors.New("MAC mismatch")
+	}
+	return nil
+}
+
+// parse splits the file into its sections.
+func (m *MappedIndex) parse() error {
+	if string(m.data[:len(mappedIndexMagic)]) != mappedIndexMagic {
+		return errors.New("invalid magic")
+	}
+	header := m.data[len(mappedIndexMagic):mappedHeaderSize]
+	if v := binary.LittleEndian.Uint32(header); v != mappedIndexVersion {
+		return errors.Errorf("unsupported version %d", v)
+	}
+	counts := header[8:]
+
+	rest := m.data[mappedHeaderSize : len(m.data)-mappedMACSize]
+	// take returns the next section of the file, which has n elements of
+	// the given size
+	take := func(n uint64, size int) ([]byte, error) {
+		if n > uint64(len(rest)/size) {
+			return nil, errors.New("file is truncated")
+		}
+		section := rest[:int(n)*size]
+		rest = rest[len(section):]
+		return section, nil
+	}
+
+	var err error
+	m.ids, err = take(binary.LittleEndian.Uint64(counts[0:]), mappedIDSize)
+	if err != nil {
+		return err
+	}
+	m.packs, err = take(binary.LittleEndian.Uint64(counts[8:]), mappedIDSize)
+	if err != nil {
+		return err
+	}
+	for t := range m.blobs {
+		m.fanout[t], err = take(1, mappedFanoutSize)
+		if err != nil {
+			return err
+		}
+		n := binary.LittleEndian.Uint64(counts[16+8*t:])
+		m.blobs[t], err = take(n, mappedEntrySize)
+		if err != nil {
+			return err
+		}
+		if uint64(m.fanoutAt(restic.BlobType(t), 255)) != n {
+			return errors.New("inconsistent fanout table")
+		}
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+// check verifies that all sections are sorted, that the fanout tables match
+// the blob entries and that all pack numbers are valid. Afterwards, lookups
+// cannot access data outside of the file.
+func (m *MappedIndex) check() error {
+	for i := 1; i < m.numPacks(); i++ {
+		prev := m.packs[(i-1)*mappedIDSize : i*mappedIDSize]
+		if bytes.Compare(prev, m.packs[i*mappedIDSize:(i+1)*mappedIDSize]) >= 0 {
+			return errors.New("packs are not sorted")
+		}
+	}
+
+	for t := range m.blobs {
+		bt := restic.BlobType(t)
+		var last uint32
+		for b := 0; b < 256; b++ {
+			v := m.fanoutAt(bt, byte(b))
+			if v < last {
+				return errors.New("inconsistent fanout table")
+			}
+			last = v
+		}
+
+		var prev mappedEntry
+		for i := 0; i < m.numBlobs(bt); i++ {
+			e := m.entry(bt, i)
+			if int(e.packIndex) >= m.numPacks() {
+				return errors.Errorf("pack number %d out of range", e.packIndex)
+			}
+			if i > 0 && compareMappedEntries(prev, e) > 0 {
+				return errors.New("blobs are not sorted")
+			}
+			lo := uint32(0)
+			if e.id[0] > 0 {
+				lo = m.fanoutAt(bt, e.id[0]-1)
+			}
+			if uint32(i) < lo || uint32(i) >= m.fanoutAt(bt, e.id[0]) {
+				return errors.New("blob does not match fanout table")
+			}
+			prev = e
+		}
+	}
+	return nil
+}
+
+// Close unmaps the file. The MappedIndex must not be used afterwards.
+func (m *MappedIndex) Close() error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return m.unmap()
+}
+
+func (m *MappedIndex) fanoutAt(t restic.BlobType, b byte) uint32 {
+	return binary.LittleEndian.Uint32(m.fanout[t][int(b)*4:])
+}
+
+func (m *MappedIndex) numBlobs(t restic.BlobType) int {
+	return len(m.blobs[t]) / mappedEntrySize
+}
+
+func (m *MappedIndex) numPacks() int {
+	return len(m.packs) / mappedIDSize
+}
+
+func (m *MappedIndex) entry(t restic.BlobType, i int) mappedEntry {
+	return decodeMappedEntry(m.blobs[t][i*mappedEntrySize:])
+}
+
+func (m *MappedIndex) packID(i uint32) (id restic.ID) {
+	if int(i) >= m.numPacks() {
+		panic(fmt.Sprintf("mapped index is damaged, pack number %d out of range", i))
+	}
+	copy(id[:], m.packs[int(i)*mappedIDSize:])
+	return id
+}
+
+func (m *MappedIndex) toPackedBlob(e mappedEntry, t restic.BlobType) restic.PackedBlob {
+	return restic.PackedBlob{
+		Blob: restic.Blob{
+			BlobHandle: restic.BlobHandle{
+				ID:   e.id,
+				Type: t},
+			Length:             uint(e.length),
+			Offset:             uint(e.offset),
+			UncompressedLength: uint(e.uncompressedLength),
+		},
+		PackID: m.packID(e.packIndex),
+	}
+}
+
+// search returns the position of the first blob of the given type and ID, or
+// -1 if the blob is unknown.
+func (m *MappedIndex) search(bh restic.BlobHandle) int {
+	n := m.numBlobs(bh.Type)
+	lo, hi := 0, min(int(m.fanoutAt(bh.Type, bh.ID[0])), n)
+	if bh.ID[0] > 0 {
+		lo = min(int(m.fanoutAt(bh.Type, bh.ID[0]-1)), hi)
+	}
+
+	blobs := m.blobs[bh.Type]
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		pos := (lo + i) * mappedEntrySize
+		return bytes.Compare(blobs[pos:pos+mappedIDSize], bh.ID[:]) >= 0
+	})
+	if i >= hi {
+		return -1
+	}
+	pos := i * mappedEntrySize
+	if !bytes.Equal(blobs[pos:pos+mappedIDSize], bh.ID[:]) {
+		return -1
+	}
+	return i
+}
+
+// Lookup queries the index for the blob ID and returns all entries including
+// duplicates. Adds found entries to blobs and returns the result.
+func (m *MappedIndex) Lookup(bh restic.BlobHandle, pbs []restic.PackedBlob) []restic.PackedBlob {
+	i := m.search(bh)
+	if i < 0 {
+		return pbs
+	}
+	for ; i < m.numBlobs(bh.Type); i++ {
+		e := m.entry(bh.Type, i)
+		if e.id != bh.ID {
+			break
+		}
+		pbs = append(pbs, m.toPackedBlob(e, bh.Type))
+	}
+	return pbs
+}
+
+// Has returns true iff the id is listed in the index.
+func (m *MappedIndex) Has(bh restic.BlobHandle) bool {
+	return m.search(bh) >= 0
+}
+
+// LookupSize returns the length of the plaintext content of the blob with the
+// given id.
+func (m *MappedIndex) LookupSize(bh restic.BlobHandle) (plaintextLength uint, found bool) {
+	i := m.search(bh)
+	if i < 0 {
+		return 0, false
+	}
+	e := m.entry(bh.Type, i)
+	if e.uncompressedLength != 0 {
+		return uint(e.uncompressedLength), true
+	}
+	return uint(crypto.PlaintextLength(int(e.length))), true
+}
+
+// BlobIndex returns a number in the range 1 to Len(bh.Type) which identifies
+// the first entry for the blob, or -1 if the blob is unknown.
+func (m *MappedIndex) BlobIndex(bh restic.BlobHandle) int {
+	i := m.search(bh)
+	if i < 0 {
+		return -1
+	}
+	return i + 1
+}
+
+// Len returns the number of entries for the blob type.
+func (m *MappedIndex) Len(t restic.BlobType) uint {
+	return uint(m.numBlobs(t))
+}
+
+// Each passes all blobs known to the index to the callback fn.
+func (m *MappedIndex) Each(ctx context.Context, fn func(restic.PackedBlob)) error {
+	for t := range m.blobs {
+		for i := 0; i < m.numBlobs(restic.BlobType(t)); i++ {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fn(m.toPackedBlob(m.entry(restic.BlobType(t), i), restic.BlobType(t)))
+		}
+	}
+	return ctx.Err()
+}
+
+// EachByPack returns a channel that yields all blobs known to the index
+// grouped by packID but ignoring blobs with a packID in packBlacklist. When
+// the context is cancelled, the background goroutine terminates. This blocks
+// closing the index.
+func (m *MappedIndex) EachByPack(ctx context.Context, packBlacklist restic.IDSet) <-chan EachByPackResult {
+	m.m.RLock()
+
+	ch := make(chan EachByPackResult)
+
+	go func() {
+		defer m.m.RUnlock()
+		defer close(ch)
+
+		byPack := make(map[uint32][]restic.Blob)
+		for t := range m.blobs {
+			for i := 0; i < m.numBlobs(restic.BlobType(t)); i++ {
+				e := m.entry(restic.BlobType(t), i)
+				pb := m.toPackedBlob(e, restic.BlobType(t))
+				if packBlacklist.Has(pb.PackID) {
+					continue
+				}
+				byPack[e.packIndex] = append(byPack[e.packIndex], pb.Blob)
+			}
+		}
+
+		for packIndex, blobs := range byPack {
+			// allow GC once entry is no longer necessary
+			delete(byPack, packIndex)
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- EachByPackResult{PackID: m.packID(packIndex), Blobs: blobs}:
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Packs returns all packs in this index.
+func (m *MappedIndex) Packs() restic.IDSet {
+	packs := restic.NewIDSet()
+	for i := 0; i < m.numPacks(); i++ {
+		packs.Insert(m.packID(uint32(i)))
+	}
+	return packs
+}
+
+// IDs returns the IDs of all index files contained in the index.
+func (m *MappedIndex) IDs() restic.IDs {
+	ids := make(restic.IDs, len(m.ids)/mappedIDSize)
+	for i := range ids {
+		copy(ids[i][:], m.ids[i*mappedIDSize:])
+	}
+	return ids
+}
+
+// WriteMappedIndex writes a mapped index to filename, which contains all
+// entries of the final index idx and of base, which may be nil. Exact
+// duplicates are removed. The file is authenticated using key. It is replaced
+// atomically, such that base may have been opened from the same file.
+func WriteMappedIndex(filename string, key []byte, base *MappedIndex, idx *Index) (err error) {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	if !idx.final {
+		return errors.New("index is not final")
+	}
+	if base == nil {
+		base = &MappedIndex{}
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+"-*.tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	w := &mappedIndexWriter{wr: bufio.NewWriter(f)}
+	w.write(base, idx)
+	if err := w.wr.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// fill in the header and fanout tables, which are only known now
+	if _, err := f.WriteAt(w.header(), 0); err != nil {
+		return errors.WithStack(err)
+	}
+	for t := range w.fanout {
+		buf := make([]byte, mappedFanoutSize)
+		for i, v := range w.fanout[t] {
+			binary.LittleEndian.PutUint32(buf[i*4:], v)
+		}
+		if _, err := f.WriteAt(buf, w.fanoutPos[t]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	mac := hmac.New(sha256.New, key)
+	if _, err := io.Copy(mac, io.NewSectionReader(f, 0, w.pos)); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := f.WriteAt(mac.Sum(nil), w.pos); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(f.Name(), filename))
+}
+
+// mappedIndexWriter writes the sections of a MappedIndex. Errors are
+// recorded by the bufio.Writer and returned by its Flush method.
+type mappedIndexWriter struct {
+	wr  *bufio.Writer
+	pos int64
+
+	numIDs, numPacks uint64
+	numBlobs         [restic.NumBlobTypes]uint64
+	fanoutPos        [restic.NumBlobTypes]int64
+	fanout           [restic.NumBlobTypes][256]uint32
+}
+
+func (w *mappedIndexWriter) writeBytes(buf []byte) {
+	_, _ = w.wr.Write(buf)
+	w.pos += int64(len(buf))
+}
+
+func (w *mappedIndexWriter) header() []byte {
+	buf := make([]byte, mappedHeaderSize)
+	copy(buf, mappedIndexMagic)
+	counts := buf[len(mappedIndexMagic):]
+	binary.LittleEndian.PutUint32(counts, mappedIndexVersion)
+	counts = counts[8:]
+	binary.LittleEndian.PutUint64(counts[0:], w.numIDs)
+	binary.LittleEndian.PutUint64(counts[8:], w.numPacks)
+	for t, n := range w.numBlobs {
+		binary.LittleEndian.PutUint64(counts[16+8*t:], n)
+	}
+	return buf
+}
+
+func (w *mappedIndexWriter) write(base *MappedIndex, idx *Index) {
+	// header is filled in later
+	w.writeBytes(make([]byte, mappedHeaderSize))
+
+	ids := append(base.IDs(), idx.ids...)
+	slices.SortFunc(ids, func(a, b restic.ID) int { return bytes.Compare(a[:], b[:]) })
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		w.writeBytes(id[:])
+		w.numIDs++
+	}
+
+	baseRemap, idxRemap := w.writePacks(base, idx)
+
+	for t := range w.fanout {
+		w.fanoutPos[t] = w.pos
+		w.writeBytes(make([]byte, mappedFanoutSize))
+		w.writeBlobs(restic.BlobType(t), base, baseRemap, idx, idxRemap)
+	}
+}
+
+// writePacks writes the sorted pack IDs of base and idx without duplicates.
+// It returns the new pack numbers for the packs of base and idx.
+func (w *mappedIndexWriter) writePacks(base *MappedIndex, idx *Index) (baseRemap, idxRemap []uint32) {
+	numBase := base.numPacks()
+	baseRemap = make([]uint32, numBase)
+	idxRemap = make([]uint32, len(idx.packs))
+
+	order := make([]int, len(idx.packs))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return bytes.Compare(idx.packs[a][:], idx.packs[b][:])
+	})
+
+	var last restic.ID
+	emit := func(id restic.ID) uint32 {
+		if w.numPacks == 0 || id != last {
+			w.writeBytes(id[:])
+			w.numPacks++
+			last = id
+		}
+		return uint32(w.numPacks - 1)
+	}
+
+	i, j := 0, 0
+	for i < numBase || j < len(order) {
+		if j == len(order) || (i < numBase && bytes.Compare(base.packs[i*mappedIDSize:(i+1)*mappedIDSize], idx.packs[order[j]][:]) <= 0) {
+			baseRemap[i] = emit(base.packID(uint32(i)))
+			i++
+		} else {
+			idxRemap[order[j]] = emit(idx.packs[order[j]])
+			j++
+		}
+	}
+	return baseRemap, idxRemap
+}
+
+// writeBlobs merges the sorted entries of base with the entries of idx.
+func (w *mappedIndexWriter) writeBlobs(t restic.BlobType, base *MappedIndex, baseRemap []uint32, idx *Index, idxRemap []uint32) {
+	entries := make([]mappedEntry, 0, idx.byType[t].len())
+	idx.byType[t].foreach(func(e *indexEntry) bool {
+		entries = append(entries, mappedEntry{
+			id:                 e.id,
+			packIndex:          idxRemap[e.packIndex],
+			offset:             e.offset,
+			length:             e.length,
+			uncompressedLength: e.uncompressedLength,
+		})
+		return true
+	})
+	slices.SortFunc(entries, compareMappedEntries)
+
+	var buf [mappedEntrySize]byte
+	var last mappedEntry
+	emit := func(e mappedEntry) {
+		if w.numBlobs[t] > 0 && e == last {
+			return
+		}
+		e.encode(buf[:])
+		w.writeBytes(buf[:])
+		w.numBlobs[t]++
+		w.fanout[t][e.id[0]]++
+		last = e
+	}
+
+	// the new pack numbers of base are strictly increasing, hence the
+	// entries of base stay sorted
+	numBase := base.numBlobs(t)
+	i, j := 0, 0
+	for i < numBase || j < len(entries) {
+		if i < numBase {
+			e := base.entry(t, i)
+			e.packIndex = baseRemap[e.packIndex]
+			if j == len(entries) || compareMappedEntries(e, entries[j]) <= 0 {
+				emit(e)
+				i++
+				continue
+			}
+		}
+		emit(entries[j])
+		j++
+	}
+
+	// convert the counts to cumulative counts
+	for b := 1; b < len(w.fanout[t]); b++ {
+		w.fanout[t][b] += w.fanout[t][b-1]
+	}
+}
@@ -0,0 +1,309 @@
+package index_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository/index"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+var testMappedKey = []byte("mapped index test key")
+
+func createFinalRandomIndex(t testing.TB, rng *rand.Rand, packfiles int) *index.Index {
+	idx, _ := createRandomIndex(rng, packfiles)
+
+	// add a tree blob and a duplicate of a data blob in a different pack
+	var dup restic.Blob
+	rtest.OK(t, idx.Each(context.TODO(), func(pb restic.PackedBlob) {
+		dup = pb.Blob
+	}))
+	idx.StorePack(NewRandomTestID(rng), []restic.Blob{
+		dup,
+		{BlobHandle: restic.BlobHandle{Type: restic.TreeBlob, ID: NewRandomTestID(rng)}, Length: 100, Offset: dup.Length},
+	})
+
+	idx.Finalize()
+	rtest.OK(t, idx.SetID(NewRandomTestID(rng)))
+	return idx
+}
+
+func listBlobs(t testing.TB, idx interface {
+	Each(context.Context, func(restic.PackedBlob)) error
+}) map[restic.PackedBlob]int {
+	blobs := make(map[restic.PackedBlob]int)
+	rtest.OK(t, idx.Each(context.TODO(), func(pb restic.PackedBlob) {
+		blobs[pb]++
+	}))
+	return blobs
+}
+
+func lookupSet(pbs []restic.PackedBlob) map[restic.PackedBlob]struct{} {
+	set := make(map[restic.PackedBlob]struct{})
+	for _, pb := range pbs {
+		set[pb] = struct{}{}
+	}
+	return set
+}
+
+func TestMappedIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idx := createFinalRandomIndex(t, rng, 20)
+
+	filename := filepath.Join(t.TempDir(), "index.mapped")
+	rtest.OK(t, index.WriteMappedIndex(filename, testMappedKey, nil, idx))
+	m, err := index.OpenMappedIndex(filename, testMappedKey)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, m.Close())
+	}()
+
+	rtest.Equals(t, listBlobs(t, idx), listBlobs(t, m))
+	rtest.Equals(t, idx.Packs(), m.Packs())
+	ids, err := idx.IDs()
+	rtest.OK(t, err)
+	rtest.Equals(t, ids, m.IDs())
+
+	blobIndexes := make(map[int]struct{})
+	rtest.OK(t, idx.Each(context.TODO(), func(pb restic.PackedBlob) {
+		rtest.Assert(t, m.Has(pb.BlobHandle), "blob %v missing", pb.BlobHandle)
+		rtest.Equals(t, lookupSet(idx.Lookup(pb.BlobHandle, nil)), lookupSet(m.Lookup(pb.BlobHandle, nil)))
+
+		size, found := m.LookupSize(pb.BlobHandle)
+		rtest.Assert(t, found, "blob %v missing", pb.BlobHandle)
+		rtest.Equals(t, pb.DataLength(), size)
+
+		i := m.BlobIndex(pb.BlobHandle)
+		rtest.Assert(t, i >= 1 && i <= int(m.Len(pb.Type)), "blob index %d out of range", i)
+		blobIndexes[int(pb.Type)<<32|i] = struct{}{}
+	}))
+	// duplicates share their blob index
+	rtest.Equals(t, int(idx.Len(restic.DataBlob)+idx.Len(restic.TreeBlob))-1, len(blobIndexes))
+
+	for _, t2 := range []restic.BlobType{restic.DataBlob, restic.TreeBlob} {
+		rtest.Equals(t, idx.Len(t2), m.Len(t2))
+		unknown := restic.BlobHandle{Type: t2, ID: NewRandomTestID(rng)}
+		rtest.Assert(t, !m.Has(unknown), "unknown blob %v found", unknown)
+		rtest.Equals(t, -1, m.BlobIndex(unknown))
+		rtest.Equals(t, 0, len(m.Lookup(unknown, nil)))
+	}
+
+	// skip the first pack
+	var skipped restic.ID
+	for id := range m.Packs() {
+		skipped = id
+		break
+	}
+	packs := restic.NewIDSet()
+	for pbs := range m.EachByPack(context.TODO(), restic.NewIDSet(skipped)) {
+		rtest.Assert(t, !packs.Has(pbs.PackID), "pack %v returned twice", pbs.PackID)
+		packs.Insert(pbs.PackID)
+		rtest.Equals(t, lookupSet(listPack(t, idx, pbs.PackID)), lookupSet(toPackedBlobs(pbs)))
+	}
+	rtest.Equals(t, idx.Packs().Sub(restic.NewIDSet(skipped)), packs)
+}
+
+func toPackedBlobs(pbs index.EachByPackResult) []restic.PackedBlob {
+	var result []restic.PackedBlob
+	for _, blob := range pbs.Blobs {
+		result = append(result, restic.PackedBlob{Blob: blob, PackID: pbs.PackID})
+	}
+	return result
+}
+
+func TestMappedIndexMerge(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idx1 := createFinalRandomIndex(t, rng, 10)
+	idx2 := createFinalRandomIndex(t, rng, 10)
+
+	filename := filepath.Join(t.TempDir(), "index.mapped")
+	rtest.OK(t, index.WriteMappedIndex(filename, testMappedKey, nil, idx1))
+	base, err := index.OpenMappedIndex(filename, testMappedKey)
+	rtest.OK(t, err)
+
+	expected := listBlobs(t, idx1)
+	for pb, n := range listBlobs(t, idx2) {
+		expected[pb] += n
+	}
+
+	rtest.OK(t, index.WriteMappedIndex(filename, testMappedKey, base, idx2))
+	rtest.OK(t, base.Close())
+	m, err := index.OpenMappedIndex(filename, testMappedKey)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, m.Close())
+	}()
+	rtest.Equals(t, expected, listBlobs(t, m))
+
+	// merging an index twice must not duplicate its entries
+	rtest.OK(t, index.WriteMappedIndex(filename, testMappedKey, m, idx2))
+	m2, err := index.OpenMappedIndex(filename, testMappedKey)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, m2.Close())
+	}()
+	rtest.Equals(t, expected, listBlobs(t, m2))
+
+	ids1, _ := idx1.IDs()
+	ids2, _ := idx2.IDs()
+	rtest.Equals(t, restic.NewIDSet(append(ids1, ids2...)...), restic.NewIDSet(m2.IDs()...))
+}
+
+func TestMappedIndexInvalid(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	idx := createFinalRandomIndex(t, rng, 2)
+
+	filename := filepath.Join(t.TempDir(), "index.mapped")
+	rtest.OK(t, index.WriteMappedIndex(filename, testMappedKey, nil, idx))
+	data, err := os.ReadFile(filename)
+	rtest.OK(t, err)
+
+	// resign replaces the MAC of a modified file
+	resign := func(buf []byte) []byte {
+		content := buf[:len(buf)-sha256.Size]
+		mac := hmac.New(sha256.New, testMappedKey)
+		_, _ = mac.Write(content)
+		return mac.Sum(content[:len(content):len(content)])
+	}
+	modify := func(pos int) []byte {
+		buf := append([]byte{}, data...)
+		buf[pos] ^= 0xff
+		return buf
+	}
+	// the first blob entry follows the header, the index and pack IDs and
+	// the first fanout table
+	numIDs := int(binary.LittleEndian.Uint64(data[16:]))
+	numPacks := int(binary.LittleEndian.Uint64(data[24:]))
+	firstEntry := 16 + (2+int(restic.NumBlobTypes))*8 + (numIDs+numPacks)*32 + 256*4
+	badPack := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(badPack[firstEntry+32:], uint32(numPacks))
+	// the first blob has the smallest ID, thus moving it to the last
+	// fanout bucket breaks the order
+	unsorted := append([]byte{}, data...)
+	unsorted[firstEntry] = 0xff
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		key  []byte
+	}{
+		{"empty", nil, testMappedKey},
+		{"truncated", data[:len(data)-1], testMappedKey},
+		{"trailing data", append(data[:len(data):len(data)], 0), testMappedKey},
+		{"magic", resign(append([]byte("XXXXXXXX"), data[8:]...)), testMappedKey},
+		{"modified", modify(firstEntry), testMappedKey},
+		{"wrong key", data, []byte("other key")},
+		{"pack out of range", resign(badPack), testMappedKey},
+		{"unsorted blobs", resign(unsorted), testMappedKey},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rtest.OK(t, os.WriteFile(filename, test.data, 0600))
+			_, err := index.OpenMappedIndex(filename, test.key)
+			rtest.Assert(t, err != nil, "invalid mapped index was accepted")
+		})
+	}
+}
+
+// countingLoader counts the loaded index files.
+type countingLoader struct {
+	restic.ListerLoaderUnpacked
+	loaded atomic.Int32
+}
+
+func (l *countingLoader) LoadUnpacked(ctx context.Context, t restic.FileType, id restic.ID) ([]byte, error) {
+	if t == restic.IndexFile {
+		l.loaded.Add(1)
+	}
+	return l.ListerLoaderUnpacked.LoadUnpacked(ctx, t, id)
+}
+
+func TestMasterIndexLoadMapped(t *testing.T) {
+	repo := createFilledRepo(t, 3, 2)
+	filename := filepath.Join(t.TempDir(), "index.mapped")
+
+	key := testMappedKey
+	loadMapped := func(expectedLoads int) *index.MasterIndex {
+		t.Helper()
+		loader := &countingLoader{ListerLoaderUnpacked: repo}
+		mi := index.NewMasterIndex()
+		rtest.OK(t, mi.LoadMapped(context.TODO(), loader, nil, filename, key))
+		rtest.Equals(t, int32(expectedLoads), loader.loaded.Load())
+
+		expected := index.NewMasterIndex()
+		rtest.OK(t, expected.Load(context.TODO(), repo, nil, nil))
+		rtest.Equals(t, listBlobs(t, expected), listBlobs(t, mi))
+		rtest.Equals(t, expected.IDs(), mi.IDs())
+		rtest.Equals(t, expected.Packs(restic.NewIDSet()), mi.Packs(restic.NewIDSet()))
+		checkAssociatedSet(t, mi)
+		return mi
+	}
+
+	numIndexes := len(listIndexes(t, repo))
+	loadMapped(numIndexes)
+	_, err := os.Stat(filename)
+	rtest.OK(t, err)
+
+	// all index files are contained in the mapped index
+	loadMapped(0)
+
+	// a mapped index for a different key is rebuilt
+	key = []byte("other key")
+	loadMapped(numIndexes)
+	loadMapped(0)
+	rtest.OK(t, os.WriteFile(filename, []byte("garbage"), 0600))
+	loadMapped(numIndexes)
+	loadMapped(0)
+
+	// only the new index file has to be loaded
+	restic.TestCreateSnapshot(t, repo, snapshotTime.Add(time.Hour), depth)
+	loadMapped(1)
+
+	// index files were removed, all index files have to be reloaded
+	mi := index.NewMasterIndex()
+	rtest.OK(t, mi.Load(context.TODO(), repo, nil, nil))
+	rtest.OK(t, mi.Rewrite(context.TODO(), repo, nil, nil, nil, index.MasterIndexRewriteOpts{}))
+	mi = loadMapped(len(listIndexes(t, repo)))
+
+	// new index entries are kept in memory in addition to the mapped index
+	bh := restic.NewRandomBlobHandle()
+	mi.StorePack(restic.NewRandomID(), []restic.Blob{{BlobHandle: bh, Length: 100}})
+	rtest.OK(t, mi.SaveIndex(context.TODO(), repo))
+	rtest.Assert(t, mi.Has(bh), "new blob %v missing", bh)
+	checkAssociatedSet(t, mi)
+}
+
+// checkAssociatedSet verifies that all blobs have a distinct position in an
+// AssociatedSet.
+func checkAssociatedSet(t testing.TB, mi *index.MasterIndex) {
+	set := index.NewAssociatedSet[int](mi)
+	i := 0
+	rtest.OK(t, mi.Each(context.TODO(), func(pb restic.PackedBlob) {
+		set.Set(pb.BlobHandle, i)
+		i++
+	}))
+	j := 0
+	rtest.OK(t, mi.Each(context.TODO(), func(pb restic.PackedBlob) {
+		v, ok := set.Get(pb.BlobHandle)
+		rtest.Assert(t, ok, "blob %v missing", pb.BlobHandle)
+		rtest.Equals(t, j, v)
+		j++
+	}))
+}
+
+func listIndexes(t testing.TB, repo restic.Lister) restic.IDSet {
+	s := restic.NewIDSet()
+	rtest.OK(t, repo.List(context.TODO(), restic.IndexFile, func(id restic.ID, _ int64) error {
+		s.Insert(id)
+		return nil
+	}))
+	return s
+}
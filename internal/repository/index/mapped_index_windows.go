@@ -0,0 +1,17 @@
+package index
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads the first size bytes of f into memory. Memory-mapping the
+// file is not implemented on Windows, such that a MappedIndex only saves the
+// time to decode the index files.
+func mmapFile(f *os.File, size int) ([]byte, func() error, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
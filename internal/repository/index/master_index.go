@@ -3,10 +3,12 @@ package index
 import (
 	"context"
 	"fmt"
+	"os"
 	"runtime"
 	"sync"
 
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui/progress"
 	"golang.org/x/sync/errgroup"
@@ -15,6 +17,7 @@ import (
 // MasterIndex is a collection of indexes and IDs of chunks that are in the process of being saved.
 type MasterIndex struct {
 	idx          []*Index
+	mapped       *MappedIndex // optional, contains final index entries only
 	pendingBlobs restic.BlobSet
 	idxMutex     sync.RWMutex
 }
@@ -27,6 +30,16 @@ func NewMasterIndex() *MasterIndex {
 }
 
 func (mi *MasterIndex) clear() {
+	if mi.mapped != nil {
+		if err := mi.mapped.Close(); err != nil {
+			debug.Log("closing mapped index failed: %v", err)
+		}
+		mi.mapped = nil
+	}
+	mi.clearIndexes()
+}
+
+func (mi *MasterIndex) clearIndexes() {
 	// Always add an empty final index, such that MergeFinalIndexes can merge into this.
 	mi.idx = []*Index{NewIndex()}
 	mi.idx[0].Finalize()
@@ -37,6 +50,9 @@ func (mi *MasterIndex) Lookup(bh restic.BlobHandle) (pbs []restic.PackedBlob) {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
 
+	if mi.mapped != nil {
+		pbs = mi.mapped.Lookup(bh, pbs)
+	}
 	for _, idx := range mi.idx {
 		pbs = idx.Lookup(bh, pbs)
 	}
@@ -49,6 +65,11 @@ func (mi *MasterIndex) LookupSize(bh restic.BlobHandle) (uint, bool) {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
 
+	if mi.mapped != nil {
+		if size, found := mi.mapped.LookupSize(bh); found {
+			return size, found
+		}
+	}
 	for _, idx := range mi.idx {
 		if size, found := idx.LookupSize(bh); found {
 			return size, found
@@ -72,6 +93,9 @@ func (mi *MasterIndex) AddPending(bh restic.BlobHandle) bool {
 		return false
 	}
 
+	if mi.mapped != nil && mi.mapped.Has(bh) {
+		return false
+	}
 	for _, idx := range mi.idx {
 		if idx.Has(bh) {
 			return false
@@ -94,6 +118,9 @@ func (mi *MasterIndex) Has(bh restic.BlobHandle) bool {
 		return true
 	}
 
+	if mi.mapped != nil && mi.mapped.Has(bh) {
+		return true
+	}
 	for _, idx := range mi.idx {
 		if idx.Has(bh) {
 			return true
@@ -109,6 +136,9 @@ func (mi *MasterIndex) IDs() restic.IDSet {
 	defer mi.idxMutex.RUnlock()
 
 	ids := restic.NewIDSet()
+	if mi.mapped != nil {
+		ids.Merge(restic.NewIDSet(mi.mapped.IDs()...))
+	}
 	for _, idx := range mi.idx {
 		if !idx.Final() {
 			continue
@@ -133,6 +163,9 @@ func (mi *MasterIndex) Packs(packBlacklist restic.IDSet) restic.IDSet {
 	defer mi.idxMutex.RUnlock()
 
 	packs := restic.NewIDSet()
+	if mi.mapped != nil {
+		packs.Merge(mi.mapped.Packs().Sub(packBlacklist))
+	}
 	for _, idx := range mi.idx {
 		idxPacks := idx.Packs()
 		if idx.final && len(packBlacklist) > 0 {
@@ -225,6 +258,11 @@ func (mi *MasterIndex) Each(ctx context.Context, fn func(restic.PackedBlob)) err
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
 
+	if mi.mapped != nil {
+		if err := mi.mapped.Each(ctx, fn); err != nil {
+			return err
+		}
+	}
 	for _, idx := range mi.idx {
 		if err := idx.Each(ctx, fn); err != nil {
 			return err
@@ -309,10 +347,144 @@ func (mi *MasterIndex) Load(ctx context.Context, r restic.ListerLoaderUnpacked,
 	return mi.MergeFinalIndexes()
 }
 
+// mappedIndexRebuildRatio controls when LoadMapped rebuilds the mapped index.
+// This happens once the index entries kept in memory exceed the given
+// fraction of the entries in the mapped index.
+const mappedIndexRebuildRatio = 16
+
+// LoadMapped loads the index like Load, but keeps the final index entries in
+// the mapped index stored in filename, which is authenticated using key.
+// Index files contained in the mapped index are not loaded again, all other
+// index files are loaded into memory. The mapped index is rebuilt if it is
+// missing or invalid, if it contains index files which no longer exist or if
+// too many entries are kept in memory. Failing to write the mapped index is
+// not an error, the entries then stay in memory.
+func (mi *MasterIndex) LoadMapped(ctx context.Context, r restic.ListerLoaderUnpacked, p *progress.Counter, filename string, key []byte) error {
+	indexList, err := restic.MemorizeList(ctx, r, restic.IndexFile)
+	if err != nil {
+		return err
+	}
+
+	existing := restic.NewIDSet()
+	err = indexList.List(ctx, restic.IndexFile, func(id restic.ID, _ int64) error {
+		existing.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	mapped := openMappedIndex(filename, key, existing)
+	contained := restic.NewIDSet()
+	if mapped != nil {
+		contained = restic.NewIDSet(mapped.IDs()...)
+	}
+
+	p.SetMax(uint64(len(existing)))
+	p.Add(uint64(len(contained)))
+	defer p.Done()
+
+	err = ForAllIndexes(ctx, &skipLister{Lister: indexList, skip: contained}, r, func(_ restic.ID, idx *Index, err error) error {
+		p.Add(1)
+		if err != nil {
+			return err
+		}
+		mi.Insert(idx)
+		return nil
+	})
+	if err == nil {
+		err = mi.MergeFinalIndexes()
+	}
+	if err != nil {
+		if mapped != nil {
+			_ = mapped.Close()
+		}
+		return err
+	}
+
+	mi.idxMutex.Lock()
+	defer mi.idxMutex.Unlock()
+
+	mi.mapped = mapped
+	if len(mi.idx) != 1 {
+		// only a single final index can be moved into the mapped index
+		return nil
+	}
+
+	var inMemory, inMapped uint
+	for t := restic.BlobType(0); t < restic.NumBlobTypes; t++ {
+		inMemory += mi.idx[0].Len(t)
+		if mapped != nil {
+			inMapped += mapped.Len(t)
+		}
+	}
+	if inMemory == 0 || (mapped != nil && inMemory*mappedIndexRebuildRatio < inMapped) {
+		return nil
+	}
+
+	debug.Log("rebuilding mapped index with %d entries from memory and %d mapped entries", inMemory, inMapped)
+	if err := WriteMappedIndex(filename, key, mapped, mi.idx[0]); err != nil {
+		debug.Log("writing mapped index failed: %v", err)
+		return nil
+	}
+	newMapped, err := OpenMappedIndex(filename, key)
+	if err != nil {
+		debug.Log("opening rebuilt mapped index failed: %v", err)
+		return nil
+	}
+	if mapped != nil {
+		_ = mapped.Close()
+	}
+	mi.mapped = newMapped
+	mi.clearIndexes()
+	return nil
+}
+
+// openMappedIndex opens the mapped index stored in filename if it only
+// contains index files which still exist. Otherwise nil is returned. An
+// invalid mapped index is removed, such that it is rebuilt afterwards.
+func openMappedIndex(filename string, key []byte, existing restic.IDSet) *MappedIndex {
+	m, err := OpenMappedIndex(filename, key)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("removing invalid mapped index: %v", err)
+			_ = os.Remove(filename)
+		}
+		return nil
+	}
+
+	for _, id := range m.IDs() {
+		if !existing.Has(id) {
+			debug.Log("mapped index is outdated, index %v no longer exists", id.Str())
+			_ = m.Close()
+			return nil
+		}
+	}
+	return m
+}
+
+// skipLister lists all files of the underlying Lister except for those in skip.
+type skipLister struct {
+	restic.Lister
+	skip restic.IDSet
+}
+
+func (l *skipLister) List(ctx context.Context, t restic.FileType, fn func(restic.ID, int64) error) error {
+	return l.Lister.List(ctx, t, func(id restic.ID, size int64) error {
+		if l.skip.Has(id) {
+			return nil
+		}
+		return fn(id, size)
+	})
+}
+
 type MasterIndexRewriteOpts struct {
 	SaveProgress   *progress.Counter
 	DeleteProgress func() *progress.Counter
 	DeleteReport   func(id restic.ID, err error)
+	// KeepIndexes lists index files which must neither be rewritten nor
+	// removed. They must not contain any of the excluded packs.
+	KeepIndexes restic.IDSet
 }
 
 // Rewrite removes packs whose ID is in excludePacks from all known indexes.
@@ -413,19 +585,19 @@ func (mi *MasterIndex) Rewrite(ctx context.Context, repo restic.Unpacked, exclud
 		defer close(saveCh)
 		newIndex := NewIndex()
 		for task := range rewriteCh {
+			ids, err := task.idx.IDs()
+			if err != nil || len(ids) != 1 {
+				panic("internal error, index has no ID")
+			}
+
 			// always rewrite indexes that include a pack that must be removed or that are not full
-			if len(task.idx.Packs().Intersect(excludePacks)) == 0 && IndexFull(task.idx) {
+			if opts.KeepIndexes.Has(ids[0]) || (len(task.idx.Packs().Intersect(excludePacks)) == 0 && IndexFull(task.idx)) {
 				// make sure that each pack is only stored exactly once in the index
 				excludePacks.Merge(task.idx.Packs())
 				// index is already up to date
 				p.Add(1)
 				continue
 			}
-
-			ids, err := task.idx.IDs()
-			if err != nil || len(ids) != 1 {
-				panic("internal error, index has no ID")
-			}
 			obsolete.Merge(restic.NewIDSet(ids...))
 
 			for pbs := range task.idx.EachByPack(wgCtx, excludePacks) {
@@ -514,6 +686,24 @@ func (mi *MasterIndex) SaveFallback(ctx context.Context, repo restic.SaverRemove
 	wg.Go(func() error {
 		defer close(ch)
 		newIndex := NewIndex()
+		if mi.mapped != nil {
+			obsolete.Merge(restic.NewIDSet(mi.mapped.IDs()...))
+			for pbs := range mi.mapped.EachByPack(wgCtx, excludePacks) {
+				newIndex.StorePack(pbs.PackID, pbs.Blobs)
+				p.Add(1)
+				if IndexFull(newIndex) {
+					select {
+					case ch <- newIndex:
+					case <-wgCtx.Done():
+						return wgCtx.Err()
+					}
+					newIndex = NewIndex()
+				}
+			}
+			if wgCtx.Err() != nil {
+				return wgCtx.Err()
+			}
+		}
 		for _, idx := range mi.idx {
 			if idx.Final() {
 				ids, err := idx.IDs()
@@ -645,6 +835,15 @@ func (mi *MasterIndex) blobIndex(h restic.BlobHandle) int {
 	defer mi.idxMutex.RUnlock()
 
 	// other indexes are ignored as their ids can change when merged into the main index
+	if mi.mapped != nil {
+		if i := mi.mapped.BlobIndex(h); i != -1 {
+			return i
+		}
+		if i := mi.idx[0].BlobIndex(h); i != -1 {
+			return int(mi.mapped.Len(h.Type)) + i
+		}
+		return -1
+	}
 	return mi.idx[0].BlobIndex(h)
 }
 
@@ -654,5 +853,8 @@ func (mi *MasterIndex) stableLen(t restic.BlobType) uint {
 	defer mi.idxMutex.RUnlock()
 
 	// other indexes are ignored as their ids can change when merged into the main index
+	if mi.mapped != nil {
+		return mi.mapped.Len(t) + mi.idx[0].Len(t)
+	}
 	return mi.idx[0].Len(t)
 }
@@ -2,10 +2,13 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/user"
+	"slices"
 	"time"
 
 	"github.com/restic/restic/internal/errors"
@@ -22,14 +25,30 @@ var (
 
 	// ErrMaxKeysReached is returned when the maximum number of keys was checked and no key could be found.
 	ErrMaxKeysReached = errors.New("maximum number of keys reached")
+
+	// ErrKeyfileRequired is returned when a key can only be opened using a
+	// keyfile in addition to the password, but no keyfile was specified.
+	ErrKeyfileRequired = errors.New("key requires a keyfile")
 )
 
+// KeyCapabilityDelete allows a key to remove pack and snapshot files from an
+// append-only repository.
+const KeyCapabilityDelete = "delete"
+
 // Key represents an encrypted master key for a repository.
 type Key struct {
 	Created  time.Time `json:"created"`
 	Username string    `json:"username"`
 	Hostname string    `json:"hostname"`
 
+	// Capabilities lists the additional permissions of the key, currently
+	// only KeyCapabilityDelete is supported.
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// Keyfile is set if the user key is derived from both the password and
+	// the content of a keyfile.
+	Keyfile bool `json:"keyfile,omitempty"`
+
 	KDF  string `json:"kdf"`
 	N    int    `json:"N"`
 	R    int    `json:"r"`
@@ -58,7 +77,14 @@ const (
 // createMasterKey creates a new master key in the given backend and encrypts
 // it with the password.
 func createMasterKey(ctx context.Context, s *Repository, password string) (*Key, error) {
-	return AddKey(ctx, s, password, "", "", nil)
+	return AddKey(ctx, s, password, "", "", nil, nil, s.keyfile)
+}
+
+// keyfilePassword combines the password with the content of a keyfile, such
+// that both are required to derive the user key.
+func keyfilePassword(password string, keyfile []byte) string {
+	h := sha256.Sum256(keyfile)
+	return password + "\x00" + hex.EncodeToString(h[:])
 }
 
 // OpenKey tries do decrypt the key specified by name with the given password.
@@ -74,6 +100,13 @@ func OpenKey(ctx context.Context, s *Repository, id restic.ID, password string)
 		return nil, errors.New("only supported KDF is scrypt()")
 	}
 
+	if k.Keyfile {
+		if len(s.keyfile) == 0 {
+			return nil, ErrKeyfileRequired
+		}
+		password = keyfilePassword(password, s.keyfile)
+	}
+
 	// derive user key
 	params := crypto.Params{
 		N: k.N,
@@ -114,6 +147,7 @@ func OpenKey(ctx context.Context, s *Repository, id restic.ID, password string)
 // zero, all keys in the repo are checked.
 func SearchKey(ctx context.Context, s *Repository, password string, maxKeys int, keyHint string) (k *Key, err error) {
 	checked := 0
+	keyfileRequired := false
 
 	if len(keyHint) > 0 {
 		id, err := restic.Find(ctx, s, restic.KeyFile, keyHint)
@@ -151,6 +185,10 @@ func SearchKey(ctx context.Context, s *Repository, password string, maxKeys int,
 			if errors.Is(err, crypto.ErrUnauthenticated) {
 				return nil
 			}
+			if errors.Is(err, ErrKeyfileRequired) {
+				keyfileRequired = true
+				return nil
+			}
 
 			return err
 		}
@@ -170,6 +208,9 @@ func SearchKey(ctx context.Context, s *Repository, password string, maxKeys int,
 	}
 
 	if k == nil {
+		if keyfileRequired {
+			return nil, fmt.Errorf("%w, some keys also require a keyfile", ErrNoKeyFound)
+		}
 		return nil, ErrNoKeyFound
 	}
 
@@ -192,8 +233,20 @@ func LoadKey(ctx context.Context, s *Repository, id restic.ID) (k *Key, err erro
 	return k, nil
 }
 
-// AddKey adds a new key to an already existing repository.
-func AddKey(ctx context.Context, s *Repository, password, username, hostname string, template *crypto.Key) (*Key, error) {
+// AddKey adds a new key to an already existing repository. Only keys with the
+// delete capability can add other keys with that capability. If keyfile is
+// not empty, the new key can only be opened using both the password and the
+// keyfile.
+func AddKey(ctx context.Context, s *Repository, password, username, hostname string, template *crypto.Key, capabilities []string, keyfile []byte) (*Key, error) {
+	for _, c := range capabilities {
+		if c != KeyCapabilityDelete {
+			return nil, fmt.Errorf("unknown key capability %q", c)
+		}
+		if template != nil && !s.CanDelete() {
+			return nil, errors.New("the current key lacks the delete capability required to add a key with this capability")
+		}
+	}
+
 	// make sure we have valid KDF parameters
 	if params == nil {
 		p, err := crypto.Calibrate(KDFTimeout, KDFMemory)
@@ -211,6 +264,9 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 		Username: username,
 		Hostname: hostname,
 
+		Capabilities: capabilities,
+		Keyfile:      len(keyfile) > 0,
+
 		KDF: "scrypt",
 		N:   params.N,
 		R:   params.R,
@@ -235,6 +291,10 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 		panic("unable to read enough random bytes for salt: " + err.Error())
 	}
 
+	if newkey.Keyfile {
+		password = keyfilePassword(password, keyfile)
+	}
+
 	// call KDF to derive user key
 	newkey.user, err = crypto.KDF(*params, newkey.Salt, password)
 	if err != nil {
@@ -284,15 +344,53 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 	return newkey, nil
 }
 
+// RemoveKey removes the key with the given ID. Keys with the delete capability
+// can only be removed using a key which also has the capability.
 func RemoveKey(ctx context.Context, repo *Repository, id restic.ID) error {
 	if id == repo.KeyID() {
 		return errors.New("refusing to remove key currently used to access repository")
 	}
 
+	if !repo.CanDelete() {
+		k, err := LoadKey(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+		if k.HasCapability(KeyCapabilityDelete) {
+			return ErrAppendOnly
+		}
+	}
+
 	h := backend.Handle{Type: restic.KeyFile, Name: id.String()}
 	return repo.be.Remove(ctx, h)
 }
 
+// setKeyCapabilities replaces the capabilities of the current key. As the key
+// ID is the hash of the key file, the key is saved as a new file and the old
+// file is removed afterwards.
+func setKeyCapabilities(ctx context.Context, repo *Repository, capabilities []string) error {
+	oldID := repo.KeyID()
+	k, err := LoadKey(ctx, repo, oldID)
+	if err != nil {
+		return err
+	}
+	k.Capabilities = capabilities
+
+	buf, err := json.Marshal(k)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+	id := restic.Hash(buf)
+	h := backend.Handle{Type: restic.KeyFile, Name: id.String()}
+	if err := repo.be.Save(ctx, h, backend.NewByteReader(buf, repo.be.Hasher())); err != nil {
+		return err
+	}
+
+	repo.keyID = id
+	repo.keyCapabilities = capabilities
+	return repo.be.Remove(ctx, backend.Handle{Type: restic.KeyFile, Name: oldID.String()})
+}
+
 func (k *Key) String() string {
 	if k == nil {
 		return "<Key nil>"
@@ -300,6 +398,11 @@ func (k *Key) String() string {
 	return fmt.Sprintf("<Key of %s@%s, created on %s>", k.Username, k.Hostname, k.Created)
 }
 
+// HasCapability returns whether the key has the given capability.
+func (k *Key) HasCapability(capability string) bool {
+	return slices.Contains(k.Capabilities, capability)
+}
+
 // ID returns an identifier for the key.
 func (k Key) ID() restic.ID {
 	return k.id
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/restic"
+)
+
+const indexManifestKind = "index-manifest"
+
+// indexManifest lists all index files present in the repository at the end of
+// the last operation which modified the index. It allows detecting a
+// repository copy which is missing some index files, for example because it
+// was only partially synchronized using rsync.
+type indexManifest struct {
+	Indexes []indexManifestEntry `json:"indexes"`
+}
+
+type indexManifestEntry struct {
+	ID   restic.ID `json:"id"`
+	Size int64     `json:"size"`
+}
+
+// IndexManifestError reports index files listed in the index manifest which
+// are either missing or have an unexpected size.
+type IndexManifestError struct {
+	Missing   restic.IDs
+	Truncated restic.IDs
+}
+
+func (e *IndexManifestError) Error() string {
+	return fmt.Sprintf("repository is incomplete: %d index files listed in the index manifest are missing and %d have a wrong size",
+		len(e.Missing), len(e.Truncated))
+}
+
+// SaveIndexManifest stores a manifest of all index files currently present in
+// the repository. It does nothing unless the index-manifest feature flag is
+// enabled and the repository supports state files.
+func SaveIndexManifest(ctx context.Context, repo restic.StateSaver) error {
+	if !feature.Flag.Enabled(feature.IndexManifest) {
+		return nil
+	}
+	if repo.Config().Version < restic.FeaturesRepoVersion {
+		debug.Log("repository does not support state files, not saving index manifest")
+		return nil
+	}
+
+	var manifest indexManifest
+	err := repo.List(ctx, restic.IndexFile, func(id restic.ID, size int64) error {
+		manifest.Indexes = append(manifest.Indexes, indexManifestEntry{ID: id, Size: size})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	id, err := restic.SaveState(ctx, repo, indexManifestKind, manifest)
+	if err != nil {
+		return fmt.Errorf("saving index manifest failed: %w", err)
+	}
+	debug.Log("saved index manifest %v with %d entries", id.Str(), len(manifest.Indexes))
+	return nil
+}
+
+// CheckIndexManifest verifies that all index files listed in the index
+// manifest exist in the repository with the expected size. Index files which
+// were added after the manifest was written are ignored. If the index-manifest
+// feature flag is disabled or the repository has no manifest, nil is returned.
+// An *IndexManifestError is returned if the verification fails.
+func CheckIndexManifest(ctx context.Context, repo restic.StateLoader) error {
+	if !feature.Flag.Enabled(feature.IndexManifest) {
+		return nil
+	}
+
+	var manifest indexManifest
+	err := restic.LoadState(ctx, repo, indexManifestKind, &manifest)
+	if err == restic.ErrNoState {
+		debug.Log("repository has no index manifest")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	sizes := make(map[restic.ID]int64)
+	err = repo.List(ctx, restic.IndexFile, func(id restic.ID, size int64) error {
+		sizes[id] = size
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	merr := &IndexManifestError{}
+	for _, entry := range manifest.Indexes {
+		size, ok := sizes[entry.ID]
+		if !ok {
+			merr.Missing = append(merr.Missing, entry.ID)
+		} else if size != entry.Size {
+			merr.Truncated = append(merr.Truncated, entry.ID)
+		}
+	}
+
+	if len(merr.Missing) > 0 || len(merr.Truncated) > 0 {
+		return merr
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestIndexManifest(t *testing.T) {
+	defer feature.TestSetFlag(t, feature.Flag, feature.IndexManifest, true)()
+
+	repo, be := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	// no manifest yet
+	rtest.OK(t, repository.CheckIndexManifest(ctx, repo))
+
+	createRandomBlobs(t, rand.New(rand.NewSource(42)), repo, 5, 0.5, true)
+	rtest.OK(t, repository.CheckIndexManifest(ctx, repo))
+
+	var indexes restic.IDs
+	rtest.OK(t, repo.List(ctx, restic.IndexFile, func(id restic.ID, _ int64) error {
+		indexes = append(indexes, id)
+		return nil
+	}))
+	rtest.Assert(t, len(indexes) > 0, "no index files found")
+
+	// simulate an incomplete repository copy
+	rtest.OK(t, be.Remove(ctx, backend.Handle{Type: restic.IndexFile, Name: indexes[0].String()}))
+
+	err := repository.CheckIndexManifest(ctx, repo)
+	var merr *repository.IndexManifestError
+	rtest.Assert(t, errors.As(err, &merr), "expected IndexManifestError, got %v", err)
+	rtest.Equals(t, restic.IDs{indexes[0]}, merr.Missing)
+
+	// the manifest is only checked if the feature flag is enabled
+	defer feature.TestSetFlag(t, feature.Flag, feature.IndexManifest, false)()
+	rtest.OK(t, repository.CheckIndexManifest(ctx, repo))
+}
+
+func TestIndexManifestFlush(t *testing.T) {
+	defer feature.TestSetFlag(t, feature.Flag, feature.IndexManifest, true)()
+
+	repo, be := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	listStates := func() restic.IDs {
+		var states restic.IDs
+		rtest.OK(t, repo.List(ctx, restic.StateFile, func(id restic.ID, _ int64) error {
+			states = append(states, id)
+			return nil
+		}))
+		return states
+	}
+
+	createRandomBlobs(t, rand.New(rand.NewSource(42)), repo, 5, 0.5, true)
+	states := listStates()
+	rtest.Assert(t, len(states) > 0, "no index manifest saved")
+
+	// flushing without adding index files leaves the manifest unchanged
+	rtest.OK(t, repo.Flush(ctx))
+	rtest.Equals(t, states, listStates())
+
+	// the manifest includes index files added by later operations
+	before := restic.NewIDSet()
+	rtest.OK(t, repo.List(ctx, restic.IndexFile, func(id restic.ID, _ int64) error {
+		before.Insert(id)
+		return nil
+	}))
+	createRandomBlobs(t, rand.New(rand.NewSource(23)), repo, 5, 0.5, true)
+
+	var added restic.IDs
+	rtest.OK(t, repo.List(ctx, restic.IndexFile, func(id restic.ID, _ int64) error {
+		if !before.Has(id) {
+			added = append(added, id)
+		}
+		return nil
+	}))
+	rtest.Assert(t, len(added) > 0, "no index files added")
+	rtest.OK(t, be.Remove(ctx, backend.Handle{Type: restic.IndexFile, Name: added[0].String()}))
+
+	err := repository.CheckIndexManifest(ctx, repo)
+	var merr *repository.IndexManifestError
+	rtest.Assert(t, errors.As(err, &merr), "expected IndexManifestError, got %v", err)
+	rtest.Equals(t, restic.IDs{added[0]}, merr.Missing)
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// CheckPackSample checks whether a random sample of n pack files referenced by
+// the index still exists in the backend and returns the IDs of those which are
+// missing, along with the number of checked pack files. This detects pack files
+// which were deleted externally, for example by a lifecycle rule of an object
+// storage bucket, without having to list all files in the repository.
+func (r *Repository) CheckPackSample(ctx context.Context, n uint) (int, restic.IDs, error) {
+	packs := r.idx.Packs(restic.NewIDSet()).List()
+	rand.Shuffle(len(packs), func(i, j int) {
+		packs[i], packs[j] = packs[j], packs[i]
+	})
+	if uint(len(packs)) > n {
+		packs = packs[:n]
+	}
+
+	var (
+		m       sync.Mutex
+		missing restic.IDs
+	)
+	wg, wgCtx := errgroup.WithContext(ctx)
+	wg.SetLimit(int(r.Connections()))
+	for _, id := range packs {
+		id := id
+		wg.Go(func() error {
+			_, err := r.be.Stat(wgCtx, backend.Handle{Type: restic.PackFile, Name: id.String()})
+			if err == nil {
+				return nil
+			}
+			if !r.be.IsNotExist(err) {
+				return err
+			}
+			debug.Log("pack %v is missing", id)
+			m.Lock()
+			missing = append(missing, id)
+			m.Unlock()
+			return nil
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return 0, nil, err
+	}
+	sort.Sort(missing)
+	return len(packs), missing, nil
+}
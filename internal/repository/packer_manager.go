@@ -156,7 +156,7 @@ func (r *Repository) savePacker(ctx context.Context, t restic.BlobType, p *packe
 	}
 
 	hr := hashing.NewReader(rd, sha256.New())
-	_, err = io.Copy(io.Discard, hr)
+	size, err := io.Copy(io.Discard, hr)
 	if err != nil {
 		return err
 	}
@@ -178,7 +178,14 @@ func (r *Repository) savePacker(ctx context.Context, t restic.BlobType, p *packe
 		return err
 	}
 
-	debug.Log("saved as %v", h)
+	logger.Debug("pack saved", "pack", id.Str(), "type", t.String(), "blobs", p.Packer.Count(), "size", p.Packer.Size())
+
+	r.savedPacksMu.Lock()
+	if r.savedPacks == nil {
+		r.savedPacks = make(map[restic.ID]int64)
+	}
+	r.savedPacks[id] = size
+	r.savedPacksMu.Unlock()
 
 	err = p.tmpfile.Close()
 	if err != nil {
@@ -192,3 +199,16 @@ func (r *Repository) savePacker(ctx context.Context, t restic.BlobType, p *packe
 	// Save index if full
 	return r.idx.SaveFullIndex(ctx, r)
 }
+
+// SavedPacks returns the IDs and sizes of all pack files saved since the
+// repository was opened.
+func (r *Repository) SavedPacks() map[restic.ID]int64 {
+	r.savedPacksMu.Lock()
+	defer r.savedPacksMu.Unlock()
+
+	packs := make(map[restic.ID]int64, len(r.savedPacks))
+	for id, size := range r.savedPacks {
+		packs[id] = size
+	}
+	return packs
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 
+	"github.com/restic/restic/internal/metrics"
 	"github.com/restic/restic/internal/restic"
 	"golang.org/x/sync/errgroup"
 )
@@ -17,6 +18,8 @@ type uploadTask struct {
 	tpe    restic.BlobType
 }
 
+var uploadQueueDepth = metrics.NewGauge("restic_pack_upload_queue_depth", "Number of pack files waiting to be uploaded.")
+
 type packerUploader struct {
 	uploadQueue chan uploadTask
 }
@@ -34,6 +37,7 @@ func newPackerUploader(ctx context.Context, wg *errgroup.Group, repo savePacker,
 					if !ok {
 						return nil
 					}
+					uploadQueueDepth.Dec()
 					err := repo.savePacker(ctx, t.tpe, t.packer)
 					if err != nil {
 						return err
@@ -49,8 +53,10 @@ func newPackerUploader(ctx context.Context, wg *errgroup.Group, repo savePacker,
 }
 
 func (pu *packerUploader) QueuePacker(ctx context.Context, t restic.BlobType, p *packer) (err error) {
+	uploadQueueDepth.Inc()
 	select {
 	case <-ctx.Done():
+		uploadQueueDepth.Dec()
 		return ctx.Err()
 	case pu.uploadQueue <- uploadTask{tpe: t, packer: p}:
 	}
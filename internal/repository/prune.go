@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"time"
 
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/cost"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository/index"
 	"github.com/restic/restic/internal/repository/pack"
@@ -28,6 +31,16 @@ type PruneOptions struct {
 	RepackCacheableOnly bool
 	RepackSmall         bool
 	RepackUncompressed  bool
+
+	// DedupOnly only removes additional copies of blobs which are stored
+	// more than once. All blobs contained in the index are considered used,
+	// such that snapshots are not loaded. Packs without duplicates are
+	// neither repacked nor removed.
+	DedupOnly bool
+
+	// Deadline stops repacking once it is reached, the remaining packs are
+	// kept. A zero value disables the deadline.
+	Deadline time.Time
 }
 
 type PruneStats struct {
@@ -57,6 +70,7 @@ type PruneStats struct {
 		Keep       uint
 		Repack     uint
 		Remove     uint
+		Retained   uint
 	}
 }
 
@@ -66,6 +80,8 @@ type PrunePlan struct {
 	keepBlobs        *index.AssociatedSet[uint8] // blobs to keep during repacking
 	removePacks      restic.IDSet                // packs to remove
 	ignorePacks      restic.IDSet                // packs to ignore when rebuilding the index
+	keepIndexes      restic.IDSet                // index files which cannot be deleted yet
+	hasCheckpoint    bool                        // the repository contains a prune checkpoint
 
 	repo  *Repository
 	stats PruneStats
@@ -104,24 +120,37 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 	if repo.Config().Version < 2 && opts.RepackUncompressed {
 		return nil, fmt.Errorf("compression requires at least repository format version 2")
 	}
+	if !opts.DryRun && !repo.CanDelete() {
+		return nil, ErrAppendOnly
+	}
+	if opts.DedupOnly {
+		if opts.RepackSmall || opts.RepackUncompressed {
+			return nil, fmt.Errorf("removing only duplicates cannot be combined with repacking small or uncompressed packs")
+		}
+		// remove all duplicates, no other data is unused
+		opts.MaxUnusedBytes = func(uint64) uint64 { return 0 }
+		getUsedBlobs = indexedBlobs
+	}
 
-	usedBlobs := index.NewAssociatedSet[uint8](repo.idx)
-	err := getUsedBlobs(ctx, repo, usedBlobs)
+	checkpoint, keepBlobs, indexPack, err := collectPackInfo(ctx, repo, getUsedBlobs, &stats, printer)
 	if err != nil {
 		return nil, err
 	}
 
-	printer.P("searching used packs...\n")
-	keepBlobs, indexPack, err := packInfoFromIndex(ctx, repo, usedBlobs, &stats, printer)
+	retained, keepIndexes, err := retainedFiles(ctx, repo, printer)
 	if err != nil {
 		return nil, err
 	}
 
 	printer.P("collecting packs for deletion and repacking\n")
-	plan, err := decidePackAction(ctx, opts, repo, indexPack, &stats, printer)
+	listPacks := func(ctx context.Context, fn func(restic.ID, int64) error) error {
+		return repo.List(ctx, restic.PackFile, fn)
+	}
+	plan, err := decidePackAction(ctx, opts, repo, indexPack, listPacks, retained, &stats, printer)
 	if err != nil {
 		return nil, err
 	}
+	plan.keepIndexes = keepIndexes
 
 	if len(plan.repackPacks) != 0 {
 		// when repacking, we do not want to keep blobs which are
@@ -140,6 +169,7 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 		keepBlobs = nil
 	}
 	plan.keepBlobs = keepBlobs
+	plan.hasCheckpoint = len(checkpoint) > 0
 
 	plan.repo = repo
 	plan.stats = stats
@@ -148,7 +178,78 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 	return &plan, nil
 }
 
-func packInfoFromIndex(ctx context.Context, idx restic.ListBlobser, usedBlobs *index.AssociatedSet[uint8], stats *PruneStats, printer progress.Printer) (*index.AssociatedSet[uint8], map[restic.ID]packInfo, error) {
+// retainedFiles returns the packs which must be kept as the backend protects
+// them or an index file referencing them from deletion. The protected index
+// files are returned as well, they must not be rewritten.
+func retainedFiles(ctx context.Context, repo *Repository, printer progress.Printer) (packs restic.IDSet, indexes restic.IDSet, err error) {
+	retainedIndexes, err := repo.ListRetained(ctx, restic.IndexFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing retained index files failed: %w", err)
+	}
+	retainedPacks, err := repo.ListRetained(ctx, restic.PackFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing retained packs failed: %w", err)
+	}
+
+	// the loaded index may have merged several index files, thus load the
+	// retained index files to determine which packs they reference
+	indexes = restic.NewIDSet()
+	packs = restic.NewIDSet()
+	for id := range retainedIndexes {
+		buf, err := repo.LoadUnpacked(ctx, restic.IndexFile, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		idx, err := index.DecodeIndex(buf, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		indexes.Insert(id)
+		packs.Merge(idx.Packs())
+	}
+	for id := range retainedPacks {
+		packs.Insert(id)
+	}
+	if len(packs) > 0 || len(indexes) > 0 {
+		printer.V("%d packs and %d index files are protected by a retention period\n", len(retainedPacks), len(indexes))
+	}
+	return packs, indexes, nil
+}
+
+// indexedBlobs adds all blobs contained in the index to usedBlobs.
+func indexedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+	return repo.ListBlobs(ctx, func(blob restic.PackedBlob) {
+		usedBlobs.Insert(blob.BlobHandle)
+	})
+}
+
+// collectPackInfo determines the used blobs and collects the used and unused
+// blobs of each pack. It also returns the packs repacked by an interrupted
+// prune run.
+func collectPackInfo(ctx context.Context, repo *Repository, getUsedBlobs func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error, stats *PruneStats, printer progress.Printer) (restic.IDSet, *index.AssociatedSet[uint8], map[restic.ID]packInfo, error) {
+	checkpoint, err := loadPruneCheckpoint(ctx, repo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	usedBlobs := index.NewAssociatedSet[uint8](repo.idx)
+	err = getUsedBlobs(ctx, repo, usedBlobs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	printer.P("searching used packs...\n")
+	keepBlobs, indexPack, err := packInfoFromIndex(ctx, repo, usedBlobs, checkpoint, stats, printer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return checkpoint, keepBlobs, indexPack, nil
+}
+
+// packInfoFromIndex collects the used and unused blobs of each pack. Duplicate
+// blobs contained in one of the repackedPacks, which were already repacked by an
+// interrupted prune run, are only kept if there is no other copy of them.
+func packInfoFromIndex(ctx context.Context, idx restic.ListBlobser, usedBlobs *index.AssociatedSet[uint8], repackedPacks restic.IDSet, stats *PruneStats, printer progress.Printer) (*index.AssociatedSet[uint8], map[restic.ID]packInfo, error) {
 	// iterate over all blobs in index to find out which blobs are duplicates
 	// The counter in usedBlobs describes how many instances of the blob exist in the repository index
 	// Thus 0 == blob is missing, 1 == blob exists once, >= 2 == duplicates exist
@@ -271,8 +372,9 @@ func packInfoFromIndex(ctx context.Context, idx restic.ListBlobser, usedBlobs *i
 
 			ip := indexPack[blob.PackID]
 			size := uint64(blob.Length)
+			obsolete := repackedPacks.Has(blob.PackID)
 			switch {
-			case ip.usedBlobs > 0, (ip.duplicateBlobs == ip.unusedBlobs), count == 0:
+			case !obsolete && (ip.usedBlobs > 0 || ip.duplicateBlobs == ip.unusedBlobs), count == 0:
 				// other used blobs in pack, only duplicate blobs or "last" occurrence ->  transition to used
 				// a pack file created by an interrupted prune run will consist of only duplicate blobs
 				// thus select such already repacked pack files
@@ -316,7 +418,9 @@ func packInfoFromIndex(ctx context.Context, idx restic.ListBlobser, usedBlobs *i
 	return usedBlobs, indexPack, nil
 }
 
-func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository, indexPack map[restic.ID]packInfo, stats *PruneStats, printer progress.Printer) (PrunePlan, error) {
+// decidePackAction decides which packs to remove and to repack. listPacks is
+// called once to list all pack files in the repository.
+func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository, indexPack map[restic.ID]packInfo, listPacks func(context.Context, func(restic.ID, int64) error) error, retained restic.IDSet, stats *PruneStats, printer progress.Printer) (PrunePlan, error) {
 	removePacksFirst := restic.NewIDSet()
 	removePacks := restic.NewIDSet()
 	repackPacks := restic.NewIDSet()
@@ -334,8 +438,18 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 	// loop over all packs and decide what to do
 	bar := printer.NewCounter("packs processed")
 	bar.SetMax(uint64(len(indexPack)))
-	err := repo.List(ctx, restic.PackFile, func(id restic.ID, packSize int64) error {
+	err := listPacks(ctx, func(id restic.ID, packSize int64) error {
 		p, ok := indexPack[id]
+		if !ok && opts.DedupOnly {
+			// only duplicates are removed, unreferenced packs are left
+			// for a regular prune run
+			return nil
+		}
+		if !ok && retained.Has(id) {
+			printer.V("keeping unreferenced pack %v, it is protected by a retention period\n", id.Str())
+			stats.Packs.Retained++
+			return nil
+		}
 		if !ok {
 			// Pack was not referenced in index and is not used  => immediately remove!
 			printer.V("will remove pack %v as it is unused and not indexed\n", id.Str())
@@ -367,7 +481,7 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 			stats.Size.Uncompressed += p.unusedSize + p.usedSize
 		}
 		mustCompress := false
-		if repoVersion >= 2 {
+		if repoVersion >= 2 && !opts.DedupOnly {
 			// repo v2: always repack tree blobs if uncompressed
 			// compress data blobs if requested
 			mustCompress = (p.tpe == restic.TreeBlob || opts.RepackUncompressed) && p.uncompressed
@@ -375,6 +489,11 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 
 		// decide what to do
 		switch {
+		case retained.Has(id):
+			// pack cannot be deleted before its retention period expires => keep pack!
+			stats.Packs.Keep++
+			stats.Packs.Retained++
+
 		case p.usedBlobs == 0:
 			// All blobs in pack are no longer used => remove pack!
 			removePacks.Insert(id)
@@ -385,6 +504,10 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 			// if this is a data pack and --repack-cacheable-only is set => keep pack!
 			stats.Packs.Keep++
 
+		case opts.DedupOnly && p.unusedBlobs == 0:
+			// pack contains no duplicates => keep pack!
+			stats.Packs.Keep++
+
 		case p.unusedBlobs == 0 && p.tpe != restic.InvalidBlob && !mustCompress:
 			if packSize >= int64(targetPackSize) {
 				// All blobs in pack are used and not mixed => keep pack!
@@ -519,6 +642,35 @@ func (plan *PrunePlan) Stats() PruneStats {
 	return plan.stats
 }
 
+// EstimateOperations estimates the backend requests which are necessary to
+// execute the plan. The estimate assumes that all index files have to be
+// rewritten and ignores the pack headers, thus it is an upper bound for the
+// index files and slightly underestimates the transferred pack data.
+func (plan *PrunePlan) EstimateOperations() cost.Operations {
+	var ops cost.Operations
+	if plan.repo == nil {
+		// the plan was already executed
+		return ops
+	}
+
+	stats := plan.stats
+	ops.Get = uint64(len(plan.repackPacks))
+	ops.Download = stats.Size.Repack + stats.Size.Repackrm
+	if stats.Size.Repack > 0 {
+		packSize := uint64(plan.repo.packSize())
+		ops.Put = (stats.Size.Repack + packSize - 1) / packSize
+		ops.Upload = stats.Size.Repack
+	}
+	ops.Delete = uint64(len(plan.removePacksFirst) + len(plan.repackPacks) + len(plan.removePacks))
+
+	if len(plan.ignorePacks)+len(plan.repackPacks)+len(plan.removePacks) > 0 || plan.opts.UnsafeRecovery {
+		indexFiles := uint64(len(plan.repo.idx.IDs()))
+		ops.Put += indexFiles
+		ops.Delete += indexFiles
+	}
+	return ops
+}
+
 // Execute does the actual pruning:
 // - remove unreferenced packs first
 // - repack given pack files while keeping the given blobs
@@ -556,18 +708,31 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 		printer.P("repacking packs\n")
 		bar := printer.NewCounter("packs repacked")
 		bar.SetMax(uint64(len(plan.repackPacks)))
-		_, err := Repack(ctx, repo, repo, plan.repackPacks, plan.keepBlobs, bar)
+		repacked := plan.repackPacks
+		var err error
+		if plan.opts.Deadline.IsZero() {
+			_, err = Repack(ctx, repo, repo, plan.repackPacks, plan.keepBlobs, bar)
+		} else {
+			repacked, err = plan.repackWithDeadline(ctx, repo, bar)
+		}
 		bar.Done()
 		if err != nil {
 			return errors.Fatal(err.Error())
 		}
 
+		complete := len(repacked) == len(plan.repackPacks)
+		if !complete {
+			printer.P("reached the maximum duration, %d of %d packs were not repacked, run prune again to continue\n",
+				len(plan.repackPacks)-len(repacked), len(plan.repackPacks))
+		}
+
 		// Also remove repacked packs
-		plan.removePacks.Merge(plan.repackPacks)
+		plan.removePacks.Merge(repacked)
 		// forget unused data
 		plan.repackPacks = nil
 
-		if plan.keepBlobs.Len() != 0 {
+		// the blobs of packs which were not repacked are still contained in keepBlobs
+		if complete && plan.keepBlobs.Len() != 0 {
 			printer.E("%v was not repacked\n\n"+
 				"Integrity check failed.\n"+
 				"Please report this error (along with the output of the 'prune' run) at\n"+
@@ -593,7 +758,15 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 			return errors.Fatalf("%s", err)
 		}
 	} else if len(plan.ignorePacks) != 0 {
-		err := rewriteIndexFiles(ctx, repo, plan.ignorePacks, nil, nil, printer)
+		err := rewriteIndexFiles(ctx, repo, plan.ignorePacks, nil, nil, plan.keepIndexes, printer)
+		var rerr *backend.RetentionError
+		if errors.As(err, &rerr) {
+			return errors.Fatalf("%s\nold index files cannot be deleted before their retention period expires, please run prune again later", err)
+		}
+		var perr *backend.PolicyError
+		if errors.As(err, &perr) {
+			return err
+		}
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
@@ -612,6 +785,27 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
+		err = SaveIndexManifest(ctx, repo)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+	}
+
+	// the index no longer contains the packs which were repacked
+	if plan.hasCheckpoint {
+		if err := restic.RemoveState(ctx, repo, pruneCheckpointKind); err != nil {
+			return errors.Fatalf("removing prune checkpoint failed: %s", err)
+		}
+	}
+
+	// the in-memory index still contains the removed packs
+	err := recordStats(ctx, repo, StatsHistoryEntry{Command: "prune"}, plan.ignorePacks)
+	if err == nil && statsHistoryEnabled(repo) {
+		// prune holds an exclusive lock
+		err = trimStatsHistory(ctx, repo)
+	}
+	if err != nil {
+		printer.E("failed to record repository statistics: %v\n", err)
 	}
 
 	// drop outdated in-memory index
@@ -628,6 +822,12 @@ func deleteFiles(ctx context.Context, ignoreError bool, repo restic.RemoverUnpac
 	defer bar.Done()
 
 	return restic.ParallelRemove(ctx, repo, fileList, fileType, func(id restic.ID, err error) error {
+		var rerr *backend.RetentionError
+		if errors.As(err, &rerr) {
+			// the file still exists and remains valid, thus only report it
+			printer.E("skipping %v/%v: %v\n", fileType, id.Str(), rerr)
+			return nil
+		}
 		if err != nil {
 			printer.E("unable to remove %v/%v from the repository\n", fileType, id)
 			if !ignoreError {
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/backend/cost"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// PackUsage describes how much of a pack file is still in use.
+type PackUsage struct {
+	ID   restic.ID
+	Size uint64
+	// Indexed is false for pack files which are not referenced by the index,
+	// all other fields except the size are empty for those.
+	Indexed bool
+	// Type is InvalidBlob if the pack contains both tree and data blobs.
+	Type           restic.BlobType
+	UsedBlobs      uint
+	UnusedBlobs    uint
+	DuplicateBlobs uint
+	UsedSize       uint64
+	UnusedSize     uint64
+	Uncompressed   bool
+}
+
+// PackAction is the action prune would apply to a pack file.
+type PackAction string
+
+const (
+	PackKeep   PackAction = "keep"
+	PackRepack PackAction = "repack"
+	PackRemove PackAction = "remove"
+)
+
+// PruneScenario is the result of planning a prune run for one setting of
+// MaxUnusedBytes.
+type PruneScenario struct {
+	Stats      PruneStats
+	Operations cost.Operations
+	Actions    map[restic.ID]PackAction
+}
+
+// AnalyzePrune plans a prune run once for each function in maxUnused, which
+// replaces opts.MaxUnusedBytes. The repository is not modified. The used
+// blobs are only determined once and the pack files are only listed once.
+func AnalyzePrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsedBlobs func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error, maxUnused []func(used uint64) uint64, printer progress.Printer) ([]PackUsage, []PruneScenario, error) {
+	opts.DryRun = true
+	if opts.UnsafeRecovery {
+		opts.MaxRepackBytes = 0
+	}
+	if repo.Config().Version < 2 && opts.RepackUncompressed {
+		return nil, nil, fmt.Errorf("compression requires at least repository format version 2")
+	}
+
+	var stats PruneStats
+	_, _, indexPack, err := collectPackInfo(ctx, repo, getUsedBlobs, &stats, printer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type packFile struct {
+		id   restic.ID
+		size int64
+	}
+	var packFiles []packFile
+	err = repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
+		packFiles = append(packFiles, packFile{id, size})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	listPacks := func(ctx context.Context, fn func(restic.ID, int64) error) error {
+		for _, pf := range packFiles {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := fn(pf.id, pf.size); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	packs := make([]PackUsage, 0, len(packFiles))
+	for _, pf := range packFiles {
+		usage := PackUsage{ID: pf.id, Size: uint64(pf.size)}
+		if p, ok := indexPack[pf.id]; ok {
+			usage.Indexed = true
+			usage.Type = p.tpe
+			usage.UsedBlobs = p.usedBlobs
+			usage.UnusedBlobs = p.unusedBlobs
+			usage.DuplicateBlobs = p.duplicateBlobs
+			usage.UsedSize = p.usedSize
+			usage.UnusedSize = p.unusedSize
+			usage.Uncompressed = p.uncompressed
+		}
+		packs = append(packs, usage)
+	}
+
+	var scenarios []PruneScenario
+	for i, fn := range maxUnused {
+		// decidePackAction modifies both the pack infos and the statistics
+		scenarioPacks := make(map[restic.ID]packInfo, len(indexPack))
+		for id, p := range indexPack {
+			scenarioPacks[id] = p
+		}
+		scenarioStats := stats
+		scenarioOpts := opts
+		scenarioOpts.MaxUnusedBytes = fn
+
+		// only report problems with the repository once
+		var scenarioPrinter progress.Printer = &progress.NoopPrinter{}
+		if i == 0 {
+			scenarioPrinter = printer
+		}
+		plan, err := decidePackAction(ctx, scenarioOpts, repo, scenarioPacks, listPacks, nil, &scenarioStats, scenarioPrinter)
+		if err != nil {
+			return nil, nil, err
+		}
+		plan.repo = repo
+		plan.stats = scenarioStats
+		plan.opts = scenarioOpts
+
+		actions := make(map[restic.ID]PackAction, len(packFiles))
+		for _, pf := range packFiles {
+			switch {
+			case plan.removePacksFirst.Has(pf.id), plan.removePacks.Has(pf.id):
+				actions[pf.id] = PackRemove
+			case plan.repackPacks.Has(pf.id):
+				actions[pf.id] = PackRepack
+			default:
+				actions[pf.id] = PackKeep
+			}
+		}
+
+		scenarios = append(scenarios, PruneScenario{
+			Stats:      scenarioStats,
+			Operations: plan.EstimateOperations(),
+			Actions:    actions,
+		})
+	}
+
+	return packs, scenarios, nil
+}
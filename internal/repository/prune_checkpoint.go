@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+const pruneCheckpointKind = "prune-checkpoint"
+
+// pruneCheckpointInterval is the maximum time between two checkpoints while
+// repacking with a deadline.
+var pruneCheckpointInterval = 10 * time.Minute
+
+// pruneCheckpoint lists the packs which were repacked by a prune run that did
+// not complete. The blobs of these packs which are still in use are already
+// stored in new packs, which are included in the index. A later prune run
+// removes these packs instead of repacking them again.
+type pruneCheckpoint struct {
+	Repacked restic.IDs `json:"repacked"`
+}
+
+// loadPruneCheckpoint returns the packs listed in the prune checkpoint. If no
+// checkpoint exists, an empty set is returned.
+func loadPruneCheckpoint(ctx context.Context, repo restic.StateLoader) (restic.IDSet, error) {
+	var checkpoint pruneCheckpoint
+	err := restic.LoadState(ctx, repo, pruneCheckpointKind, &checkpoint)
+	if err == restic.ErrNoState {
+		return restic.NewIDSet(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	debug.Log("loaded prune checkpoint with %d repacked packs", len(checkpoint.Repacked))
+	return restic.NewIDSet(checkpoint.Repacked...), nil
+}
+
+func savePruneCheckpoint(ctx context.Context, repo restic.StateSaver, repacked restic.IDSet) error {
+	_, err := restic.SaveState(ctx, repo, pruneCheckpointKind, pruneCheckpoint{Repacked: repacked.List()})
+	if err == restic.ErrStateUnsupported {
+		// an interrupted prune run has to repeat the repacking
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("saving prune checkpoint failed: %w", err)
+	}
+	return nil
+}
+
+// repackWithDeadline repacks the packs of the plan until the deadline is
+// reached. After each interval of at most pruneCheckpointInterval, the packs
+// repacked so far are recorded in the prune checkpoint. This ensures that an
+// interrupted prune run does not have to repeat this work. Returned are the
+// packs which were repacked.
+func (plan *PrunePlan) repackWithDeadline(ctx context.Context, repo *Repository, p *progress.Counter) (restic.IDSet, error) {
+	repacked := restic.NewIDSet()
+	for len(repacked) < len(plan.repackPacks) {
+		stopAt := time.Now().Add(pruneCheckpointInterval)
+		if plan.opts.Deadline.Before(stopAt) {
+			stopAt = plan.opts.Deadline
+		}
+		if !time.Now().Before(stopAt) {
+			break
+		}
+
+		remaining := restic.NewIDSet()
+		for id := range plan.repackPacks {
+			if !repacked.Has(id) {
+				remaining.Insert(id)
+			}
+		}
+
+		processed, err := repackUntil(ctx, repo, repo, remaining, plan.keepBlobs, p, stopAt)
+		if err != nil {
+			return nil, err
+		}
+		if len(processed) == 0 {
+			// either the deadline was reached or no packs are left
+			break
+		}
+		repacked.Merge(processed)
+
+		err = savePruneCheckpoint(ctx, repo, repacked)
+		if err != nil {
+			return nil, err
+		}
+		plan.hasCheckpoint = true
+	}
+	return repacked, nil
+}
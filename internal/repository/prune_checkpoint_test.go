@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestPruneResumeFromCheckpoint(t *testing.T) {
+	repo, _ := TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	used, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, rtest.Random(1, 1000), restic.ID{}, false)
+	rtest.OK(t, err)
+	_, _, _, err = repo.SaveBlob(context.TODO(), restic.DataBlob, rtest.Random(2, 1000), restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.TODO()))
+
+	var oldPack restic.ID
+	rtest.OK(t, repo.List(context.TODO(), restic.PackFile, func(id restic.ID, _ int64) error {
+		oldPack = id
+		return nil
+	}))
+
+	opts := PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+		Deadline:       time.Now().Add(time.Hour),
+	}
+	getUsedBlobs := func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		usedBlobs.Insert(restic.BlobHandle{Type: restic.DataBlob, ID: used})
+		return nil
+	}
+
+	// simulate a prune run which was interrupted after repacking
+	plan, err := PlanPrune(context.TODO(), opts, repo, getUsedBlobs, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, plan.repackPacks.Has(oldPack), "pack %v is not repacked", oldPack)
+	repacked, err := plan.repackWithDeadline(context.TODO(), repo, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.NewIDSet(oldPack), repacked)
+
+	checkpoint, err := loadPruneCheckpoint(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, repacked, checkpoint)
+
+	// the next run must remove the already repacked pack
+	opts.Deadline = time.Time{}
+	plan, err = PlanPrune(context.TODO(), opts, repo, getUsedBlobs, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, plan.removePacks.Has(oldPack), "pack %v is not removed", oldPack)
+	rtest.Equals(t, 0, len(plan.repackPacks))
+
+	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+	var state pruneCheckpoint
+	err = restic.LoadState(context.TODO(), repo, pruneCheckpointKind, &state)
+	rtest.Assert(t, err == restic.ErrNoState, "prune checkpoint was not removed, got %v", err)
+
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+	_, err = repo.LoadBlob(context.TODO(), restic.DataBlob, used, nil)
+	rtest.OK(t, err)
+}
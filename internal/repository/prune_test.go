@@ -44,6 +44,11 @@ func testPrune(t *testing.T, opts repository.PruneOptions, errOnUnused bool) {
 	}, &progress.NoopPrinter{})
 	rtest.OK(t, err)
 
+	ops := plan.EstimateOperations()
+	stats := plan.Stats()
+	rtest.Equals(t, uint64(stats.Packs.Repack), ops.Get)
+	rtest.Assert(t, ops.Delete >= uint64(stats.Packs.Repack+stats.Packs.Remove), "expected at least %d deletes, got %d", stats.Packs.Repack+stats.Packs.Remove, ops.Delete)
+
 	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
 
 	repo = repository.TestOpenBackend(t, be)
@@ -100,6 +105,15 @@ func TestPrune(t *testing.T) {
 			},
 			errOnUnused: true,
 		},
+		{
+			name: "deadline",
+			opts: repository.PruneOptions{
+				MaxRepackBytes: math.MaxUint64,
+				MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+				Deadline:       time.Now().Add(time.Hour),
+			},
+			errOnUnused: true,
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			testPrune(t, test.opts, test.errOnUnused)
@@ -112,3 +126,153 @@ func TestPrune(t *testing.T) {
 		})
 	}
 }
+
+func TestPruneDeadlineReached(t *testing.T) {
+	seed := time.Now().UnixNano()
+	random := rand.New(rand.NewSource(seed))
+	t.Logf("rand initialized with seed %d", seed)
+
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	createRandomBlobs(t, random, repo, 50, 0.5, true)
+	keep, _ := selectBlobs(t, random, repo, 0.5)
+	getUsedBlobs := func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		for blob := range keep {
+			usedBlobs.Insert(blob)
+		}
+		return nil
+	}
+
+	opts := repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+		Deadline:       time.Now().Add(-time.Second),
+	}
+	plan, err := repository.PlanPrune(context.TODO(), opts, repo, getUsedBlobs, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, plan.Stats().Packs.Repack > 0, "no packs to repack")
+	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+
+	// partially used packs are kept
+	repo = repository.TestOpenBackend(t, be)
+	checker.TestCheckRepo(t, repo, true)
+	existing := listBlobs(repo)
+	for blob := range keep {
+		rtest.Assert(t, existing.Has(blob), "blob %v is missing", blob)
+	}
+	rtest.Assert(t, len(existing) > len(keep), "unused blobs were removed")
+
+	// a prune run without deadline continues the work
+	opts.Deadline = time.Time{}
+	plan, err = repository.PlanPrune(context.TODO(), opts, repo, getUsedBlobs, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+
+	repo = repository.TestOpenBackend(t, be)
+	checker.TestCheckRepo(t, repo, true)
+	existing = listBlobs(repo)
+	rtest.Assert(t, existing.Equals(keep), "unexpected blobs, wanted %v got %v", keep, existing)
+}
+
+func TestPruneDedupOnly(t *testing.T) {
+	seed := time.Now().UnixNano()
+	random := rand.New(rand.NewSource(seed))
+	t.Logf("rand initialized with seed %d", seed)
+
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	createRandomBlobs(t, random, repo, 20, 0.5, true)
+	all := listBlobs(repo)
+	duplicate, _ := selectBlobs(t, random, repo, 0.3)
+
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	for blob := range duplicate {
+		buf, err := repo.LoadBlob(context.TODO(), blob.Type, blob.ID, nil)
+		rtest.OK(t, err)
+		_, _, _, err = repo.SaveBlob(context.TODO(), blob.Type, buf, blob.ID, true)
+		rtest.OK(t, err)
+	}
+	rtest.OK(t, repo.Flush(context.TODO()))
+
+	opts := repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return math.MaxUint64 },
+		DedupOnly:      true,
+	}
+	plan, err := repository.PlanPrune(context.TODO(), opts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		t.Fatal("used blobs must not be determined")
+		return nil
+	}, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(len(duplicate)), plan.Stats().Blobs.Duplicate)
+	rtest.Equals(t, uint(0), plan.Stats().Blobs.Unused)
+	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+
+	repo = repository.TestOpenBackend(t, be)
+	checker.TestCheckRepo(t, repo, true)
+	count := 0
+	rtest.OK(t, repo.ListBlobs(context.TODO(), func(restic.PackedBlob) {
+		count++
+	}))
+	rtest.Equals(t, len(all), count)
+	rtest.Assert(t, listBlobs(repo).Equals(all), "unexpected blobs, wanted %v got %v", all, listBlobs(repo))
+}
+
+func TestAnalyzePrune(t *testing.T) {
+	seed := time.Now().UnixNano()
+	random := rand.New(rand.NewSource(seed))
+	t.Logf("rand initialized with seed %d", seed)
+
+	repo, _ := repository.TestRepositoryWithVersion(t, 0)
+	createRandomBlobs(t, random, repo, 20, 0.5, true)
+	keep, _ := selectBlobs(t, random, repo, 0.5)
+	getUsedBlobs := func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		for blob := range keep {
+			usedBlobs.Insert(blob)
+		}
+		return nil
+	}
+
+	opts := repository.PruneOptions{MaxRepackBytes: math.MaxUint64}
+	maxUnused := []func(used uint64) uint64{
+		func(_ uint64) uint64 { return 0 },
+		func(_ uint64) uint64 { return math.MaxUint64 },
+	}
+	packs, scenarios, err := repository.AnalyzePrune(context.TODO(), opts, repo, getUsedBlobs, maxUnused, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, len(maxUnused), len(scenarios))
+
+	var packCount int
+	rtest.OK(t, repo.List(context.TODO(), restic.PackFile, func(_ restic.ID, _ int64) error {
+		packCount++
+		return nil
+	}))
+	rtest.Equals(t, packCount, len(packs))
+	for _, p := range packs {
+		rtest.Assert(t, p.Indexed, "pack %v is not indexed", p.ID)
+		rtest.Equals(t, p.Size, p.UsedSize+p.UnusedSize)
+	}
+
+	// each scenario matches the plan for the same options
+	for i, fn := range maxUnused {
+		opts.MaxUnusedBytes = fn
+		opts.DryRun = true
+		plan, err := repository.PlanPrune(context.TODO(), opts, repo, getUsedBlobs, &progress.NoopPrinter{})
+		rtest.OK(t, err)
+		rtest.Equals(t, plan.Stats(), scenarios[i].Stats)
+		rtest.Equals(t, plan.EstimateOperations(), scenarios[i].Operations)
+
+		var repack, remove uint
+		for _, action := range scenarios[i].Actions {
+			switch action {
+			case repository.PackRepack:
+				repack++
+			case repository.PackRemove:
+				remove++
+			}
+		}
+		rtest.Equals(t, plan.Stats().Packs.Repack, repack)
+		rtest.Equals(t, plan.Stats().Packs.Remove+plan.Stats().Packs.Unref, remove)
+	}
+	rtest.Assert(t, scenarios[0].Stats.Packs.Repack >= scenarios[1].Stats.Packs.Repack,
+		"tolerating unused data repacked more packs")
+}
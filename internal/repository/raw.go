@@ -20,7 +20,7 @@ func (r *Repository) LoadRaw(ctx context.Context, t restic.FileType, id restic.I
 
 	// retry loading damaged data only once. If a file fails to download correctly
 	// the second time, then it is likely corrupted at the backend.
-	if h.Type != backend.ConfigFile && id != restic.Hash(buf) {
+	if h.Type != backend.ConfigFile && !matchesContent(t, id, buf) {
 		if r.Cache != nil {
 			// Cleanup cache to make sure it's not the cached copy that is broken.
 			// Ignore error as there's not much we can do in that case.
@@ -29,7 +29,7 @@ func (r *Repository) LoadRaw(ctx context.Context, t restic.FileType, id restic.I
 
 		buf, err = loadRaw(ctx, r.be, h)
 
-		if err == nil && id != restic.Hash(buf) {
+		if err == nil && !matchesContent(t, id, buf) {
 			// Return corrupted data to the caller if it is still broken the second time to
 			// let the caller decide what to do with the data.
 			return buf, fmt.Errorf("LoadRaw(%v): %w", h, restic.ErrInvalidData)
@@ -42,6 +42,17 @@ func (r *Repository) LoadRaw(ctx context.Context, t restic.FileType, id restic.I
 	return buf, nil
 }
 
+// matchesContent returns whether id is the ID of a file of type t with content
+// buf. The first bytes of the ID of a state file identify the kind of the
+// state instead.
+func matchesContent(t restic.FileType, id restic.ID, buf []byte) bool {
+	hash := restic.Hash(buf)
+	if t == restic.StateFile {
+		return bytes.Equal(id[restic.StateKindSize:], hash[restic.StateKindSize:])
+	}
+	return id == hash
+}
+
 func loadRaw(ctx context.Context, be backend.Backend, h backend.Handle) (buf []byte, err error) {
 	err = be.Load(ctx, h, 0, 0, func(rd io.Reader) error {
 		wr := new(bytes.Buffer)
@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"runtime"
 	"sync"
+	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
@@ -26,6 +29,14 @@ type repackBlobSet interface {
 // The map keepBlobs is modified by Repack, it is used to keep track of which
 // blobs have been processed.
 func Repack(ctx context.Context, repo restic.Repository, dstRepo restic.Repository, packs restic.IDSet, keepBlobs repackBlobSet, p *progress.Counter) (obsoletePacks restic.IDSet, err error) {
+	return repackUntil(ctx, repo, dstRepo, packs, keepBlobs, p, time.Time{})
+}
+
+// repackUntil works like Repack, but stops repacking further packs once stopAt
+// is reached, unless stopAt is zero. Packs which are already being repacked at
+// that time are completed. Only the packs which were repacked completely are
+// returned as obsolete.
+func repackUntil(ctx context.Context, repo restic.Repository, dstRepo restic.Repository, packs restic.IDSet, keepBlobs repackBlobSet, p *progress.Counter, stopAt time.Time) (obsoletePacks restic.IDSet, err error) {
 	debug.Log("repacking %d packs while keeping %d blobs", len(packs), keepBlobs.Len())
 
 	if repo == dstRepo && dstRepo.Connections() < 2 {
@@ -37,7 +48,7 @@ func Repack(ctx context.Context, repo restic.Repository, dstRepo restic.Reposito
 	dstRepo.StartPackUploader(wgCtx, wg)
 	wg.Go(func() error {
 		var err error
-		obsoletePacks, err = repack(wgCtx, repo, dstRepo, packs, keepBlobs, p)
+		obsoletePacks, err = repack(wgCtx, repo, dstRepo, packs, keepBlobs, p, stopAt)
 		return err
 	})
 
@@ -47,14 +58,30 @@ func Repack(ctx context.Context, repo restic.Repository, dstRepo restic.Reposito
 	return obsoletePacks, nil
 }
 
-func repack(ctx context.Context, repo restic.Repository, dstRepo restic.Repository, packs restic.IDSet, keepBlobs repackBlobSet, p *progress.Counter) (obsoletePacks restic.IDSet, err error) {
+// repackBlob is a blob which was downloaded and is waiting to be saved.
+type repackBlob struct {
+	restic.BlobHandle
+	buf []byte
+}
+
+// repackSaveQueueSize limits the number of downloaded blobs which wait to be
+// saved. This bounds the memory usage if saving is slower than downloading.
+const repackSaveQueueSize = 16
+
+func repack(ctx context.Context, repo restic.Repository, dstRepo restic.Repository, packs restic.IDSet, keepBlobs repackBlobSet, p *progress.Counter, stopAt time.Time) (obsoletePacks restic.IDSet, err error) {
 	wg, wgCtx := errgroup.WithContext(ctx)
 
 	var keepMutex sync.Mutex
+	repacked := restic.NewIDSet()
 	downloadQueue := make(chan restic.PackBlobs)
 	wg.Go(func() error {
 		defer close(downloadQueue)
 		for pbs := range repo.ListPacksFromIndex(wgCtx, packs) {
+			if !stopAt.IsZero() && time.Now().After(stopAt) {
+				debug.Log("stop repacking, time limit reached")
+				break
+			}
+
 			var packBlobs []restic.Blob
 			keepMutex.Lock()
 			// filter out unnecessary blobs
@@ -75,7 +102,14 @@ func repack(ctx context.Context, repo restic.Repository, dstRepo restic.Reposito
 		return wgCtx.Err()
 	})
 
-	worker := func() error {
+	// Downloading and saving blobs is pipelined: the download workers only
+	// decrypt the blobs, the save workers compress and encrypt them for the
+	// destination. The pack uploader of the destination repository runs
+	// concurrently to both. Thus, a slow network connection or CPU does not
+	// stall the other stages.
+	saveQueue := make(chan repackBlob, repackSaveQueueSize)
+
+	downloadWorker := func() error {
 		for t := range downloadQueue {
 			err := repo.LoadBlobsFromPack(wgCtx, t.PackID, t.Blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
 				if err != nil {
@@ -95,32 +129,64 @@ func repack(ctx context.Context, repo restic.Repository, dstRepo restic.Reposito
 					return nil
 				}
 
-				// We do want to save already saved blobs!
-				_, _, _, err = dstRepo.SaveBlob(wgCtx, blob.Type, buf, blob.ID, true)
-				if err != nil {
-					return err
+				// buf is only valid during the callback
+				select {
+				case saveQueue <- repackBlob{BlobHandle: blob, buf: bytes.Clone(buf)}:
+				case <-wgCtx.Done():
+					return wgCtx.Err()
 				}
-
-				debug.Log("  saved blob %v", blob.ID)
 				return nil
 			})
 			if err != nil {
 				return err
 			}
+
+			// if saving a blob of the pack fails, then the whole repack fails
+			keepMutex.Lock()
+			repacked.Insert(t.PackID)
+			keepMutex.Unlock()
 			p.Add(1)
 		}
 		return nil
 	}
 
+	saveWorker := func() error {
+		for blob := range saveQueue {
+			// We do want to save already saved blobs!
+			_, _, _, err := dstRepo.SaveBlob(wgCtx, blob.Type, blob.buf, blob.ID, true)
+			if err != nil {
+				return err
+			}
+
+			debug.Log("  saved blob %v", blob.ID)
+		}
+		return nil
+	}
+
 	// as packs are streamed the concurrency is limited by IO
 	// reduce by one to ensure that uploading is always possible
-	repackWorkerCount := int(repo.Connections() - 1)
+	downloadWorkerCount := int(repo.Connections() - 1)
 	if repo != dstRepo {
 		// no need to share the upload and download connections for different repositories
-		repackWorkerCount = int(repo.Connections())
+		downloadWorkerCount = int(repo.Connections())
+	}
+	var downloadWg sync.WaitGroup
+	downloadWg.Add(downloadWorkerCount)
+	for i := 0; i < downloadWorkerCount; i++ {
+		wg.Go(func() error {
+			defer downloadWg.Done()
+			return downloadWorker()
+		})
 	}
-	for i := 0; i < repackWorkerCount; i++ {
-		wg.Go(worker)
+	go func() {
+		// all blobs are queued once the download workers have finished
+		downloadWg.Wait()
+		close(saveQueue)
+	}()
+
+	// saving blobs is CPU bound
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Go(saveWorker)
 	}
 
 	if err := wg.Wait(); err != nil {
@@ -131,5 +197,5 @@ func repack(ctx context.Context, repo restic.Repository, dstRepo restic.Reposito
 		return nil, err
 	}
 
-	return packs, nil
+	return repacked, nil
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -299,6 +300,30 @@ func testRepackCopy(t *testing.T, version uint) {
 	}
 }
 
+type failingSaveRepo struct {
+	restic.Repository
+}
+
+func (r failingSaveRepo) SaveBlob(_ context.Context, _ restic.BlobType, _ []byte, _ restic.ID, _ bool) (restic.ID, bool, int, error) {
+	return restic.ID{}, false, 0, errors.New("save failed")
+}
+
+func TestRepackSaveError(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 0)
+	dstRepo, _ := repository.TestRepositoryWithVersion(t, 0)
+
+	random := rand.New(rand.NewSource(23))
+	createRandomBlobs(t, random, repo, 50, 0.7, false)
+	createRandomBlobs(t, random, repo, 50, 0.7, false)
+
+	_, keepBlobs := selectBlobs(t, random, repo, 0)
+	copyPacks := findPacksForBlobs(t, repo, keepBlobs)
+
+	// the download workers must not block once saving has failed
+	_, err := repository.Repack(context.TODO(), repo, failingSaveRepo{dstRepo}, copyPacks, keepBlobs, nil)
+	rtest.Assert(t, err != nil && err.Error() == "save failed", "unexpected error %v", err)
+}
+
 func TestRepackWrongBlob(t *testing.T) {
 	repository.TestAllVersions(t, testRepackWrongBlob)
 }
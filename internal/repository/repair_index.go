@@ -109,7 +109,7 @@ func RepairIndex(ctx context.Context, repo *Repository, opts RepairIndexOptions,
 		return err
 	}
 
-	err = rewriteIndexFiles(ctx, repo, removePacks, oldIndexes, obsoleteIndexes, printer)
+	err = rewriteIndexFiles(ctx, repo, removePacks, oldIndexes, obsoleteIndexes, nil, printer)
 	if err != nil {
 		return err
 	}
@@ -119,12 +119,13 @@ func RepairIndex(ctx context.Context, repo *Repository, opts RepairIndexOptions,
 	return nil
 }
 
-func rewriteIndexFiles(ctx context.Context, repo *Repository, removePacks restic.IDSet, oldIndexes restic.IDSet, extraObsolete restic.IDs, printer progress.Printer) error {
+func rewriteIndexFiles(ctx context.Context, repo *Repository, removePacks restic.IDSet, oldIndexes restic.IDSet, extraObsolete restic.IDs, keepIndexes restic.IDSet, printer progress.Printer) error {
 	printer.P("rebuilding index\n")
 
 	bar := printer.NewCounter("indexes processed")
-	return repo.idx.Rewrite(ctx, repo, removePacks, oldIndexes, extraObsolete, index.MasterIndexRewriteOpts{
+	err := repo.idx.Rewrite(ctx, repo, removePacks, oldIndexes, extraObsolete, index.MasterIndexRewriteOpts{
 		SaveProgress: bar,
+		KeepIndexes:  keepIndexes,
 		DeleteProgress: func() *progress.Counter {
 			return printer.NewCounter("old indexes deleted")
 		},
@@ -136,4 +137,9 @@ func rewriteIndexFiles(ctx context.Context, repo *Repository, removePacks restic
 			}
 		},
 	})
+	if err != nil {
+		return err
+	}
+
+	return SaveIndexManifest(ctx, repo)
 }
@@ -3,22 +3,32 @@ package repository
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"os"
 	"runtime"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/restic/chunker"
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/cache"
 	"github.com/restic/restic/internal/backend/dryrun"
+	"github.com/restic/restic/internal/backend/transform"
 	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/logging"
+	"github.com/restic/restic/internal/metrics"
+	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository/index"
 	"github.com/restic/restic/internal/repository/pack"
 	"github.com/restic/restic/internal/restic"
@@ -31,6 +41,13 @@ const MinPackSize = 4 * 1024 * 1024
 const DefaultPackSize = 16 * 1024 * 1024
 const MaxPackSize = 128 * 1024 * 1024
 
+var logger = logging.Logger("repository")
+
+var (
+	blobsSaved     = metrics.NewCounterVec("restic_blobs_saved_total", "Number of new blobs saved in the repository.", "type")
+	blobBytesSaved = metrics.NewCounterVec("restic_blob_saved_bytes_total", "Uncompressed size of the new blobs saved in the repository.", "type")
+)
+
 // Repository is used to access a repository in a backend.
 type Repository struct {
 	be    backend.Backend
@@ -40,23 +57,48 @@ type Repository struct {
 	idx   *index.MasterIndex
 	Cache *cache.Cache
 
+	// keyCapabilities contains the capabilities of the key file used to open
+	// the repository
+	keyCapabilities []string
+	// keyfile is required in addition to the password for some keys
+	keyfile []byte
+
 	opts Options
+	// packTransforms is set once the backend applies the pack transforms
+	packTransforms bool
 
 	packerWg *errgroup.Group
 	uploader *packerUploader
 	treePM   *packerManager
 	dataPM   *packerManager
 
-	allocEnc sync.Once
-	allocDec sync.Once
-	enc      *zstd.Encoder
-	dec      *zstd.Decoder
+	// savedPacks contains the size of all pack files saved since the
+	// repository was opened
+	savedPacksMu sync.Mutex
+	savedPacks   map[restic.ID]int64
+	// indexSaved is set if an index file was saved since the index manifest
+	// was last written
+	indexSaved atomic.Bool
+
+	allocEnc     sync.Once
+	allocDec     sync.Once
+	allocDictEnc sync.Once
+	enc          *zstd.Encoder
+	dec          *zstd.Decoder
+	dictEnc      *zstd.Encoder
+
+	// rangeBudget limits the number of additional concurrent range requests
+	// across all pack files, see streamPackParallel
+	rangeBudget chan struct{}
 }
 
 type Options struct {
 	Compression   CompressionMode
 	PackSize      uint
 	NoExtraVerify bool
+	// PackTransformOptions configures the pack transforms listed in the
+	// repository config.
+	PackTransformOptions options.Options
 }
 
 // CompressionMode configures if data should be compressed.
@@ -124,6 +166,9 @@ func New(be backend.Backend, opts Options) (*Repository, error) {
 		opts: opts,
 		idx:  index.NewMasterIndex(),
 	}
+	if pbe := backend.AsBackend[backend.ParallelRangeBackend](be); pbe != nil && pbe.ParallelRangeRequests() > 1 {
+		repo.rangeBudget = make(chan struct{}, pbe.ParallelRangeRequests()-1)
+	}
 
 	return repo, nil
 }
@@ -131,6 +176,18 @@ func New(be backend.Backend, opts Options) (*Repository, error) {
 // setConfig assigns the given config and updates the repository parameters accordingly
 func (r *Repository) setConfig(cfg restic.Config) {
 	r.cfg = cfg
+
+	if len(cfg.CompressionDictionaries) > 0 {
+		// the decoder was possibly created while loading the config, recreate
+		// it such that it uses the dictionaries
+		if r.dec != nil {
+			r.dec.Close()
+		}
+		r.dec = nil
+		r.allocDec = sync.Once{}
+		r.dictEnc = nil
+		r.allocDictEnc = sync.Once{}
+	}
 }
 
 // Config returns the repository configuration.
@@ -153,6 +210,26 @@ func (r *Repository) UseCache(c *cache.Cache) {
 	r.be = c.Wrap(r.be)
 }
 
+// usePackTransforms replaces the backend with one that applies the named
+// transforms to all pack files. Unknown transforms result in an error.
+func (r *Repository) usePackTransforms(names []string) error {
+	if len(names) == 0 || r.packTransforms {
+		return nil
+	}
+	if r.Cache != nil {
+		return errors.New("pack transforms must be configured before the cache")
+	}
+
+	transforms, err := transform.Load(names, r.opts.PackTransformOptions)
+	if err != nil {
+		return err
+	}
+	debug.Log("using %d pack transforms", len(transforms))
+	r.be = transform.New(r.be, transforms...)
+	r.packTransforms = true
+	return nil
+}
+
 // SetDryRun sets the repo backend into dry-run mode.
 func (r *Repository) SetDryRun() {
 	r.be = dryrun.New(r.be)
@@ -258,7 +335,7 @@ func (r *Repository) loadBlob(ctx context.Context, blobs []restic.PackedBlob, bu
 
 		_, err := backend.ReadAt(ctx, r.be, h, int64(blob.Offset), buf)
 		if err != nil {
-			debug.Log("error loading blob %v: %v", blob, err)
+			logger.Warn("loading blob failed", "blob", blob.BlobHandle.String(), "pack", blob.PackID.Str(), "err", err)
 			lastError = err
 			continue
 		}
@@ -270,7 +347,7 @@ func (r *Repository) loadBlob(ctx context.Context, blobs []restic.PackedBlob, bu
 			err = pbv.Err
 		}
 		if err != nil {
-			debug.Log("error decoding blob %v: %v", blob, err)
+			logger.Warn("decoding blob failed", "blob", blob.BlobHandle.String(), "pack", blob.PackID.Str(), "err", err)
 			lastError = err
 			continue
 		}
@@ -292,31 +369,55 @@ func (r *Repository) loadBlob(ctx context.Context, blobs []restic.PackedBlob, bu
 	return nil, errors.Errorf("loading %v from %v packs failed", blobs[0].BlobHandle, len(blobs))
 }
 
+func (r *Repository) zstdEncoderLevel() zstd.EncoderLevel {
+	if r.opts.Compression == CompressionMax {
+		return zstd.SpeedBestCompression
+	}
+	return zstd.SpeedDefault
+}
+
+func (r *Repository) zstdEncoderOptions() []zstd.EOption {
+	return []zstd.EOption{
+		// Set the compression level configured.
+		zstd.WithEncoderLevel(r.zstdEncoderLevel()),
+		// Disable CRC, we have enough checks in place, makes the
+		// compressed data four bytes shorter.
+		zstd.WithEncoderCRC(false),
+		// Set a window of 512kbyte, so we have good lookbehind for usual
+		// blob sizes.
+		zstd.WithWindowSize(512 * 1024),
+	}
+}
+
 func (r *Repository) getZstdEncoder() *zstd.Encoder {
 	r.allocEnc.Do(func() {
-		level := zstd.SpeedDefault
-		if r.opts.Compression == CompressionMax {
-			level = zstd.SpeedBestCompression
+		enc, err := zstd.NewWriter(nil, r.zstdEncoderOptions()...)
+		if err != nil {
+			panic(err)
 		}
+		r.enc = enc
+	})
+	return r.enc
+}
 
-		opts := []zstd.EOption{
-			// Set the compression level configured.
-			zstd.WithEncoderLevel(level),
-			// Disable CRC, we have enough checks in place, makes the
-			// compressed data four bytes shorter.
-			zstd.WithEncoderCRC(false),
-			// Set a window of 512kbyte, so we have good lookbehind for usual
-			// blob sizes.
-			zstd.WithWindowSize(512 * 1024),
-		}
+// getZstdBlobEncoder returns the encoder for blobs. It uses the latest
+// compression dictionary of the repository, if there is one. Unpacked files
+// like the config are never compressed using a dictionary.
+func (r *Repository) getZstdBlobEncoder() *zstd.Encoder {
+	dicts := r.cfg.CompressionDictionaries
+	if len(dicts) == 0 {
+		return r.getZstdEncoder()
+	}
 
+	r.allocDictEnc.Do(func() {
+		opts := append(r.zstdEncoderOptions(), zstd.WithEncoderDict(dicts[len(dicts)-1]))
 		enc, err := zstd.NewWriter(nil, opts...)
 		if err != nil {
 			panic(err)
 		}
-		r.enc = enc
+		r.dictEnc = enc
 	})
-	return r.enc
+	return r.dictEnc
 }
 
 func (r *Repository) getZstdDecoder() *zstd.Decoder {
@@ -328,6 +429,10 @@ func (r *Repository) getZstdDecoder() *zstd.Decoder {
 			// conservative value.
 			zstd.WithDecoderMaxMemory(16 * 1024 * 1024 * 1024),
 		}
+		if len(r.cfg.CompressionDictionaries) > 0 {
+			// blobs compressed without a dictionary are still decoded as usual
+			opts = append(opts, zstd.WithDecoderDicts(r.cfg.CompressionDictionaries...))
+		}
 
 		dec, err := zstd.NewReader(nil, opts...)
 		if err != nil {
@@ -353,7 +458,7 @@ func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data
 		// compressed.
 		if r.opts.Compression != CompressionOff || t != restic.DataBlob {
 			uncompressedLength = len(data)
-			data = r.getZstdEncoder().EncodeAll(data, nil)
+			data = r.getZstdBlobEncoder().EncodeAll(data, nil)
 		}
 	}
 
@@ -447,30 +552,27 @@ func (r *Repository) decompressUnpacked(p []byte) ([]byte, error) {
 // SaveUnpacked encrypts data and stores it in the backend. Returned is the
 // storage hash.
 func (r *Repository) SaveUnpacked(ctx context.Context, t restic.FileType, buf []byte) (id restic.ID, err error) {
-	p := buf
-	if t != restic.ConfigFile {
-		p, err = r.compressUnpacked(p)
-		if err != nil {
-			return restic.ID{}, err
-		}
-	}
-
-	ciphertext := crypto.NewBlobBuffer(len(p))
-	ciphertext = ciphertext[:0]
-	nonce := crypto.NewRandomNonce()
-	ciphertext = append(ciphertext, nonce...)
-
-	ciphertext = r.key.Seal(ciphertext, nonce, p, nil)
+	return r.SaveUnpackedWithPrefix(ctx, t, nil, buf)
+}
 
-	if err := r.verifyUnpacked(ciphertext, t, buf); err != nil {
-		//nolint:revive // ignore linter warnings about error message spelling
-		return restic.ID{}, fmt.Errorf("Detected data corruption while saving file of type %v: %w\nCorrupted data is either caused by hardware issues or software bugs. Please open an issue at https://github.com/restic/restic/issues/new/choose for further troubleshooting.", t, err)
+// SaveUnpackedWithPrefix is like SaveUnpacked, but the first bytes of the
+// returned ID are replaced with prefix. This allows finding state files of a
+// certain kind without loading them. As the remaining bytes are still derived
+// from the content, LoadRaw can verify the integrity of the file.
+func (r *Repository) SaveUnpackedWithPrefix(ctx context.Context, t restic.FileType, prefix []byte, buf []byte) (id restic.ID, err error) {
+	if len(prefix) != 0 && (t != restic.StateFile || len(prefix) != restic.StateKindSize) {
+		return restic.ID{}, errors.Errorf("invalid prefix for file type %v", t)
+	}
+	ciphertext, err := r.sealUnpacked(t, buf)
+	if err != nil {
+		return restic.ID{}, err
 	}
 
 	if t == restic.ConfigFile {
 		id = restic.ID{}
 	} else {
 		id = restic.Hash(ciphertext)
+		copy(id[:], prefix)
 	}
 	h := backend.Handle{Type: t, Name: id.String()}
 
@@ -479,11 +581,39 @@ func (r *Repository) SaveUnpacked(ctx context.Context, t restic.FileType, buf []
 		debug.Log("error saving blob %v: %v", h, err)
 		return restic.ID{}, err
 	}
+	if t == restic.IndexFile {
+		r.indexSaved.Store(true)
+	}
 
 	debug.Log("blob %v saved", h)
 	return id, nil
 }
 
+// sealUnpacked compresses and encrypts buf as the content of a file of type t.
+func (r *Repository) sealUnpacked(t restic.FileType, buf []byte) ([]byte, error) {
+	p := buf
+	if t != restic.ConfigFile {
+		var err error
+		p, err = r.compressUnpacked(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ciphertext := crypto.NewBlobBuffer(len(p))
+	ciphertext = ciphertext[:0]
+	nonce := crypto.NewRandomNonce()
+	ciphertext = append(ciphertext, nonce...)
+
+	ciphertext = r.key.Seal(ciphertext, nonce, p, nil)
+
+	if err := r.verifyUnpacked(ciphertext, t, buf); err != nil {
+		//nolint:revive // ignore linter warnings about error message spelling
+		return nil, fmt.Errorf("Detected data corruption while saving file of type %v: %w\nCorrupted data is either caused by hardware issues or software bugs. Please open an issue at https://github.com/restic/restic/issues/new/choose for further troubleshooting.", t, err)
+	}
+	return ciphertext, nil
+}
+
 func (r *Repository) verifyUnpacked(buf []byte, t restic.FileType, expected []byte) error {
 	if r.opts.NoExtraVerify {
 		return nil
@@ -509,16 +639,31 @@ func (r *Repository) verifyUnpacked(buf []byte, t restic.FileType, expected []by
 
 func (r *Repository) RemoveUnpacked(ctx context.Context, t restic.FileType, id restic.ID) error {
 	// TODO prevent everything except removing snapshots for non-repository code
+	if (t == restic.PackFile || t == restic.SnapshotFile) && !r.CanDelete() {
+		return ErrAppendOnly
+	}
 	return r.be.Remove(ctx, backend.Handle{Type: t, Name: id.String()})
 }
 
-// Flush saves all remaining packs and the index
+// Flush saves all remaining packs and the index. If index files were added,
+// the index manifest is updated as well.
 func (r *Repository) Flush(ctx context.Context) error {
 	if err := r.flushPacks(ctx); err != nil {
 		return err
 	}
 
-	return r.idx.SaveIndex(ctx, r)
+	if err := r.idx.SaveIndex(ctx, r); err != nil {
+		return err
+	}
+
+	if !r.indexSaved.Swap(false) {
+		return nil
+	}
+	if err := SaveIndexManifest(ctx, r); err != nil {
+		r.indexSaved.Store(true)
+		return err
+	}
+	return nil
 }
 
 func (r *Repository) StartPackUploader(ctx context.Context, wg *errgroup.Group) {
@@ -595,17 +740,37 @@ func (r *Repository) clearIndex() {
 	r.idx = index.NewMasterIndex()
 }
 
+// mappedIndexKey derives the key which authenticates the mapped index in the
+// local cache from the master key.
+func (r *Repository) mappedIndexKey() []byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte("restic mapped index"))
+	_, _ = h.Write(r.key.EncryptionKey[:])
+	_, _ = h.Write(r.key.MACKey.K[:])
+	_, _ = h.Write(r.key.MACKey.R[:])
+	return h.Sum(nil)
+}
+
 // LoadIndex loads all index files from the backend in parallel and stores them
 func (r *Repository) LoadIndex(ctx context.Context, p *progress.Counter) error {
 	debug.Log("Loading index")
+	start := time.Now()
 
 	// reset in-memory index before loading it from the repository
 	r.clearIndex()
 
-	err := r.idx.Load(ctx, r, p, nil)
+	var err error
+	if feature.Flag.Enabled(feature.MappedIndex) && r.Cache != nil && r.Cache.MappedIndexFilename() != "" {
+		err = r.idx.LoadMapped(ctx, r, p, r.Cache.MappedIndexFilename(), r.mappedIndexKey())
+	} else {
+		err = r.idx.Load(ctx, r, p, nil)
+	}
 	if err != nil {
 		return err
 	}
+	if logger.Enabled(ctx, slog.LevelInfo) {
+		logger.Info("index loaded", "index_files", len(r.idx.IDs()), "duration", time.Since(start))
+	}
 
 	// Trigger GC to reset garbage collection threshold
 	runtime.GC()
@@ -740,14 +905,52 @@ func (r *Repository) SearchKey(ctx context.Context, password string, maxKeys int
 		}
 		return fmt.Errorf("config cannot be loaded: %w", err)
 	}
+	if err := r.usePackTransforms(cfg.PackTransforms); err != nil {
+		r.key = oldKey
+		r.keyID = oldKeyID
+		return err
+	}
+	r.keyCapabilities = key.Capabilities
+
+	r.setConfig(cfg)
+	return nil
+}
+
+// UseMasterKey opens the repository using the master key directly instead of
+// searching for a key file matching a password. This is used to recover
+// access to a repository from an exported master key. As there is no key file,
+// the repository is opened without any key capabilities.
+func (r *Repository) UseMasterKey(ctx context.Context, key *crypto.Key) error {
+	oldKey := r.key
+	oldKeyID := r.keyID
+
+	r.key = key
+	r.keyID = restic.ID{}
+	cfg, err := restic.LoadConfig(ctx, r)
+	if err != nil {
+		r.key = oldKey
+		r.keyID = oldKeyID
+
+		if err == crypto.ErrUnauthenticated {
+			return fmt.Errorf("master key does not match the repository: %w", err)
+		}
+		return fmt.Errorf("config cannot be loaded: %w", err)
+	}
+	if err := r.usePackTransforms(cfg.PackTransforms); err != nil {
+		r.key = oldKey
+		r.keyID = oldKeyID
+		return err
+	}
+	r.keyCapabilities = nil
 
 	r.setConfig(cfg)
 	return nil
 }
 
 // Init creates a new master key with the supplied password, initializes and
-// saves the repository config.
-func (r *Repository) Init(ctx context.Context, version uint, password string, chunkerPolynomial *chunker.Pol) error {
+// saves the repository config. The names of the pack transforms are stored in
+// the config, the transforms are configured using Options.PackTransformOptions.
+func (r *Repository) Init(ctx context.Context, version uint, password string, chunkerPolynomial *chunker.Pol, packTransforms []string) error {
 	if version > restic.MaxRepoVersion {
 		return fmt.Errorf("repository version %v too high", version)
 	}
@@ -756,6 +959,10 @@ func (r *Repository) Init(ctx context.Context, version uint, password string, ch
 		return fmt.Errorf("repository version %v too low", version)
 	}
 
+	if len(packTransforms) > 0 && version < restic.FeaturesRepoVersion {
+		return fmt.Errorf("pack transforms require repository version %v or later", restic.FeaturesRepoVersion)
+	}
+
 	_, err := r.be.Stat(ctx, backend.Handle{Type: restic.ConfigFile})
 	if err != nil && !r.be.IsNotExist(err) {
 		return err
@@ -788,6 +995,10 @@ func (r *Repository) Init(ctx context.Context, version uint, password string, ch
 	if chunkerPolynomial != nil {
 		cfg.ChunkerPolynomial = *chunkerPolynomial
 	}
+	cfg.PackTransforms = packTransforms
+	if err := r.usePackTransforms(packTransforms); err != nil {
+		return err
+	}
 
 	return r.init(ctx, password, cfg)
 }
@@ -816,6 +1027,30 @@ func (r *Repository) KeyID() restic.ID {
 	return r.keyID
 }
 
+// UseKeyfile sets the keyfile which is used in addition to the password to
+// open keys which require a keyfile. When initializing a repository, the
+// master key also requires the keyfile.
+func (r *Repository) UseKeyfile(keyfile []byte) {
+	r.keyfile = keyfile
+}
+
+// Keyfile returns the keyfile set using UseKeyfile.
+func (r *Repository) Keyfile() []byte {
+	return r.keyfile
+}
+
+// KeyCapabilities returns the capabilities of the current key.
+func (r *Repository) KeyCapabilities() []string {
+	return r.keyCapabilities
+}
+
+// CanDelete returns whether pack and snapshot files can be removed from the
+// repository. This is only prevented for append-only repositories which were
+// opened using a key without the delete capability.
+func (r *Repository) CanDelete() bool {
+	return !r.cfg.AppendOnly || slices.Contains(r.keyCapabilities, KeyCapabilityDelete)
+}
+
 // List runs fn for all files of type t in the repo.
 func (r *Repository) List(ctx context.Context, t restic.FileType, fn func(restic.ID, int64) error) error {
 	return r.be.List(ctx, t, func(fi backend.FileInfo) error {
@@ -890,44 +1125,115 @@ func (r *Repository) SaveBlob(ctx context.Context, t restic.BlobType, buf []byte
 	// only save when needed or explicitly told
 	if !known || storeDuplicate {
 		size, err = r.saveAndEncrypt(ctx, t, buf, newID)
+		if err == nil {
+			blobsSaved.With(t.String()).Inc()
+			blobBytesSaved.With(t.String()).Add(uint64(len(buf)))
+		}
 	}
 
 	return newID, known, size, err
 }
 
 type backendLoadFn func(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error
+type backendLoadRangesFn func(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error
 type loadBlobFn func(ctx context.Context, t restic.BlobType, id restic.ID, buf []byte) ([]byte, error)
 
+// loadPartsFn downloads the parts of a pack file, see loadPackPart and
+// loadPackParts.
+type loadPartsFn func(ctx context.Context, parts [][]restic.Blob) ([][]byte, error)
+
 // Skip sections with more than 1MB unused blobs
 const maxUnusedRange = 1 * 1024 * 1024
 
+// maxChunkSize is the maximum size of a part of a pack file, and of the parts
+// requested together using a multi-range request.
+const maxChunkSize = 2 * DefaultPackSize
+
 // LoadBlobsFromPack loads the listed blobs from the specified pack file. The plaintext blob is passed to
 // the handleBlobFn callback or an error if decryption failed or the blob hash does not match.
 // handleBlobFn is called at most once for each blob. If the callback returns an error,
 // then LoadBlobsFromPack will abort and not retry it. The buf passed to the callback is only valid within
 // this specific call. The callback must not keep a reference to buf.
 func (r *Repository) LoadBlobsFromPack(ctx context.Context, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
-	return streamPack(ctx, r.be.Load, r.LoadBlob, r.getZstdDecoder(), r.key, packID, blobs, handleBlobFn)
+	if be, ok := r.be.(backend.MultiRangeBackend); ok && be.MaxRanges() > 1 {
+		return streamPackRanges(ctx, be.LoadRanges, r.LoadBlob, r.getZstdDecoder(), r.key, packID, blobs, be.MaxRanges(), r.rangeBudget, handleBlobFn)
+	}
+	return streamPack(ctx, r.be.Load, r.LoadBlob, r.getZstdDecoder(), r.key, packID, blobs, r.rangeBudget, handleBlobFn)
+}
+
+// streamPack loads the blobs from the pack file. Parts of the pack file which
+// are separated by large unused ranges are requested separately. If budget is
+// not nil, additional parts are requested concurrently as long as budget has
+// room, see streamPackParallel. handleBlobFn is always called sequentially in
+// the order of the blobs in the pack file.
+func streamPack(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, blobs []restic.Blob, budget chan struct{}, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+	parts, err := splitPack(packID, blobs)
+	if err != nil || len(parts) == 0 {
+		return err
+	}
+
+	batches := make([][][]restic.Blob, 0, len(parts))
+	for _, part := range parts {
+		batches = append(batches, [][]restic.Blob{part})
+	}
+	loadParts := func(ctx context.Context, parts [][]restic.Blob) ([][]byte, error) {
+		data, err := loadPackPart(ctx, beLoad, packID, parts[0])
+		return [][]byte{data}, err
+	}
+	return streamPackBatches(ctx, loadParts, loadBlobFn, dec, key, packID, batches, budget, handleBlobFn)
 }
 
-func streamPack(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+// streamPackRanges is the same as streamPack, except that up to maxRanges
+// parts of the pack file are requested together using beLoadRanges.
+func streamPackRanges(ctx context.Context, beLoadRanges backendLoadRangesFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, blobs []restic.Blob, maxRanges int, budget chan struct{}, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+	parts, err := splitPack(packID, blobs)
+	if err != nil || len(parts) == 0 {
+		return err
+	}
+
+	// group consecutive parts, such that the data of a batch is at most
+	// maxChunkSize bytes unless it consists of a single part
+	var batches [][][]restic.Blob
+	first := 0
+	var size uint
+	for i, part := range parts {
+		partSize := partLength(part)
+		if i > first && (i-first >= maxRanges || size+partSize > maxChunkSize) {
+			batches = append(batches, parts[first:i])
+			first = i
+			size = 0
+		}
+		size += partSize
+	}
+	batches = append(batches, parts[first:])
+
+	loadParts := func(ctx context.Context, parts [][]restic.Blob) ([][]byte, error) {
+		return loadPackParts(ctx, beLoadRanges, packID, parts)
+	}
+	return streamPackBatches(ctx, loadParts, loadBlobFn, dec, key, packID, batches, budget, handleBlobFn)
+}
+
+// splitPack sorts the blobs by offset and splits them into parts. Parts are
+// split at large unused ranges and if they would become larger than
+// maxChunkSize.
+func splitPack(packID restic.ID, blobs []restic.Blob) ([][]restic.Blob, error) {
 	if len(blobs) == 0 {
 		// nothing to do
-		return nil
+		return nil, nil
 	}
 
 	sort.Slice(blobs, func(i, j int) bool {
 		return blobs[i].Offset < blobs[j].Offset
 	})
 
+	var parts [][]restic.Blob
 	lowerIdx := 0
 	lastPos := blobs[0].Offset
-	const maxChunkSize = 2 * DefaultPackSize
 
 	for i := 0; i < len(blobs); i++ {
 		if blobs[i].Offset < lastPos {
 			// don't wait for streamPackPart to fail
-			return errors.Errorf("overlapping blobs in pack %v", packID)
+			return nil, errors.Errorf("overlapping blobs in pack %v", packID)
 		}
 
 		chunkSizeAfter := (blobs[i].Offset + blobs[i].Length) - blobs[lowerIdx].Offset
@@ -944,19 +1250,138 @@ func streamPack(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn
 
 		if split {
 			// load everything up to the skipped file section
-			err := streamPackPart(ctx, beLoad, loadBlobFn, dec, key, packID, blobs[lowerIdx:i], handleBlobFn)
-			if err != nil {
-				return err
-			}
+			parts = append(parts, blobs[lowerIdx:i])
 			lowerIdx = i
 		}
 		lastPos = blobs[i].Offset + blobs[i].Length
 	}
 	// load remainder
-	return streamPackPart(ctx, beLoad, loadBlobFn, dec, key, packID, blobs[lowerIdx:], handleBlobFn)
+	parts = append(parts, blobs[lowerIdx:])
+	return parts, nil
 }
 
-func streamPackPart(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+// partLength returns the length of the section of the pack file which
+// contains the blobs of part.
+func partLength(part []restic.Blob) uint {
+	return part[len(part)-1].Offset + part[len(part)-1].Length - part[0].Offset
+}
+
+// streamPackBatches downloads the batches of parts using loadParts and passes
+// the parts to handlePackPart. The batches are downloaded concurrently if
+// budget is not nil.
+func streamPackBatches(ctx context.Context, loadParts loadPartsFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, batches [][][]restic.Blob, budget chan struct{}, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+	if budget != nil && len(batches) > 1 {
+		return streamPackParallel(ctx, loadParts, loadBlobFn, dec, key, packID, batches, budget, handleBlobFn)
+	}
+	for _, batch := range batches {
+		data, err := loadParts(ctx, batch)
+		err = handlePackBatch(ctx, loadBlobFn, dec, key, packID, batch, data, err, handleBlobFn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamPackParallel downloads the batches of the pack file concurrently. One
+// download per pack file is always allowed, each further concurrent download
+// requires a slot in budget. As budget is shared by all pack files of a
+// repository, this bounds the total number of additional requests, no matter
+// how many pack files are loaded at the same time. The batches are passed to
+// handlePackBatch in order, a batch is only downloaded once less than
+// cap(budget)+1 batches are waiting to be processed.
+func streamPackParallel(ctx context.Context, loadParts loadPartsFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, batches [][][]restic.Blob, budget chan struct{}, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		// wait for all downloads before returning
+		cancel()
+		wg.Wait()
+	}()
+
+	type batchResult struct {
+		data [][]byte
+		err  error
+	}
+	results := make([]chan batchResult, len(batches))
+	for i := range results {
+		results[i] = make(chan batchResult, 1)
+	}
+	sem := make(chan struct{}, cap(budget)+1)
+	free := make(chan struct{}, 1)
+	free <- struct{}{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i, batch := range batches {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			// prefer the slot of this pack file over the shared budget
+			var release func()
+			select {
+			case <-free:
+				release = func() { free <- struct{}{} }
+			default:
+				select {
+				case <-free:
+					release = func() { free <- struct{}{} }
+				case budget <- struct{}{}:
+					release = func() { <-budget }
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			wg.Add(1)
+			go func(result chan<- batchResult, batch [][]restic.Blob) {
+				defer wg.Done()
+				data, err := loadParts(ctx, batch)
+				release()
+				result <- batchResult{data: data, err: err}
+			}(results[i], batch)
+		}
+	}()
+
+	for i, batch := range batches {
+		var result batchResult
+		select {
+		case result = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		err := handlePackBatch(ctx, loadBlobFn, dec, key, packID, batch, result.data, result.err, handleBlobFn)
+		<-sem
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handlePackBatch passes each part of the batch and its data to
+// handlePackPart. If the download failed with err, it is passed on for all
+// parts.
+func handlePackBatch(ctx context.Context, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, batch [][]restic.Blob, data [][]byte, err error, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+	for i, part := range batch {
+		var partData []byte
+		if err == nil {
+			partData = data[i]
+		}
+		if herr := handlePackPart(ctx, loadBlobFn, dec, key, packID, part, partData, err, handleBlobFn); herr != nil {
+			return herr
+		}
+	}
+	return nil
+}
+
+// loadPackPart downloads the section of the pack file which contains blobs.
+func loadPackPart(ctx context.Context, beLoad backendLoadFn, packID restic.ID, blobs []restic.Blob) ([]byte, error) {
 	h := backend.Handle{Type: restic.PackFile, Name: packID.String(), IsMetadata: blobs[0].Type.IsMetadata()}
 
 	dataStart := blobs[0].Offset
@@ -969,6 +1394,37 @@ func streamPackPart(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBl
 		_, cerr := io.ReadFull(rd, data)
 		return cerr
 	})
+	return data, err
+}
+
+// loadPackParts downloads the sections of the pack file which contain the
+// parts using a single call to beLoadRanges.
+func loadPackParts(ctx context.Context, beLoadRanges backendLoadRangesFn, packID restic.ID, parts [][]restic.Blob) ([][]byte, error) {
+	h := backend.Handle{Type: restic.PackFile, Name: packID.String(), IsMetadata: parts[0][0].Type.IsMetadata()}
+
+	ranges := make([]backend.Range, 0, len(parts))
+	data := make([][]byte, 0, len(parts))
+	for _, part := range parts {
+		length := int(partLength(part))
+		ranges = append(ranges, backend.Range{Offset: int64(part[0].Offset), Length: length})
+		data = append(data, make([]byte, length))
+	}
+
+	debug.Log("streaming pack %v, ranges: %v", packID, ranges)
+
+	err := beLoadRanges(ctx, h, ranges, func(i int, rd io.Reader) error {
+		_, cerr := io.ReadFull(rd, data[i])
+		return cerr
+	})
+	return data, err
+}
+
+// handlePackPart passes the blobs contained in data, which was downloaded by
+// loadPackPart, to handleBlobFn. If the download failed with err, the blobs
+// are loaded using loadBlobFn if possible.
+func handlePackPart(ctx context.Context, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, blobs []restic.Blob, data []byte, err error, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+	dataStart := blobs[0].Offset
+
 	// prevent callbacks after cancellation
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"io"
 	"math/rand"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	"github.com/google/go-cmp/cmp"
@@ -176,7 +179,7 @@ func testStreamPack(t *testing.T, version uint) {
 	switch version {
 	case 1:
 		compress = false
-	case 2:
+	case 2, 3:
 		compress = true
 	default:
 		t.Fatal("test does not support repository version", version)
@@ -276,7 +279,7 @@ func testStreamPack(t *testing.T, version uint) {
 
 				loadCalls = 0
 				shortFirstLoad = test.shortFirstLoad
-				err := streamPack(ctx, load, nil, dec, &key, restic.ID{}, test.blobs, handleBlob)
+				err := streamPack(ctx, load, nil, dec, &key, restic.ID{}, test.blobs, nil, handleBlob)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -290,6 +293,139 @@ func testStreamPack(t *testing.T, version uint) {
 	})
 	shortFirstLoad = false
 
+	// the parts of the pack file separated by large unused ranges are loaded concurrently
+	t.Run("parallel", func(t *testing.T) {
+		// three parts, separated by the blobs with a size of 13522811 and 3522811 bytes
+		blobs := []restic.Blob{
+			packfileBlobs[13],
+			packfileBlobs[0],
+			packfileBlobs[9],
+			packfileBlobs[2],
+			packfileBlobs[7],
+		}
+
+		var m sync.Mutex
+		var calls, active, maxActive int
+		parallelLoad := func(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+			m.Lock()
+			calls++
+			active++
+			maxActive = max(maxActive, active)
+			m.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+
+			m.Lock()
+			active--
+			m.Unlock()
+			return fn(bytes.NewReader(packfile[offset : offset+int64(length)]))
+		}
+
+		var gotBlobs []restic.ID
+		handleBlob := func(blob restic.BlobHandle, buf []byte, err error) error {
+			rtest.OK(t, err)
+			rtest.Equals(t, blob.ID, restic.Hash(buf))
+			gotBlobs = append(gotBlobs, blob.ID)
+			return nil
+		}
+
+		err := streamPack(context.TODO(), parallelLoad, nil, dec, &key, restic.ID{}, blobs, make(chan struct{}, 3), handleBlob)
+		rtest.OK(t, err)
+		rtest.Equals(t, 3, calls)
+		rtest.Assert(t, maxActive > 1, "parts were not loaded concurrently")
+		// blobs are passed in the order of the pack file
+		rtest.Equals(t, []restic.ID{packfileBlobs[0].ID, packfileBlobs[2].ID, packfileBlobs[7].ID, packfileBlobs[9].ID, packfileBlobs[13].ID}, gotBlobs)
+
+		// an error of the callback stops the download of the remaining parts
+		testErr := errors.New("test error")
+		gotBlobs = nil
+		handleBlob = func(blob restic.BlobHandle, buf []byte, err error) error {
+			gotBlobs = append(gotBlobs, blob.ID)
+			if blob.ID.Equal(packfileBlobs[7].ID) {
+				return testErr
+			}
+			return nil
+		}
+		err = streamPack(context.TODO(), parallelLoad, nil, dec, &key, restic.ID{}, blobs, make(chan struct{}, 1), handleBlob)
+		rtest.Assert(t, errors.Is(err, testErr), "unexpected error %v", err)
+		rtest.Equals(t, []restic.ID{packfileBlobs[0].ID, packfileBlobs[2].ID, packfileBlobs[7].ID}, gotBlobs)
+
+		// the budget limits the additional requests of all pack files together
+		calls, maxActive = 0, 0
+		budget := make(chan struct{}, 1)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := streamPack(context.TODO(), parallelLoad, nil, dec, &key, restic.ID{}, slices.Clone(blobs), budget, func(blob restic.BlobHandle, buf []byte, err error) error {
+					return err
+				})
+				rtest.OK(t, err)
+			}()
+		}
+		wg.Wait()
+		rtest.Equals(t, 12, calls)
+		rtest.Assert(t, maxActive <= 5, "too many concurrent requests: %d", maxActive)
+	})
+
+	t.Run("ranges", func(t *testing.T) {
+		// three parts, separated by the blobs with a size of 13522811 and 3522811 bytes
+		blobs := []restic.Blob{
+			packfileBlobs[13],
+			packfileBlobs[0],
+			packfileBlobs[9],
+			packfileBlobs[2],
+			packfileBlobs[7],
+		}
+
+		var m sync.Mutex
+		var requests [][]backend.Range
+		loadRanges := func(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+			m.Lock()
+			requests = append(requests, ranges)
+			m.Unlock()
+			for i, r := range ranges {
+				if err := fn(i, bytes.NewReader(packfile[r.Offset:r.Offset+int64(r.Length)])); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		var gotBlobs []restic.ID
+		handleBlob := func(blob restic.BlobHandle, buf []byte, err error) error {
+			rtest.OK(t, err)
+			rtest.Equals(t, blob.ID, restic.Hash(buf))
+			gotBlobs = append(gotBlobs, blob.ID)
+			return nil
+		}
+		wantBlobs := []restic.ID{packfileBlobs[0].ID, packfileBlobs[2].ID, packfileBlobs[7].ID, packfileBlobs[9].ID, packfileBlobs[13].ID}
+
+		// all parts are loaded using a single request
+		err := streamPackRanges(context.TODO(), loadRanges, nil, dec, &key, restic.ID{}, blobs, 32, make(chan struct{}, 3), handleBlob)
+		rtest.OK(t, err)
+		rtest.Equals(t, 1, len(requests))
+		rtest.Equals(t, 3, len(requests[0]))
+		rtest.Equals(t, wantBlobs, gotBlobs)
+
+		// at most maxRanges parts per request
+		requests = nil
+		gotBlobs = nil
+		err = streamPackRanges(context.TODO(), loadRanges, nil, dec, &key, restic.ID{}, blobs, 2, make(chan struct{}, 3), handleBlob)
+		rtest.OK(t, err)
+		rtest.Equals(t, 2, len(requests))
+		rtest.Equals(t, wantBlobs, gotBlobs)
+
+		// a failed request is reported for the blobs of all its parts
+		testErr := errors.New("test error")
+		failRanges := func(ctx context.Context, h backend.Handle, ranges []backend.Range, fn func(i int, rd io.Reader) error) error {
+			return testErr
+		}
+		err = streamPackRanges(context.TODO(), failRanges, nil, dec, &key, restic.ID{}, blobs, 32, nil, handleBlob)
+		rtest.Assert(t, errors.Is(err, testErr), "unexpected error %v", err)
+	})
+
 	// next, test invalid uses, which should return an error
 	t.Run("invalid", func(t *testing.T) {
 		tests := []struct {
@@ -339,7 +475,7 @@ func testStreamPack(t *testing.T, version uint) {
 					return err
 				}
 
-				err := streamPack(ctx, load, nil, dec, &key, restic.ID{}, test.blobs, handleBlob)
+				err := streamPack(ctx, load, nil, dec, &key, restic.ID{}, test.blobs, nil, handleBlob)
 				if err == nil {
 					t.Fatalf("wanted error %v, got nil", test.err)
 				}
@@ -515,7 +651,7 @@ func TestStreamPackFallback(t *testing.T) {
 			return err
 		}
 
-		err := streamPack(ctx, loadPack, loadBlob, dec, &key, restic.ID{}, blobs, handleBlob)
+		err := streamPack(ctx, loadPack, loadBlob, dec, &key, restic.ID{}, blobs, nil, handleBlob)
 		rtest.OK(t, err)
 		rtest.Assert(t, blobOK, "blob failed to load")
 	}
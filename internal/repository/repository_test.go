@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"io"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -81,6 +82,25 @@ func testSave(t *testing.T, version uint, calculateID bool) {
 	}
 }
 
+func TestSavedPacks(t *testing.T) {
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	rtest.Equals(t, 0, len(repo.SavedPacks()))
+
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	_, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, rtest.Random(23, 1000), restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.Background()))
+
+	packs := repo.SavedPacks()
+	rtest.Equals(t, 1, len(packs))
+	for id, size := range packs {
+		fi, err := be.Stat(context.TODO(), backend.Handle{Type: restic.PackFile, Name: id.String()})
+		rtest.OK(t, err)
+		rtest.Equals(t, fi.Size, size)
+	}
+}
+
 func BenchmarkSaveAndEncrypt(t *testing.B) {
 	repository.BenchmarkAllVersions(t, benchmarkSaveAndEncrypt)
 }
@@ -459,12 +479,12 @@ func TestNoDoubleInit(t *testing.T) {
 	rtest.OK(t, err)
 
 	pol := r.Config().ChunkerPolynomial
-	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol)
+	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol, nil)
 	rtest.Assert(t, strings.Contains(err.Error(), "repository master key and config already initialized"), "expected config exist error, got %q", err)
 
 	// must also prevent init if only keys exist
 	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: backend.ConfigFile}))
-	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol)
+	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol, nil)
 	rtest.Assert(t, strings.Contains(err.Error(), "repository already contains keys"), "expected already contains keys error, got %q", err)
 
 	// must also prevent init if a snapshot exists and keys were deleted
@@ -474,6 +494,65 @@ func TestNoDoubleInit(t *testing.T) {
 	rtest.OK(t, be.List(context.TODO(), restic.KeyFile, func(fi backend.FileInfo) error {
 		return be.Remove(context.TODO(), backend.Handle{Type: restic.KeyFile, Name: fi.Name})
 	}))
-	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol)
+	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol, nil)
 	rtest.Assert(t, strings.Contains(err.Error(), "repository already contains snapshots"), "expected already contains snapshots error, got %q", err)
 }
+
+func TestInitPackTransforms(t *testing.T) {
+	repository.TestUseLowSecurityKDFParameters(t)
+	keyFile := filepath.Join(t.TempDir(), "key")
+	rtest.OK(t, os.WriteFile(keyFile, rtest.Random(23, 32), 0600))
+	opts := repository.Options{PackTransformOptions: map[string]string{"transform.sign.key-file": keyFile}}
+	be := mem.New()
+
+	repo, err := repository.New(be, opts)
+	rtest.OK(t, err)
+	err = repo.Init(context.TODO(), 2, rtest.TestPassword, nil, []string{"sign"})
+	rtest.Assert(t, err != nil, "pack transforms accepted for repository version 2")
+	err = repo.Init(context.TODO(), 3, rtest.TestPassword, nil, []string{"missing"})
+	rtest.Assert(t, err != nil, "unknown pack transform accepted")
+	rtest.OK(t, repo.Init(context.TODO(), 3, rtest.TestPassword, nil, []string{"sign"}))
+
+	// opening the repository requires the options for the transforms
+	repo, err = repository.New(be, repository.Options{})
+	rtest.OK(t, err)
+	err = repo.SearchKey(context.TODO(), rtest.TestPassword, 1, "")
+	rtest.Assert(t, err != nil, "repository opened without configured pack transforms")
+
+	repo, err = repository.New(be, opts)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.SearchKey(context.TODO(), rtest.TestPassword, 1, ""))
+	rtest.Equals(t, []string{"sign"}, repo.Config().PackTransforms)
+}
+
+func TestCheckPackSample(t *testing.T) {
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	for i := 0; i < 5; i++ {
+		_, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, rtest.Random(23+i, 1000), restic.ID{}, false)
+		rtest.OK(t, err)
+		// store each blob in a separate pack file
+		rtest.OK(t, repo.Flush(context.Background()))
+		repo.StartPackUploader(context.TODO(), &wg)
+	}
+	packs := restic.NewIDSet()
+	for id := range repo.SavedPacks() {
+		packs.Insert(id)
+	}
+	rtest.Equals(t, 5, len(packs))
+
+	checked, missing, err := repo.CheckPackSample(context.TODO(), 3)
+	rtest.OK(t, err)
+	rtest.Equals(t, 3, checked)
+	rtest.Equals(t, 0, len(missing))
+
+	removed := packs.List()[0]
+	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: restic.PackFile, Name: removed.String()}))
+
+	checked, missing, err = repo.CheckPackSample(context.TODO(), 10)
+	rtest.OK(t, err)
+	rtest.Equals(t, 5, checked)
+	rtest.Equals(t, restic.IDs{removed}, missing)
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ListRetained returns the files of type t which the backend protects from
+// deletion, together with the end of their retention period. It returns an
+// empty map if the backend does not support retention periods.
+func (r *Repository) ListRetained(ctx context.Context, t restic.FileType) (map[restic.ID]time.Time, error) {
+	retained := make(map[restic.ID]time.Time)
+	be := backend.AsBackend[backend.RetentionBackend](r.be)
+	if be == nil {
+		return retained, nil
+	}
+
+	err := be.ListRetained(ctx, t, func(name string, until time.Time) error {
+		id, err := restic.ParseID(name)
+		if err != nil {
+			// ignore files which are not part of the repository
+			return nil
+		}
+		retained[id] = until
+		return nil
+	})
+	return retained, err
+}
@@ -0,0 +1,114 @@
+package repository_test
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// retentionBackend refuses to delete the files in refuse and reports those in
+// listed as retained.
+type retentionBackend struct {
+	backend.Backend
+	listed restic.IDSet
+	refuse restic.IDSet
+}
+
+var _ backend.RetentionBackend = &retentionBackend{}
+
+func (be *retentionBackend) Remove(ctx context.Context, h backend.Handle) error {
+	id, err := restic.ParseID(h.Name)
+	if err == nil && be.refuse.Has(id) {
+		return &backend.RetentionError{Handle: h}
+	}
+	return be.Backend.Remove(ctx, h)
+}
+
+func (be *retentionBackend) ListRetained(ctx context.Context, t backend.FileType, fn func(name string, until time.Time) error) error {
+	return be.Backend.List(ctx, t, func(fi backend.FileInfo) error {
+		id, err := restic.ParseID(fi.Name)
+		if err != nil || !be.listed.Has(id) {
+			return nil
+		}
+		return fn(fi.Name, time.Now().Add(time.Hour))
+	})
+}
+
+func listIDs(t *testing.T, repo restic.Lister, tpe restic.FileType) restic.IDSet {
+	ids := restic.NewIDSet()
+	rtest.OK(t, repo.List(context.TODO(), tpe, func(id restic.ID, _ int64) error {
+		ids.Insert(id)
+		return nil
+	}))
+	return ids
+}
+
+func pruneAll(t *testing.T, repo *repository.Repository) repository.PruneStats {
+	opts := repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+	}
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+	plan, err := repository.PlanPrune(context.TODO(), opts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		return nil
+	}, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+	return plan.Stats()
+}
+
+func TestPruneRetained(t *testing.T) {
+	random := rand.New(rand.NewSource(23))
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	createRandomBlobs(t, random, repo, 5, 0.5, true)
+	lockedPacks := listIDs(t, repo, restic.PackFile)
+	lockedIndexes := listIDs(t, repo, restic.IndexFile)
+	lockedBlobs := listBlobs(repo)
+	for i := 0; i < 3; i++ {
+		createRandomBlobs(t, random, repo, 5, 0.5, true)
+	}
+
+	// only the index is reported, its packs must be kept nevertheless
+	locked := lockedPacks.Clone()
+	locked.Merge(lockedIndexes)
+	rbe := &retentionBackend{Backend: be, listed: lockedIndexes, refuse: locked}
+	repo = repository.TestOpenBackend(t, rbe)
+	stats := pruneAll(t, repo)
+	rtest.Equals(t, uint(len(lockedPacks)), stats.Packs.Retained)
+
+	repo = repository.TestOpenBackend(t, rbe)
+	checker.TestCheckRepo(t, repo, true)
+	rtest.Equals(t, lockedPacks, listIDs(t, repo, restic.PackFile))
+	rtest.Equals(t, lockedIndexes, listIDs(t, repo, restic.IndexFile))
+	rtest.Assert(t, listBlobs(repo).Equals(lockedBlobs), "unexpected blobs, wanted %v got %v", lockedBlobs, listBlobs(repo))
+}
+
+func TestPruneRetentionErrorOnDelete(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	repo, be := repository.TestRepositoryWithVersion(t, 0)
+	createRandomBlobs(t, random, repo, 5, 0.5, true)
+	createRandomBlobs(t, random, repo, 5, 0.5, true)
+	packs := listIDs(t, repo, restic.PackFile)
+
+	// a pack whose retention period is not reported is skipped on deletion
+	var refused restic.ID
+	for id := range packs {
+		refused = id
+		break
+	}
+	rbe := &retentionBackend{Backend: be, refuse: restic.NewIDSet(refused)}
+	repo = repository.TestOpenBackend(t, rbe)
+	pruneAll(t, repo)
+
+	rtest.Equals(t, restic.NewIDSet(refused), listIDs(t, repo, restic.PackFile))
+	rtest.Equals(t, 0, len(listBlobs(repository.TestOpenBackend(t, rbe))))
+}
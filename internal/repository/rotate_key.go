@@ -0,0 +1,587 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository/pack"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+const keyRotationKind = "key-rotation"
+
+// ErrOtherKeysExist is returned when starting a master key rotation while the
+// repository contains key files besides the current one.
+var ErrOtherKeysExist = errors.New("the repository contains other keys which would still grant access using the previous master key")
+
+// keyRotation lists the pack files which were stored before the master key
+// was rotated and thus have to be re-encrypted using the new master key.
+type keyRotation struct {
+	Started time.Time `json:"started"`
+	// Key identifies the master key the rotation belongs to. The state is
+	// discarded if it does not match the current master key.
+	Key   restic.ID  `json:"key"`
+	Packs restic.IDs `json:"packs"`
+	// Snapshots maps the IDs of re-encrypted snapshots to their new IDs. It
+	// is stored before the old snapshot files are removed.
+	Snapshots map[string]restic.ID `json:"snapshots,omitempty"`
+}
+
+// maxVerifyPasses limits how often the packs which still use the previous
+// master key are re-encrypted before the rotation is aborted.
+const maxVerifyPasses = 3
+
+// keyFingerprint identifies the master key k. The state file which contains it
+// is encrypted using that key.
+func keyFingerprint(k *crypto.Key) restic.ID {
+	buf := make([]byte, 0, len(k.EncryptionKey)+len(k.MACKey.K)+len(k.MACKey.R))
+	buf = append(buf, k.EncryptionKey[:]...)
+	buf = append(buf, k.MACKey.K[:]...)
+	buf = append(buf, k.MACKey.R[:]...)
+	return restic.Hash(buf)
+}
+
+// RotateMasterKeyOptions collects the parameters for RotateMasterKey.
+type RotateMasterKeyOptions struct {
+	// Password is used for the key file which replaces the current one.
+	Password string
+	// RemoveOtherKeys allows removing all other key files when starting the
+	// rotation. Otherwise, ErrOtherKeysExist is returned if such keys exist.
+	RemoveOtherKeys bool
+	// Deadline stops re-encrypting pack files once it is reached. The
+	// rotation then has to be continued later on. A zero value means no limit.
+	Deadline time.Time
+}
+
+// KeyRotationInProgress returns whether the master key was rotated, but not
+// all data of the repository has been re-encrypted yet.
+func (r *Repository) KeyRotationInProgress() bool {
+	return r.key != nil && r.key.Previous != nil
+}
+
+// RotateMasterKey replaces the master key of the repository and re-encrypts
+// all data using the new master key. Until the rotation is complete, the new
+// master key is stored together with the previous one, such that data which
+// has not been re-encrypted yet remains accessible. The progress is stored in
+// the repository, thus an interrupted rotation or one which reached the
+// deadline is continued by calling RotateMasterKey again. It returns whether
+// the rotation is complete.
+//
+// Snapshot files are saved again, which changes their IDs. The repository
+// must be locked exclusively.
+func RotateMasterKey(ctx context.Context, repo *Repository, opts RotateMasterKeyOptions, printer progress.Printer) (bool, error) {
+	if repo.KeyID().IsNull() {
+		return false, errors.New("rotating the master key requires opening the repository using a key file")
+	}
+	if repo.Config().Version < restic.FeaturesRepoVersion {
+		return false, fmt.Errorf("rotating the master key requires repository version %v: %w", restic.FeaturesRepoVersion, restic.ErrStateUnsupported)
+	}
+	if !repo.CanDelete() {
+		return false, ErrAppendOnly
+	}
+
+	// the new key requires the same keyfile as the current key
+	current, err := LoadKey(ctx, repo, repo.KeyID())
+	if err != nil {
+		return false, err
+	}
+	var keyfile []byte
+	if current.Keyfile {
+		keyfile = repo.Keyfile()
+	}
+
+	if !repo.KeyRotationInProgress() {
+		if err := startKeyRotation(ctx, repo, opts, keyfile, printer); err != nil {
+			return false, err
+		}
+	} else {
+		printer.P("continuing master key rotation\n")
+	}
+
+	rotation, err := loadKeyRotation(ctx, repo)
+	if err != nil {
+		return false, err
+	}
+
+	for pass := 0; ; pass++ {
+		complete, err := reencryptPacks(ctx, repo, rotation, opts.Deadline, printer)
+		if err != nil || !complete {
+			return false, err
+		}
+
+		// the previous key is dropped afterwards, make sure that it is no
+		// longer required to decrypt any pack file
+		printer.P("verifying that all packs use the new master key\n")
+		remaining, err := packsUsingPreviousKey(ctx, repo)
+		if err != nil {
+			return false, err
+		}
+		if len(remaining) == 0 {
+			break
+		}
+		if pass+1 >= maxVerifyPasses {
+			return false, errors.Fatalf("%d packs still use the previous master key, the rotation cannot be completed", len(remaining))
+		}
+		printer.P("%d packs still use the previous master key\n", len(remaining))
+		rotation.Packs = remaining
+		if err := saveKeyRotation(ctx, repo, rotation); err != nil {
+			return false, err
+		}
+	}
+
+	return true, finishKeyRotation(ctx, repo, rotation, opts.Password, keyfile, printer)
+}
+
+func startKeyRotation(ctx context.Context, repo *Repository, opts RotateMasterKeyOptions, keyfile []byte, printer progress.Printer) error {
+	otherKeys := restic.NewIDSet()
+	err := repo.List(ctx, restic.KeyFile, func(id restic.ID, _ int64) error {
+		if id != repo.KeyID() {
+			otherKeys.Insert(id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(otherKeys) > 0 && !opts.RemoveOtherKeys {
+		return ErrOtherKeysExist
+	}
+
+	// the state of an earlier rotation whose removal failed must not be used
+	if err := restic.RemoveState(ctx, repo, keyRotationKind); err != nil {
+		return fmt.Errorf("removing old key rotation state failed: %w", err)
+	}
+
+	master := crypto.NewRandomKey()
+	master.Previous = repo.Key().Current()
+	if err := replaceKey(ctx, repo, master, opts.Password, keyfile, printer); err != nil {
+		return err
+	}
+
+	for id := range otherKeys {
+		if err := RemoveKey(ctx, repo, id); err != nil {
+			return fmt.Errorf("removing key %v failed: %w", id.Str(), err)
+		}
+		printer.P("removed key %v\n", id.Str())
+	}
+
+	// all packs which exist at this point are encrypted using the previous key
+	_, err = loadKeyRotation(ctx, repo)
+	return err
+}
+
+// replaceKey stores master in a new key file for password, switches the
+// repository to the new key file and removes the previously used key file.
+func replaceKey(ctx context.Context, repo *Repository, master *crypto.Key, password string, keyfile []byte, printer progress.Printer) error {
+	oldID := repo.KeyID()
+	key, err := AddKey(ctx, repo, password, "", "", master, repo.KeyCapabilities(), keyfile)
+	if err != nil {
+		return fmt.Errorf("creating new key failed: %w", err)
+	}
+
+	// verify the new key to make sure it really works, a broken key could
+	// render the whole repository inaccessible
+	id := key.ID()
+	err = repo.SearchKey(ctx, password, 0, id.String())
+	if err != nil {
+		_ = repo.be.Remove(ctx, backend.Handle{Type: restic.KeyFile, Name: id.String()})
+		return fmt.Errorf("failed to access repository with new key: %w", err)
+	}
+	printer.P("saved new key as %v\n", id.Str())
+
+	return RemoveKey(ctx, repo, oldID)
+}
+
+// loadKeyRotation returns the progress of the current key rotation. If no
+// progress was stored yet for the current master key, all existing packs are
+// considered to require re-encryption.
+func loadKeyRotation(ctx context.Context, repo *Repository) (*keyRotation, error) {
+	var rotation keyRotation
+	fingerprint := keyFingerprint(repo.Key())
+	err := restic.LoadState(ctx, repo, keyRotationKind, &rotation)
+	if err == nil && rotation.Key == fingerprint {
+		debug.Log("loaded key rotation state with %d packs", len(rotation.Packs))
+		return &rotation, nil
+	}
+	if err == nil {
+		debug.Log("discarding key rotation state of another master key")
+	} else if err != restic.ErrNoState {
+		return nil, err
+	}
+
+	rotation = keyRotation{Started: time.Now(), Key: fingerprint}
+	err = repo.List(ctx, restic.PackFile, func(id restic.ID, _ int64) error {
+		rotation.Packs = append(rotation.Packs, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := saveKeyRotation(ctx, repo, &rotation); err != nil {
+		return nil, err
+	}
+	return &rotation, nil
+}
+
+func saveKeyRotation(ctx context.Context, repo restic.StateSaver, rotation *keyRotation) error {
+	_, err := restic.SaveState(ctx, repo, keyRotationKind, rotation)
+	if err != nil {
+		return fmt.Errorf("saving key rotation state failed: %w", err)
+	}
+	return nil
+}
+
+// reencryptPacks repacks all packs listed in rotation which still exist. This
+// stores their blobs encrypted using the current master key. Packs which are
+// not referenced by the index are removed. It returns whether all packs were
+// processed before the deadline was reached.
+func reencryptPacks(ctx context.Context, repo *Repository, rotation *keyRotation, deadline time.Time, printer progress.Printer) (bool, error) {
+	if len(rotation.Packs) == 0 {
+		return true, nil
+	}
+
+	existing := restic.NewIDSet()
+	err := repo.List(ctx, restic.PackFile, func(id restic.ID, _ int64) error {
+		existing.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	remaining := restic.NewIDSet()
+	for _, id := range rotation.Packs {
+		if existing.Has(id) {
+			remaining.Insert(id)
+		}
+	}
+
+	printer.P("loading index files\n")
+	bar := printer.NewCounter("index files loaded")
+	err = repo.LoadIndex(ctx, bar)
+	bar.Done()
+	if err != nil {
+		return false, err
+	}
+
+	indexed := restic.NewIDSet()
+	keepBlobs := restic.NewBlobSet()
+	for pbs := range repo.ListPacksFromIndex(ctx, remaining) {
+		indexed.Insert(pbs.PackID)
+		for _, blob := range pbs.Blobs {
+			keepBlobs.Insert(blob.BlobHandle)
+		}
+	}
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	unreferenced := remaining.Sub(indexed)
+	if len(unreferenced) != 0 {
+		printer.P("deleting %d unreferenced packs\n", len(unreferenced))
+		_ = deleteFiles(ctx, true, repo, unreferenced, restic.PackFile, printer)
+	}
+
+	repacked := restic.NewIDSet()
+	if len(indexed) != 0 {
+		printer.P("re-encrypting %d packs\n", len(indexed))
+		bar := printer.NewCounter("packs re-encrypted")
+		bar.SetMax(uint64(len(indexed)))
+		repacked, err = repackUntil(ctx, repo, repo, indexed, keepBlobs, bar, deadline)
+		bar.Done()
+		if err != nil {
+			return false, err
+		}
+
+		if len(repacked) == len(indexed) && keepBlobs.Len() != 0 {
+			return false, errors.Fatalf("internal error: blobs %v were not re-encrypted", keepBlobs)
+		}
+
+		if len(repacked) != 0 {
+			if err := rewriteIndexFiles(ctx, repo, repacked, nil, nil, nil, printer); err != nil {
+				return false, err
+			}
+			printer.P("removing %d old packs\n", len(repacked))
+			_ = deleteFiles(ctx, true, repo, repacked, restic.PackFile, printer)
+		}
+	}
+	// drop outdated in-memory index
+	repo.clearIndex()
+
+	rotation.Packs = indexed.Sub(repacked).List()
+	if len(rotation.Packs) != 0 {
+		if err := saveKeyRotation(ctx, repo, rotation); err != nil {
+			return false, err
+		}
+		printer.P("reached the maximum duration, %d packs were not re-encrypted, run the command again to continue\n", len(rotation.Packs))
+		return false, nil
+	}
+	return true, nil
+}
+
+// packsUsingPreviousKey returns the pack files whose header cannot be
+// decrypted using the current master key alone.
+func packsUsingPreviousKey(ctx context.Context, repo *Repository) (restic.IDs, error) {
+	key := repo.Key().Current()
+	var m sync.Mutex
+	var ids restic.IDs
+	err := restic.ParallelList(ctx, repo, restic.PackFile, repo.Connections(), func(ctx context.Context, id restic.ID, size int64) error {
+		h := backend.Handle{Type: restic.PackFile, Name: id.String()}
+		_, _, err := pack.List(key, backend.ReaderAt(ctx, repo.be, h), size)
+		if errors.Is(err, crypto.ErrUnauthenticated) {
+			m.Lock()
+			ids = append(ids, id)
+			m.Unlock()
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("pack %v: %w", id.Str(), err)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// finishKeyRotation re-encrypts all remaining files and afterwards stores the
+// master key without the previous key.
+func finishKeyRotation(ctx context.Context, repo *Repository, rotation *keyRotation, password string, keyfile []byte, printer progress.Printer) error {
+	printer.P("re-encrypting snapshots\n")
+	if err := reencryptSnapshots(ctx, repo, rotation, printer); err != nil {
+		return err
+	}
+
+	printer.P("re-encrypting index and state files\n")
+	renamed, err := reencryptUnpacked(ctx, repo, restic.IndexFile)
+	if err != nil {
+		return err
+	}
+	if len(renamed) != 0 {
+		if err := SaveIndexManifest(ctx, repo); err != nil {
+			return err
+		}
+	}
+	if _, err := reencryptUnpacked(ctx, repo, restic.StateFile); err != nil {
+		return err
+	}
+	if err := reencryptConfig(ctx, repo); err != nil {
+		return err
+	}
+
+	// all data is now accessible using the new master key
+	if err := replaceKey(ctx, repo, repo.Key().Current(), password, keyfile, printer); err != nil {
+		return err
+	}
+	return restic.RemoveState(ctx, repo, keyRotationKind)
+}
+
+// usesCurrentKey returns whether buf was encrypted using the current master
+// key instead of the previous one.
+func (r *Repository) usesCurrentKey(buf []byte) bool {
+	key := r.key.Current()
+	if len(buf) < key.NonceSize()+key.Overhead() {
+		return false
+	}
+	nonce, ciphertext := buf[:key.NonceSize()], buf[key.NonceSize():]
+	_, err := key.Open(nil, nonce, ciphertext, nil)
+	return err == nil
+}
+
+// reencryptUnpacked saves all files of type t which are encrypted using the
+// previous master key again. As the ID of a file depends on its content, a
+// map from the old to the new IDs is returned.
+func reencryptUnpacked(ctx context.Context, repo *Repository, t restic.FileType) (map[restic.ID]restic.ID, error) {
+	var ids restic.IDs
+	err := repo.List(ctx, t, func(id restic.ID, _ int64) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := make(map[restic.ID]restic.ID)
+	for _, id := range ids {
+		buf, err := repo.LoadRaw(ctx, t, id)
+		if err != nil {
+			return nil, err
+		}
+		if repo.usesCurrentKey(buf) {
+			continue
+		}
+
+		plaintext, err := repo.LoadUnpacked(ctx, t, id)
+		if err != nil {
+			return nil, err
+		}
+		var prefix []byte
+		if t == restic.StateFile {
+			// keep the part of the ID which identifies the kind of the state
+			prefix = id[:restic.StateKindSize]
+		}
+		newID, err := repo.SaveUnpackedWithPrefix(ctx, t, prefix, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		if err := repo.RemoveUnpacked(ctx, t, id); err != nil {
+			return nil, err
+		}
+		debug.Log("re-encrypted %v %v as %v", t, id.Str(), newID.Str())
+		renamed[id] = newID
+	}
+	return renamed, nil
+}
+
+type reencryptSnapshot struct {
+	id         restic.ID
+	sn         restic.Snapshot
+	plaintext  []byte
+	currentKey bool
+}
+
+// reencryptSnapshots saves all snapshots which are encrypted using the previous
+// master key again. As the ID of a snapshot depends on its content, snapshots
+// which supersede a re-encrypted snapshot are saved again as well. The mapping
+// from the old to the new IDs is stored in the rotation state before the old
+// snapshots are removed, such that an interrupted run can be continued.
+func reencryptSnapshots(ctx context.Context, repo *Repository, rotation *keyRotation, printer progress.Printer) error {
+	renamed := make(map[restic.ID]restic.ID)
+	for oldID, newID := range rotation.Snapshots {
+		id, err := restic.ParseID(oldID)
+		if err != nil {
+			return fmt.Errorf("invalid key rotation state: %w", err)
+		}
+		renamed[id] = newID
+	}
+
+	existing := restic.NewIDSet()
+	var snapshots []reencryptSnapshot
+	err := repo.List(ctx, restic.SnapshotFile, func(id restic.ID, _ int64) error {
+		existing.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for id := range existing {
+		if _, ok := renamed[id]; ok {
+			// already saved again by an interrupted run
+			continue
+		}
+		buf, err := repo.LoadRaw(ctx, restic.SnapshotFile, id)
+		if err != nil {
+			return err
+		}
+		plaintext, err := repo.LoadUnpacked(ctx, restic.SnapshotFile, id)
+		if err != nil {
+			return err
+		}
+		s := reencryptSnapshot{id: id, plaintext: plaintext, currentKey: repo.usesCurrentKey(buf)}
+		if err := json.Unmarshal(plaintext, &s.sn); err != nil {
+			return fmt.Errorf("snapshot %v: %w", id.Str(), err)
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	// superseded snapshots are older, thus their new ID is known in time
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].sn.Time.Before(snapshots[j].sn.Time)
+	})
+	for _, s := range snapshots {
+		changed := false
+		for i, id := range s.sn.Supersedes {
+			if newID, ok := renamed[id]; ok {
+				s.sn.Supersedes[i] = newID
+				changed = true
+			}
+		}
+		if s.currentKey && !changed {
+			continue
+		}
+
+		buf := s.plaintext
+		if changed {
+			buf, err = json.Marshal(&s.sn)
+			if err != nil {
+				return errors.Wrap(err, "json.Marshal")
+			}
+		}
+		newID, err := repo.SaveUnpacked(ctx, restic.SnapshotFile, buf)
+		if err != nil {
+			return err
+		}
+		renamed[s.id] = newID
+		printer.V("snapshot %v is now %v\n", s.id.Str(), newID.Str())
+	}
+
+	rotation.Snapshots = make(map[string]restic.ID, len(renamed))
+	for oldID, newID := range renamed {
+		rotation.Snapshots[oldID.String()] = newID
+	}
+	if err := saveKeyRotation(ctx, repo, rotation); err != nil {
+		return err
+	}
+	if err := renameSnapshotReferences(ctx, repo, renamed); err != nil {
+		return err
+	}
+
+	for id := range renamed {
+		if !existing.Has(id) {
+			continue
+		}
+		if err := repo.RemoveUnpacked(ctx, restic.SnapshotFile, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameSnapshotReferences updates the snapshot aliases and the replication
+// state to refer to the new IDs of the re-encrypted snapshots.
+func renameSnapshotReferences(ctx context.Context, repo *Repository, renamed map[restic.ID]restic.ID) error {
+	aliases, err := restic.LoadSnapshotAliases(ctx, repo)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for name, id := range aliases {
+		if newID, ok := renamed[id]; ok {
+			aliases[name] = newID
+			changed = true
+		}
+	}
+	if changed {
+		if err := restic.SaveSnapshotAliases(ctx, repo, aliases); err != nil {
+			return err
+		}
+	}
+
+	replication, err := restic.LoadReplicationState(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if replication.RenameReplicas(renamed) {
+		return restic.SaveReplicationState(ctx, repo, replication)
+	}
+	return nil
+}
+
+// reencryptConfig saves the config again if it is encrypted using the
+// previous master key.
+func reencryptConfig(ctx context.Context, repo *Repository) error {
+	buf, err := repo.LoadRaw(ctx, restic.ConfigFile, restic.ID{})
+	if err != nil {
+		return err
+	}
+	if repo.usesCurrentKey(buf) {
+		return nil
+	}
+	return replaceConfig(ctx, repo, repo.Config())
+}
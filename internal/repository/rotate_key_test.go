@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestRotateMasterKey(t *testing.T) {
+	repo, be := TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	var wg errgroup.Group
+	repo.StartPackUploader(ctx, &wg)
+	data := map[restic.ID][]byte{}
+	for i := 0; i < 5; i++ {
+		buf := rtest.Random(i, 1000)
+		id, _, _, err := repo.SaveBlob(ctx, restic.DataBlob, buf, restic.ID{}, false)
+		rtest.OK(t, err)
+		data[id] = buf
+		rtest.OK(t, repo.Flush(ctx))
+		repo.StartPackUploader(ctx, &wg)
+	}
+	rtest.OK(t, repo.Flush(ctx))
+
+	sn, err := restic.NewSnapshot([]string{"/foo"}, nil, "host", time.Now())
+	rtest.OK(t, err)
+	snID, err := restic.SaveSnapshot(ctx, repo, sn)
+	rtest.OK(t, err)
+	rtest.OK(t, restic.SaveSnapshotAliases(ctx, repo, restic.SnapshotAliases{"foo": snID}))
+
+	oldPacks := listFiles(t, repo, restic.PackFile)
+	oldKey := repo.Key()
+
+	// another key must only be removed if explicitly requested
+	_, err = AddKey(ctx, repo, "other", "", "", repo.Key(), nil, nil)
+	rtest.OK(t, err)
+	opts := RotateMasterKeyOptions{Password: rtest.TestPassword, Deadline: time.Now()}
+	_, err = RotateMasterKey(ctx, repo, opts, &progress.NoopPrinter{})
+	rtest.Assert(t, err == ErrOtherKeysExist, "unexpected error %v", err)
+	rtest.Assert(t, !repo.KeyRotationInProgress(), "key rotation must not be started")
+
+	// a rotation which reaches the deadline is continued later on
+	opts.RemoveOtherKeys = true
+	complete, err := RotateMasterKey(ctx, repo, opts, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, !complete, "key rotation should not be complete")
+	rtest.Equals(t, 1, len(listFiles(t, repo, restic.KeyFile)))
+
+	repo = TestOpenBackend(t, be)
+	rtest.Assert(t, repo.KeyRotationInProgress(), "key rotation should be in progress")
+	rtest.Equals(t, *oldKey.Current(), *repo.Key().Previous)
+
+	opts.Deadline = time.Time{}
+	complete, err = RotateMasterKey(ctx, repo, opts, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, complete, "key rotation should be complete")
+
+	repo = TestOpenBackend(t, be)
+	rtest.Assert(t, !repo.KeyRotationInProgress(), "key rotation should be complete")
+	rtest.Assert(t, repo.Key().MACKey != oldKey.MACKey, "master key was not replaced")
+
+	// all files are readable using only the new master key
+	for _, tpe := range []restic.FileType{restic.IndexFile, restic.SnapshotFile, restic.StateFile} {
+		for id := range listFiles(t, repo, tpe) {
+			buf, err := repo.LoadRaw(ctx, tpe, id)
+			rtest.OK(t, err)
+			rtest.Assert(t, repo.usesCurrentKey(buf), "%v %v uses the previous key", tpe, id.Str())
+		}
+	}
+	rtest.Equals(t, 0, len(listFiles(t, repo, restic.PackFile).Intersect(oldPacks)))
+
+	rtest.OK(t, repo.LoadIndex(ctx, nil))
+	for id, buf := range data {
+		loaded, err := repo.LoadBlob(ctx, restic.DataBlob, id, nil)
+		rtest.OK(t, err)
+		rtest.Equals(t, buf, loaded)
+	}
+
+	// the snapshot and its alias refer to the re-encrypted snapshot
+	snapshots := listFiles(t, repo, restic.SnapshotFile)
+	rtest.Equals(t, 1, len(snapshots))
+	rtest.Assert(t, !snapshots.Has(snID), "snapshot was not re-encrypted")
+	aliases, err := restic.LoadSnapshotAliases(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, snapshots.Has(aliases["foo"]), "alias refers to unknown snapshot %v", aliases["foo"])
+}
+
+func TestRotateMasterKeyStaleState(t *testing.T) {
+	repo, be := TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	var wg errgroup.Group
+	repo.StartPackUploader(ctx, &wg)
+	_, _, _, err := repo.SaveBlob(ctx, restic.DataBlob, rtest.Random(23, 1000), restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(ctx))
+	oldPacks := listFiles(t, repo, restic.PackFile)
+
+	opts := RotateMasterKeyOptions{Password: rtest.TestPassword, Deadline: time.Now()}
+	complete, err := RotateMasterKey(ctx, repo, opts, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, !complete, "key rotation should not be complete")
+
+	// a state which belongs to another master key must not be used
+	rtest.OK(t, saveKeyRotation(ctx, repo, &keyRotation{Started: time.Now(), Key: restic.NewRandomID()}))
+
+	repo = TestOpenBackend(t, be)
+	opts.Deadline = time.Time{}
+	complete, err = RotateMasterKey(ctx, repo, opts, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, complete, "key rotation should be complete")
+	rtest.Equals(t, 0, len(listFiles(t, repo, restic.PackFile).Intersect(oldPacks)))
+}
+
+func TestRotateMasterKeySnapshotReferences(t *testing.T) {
+	repo, be := TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	base, err := restic.NewSnapshot([]string{"/foo"}, nil, "host", time.Now().Add(-time.Hour))
+	rtest.OK(t, err)
+	baseID, err := restic.SaveSnapshot(ctx, repo, base)
+	rtest.OK(t, err)
+	sn, err := restic.NewSnapshot([]string{"/foo"}, nil, "host", time.Now())
+	rtest.OK(t, err)
+	sn.Supersedes = restic.IDs{baseID}
+	snID, err := restic.SaveSnapshot(ctx, repo, sn)
+	rtest.OK(t, err)
+	rtest.OK(t, restic.SaveReplicationState(ctx, repo, &restic.ReplicationState{
+		Source:    "source",
+		Snapshots: []restic.ReplicatedSnapshot{{Source: restic.NewRandomID(), Replica: snID}},
+	}))
+
+	opts := RotateMasterKeyOptions{Password: rtest.TestPassword}
+	complete, err := RotateMasterKey(ctx, repo, opts, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Assert(t, complete, "key rotation should be complete")
+
+	repo = TestOpenBackend(t, be)
+	snapshots := listFiles(t, repo, restic.SnapshotFile)
+	rtest.Equals(t, 2, len(snapshots))
+	rtest.Assert(t, !snapshots.Has(baseID) && !snapshots.Has(snID), "snapshots were not re-encrypted")
+
+	var newBaseID, newID restic.ID
+	for id := range snapshots {
+		loaded, err := restic.LoadSnapshot(ctx, repo, id)
+		rtest.OK(t, err)
+		if len(loaded.Supersedes) == 0 {
+			newBaseID = id
+		} else {
+			newID = id
+			rtest.Equals(t, 1, len(loaded.Supersedes))
+			rtest.Assert(t, snapshots.Has(loaded.Supersedes[0]), "superseded snapshot %v does not exist", loaded.Supersedes[0].Str())
+		}
+	}
+	rtest.Assert(t, !newBaseID.IsNull() && !newID.IsNull(), "snapshot missing")
+
+	replication, err := restic.LoadReplicationState(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, newID, replication.Snapshots[0].Replica)
+}
+
+func TestRotateMasterKeyUnsupported(t *testing.T) {
+	repo, _ := TestRepositoryWithVersion(t, 2)
+	_, err := RotateMasterKey(context.TODO(), repo, RotateMasterKeyOptions{Password: rtest.TestPassword}, &progress.NoopPrinter{})
+	rtest.Assert(t, errors.Is(err, restic.ErrStateUnsupported), "unexpected error %v", err)
+}
+
+func listFiles(t *testing.T, repo *Repository, tpe restic.FileType) restic.IDSet {
+	ids := restic.NewIDSet()
+	rtest.OK(t, repo.List(context.TODO(), tpe, func(id restic.ID, _ int64) error {
+		ids.Insert(id)
+		return nil
+	}))
+	return ids
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// snapshotCache is the content of the snapshot cache file in the local cache.
+// The file is encrypted using the master key of the repository.
+type snapshotCache struct {
+	Snapshots map[string]json.RawMessage `json:"snapshots"`
+}
+
+var _ restic.SnapshotCache = &Repository{}
+
+// LoadSnapshotCache returns the plaintext of all snapshot files stored in the
+// snapshot cache. It returns an empty map if the repository has no cache or
+// the snapshot cache does not exist yet.
+func (r *Repository) LoadSnapshotCache() (map[restic.ID][]byte, error) {
+	snapshots := make(map[restic.ID][]byte)
+	if r.Cache == nil {
+		return snapshots, nil
+	}
+
+	buf, err := os.ReadFile(r.Cache.SnapshotCacheFilename())
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshots, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if len(buf) < r.key.NonceSize()+r.key.Overhead() {
+		return nil, errors.New("snapshot cache is truncated")
+	}
+	nonce, ciphertext := buf[:r.key.NonceSize()], buf[r.key.NonceSize():]
+	plaintext, err := r.key.Open(ciphertext[:0], nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting snapshot cache failed: %w", err)
+	}
+	plaintext, err = r.decompressUnpacked(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	var sc snapshotCache
+	if err := json.Unmarshal(plaintext, &sc); err != nil {
+		return nil, fmt.Errorf("decoding snapshot cache failed: %w", err)
+	}
+	for s, data := range sc.Snapshots {
+		id, err := restic.ParseID(s)
+		if err != nil {
+			return nil, fmt.Errorf("decoding snapshot cache failed: %w", err)
+		}
+		snapshots[id] = data
+	}
+	debug.Log("loaded %d snapshots from the snapshot cache", len(snapshots))
+	return snapshots, nil
+}
+
+// SaveSnapshotCache replaces the content of the snapshot cache. It does
+// nothing if the repository has no cache.
+func (r *Repository) SaveSnapshotCache(snapshots map[restic.ID][]byte) (err error) {
+	if r.Cache == nil {
+		return nil
+	}
+
+	sc := snapshotCache{Snapshots: make(map[string]json.RawMessage, len(snapshots))}
+	for id, data := range snapshots {
+		sc.Snapshots[id.String()] = data
+	}
+	plaintext, err := json.Marshal(sc)
+	if err != nil {
+		return err
+	}
+	plaintext, err = r.compressUnpacked(plaintext)
+	if err != nil {
+		return err
+	}
+
+	nonce := crypto.NewRandomNonce()
+	ciphertext := make([]byte, 0, crypto.CiphertextLength(len(plaintext)))
+	ciphertext = append(ciphertext, nonce...)
+	ciphertext = r.key.Seal(ciphertext, nonce, plaintext, nil)
+
+	// write to a temporary file first, such that concurrent restic processes
+	// never see a partially written cache
+	filename := r.Cache.SnapshotCacheFilename()
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+"-*.tmp")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(ciphertext); err != nil {
+		return errors.WithStack(err)
+	}
+	if err = f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err = os.Rename(f.Name(), filename); err != nil {
+		return errors.WithStack(err)
+	}
+	debug.Log("saved %d snapshots to the snapshot cache", len(snapshots))
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend/cache"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func listSnapshots(t *testing.T, repo restic.ListerLoaderUnpacked) map[restic.ID]*restic.Snapshot {
+	snapshots := make(map[restic.ID]*restic.Snapshot)
+	rtest.OK(t, restic.ForAllSnapshots(context.TODO(), repo, repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
+		rtest.OK(t, err)
+		snapshots[id] = sn
+		return nil
+	}))
+	return snapshots
+}
+
+func TestSnapshotCache(t *testing.T) {
+	repository.TestAllVersions(t, testSnapshotCache)
+}
+
+func testSnapshotCache(t *testing.T, version uint) {
+	repo, _ := repository.TestRepositoryWithVersion(t, version)
+	c := cache.TestNewCache(t)
+	repo.UseCache(c)
+
+	var ids restic.IDs
+	for i := 0; i < 3; i++ {
+		tree := restic.NewRandomID()
+		sn := &restic.Snapshot{Time: time.Unix(int64(i), 0), Tree: &tree, Hostname: "foo"}
+		id, err := restic.SaveSnapshot(context.TODO(), repo, sn)
+		rtest.OK(t, err)
+		ids = append(ids, id)
+	}
+
+	_, err := os.Stat(c.SnapshotCacheFilename())
+	rtest.Assert(t, os.IsNotExist(err), "snapshot cache exists before listing snapshots")
+	rtest.Equals(t, 3, len(listSnapshots(t, repo)))
+	cached, err := repo.LoadSnapshotCache()
+	rtest.OK(t, err)
+	rtest.Equals(t, 3, len(cached))
+
+	// the cache must not contain the snapshots in plaintext
+	buf, err := os.ReadFile(c.SnapshotCacheFilename())
+	rtest.OK(t, err)
+	rtest.Assert(t, !json.Valid(buf), "snapshot cache is not encrypted")
+
+	// snapshots are read from the cache
+	var sn restic.Snapshot
+	rtest.OK(t, json.Unmarshal(cached[ids[0]], &sn))
+	sn.Hostname = "cached"
+	cached[ids[0]], err = json.Marshal(sn)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.SaveSnapshotCache(cached))
+	snapshots := listSnapshots(t, repo)
+	rtest.Equals(t, "cached", snapshots[ids[0]].Hostname)
+	rtest.Equals(t, ids[0], *snapshots[ids[0]].ID())
+
+	// removed snapshots are dropped from the cache
+	rtest.OK(t, repo.RemoveUnpacked(context.TODO(), restic.SnapshotFile, ids[1]))
+	rtest.Equals(t, 2, len(listSnapshots(t, repo)))
+	cached, err = repo.LoadSnapshotCache()
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(cached))
+	_, ok := cached[ids[1]]
+	rtest.Assert(t, !ok, "removed snapshot still cached")
+
+	// a damaged cache is ignored and replaced
+	rtest.OK(t, os.WriteFile(c.SnapshotCacheFilename(), []byte("invalid"), 0600))
+	rtest.Equals(t, 2, len(listSnapshots(t, repo)))
+	cached, err = repo.LoadSnapshotCache()
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(cached))
+}
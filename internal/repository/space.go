@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/repository/pack"
+)
+
+// Space describes the space available for a repository.
+type Space struct {
+	// Used is the size of the pack files referenced by the index.
+	Used uint64
+	// Quota is the quota configured for the backend, zero if there is none.
+	Quota uint64
+	// Remaining is the space which can still be used by the repository,
+	// limited by the quota and the free space of the storage.
+	Remaining uint64
+}
+
+// Space returns the space available for the repository. If the backend
+// neither has a quota configured nor reports the free space of its storage,
+// ok is false. The index must be loaded.
+func (r *Repository) Space(ctx context.Context) (space Space, ok bool, err error) {
+	be := backend.AsBackend[backend.SpaceBackend](r.be)
+	if be == nil {
+		return Space{}, false, nil
+	}
+	info, err := be.Space(ctx)
+	if err != nil {
+		return Space{}, false, err
+	}
+	if info.Quota == 0 && !info.FreeKnown {
+		return Space{}, false, nil
+	}
+
+	packSize, err := pack.Size(ctx, r, false)
+	if err != nil {
+		return Space{}, false, err
+	}
+	var used uint64
+	for _, size := range packSize {
+		used += uint64(size)
+	}
+
+	remaining, _ := remainingSpace(info, used)
+	return Space{Used: used, Quota: info.Quota, Remaining: remaining}, true, nil
+}
+
+// remainingSpace returns the space which can still be used by a repository of
+// size used, ok is false if neither a quota nor the free space is known.
+func remainingSpace(info backend.SpaceInfo, used uint64) (remaining uint64, ok bool) {
+	if info.Quota > 0 {
+		if used < info.Quota {
+			remaining = info.Quota - used
+		}
+		ok = true
+	}
+	if info.FreeKnown && (!ok || info.Free < remaining) {
+		remaining = info.Free
+		ok = true
+	}
+	return remaining, ok
+}
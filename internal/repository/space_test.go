@@ -0,0 +1,71 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+type spaceBackend struct {
+	backend.Backend
+	info backend.SpaceInfo
+}
+
+func (be *spaceBackend) Space(_ context.Context) (backend.SpaceInfo, error) {
+	return be.info, nil
+}
+
+func TestRepositorySpace(t *testing.T) {
+	be := &spaceBackend{Backend: mem.New()}
+	repo, _ := repository.TestRepositoryWithBackend(t, be, 0, repository.Options{})
+
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	_, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, rtest.Random(23, 1000), restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.Background()))
+
+	_, ok, err := repo.Space(context.TODO())
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "space reported without quota and free space")
+
+	for _, test := range []struct {
+		info      backend.SpaceInfo
+		remaining func(used uint64) uint64
+	}{
+		{
+			info:      backend.SpaceInfo{Quota: 1 << 20},
+			remaining: func(used uint64) uint64 { return 1<<20 - used },
+		},
+		{
+			info:      backend.SpaceInfo{Quota: 10},
+			remaining: func(_ uint64) uint64 { return 0 },
+		},
+		{
+			info:      backend.SpaceInfo{Free: 500, FreeKnown: true},
+			remaining: func(_ uint64) uint64 { return 500 },
+		},
+		{
+			info:      backend.SpaceInfo{Quota: 1 << 20, Free: 500, FreeKnown: true},
+			remaining: func(_ uint64) uint64 { return 500 },
+		},
+		{
+			info:      backend.SpaceInfo{Quota: 1 << 20, Free: 1 << 30, FreeKnown: true},
+			remaining: func(used uint64) uint64 { return 1<<20 - used },
+		},
+	} {
+		be.info = test.info
+		space, ok, err := repo.Space(context.TODO())
+		rtest.OK(t, err)
+		rtest.Assert(t, ok, "no space reported for %v", test.info)
+		rtest.Assert(t, space.Used > 1000, "unexpected used space %v", space.Used)
+		rtest.Equals(t, test.info.Quota, space.Quota)
+		rtest.Equals(t, test.remaining(space.Used), space.Remaining)
+	}
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/restic"
+)
+
+const statsHistoryKind = "stats-history"
+
+// maxStatsHistoryEntries limits the size of the stats history, older entries
+// are dropped by prune.
+const maxStatsHistoryEntries = 1000
+
+// StatsHistoryEntry contains the statistics of the repository at the end of a
+// backup or prune run.
+type StatsHistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+
+	// statistics of the repository index
+	PackCount        uint    `json:"pack_count"`
+	BlobCount        uint    `json:"blob_count"`
+	TotalSize        uint64  `json:"total_size"`
+	UncompressedSize uint64  `json:"total_uncompressed_size"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+
+	// statistics of a backup run. DataAdded is the size of the new file data
+	// before compression, DedupRatio is BytesProcessed divided by DataAdded.
+	SnapshotID     *restic.ID `json:"snapshot_id,omitempty"`
+	BytesProcessed uint64     `json:"bytes_processed,omitempty"`
+	DataAdded      uint64     `json:"data_added,omitempty"`
+	DedupRatio     float64    `json:"dedup_ratio,omitempty"`
+}
+
+// RecordStats adds the current statistics of the repository index to entry
+// and appends it to the stats history. The index must be loaded. It does
+// nothing unless the stats-history feature flag is enabled and the repository
+// supports state files. Each entry is stored in a separate state file, thus
+// concurrent backups holding a non-exclusive lock can record their statistics.
+func RecordStats(ctx context.Context, repo restic.Repository, entry StatsHistoryEntry) error {
+	return recordStats(ctx, repo, entry, nil)
+}
+
+// recordStats is like RecordStats, but ignores the blobs stored in ignorePacks.
+// This allows recording the statistics while the in-memory index still
+// contains packs which were already removed.
+func recordStats(ctx context.Context, repo restic.Repository, entry StatsHistoryEntry, ignorePacks restic.IDSet) error {
+	if !statsHistoryEnabled(repo) {
+		return nil
+	}
+	packs := restic.NewIDSet()
+	err := repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		if ignorePacks.Has(pb.PackID) {
+			return
+		}
+		packs.Insert(pb.PackID)
+		entry.BlobCount++
+		entry.TotalSize += uint64(pb.Length)
+		entry.UncompressedSize += uint64(pb.DataLength())
+	})
+	if err != nil {
+		return err
+	}
+	entry.PackCount = uint(len(packs))
+	if entry.TotalSize > 0 {
+		entry.CompressionRatio = float64(entry.UncompressedSize) / float64(entry.TotalSize)
+	}
+	if entry.DataAdded > 0 {
+		entry.DedupRatio = float64(entry.BytesProcessed) / float64(entry.DataAdded)
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	id, err := restic.AppendState(ctx, repo, statsHistoryKind, entry)
+	if err != nil {
+		return fmt.Errorf("saving stats history failed: %w", err)
+	}
+	debug.Log("saved stats history entry %v", id.Str())
+	return nil
+}
+
+func statsHistoryEnabled(repo restic.Repository) bool {
+	if !feature.Flag.Enabled(feature.StatsHistory) {
+		return false
+	}
+	if repo.Config().Version < restic.FeaturesRepoVersion {
+		debug.Log("repository does not support state files, not recording stats")
+		return false
+	}
+	return true
+}
+
+// trimStatsHistory removes the oldest entries of the stats history exceeding
+// maxStatsHistoryEntries. It requires an exclusive lock.
+func trimStatsHistory(ctx context.Context, repo restic.StateSaver) error {
+	return restic.TrimStateLog(ctx, repo, statsHistoryKind, maxStatsHistoryEntries)
+}
+
+// LoadStatsHistory returns the recorded statistics, sorted from oldest to
+// newest. If no statistics were recorded yet, an empty list is returned.
+func LoadStatsHistory(ctx context.Context, repo restic.StateLoader) ([]StatsHistoryEntry, error) {
+	var history []StatsHistoryEntry
+	err := restic.LoadStateLog(ctx, repo, statsHistoryKind, func(data json.RawMessage) error {
+		var entry StatsHistoryEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		history = append(history, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(history) > maxStatsHistoryEntries {
+		history = history[len(history)-maxStatsHistoryEntries:]
+	}
+	return history, nil
+}
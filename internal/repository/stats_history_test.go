@@ -0,0 +1,70 @@
+package repository_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestStatsHistory(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+	createRandomBlobs(t, rand.New(rand.NewSource(42)), repo, 5, 0.5, true)
+
+	// nothing is recorded unless the feature flag is enabled
+	rtest.OK(t, repository.RecordStats(ctx, repo, repository.StatsHistoryEntry{Command: "backup"}))
+	history, err := repository.LoadStatsHistory(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(history))
+
+	defer feature.TestSetFlag(t, feature.Flag, feature.StatsHistory, true)()
+
+	var blobs, packs uint
+	var size, uncompressed uint64
+	packIDs := restic.NewIDSet()
+	rtest.OK(t, repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		blobs++
+		size += uint64(pb.Length)
+		uncompressed += uint64(pb.DataLength())
+		packIDs.Insert(pb.PackID)
+	}))
+	packs = uint(len(packIDs))
+
+	id := restic.NewRandomID()
+	rtest.OK(t, repository.RecordStats(ctx, repo, repository.StatsHistoryEntry{
+		Command:        "backup",
+		SnapshotID:     &id,
+		BytesProcessed: 1000,
+		DataAdded:      250,
+	}))
+	rtest.OK(t, repository.RecordStats(ctx, repo, repository.StatsHistoryEntry{Command: "prune"}))
+
+	history, err = repository.LoadStatsHistory(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(history))
+	rtest.Equals(t, "backup", history[0].Command)
+	rtest.Equals(t, id, *history[0].SnapshotID)
+	rtest.Equals(t, 4.0, history[0].DedupRatio)
+	rtest.Equals(t, "prune", history[1].Command)
+	for _, entry := range history {
+		rtest.Equals(t, blobs, entry.BlobCount)
+		rtest.Equals(t, packs, entry.PackCount)
+		rtest.Equals(t, size, entry.TotalSize)
+		rtest.Equals(t, uncompressed, entry.UncompressedSize)
+		rtest.Assert(t, !entry.Time.IsZero(), "missing time")
+	}
+
+	// each entry is stored in a separate state file, such that concurrent
+	// backups don't overwrite each other's entries
+	states := 0
+	rtest.OK(t, repo.List(ctx, restic.StateFile, func(restic.ID, int64) error {
+		states++
+		return nil
+	}))
+	rtest.Equals(t, 2, states)
+}
@@ -18,14 +18,14 @@ import (
 	"github.com/restic/chunker"
 )
 
-type logger interface {
+type testLogger interface {
 	Logf(format string, args ...interface{})
 }
 
 var paramsOnce sync.Once
 
 // TestUseLowSecurityKDFParameters configures low-security KDF parameters for testing.
-func TestUseLowSecurityKDFParameters(t logger) {
+func TestUseLowSecurityKDFParameters(t testLogger) {
 	t.Logf("using low-security KDF parameters for test")
 	paramsOnce.Do(func() {
 		params = &crypto.Params{
@@ -64,7 +64,7 @@ func TestRepositoryWithBackend(t testing.TB, be backend.Backend, version uint, o
 		version = restic.StableRepoVersion
 	}
 	pol := testChunkerPol
-	err = repo.Init(context.TODO(), version, test.TestPassword, &pol)
+	err = repo.Init(context.TODO(), version, test.TestPassword, &pol, nil)
 	if err != nil {
 		t.Fatalf("TestRepository(): initialize repo failed: %v", err)
 	}
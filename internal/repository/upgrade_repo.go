@@ -30,7 +30,7 @@ func (err *upgradeRepoV2Error) Unwrap() error {
 	return err.UploadNewConfigError
 }
 
-func upgradeRepository(ctx context.Context, repo *Repository) error {
+func upgradeRepository(ctx context.Context, repo *Repository, version uint) error {
 	h := backend.Handle{Type: backend.ConfigFile}
 
 	if !repo.be.HasAtomicReplace() {
@@ -43,7 +43,7 @@ func upgradeRepository(ctx context.Context, repo *Repository) error {
 
 	// upgrade config
 	cfg := repo.Config()
-	cfg.Version = 2
+	cfg.Version = version
 
 	err := restic.SaveConfig(ctx, repo, cfg)
 	if err != nil {
@@ -57,8 +57,19 @@ func UpgradeRepo(ctx context.Context, repo *Repository) error {
 	if repo.Config().Version != 1 {
 		return fmt.Errorf("repository has version %v, only upgrades from version 1 are supported", repo.Config().Version)
 	}
+	return upgradeRepo(ctx, repo, 2)
+}
+
+// UpgradeRepoV3 upgrades a repository from version 2 to version 3.
+func UpgradeRepoV3(ctx context.Context, repo *Repository) error {
+	if repo.Config().Version != 2 {
+		return fmt.Errorf("repository has version %v, only upgrades from version 2 are supported", repo.Config().Version)
+	}
+	return upgradeRepo(ctx, repo, 3)
+}
 
-	tempdir, err := os.MkdirTemp("", "restic-migrate-upgrade-repo-v2-")
+func upgradeRepo(ctx context.Context, repo *Repository, version uint) error {
+	tempdir, err := os.MkdirTemp("", fmt.Sprintf("restic-migrate-upgrade-repo-v%d-", version))
 	if err != nil {
 		return fmt.Errorf("create temp dir failed: %w", err)
 	}
@@ -78,7 +89,7 @@ func UpgradeRepo(ctx context.Context, repo *Repository) error {
 	}
 
 	// run the upgrade
-	err = upgradeRepository(ctx, repo)
+	err = upgradeRepository(ctx, repo, version)
 	if err != nil {
 
 		// build an error we can return to the caller
@@ -97,6 +108,10 @@ func UpgradeRepo(ctx context.Context, repo *Repository) error {
 		return repoError
 	}
 
+	cfg := repo.Config()
+	cfg.Version = version
+	repo.setConfig(cfg)
+
 	_ = os.Remove(backupFileName)
 	_ = os.Remove(tempdir)
 	return nil
@@ -80,3 +80,14 @@ func TestUpgradeRepoV2Failure(t *testing.T) {
 	rtest.OK(t, os.Remove(upgradeErr.BackupFilePath))
 	rtest.OK(t, os.Remove(filepath.Dir(upgradeErr.BackupFilePath)))
 }
+
+func TestUpgradeRepoV3(t *testing.T) {
+	repo, _ := TestRepositoryWithVersion(t, 2)
+
+	err := UpgradeRepoV3(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(3), repo.Config().Version)
+
+	err = UpgradeRepoV3(context.Background(), repo)
+	rtest.Assert(t, err != nil, "upgrading a version 3 repository should fail")
+}
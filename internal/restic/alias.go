@@ -0,0 +1,117 @@
+package restic
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+const aliasesKind = "aliases"
+
+// SnapshotAliases maps user-defined names to snapshot IDs. An alias can be
+// used instead of the snapshot ID wherever a single snapshot is selected.
+type SnapshotAliases map[string]ID
+
+// ValidateAliasName returns an error if name cannot be used as an alias. Names
+// must not be mistaken for a snapshot ID, "latest" or the
+// <snapshot>:<subfolder> syntax.
+func ValidateAliasName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("alias name is empty")
+	case name == "latest":
+		return errors.New(`"latest" cannot be used as alias name`)
+	case isHex(name):
+		return errors.Errorf("alias name %q could be confused with a snapshot ID", name)
+	case strings.ContainsAny(name, ":/\\") || strings.ContainsFunc(name, unicode.IsSpace):
+		return errors.Errorf("alias name %q must not contain ':', '/', '\\' or whitespace", name)
+	}
+	return nil
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Names returns the sorted names of all aliases which refer to id.
+func (a SnapshotAliases) Names(id ID) []string {
+	var names []string
+	for name, target := range a {
+		if target == id {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadSnapshotAliases returns the aliases stored in the repository. If no
+// aliases exist, an empty map is returned.
+func LoadSnapshotAliases(ctx context.Context, repo StateLoader) (SnapshotAliases, error) {
+	aliases := make(SnapshotAliases)
+	err := LoadState(ctx, repo, aliasesKind, &aliases)
+	if err != nil && !errors.Is(err, ErrNoState) {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// SaveSnapshotAliases stores aliases in the repository, replacing all
+// previously stored aliases.
+func SaveSnapshotAliases(ctx context.Context, repo StateSaver, aliases SnapshotAliases) error {
+	if len(aliases) == 0 {
+		return RemoveState(ctx, repo, aliasesKind)
+	}
+	_, err := SaveState(ctx, repo, aliasesKind, aliases)
+	return err
+}
+
+// RemoveSnapshotAliases removes all aliases which refer to a snapshot for
+// which remove returns true. The sorted names of the removed aliases are
+// returned.
+func RemoveSnapshotAliases(ctx context.Context, repo StateSaver, remove func(id ID) bool) ([]string, error) {
+	aliases, err := LoadSnapshotAliases(ctx, repo)
+	if err != nil || len(aliases) == 0 {
+		return nil, err
+	}
+
+	var removed []string
+	for name, id := range aliases {
+		if remove(id) {
+			delete(aliases, name)
+			removed = append(removed, name)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	sort.Strings(removed)
+	return removed, SaveSnapshotAliases(ctx, repo, aliases)
+}
+
+// resolveAlias returns the snapshot ID the alias name refers to. The aliases
+// can only be loaded if loader is also able to list files and to return the
+// config, which holds for repositories.
+func resolveAlias(ctx context.Context, loader LoaderUnpacked, name string) (ID, bool, error) {
+	if ValidateAliasName(name) != nil {
+		return ID{}, false, nil
+	}
+	repo, ok := loader.(StateLoader)
+	if !ok {
+		return ID{}, false, nil
+	}
+	aliases, err := LoadSnapshotAliases(ctx, repo)
+	if err != nil {
+		return ID{}, false, err
+	}
+	id, ok := aliases[name]
+	return id, ok, nil
+}
@@ -0,0 +1,86 @@
+package restic_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestValidateAliasName(t *testing.T) {
+	for _, name := range []string{"golden-master", "release_1.2", "before-upgrade"} {
+		rtest.OK(t, restic.ValidateAliasName(name))
+	}
+	for _, name := range []string{"", "latest", "1a2b3c", "DEADBEEF", "a:b", "a/b", "a b"} {
+		rtest.Assert(t, restic.ValidateAliasName(name) != nil, "invalid name %q was accepted", name)
+	}
+}
+
+func TestSnapshotAliases(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+	sn1 := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
+	sn2 := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2017-07-07 07:07:07"), 1)
+
+	aliases, err := restic.LoadSnapshotAliases(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(aliases))
+
+	aliases["golden-master"] = *sn1.ID()
+	aliases["before-upgrade"] = *sn1.ID()
+	aliases["current"] = *sn2.ID()
+	rtest.OK(t, restic.SaveSnapshotAliases(ctx, repo, aliases))
+	aliases, err = restic.LoadSnapshotAliases(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"before-upgrade", "golden-master"}, aliases.Names(*sn1.ID()))
+
+	for _, exp := range []struct {
+		query     string
+		id        restic.ID
+		subfolder string
+	}{
+		{"golden-master", *sn1.ID(), ""},
+		{"current:subfolder", *sn2.ID(), "subfolder"},
+		{sn2.ID().Str(), *sn2.ID(), ""},
+	} {
+		sn, subfolder, err := restic.FindSnapshot(ctx, repo, repo, exp.query)
+		rtest.OK(t, err)
+		rtest.Equals(t, exp.id, *sn.ID())
+		rtest.Equals(t, exp.subfolder, subfolder)
+	}
+	_, _, err = restic.FindSnapshot(ctx, repo, repo, "unknown")
+	rtest.Assert(t, err != nil, "unknown alias was resolved")
+
+	rtest.OK(t, restic.SaveSnapshotAliases(ctx, repo, restic.SnapshotAliases{}))
+	aliases, err = restic.LoadSnapshotAliases(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(aliases))
+}
+
+func TestRemoveSnapshotAliases(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+	sn1 := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
+	sn2 := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2017-07-07 07:07:07"), 1)
+
+	aliases := restic.SnapshotAliases{
+		"golden-master":  *sn1.ID(),
+		"before-upgrade": *sn2.ID(),
+		"current":        *sn2.ID(),
+	}
+	rtest.OK(t, restic.SaveSnapshotAliases(ctx, repo, aliases))
+
+	removed, err := restic.RemoveSnapshotAliases(ctx, repo, func(restic.ID) bool { return false })
+	rtest.OK(t, err)
+	rtest.Equals(t, []string(nil), removed)
+
+	removed, err = restic.RemoveSnapshotAliases(ctx, repo, restic.NewIDSet(*sn2.ID()).Has)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"before-upgrade", "current"}, removed)
+
+	aliases, err = restic.LoadSnapshotAliases(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.SnapshotAliases{"golden-master": *sn1.ID()}, aliases)
+}
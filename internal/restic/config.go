@@ -17,10 +17,33 @@ type Config struct {
 	Version           uint        `json:"version"`
 	ID                string      `json:"id"`
 	ChunkerPolynomial chunker.Pol `json:"chunker_polynomial"`
+	// PackTransforms lists the transforms applied to pack files before they
+	// are uploaded, see the package internal/backend/transform.
+	PackTransforms []string `json:"pack_transforms,omitempty"`
+	// CompressionDictionaries contains zstd dictionaries trained for this
+	// repository. The last dictionary is used to compress new blobs, the
+	// other ones are only required to decompress existing blobs.
+	CompressionDictionaries [][]byte `json:"compression_dictionaries,omitempty"`
+	// AppendOnly prevents removing pack and snapshot files unless the
+	// repository is accessed using a key with the delete capability.
+	AppendOnly bool `json:"append_only,omitempty"`
 }
 
 const MinRepoVersion = 1
-const MaxRepoVersion = 2
+const MaxRepoVersion = 3
+
+// FeaturesRepoVersion is the first repository version which supports pack
+// transforms, compression dictionaries and state files. Older versions of
+// restic refuse to open such repositories.
+const FeaturesRepoVersion = 3
+
+// RepoVersionFeatures lists the format features added by each repository
+// version.
+var RepoVersionFeatures = map[uint][]string{
+	1: {},
+	2: {"compression"},
+	3: {"pack-transforms", "compression-dictionaries", "state-files"},
+}
 
 // StableRepoVersion is the version that is written to the config when a repository
 // is newly created with Init().
@@ -77,6 +100,9 @@ func LoadConfig(ctx context.Context, r LoaderUnpacked) (Config, error) {
 	if cfg.Version < MinRepoVersion || cfg.Version > MaxRepoVersion {
 		return Config{}, errors.Errorf("unsupported repository version %v", cfg.Version)
 	}
+	if err := cfg.checkFeatures(); err != nil {
+		return Config{}, err
+	}
 
 	if checkPolynomial {
 		if !cfg.ChunkerPolynomial.Irreducible() {
@@ -87,6 +113,18 @@ func LoadConfig(ctx context.Context, r LoaderUnpacked) (Config, error) {
 	return cfg, nil
 }
 
+// checkFeatures returns an error if the config uses features which are not
+// supported by its version.
+func (cfg Config) checkFeatures() error {
+	if len(cfg.PackTransforms) > 0 && cfg.Version < FeaturesRepoVersion {
+		return errors.Errorf("pack transforms require repository version %v, the repository has version %v", FeaturesRepoVersion, cfg.Version)
+	}
+	if len(cfg.CompressionDictionaries) > 0 && cfg.Version < FeaturesRepoVersion {
+		return errors.Errorf("compression dictionaries require repository version %v, the repository has version %v", FeaturesRepoVersion, cfg.Version)
+	}
+	return nil
+}
+
 func SaveConfig(ctx context.Context, r SaverUnpacked, cfg Config) error {
 	_, err := SaveJSONUnpacked(ctx, r, ConfigFile, cfg)
 	return err
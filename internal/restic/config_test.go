@@ -60,6 +60,5 @@ func TestConfig(t *testing.T) {
 	cfg2, err := restic.LoadConfig(context.TODO(), loader{load})
 	rtest.OK(t, err)
 
-	rtest.Assert(t, cfg1 == cfg2,
-		"configs aren't equal: %v != %v", cfg1, cfg2)
+	rtest.Equals(t, cfg1, cfg2)
 }
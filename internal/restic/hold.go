@@ -0,0 +1,196 @@
+package restic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+const (
+	holdKind      = "hold"
+	holdAuditKind = "hold-audit"
+)
+
+// Hold is a legal hold on the repository. While a hold is active, commands
+// which remove or rewrite snapshots or data refuse to run, unless the hold is
+// explicitly overridden. Each override is recorded in the hold and in the
+// audit log.
+type Hold struct {
+	Time      time.Time      `json:"time"`
+	Hostname  string         `json:"hostname,omitempty"`
+	Username  string         `json:"username,omitempty"`
+	Reason    string         `json:"reason"`
+	Overrides []HoldOverride `json:"overrides,omitempty"`
+}
+
+// HoldOverride records that a command was run despite an active hold.
+type HoldOverride struct {
+	Time          time.Time `json:"time"`
+	Hostname      string    `json:"hostname,omitempty"`
+	Username      string    `json:"username,omitempty"`
+	Command       string    `json:"command"`
+	Justification string    `json:"justification"`
+}
+
+// Actions recorded in the audit log of legal holds.
+const (
+	HoldActionSet      = "set"
+	HoldActionOverride = "override"
+	HoldActionClear    = "clear"
+)
+
+// HoldEvent is an entry of the audit log of legal holds. Entries are only
+// ever added to the audit log, it is kept when a hold is cleared.
+type HoldEvent struct {
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname,omitempty"`
+	Username string    `json:"username,omitempty"`
+	Action   string    `json:"action"`
+	Command  string    `json:"command,omitempty"`
+	// Text is the reason for a new hold, or the justification for an
+	// override or for clearing the hold.
+	Text string `json:"text"`
+}
+
+func hostAndUser() (hostname string, username string) {
+	hostname, _ = os.Hostname()
+	if usr, err := user.Current(); err == nil {
+		username = usr.Username
+	}
+	return hostname, username
+}
+
+// NewHold returns a new hold with the given reason, created by the current
+// user on this host.
+func NewHold(reason string) *Hold {
+	hostname, username := hostAndUser()
+	return &Hold{
+		Time:     time.Now(),
+		Hostname: hostname,
+		Username: username,
+		Reason:   reason,
+	}
+}
+
+// AddOverride records that command was run despite the hold.
+func (h *Hold) AddOverride(command string, justification string) {
+	hostname, username := hostAndUser()
+	h.Overrides = append(h.Overrides, HoldOverride{
+		Time:          time.Now(),
+		Hostname:      hostname,
+		Username:      username,
+		Command:       command,
+		Justification: justification,
+	})
+}
+
+// LoadHold returns the active hold of the repository or nil if there is none.
+func LoadHold(ctx context.Context, repo StateLoader) (*Hold, error) {
+	var h Hold
+	err := LoadState(ctx, repo, holdKind, &h)
+	if errors.Is(err, ErrNoState) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// SaveHold stores h as the active hold of the repository.
+func SaveHold(ctx context.Context, repo StateSaver, h *Hold) error {
+	_, err := SaveState(ctx, repo, holdKind, h)
+	return err
+}
+
+// PlaceHold places the repository under a new legal hold with the given
+// reason and records this in the audit log.
+func PlaceHold(ctx context.Context, repo StateSaver, reason string) (*Hold, error) {
+	h := NewHold(reason)
+	if err := appendHoldEvent(ctx, repo, HoldActionSet, "", reason); err != nil {
+		return nil, err
+	}
+	if err := SaveHold(ctx, repo, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ClearHold removes the active hold of the repository. The justification is
+// required and recorded in the audit log.
+func ClearHold(ctx context.Context, repo StateSaver, justification string) error {
+	if justification == "" {
+		return errors.New("a justification is required to clear a legal hold")
+	}
+	if err := appendHoldEvent(ctx, repo, HoldActionClear, "", justification); err != nil {
+		return err
+	}
+	return RemoveState(ctx, repo, holdKind)
+}
+
+// LoadHoldAudit returns the audit log of legal holds, from oldest to newest.
+func LoadHoldAudit(ctx context.Context, repo StateLoader) ([]HoldEvent, error) {
+	var events []HoldEvent
+	err := LoadStateLog(ctx, repo, holdAuditKind, func(data json.RawMessage) error {
+		var ev HoldEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("failed to decode hold audit log: %w", err)
+		}
+		events = append(events, ev)
+		return nil
+	})
+	return events, err
+}
+
+func appendHoldEvent(ctx context.Context, repo StateSaver, action string, command string, text string) error {
+	hostname, username := hostAndUser()
+	_, err := AppendState(ctx, repo, holdAuditKind, HoldEvent{
+		Time:     time.Now(),
+		Hostname: hostname,
+		Username: username,
+		Action:   action,
+		Command:  command,
+		Text:     text,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record %v in hold audit log: %w", action, err)
+	}
+	return nil
+}
+
+// HoldError is returned by CheckHold if the repository is under a legal hold.
+type HoldError struct {
+	Hold *Hold
+}
+
+func (e *HoldError) Error() string {
+	return fmt.Sprintf("repository is under a legal hold since %v: %v", e.Hold.Time.Local().Format(time.DateTime), e.Hold.Reason)
+}
+
+// CheckHold returns a *HoldError if the repository is under a legal hold and
+// no justification to override the hold is given. Otherwise, the override of
+// the hold by command is recorded in the hold and the audit log, and the hold
+// is returned, which is nil if the repository is not under a hold.
+func CheckHold(ctx context.Context, repo StateSaver, command string, justification string) (*Hold, error) {
+	hold, err := LoadHold(ctx, repo)
+	if err != nil || hold == nil {
+		return nil, err
+	}
+	if justification == "" {
+		return nil, &HoldError{Hold: hold}
+	}
+
+	if err := appendHoldEvent(ctx, repo, HoldActionOverride, command, justification); err != nil {
+		return nil, err
+	}
+	hold.AddOverride(command, justification)
+	if err := SaveHold(ctx, repo, hold); err != nil {
+		return nil, fmt.Errorf("unable to record hold override: %w", err)
+	}
+	return hold, nil
+}
@@ -0,0 +1,58 @@
+package restic_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestHold(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	h, err := restic.LoadHold(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, h == nil, "unexpected hold %v", h)
+
+	_, err = restic.PlaceHold(ctx, repo, "case 42")
+	rtest.OK(t, err)
+	h, err = restic.LoadHold(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, "case 42", h.Reason)
+	rtest.Equals(t, 0, len(h.Overrides))
+
+	_, err = restic.CheckHold(ctx, repo, "prune", "")
+	var herr *restic.HoldError
+	rtest.Assert(t, errors.As(err, &herr), "unexpected error %v", err)
+	_, err = restic.CheckHold(ctx, repo, "prune", "approved by legal")
+	rtest.OK(t, err)
+	h, err = restic.LoadHold(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(h.Overrides))
+	rtest.Equals(t, "prune", h.Overrides[0].Command)
+	rtest.Equals(t, "approved by legal", h.Overrides[0].Justification)
+
+	rtest.Assert(t, restic.ClearHold(ctx, repo, "") != nil, "hold was cleared without justification")
+	rtest.OK(t, restic.ClearHold(ctx, repo, "case closed"))
+	h, err = restic.LoadHold(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, h == nil, "hold was not removed")
+
+	// the audit log is kept after the hold was cleared
+	events, err := restic.LoadHoldAudit(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 3, len(events))
+	for i, want := range []restic.HoldEvent{
+		{Action: restic.HoldActionSet, Text: "case 42"},
+		{Action: restic.HoldActionOverride, Command: "prune", Text: "approved by legal"},
+		{Action: restic.HoldActionClear, Text: "case closed"},
+	} {
+		rtest.Equals(t, want.Action, events[i].Action)
+		rtest.Equals(t, want.Command, events[i].Command)
+		rtest.Equals(t, want.Text, events[i].Text)
+	}
+}
@@ -46,13 +46,15 @@ const (
 	TypeFileAttributes GenericAttributeType = "windows.file_attributes"
 	// TypeSecurityDescriptor is the GenericAttributeType used for storing security descriptors including owner, group, discretionary access control list (DACL), system access control list (SACL)) for windows files within the generic attributes map.
 	TypeSecurityDescriptor GenericAttributeType = "windows.security_descriptor"
+	// TypeAlternateDataStream is the GenericAttributeType used for marking nodes which store an alternate data stream of a windows file instead of a file.
+	TypeAlternateDataStream GenericAttributeType = "windows.alternate_data_stream"
 
 	// Generic Attributes for other OS types should be defined here.
 )
 
 // init is called when the package is initialized. Any new GenericAttributeTypes being created must be added here as well.
 func init() {
-	storeGenericAttributeType(TypeCreationTime, TypeFileAttributes, TypeSecurityDescriptor)
+	storeGenericAttributeType(TypeCreationTime, TypeFileAttributes, TypeSecurityDescriptor, TypeAlternateDataStream)
 }
 
 // genericAttributesForOS maintains a map of known genericAttributesForOS to the OSType
@@ -89,6 +91,7 @@ type Node struct {
 	ModTime    time.Time   `json:"mtime,omitempty"`
 	AccessTime time.Time   `json:"atime,omitempty"`
 	ChangeTime time.Time   `json:"ctime,omitempty"`
+	BirthTime  *time.Time  `json:"btime,omitempty"` // only stored if the birth-time feature flag is enabled
 	UID        uint32      `json:"uid"`
 	GID        uint32      `json:"gid"`
 	User       string      `json:"user,omitempty"`
@@ -154,6 +157,13 @@ func (node Node) GetExtendedAttribute(a string) []byte {
 	return nil
 }
 
+// IsAlternateDataStream returns true if the node stores an alternate data
+// stream of a file on Windows instead of a file.
+func (node Node) IsAlternateDataStream() bool {
+	_, ok := node.GenericAttributes[TypeAlternateDataStream]
+	return ok
+}
+
 // FixTime returns a time.Time which can safely be used to marshal as JSON. If
 // the timestamp is earlier than year zero, the year is set to zero. In the same
 // way, if the year is larger than 9999, the year is set to 9999. Other than
@@ -175,6 +185,10 @@ func (node Node) MarshalJSON() ([]byte, error) {
 	node.ModTime = FixTime(node.ModTime)
 	node.AccessTime = FixTime(node.AccessTime)
 	node.ChangeTime = FixTime(node.ChangeTime)
+	if node.BirthTime != nil {
+		btime := FixTime(*node.BirthTime)
+		node.BirthTime = &btime
+	}
 
 	type nodeJSON Node
 	nj := nodeJSON(node)
@@ -230,6 +244,9 @@ func (node Node) Equals(other Node) bool {
 	if !node.ChangeTime.Equal(other.ChangeTime) {
 		return false
 	}
+	if !node.sameBirthTime(other) {
+		return false
+	}
 	if node.UID != other.UID {
 		return false
 	}
@@ -289,6 +306,13 @@ func (node Node) Equals(other Node) bool {
 	return true
 }
 
+func (node Node) sameBirthTime(other Node) bool {
+	if node.BirthTime == nil || other.BirthTime == nil {
+		return node.BirthTime == nil && other.BirthTime == nil
+	}
+	return node.BirthTime.Equal(*other.BirthTime)
+}
+
 func (node Node) sameContent(other Node) bool {
 	if node.Content == nil {
 		return other.Content == nil
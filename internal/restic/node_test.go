@@ -3,6 +3,7 @@ package restic
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -94,3 +95,21 @@ func TestSymlinkSerializationFormat(t *testing.T) {
 		test.Assert(t, n2.LinkTargetRaw == nil, "quoted link target is just a helper field and must be unset after decoding")
 	}
 }
+
+func TestBirthTimeSerialization(t *testing.T) {
+	// nodes without a birth time must serialize as before to keep tree IDs stable
+	ser, err := json.Marshal(Node{Name: "foo"})
+	test.OK(t, err)
+	test.Assert(t, !strings.Contains(string(ser), "btime"), "unexpected btime in %s", ser)
+
+	btime := parseTimeNano(t, "2005-05-14T21:07:03.123456789+02:00")
+	n := Node{Name: "foo", BirthTime: &btime}
+	ser, err = json.Marshal(n)
+	test.OK(t, err)
+
+	var n2 Node
+	test.OK(t, json.Unmarshal(ser, &n2))
+	test.Assert(t, n2.BirthTime != nil, "birth time missing after roundtrip")
+	test.Assert(t, n.Equals(n2), "nodes differ after roundtrip: %v vs %v", n.BirthTime, n2.BirthTime)
+	test.Assert(t, !n.Equals(Node{Name: "foo"}), "nodes with and without birth time must differ")
+}
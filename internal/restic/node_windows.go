@@ -16,6 +16,9 @@ type WindowsAttributes struct {
 	// SecurityDescriptor is used for storing security descriptors which includes
 	// owner, group, discretionary access control list (DACL), system access control list (SACL)
 	SecurityDescriptor *[]byte `generic:"security_descriptor"`
+	// AlternateDataStream is set for nodes which store an alternate data stream
+	// named "file:stream" of a file.
+	AlternateDataStream *bool `generic:"alternate_data_stream"`
 }
 
 // windowsAttrsToGenericAttributes converts the WindowsAttributes to a generic attributes map using reflection
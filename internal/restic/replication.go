@@ -0,0 +1,52 @@
+package restic
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+const replicationKind = "replication"
+
+// ReplicationState records the source repository and the snapshots which were
+// replicated from it into a repository.
+type ReplicationState struct {
+	Source    string               `json:"source"`
+	Snapshots []ReplicatedSnapshot `json:"snapshots"`
+}
+
+// ReplicatedSnapshot maps a snapshot of the source repository to its replica.
+type ReplicatedSnapshot struct {
+	Source  ID `json:"source"`
+	Replica ID `json:"replica"`
+}
+
+// RenameReplicas replaces the IDs of replicas which are listed in renamed. It
+// returns whether a replica was renamed.
+func (st *ReplicationState) RenameReplicas(renamed map[ID]ID) bool {
+	changed := false
+	for i, sn := range st.Snapshots {
+		if newID, ok := renamed[sn.Replica]; ok {
+			st.Snapshots[i].Replica = newID
+			changed = true
+		}
+	}
+	return changed
+}
+
+// LoadReplicationState returns the replication state stored in the
+// repository. If the repository is not a replica, an empty state is returned.
+func LoadReplicationState(ctx context.Context, repo StateLoader) (*ReplicationState, error) {
+	var st ReplicationState
+	err := LoadState(ctx, repo, replicationKind, &st)
+	if err != nil && !errors.Is(err, ErrNoState) {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// SaveReplicationState stores the replication state in the repository.
+func SaveReplicationState(ctx context.Context, repo StateSaver, st *ReplicationState) error {
+	_, err := SaveState(ctx, repo, replicationKind, st)
+	return err
+}
@@ -58,6 +58,9 @@ type Repository interface {
 	// LoadUnpacked loads and decrypts the file with the given type and ID.
 	LoadUnpacked(ctx context.Context, t FileType, id ID) (data []byte, err error)
 	SaveUnpacked(ctx context.Context, t FileType, buf []byte) (ID, error)
+	// SaveUnpackedWithPrefix is like SaveUnpacked, but the ID of the file
+	// starts with prefix. It is used for state files.
+	SaveUnpackedWithPrefix(ctx context.Context, t FileType, prefix []byte, buf []byte) (ID, error)
 	// RemoveUnpacked removes a file from the repository. This will eventually be restricted to deleting only snapshots.
 	RemoveUnpacked(ctx context.Context, t FileType, id ID) error
 }
@@ -72,6 +75,7 @@ const (
 	SnapshotFile FileType = backend.SnapshotFile
 	IndexFile    FileType = backend.IndexFile
 	ConfigFile   FileType = backend.ConfigFile
+	StateFile    FileType = backend.StateFile
 )
 
 // LoaderUnpacked allows loading a blob not stored in a pack file
@@ -81,6 +85,16 @@ type LoaderUnpacked interface {
 	LoadUnpacked(ctx context.Context, t FileType, id ID) (data []byte, err error)
 }
 
+// SnapshotCache is implemented by repositories which keep a local copy of the
+// decrypted snapshot files. As snapshot files are never modified, a cached
+// snapshot is valid as long as a snapshot file with the same ID exists.
+type SnapshotCache interface {
+	// LoadSnapshotCache returns the plaintext of all cached snapshot files.
+	LoadSnapshotCache() (map[ID][]byte, error)
+	// SaveSnapshotCache replaces the cached snapshot files.
+	SaveSnapshotCache(snapshots map[ID][]byte) error
+}
+
 // SaverUnpacked allows saving a blob not stored in a pack file
 type SaverUnpacked interface {
 	// Connections returns the maximum number of concurrent backend operations
@@ -2,6 +2,7 @@ package restic
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os/user"
 	"path/filepath"
@@ -28,6 +29,17 @@ type Snapshot struct {
 	ProgramVersion string           `json:"program_version,omitempty"`
 	Summary        *SnapshotSummary `json:"summary,omitempty"`
 
+	// Partial is set if the backup did not complete, the snapshot then only
+	// contains the files processed so far.
+	Partial bool `json:"partial,omitempty"`
+	// Supersedes lists the chain of partial snapshots, newest first, which
+	// preceded this complete snapshot.
+	Supersedes IDs `json:"supersedes,omitempty"`
+	// IngestedAt is the time at which the snapshot was created in the
+	// repository. Unlike Time, it cannot be set by the user and is kept when
+	// the snapshot is rewritten or copied.
+	IngestedAt *time.Time `json:"ingested_at,omitempty"`
+
 	id *ID // plaintext ID, used during restore
 }
 
@@ -51,8 +63,8 @@ type SnapshotSummary struct {
 }
 
 // NewSnapshot returns an initialized snapshot struct for the current user and
-// time.
-func NewSnapshot(paths []string, tags []string, hostname string, time time.Time) (*Snapshot, error) {
+// the given time. The current time is recorded as IngestedAt.
+func NewSnapshot(paths []string, tags []string, hostname string, timestamp time.Time) (*Snapshot, error) {
 	absPaths := make([]string, 0, len(paths))
 	for _, path := range paths {
 		p, err := filepath.Abs(path)
@@ -63,11 +75,13 @@ func NewSnapshot(paths []string, tags []string, hostname string, time time.Time)
 		}
 	}
 
+	now := time.Now()
 	sn := &Snapshot{
-		Paths:    absPaths,
-		Time:     time,
-		Tags:     tags,
-		Hostname: hostname,
+		Paths:      absPaths,
+		Time:       timestamp,
+		Tags:       tags,
+		Hostname:   hostname,
+		IngestedAt: &now,
 	}
 
 	err := sn.fillUserInfo()
@@ -80,12 +94,29 @@ func NewSnapshot(paths []string, tags []string, hostname string, time time.Time)
 
 // LoadSnapshot loads the snapshot with the id and returns it.
 func LoadSnapshot(ctx context.Context, loader LoaderUnpacked, id ID) (*Snapshot, error) {
-	sn := &Snapshot{id: &id}
-	err := LoadJSONUnpacked(ctx, loader, SnapshotFile, id, sn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load snapshot %v: %w", id.Str(), err)
+	sn, _, err := loadSnapshot(ctx, loader, id)
+	return sn, err
+}
+
+// loadSnapshot loads the snapshot with the id and returns it along with the
+// plaintext of the snapshot file.
+func loadSnapshot(ctx context.Context, loader LoaderUnpacked, id ID) (*Snapshot, []byte, error) {
+	buf, err := loader.LoadUnpacked(ctx, SnapshotFile, id)
+	if err == nil {
+		var sn *Snapshot
+		sn, err = decodeSnapshot(id, buf)
+		if err == nil {
+			return sn, buf, nil
+		}
 	}
+	return nil, nil, fmt.Errorf("failed to load snapshot %v: %w", id.Str(), err)
+}
 
+func decodeSnapshot(id ID, buf []byte) (*Snapshot, error) {
+	sn := &Snapshot{id: &id}
+	if err := json.Unmarshal(buf, sn); err != nil {
+		return nil, err
+	}
 	return sn, nil
 }
 
@@ -99,20 +130,78 @@ func SaveSnapshot(ctx context.Context, repo SaverUnpacked, sn *Snapshot) (ID, er
 // If the called function returns an error, this function is cancelled and
 // also returns this error.
 // If a snapshot ID is in excludeIDs, it will be ignored.
+// If loader implements SnapshotCache, cached snapshots are not loaded again
+// and the cache is updated to match the snapshot files in the repository.
 func ForAllSnapshots(ctx context.Context, be Lister, loader LoaderUnpacked, excludeIDs IDSet, fn func(ID, *Snapshot, error) error) error {
 	var m sync.Mutex
 
+	cache, useCache := loader.(SnapshotCache)
+	var cached, current map[ID][]byte
+	if useCache {
+		var err error
+		cached, err = cache.LoadSnapshotCache()
+		if err != nil {
+			debug.Log("ignoring snapshot cache: %v", err)
+			cached = nil
+		}
+		current = make(map[ID][]byte, len(cached))
+	}
+
 	// For most snapshots decoding is nearly for free, thus just assume were only limited by IO
-	return ParallelList(ctx, be, SnapshotFile, loader.Connections(), func(ctx context.Context, id ID, _ int64) error {
+	err := ParallelList(ctx, be, SnapshotFile, loader.Connections(), func(ctx context.Context, id ID, _ int64) error {
+		m.Lock()
+		buf, isCached := cached[id]
+		m.Unlock()
+
 		if excludeIDs.Has(id) {
+			if isCached {
+				m.Lock()
+				current[id] = buf
+				m.Unlock()
+			}
 			return nil
 		}
 
-		sn, err := LoadSnapshot(ctx, loader, id)
+		var sn *Snapshot
+		var err error
+		if isCached {
+			sn, err = decodeSnapshot(id, buf)
+			if err != nil {
+				debug.Log("ignoring cached snapshot %v: %v", id, err)
+				isCached = false
+			}
+		}
+		if !isCached {
+			sn, buf, err = loadSnapshot(ctx, loader, id)
+		}
+
 		m.Lock()
 		defer m.Unlock()
+		if useCache && err == nil {
+			current[id] = buf
+		}
 		return fn(id, sn, err)
 	})
+
+	if err == nil && useCache && !sameSnapshotIDs(cached, current) {
+		// the cache is only an optimization, failing to update it is not fatal
+		if err := cache.SaveSnapshotCache(current); err != nil {
+			debug.Log("updating snapshot cache failed: %v", err)
+		}
+	}
+	return err
+}
+
+func sameSnapshotIDs(a, b map[ID][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id := range a {
+		if _, ok := b[id]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
 func (sn Snapshot) String() string {
@@ -248,6 +337,34 @@ func (sn *Snapshot) HasHostname(hostnames []string) bool {
 	return false
 }
 
+// IsIdentical returns true if both snapshots reference the same tree and have
+// the same hostname, paths and tags. Other metadata like the time is ignored.
+func (sn *Snapshot) IsIdentical(other *Snapshot) bool {
+	if sn.Tree == nil || other.Tree == nil || !sn.Tree.Equal(*other.Tree) {
+		return false
+	}
+	return sn.Hostname == other.Hostname &&
+		sn.HasPaths(other.Paths) && other.HasPaths(sn.Paths) &&
+		sn.HasTags(other.Tags) && other.HasTags(sn.Tags)
+}
+
+// FindSupersededSnapshots returns the partial snapshots from list which are
+// superseded by another snapshot in list.
+func FindSupersededSnapshots(list Snapshots) (superseded Snapshots) {
+	ids := NewIDSet()
+	for _, sn := range list {
+		for _, id := range sn.Supersedes {
+			ids.Insert(id)
+		}
+	}
+	for _, sn := range list {
+		if sn.Partial && ids.Has(*sn.ID()) {
+			superseded = append(superseded, sn)
+		}
+	}
+	return superseded
+}
+
 // Snapshots is a list of snapshots.
 type Snapshots []*Snapshot
 
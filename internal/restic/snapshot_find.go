@@ -89,18 +89,26 @@ func splitSnapshotID(s string) (id, subfolder string) {
 }
 
 // FindSnapshot takes a string and tries to find a snapshot whose ID matches
-// the string as closely as possible.
+// the string as closely as possible. The string can also be the name of a
+// snapshot alias.
 func FindSnapshot(ctx context.Context, be Lister, loader LoaderUnpacked, s string) (*Snapshot, string, error) {
 	s, subfolder := splitSnapshotID(s)
 
 	// no need to list snapshots if `s` is already a full id
 	id, err := ParseID(s)
 	if err != nil {
-		// find snapshot id with prefix
-		id, err = Find(ctx, be, SnapshotFile, s)
+		var found bool
+		id, found, err = resolveAlias(ctx, loader, s)
 		if err != nil {
 			return nil, "", err
 		}
+		if !found {
+			// find snapshot id with prefix
+			id, err = Find(ctx, be, SnapshotFile, s)
+			if err != nil {
+				return nil, "", err
+			}
+		}
 	}
 	sn, err := LoadSnapshot(ctx, loader, id)
 	return sn, subfolder, err
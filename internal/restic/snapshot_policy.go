@@ -308,3 +308,19 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 
 	return keep, remove, reasons
 }
+
+// FindIdenticalSnapshots returns the snapshots from list which are identical
+// to the next older snapshot, see Snapshot.IsIdentical. Thus, only the oldest
+// snapshot of each run of identical snapshots is not returned. The newest
+// snapshot is never returned. list is sorted in the process.
+func FindIdenticalSnapshots(list Snapshots) (identical Snapshots) {
+	// sort newest snapshots first
+	sort.Stable(list)
+
+	for i := 1; i < len(list)-1; i++ {
+		if list[i].IsIdentical(list[i+1]) {
+			identical = append(identical, list[i])
+		}
+	}
+	return identical
+}
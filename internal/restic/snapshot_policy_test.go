@@ -285,3 +285,61 @@ func TestApplyPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestFindSupersededSnapshots(t *testing.T) {
+	var list restic.Snapshots
+	for i := 0; i < 5; i++ {
+		sn, err := restic.NewSnapshot([]string{"/data"}, nil, "foo", parseTimeUTC("2024-01-01 12:00:00").AddDate(0, 0, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		restic.TestSetSnapshotID(t, sn, restic.NewRandomID())
+		list = append(list, sn)
+	}
+	// two partial snapshots superseded by a complete one, followed by a
+	// partial snapshot which is not superseded yet
+	list[0].Partial = true
+	list[1].Partial = true
+	list[2].Supersedes = restic.IDs{*list[1].ID(), *list[0].ID()}
+	list[4].Partial = true
+	// only partial snapshots are returned
+	list[3].Supersedes = restic.IDs{*list[2].ID()}
+
+	superseded := restic.FindSupersededSnapshots(list)
+	want := restic.Snapshots{list[0], list[1]}
+	if len(superseded) != len(want) {
+		t.Fatalf("expected %d superseded snapshots, got %d", len(want), len(superseded))
+	}
+	for i := range want {
+		if superseded[i] != want[i] {
+			t.Errorf("wrong snapshot %v at index %d, want %v", superseded[i].Time, i, want[i].Time)
+		}
+	}
+}
+
+func TestFindIdenticalSnapshots(t *testing.T) {
+	trees := restic.IDs{restic.NewRandomID(), restic.NewRandomID()}
+	var list restic.Snapshots
+	// the tree of each snapshot, from old to new
+	for i, tree := range []int{0, 0, 0, 1, 1, 0, 0} {
+		sn, err := restic.NewSnapshot([]string{"/data"}, nil, "foo", parseTimeUTC("2024-01-01 12:00:00").AddDate(0, 0, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sn.Tree = &trees[tree]
+		list = append(list, sn)
+	}
+	all := append(restic.Snapshots{}, list...)
+
+	identical := restic.FindIdenticalSnapshots(list)
+	// the oldest snapshot of each run and the newest snapshot remain
+	want := restic.Snapshots{all[4], all[2], all[1]}
+	if len(identical) != len(want) {
+		t.Fatalf("expected %d identical snapshots, got %d", len(want), len(identical))
+	}
+	for i := range want {
+		if identical[i] != want[i] {
+			t.Errorf("wrong snapshot %v at index %d, want %v", identical[i].Time, i, want[i].Time)
+		}
+	}
+}
@@ -13,8 +13,11 @@ import (
 func TestNewSnapshot(t *testing.T) {
 	paths := []string{"/home/foobar"}
 
-	_, err := restic.NewSnapshot(paths, nil, "foo", time.Now())
+	before := time.Now()
+	sn, err := restic.NewSnapshot(paths, nil, "foo", time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC))
 	rtest.OK(t, err)
+	rtest.Equals(t, 2001, sn.Time.Year())
+	rtest.Assert(t, sn.IngestedAt != nil && !sn.IngestedAt.Before(before), "unexpected ingestion time %v", sn.IngestedAt)
 }
 
 func TestTagList(t *testing.T) {
@@ -27,6 +30,28 @@ func TestTagList(t *testing.T) {
 	rtest.Assert(t, r, "Failed to match untagged snapshot")
 }
 
+func TestSnapshotIsIdentical(t *testing.T) {
+	tree := restic.NewRandomID()
+	newSnapshot := func(paths []string, tags []string, hostname string) *restic.Snapshot {
+		sn, err := restic.NewSnapshot(paths, tags, hostname, time.Now())
+		rtest.OK(t, err)
+		id := tree
+		sn.Tree = &id
+		return sn
+	}
+
+	sn := newSnapshot([]string{"/a", "/b"}, []string{"x", "y"}, "foo")
+	rtest.Assert(t, sn.IsIdentical(newSnapshot([]string{"/b", "/a"}, []string{"y", "x"}, "foo")), "snapshots are not identical")
+	rtest.Assert(t, !sn.IsIdentical(newSnapshot([]string{"/a"}, []string{"x", "y"}, "foo")), "different paths are identical")
+	rtest.Assert(t, !sn.IsIdentical(newSnapshot([]string{"/a", "/b"}, []string{"x"}, "foo")), "different tags are identical")
+	rtest.Assert(t, !sn.IsIdentical(newSnapshot([]string{"/a", "/b"}, []string{"x", "y"}, "bar")), "different hosts are identical")
+
+	other := newSnapshot([]string{"/a", "/b"}, []string{"x", "y"}, "foo")
+	otherTree := restic.NewRandomID()
+	other.Tree = &otherTree
+	rtest.Assert(t, !sn.IsIdentical(other), "different trees are identical")
+}
+
 func TestLoadJSONUnpacked(t *testing.T) {
 	repository.TestAllVersions(t, testLoadJSONUnpacked)
 }
@@ -0,0 +1,240 @@
+package restic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// ErrNoState is returned by LoadState if no state of the requested kind exists.
+var ErrNoState = errors.New("no state found")
+
+// ErrStateUnsupported is returned when saving a state in a repository which
+// does not support state files.
+var ErrStateUnsupported = errors.Errorf("state files require repository version %v, use `migrate upgrade_repo_v3` to upgrade the repository", FeaturesRepoVersion)
+
+// StateKindSize is the number of bytes at the start of the ID of a state file
+// which identify the kind of the state. The remaining bytes are taken from the
+// hash of the file content.
+const StateKindSize = 16
+
+// state is the content of a state file. State files hold small pieces of
+// repository-wide information, for example the index manifest. Each file
+// stores one version of the state of a certain kind. The newest version wins.
+type state struct {
+	Kind string          `json:"kind"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+type stateVersion struct {
+	id   ID
+	time time.Time
+	data json.RawMessage
+}
+
+// StateLoader allows loading state files.
+type StateLoader interface {
+	ListerLoaderUnpacked
+	Config() Config
+}
+
+// StateSaver allows saving and removing state files.
+type StateSaver interface {
+	StateLoader
+	RemoverUnpacked
+	// SaveUnpackedWithPrefix encrypts buf and stores it. The ID of the file
+	// starts with prefix, the remaining bytes are taken from the hash of the
+	// stored data.
+	SaveUnpackedWithPrefix(ctx context.Context, t FileType, prefix []byte, buf []byte) (ID, error)
+}
+
+// supportsState returns whether the repository can store state files.
+func supportsState(repo StateLoader) bool {
+	return repo.Config().Version >= FeaturesRepoVersion
+}
+
+// stateKindPrefix returns the prefix of the IDs of all state files of the
+// given kind.
+func stateKindPrefix(kind string) []byte {
+	h := Hash([]byte("state:" + kind))
+	return h[:StateKindSize]
+}
+
+// listStates returns all versions of the state of the given kind, sorted
+// from oldest to newest. Only the files whose ID matches the kind are loaded.
+// Unreadable state files are ignored.
+func listStates(ctx context.Context, repo StateLoader, kind string) ([]stateVersion, error) {
+	prefix := stateKindPrefix(kind)
+	var ids IDs
+	err := repo.List(ctx, StateFile, func(id ID, _ int64) error {
+		if bytes.Equal(id[:StateKindSize], prefix) {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []stateVersion
+	for _, id := range ids {
+		var st state
+		err := LoadJSONUnpacked(ctx, repo, StateFile, id, &st)
+		if err != nil {
+			debug.Log("unable to load state file %v: %v", id.Str(), err)
+			continue
+		}
+		if st.Kind != kind {
+			continue
+		}
+		versions = append(versions, stateVersion{id: id, time: st.Time, data: st.Data})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].time.Before(versions[j].time)
+	})
+	return versions, nil
+}
+
+// LoadState loads the newest version of the state of the given kind into
+// data. If no such state exists or the repository does not support state
+// files, ErrNoState is returned.
+func LoadState(ctx context.Context, repo StateLoader, kind string, data interface{}) error {
+	if !supportsState(repo) {
+		return ErrNoState
+	}
+	versions, err := listStates(ctx, repo, kind)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return ErrNoState
+	}
+
+	err = json.Unmarshal(versions[len(versions)-1].data, data)
+	if err != nil {
+		return fmt.Errorf("failed to decode %v state: %w", kind, err)
+	}
+	return nil
+}
+
+// SaveState stores data as the newest version of the state of the given kind.
+// Older versions of that state are removed afterwards. If the repository does
+// not support state files, ErrStateUnsupported is returned.
+func SaveState(ctx context.Context, repo StateSaver, kind string, data interface{}) (ID, error) {
+	if !supportsState(repo) {
+		return ID{}, ErrStateUnsupported
+	}
+	versions, err := listStates(ctx, repo, kind)
+	if err != nil {
+		return ID{}, err
+	}
+
+	id, err := saveStateFile(ctx, repo, kind, data)
+	if err != nil {
+		return ID{}, err
+	}
+
+	for _, v := range versions {
+		if v.id == id {
+			continue
+		}
+		err = repo.RemoveUnpacked(ctx, StateFile, v.id)
+		if err != nil {
+			debug.Log("unable to remove old state %v: %v", v.id.Str(), err)
+		}
+	}
+	return id, nil
+}
+
+// AppendState stores data as a new entry of the log of the given kind. Unlike
+// SaveState, the existing entries are kept, such that the entries form an
+// append-only log. If the repository does not support state files,
+// ErrStateUnsupported is returned.
+func AppendState(ctx context.Context, repo StateSaver, kind string, data interface{}) (ID, error) {
+	if !supportsState(repo) {
+		return ID{}, ErrStateUnsupported
+	}
+	return saveStateFile(ctx, repo, kind, data)
+}
+
+// LoadStateLog passes all entries of the log of the given kind to fn, from
+// oldest to newest. If the repository does not support state files, no
+// entries are returned.
+func LoadStateLog(ctx context.Context, repo StateLoader, kind string, fn func(data json.RawMessage) error) error {
+	if !supportsState(repo) {
+		return nil
+	}
+	versions, err := listStates(ctx, repo, kind)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		if err := fn(v.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveStateFile(ctx context.Context, repo StateSaver, kind string, data interface{}) (ID, error) {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return ID{}, errors.Wrap(err, "json.Marshal")
+	}
+
+	buf, err = json.Marshal(state{Kind: kind, Time: time.Now(), Data: buf})
+	if err != nil {
+		return ID{}, errors.Wrap(err, "json.Marshal")
+	}
+	return repo.SaveUnpackedWithPrefix(ctx, StateFile, stateKindPrefix(kind), buf)
+}
+
+// RemoveState removes all versions of the state of the given kind.
+func RemoveState(ctx context.Context, repo StateSaver, kind string) error {
+	if !supportsState(repo) {
+		return nil
+	}
+	versions, err := listStates(ctx, repo, kind)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		err = repo.RemoveUnpacked(ctx, StateFile, v.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TrimStateLog removes the oldest entries of the log of the given kind, such
+// that at most keep entries remain. The caller must hold an exclusive lock, as
+// concurrently appended entries could be removed otherwise.
+func TrimStateLog(ctx context.Context, repo StateSaver, kind string, keep int) error {
+	if !supportsState(repo) {
+		return nil
+	}
+	versions, err := listStates(ctx, repo, kind)
+	if err != nil {
+		return err
+	}
+
+	for len(versions) > keep {
+		err = repo.RemoveUnpacked(ctx, StateFile, versions[0].id)
+		if err != nil {
+			return err
+		}
+		versions = versions[1:]
+	}
+	return nil
+}
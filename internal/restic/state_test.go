@@ -0,0 +1,111 @@
+package restic_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type testState struct {
+	Value string `json:"value"`
+}
+
+func TestStateRoundtrip(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	var st testState
+	err := restic.LoadState(ctx, repo, "test", &st)
+	rtest.Assert(t, err == restic.ErrNoState, "expected ErrNoState, got %v", err)
+
+	_, err = restic.SaveState(ctx, repo, "test", testState{Value: "first"})
+	rtest.OK(t, err)
+	_, err = restic.SaveState(ctx, repo, "other", testState{Value: "other"})
+	rtest.OK(t, err)
+	_, err = restic.SaveState(ctx, repo, "test", testState{Value: "second"})
+	rtest.OK(t, err)
+
+	rtest.OK(t, restic.LoadState(ctx, repo, "test", &st))
+	rtest.Equals(t, "second", st.Value)
+	rtest.OK(t, restic.LoadState(ctx, repo, "other", &st))
+	rtest.Equals(t, "other", st.Value)
+
+	// older versions are removed when saving a new one
+	count := 0
+	rtest.OK(t, repo.List(ctx, restic.StateFile, func(_ restic.ID, _ int64) error {
+		count++
+		return nil
+	}))
+	rtest.Equals(t, 2, count)
+
+	rtest.OK(t, restic.RemoveState(ctx, repo, "test"))
+	err = restic.LoadState(ctx, repo, "test", &st)
+	rtest.Assert(t, err == restic.ErrNoState, "expected ErrNoState, got %v", err)
+}
+
+func TestStateLogTrim(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	for _, value := range []string{"a", "b", "c", "d"} {
+		_, err := restic.AppendState(ctx, repo, "log", testState{Value: value})
+		rtest.OK(t, err)
+	}
+
+	loadLog := func() []string {
+		var values []string
+		rtest.OK(t, restic.LoadStateLog(ctx, repo, "log", func(data json.RawMessage) error {
+			var st testState
+			rtest.OK(t, json.Unmarshal(data, &st))
+			values = append(values, st.Value)
+			return nil
+		}))
+		return values
+	}
+	rtest.Equals(t, []string{"a", "b", "c", "d"}, loadLog())
+
+	// the oldest entries are removed
+	rtest.OK(t, restic.TrimStateLog(ctx, repo, "log", 2))
+	rtest.Equals(t, []string{"c", "d"}, loadLog())
+	rtest.OK(t, restic.TrimStateLog(ctx, repo, "log", 5))
+	rtest.Equals(t, []string{"c", "d"}, loadLog())
+}
+
+func TestStateKindInID(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, restic.FeaturesRepoVersion)
+	ctx := context.TODO()
+
+	first, err := restic.SaveState(ctx, repo, "test", testState{Value: "first"})
+	rtest.OK(t, err)
+	other, err := restic.SaveState(ctx, repo, "other", testState{Value: "other"})
+	rtest.OK(t, err)
+	second, err := restic.SaveState(ctx, repo, "test", testState{Value: "second"})
+	rtest.OK(t, err)
+
+	// the kind can be determined from the ID without loading the file
+	rtest.Equals(t, first[:restic.StateKindSize], second[:restic.StateKindSize])
+	rtest.Assert(t, first != second, "states with different content have the same ID")
+	rtest.Assert(t, !bytes.Equal(first[:restic.StateKindSize], other[:restic.StateKindSize]), "states of different kinds have the same ID prefix")
+
+	// the file content is still verified when loading
+	buf, err := repo.LoadRaw(ctx, restic.StateFile, second)
+	rtest.OK(t, err)
+	rtest.Assert(t, len(buf) > 0, "empty state file")
+}
+
+func TestStateUnsupported(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 2)
+	ctx := context.TODO()
+
+	_, err := restic.SaveState(ctx, repo, "test", testState{Value: "first"})
+	rtest.Assert(t, err == restic.ErrStateUnsupported, "expected ErrStateUnsupported, got %v", err)
+	var st testState
+	err = restic.LoadState(ctx, repo, "test", &st)
+	rtest.Assert(t, err == restic.ErrNoState, "expected ErrNoState, got %v", err)
+	rtest.OK(t, restic.RemoveState(ctx, repo, "test"))
+}
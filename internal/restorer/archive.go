@@ -0,0 +1,53 @@
+package restorer
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// RestoreToArchive sends the items selected by SelectFilter to ch, which is
+// closed afterwards. The Path of each node is set to its location within the
+// snapshot, such that ch can be passed to dump.Dumper.DumpNodes. Only files,
+// directories and symlinks are sent, as other types cannot be stored in an
+// archive. Parent directories which are not selected are not sent.
+func (res *Restorer) RestoreToArchive(ctx context.Context, ch chan<- *restic.Node) error {
+	defer close(ch)
+
+	if res.sn.Tree == nil {
+		return errors.Errorf("snapshot %v has nil tree", res.sn.ID().Str())
+	}
+
+	send := func(node *restic.Node, location string) error {
+		switch node.Type {
+		case restic.NodeTypeFile, restic.NodeTypeDir, restic.NodeTypeSymlink:
+		default:
+			return nil
+		}
+
+		// don't modify the node of the tree
+		n := *node
+		n.Path = filepath.ToSlash(location)
+		select {
+		case ch <- &n:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return res.traverseTree(ctx, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+		enterDir: func(node *restic.Node, _, location string) error {
+			if node == nil {
+				// the root directory of the snapshot
+				return nil
+			}
+			return send(node, location)
+		},
+		visitNode: func(node *restic.Node, _, location string) error {
+			return send(node, location)
+		},
+	})
+}
@@ -0,0 +1,216 @@
+package restorer
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"golang.org/x/sync/errgroup"
+)
+
+// blockDeviceWriteSize is the amount of data written to a block device at
+// once. It is a multiple of all common sector sizes.
+const blockDeviceWriteSize = 4 * 1024 * 1024
+
+// RestoreToBlockDevice writes the content of the single file selected by
+// SelectFilter to the start of the block device dev, for example a disk image
+// created using "backup --stdin". The device must be at least as large as the
+// file. Data is written in sector-aligned blocks, ranges of zeros are zeroed
+// using the device if it supports this. The number of bytes written is
+// returned.
+func (res *Restorer) RestoreToBlockDevice(ctx context.Context, dev string) (uint64, error) {
+	node, err := res.selectImage(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := openBlockDevice(dev)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if uint64(size) < node.Size {
+		return 0, errors.Errorf("%v is too small, it has %d bytes but %v contains %d bytes", dev, size, node.Name, node.Size)
+	}
+
+	sector := blockDeviceSectorSize(f)
+	debug.Log("restoring %v to %v with %d bytes per sector", node.Name, dev, sector)
+	w := newBlockWriter(f, sector)
+
+	type result struct {
+		buf []byte
+		err error
+	}
+	wg, wgCtx := errgroup.WithContext(ctx)
+	// each channel receives one blob, the number of channels in flight limits
+	// the number of concurrently loaded blobs
+	results := make(chan chan result, res.repo.Connections())
+	wg.Go(func() error {
+		defer close(results)
+		for _, id := range node.Content {
+			id := id
+			ch := make(chan result, 1)
+			select {
+			case results <- ch:
+			case <-wgCtx.Done():
+				return wgCtx.Err()
+			}
+			go func() {
+				buf, err := res.repo.LoadBlob(wgCtx, restic.DataBlob, id, nil)
+				ch <- result{buf, err}
+			}()
+		}
+		return nil
+	})
+	wg.Go(func() error {
+		for ch := range results {
+			var r result
+			select {
+			case r = <-ch:
+			case <-wgCtx.Done():
+				return wgCtx.Err()
+			}
+			if r.err != nil {
+				return r.err
+			}
+			if err := w.Write(r.buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := wg.Wait(); err != nil {
+		return 0, err
+	}
+
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	if w.offset != int64(node.Size) {
+		return 0, errors.Errorf("wrote %d bytes, but %v contains %d bytes", w.offset, node.Name, node.Size)
+	}
+	if err := f.Sync(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return node.Size, errors.WithStack(f.Close())
+}
+
+// selectImage returns the only file selected by SelectFilter.
+func (res *Restorer) selectImage(ctx context.Context) (*restic.Node, error) {
+	if res.sn.Tree == nil {
+		return nil, errors.Errorf("snapshot %v has nil tree", res.sn.ID().Str())
+	}
+
+	var image *restic.Node
+	var files []string
+	err := res.traverseTree(ctx, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+		visitNode: func(node *restic.Node, _, location string) error {
+			if node.Type == restic.NodeTypeFile {
+				image = node
+				files = append(files, location)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(files) {
+	case 0:
+		return nil, errors.New("no file was selected, select the image to restore using --include or snapshotID:path")
+	case 1:
+		return image, nil
+	default:
+		return nil, errors.Errorf("%d files were selected, but only a single image can be restored to a block device, for example %v", len(files), files[0])
+	}
+}
+
+// blockWriter writes a stream of data to a block device in sector-aligned
+// blocks.
+type blockWriter struct {
+	f      *os.File
+	sector int
+	// buf contains the data to write at offset
+	buf    []byte
+	offset int64
+	// noZeroRange is set once the device does not support zeroing a range
+	noZeroRange bool
+}
+
+func newBlockWriter(f *os.File, sector int) *blockWriter {
+	size := blockDeviceWriteSize
+	if size%sector != 0 {
+		size = (size/sector + 1) * sector
+	}
+	return &blockWriter{f: f, sector: sector, buf: make([]byte, 0, size)}
+}
+
+// Write buffers p and writes all completed blocks.
+func (w *blockWriter) Write(p []byte) error {
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		if len(w.buf) == cap(w.buf) {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered data. Only the last block of the data may end
+// within a sector.
+func (w *blockWriter) Flush() error {
+	data := w.buf
+	for len(data) > 0 {
+		// find the run of sectors which are all zero or all contain data
+		zero := isZero(data[:min(w.sector, len(data))])
+		n := 0
+		for n < len(data) {
+			end := min(n+w.sector, len(data))
+			if isZero(data[n:end]) != zero {
+				break
+			}
+			n = end
+		}
+
+		if err := w.writeRun(data[:n], zero); err != nil {
+			return err
+		}
+		w.offset += int64(n)
+		data = data[n:]
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *blockWriter) writeRun(data []byte, zero bool) error {
+	if zero && !w.noZeroRange && len(data)%w.sector == 0 {
+		err := zeroRange(w.f, w.offset, int64(len(data)))
+		if err == nil {
+			return nil
+		}
+		debug.Log("zeroing range failed, writing zeros instead: %v", err)
+		w.noZeroRange = true
+	}
+	_, err := w.f.WriteAt(data, w.offset)
+	return errors.WithStack(err)
+}
+
+func isZero(p []byte) bool {
+	return restic.ZeroPrefixLen(p) == len(p)
+}
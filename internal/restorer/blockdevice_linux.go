@@ -0,0 +1,34 @@
+package restorer
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// openBlockDevice opens dev for writing. The kernel refuses to open a block
+// device exclusively while it is mounted.
+func openBlockDevice(dev string) (*os.File, error) {
+	return os.OpenFile(dev, os.O_WRONLY|unix.O_EXCL, 0)
+}
+
+// blockDeviceSectorSize returns the logical sector size of the block device.
+func blockDeviceSectorSize(f *os.File) int {
+	n, err := unix.IoctlGetInt(int(f.Fd()), unix.BLKSSZGET)
+	if err != nil || n <= 0 {
+		return 512
+	}
+	return n
+}
+
+// zeroRange zeroes a sector-aligned range of the block device, without
+// transferring the zeros to the device if it supports this.
+func zeroRange(f *os.File, offset, length int64) error {
+	r := [2]uint64{uint64(offset), uint64(length)}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), unix.BLKZEROOUT, uintptr(unsafe.Pointer(&r)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+package restorer
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// openBlockDevice opens dev for writing.
+func openBlockDevice(dev string) (*os.File, error) {
+	return os.OpenFile(dev, os.O_WRONLY, 0)
+}
+
+// blockDeviceSectorSize returns the logical sector size of the block device.
+func blockDeviceSectorSize(_ *os.File) int {
+	return 512
+}
+
+// zeroRange is not supported on this platform, the zeros are written instead.
+func zeroRange(_ *os.File, _, _ int64) error {
+	return errors.New("zeroing a range is not supported")
+}
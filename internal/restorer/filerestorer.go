@@ -1,8 +1,10 @@
 package restorer
 
 import (
+	"bytes"
 	"context"
 	"path/filepath"
+	"sort"
 	"sync"
 
 	"golang.org/x/sync/errgroup"
@@ -51,13 +53,21 @@ type fileRestorer struct {
 	filesWriter *filesWriter
 	zeroChunk   restic.ID
 	sparse      bool
+	writeOrder  WriteOrder
 	progress    *restore.Progress
 
+	// writeLock ensures that only one pack is written at a time if
+	// writeOrder is WriteOrderSequential
+	writeLock sync.Mutex
+
 	allowRecursiveDelete bool
 
 	dst   string
 	files []*fileInfo
 	Error func(string, error) error
+	// MissingRange is called for each part of a file that could not be
+	// restored, it may be nil
+	MissingRange func(location string, offset, length int64)
 }
 
 func newFileRestorer(dst string,
@@ -65,6 +75,7 @@ func newFileRestorer(dst string,
 	idx func(restic.BlobType, restic.ID) []restic.PackedBlob,
 	connections uint,
 	sparse bool,
+	writeOrder WriteOrder,
 	allowRecursiveDelete bool,
 	progress *restore.Progress) *fileRestorer {
 
@@ -77,6 +88,7 @@ func newFileRestorer(dst string,
 		filesWriter:          newFilesWriter(workerCount, allowRecursiveDelete),
 		zeroChunk:            repository.ZeroChunk(),
 		sparse:               sparse,
+		writeOrder:           writeOrder,
 		progress:             progress,
 		allowRecursiveDelete: allowRecursiveDelete,
 		workerCount:          workerCount,
@@ -292,6 +304,23 @@ func (r *fileRestorer) sanitizeError(file *fileInfo, err error) error {
 	}
 }
 
+// sanitizeRangeError is like sanitizeError, but also reports that length
+// bytes starting at offset are missing in the file.
+func (r *fileRestorer) sanitizeRangeError(file *fileInfo, offset, length int64, err error) error {
+	r.reportMissing(file, offset, length, err)
+	return r.sanitizeError(file, err)
+}
+
+func (r *fileRestorer) reportMissing(file *fileInfo, offset, length int64, err error) {
+	switch err {
+	case nil, context.Canceled, context.DeadlineExceeded:
+		return
+	}
+	if r.MissingRange != nil && length > 0 {
+		r.MissingRange(file.location, offset, length)
+	}
+}
+
 func (r *fileRestorer) reportError(blobs blobToFileOffsetsMapping, processedBlobs restic.BlobSet, err error) error {
 	if err == nil {
 		return nil
@@ -303,8 +332,11 @@ func (r *fileRestorer) reportError(blobs blobToFileOffsetsMapping, processedBlob
 		if processedBlobs.Has(entry.blob.BlobHandle) {
 			continue
 		}
-		for file := range entry.files {
+		for file, offsets := range entry.files {
 			affectedFiles[file] = struct{}{}
+			for _, offset := range offsets {
+				r.reportMissing(file, offset, int64(entry.blob.DataLength()), err)
+			}
 		}
 	}
 
@@ -323,18 +355,26 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 	for _, entry := range blobs {
 		blobList = append(blobList, entry.blob)
 	}
-	return r.blobsLoader(ctx, packID, blobList,
+	var pending []pendingWrite
+	err := r.blobsLoader(ctx, packID, blobList,
 		func(h restic.BlobHandle, blobData []byte, err error) error {
 			processedBlobs.Insert(h)
 			blob := blobs[h.ID]
 			if err != nil {
-				for file := range blob.files {
+				for file, offsets := range blob.files {
+					for _, offset := range offsets {
+						r.reportMissing(file, offset, int64(blob.blob.DataLength()), err)
+					}
 					if errFile := r.sanitizeError(file, err); errFile != nil {
 						return errFile
 					}
 				}
 				return nil
 			}
+			if r.writeOrder == WriteOrderSequential {
+				// the buffer is reused by the blobsLoader
+				blobData = bytes.Clone(blobData)
+			}
 			for file, offsets := range blob.files {
 				for _, offset := range offsets {
 					// avoid long cancelation delays for frequently used blobs
@@ -342,27 +382,11 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 						return ctx.Err()
 					}
 
-					writeToFile := func() error {
-						// this looks overly complicated and needs explanation
-						// two competing requirements:
-						// - must create the file once and only once
-						// - should allow concurrent writes to the file
-						// so write the first blob while holding file lock
-						// write other blobs after releasing the lock
-						createSize := int64(-1)
-						file.lock.Lock()
-						if file.inProgress {
-							file.lock.Unlock()
-						} else {
-							defer file.lock.Unlock()
-							file.inProgress = true
-							createSize = file.size
-						}
-						writeErr := r.filesWriter.writeToFile(r.targetPath(file.location), blobData, offset, createSize, file.sparse)
-						r.reportBlobProgress(file, uint64(len(blobData)))
-						return writeErr
+					if r.writeOrder == WriteOrderSequential {
+						pending = append(pending, pendingWrite{file: file, offset: offset, data: blobData})
+						continue
 					}
-					err := r.sanitizeError(file, writeToFile())
+					err := r.sanitizeRangeError(file, offset, int64(len(blobData)), r.writeBlob(file, blobData, offset))
 					if err != nil {
 						return err
 					}
@@ -370,6 +394,87 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 			}
 			return nil
 		})
+
+	// also write the blobs that were downloaded before an error occurred
+	if errWrite := r.writePending(ctx, pending); errWrite != nil {
+		return errWrite
+	}
+	return err
+}
+
+// pendingWrite is a blob write which is deferred until all blobs of a pack
+// are downloaded.
+type pendingWrite struct {
+	file   *fileInfo
+	offset int64
+	data   []byte
+}
+
+// writePending sorts the writes by file and offset and merges writes to
+// adjacent parts of a file into a single write.
+func (r *fileRestorer) writePending(ctx context.Context, pending []pendingWrite) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].file != pending[j].file {
+			return pending[i].file.location < pending[j].file.location
+		}
+		return pending[i].offset < pending[j].offset
+	})
+
+	r.writeLock.Lock()
+	defer r.writeLock.Unlock()
+
+	for i := 0; i < len(pending); {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		file, offset := pending[i].file, pending[i].offset
+		end := offset + int64(len(pending[i].data))
+		j := i + 1
+		for j < len(pending) && pending[j].file == file && pending[j].offset == end {
+			end += int64(len(pending[j].data))
+			j++
+		}
+
+		data := pending[i].data
+		if j > i+1 {
+			data = make([]byte, 0, end-offset)
+			for _, w := range pending[i:j] {
+				data = append(data, w.data...)
+			}
+		}
+		err := r.sanitizeRangeError(file, offset, int64(len(data)), r.writeBlob(file, data, offset))
+		if err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+func (r *fileRestorer) writeBlob(file *fileInfo, blobData []byte, offset int64) error {
+	// this looks overly complicated and needs explanation
+	// two competing requirements:
+	// - must create the file once and only once
+	// - should allow concurrent writes to the file
+	// so write the first blob while holding file lock
+	// write other blobs after releasing the lock
+	createSize := int64(-1)
+	file.lock.Lock()
+	if file.inProgress {
+		file.lock.Unlock()
+	} else {
+		defer file.lock.Unlock()
+		file.inProgress = true
+		createSize = file.size
+	}
+	writeErr := r.filesWriter.writeToFile(r.targetPath(file.location), blobData, offset, createSize, file.sparse)
+	r.reportBlobProgress(file, uint64(len(blobData)))
+	return writeErr
 }
 
 func (r *fileRestorer) reportBlobProgress(file *fileInfo, blobSize uint64) {
@@ -144,7 +144,7 @@ func restoreAndVerify(t *testing.T, tempdir string, content []TestFile, files ma
 	t.Helper()
 	repo := newTestRepo(content)
 
-	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 2, sparse, false, nil)
+	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 2, sparse, WriteOrderAny, false, nil)
 
 	if files == nil {
 		r.files = repo.files
@@ -285,7 +285,7 @@ func TestErrorRestoreFiles(t *testing.T) {
 		return loadError
 	}
 
-	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 2, false, false, nil)
+	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 2, false, WriteOrderAny, false, nil)
 	r.files = repo.files
 
 	err := r.restoreFiles(context.TODO())
@@ -326,7 +326,7 @@ func TestFatalDownloadError(t *testing.T) {
 		})
 	}
 
-	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 2, false, false, nil)
+	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 2, false, WriteOrderAny, false, nil)
 	r.files = repo.files
 
 	var errors []string
@@ -335,10 +335,91 @@ func TestFatalDownloadError(t *testing.T) {
 		errors = append(errors, s)
 		return nil
 	}
+	var missing []string
+	r.MissingRange = func(location string, offset, length int64) {
+		missing = append(missing, fmt.Sprintf("%v:%d+%d", location, offset, length))
+	}
 
 	err := r.restoreFiles(context.TODO())
 	rtest.OK(t, err)
 
 	rtest.Assert(t, len(errors) == 1, "unexpected number of restore errors, expected: 1, got: %v", len(errors))
 	rtest.Assert(t, errors[0] == "file2", "expected error for file2, got: %v", errors[0])
+	sort.Strings(missing)
+	rtest.Equals(t, []string{"file2:0+7", "file2:14+7", "file2:7+7"}, missing)
+}
+
+func TestFileRestorerSequentialWriteOrder(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	content := []TestFile{
+		{
+			name: "file1",
+			blobs: []TestBlob{
+				{"data1-1", "pack1"},
+				{"data1-2", "pack1"},
+				{"data1-3", "pack2"},
+				{"data1-4", "pack1"},
+			},
+		},
+		{
+			name: "file2",
+			blobs: []TestBlob{
+				{"data2-1", "pack2"},
+				{"data1-2", "pack1"},
+				{"data2-3", "pack1"},
+			},
+		},
+	}
+
+	repo := newTestRepo(content)
+	loader := repo.loader
+	repo.loader = func(ctx context.Context, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+		// return blobs in reverse order and reuse the buffer like the repository
+		reversed := make([]restic.Blob, len(blobs))
+		for i, blob := range blobs {
+			reversed[len(blobs)-1-i] = blob
+		}
+		var buf []byte
+		return loader(ctx, packID, reversed, func(blob restic.BlobHandle, data []byte, err error) error {
+			buf = append(buf[:0], data...)
+			err = handleBlobFn(blob, buf, err)
+			for i := range buf {
+				buf[i] = 'x'
+			}
+			return err
+		})
+	}
+
+	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 2, false, WriteOrderSequential, false, nil)
+	r.files = repo.files
+	rtest.OK(t, r.restoreFiles(context.TODO()))
+
+	for _, file := range repo.files {
+		data, err := os.ReadFile(r.targetPath(file.location))
+		rtest.OK(t, err)
+		rtest.Equals(t, repo.fileContent(file), string(data))
+	}
+}
+
+func TestWritePending(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	r := newFileRestorer(tempdir, nil, nil, 2, false, WriteOrderSequential, false, nil)
+
+	file1 := &fileInfo{location: "file1", size: 9}
+	file2 := &fileInfo{location: "file2", size: 4}
+	pending := []pendingWrite{
+		{file: file1, offset: 6, data: []byte("ghi")},
+		{file: file2, offset: 2, data: []byte("cd")},
+		{file: file1, offset: 0, data: []byte("abc")},
+		{file: file2, offset: 0, data: []byte("ab")},
+		{file: file1, offset: 3, data: []byte("def")},
+	}
+	rtest.OK(t, r.writePending(context.TODO(), pending))
+
+	data, err := os.ReadFile(r.targetPath("file1"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "abcdefghi", string(data))
+	data, err = os.ReadFile(r.targetPath("file2"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "abcd", string(data))
 }
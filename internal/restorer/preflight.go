@@ -0,0 +1,52 @@
+package restorer
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Preflight checks whether the items selected by SelectFilter could be
+// restored to dst with all of their metadata, without restoring any data.
+// For each item that would fail, report is called with its location within
+// the snapshot and a description of each problem. The number of such items
+// is returned.
+func (res *Restorer) Preflight(ctx context.Context, dst string, report func(location string, problems []string)) (int, error) {
+	if res.sn.Tree == nil {
+		return 0, errors.Errorf("snapshot %v has nil tree", res.sn.ID().Str())
+	}
+
+	probe, err := fs.NewMetadataProbe(dst)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = probe.Close()
+	}()
+
+	failed := 0
+	check := func(node *restic.Node, location string) error {
+		if problems := probe.Check(node); len(problems) > 0 {
+			failed++
+			report(location, problems)
+		}
+		return nil
+	}
+
+	err = res.traverseTree(ctx, dst, *res.sn.Tree, treeVisitor{
+		visitNode: func(node *restic.Node, _, location string) error {
+			return check(node, location)
+		},
+		// directories receive their metadata when they are left, this
+		// includes unselected parents of selected items
+		leaveDir: func(node *restic.Node, _, location string, _ []string) error {
+			if node == nil {
+				return nil
+			}
+			return check(node, location)
+		},
+	})
+	return failed, err
+}
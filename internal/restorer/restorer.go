@@ -2,10 +2,12 @@ package restorer
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync/atomic"
 
 	"github.com/restic/restic/internal/debug"
@@ -26,8 +28,17 @@ type Restorer struct {
 
 	fileList map[string]bool
 
+	// skipADS and skipSecurityDescriptors are set if the restore target
+	// cannot store alternate data streams or security descriptors.
+	skipADS                 bool
+	skipSecurityDescriptors bool
+	warnedADS               bool
+
 	Error func(location string, err error) error
-	Warn  func(message string)
+	// MissingRange is called in addition to Error for each part of a file
+	// which could not be restored. It may be called concurrently.
+	MissingRange func(location string, offset, length int64)
+	Warn         func(message string)
 	// SelectFilter determines whether the item is selectedForRestore or whether a childMayBeSelected.
 	// selectedForRestore must not depend on isDir as `removeUnexpectedFiles` always passes false to isDir.
 	SelectFilter func(item string, isDir bool) (selectedForRestore bool, childMayBeSelected bool)
@@ -36,11 +47,18 @@ type Restorer struct {
 var restorerAbortOnAllErrors = func(_ string, err error) error { return err }
 
 type Options struct {
-	DryRun    bool
-	Sparse    bool
-	Progress  *restoreui.Progress
-	Overwrite OverwriteBehavior
-	Delete    bool
+	DryRun         bool
+	Sparse         bool
+	Progress       *restoreui.Progress
+	Overwrite      OverwriteBehavior
+	Delete         bool
+	CaseCollisions CaseCollisionBehavior
+	WriteOrder     WriteOrder
+	NoHardlinks    bool
+	// ExcludeADS skips restoring alternate data streams of files on Windows.
+	ExcludeADS bool
+	// NoWindowsACLs skips restoring security descriptors on Windows.
+	NoWindowsACLs bool
 }
 
 type OverwriteBehavior int
@@ -94,6 +112,103 @@ func (c *OverwriteBehavior) Type() string {
 	return "behavior"
 }
 
+// CaseCollisionBehavior determines how items are restored whose name only
+// differs in case from an item which was restored before in the same
+// directory. On case-insensitive file systems, such items would overwrite
+// each other.
+type CaseCollisionBehavior int
+
+// Constants for different case collision behavior
+const (
+	// CaseCollisionKeep restores all items using their original name.
+	CaseCollisionKeep CaseCollisionBehavior = iota
+	// CaseCollisionRename restores colliding items using a new name, which
+	// contains a counter, for example "README (case collision 1).md".
+	CaseCollisionRename
+	// CaseCollisionSkip only restores the first of the colliding items.
+	CaseCollisionSkip
+	CaseCollisionInvalid
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (c *CaseCollisionBehavior) Set(s string) error {
+	switch s {
+	case "keep":
+		*c = CaseCollisionKeep
+	case "rename":
+		*c = CaseCollisionRename
+	case "skip":
+		*c = CaseCollisionSkip
+	default:
+		*c = CaseCollisionInvalid
+		return fmt.Errorf("invalid case collision behavior %q, must be one of (keep|rename|skip)", s)
+	}
+
+	return nil
+}
+
+func (c *CaseCollisionBehavior) String() string {
+	switch *c {
+	case CaseCollisionKeep:
+		return "keep"
+	case CaseCollisionRename:
+		return "rename"
+	case CaseCollisionSkip:
+		return "skip"
+	default:
+		return "invalid"
+	}
+}
+
+func (c *CaseCollisionBehavior) Type() string {
+	return "behavior"
+}
+
+// WriteOrder determines in which order the restorer writes the blobs of a
+// pack to the target files.
+type WriteOrder int
+
+// Constants for different write orders
+const (
+	// WriteOrderAny writes blobs as soon as they are downloaded.
+	WriteOrderAny WriteOrder = iota
+	// WriteOrderSequential collects the blobs of a pack, sorts them by file
+	// and offset and merges adjacent blobs into a single write. Only one pack
+	// is written at a time. This reduces the seek load on spinning disks.
+	WriteOrderSequential
+	WriteOrderInvalid
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (c *WriteOrder) Set(s string) error {
+	switch s {
+	case "any":
+		*c = WriteOrderAny
+	case "sequential":
+		*c = WriteOrderSequential
+	default:
+		*c = WriteOrderInvalid
+		return fmt.Errorf("invalid write order %q, must be one of (any|sequential)", s)
+	}
+
+	return nil
+}
+
+func (c *WriteOrder) String() string {
+	switch *c {
+	case WriteOrderAny:
+		return "any"
+	case WriteOrderSequential:
+		return "sequential"
+	default:
+		return "invalid"
+	}
+}
+
+func (c *WriteOrder) Type() string {
+	return "order"
+}
+
 // NewRestorer creates a restorer preloaded with the content from the snapshot id.
 func NewRestorer(repo restic.Repository, sn *restic.Snapshot, opts Options) *Restorer {
 	r := &Restorer{
@@ -114,6 +229,9 @@ type treeVisitor struct {
 	// 'entries' contains all files the snapshot contains for this node. This also includes files
 	// ignored by the SelectFilter.
 	leaveDir func(node *restic.Node, target, location string, entries []string) error
+	// caseCollision is called for items whose name only differs in case from an item
+	// visited before in the same directory. 'location' is the path within the snapshot.
+	caseCollision func(node *restic.Node, location string)
 }
 
 func (res *Restorer) sanitizeError(location string, err error) error {
@@ -137,7 +255,7 @@ func (res *Restorer) traverseTree(ctx context.Context, target string, treeID res
 			return err
 		}
 	}
-	childFilenames, hasRestored, err := res.traverseTreeInner(ctx, target, location, treeID, visitor)
+	childFilenames, hasRestored, err := res.traverseTreeInner(ctx, target, location, location, treeID, visitor)
 	if err != nil {
 		return err
 	}
@@ -148,7 +266,10 @@ func (res *Restorer) traverseTree(ctx context.Context, target string, treeID res
 	return err
 }
 
-func (res *Restorer) traverseTreeInner(ctx context.Context, target, location string, treeID restic.ID, visitor treeVisitor) (filenames []string, hasRestored bool, err error) {
+// traverseTreeInner traverses the tree treeID below the directory target. location is the path
+// of the directory relative to the restore target, snLocation is its path within the snapshot. Both
+// only differ if an item was renamed due to a case collision.
+func (res *Restorer) traverseTreeInner(ctx context.Context, target, location, snLocation string, treeID restic.ID, visitor treeVisitor) (filenames []string, hasRestored bool, err error) {
 	debug.Log("%v %v %v", target, location, treeID)
 	tree, err := restic.LoadTree(ctx, res.repo, treeID)
 	if err != nil {
@@ -159,6 +280,17 @@ func (res *Restorer) traverseTreeInner(ctx context.Context, target, location str
 	if res.opts.Delete {
 		filenames = make([]string, 0, len(tree.Nodes))
 	}
+
+	// names of all items in the tree, used to pick unique names when renaming items
+	var allNames map[string]struct{}
+	if res.opts.CaseCollisions == CaseCollisionRename {
+		allNames = make(map[string]struct{}, len(tree.Nodes))
+		for _, node := range tree.Nodes {
+			allNames[fs.FoldCase(node.Name)] = struct{}{}
+		}
+	}
+	restoredNames := make(map[string]struct{}, len(tree.Nodes))
+
 	for i, node := range tree.Nodes {
 		if ctx.Err() != nil {
 			return nil, hasRestored, ctx.Err()
@@ -188,6 +320,7 @@ func (res *Restorer) traverseTreeInner(ctx context.Context, target, location str
 
 		nodeTarget := filepath.Join(target, nodeName)
 		nodeLocation := filepath.Join(location, nodeName)
+		nodeSnLocation := filepath.Join(snLocation, nodeName)
 
 		if target == nodeTarget || !fs.HasPathPrefix(target, nodeTarget) {
 			debug.Log("target: %v %v", target, nodeTarget)
@@ -206,8 +339,40 @@ func (res *Restorer) traverseTreeInner(ctx context.Context, target, location str
 			continue
 		}
 
-		selectedForRestore, childMayBeSelected := res.SelectFilter(nodeLocation, node.Type == restic.NodeTypeDir)
-		debug.Log("SelectFilter returned %v %v for %q", selectedForRestore, childMayBeSelected, nodeLocation)
+		if res.skipADS && node.IsAlternateDataStream() {
+			if !res.opts.ExcludeADS && !res.warnedADS && res.Warn != nil {
+				res.warnedADS = true
+				res.Warn("the target file system does not support alternate data streams, skipping them")
+			}
+			continue
+		}
+
+		selectedForRestore, childMayBeSelected := res.SelectFilter(nodeSnLocation, node.Type == restic.NodeTypeDir)
+		debug.Log("SelectFilter returned %v %v for %q", selectedForRestore, childMayBeSelected, nodeSnLocation)
+
+		if selectedForRestore || (node.Type == restic.NodeTypeDir && childMayBeSelected) {
+			key := fs.FoldCase(nodeName)
+			if _, ok := restoredNames[key]; ok {
+				debug.Log("node %q collides with another node on case-insensitive file systems", nodeSnLocation)
+				if visitor.caseCollision != nil {
+					visitor.caseCollision(node, nodeSnLocation)
+				}
+
+				switch res.opts.CaseCollisions {
+				case CaseCollisionSkip:
+					continue
+				case CaseCollisionRename:
+					nodeName = caseCollisionName(nodeName, allNames)
+					key = fs.FoldCase(nodeName)
+					nodeTarget = filepath.Join(target, nodeName)
+					nodeLocation = filepath.Join(location, nodeName)
+					if res.opts.Delete {
+						filenames = append(filenames, nodeName)
+					}
+				}
+			}
+			restoredNames[key] = struct{}{}
+		}
 
 		if selectedForRestore {
 			hasRestored = true
@@ -231,7 +396,7 @@ func (res *Restorer) traverseTreeInner(ctx context.Context, target, location str
 			var childFilenames []string
 
 			if childMayBeSelected {
-				childFilenames, childHasRestored, err = res.traverseTreeInner(ctx, nodeTarget, nodeLocation, *node.Subtree, visitor)
+				childFilenames, childHasRestored, err = res.traverseTreeInner(ctx, nodeTarget, nodeLocation, nodeSnLocation, *node.Subtree, visitor)
 				err = res.sanitizeError(nodeLocation, err)
 				if err != nil {
 					return nil, hasRestored, err
@@ -265,6 +430,26 @@ func (res *Restorer) traverseTreeInner(ctx context.Context, target, location str
 	return filenames, hasRestored, nil
 }
 
+// caseCollisionName returns a name for an item which collides with another item
+// on case-insensitive file systems. The name must not collide with any of the
+// names in allNames, which is updated to contain the new name.
+func caseCollisionName(name string, allNames map[string]struct{}) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		// hidden files like ".profile" have no extension
+		base, ext = name, ""
+	}
+	for i := 1; ; i++ {
+		newName := fmt.Sprintf("%s (case collision %d)%s", base, i, ext)
+		key := fs.FoldCase(newName)
+		if _, ok := allNames[key]; !ok {
+			allNames[key] = struct{}{}
+			return newName
+		}
+	}
+}
+
 func (res *Restorer) restoreNodeTo(node *restic.Node, target, location string) error {
 	if !res.opts.DryRun {
 		debug.Log("restoreNode %v %v %v", node.Name, target, location)
@@ -288,6 +473,9 @@ func (res *Restorer) restoreNodeMetadataTo(node *restic.Node, target, location s
 		return nil
 	}
 	debug.Log("restoreNodeMetadata %v %v %v", node.Name, target, location)
+	if res.skipSecurityDescriptors {
+		node = withoutSecurityDescriptor(node)
+	}
 	err := fs.NodeRestoreMetadata(node, target, res.Warn)
 	if err != nil {
 		debug.Log("node.RestoreMetadata(%s) error %v", target, err)
@@ -295,6 +483,22 @@ func (res *Restorer) restoreNodeMetadataTo(node *restic.Node, target, location s
 	return err
 }
 
+// withoutSecurityDescriptor returns a copy of node which does not contain a
+// security descriptor.
+func withoutSecurityDescriptor(node *restic.Node) *restic.Node {
+	if _, ok := node.GenericAttributes[restic.TypeSecurityDescriptor]; !ok {
+		return node
+	}
+	n := *node
+	n.GenericAttributes = make(map[restic.GenericAttributeType]json.RawMessage, len(node.GenericAttributes))
+	for k, v := range node.GenericAttributes {
+		if k != restic.TypeSecurityDescriptor {
+			n.GenericAttributes[k] = v
+		}
+	}
+	return &n
+}
+
 func (res *Restorer) restoreHardlinkAt(node *restic.Node, target, path, location string) error {
 	if !res.opts.DryRun {
 		if err := fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -352,15 +556,22 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 		}
 	}
 
+	res.skipADS = res.opts.ExcludeADS || !fs.SupportsAlternateDataStreams(dst)
+	res.skipSecurityDescriptors = res.opts.NoWindowsACLs || !fs.SupportsSecurityDescriptors(dst)
+	debug.Log("skip alternate data streams %v, skip security descriptors %v", res.skipADS, res.skipSecurityDescriptors)
+
 	idx := NewHardlinkIndex[string]()
 	filerestorer := newFileRestorer(dst, res.repo.LoadBlobsFromPack, res.repo.LookupBlob,
-		res.repo.Connections(), res.opts.Sparse, res.opts.Delete, res.opts.Progress)
+		res.repo.Connections(), res.opts.Sparse, res.opts.WriteOrder, res.opts.Delete, res.opts.Progress)
 	filerestorer.Error = res.Error
+	filerestorer.MissingRange = res.MissingRange
 
 	debug.Log("first pass for %q", dst)
 
 	var buf []byte
 
+	var caseCollisions uint64
+
 	// first tree pass: create directories and collect all files to restore
 	err = res.traverseTree(ctx, dst, *res.sn.Tree, treeVisitor{
 		enterDir: func(_ *restic.Node, target, location string) error {
@@ -371,6 +582,24 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 			return res.ensureDir(target)
 		},
 
+		caseCollision: func(_ *restic.Node, location string) {
+			res.opts.Progress.ReportCaseCollision()
+			caseCollisions++
+			if res.Warn == nil {
+				return
+			}
+			switch res.opts.CaseCollisions {
+			case CaseCollisionRename:
+				res.Warn(fmt.Sprintf("%v: name only differs in case from another item, restoring it using a different name", location))
+			case CaseCollisionSkip:
+				res.Warn(fmt.Sprintf("%v: name only differs in case from another item, skipping it", location))
+			default:
+				// all items are restored, a single warning after the first
+				// pass is sufficient
+				debug.Log("%v: name only differs in case from another item", location)
+			}
+		},
+
 		visitNode: func(node *restic.Node, target, location string) error {
 			debug.Log("first pass, visitNode: mkdir %q, leaveDir on second pass should restore metadata", location)
 			if err := res.ensureDir(filepath.Dir(target)); err != nil {
@@ -382,7 +611,7 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 				return nil
 			}
 
-			if node.Links > 1 {
+			if node.Links > 1 && !res.opts.NoHardlinks {
 				if idx.Has(node.Inode, node.DeviceID) {
 					// a hardlinked file does not increase the restore size
 					res.opts.Progress.AddFile(0)
@@ -420,6 +649,10 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 		return 0, err
 	}
 
+	if caseCollisions > 0 && res.opts.CaseCollisions == CaseCollisionKeep && res.Warn != nil {
+		res.Warn(fmt.Sprintf("%d items only differ in case from another item in the same directory, they overwrite each other on case-insensitive file systems. Use --case-collisions rename or skip to avoid this", caseCollisions))
+	}
+
 	if !res.opts.DryRun {
 		err = filerestorer.restoreFiles(ctx)
 		if err != nil {
@@ -513,11 +746,17 @@ func (res *Restorer) removeUnexpectedFiles(ctx context.Context, target, location
 		if selectedForRestore {
 			// First collect all files that will be deleted
 			var filesToDelete []string
-			err := filepath.Walk(nodeTarget, func(path string, _ os.FileInfo, err error) error {
+			var sizes []uint64
+			err := filepath.Walk(nodeTarget, func(path string, fi os.FileInfo, err error) error {
 				if err != nil {
 					return err
 				}
 				filesToDelete = append(filesToDelete, path)
+				var size uint64
+				if fi.Mode().IsRegular() {
+					size = uint64(fi.Size())
+				}
+				sizes = append(sizes, size)
 				return nil
 			})
 			if err != nil {
@@ -533,7 +772,7 @@ func (res *Restorer) removeUnexpectedFiles(ctx context.Context, target, location
 
 			// Report paths as deleted only after successful removal
 			for i := len(filesToDelete) - 1; i >= 0; i-- {
-				res.opts.Progress.ReportDeletion(filesToDelete[i])
+				res.opts.Progress.ReportDeletion(filesToDelete[i], sizes[i])
 			}
 		}
 	}
@@ -1347,6 +1347,40 @@ func TestRestoreDryRun(t *testing.T) {
 	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "expected no file to be created, got %v", err)
 }
 
+func TestRestorePreflight(t *testing.T) {
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "content: foo\n"},
+			"dirtest": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n"},
+				},
+			},
+			"link": Symlink{Target: "foo"},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	parent := rtest.TempDir(t)
+	tempdir := filepath.Join(parent, "target")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+	res := NewRestorer(repo, sn, Options{})
+	failed, err := res.Preflight(ctx, tempdir, func(location string, problems []string) {
+		t.Errorf("unexpected problems for %v: %v", location, problems)
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, failed)
+
+	// neither the target nor the probe directory must be left behind
+	entries, err := os.ReadDir(parent)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(entries))
+}
+
 func TestRestoreDryRunDelete(t *testing.T) {
 	snapshot := Snapshot{
 		Nodes: map[string]Node{
@@ -1361,17 +1395,20 @@ func TestRestoreDryRunDelete(t *testing.T) {
 	defer cancel()
 
 	rtest.OK(t, os.Mkdir(tempdir, 0o755))
-	f, err := os.Create(tempfile)
-	rtest.OK(t, err)
-	rtest.OK(t, f.Close())
+	rtest.OK(t, os.WriteFile(tempfile, []byte("content: existing\n"), 0o644))
 
 	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
-	res := NewRestorer(repo, sn, Options{DryRun: true, Delete: true})
-	_, err = res.RestoreTo(ctx, tempdir)
+	mock := &printerMock{}
+	progress := restoreui.NewProgress(mock, 0)
+	res := NewRestorer(repo, sn, Options{DryRun: true, Delete: true, Progress: progress})
+	_, err := res.RestoreTo(ctx, tempdir)
 	rtest.OK(t, err)
+	progress.Finish()
 
 	_, err = os.Stat(tempfile)
 	rtest.Assert(t, err == nil, "expected file to still exist, got error %v", err)
+	rtest.Equals(t, uint64(1), mock.s.FilesDeleted)
+	rtest.Equals(t, uint64(len("content: existing\n")), mock.s.AllBytesDeleted)
 }
 
 func TestRestoreOverwriteDirectory(t *testing.T) {
@@ -1563,3 +1600,319 @@ func TestRestorerLongPath(t *testing.T) {
 	_, err = res.VerifyFiles(ctx, tmp, countRestoredFiles, nil)
 	rtest.OK(t, err)
 }
+
+func TestRestoreToSnapshot(t *testing.T) {
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "content: foo\n"},
+			"bar": File{Data: "content: bar\n"},
+			"dirtest": Dir{
+				Nodes: map[string]Node{
+					"file":  File{Data: "content: file\n"},
+					"other": File{Data: "content: other\n"},
+				},
+			},
+			"unselected": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n"},
+				},
+			},
+			"selected": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n"},
+				},
+			},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+	res := NewRestorer(repo, sn, Options{})
+	res.SelectFilter = func(item string, isDir bool) (selectedForRestore bool, childMayBeSelected bool) {
+		switch filepath.ToSlash(item) {
+		case "/foo", "/dirtest/file":
+			return true, false
+		case "/dirtest", "/unselected":
+			return false, true
+		case "/selected":
+			return true, false
+		}
+		return false, false
+	}
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	repo.StartPackUploader(wgCtx, wg)
+	treeID, err := res.RestoreToSnapshot(ctx)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(ctx))
+
+	tree, err := restic.LoadTree(ctx, repo, treeID)
+	rtest.OK(t, err)
+	var names []string
+	for _, node := range tree.Nodes {
+		names = append(names, node.Name)
+	}
+	rtest.Equals(t, []string{"dirtest", "foo", "selected"}, names)
+
+	subtree, err := restic.LoadTree(ctx, repo, *tree.Nodes[0].Subtree)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(subtree.Nodes))
+	rtest.Equals(t, "file", subtree.Nodes[0].Name)
+
+	subtree, err = restic.LoadTree(ctx, repo, *tree.Nodes[2].Subtree)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(subtree.Nodes))
+
+	// nothing selected
+	res.SelectFilter = func(string, bool) (bool, bool) { return false, false }
+	treeID, err = res.RestoreToSnapshot(ctx)
+	rtest.OK(t, err)
+	rtest.Assert(t, treeID.IsNull(), "expected null tree, got %v", treeID)
+}
+
+func TestRestoreCaseCollisions(t *testing.T) {
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"README":    File{Data: "content: README"},
+			"Readme":    File{Data: "content: Readme"},
+			"other.txt": File{Data: "content: other"},
+			"readme": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file"},
+				},
+			},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					".profile": File{Data: "content: profile"},
+					".PROFILE": File{Data: "content: PROFILE"},
+				},
+			},
+		},
+	}
+
+	var tests = []struct {
+		CaseCollisions CaseCollisionBehavior
+		Files          map[string]string
+		Missing        []string
+		Warnings       int
+	}{
+		{
+			CaseCollisions: CaseCollisionKeep,
+			Files: map[string]string{
+				"other.txt": "content: other",
+			},
+			Warnings: 1,
+		},
+		{
+			CaseCollisions: CaseCollisionRename,
+			Files: map[string]string{
+				"README":                          "content: README",
+				"Readme (case collision 1)":       "content: Readme",
+				"readme (case collision 2)/file":  "content: file",
+				"other.txt":                       "content: other",
+				"dir/.PROFILE":                    "content: PROFILE",
+				"dir/.profile (case collision 1)": "content: profile",
+			},
+			Missing:  []string{"Readme", "readme", "dir/.profile"},
+			Warnings: 3,
+		},
+		{
+			CaseCollisions: CaseCollisionSkip,
+			Files: map[string]string{
+				"README":       "content: README",
+				"other.txt":    "content: other",
+				"dir/.PROFILE": "content: PROFILE",
+			},
+			Missing:  []string{"Readme", "readme", "dir/.profile"},
+			Warnings: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.CaseCollisions.String(), func(t *testing.T) {
+			repo := repository.TestRepository(t)
+			sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+			tempdir := rtest.TempDir(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mock := &printerMock{}
+			progress := restoreui.NewProgress(mock, 0)
+			var warnings []string
+			res := NewRestorer(repo, sn, Options{CaseCollisions: test.CaseCollisions, Progress: progress})
+			res.Warn = func(message string) {
+				warnings = append(warnings, message)
+			}
+			countRestoredFiles, err := res.RestoreTo(ctx, tempdir)
+			rtest.OK(t, err)
+			progress.Finish()
+			rtest.Equals(t, uint64(3), mock.s.CaseCollisions)
+			rtest.Equals(t, test.Warnings, len(warnings))
+
+			_, err = res.VerifyFiles(ctx, tempdir, countRestoredFiles, nil)
+			rtest.OK(t, err)
+
+			// renamed items are not deleted
+			res = NewRestorer(repo, sn, Options{CaseCollisions: test.CaseCollisions, Delete: true})
+			_, err = res.RestoreTo(ctx, tempdir)
+			rtest.OK(t, err)
+
+			for filename, content := range test.Files {
+				data, err := os.ReadFile(filepath.Join(tempdir, filepath.FromSlash(filename)))
+				rtest.OK(t, err)
+				rtest.Equals(t, content, string(data))
+			}
+			// list the directories as a lookup would succeed on case-insensitive file systems
+			for _, filename := range test.Missing {
+				dir, name := filepath.Split(filepath.Join(tempdir, filepath.FromSlash(filename)))
+				names, err := fs.Readdirnames(fs.Local{}, dir, fs.O_NOFOLLOW)
+				rtest.OK(t, err)
+				for _, n := range names {
+					rtest.Assert(t, n != name, "expected %v to be missing", filename)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreSkipsAlternateDataStreams(t *testing.T) {
+	if fs.SupportsAlternateDataStreams(rtest.TempDir(t)) {
+		t.Skip("the file system supports alternate data streams")
+	}
+
+	// nodes with attributes are marked as alternate data streams
+	markStreams := func(attr *FileAttributes, _ bool) map[restic.GenericAttributeType]json.RawMessage {
+		if attr == nil {
+			return nil
+		}
+		return map[restic.GenericAttributeType]json.RawMessage{restic.TypeAlternateDataStream: json.RawMessage("true")}
+	}
+
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"foo":        File{Data: "content: foo"},
+			"foo:stream": File{Data: "content: stream", attributes: &FileAttributes{}},
+		},
+	}, markStreams)
+
+	for _, excludeADS := range []bool{false, true} {
+		tempdir := rtest.TempDir(t)
+		res := NewRestorer(repo, sn, Options{ExcludeADS: excludeADS})
+		var warnings []string
+		res.Warn = func(message string) {
+			warnings = append(warnings, message)
+		}
+		_, err := res.RestoreTo(context.TODO(), tempdir)
+		rtest.OK(t, err)
+
+		data, err := os.ReadFile(filepath.Join(tempdir, "foo"))
+		rtest.OK(t, err)
+		rtest.Equals(t, "content: foo", string(data))
+		_, err = os.Lstat(filepath.Join(tempdir, "foo:stream"))
+		rtest.Assert(t, errors.Is(err, os.ErrNotExist), "alternate data stream was restored: %v", err)
+
+		// only unsupported streams are reported, excluded ones are expected to be missing
+		if excludeADS {
+			rtest.Equals(t, 0, len(warnings))
+		} else {
+			rtest.Equals(t, 1, len(warnings))
+		}
+	}
+}
+
+func TestWithoutSecurityDescriptor(t *testing.T) {
+	node := &restic.Node{
+		Name: "foo",
+		GenericAttributes: map[restic.GenericAttributeType]json.RawMessage{
+			restic.TypeSecurityDescriptor: json.RawMessage(`"AQAEgA=="`),
+			restic.TypeFileAttributes:     json.RawMessage("32"),
+		},
+	}
+
+	stripped := withoutSecurityDescriptor(node)
+	rtest.Equals(t, map[restic.GenericAttributeType]json.RawMessage{
+		restic.TypeFileAttributes: json.RawMessage("32"),
+	}, stripped.GenericAttributes)
+	// the original node must not be modified
+	rtest.Equals(t, 2, len(node.GenericAttributes))
+
+	plain := &restic.Node{Name: "bar"}
+	rtest.Assert(t, withoutSecurityDescriptor(plain) == plain, "node without security descriptor was copied")
+}
+
+func TestRestoreToBlockDevice(t *testing.T) {
+	zeros := string(make([]byte, 3*blockDeviceWriteSize/2))
+	parts := []string{"first part\n", zeros, "middle\n", zeros, "last part"}
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"disk.img": File{DataParts: parts},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"other": File{Data: "other\n"},
+				},
+			},
+		},
+	}
+	expected := strings.Join(parts, "")
+
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dev := filepath.Join(rtest.TempDir(t), "dev")
+	rtest.OK(t, os.WriteFile(dev, bytes.Repeat([]byte{0xff}, len(expected)+4096), 0600))
+
+	res := NewRestorer(repo, sn, Options{})
+	_, err := res.RestoreToBlockDevice(ctx, dev)
+	rtest.Assert(t, err != nil, "expected error for multiple selected files")
+
+	res.SelectFilter = func(item string, isDir bool) (bool, bool) {
+		return item == "/disk.img", false
+	}
+	n, err := res.RestoreToBlockDevice(ctx, dev)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(len(expected)), n)
+
+	data, err := os.ReadFile(dev)
+	rtest.OK(t, err)
+	rtest.Equals(t, len(expected)+4096, len(data))
+	rtest.Assert(t, string(data[:len(expected)]) == expected, "restored data differs")
+	// data after the image must not be modified
+	rtest.Assert(t, bytes.Equal(data[len(expected):], bytes.Repeat([]byte{0xff}, 4096)), "data after the image was modified")
+
+	// the device must be large enough for the image
+	rtest.OK(t, os.Truncate(dev, int64(len(expected)-1)))
+	_, err = res.RestoreToBlockDevice(ctx, dev)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "too small"), "unexpected error %v", err)
+}
+
+func TestBlockWriterSectors(t *testing.T) {
+	dev := filepath.Join(rtest.TempDir(t), "dev")
+	rtest.OK(t, os.WriteFile(dev, bytes.Repeat([]byte{0xff}, 4000), 0600))
+	f, err := os.OpenFile(dev, os.O_WRONLY, 0)
+	rtest.OK(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+
+	w := newBlockWriter(f, 1000)
+	rtest.Equals(t, 0, cap(w.buf)%1000)
+	// a zero sector, a sector with data followed by a partial zero sector
+	data := make([]byte, 2500)
+	data[1500] = 1
+	rtest.OK(t, w.Write(data[:700]))
+	rtest.OK(t, w.Write(data[700:]))
+	rtest.OK(t, w.Flush())
+	rtest.Equals(t, int64(2500), w.offset)
+
+	buf, err := os.ReadFile(dev)
+	rtest.OK(t, err)
+	expected := append(data, bytes.Repeat([]byte{0xff}, 1500)...)
+	rtest.Assert(t, bytes.Equal(expected, buf), "unexpected device content")
+}
@@ -5,6 +5,7 @@ package restorer
 
 import (
 	"context"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -142,3 +143,33 @@ func TestRestorePermissions(t *testing.T) {
 		rtest.Equals(t, fs.FileMode(0o600), fi.Mode().Perm(), "unexpected permissions")
 	}
 }
+
+func TestRestorerNoHardlinks(t *testing.T) {
+	repo := repository.TestRepository(t)
+
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"file1": File{Data: "content", Links: 2, Inode: 1},
+			"file2": File{Data: "content", Links: 2, Inode: 1},
+		},
+	}, noopGetGenericAttributes)
+
+	for _, noHardlinks := range []bool{false, true} {
+		res := NewRestorer(repo, sn, Options{NoHardlinks: noHardlinks})
+		tempdir := rtest.TempDir(t)
+		_, err := res.RestoreTo(context.TODO(), tempdir)
+		rtest.OK(t, err)
+
+		var inodes []uint64
+		for _, name := range []string{"file1", "file2"} {
+			data, err := os.ReadFile(filepath.Join(tempdir, name))
+			rtest.OK(t, err)
+			rtest.Equals(t, "content", string(data))
+
+			fi, err := os.Stat(filepath.Join(tempdir, name))
+			rtest.OK(t, err)
+			inodes = append(inodes, fi.Sys().(*syscall.Stat_t).Ino)
+		}
+		rtest.Equals(t, !noHardlinks, inodes[0] == inodes[1], fmt.Sprintf("hard link with NoHardlinks=%v", noHardlinks))
+	}
+}
@@ -0,0 +1,105 @@
+package restorer
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// RestoreToSnapshot stores the items selected by SelectFilter in a new tree
+// and returns its ID. No file data is copied, the new tree references the
+// blobs of the original snapshot. Directories which are not selected are only
+// kept if they contain a selected item. If nothing was selected, the null ID
+// is returned.
+//
+// The caller must start the pack uploader of the repository before and flush
+// the repository afterwards.
+func (res *Restorer) RestoreToSnapshot(ctx context.Context) (restic.ID, error) {
+	if res.sn.Tree == nil {
+		return restic.ID{}, errors.Errorf("snapshot %v has nil tree", res.sn.ID().Str())
+	}
+
+	treeID, hasSelected, err := res.selectTree(ctx, string(filepath.Separator), *res.sn.Tree)
+	if err != nil || !hasSelected {
+		return restic.ID{}, err
+	}
+	return treeID, nil
+}
+
+func (res *Restorer) selectTree(ctx context.Context, location string, treeID restic.ID) (newTreeID restic.ID, hasSelected bool, err error) {
+	debug.Log("%v %v", location, treeID)
+	tree, err := restic.LoadTree(ctx, res.repo, treeID)
+	if err != nil {
+		return restic.ID{}, false, res.sanitizeError(location, err)
+	}
+
+	newTree := restic.NewTree(len(tree.Nodes))
+	for _, node := range tree.Nodes {
+		if ctx.Err() != nil {
+			return restic.ID{}, false, ctx.Err()
+		}
+
+		nodeName := filepath.Base(filepath.Join(string(filepath.Separator), node.Name))
+		if nodeName != node.Name {
+			debug.Log("node %q has invalid name %q", node.Name, nodeName)
+			err := res.sanitizeError(location, errors.Errorf("invalid child node name %s", node.Name))
+			if err != nil {
+				return restic.ID{}, false, err
+			}
+			continue
+		}
+
+		nodeLocation := filepath.Join(location, nodeName)
+		selected, childMayBeSelected := res.SelectFilter(nodeLocation, node.Type == restic.NodeTypeDir)
+		debug.Log("SelectFilter returned %v %v for %q", selected, childMayBeSelected, nodeLocation)
+
+		if node.Type != restic.NodeTypeDir {
+			if selected {
+				if err := newTree.Insert(node); err != nil {
+					return restic.ID{}, false, err
+				}
+			}
+			continue
+		}
+
+		if node.Subtree == nil {
+			return restic.ID{}, false, errors.Errorf("Dir without subtree in tree %v", treeID.Str())
+		}
+
+		var subtreeID restic.ID
+		childHasSelected := false
+		if childMayBeSelected {
+			subtreeID, childHasSelected, err = res.selectTree(ctx, nodeLocation, *node.Subtree)
+			if err != nil {
+				return restic.ID{}, false, err
+			}
+		}
+
+		if !selected && !childHasSelected {
+			continue
+		}
+		if !childHasSelected {
+			// the directory is selected, but none of its children
+			subtreeID, err = restic.SaveTree(ctx, res.repo, restic.NewTree(0))
+			if err != nil {
+				return restic.ID{}, false, err
+			}
+		}
+
+		newNode := *node
+		newNode.Subtree = &subtreeID
+		if err := newTree.Insert(&newNode); err != nil {
+			return restic.ID{}, false, err
+		}
+	}
+
+	if len(newTree.Nodes) == 0 {
+		return restic.ID{}, false, nil
+	}
+
+	newTreeID, err = restic.SaveTree(ctx, res.repo, newTree)
+	return newTreeID, true, err
+}
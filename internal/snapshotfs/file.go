@@ -0,0 +1,221 @@
+package snapshotfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+var (
+	errIsDir    = errors.New("is a directory")
+	errNotDir   = errors.New("not a directory")
+	errNoTree   = errors.New("directory has no subtree")
+	errNegative = errors.New("negative offset")
+)
+
+// file is an open file, symlink or special file.
+type file struct {
+	fs   *FS
+	node *restic.Node
+
+	// cumsize[i] is the offset of the i-th blob of the content, the last
+	// element is the size of the content. It is nil unless node is a file.
+	cumsize []uint64
+	offset  int64
+}
+
+var (
+	_ fs.File     = &file{}
+	_ io.ReaderAt = &file{}
+	_ io.Seeker   = &file{}
+)
+
+func (f *FS) openFile(name string, node *restic.Node) (*file, error) {
+	cumsize := make([]uint64, 1+len(node.Content))
+	for i, id := range node.Content {
+		size, found := f.repo.LookupBlobSize(restic.DataBlob, id)
+		if !found {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errors.Errorf("blob %v not found in repository", id.Str())}
+		}
+		cumsize[i+1] = cumsize[i] + uint64(size)
+	}
+	return &file{fs: f, node: node, cumsize: cumsize}, nil
+}
+
+// size returns the size of the content, which may differ from the size stored
+// in the node for damaged snapshots.
+func (f *file) size() int64 {
+	if f.cumsize == nil {
+		return 0
+	}
+	return int64(f.cumsize[len(f.cumsize)-1])
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return fileInfo{f.node}, nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt reads the content starting at off. It is safe for concurrent use.
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.node.Name, Err: errNegative}
+	}
+	if off >= f.size() {
+		return 0, io.EOF
+	}
+
+	// skip the blobs before the offset
+	i := sort.Search(len(f.cumsize), func(i int) bool {
+		return f.cumsize[i] > uint64(off)
+	}) - 1
+	blobOffset := uint64(off) - f.cumsize[i]
+
+	n := 0
+	for ; n < len(p) && i < len(f.node.Content); i++ {
+		blob, err := f.fs.loadBlob(f.node.Content[i])
+		if err != nil {
+			return n, &fs.PathError{Op: "read", Path: f.node.Name, Err: err}
+		}
+		n += copy(p[n:], blob[blobOffset:])
+		blobOffset = 0
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.node.Name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.node.Name, Err: errNegative}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+// dir is an open directory.
+type dir struct {
+	fs   *FS
+	node *restic.Node
+
+	// entries is loaded by the first call to ReadDir
+	entries []fs.DirEntry
+	loaded  bool
+}
+
+var _ fs.ReadDirFile = &dir{}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return fileInfo{d.node}, nil
+}
+
+func (d *dir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.Name, Err: errIsDir}
+}
+
+// ReadDir returns the next n entries of the directory, or all remaining
+// entries if n <= 0, as described by fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		tree, err := d.fs.loadSubtree(d.node)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.node.Name, Err: err}
+		}
+		d.entries = dirEntries(tree.Nodes)
+		d.loaded = true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func dirEntries(nodes []*restic.Node) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(nodes))
+	for _, node := range nodes {
+		entries = append(entries, fs.FileInfoToDirEntry(fileInfo{node}))
+	}
+	return entries
+}
+
+// fileInfo implements fs.FileInfo for a node.
+type fileInfo struct {
+	node *restic.Node
+}
+
+func (fi fileInfo) Name() string { return fi.node.Name }
+
+func (fi fileInfo) Size() int64 {
+	switch fi.node.Type {
+	case restic.NodeTypeFile:
+		return int64(fi.node.Size)
+	case restic.NodeTypeSymlink:
+		return int64(len(fi.node.LinkTarget))
+	}
+	return 0
+}
+
+// Mode returns the mode of the node, with the type bits derived from the
+// node type.
+func (fi fileInfo) Mode() fs.FileMode {
+	mode := fi.node.Mode &^ os.ModeType
+	switch fi.node.Type {
+	case restic.NodeTypeDir:
+		mode |= os.ModeDir
+	case restic.NodeTypeSymlink:
+		mode |= os.ModeSymlink
+	case restic.NodeTypeDev:
+		mode |= os.ModeDevice
+	case restic.NodeTypeCharDev:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case restic.NodeTypeFifo:
+		mode |= os.ModeNamedPipe
+	case restic.NodeTypeSocket:
+		mode |= os.ModeSocket
+	}
+	return mode
+}
+
+func (fi fileInfo) ModTime() time.Time { return fi.node.ModTime }
+func (fi fileInfo) IsDir() bool        { return fi.node.Type == restic.NodeTypeDir }
+
+// Sys returns the *restic.Node.
+func (fi fileInfo) Sys() any { return fi.node }
@@ -0,0 +1,225 @@
+// Package snapshotfs provides read-only access to the files and directories
+// of a snapshot using the interfaces of the io/fs package. This allows using
+// standard tooling like fs.WalkDir, http.FS or archive writers with snapshots.
+package snapshotfs
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/bloblru"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// cacheSize is the size of the cache for tree and data blobs.
+const cacheSize = 64 << 20
+
+// FS is a read-only file system containing the files of a snapshot. Symlinks
+// are not followed, opening a symlink returns the symlink itself, whose target
+// can be read using ReadLink.
+//
+// All operations use the context passed to New or WithContext, they fail
+// once it is canceled. FS is safe for concurrent use.
+type FS struct {
+	ctx   context.Context
+	repo  restic.Loader
+	tree  restic.ID
+	time  time.Time
+	cache *bloblru.Cache
+}
+
+var (
+	_ fs.FS         = &FS{}
+	_ fs.StatFS     = &FS{}
+	_ fs.ReadDirFS  = &FS{}
+	_ fs.ReadFileFS = &FS{}
+)
+
+// New returns a file system for the snapshot sn, whose data is loaded from
+// repo. The index of the repository must be loaded.
+func New(ctx context.Context, repo restic.Loader, sn *restic.Snapshot) (*FS, error) {
+	if sn.Tree == nil {
+		return nil, errors.Errorf("snapshot %v has no tree", sn.ID().Str())
+	}
+	return &FS{
+		ctx:   ctx,
+		repo:  repo,
+		tree:  *sn.Tree,
+		time:  sn.Time,
+		cache: bloblru.New(cacheSize),
+	}, nil
+}
+
+// WithContext returns a copy of the file system which uses ctx for all
+// operations. The copy shares the blob cache with f.
+func (f *FS) WithContext(ctx context.Context) *FS {
+	f2 := *f
+	f2.ctx = ctx
+	return &f2
+}
+
+// Open opens the named file or directory. The returned file implements
+// io.ReaderAt and io.Seeker, for directories it implements fs.ReadDirFile.
+func (f *FS) Open(name string) (fs.File, error) {
+	node, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.Type {
+	case restic.NodeTypeDir:
+		return &dir{fs: f, node: node}, nil
+	case restic.NodeTypeFile:
+		return f.openFile(name, node)
+	default:
+		// symlinks and special files have no content
+		return &file{fs: f, node: node}, nil
+	}
+}
+
+// Stat returns information about the named file or directory. The Sys method
+// of the result returns the *restic.Node.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	node, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{node}, nil
+}
+
+// Lstat is the same as Stat, as symlinks are never followed.
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	return f.Stat(name)
+}
+
+// ReadLink returns the target of the named symlink.
+func (f *FS) ReadLink(name string) (string, error) {
+	node, err := f.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if node.Type != restic.NodeTypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return node.LinkTarget, nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	node, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.Type != restic.NodeTypeDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	tree, err := f.loadSubtree(node)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return dirEntries(tree.Nodes), nil
+}
+
+// ReadFile returns the content of the named file.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	fd, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := fd.(*dir); ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	content, ok := fd.(*file)
+	if !ok || content.cumsize == nil {
+		return []byte{}, nil
+	}
+
+	buf := make([]byte, content.size())
+	if len(buf) == 0 {
+		return buf, nil
+	}
+	_, err = content.ReadAt(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// lookup returns the node for name. The root directory, which has no node in
+// the snapshot, is represented by a directory node named ".".
+func (f *FS) lookup(op, name string) (*restic.Node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	node := &restic.Node{
+		Name:    ".",
+		Type:    restic.NodeTypeDir,
+		Mode:    os.ModeDir | 0555,
+		ModTime: f.time,
+		Subtree: &f.tree,
+	}
+	if name == "." {
+		return node, nil
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if node.Type != restic.NodeTypeDir {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		tree, err := f.loadSubtree(node)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		node = tree.Find(part)
+		if node == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	return node, nil
+}
+
+// loadSubtree loads the tree of the directory node. A directory without a
+// subtree can only be found in a damaged snapshot.
+func (f *FS) loadSubtree(node *restic.Node) (*restic.Tree, error) {
+	if node.Subtree == nil {
+		return nil, errNoTree
+	}
+	return f.loadTree(*node.Subtree)
+}
+
+func (f *FS) loadTree(id restic.ID) (*restic.Tree, error) {
+	if f.ctx.Err() != nil {
+		return nil, f.ctx.Err()
+	}
+
+	buf, err := f.cache.GetOrCompute(id, func() ([]byte, error) {
+		return f.repo.LoadBlob(f.ctx, restic.TreeBlob, id, nil)
+	})
+	if err != nil {
+		debug.Log("loading tree %v failed: %v", id.Str(), err)
+		return nil, err
+	}
+
+	tree := &restic.Tree{}
+	if err := json.Unmarshal(buf, tree); err != nil {
+		return nil, errors.Wrapf(err, "tree %v", id.Str())
+	}
+	return tree, nil
+}
+
+func (f *FS) loadBlob(id restic.ID) ([]byte, error) {
+	if f.ctx.Err() != nil {
+		return nil, f.ctx.Err()
+	}
+	return f.cache.GetOrCompute(id, func() ([]byte, error) {
+		return f.repo.LoadBlob(f.ctx, restic.DataBlob, id, nil)
+	})
+}
@@ -0,0 +1,200 @@
+package snapshotfs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	rfs "github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/snapshotfs"
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+// largeContent spans several chunks
+var largeContent = strings.Repeat("0123456789abcdef", 1<<18)
+
+var testFiles = archiver.TestDir{
+	"empty": archiver.TestFile{Content: ""},
+	"small": archiver.TestFile{Content: "foobar"},
+	"large": archiver.TestFile{Content: largeContent},
+	"dir": archiver.TestDir{
+		"file": archiver.TestFile{Content: "nested"},
+		"sub": archiver.TestDir{
+			"file2": archiver.TestFile{Content: "deeply nested"},
+		},
+		"empty": archiver.TestDir{},
+	},
+	"link": archiver.TestSymlink{Target: "dir/file"},
+}
+
+func createSnapshot(t *testing.T) (restic.Repository, *restic.Snapshot) {
+	tempdir := rtest.TempDir(t)
+	repo := repository.TestRepository(t)
+	archiver.TestCreateFiles(t, tempdir, testFiles)
+
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	arch := archiver.New(repo, rfs.Track{FS: rfs.Local{}}, archiver.Options{})
+	sn, _, _, err := arch.Snapshot(context.TODO(), []string{"."}, archiver.SnapshotOptions{})
+	rtest.OK(t, err)
+	return repo, sn
+}
+
+func TestFS(t *testing.T) {
+	repo, sn := createSnapshot(t)
+	fsys, err := snapshotfs.New(context.TODO(), repo, sn)
+	rtest.OK(t, err)
+
+	rtest.OK(t, fstest.TestFS(fsys, "empty", "small", "large", "dir/file", "dir/sub/file2", "link"))
+
+	for name, content := range map[string]string{
+		"empty":         "",
+		"small":         "foobar",
+		"large":         largeContent,
+		"dir/sub/file2": "deeply nested",
+	} {
+		buf, err := fs.ReadFile(fsys, name)
+		rtest.OK(t, err)
+		rtest.Assert(t, string(buf) == content, "wrong content for %v", name)
+	}
+
+	var paths []string
+	rtest.OK(t, fs.WalkDir(fsys, ".", func(path string, _ fs.DirEntry, err error) error {
+		paths = append(paths, path)
+		return err
+	}))
+	rtest.Equals(t, []string{".", "dir", "dir/empty", "dir/file", "dir/sub", "dir/sub/file2", "empty", "large", "link", "small"}, paths)
+
+	fi, err := fs.Stat(fsys, "link")
+	rtest.OK(t, err)
+	rtest.Equals(t, fs.ModeSymlink, fi.Mode().Type())
+	target, err := fsys.ReadLink("link")
+	rtest.OK(t, err)
+	rtest.Equals(t, "dir/file", target)
+	_, ok := fi.Sys().(*restic.Node)
+	rtest.Assert(t, ok, "Sys() does not return the node")
+
+	for _, name := range []string{"missing", "small/file", "dir/missing/file"} {
+		_, err := fsys.Open(name)
+		rtest.Assert(t, errors.Is(err, fs.ErrNotExist), "unexpected error for %v: %v", name, err)
+	}
+	for _, name := range []string{"/small", "dir/../small", "dir/"} {
+		_, err := fsys.Open(name)
+		rtest.Assert(t, errors.Is(err, fs.ErrInvalid), "unexpected error for %v: %v", name, err)
+	}
+}
+
+func TestFSReadAt(t *testing.T) {
+	repo, sn := createSnapshot(t)
+	fsys, err := snapshotfs.New(context.TODO(), repo, sn)
+	rtest.OK(t, err)
+
+	f, err := fsys.Open("large")
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, f.Close())
+	}()
+	r := f.(io.ReaderAt)
+
+	for _, off := range []int{0, 1, 1 << 20, len(largeContent) - 100} {
+		buf := make([]byte, 200)
+		n, err := r.ReadAt(buf, int64(off))
+		want := largeContent[off:min(off+len(buf), len(largeContent))]
+		if n < len(buf) {
+			rtest.Assert(t, err == io.EOF, "expected EOF at offset %v, got %v", off, err)
+		} else {
+			rtest.OK(t, err)
+		}
+		rtest.Equals(t, want, string(buf[:n]))
+	}
+
+	pos, err := f.(io.Seeker).Seek(-6, io.SeekEnd)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len(largeContent)-6), pos)
+	rest, err := io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.Equals(t, largeContent[len(largeContent)-6:], string(rest))
+}
+
+func TestFSHTTP(t *testing.T) {
+	repo, sn := createSnapshot(t)
+	fsys, err := snapshotfs.New(context.TODO(), repo, sn)
+	rtest.OK(t, err)
+
+	srv := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/large", nil)
+	rtest.OK(t, err)
+	req.Header.Set("Range", "bytes=1000-1009")
+	resp, err := http.DefaultClient.Do(req)
+	rtest.OK(t, err)
+	body, err := io.ReadAll(resp.Body)
+	rtest.OK(t, err)
+	rtest.OK(t, resp.Body.Close())
+	rtest.Equals(t, http.StatusPartialContent, resp.StatusCode)
+	rtest.Equals(t, largeContent[1000:1010], string(body))
+}
+
+func TestFSContext(t *testing.T) {
+	repo, sn := createSnapshot(t)
+	fsys, err := snapshotfs.New(context.TODO(), repo, sn)
+	rtest.OK(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fsys.WithContext(ctx).ReadFile("dir/file")
+	rtest.Assert(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+
+	// the original file system is not affected
+	buf, err := fsys.ReadFile("dir/file")
+	rtest.OK(t, err)
+	rtest.Equals(t, "nested", string(buf))
+}
+
+func TestFSMissingSubtree(t *testing.T) {
+	ctx := context.TODO()
+	repo := repository.TestRepository(t)
+
+	tree := &restic.Tree{
+		Nodes: []*restic.Node{
+			{Name: "damaged", Type: restic.NodeTypeDir, Mode: 0755},
+		},
+	}
+	wg, wgCtx := errgroup.WithContext(ctx)
+	repo.StartPackUploader(wgCtx, wg)
+	id, err := restic.SaveTree(ctx, repo, tree)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(ctx))
+
+	sn, err := restic.NewSnapshot([]string{"/damaged"}, nil, "foo", time.Now())
+	rtest.OK(t, err)
+	sn.Tree = &id
+
+	fsys, err := snapshotfs.New(ctx, repo, sn)
+	rtest.OK(t, err)
+
+	var pathErr *fs.PathError
+	_, err = fsys.ReadDir("damaged")
+	rtest.Assert(t, errors.As(err, &pathErr), "unexpected error %v", err)
+	_, err = fsys.Open("damaged/file")
+	rtest.Assert(t, errors.As(err, &pathErr), "unexpected error %v", err)
+
+	f, err := fsys.Open("damaged")
+	rtest.OK(t, err)
+	_, err = f.(fs.ReadDirFile).ReadDir(-1)
+	rtest.Assert(t, errors.As(err, &pathErr), "unexpected error %v", err)
+	rtest.OK(t, f.Close())
+}
@@ -0,0 +1,119 @@
+package backup
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/ui"
+)
+
+// maxHistoryRuns limits the number of recent runs stored in the history.
+const maxHistoryRuns = 10
+
+// RunStats holds the change statistics of a single backup run.
+type RunStats struct {
+	Time time.Time `json:"time"`
+	// FilesChanged is the number of new and modified files.
+	FilesChanged uint64 `json:"files_changed"`
+	// ItemsRemoved is the number of files and directories which were
+	// contained in the parent snapshot, but no longer exist.
+	ItemsRemoved uint64 `json:"items_removed"`
+	// DataAdded is the size of the new file data before compression.
+	DataAdded uint64 `json:"data_added"`
+}
+
+// NewRunStats returns the change statistics of the backup described by summary.
+func NewRunStats(summary *archiver.Summary) RunStats {
+	return RunStats{
+		Time:         summary.BackupEnd,
+		FilesChanged: uint64(summary.Files.New + summary.Files.Changed),
+		ItemsRemoved: uint64(summary.RemovedItems),
+		DataAdded:    summary.DataSize,
+	}
+}
+
+// Anomaly describes a statistic of a backup run which deviates strongly from
+// the recent runs.
+type Anomaly struct {
+	// Metric is the name of the statistic, it matches the JSON field of RunStats.
+	Metric string `json:"metric"`
+	Value  uint64 `json:"value"`
+	// Baseline is the value expected based on the recent runs.
+	Baseline uint64  `json:"baseline"`
+	Factor   float64 `json:"factor"`
+}
+
+func (a Anomaly) String() string {
+	value, baseline := fmt.Sprint(a.Value), fmt.Sprint(a.Baseline)
+	if a.Metric == "data_added" {
+		value, baseline = ui.FormatBytes(a.Value), ui.FormatBytes(a.Baseline)
+	}
+	return fmt.Sprintf("%v is %v, %.0f times the usual %v", a.Metric, value, a.Factor, baseline)
+}
+
+// AnomalyDetector compares the statistics of a backup run against those of the
+// recent runs for the same targets, which are sorted from oldest to newest.
+type AnomalyDetector interface {
+	Detect(recent []RunStats, current RunStats) []Anomaly
+}
+
+// ThresholdDetector reports a statistic as anomalous if it exceeds the median
+// of the recent runs by at least Factor. As backups often change almost
+// nothing, the median is raised to MinFiles or MinBytes, respectively.
+type ThresholdDetector struct {
+	Factor float64
+	// MinRuns is the number of recent runs required for a reliable median.
+	MinRuns  int
+	MinFiles uint64
+	MinBytes uint64
+}
+
+var _ AnomalyDetector = ThresholdDetector{}
+
+// NewThresholdDetector returns a ThresholdDetector with default limits for
+// the given factor.
+func NewThresholdDetector(factor float64) ThresholdDetector {
+	return ThresholdDetector{
+		Factor:   factor,
+		MinRuns:  3,
+		MinFiles: 100,
+		MinBytes: 100 * 1024 * 1024,
+	}
+}
+
+func (d ThresholdDetector) Detect(recent []RunStats, current RunStats) []Anomaly {
+	if d.Factor <= 0 || len(recent) < max(d.MinRuns, 1) {
+		return nil
+	}
+
+	var anomalies []Anomaly
+	check := func(metric string, value func(RunStats) uint64, minBaseline uint64) {
+		values := make([]uint64, 0, len(recent))
+		for _, run := range recent {
+			values = append(values, value(run))
+		}
+		baseline := max(median(values), minBaseline, 1)
+
+		v := value(current)
+		if factor := float64(v) / float64(baseline); factor >= d.Factor {
+			anomalies = append(anomalies, Anomaly{Metric: metric, Value: v, Baseline: baseline, Factor: factor})
+		}
+	}
+
+	check("files_changed", func(s RunStats) uint64 { return s.FilesChanged }, d.MinFiles)
+	check("items_removed", func(s RunStats) uint64 { return s.ItemsRemoved }, d.MinFiles)
+	check("data_added", func(s RunStats) uint64 { return s.DataAdded }, d.MinBytes)
+	return anomalies
+}
+
+// median returns the median of values, which is modified.
+func median(values []uint64) uint64 {
+	slices.Sort(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return values[n/2-1]/2 + values[n/2]/2
+}
@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestThresholdDetector(t *testing.T) {
+	recent := []RunStats{
+		{FilesChanged: 200, ItemsRemoved: 0, DataAdded: 300 << 20},
+		{FilesChanged: 150, ItemsRemoved: 5, DataAdded: 200 << 20},
+		{FilesChanged: 5000, ItemsRemoved: 2, DataAdded: 10 << 30},
+		{FilesChanged: 180, ItemsRemoved: 1, DataAdded: 250 << 20},
+	}
+	d := NewThresholdDetector(10)
+
+	for _, test := range []struct {
+		name    string
+		recent  []RunStats
+		current RunStats
+		want    []Anomaly
+	}{
+		{"usual", recent, RunStats{FilesChanged: 1000, ItemsRemoved: 900, DataAdded: 2 << 30}, nil},
+		{"files changed", recent, RunStats{FilesChanged: 20000}, []Anomaly{
+			// the median of 150, 180, 200 and 5000 is 190
+			{Metric: "files_changed", Value: 20000, Baseline: 190, Factor: 20000.0 / 190},
+		}},
+		{"removed", recent, RunStats{ItemsRemoved: 1000, DataAdded: 3 << 30}, []Anomaly{
+			// the baseline is raised to MinFiles and MinBytes
+			{Metric: "items_removed", Value: 1000, Baseline: 100, Factor: 10},
+			{Metric: "data_added", Value: 3 << 30, Baseline: 275 << 20, Factor: float64(3<<30) / float64(275<<20)},
+		}},
+		{"too few runs", recent[:2], RunStats{FilesChanged: 20000}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rtest.Equals(t, test.want, d.Detect(test.recent, test.current))
+		})
+	}
+
+	// disabled
+	rtest.Equals(t, []Anomaly(nil), NewThresholdDetector(0).Detect(recent, RunStats{FilesChanged: 1 << 30}))
+}
+
+func TestAnomalyString(t *testing.T) {
+	rtest.Equals(t, "files_changed is 20000, 105 times the usual 190",
+		Anomaly{Metric: "files_changed", Value: 20000, Baseline: 190, Factor: 20000.0 / 190}.String())
+	rtest.Equals(t, "data_added is 3.000 GiB, 11 times the usual 275.000 MiB",
+		Anomaly{Metric: "data_added", Value: 3 << 30, Baseline: 275 << 20, Factor: 11.17}.String())
+}
+
+func TestNewHistoryRuns(t *testing.T) {
+	prog := NewProgress(&mockPrinter{}, time.Millisecond)
+	defer prog.Done()
+
+	var h *History
+	for i := 0; i < maxHistoryRuns+2; i++ {
+		prog.SetHistory(h)
+		summary := &archiver.Summary{Files: archiver.ChangeStats{New: uint(i), Changed: 1}, RemovedItems: 2}
+		summary.DataSize = 3
+		h = prog.NewHistory(summary)
+	}
+
+	rtest.Equals(t, maxHistoryRuns, len(h.Runs))
+	rtest.Equals(t, RunStats{FilesChanged: 3, ItemsRemoved: 2, DataAdded: 3}, h.Runs[0])
+	rtest.Equals(t, uint64(maxHistoryRuns+2), h.Runs[len(h.Runs)-1].FilesChanged)
+}
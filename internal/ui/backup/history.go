@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// historyWarmup is the duration after which the estimate of the remaining
+// time only depends on the rate measured during the current run.
+const historyWarmup = minRateEstimatorMinutes * time.Minute
+
+// maxHistoryRateScale limits how much the rate of the previous run is adjusted
+// to the share of changed data in the current run.
+const maxHistoryRateScale = 4
+
+// History holds the statistics of a previous backup of the same targets.
+type History struct {
+	// TotalBytes is the size of all processed files.
+	TotalBytes uint64 `json:"total_bytes"`
+	// ChangedBytes is the size of all new and modified files.
+	ChangedBytes uint64        `json:"changed_bytes"`
+	Duration     time.Duration `json:"duration"`
+	// Runs contains the change statistics of the recent runs, sorted from
+	// oldest to newest.
+	Runs []RunStats `json:"runs,omitempty"`
+}
+
+// rate returns the expected processing rate in bytes per second, given the
+// share of new and modified data observed so far. Reading unchanged files
+// usually takes much less time than reading changed ones. Thus, the rate of
+// the previous run is scaled according to the share of changed data.
+func (h *History) rate(changeRatio float64) float64 {
+	if h.Duration <= 0 || h.TotalBytes == 0 {
+		return 0
+	}
+	rate := float64(h.TotalBytes) / h.Duration.Seconds()
+	if h.ChangedBytes == 0 || changeRatio <= 0 {
+		return rate
+	}
+
+	changedRate := float64(h.ChangedBytes) / h.Duration.Seconds()
+	return min(max(changedRate/changeRatio, rate/maxHistoryRateScale), rate*maxHistoryRateScale)
+}
+
+// HistoryKey returns a key which identifies backups of the given targets on
+// the host, regardless of the order of the targets.
+func HistoryKey(hostname string, targets []string) string {
+	sorted := append([]string{hostname}, targets...)
+	sort.Strings(sorted[1:])
+
+	h := sha256.New()
+	for _, s := range sorted {
+		_, _ = h.Write([]byte(s))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LoadHistory reads the statistics stored in filename. If the file does not
+// exist, nil is returned.
+func LoadHistory(filename string) (*History, error) {
+	buf, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var h History
+	if err := json.Unmarshal(buf, &h); err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+	return &h, nil
+}
+
+// SaveHistory stores the statistics in filename, replacing the previous ones.
+func SaveHistory(filename string, h *History) error {
+	buf, err := json.Marshal(h)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,39 @@
+package backup
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestHistorySaveLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "progress", HistoryKey("host", []string{"/b", "/a"}))
+
+	h, err := LoadHistory(filename)
+	rtest.OK(t, err)
+	rtest.Assert(t, h == nil, "expected no history, got %v", h)
+
+	want := &History{TotalBytes: 1000, ChangedBytes: 100, Duration: time.Minute}
+	rtest.OK(t, SaveHistory(filename, want))
+	h, err = LoadHistory(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, want, h)
+}
+
+func TestHistoryKey(t *testing.T) {
+	rtest.Equals(t, HistoryKey("host", []string{"/a", "/b"}), HistoryKey("host", []string{"/b", "/a"}))
+	rtest.Assert(t, HistoryKey("host", []string{"/a"}) != HistoryKey("other", []string{"/a"}), "hostname is ignored")
+	rtest.Assert(t, HistoryKey("host", []string{"/a"}) != HistoryKey("host", []string{"/a", "/b"}), "targets are ignored")
+}
+
+func TestHistoryRate(t *testing.T) {
+	h := &History{TotalBytes: 1000, ChangedBytes: 100, Duration: 10 * time.Second}
+	rtest.Equals(t, 100., h.rate(0))
+	rtest.Equals(t, 100., h.rate(0.1))
+	rtest.Equals(t, 200., h.rate(0.05))
+	// the adjustment is limited
+	rtest.Equals(t, 400., h.rate(0.001))
+	rtest.Equals(t, 25., h.rate(1))
+}
@@ -163,12 +163,18 @@ func (b *JSONProgress) ReportTotal(start time.Time, s archiver.ScanStats) {
 
 // Finish prints the finishing messages.
 func (b *JSONProgress) Finish(snapshotID restic.ID, summary *archiver.Summary, dryRun bool) {
+	b.print(NewSummaryOutput(snapshotID, summary, dryRun))
+}
+
+// NewSummaryOutput returns the summary message which is printed at the end of
+// a backup.
+func NewSummaryOutput(snapshotID restic.ID, summary *archiver.Summary, dryRun bool) SummaryOutput {
 	id := ""
 	// empty if snapshot creation was skipped
 	if !snapshotID.IsNull() {
 		id = snapshotID.String()
 	}
-	b.print(summaryOutput{
+	return SummaryOutput{
 		MessageType:         "summary",
 		FilesNew:            summary.Files.New,
 		FilesChanged:        summary.Files.Changed,
@@ -177,6 +183,7 @@ func (b *JSONProgress) Finish(snapshotID restic.ID, summary *archiver.Summary, d
 		DirsChanged:         summary.Dirs.Changed,
 		DirsUnmodified:      summary.Dirs.Unchanged,
 		DataBlobs:           summary.ItemStats.DataBlobs,
+		DataBlobsReused:     summary.DataBlobsReused(),
 		TreeBlobs:           summary.ItemStats.TreeBlobs,
 		DataAdded:           summary.ItemStats.DataSize + summary.ItemStats.TreeSize,
 		DataAddedPacked:     summary.ItemStats.DataSizeInRepo + summary.ItemStats.TreeSizeInRepo,
@@ -187,7 +194,13 @@ func (b *JSONProgress) Finish(snapshotID restic.ID, summary *archiver.Summary, d
 		TotalDuration:       summary.BackupEnd.Sub(summary.BackupStart).Seconds(),
 		SnapshotID:          id,
 		DryRun:              dryRun,
-	})
+		CaseCollisions:      summary.CaseCollisions,
+		TotalCaseCollisions: summary.NumCaseCollisions,
+		Interrupted:         summary.Interrupted,
+		DedupRatio:          summary.DedupRatio(),
+		CompressionRatio:    summary.CompressionRatio(),
+		SpaceRemaining:      summary.SpaceRemaining,
+	}
 }
 
 // Reset no-op
@@ -230,7 +243,8 @@ type verboseUpdate struct {
 	TotalFiles         uint    `json:"total_files"`
 }
 
-type summaryOutput struct {
+// SummaryOutput is the JSON summary of a backup.
+type SummaryOutput struct {
 	MessageType         string    `json:"message_type"` // "summary"
 	FilesNew            uint      `json:"files_new"`
 	FilesChanged        uint      `json:"files_changed"`
@@ -239,6 +253,7 @@ type summaryOutput struct {
 	DirsChanged         uint      `json:"dirs_changed"`
 	DirsUnmodified      uint      `json:"dirs_unmodified"`
 	DataBlobs           int       `json:"data_blobs"`
+	DataBlobsReused     uint64    `json:"data_blobs_reused"`
 	TreeBlobs           int       `json:"tree_blobs"`
 	DataAdded           uint64    `json:"data_added"`
 	DataAddedPacked     uint64    `json:"data_added_packed"`
@@ -249,4 +264,10 @@ type summaryOutput struct {
 	BackupEnd           time.Time `json:"backup_end"`
 	SnapshotID          string    `json:"snapshot_id,omitempty"`
 	DryRun              bool      `json:"dry_run,omitempty"`
+	CaseCollisions      []string  `json:"case_collisions,omitempty"`
+	TotalCaseCollisions uint      `json:"total_case_collisions,omitempty"`
+	Interrupted         bool      `json:"interrupted,omitempty"`
+	DedupRatio          float64   `json:"dedup_ratio,omitempty"`
+	CompressionRatio    float64   `json:"compression_ratio,omitempty"`
+	SpaceRemaining      *uint64   `json:"space_remaining,omitempty"`
 }
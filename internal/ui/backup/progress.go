@@ -42,6 +42,11 @@ type Progress struct {
 	processed, total Counter
 	errors           uint
 
+	// history holds the statistics of the previous run, changedBytes is the
+	// size of the new and modified files processed so far
+	history      *History
+	changedBytes uint64
+
 	printer ProgressPrinter
 }
 
@@ -62,24 +67,83 @@ func NewProgress(printer ProgressPrinter, interval time.Duration) *Progress {
 				return
 			}
 
-			var secondsRemaining uint64
-			if p.scanFinished {
-				rate := p.estimator.rate(time.Now())
-				tooSlowCutoff := 1024.
-				if rate <= tooSlowCutoff {
-					secondsRemaining = 0
-				} else {
-					todo := float64(p.total.Bytes - p.processed.Bytes)
-					secondsRemaining = uint64(todo / rate)
-				}
-			}
-
+			secondsRemaining := p.secondsRemaining(time.Now())
 			p.printer.Update(p.total, p.processed, p.errors, p.currentFiles, p.start, secondsRemaining)
 		}
 	})
 	return p
 }
 
+// SetHistory sets the statistics of a previous run for the same targets. They
+// are used to estimate the remaining time before the scan has finished and
+// while the current rate is not yet reliable.
+func (p *Progress) SetHistory(h *History) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.history = h
+}
+
+// NewHistory returns the statistics of the finished run, which can be used
+// to seed the estimates of the next run. The change statistics of the run are
+// appended to the recent runs of the previous history.
+func (p *Progress) NewHistory(summary *archiver.Summary) *History {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var runs []RunStats
+	if p.history != nil {
+		runs = append(runs, p.history.Runs...)
+	}
+	runs = append(runs, NewRunStats(summary))
+	if len(runs) > maxHistoryRuns {
+		runs = runs[len(runs)-maxHistoryRuns:]
+	}
+
+	return &History{
+		TotalBytes:   summary.ProcessedBytes,
+		ChangedBytes: p.changedBytes,
+		Duration:     summary.BackupEnd.Sub(summary.BackupStart),
+		Runs:         runs,
+	}
+}
+
+// secondsRemaining estimates the time until the backup is complete. Without
+// statistics of a previous run, no estimate is available until the scan has
+// finished. Otherwise, the expected total size and the rate of the previous
+// run are used at first, the measured rate gradually replaces the latter.
+// The caller must hold p.mu.
+func (p *Progress) secondsRemaining(now time.Time) uint64 {
+	total := p.total.Bytes
+	if !p.scanFinished {
+		if p.history == nil {
+			return 0
+		}
+		total = max(total, p.history.TotalBytes)
+	}
+	if total <= p.processed.Bytes {
+		return 0
+	}
+
+	rate := p.estimator.rate(now)
+	if p.history != nil {
+		changeRatio := 0.
+		if p.processed.Bytes > 0 {
+			changeRatio = float64(p.changedBytes) / float64(p.processed.Bytes)
+		}
+		if historyRate := p.history.rate(changeRatio); historyRate > 0 {
+			weight := min(1, float64(now.Sub(p.start))/float64(historyWarmup))
+			rate = weight*rate + (1-weight)*historyRate
+		}
+	}
+
+	tooSlowCutoff := 1024.
+	if rate <= tooSlowCutoff {
+		return 0
+	}
+	todo := float64(total - p.processed.Bytes)
+	return uint64(todo / rate)
+}
+
 // Error is the error callback function for the archiver, it prints the error and returns nil.
 func (p *Progress) Error(item string, err error) error {
 	p.mu.Lock()
@@ -141,6 +205,9 @@ func (p *Progress) CompleteItem(item string, previous, current *restic.Node, s a
 	case restic.NodeTypeFile:
 		p.mu.Lock()
 		p.addProcessed(Counter{Files: 1})
+		if previous == nil || !previous.Equals(*current) {
+			p.changedBytes += current.Size
+		}
 		delete(p.currentFiles, item)
 		p.mu.Unlock()
 
@@ -75,3 +75,34 @@ func TestProgress(t *testing.T) {
 		t.Errorf("id not stored (has %v)", prnt.id)
 	}
 }
+
+func TestProgressHistoryEstimate(t *testing.T) {
+	prog := NewProgress(&mockPrinter{}, time.Hour)
+	defer prog.Done()
+	now := prog.start
+
+	// without history, there is no estimate until the scan has finished
+	if secs := prog.secondsRemaining(now); secs != 0 {
+		t.Errorf("unexpected estimate without history: %v", secs)
+	}
+
+	// the previous run processed 100 MiB in 100 seconds
+	prog.SetHistory(&History{TotalBytes: 100 << 20, ChangedBytes: 10 << 20, Duration: 100 * time.Second})
+	if secs := prog.secondsRemaining(now); secs != 100 {
+		t.Errorf("expected estimate of 100 seconds based on history, got %v", secs)
+	}
+
+	// only half as much data changed, thus the backup is expected to take half as long
+	node := restic.Node{Type: restic.NodeTypeFile, Size: 1 << 20}
+	prog.CompleteBlob(20 << 20)
+	prog.CompleteItem("foo", nil, &node, archiver.ItemStats{}, 0)
+	if secs := prog.secondsRemaining(now); secs != 40 {
+		t.Errorf("expected estimate of 40 seconds, got %v", secs)
+	}
+
+	// after the warmup, only the measured rate of 20 MiB in two minutes is used
+	prog.ReportTotal("", archiver.ScanStats{Bytes: 40 << 20})
+	if secs := prog.secondsRemaining(now.Add(historyWarmup)); secs < 119 || secs > 120 {
+		t.Errorf("expected estimate of 120 seconds, got %v", secs)
+	}
+}
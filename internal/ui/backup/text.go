@@ -134,7 +134,7 @@ func (b *TextProgress) Finish(id restic.ID, summary *archiver.Summary, dryRun bo
 	b.P("\n")
 	b.P("Files:       %5d new, %5d changed, %5d unmodified\n", summary.Files.New, summary.Files.Changed, summary.Files.Unchanged)
 	b.P("Dirs:        %5d new, %5d changed, %5d unmodified\n", summary.Dirs.New, summary.Dirs.Changed, summary.Dirs.Unchanged)
-	b.V("Data Blobs:  %5d new\n", summary.ItemStats.DataBlobs)
+	b.V("Data Blobs:  %5d new, %5d reused\n", summary.ItemStats.DataBlobs, summary.DataBlobsReused())
 	b.V("Tree Blobs:  %5d new\n", summary.ItemStats.TreeBlobs)
 	verb := "Added"
 	if dryRun {
@@ -143,6 +143,21 @@ func (b *TextProgress) Finish(id restic.ID, summary *archiver.Summary, dryRun bo
 	b.P("%s to the repository: %-5s (%-5s stored)\n", verb,
 		ui.FormatBytes(summary.ItemStats.DataSize+summary.ItemStats.TreeSize),
 		ui.FormatBytes(summary.ItemStats.DataSizeInRepo+summary.ItemStats.TreeSizeInRepo))
+	b.P("Efficiency:  dedup ratio %s, compression ratio %s\n",
+		formatRatio(summary.DedupRatio()), formatRatio(summary.CompressionRatio()))
+	if summary.SpaceRemaining != nil {
+		b.P("Remaining space in the repository: %s\n", ui.FormatBytes(*summary.SpaceRemaining))
+	}
+	if summary.NumCaseCollisions > 0 {
+		b.P("Warning: %d items only differ in case from another item in the same directory,\n", summary.NumCaseCollisions)
+		b.P("they collide when restored to a case-insensitive file system\n")
+		for _, item := range summary.CaseCollisions {
+			b.V("  %v\n", item)
+		}
+		if more := summary.NumCaseCollisions - uint(len(summary.CaseCollisions)); more > 0 {
+			b.V("  and %d more\n", more)
+		}
+	}
 	b.P("\n")
 	b.P("processed %v files, %v in %s",
 		summary.Files.New+summary.Files.Changed+summary.Files.Unchanged,
@@ -151,10 +166,25 @@ func (b *TextProgress) Finish(id restic.ID, summary *archiver.Summary, dryRun bo
 	)
 
 	if !dryRun {
-		if id.IsNull() {
+		switch {
+		case summary.BelowChangeThreshold:
+			b.P("skipped creating snapshot, %d changed files with %v are below the thresholds\n",
+				summary.ChangedFiles(), ui.FormatBytes(summary.ChangedBytes))
+		case id.IsNull():
 			b.P("skipped creating snapshot\n")
-		} else {
+		case summary.Interrupted:
+			b.P("partial snapshot %s saved, it only contains the files processed before the backup was interrupted\n", id.Str())
+		default:
 			b.P("snapshot %s saved\n", id.Str())
 		}
 	}
 }
+
+// formatRatio formats a ratio returned by the methods of archiver.Summary,
+// zero means that the ratio is undefined.
+func formatRatio(ratio float64) string {
+	if ratio == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2fx", ratio)
+}
@@ -1,9 +1,13 @@
 package backup
 
 import (
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/restic/restic/internal/archiver"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/test"
 	"github.com/restic/restic/internal/ui"
 )
@@ -25,3 +29,36 @@ func TestScannerError(t *testing.T) {
 	test.Equals(t, printer.ScannerError("/path", errors.New("error \"message\"")), nil)
 	test.Equals(t, []string{"scan: error \"message\"\n"}, term.Errors)
 }
+
+func TestFinishEfficiency(t *testing.T) {
+	term, printer := createTextProgress()
+	summary := &archiver.Summary{
+		ProcessedBytes: 1000,
+		ProcessedBlobs: 10,
+		ItemStats: archiver.ItemStats{
+			DataBlobs:      4,
+			DataSize:       400,
+			DataSizeInRepo: 200,
+		},
+	}
+	printer.Finish(restic.NewRandomID(), summary, false)
+	test.Assert(t, slices.Contains(term.Output, "Data Blobs:      4 new,     6 reused\n"), "missing blob counts in %q", term.Output)
+	test.Assert(t, slices.Contains(term.Output, "Efficiency:  dedup ratio 2.50x, compression ratio 2.00x\n"), "missing efficiency in %q", term.Output)
+
+	term, printer = createTextProgress()
+	printer.Finish(restic.NewRandomID(), &archiver.Summary{ProcessedBytes: 1000, ProcessedBlobs: 10}, false)
+	test.Assert(t, slices.Contains(term.Output, "Efficiency:  dedup ratio n/a, compression ratio n/a\n"), "missing efficiency in %q", term.Output)
+}
+
+func TestFinishSpaceRemaining(t *testing.T) {
+	term, printer := createTextProgress()
+	printer.Finish(restic.NewRandomID(), &archiver.Summary{}, false)
+	for _, line := range term.Output {
+		test.Assert(t, !strings.HasPrefix(line, "Remaining space"), "unexpected remaining space in %q", term.Output)
+	}
+
+	remaining := uint64(3 * 1024 * 1024 * 1024)
+	term, printer = createTextProgress()
+	printer.Finish(restic.NewRandomID(), &archiver.Summary{SpaceRemaining: &remaining}, false)
+	test.Assert(t, slices.Contains(term.Output, "Remaining space in the repository: 3.000 GiB\n"), "missing remaining space in %q", term.Output)
+}
@@ -0,0 +1,105 @@
+package progress
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/ui"
+)
+
+// JSONPrinter is a Printer which emits all messages and the state of its
+// counters as JSON lines.
+type JSONPrinter struct {
+	term      ui.Terminal
+	verbosity uint
+	interval  time.Duration
+}
+
+var _ Printer = (*JSONPrinter)(nil)
+
+// NewJSONPrinter returns a new JSONPrinter which reports the state of its
+// counters every interval. If interval is zero, only the final state is
+// reported.
+func NewJSONPrinter(term ui.Terminal, verbosity uint, interval time.Duration) *JSONPrinter {
+	return &JSONPrinter{
+		term:      term,
+		verbosity: verbosity,
+		interval:  interval,
+	}
+}
+
+type jsonStatus struct {
+	MessageType    string  `json:"message_type"` // "status"
+	Phase          string  `json:"phase"`
+	SecondsElapsed uint64  `json:"seconds_elapsed"`
+	PercentDone    float64 `json:"percent_done,omitempty"`
+	Done           uint64  `json:"done"`
+	Total          uint64  `json:"total,omitempty"`
+}
+
+type jsonMessage struct {
+	MessageType string `json:"message_type"` // "message"
+	Message     string `json:"message"`
+}
+
+type jsonErrorObject struct {
+	Message string `json:"message"`
+}
+
+type jsonError struct {
+	MessageType string          `json:"message_type"` // "error"
+	Error       jsonErrorObject `json:"error"`
+}
+
+// NewCounter returns a counter which reports its state as a status message
+// for the phase description. The total is omitted if it is unknown.
+func (p *JSONPrinter) NewCounter(description string) *Counter {
+	if p.verbosity < 1 {
+		return nil
+	}
+	return NewCounter(p.interval, 0, func(value uint64, total uint64, runtime time.Duration, _ bool) {
+		status := jsonStatus{
+			MessageType:    "status",
+			Phase:          description,
+			SecondsElapsed: uint64(runtime / time.Second),
+			Done:           value,
+			Total:          total,
+		}
+		if total > 0 {
+			status.PercentDone = float64(value) / float64(total)
+		}
+		p.term.Print(ui.ToJSONString(status))
+	})
+}
+
+func (p *JSONPrinter) message(minVerbosity uint, msg string, args ...interface{}) {
+	if p.verbosity < minVerbosity {
+		return
+	}
+	text := strings.TrimSpace(fmt.Sprintf(msg, args...))
+	if text == "" {
+		return
+	}
+	p.term.Print(ui.ToJSONString(jsonMessage{MessageType: "message", Message: text}))
+}
+
+func (p *JSONPrinter) E(msg string, args ...interface{}) {
+	text := strings.TrimSpace(fmt.Sprintf(msg, args...))
+	if text == "" {
+		return
+	}
+	p.term.Error(ui.ToJSONString(jsonError{MessageType: "error", Error: jsonErrorObject{text}}))
+}
+
+func (p *JSONPrinter) P(msg string, args ...interface{}) {
+	p.message(1, msg, args...)
+}
+
+func (p *JSONPrinter) V(msg string, args ...interface{}) {
+	p.message(2, msg, args...)
+}
+
+func (p *JSONPrinter) VV(msg string, args ...interface{}) {
+	p.message(3, msg, args...)
+}
@@ -0,0 +1,33 @@
+package progress_test
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func TestJSONPrinter(t *testing.T) {
+	term := &ui.MockTerminal{}
+	printer := progress.NewJSONPrinter(term, 1, 0)
+
+	c := printer.NewCounter("packs repacked")
+	c.SetMax(4)
+	c.Add(1)
+	c.Done()
+
+	printer.P("loading indexes...\n")
+	printer.V("hidden at verbosity 1\n")
+	printer.P("\n")
+	printer.E("error: %v\n", "broken")
+
+	test.Equals(t, []string{
+		`{"message_type":"status","phase":"packs repacked","seconds_elapsed":0,"percent_done":0.25,"done":1,"total":4}` + "\n",
+		`{"message_type":"message","message":"loading indexes..."}` + "\n",
+	}, term.Output)
+	test.Equals(t, []string{`{"message_type":"error","error":{"message":"error: broken"}}` + "\n"}, term.Errors)
+
+	// counters are disabled for --quiet
+	test.Assert(t, progress.NewJSONPrinter(term, 0, 0).NewCounter("files deleted") == nil, "counter for verbosity 0")
+}
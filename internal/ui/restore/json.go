@@ -9,12 +9,17 @@ import (
 type jsonPrinter struct {
 	terminal  ui.Terminal
 	verbosity uint
+	dryRun    bool
 }
 
-func NewJSONProgress(terminal ui.Terminal, verbosity uint) ProgressPrinter {
+// NewJSONProgress returns a new restore progress printer which emits JSON
+// messages. If dryRun is set, the verbose_status messages which list the
+// changed files are already printed for verbosity level 2.
+func NewJSONProgress(terminal ui.Terminal, verbosity uint, dryRun bool) ProgressPrinter {
 	return &jsonPrinter{
 		terminal:  terminal,
 		verbosity: verbosity,
+		dryRun:    dryRun,
 	}
 }
 
@@ -37,6 +42,7 @@ func (t *jsonPrinter) Update(p State, duration time.Duration) {
 		TotalBytes:     p.AllBytesTotal,
 		BytesRestored:  p.AllBytesWritten,
 		BytesSkipped:   p.AllBytesSkipped,
+		BytesDeleted:   p.AllBytesDeleted,
 	}
 
 	if p.AllBytesTotal > 0 {
@@ -57,7 +63,11 @@ func (t *jsonPrinter) Error(item string, err error) error {
 }
 
 func (t *jsonPrinter) CompleteItem(messageType ItemAction, item string, size uint64) {
-	if t.verbosity < 3 {
+	minVerbosity := uint(3)
+	if t.dryRun && messageType != ActionFileUnchanged {
+		minVerbosity = 2
+	}
+	if t.verbosity < minVerbosity {
 		return
 	}
 
@@ -99,6 +109,9 @@ func (t *jsonPrinter) Finish(p State, duration time.Duration) {
 		TotalBytes:     p.AllBytesTotal,
 		BytesRestored:  p.AllBytesWritten,
 		BytesSkipped:   p.AllBytesSkipped,
+		BytesDeleted:   p.AllBytesDeleted,
+		CaseCollisions: p.CaseCollisions,
+		DryRun:         t.dryRun,
 	}
 	t.print(status)
 }
@@ -114,6 +127,7 @@ type statusUpdate struct {
 	TotalBytes     uint64  `json:"total_bytes,omitempty"`
 	BytesRestored  uint64  `json:"bytes_restored,omitempty"`
 	BytesSkipped   uint64  `json:"bytes_skipped,omitempty"`
+	BytesDeleted   uint64  `json:"bytes_deleted,omitempty"`
 }
 
 type errorObject struct {
@@ -144,4 +158,7 @@ type summaryOutput struct {
 	TotalBytes     uint64 `json:"total_bytes,omitempty"`
 	BytesRestored  uint64 `json:"bytes_restored,omitempty"`
 	BytesSkipped   uint64 `json:"bytes_skipped,omitempty"`
+	BytesDeleted   uint64 `json:"bytes_deleted,omitempty"`
+	CaseCollisions uint64 `json:"case_collisions,omitempty"`
+	DryRun         bool   `json:"dry_run,omitempty"`
 }
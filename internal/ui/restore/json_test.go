@@ -11,40 +11,46 @@ import (
 
 func createJSONProgress() (*ui.MockTerminal, ProgressPrinter) {
 	term := &ui.MockTerminal{}
-	printer := NewJSONProgress(term, 3)
+	printer := NewJSONProgress(term, 3, false)
 	return term, printer
 }
 
 func TestJSONPrintUpdate(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Update(State{3, 11, 0, 0, 29, 47, 0}, 5*time.Second)
+	printer.Update(State{3, 11, 0, 0, 29, 47, 0, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"status\",\"seconds_elapsed\":5,\"percent_done\":0.6170212765957447,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29}\n"}, term.Output)
 }
 
 func TestJSONPrintUpdateWithSkipped(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Update(State{3, 11, 2, 0, 29, 47, 59}, 5*time.Second)
+	printer.Update(State{3, 11, 2, 0, 29, 47, 59, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"status\",\"seconds_elapsed\":5,\"percent_done\":0.6170212765957447,\"total_files\":11,\"files_restored\":3,\"files_skipped\":2,\"total_bytes\":47,\"bytes_restored\":29,\"bytes_skipped\":59}\n"}, term.Output)
 }
 
 func TestJSONPrintSummaryOnSuccess(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Finish(State{11, 11, 0, 0, 47, 47, 0}, 5*time.Second)
+	printer.Finish(State{11, 11, 0, 0, 47, 47, 0, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"total_bytes\":47,\"bytes_restored\":47}\n"}, term.Output)
 }
 
 func TestJSONPrintSummaryOnErrors(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Finish(State{3, 11, 0, 0, 29, 47, 0}, 5*time.Second)
+	printer.Finish(State{3, 11, 0, 0, 29, 47, 0, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29}\n"}, term.Output)
 }
 
 func TestJSONPrintSummaryOnSuccessWithSkipped(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Finish(State{11, 11, 2, 0, 47, 47, 59}, 5*time.Second)
+	printer.Finish(State{11, 11, 2, 0, 47, 47, 59, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"files_skipped\":2,\"total_bytes\":47,\"bytes_restored\":47,\"bytes_skipped\":59}\n"}, term.Output)
 }
 
+func TestJSONPrintSummaryWithCaseCollisions(t *testing.T) {
+	term, printer := createJSONProgress()
+	printer.Finish(State{11, 11, 0, 0, 47, 47, 0, 0, 3}, 5*time.Second)
+	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"total_bytes\":47,\"bytes_restored\":47,\"case_collisions\":3}\n"}, term.Output)
+}
+
 func TestJSONPrintCompleteItem(t *testing.T) {
 	for _, data := range []struct {
 		action   ItemAction
@@ -68,3 +74,16 @@ func TestJSONError(t *testing.T) {
 	test.Equals(t, printer.Error("/path", errors.New("error \"message\"")), nil)
 	test.Equals(t, []string{"{\"message_type\":\"error\",\"error\":{\"message\":\"error \\\"message\\\"\"},\"during\":\"restore\",\"item\":\"/path\"}\n"}, term.Errors)
 }
+
+func TestJSONPrintSummaryDryRun(t *testing.T) {
+	term := &ui.MockTerminal{}
+	printer := NewJSONProgress(term, 2, true)
+	printer.Finish(State{11, 11, 0, 2, 47, 47, 0, 12, 0}, 5*time.Second)
+	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"files_deleted\":2,\"total_bytes\":47,\"bytes_restored\":47,\"bytes_deleted\":12,\"dry_run\":true}\n"}, term.Output)
+
+	term = &ui.MockTerminal{}
+	printer = NewJSONProgress(term, 2, true)
+	printer.CompleteItem(ActionFileUnchanged, "test", 123)
+	printer.CompleteItem(ActionDeleted, "test", 123)
+	test.Equals(t, []string{"{\"message_type\":\"verbose_status\",\"action\":\"deleted\",\"item\":\"test\",\"size\":123}\n"}, term.Output)
+}
@@ -15,6 +15,8 @@ type State struct {
 	AllBytesWritten uint64
 	AllBytesTotal   uint64
 	AllBytesSkipped uint64
+	AllBytesDeleted uint64
+	CaseCollisions  uint64
 }
 
 type Progress struct {
@@ -125,17 +127,33 @@ func (p *Progress) AddSkippedFile(name string, size uint64) {
 	p.printer.CompleteItem(ActionFileUnchanged, name, size)
 }
 
-func (p *Progress) ReportDeletion(name string) {
+// ReportDeletion reports that the file or directory name with the given size
+// was deleted. The size of directories should be zero.
+func (p *Progress) ReportDeletion(name string, size uint64) {
 	if p == nil {
 		return
 	}
 
+	p.m.Lock()
+	defer p.m.Unlock()
+
 	p.s.FilesDeleted++
+	p.s.AllBytesDeleted += size
+
+	p.printer.CompleteItem(ActionDeleted, name, size)
+}
+
+// ReportCaseCollision reports that the name of the item only differs in case
+// from another item in the same directory.
+func (p *Progress) ReportCaseCollision() {
+	if p == nil {
+		return
+	}
 
 	p.m.Lock()
 	defer p.m.Unlock()
 
-	p.printer.CompleteItem(ActionDeleted, name, 0)
+	p.s.CaseCollisions++
 }
 
 func (p *Progress) Error(item string, err error) error {
@@ -11,12 +11,17 @@ type textPrinter struct {
 	*ui.Message
 
 	terminal ui.Terminal
+	dryRun   bool
 }
 
-func NewTextProgress(terminal ui.Terminal, verbosity uint) ProgressPrinter {
+// NewTextProgress returns a new restore progress printer. If dryRun is set,
+// the printed messages describe what a restore would do and the list of
+// changed files is already printed for verbosity level 2.
+func NewTextProgress(terminal ui.Terminal, verbosity uint, dryRun bool) ProgressPrinter {
 	return &textPrinter{
 		Message:  ui.NewMessage(terminal, verbosity),
 		terminal: terminal,
+		dryRun:   dryRun,
 	}
 }
 
@@ -31,7 +36,7 @@ func (t *textPrinter) Update(p State, duration time.Duration) {
 		progress += fmt.Sprintf(", skipped %v files/dirs %v", p.FilesSkipped, ui.FormatBytes(p.AllBytesSkipped))
 	}
 	if p.FilesDeleted > 0 {
-		progress += fmt.Sprintf(", deleted %v files/dirs", p.FilesDeleted)
+		progress += formatDeleted(p, "deleted")
 	}
 
 	t.terminal.SetStatus([]string{progress})
@@ -61,11 +66,36 @@ func (t *textPrinter) CompleteItem(messageType ItemAction, item string, size uin
 		panic("unknown message type")
 	}
 
-	if messageType == ActionDirRestored || messageType == ActionOtherRestored || messageType == ActionDeleted {
-		t.VV("%-9v %v", action, item)
+	printFn := t.VV
+	width := 9
+	if t.dryRun {
+		// the list of changes is the main output of a dry run
+		if messageType != ActionFileUnchanged {
+			printFn = t.V
+		}
+		action = dryRunActions[action]
+		width = 13
+	}
+
+	if messageType == ActionDirRestored || messageType == ActionOtherRestored || (messageType == ActionDeleted && size == 0) {
+		printFn("%-*v %v", width, action, item)
 	} else {
-		t.VV("%-9v %v with size %v", action, item, ui.FormatBytes(size))
+		printFn("%-*v %v with size %v", width, action, item, ui.FormatBytes(size))
+	}
+}
+
+var dryRunActions = map[string]string{
+	"restored":  "would restore",
+	"updated":   "would update",
+	"unchanged": "unchanged",
+	"deleted":   "would delete",
+}
+
+func formatDeleted(p State, verb string) string {
+	if p.AllBytesDeleted == 0 {
+		return fmt.Sprintf(", %s %v files/dirs", verb, p.FilesDeleted)
 	}
+	return fmt.Sprintf(", %s %v files/dirs %v", verb, p.FilesDeleted, ui.FormatBytes(p.AllBytesDeleted))
 }
 
 func (t *textPrinter) Finish(p State, duration time.Duration) {
@@ -74,19 +104,27 @@ func (t *textPrinter) Finish(p State, duration time.Duration) {
 	timeLeft := ui.FormatDuration(duration)
 	formattedAllBytesTotal := ui.FormatBytes(p.AllBytesTotal)
 
+	verb, deleteVerb := "Restored", "deleted"
+	if t.dryRun {
+		verb, deleteVerb = "Would restore", "would delete"
+	}
+
 	var summary string
 	if p.FilesFinished == p.FilesTotal && p.AllBytesWritten == p.AllBytesTotal {
-		summary = fmt.Sprintf("Summary: Restored %d files/dirs (%s) in %s", p.FilesTotal, formattedAllBytesTotal, timeLeft)
+		summary = fmt.Sprintf("Summary: %s %d files/dirs (%s) in %s", verb, p.FilesTotal, formattedAllBytesTotal, timeLeft)
 	} else {
 		formattedAllBytesWritten := ui.FormatBytes(p.AllBytesWritten)
-		summary = fmt.Sprintf("Summary: Restored %d / %d files/dirs (%s / %s) in %s",
-			p.FilesFinished, p.FilesTotal, formattedAllBytesWritten, formattedAllBytesTotal, timeLeft)
+		summary = fmt.Sprintf("Summary: %s %d / %d files/dirs (%s / %s) in %s",
+			verb, p.FilesFinished, p.FilesTotal, formattedAllBytesWritten, formattedAllBytesTotal, timeLeft)
 	}
 	if p.FilesSkipped > 0 {
 		summary += fmt.Sprintf(", skipped %v files/dirs %v", p.FilesSkipped, ui.FormatBytes(p.AllBytesSkipped))
 	}
 	if p.FilesDeleted > 0 {
-		summary += fmt.Sprintf(", deleted %v files/dirs", p.FilesDeleted)
+		summary += formatDeleted(p, deleteVerb)
+	}
+	if p.CaseCollisions > 0 {
+		summary += fmt.Sprintf(", %v case collisions", p.CaseCollisions)
 	}
 
 	t.terminal.Print(summary)
@@ -11,40 +11,46 @@ import (
 
 func createTextProgress() (*ui.MockTerminal, ProgressPrinter) {
 	term := &ui.MockTerminal{}
-	printer := NewTextProgress(term, 3)
+	printer := NewTextProgress(term, 3, false)
 	return term, printer
 }
 
 func TestPrintUpdate(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Update(State{3, 11, 0, 0, 29, 47, 0}, 5*time.Second)
+	printer.Update(State{3, 11, 0, 0, 29, 47, 0, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"[0:05] 61.70%  3 files/dirs 29 B, total 11 files/dirs 47 B"}, term.Output)
 }
 
 func TestPrintUpdateWithSkipped(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Update(State{3, 11, 2, 0, 29, 47, 59}, 5*time.Second)
+	printer.Update(State{3, 11, 2, 0, 29, 47, 59, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"[0:05] 61.70%  3 files/dirs 29 B, total 11 files/dirs 47 B, skipped 2 files/dirs 59 B"}, term.Output)
 }
 
 func TestPrintSummaryOnSuccess(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Finish(State{11, 11, 0, 0, 47, 47, 0}, 5*time.Second)
+	printer.Finish(State{11, 11, 0, 0, 47, 47, 0, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05"}, term.Output)
 }
 
 func TestPrintSummaryOnErrors(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Finish(State{3, 11, 0, 0, 29, 47, 0}, 5*time.Second)
+	printer.Finish(State{3, 11, 0, 0, 29, 47, 0, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 3 / 11 files/dirs (29 B / 47 B) in 0:05"}, term.Output)
 }
 
 func TestPrintSummaryOnSuccessWithSkipped(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Finish(State{11, 11, 2, 0, 47, 47, 59}, 5*time.Second)
+	printer.Finish(State{11, 11, 2, 0, 47, 47, 59, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05, skipped 2 files/dirs 59 B"}, term.Output)
 }
 
+func TestPrintSummaryWithCaseCollisions(t *testing.T) {
+	term, printer := createTextProgress()
+	printer.Finish(State{11, 11, 0, 0, 47, 47, 0, 0, 3}, 5*time.Second)
+	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05, 3 case collisions"}, term.Output)
+}
+
 func TestPrintCompleteItem(t *testing.T) {
 	for _, data := range []struct {
 		action   ItemAction
@@ -69,3 +75,31 @@ func TestError(t *testing.T) {
 	test.Equals(t, printer.Error("/path", errors.New("error \"message\"")), nil)
 	test.Equals(t, []string{"ignoring error for /path: error \"message\"\n"}, term.Errors)
 }
+
+func TestPrintSummaryDryRun(t *testing.T) {
+	term := &ui.MockTerminal{}
+	printer := NewTextProgress(term, 3, true)
+	printer.Finish(State{11, 11, 0, 2, 47, 47, 0, 12, 0}, 5*time.Second)
+	test.Equals(t, []string{"Summary: Would restore 11 files/dirs (47 B) in 0:05, would delete 2 files/dirs 12 B"}, term.Output)
+}
+
+func TestPrintCompleteItemDryRun(t *testing.T) {
+	for _, data := range []struct {
+		action   ItemAction
+		size     uint64
+		expected []string
+	}{
+		{ActionDirRestored, 0, []string{"would restore test"}},
+		{ActionFileRestored, 123, []string{"would restore test with size 123 B"}},
+		{ActionFileUpdated, 123, []string{"would update  test with size 123 B"}},
+		{ActionFileUnchanged, 123, nil},
+		{ActionDeleted, 0, []string{"would delete  test"}},
+		{ActionDeleted, 123, []string{"would delete  test with size 123 B"}},
+	} {
+		term := &ui.MockTerminal{}
+		// verbosity 2 must be sufficient to list changes
+		printer := NewTextProgress(term, 2, true)
+		printer.CompleteItem(data.action, "test", data.size)
+		test.Equals(t, data.expected, term.Output)
+	}
+}
@@ -0,0 +1,104 @@
+package walker
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// TreeLoader loads trees from a repository using a bounded number of
+// concurrent requests.
+type TreeLoader struct {
+	repo restic.BlobLoader
+	sem  chan struct{}
+}
+
+// NewTreeLoader returns a TreeLoader which runs at most parallelism requests
+// at the same time. With a parallelism of one or less, all trees are loaded
+// synchronously.
+func NewTreeLoader(repo restic.BlobLoader, parallelism int) *TreeLoader {
+	l := &TreeLoader{repo: repo}
+	if parallelism > 1 {
+		l.sem = make(chan struct{}, parallelism)
+	}
+	return l
+}
+
+// LoadTree loads the tree with the given id.
+func (l *TreeLoader) LoadTree(ctx context.Context, id restic.ID) (*restic.Tree, error) {
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-l.sem }()
+	}
+	return restic.LoadTree(ctx, l.repo, id)
+}
+
+type loadedTree struct {
+	tree *restic.Tree
+	err  error
+}
+
+// TreeQueue returns trees in a fixed order. While the caller processes a
+// tree, the following trees are already loaded in the background.
+type TreeQueue struct {
+	loader  *TreeLoader
+	ctx     context.Context
+	ids     restic.IDs
+	pending []chan loadedTree
+	// next is the index of the tree returned by the next call to Next
+	next int
+}
+
+// Queue returns a queue which returns the trees with the given ids in order.
+// At most as many trees as the parallelism of the loader are loaded ahead of
+// the caller. Trees which are not requested from the queue are still loaded
+// once their background request has started.
+func (l *TreeLoader) Queue(ctx context.Context, ids restic.IDs) *TreeQueue {
+	return &TreeQueue{
+		loader:  l,
+		ctx:     ctx,
+		ids:     ids,
+		pending: make([]chan loadedTree, len(ids)),
+	}
+}
+
+// start begins loading the tree at index i in the background.
+func (q *TreeQueue) start(i int) {
+	ch := make(chan loadedTree, 1)
+	q.pending[i] = ch
+	id := q.ids[i]
+	go func() {
+		tree, err := q.loader.LoadTree(q.ctx, id)
+		ch <- loadedTree{tree, err}
+	}()
+}
+
+// Next returns the next tree of the queue. It must not be called more often
+// than the number of ids passed to Queue.
+func (q *TreeQueue) Next() (*restic.Tree, error) {
+	i := q.next
+	q.next++
+
+	if q.loader.sem == nil {
+		return q.loader.LoadTree(q.ctx, q.ids[i])
+	}
+
+	for j := i; j < len(q.ids) && j <= i+cap(q.loader.sem); j++ {
+		if q.pending[j] == nil {
+			q.start(j)
+		}
+	}
+
+	ch := q.pending[i]
+	q.pending[i] = nil
+	select {
+	case res := <-ch:
+		return res.tree, res.err
+	case <-q.ctx.Done():
+		return nil, q.ctx.Err()
+	}
+}
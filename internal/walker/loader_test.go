@@ -0,0 +1,67 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// countingLoader records the maximum number of concurrent requests.
+type countingLoader struct {
+	TreeMap
+	m       sync.Mutex
+	running int
+	max     int
+}
+
+func (l *countingLoader) LoadBlob(ctx context.Context, tpe restic.BlobType, id restic.ID, buf []byte) ([]byte, error) {
+	l.m.Lock()
+	l.running++
+	l.max = max(l.max, l.running)
+	l.m.Unlock()
+	defer func() {
+		l.m.Lock()
+		l.running--
+		l.m.Unlock()
+	}()
+	return l.TreeMap.LoadBlob(ctx, tpe, id, buf)
+}
+
+func TestTreeQueue(t *testing.T) {
+	tree := TestTree{}
+	for i := 0; i < 20; i++ {
+		tree[fmt.Sprintf("dir%02d", i)] = TestTree{fmt.Sprintf("file%02d", i): TestFile{}}
+	}
+	repo, root := BuildTreeMap(tree)
+
+	rootTree, err := restic.LoadTree(context.TODO(), repo, root)
+	rtest.OK(t, err)
+	var ids restic.IDs
+	for _, node := range rootTree.Nodes {
+		ids = append(ids, *node.Subtree)
+	}
+
+	for _, parallelism := range []int{1, 4} {
+		loader := &countingLoader{TreeMap: repo}
+		queue := NewTreeLoader(loader, parallelism).Queue(context.TODO(), ids)
+		for i := range ids {
+			subtree, err := queue.Next()
+			rtest.OK(t, err)
+			rtest.Equals(t, fmt.Sprintf("file%02d", i), subtree.Nodes[0].Name)
+		}
+		rtest.Assert(t, loader.max <= parallelism, "%d concurrent requests, expected at most %d", loader.max, parallelism)
+	}
+
+	// a missing tree is reported for its position only
+	queue := NewTreeLoader(repo, 4).Queue(context.TODO(), restic.IDs{ids[0], restic.NewRandomID(), ids[1]})
+	_, err = queue.Next()
+	rtest.OK(t, err)
+	_, err = queue.Next()
+	rtest.Assert(t, err != nil, "expected error for missing tree")
+	_, err = queue.Next()
+	rtest.OK(t, err)
+}
@@ -35,7 +35,16 @@ type WalkVisitor struct {
 // error, it is passed up the call stack. The trees in ignoreTrees are not
 // walked. If walkFn ignores trees, these are added to the set.
 func Walk(ctx context.Context, repo restic.BlobLoader, root restic.ID, visitor WalkVisitor) error {
-	tree, err := restic.LoadTree(ctx, repo, root)
+	return WalkParallel(ctx, repo, root, 1, visitor)
+}
+
+// WalkParallel works like Walk, but loads up to parallelism subtrees of a
+// directory concurrently. The visitor is still called sequentially and in the
+// same order as by Walk. Subtrees which are skipped by the visitor may
+// nevertheless be loaded.
+func WalkParallel(ctx context.Context, repo restic.BlobLoader, root restic.ID, parallelism int, visitor WalkVisitor) error {
+	loader := NewTreeLoader(repo, parallelism)
+	tree, err := loader.LoadTree(ctx, root)
 	err = visitor.ProcessNode(root, "/", nil, err)
 
 	if err != nil {
@@ -45,17 +54,25 @@ func Walk(ctx context.Context, repo restic.BlobLoader, root restic.ID, visitor W
 		return err
 	}
 
-	return walk(ctx, repo, "/", root, tree, visitor)
+	return walk(ctx, loader, "/", root, tree, visitor)
 }
 
 // walk recursively traverses the tree, ignoring subtrees when the ID of the
 // subtree is in ignoreTrees. If err is nil and ignore is true, the subtree ID
 // will be added to ignoreTrees by walk.
-func walk(ctx context.Context, repo restic.BlobLoader, prefix string, parentTreeID restic.ID, tree *restic.Tree, visitor WalkVisitor) (err error) {
+func walk(ctx context.Context, loader *TreeLoader, prefix string, parentTreeID restic.ID, tree *restic.Tree, visitor WalkVisitor) (err error) {
 	sort.Slice(tree.Nodes, func(i, j int) bool {
 		return tree.Nodes[i].Name < tree.Nodes[j].Name
 	})
 
+	var subtrees restic.IDs
+	for _, node := range tree.Nodes {
+		if node.Type == restic.NodeTypeDir && node.Subtree != nil {
+			subtrees = append(subtrees, *node.Subtree)
+		}
+	}
+	queue := loader.Queue(ctx, subtrees)
+
 	for _, node := range tree.Nodes {
 		if ctx.Err() != nil {
 			return ctx.Err()
@@ -85,7 +102,7 @@ func walk(ctx context.Context, repo restic.BlobLoader, prefix string, parentTree
 			return errors.Errorf("subtree for node %v in tree %v is nil", node.Name, p)
 		}
 
-		subtree, err := restic.LoadTree(ctx, repo, *node.Subtree)
+		subtree, err := queue.Next()
 		err = visitor.ProcessNode(parentTreeID, p, node, err)
 		if err != nil {
 			if err == ErrSkipNode {
@@ -93,7 +110,7 @@ func walk(ctx context.Context, repo restic.BlobLoader, prefix string, parentTree
 			}
 		}
 
-		err = walk(ctx, repo, p, *node.Subtree, subtree, visitor)
+		err = walk(ctx, loader, p, *node.Subtree, subtree, visitor)
 		if err != nil {
 			return err
 		}
@@ -97,8 +97,8 @@ type checkFunc func(t testing.TB) (walker WalkFunc, leaveDir func(path string) e
 
 // checkItemOrder ensures that the order of the 'path' arguments is the one passed in as 'want'.
 func checkItemOrder(want []string) checkFunc {
-	pos := 0
 	return func(t testing.TB) (walker WalkFunc, leaveDir func(path string) error, final func(testing.TB)) {
+		pos := 0
 		walker = func(treeID restic.ID, path string, node *restic.Node, err error) error {
 			if err != nil {
 				t.Errorf("error walking %v: %v", path, err)
@@ -133,8 +133,8 @@ func checkItemOrder(want []string) checkFunc {
 
 // checkParentTreeOrder ensures that the order of the 'parentID' arguments is the one passed in as 'want'.
 func checkParentTreeOrder(want []string) checkFunc {
-	pos := 0
 	return func(t testing.TB) (walker WalkFunc, leaveDir func(path string) error, final func(testing.TB)) {
+		pos := 0
 		walker = func(treeID restic.ID, path string, node *restic.Node, err error) error {
 			if err != nil {
 				t.Errorf("error walking %v: %v", path, err)
@@ -166,9 +166,8 @@ func checkParentTreeOrder(want []string) checkFunc {
 // checkSkipFor returns ErrSkipNode if path is in skipFor, it checks that the
 // paths the walk func is called for are exactly the ones in wantPaths.
 func checkSkipFor(skipFor map[string]struct{}, wantPaths []string) checkFunc {
-	var pos int
-
 	return func(t testing.TB) (walker WalkFunc, leaveDir func(path string) error, final func(testing.TB)) {
+		var pos int
 		walker = func(treeID restic.ID, path string, node *restic.Node, err error) error {
 			if err != nil {
 				t.Errorf("error walking %v: %v", path, err)
@@ -433,20 +432,22 @@ func TestWalker(t *testing.T) {
 		t.Run("", func(t *testing.T) {
 			repo, root := BuildTreeMap(test.tree)
 			for _, check := range test.checks {
-				t.Run("", func(t *testing.T) {
-					ctx, cancel := context.WithCancel(context.TODO())
-					defer cancel()
-
-					fn, leaveDir, last := check(t)
-					err := Walk(ctx, repo, root, WalkVisitor{
-						ProcessNode: fn,
-						LeaveDir:    leaveDir,
+				for _, parallelism := range []int{1, 3} {
+					t.Run(fmt.Sprintf("parallelism-%d", parallelism), func(t *testing.T) {
+						ctx, cancel := context.WithCancel(context.TODO())
+						defer cancel()
+
+						fn, leaveDir, last := check(t)
+						err := WalkParallel(ctx, repo, root, parallelism, WalkVisitor{
+							ProcessNode: fn,
+							LeaveDir:    leaveDir,
+						})
+						if err != nil {
+							t.Error(err)
+						}
+						last(t)
 					})
-					if err != nil {
-						t.Error(err)
-					}
-					last(t)
-				})
+				}
 			}
 		})
 	}
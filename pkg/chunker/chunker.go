@@ -0,0 +1,159 @@
+// Package chunker splits a stream of data into content defined chunks. It
+// wraps github.com/restic/chunker, which is used by restic to split files
+// into blobs, and adds support for cancellation using a context. The large
+// internal buffers of a Chunker are reused after it is closed.
+//
+// The chunk boundaries only depend on the data and the polynomial. Using the
+// polynomial stored in the config of a repository, the same chunks are
+// produced as when restic backs up the data.
+package chunker
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/restic/chunker"
+)
+
+// Pol is an irreducible polynomial used for chunking.
+type Pol = chunker.Pol
+
+// Chunk is one content defined chunk of data.
+type Chunk = chunker.Chunk
+
+const (
+	// MinSize is the minimal size of a chunk.
+	MinSize = chunker.MinSize
+	// MaxSize is the maximal size of a chunk.
+	MaxSize = chunker.MaxSize
+)
+
+// RandomPolynomial returns a new random irreducible polynomial.
+func RandomPolynomial() (Pol, error) {
+	return chunker.RandomPolynomial()
+}
+
+var (
+	// chunkers contains unused *chunker.Chunker, each has a read buffer of
+	// 512 KiB.
+	chunkers sync.Pool
+	// buffers contains unused chunk buffers with a capacity of MaxSize.
+	buffers = sync.Pool{New: func() interface{} {
+		buf := make([]byte, 0, MaxSize)
+		return &buf
+	}}
+)
+
+var _ io.WriterTo = &Chunker{}
+
+// contextReader returns the error of ctx instead of reading once the context
+// is canceled.
+type contextReader struct {
+	ctx context.Context
+	rd  io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.rd.Read(p)
+}
+
+// Chunker splits the data read from a reader into content defined chunks. A
+// Chunker must be closed after use to make its buffers available for other
+// chunkers. It is not safe for concurrent use.
+type Chunker struct {
+	c   *chunker.Chunker
+	rd  contextReader
+	buf *[]byte
+}
+
+// New returns a Chunker which splits the data read from rd using the
+// polynomial pol.
+func New(rd io.Reader, pol Pol) *Chunker {
+	c := &Chunker{buf: buffers.Get().(*[]byte)}
+	c.rd = contextReader{ctx: context.Background(), rd: rd}
+
+	if pooled, ok := chunkers.Get().(*chunker.Chunker); ok {
+		c.c = pooled
+		c.c.Reset(&c.rd, pol)
+	} else {
+		c.c = chunker.New(&c.rd, pol)
+	}
+	return c
+}
+
+// Reset restarts chunking with the data read from rd using the polynomial
+// pol. This also allows continuing after an error.
+func (c *Chunker) Reset(rd io.Reader, pol Pol) {
+	c.rd = contextReader{ctx: context.Background(), rd: rd}
+	c.c.Reset(&c.rd, pol)
+}
+
+// Next returns the next chunk, see NextContext.
+func (c *Chunker) Next() (Chunk, error) {
+	return c.NextContext(context.Background())
+}
+
+// NextContext returns the next chunk. The data of the chunk is only valid
+// until the next call to NextContext, Next or WriteTo. After the last chunk,
+// io.EOF is returned. If ctx is canceled while the chunk is read, the error of
+// ctx is returned. After an error, the chunker must be reset before it can be
+// used again.
+func (c *Chunker) NextContext(ctx context.Context) (Chunk, error) {
+	if c.c == nil {
+		panic("chunker is already closed")
+	}
+	c.rd.ctx = ctx
+	chunk, err := c.c.Next(*c.buf)
+	c.rd.ctx = context.Background()
+	if err != nil {
+		return Chunk{}, err
+	}
+	return chunk, nil
+}
+
+// WriteTo writes the data of all remaining chunks to w. Each chunk is written
+// using a single call to w.Write, such that w can process the chunks one by
+// one without copying them. The data passed to w.Write must not be retained.
+// WriteTo implements io.WriterTo.
+func (c *Chunker) WriteTo(w io.Writer) (n int64, err error) {
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		written, err := w.Write(chunk.Data)
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+		if written != len(chunk.Data) {
+			return n, io.ErrShortWrite
+		}
+	}
+}
+
+// Close releases the buffers of the chunker. The chunker must not be used
+// afterwards.
+func (c *Chunker) Close() error {
+	if c.c == nil {
+		return nil
+	}
+
+	// the pooled chunker still references c.rd, drop the reader
+	c.rd = contextReader{}
+	chunkers.Put(c.c)
+	c.c = nil
+
+	*c.buf = (*c.buf)[:0]
+	buffers.Put(c.buf)
+	c.buf = nil
+	return nil
+}
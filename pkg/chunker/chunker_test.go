@@ -0,0 +1,127 @@
+package chunker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/restic/chunker"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+const testPol = Pol(0x3DA3358B4DC173)
+
+// expectedChunks returns the chunks produced by the upstream chunker.
+func expectedChunks(t *testing.T, data []byte) [][]byte {
+	var chunks [][]byte
+	c := chunker.New(bytes.NewReader(data), testPol)
+	for {
+		chunk, err := c.Next(nil)
+		if err == io.EOF {
+			return chunks
+		}
+		rtest.OK(t, err)
+		chunks = append(chunks, chunk.Data)
+	}
+}
+
+func TestChunkerBoundaries(t *testing.T) {
+	data := rtest.Random(23, 20*1024*1024)
+	expected := expectedChunks(t, data)
+	rtest.Assert(t, len(expected) > 1, "expected more than one chunk")
+
+	// run twice to also use pooled buffers
+	for i := 0; i < 2; i++ {
+		c := New(bytes.NewReader(data), testPol)
+		for j, exp := range expected {
+			chunk, err := c.NextContext(context.Background())
+			rtest.OK(t, err)
+			rtest.Assert(t, bytes.Equal(exp, chunk.Data), "chunk %d differs", j)
+		}
+		_, err := c.Next()
+		rtest.Equals(t, io.EOF, err)
+		rtest.OK(t, c.Close())
+	}
+}
+
+func TestChunkerReset(t *testing.T) {
+	data := rtest.Random(42, 5*1024*1024)
+	expected := expectedChunks(t, data)
+
+	c := New(bytes.NewReader(rtest.Random(1, 1024)), testPol)
+	defer func() {
+		rtest.OK(t, c.Close())
+	}()
+	_, err := c.Next()
+	rtest.OK(t, err)
+
+	c.Reset(bytes.NewReader(data), testPol)
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len(data)), n)
+	rtest.Equals(t, bytes.Join(expected, nil), buf.Bytes())
+}
+
+func TestChunkerContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := New(bytes.NewReader(rtest.Random(5, 1024*1024)), testPol)
+	defer func() {
+		rtest.OK(t, c.Close())
+	}()
+	_, err := c.NextContext(ctx)
+	rtest.Assert(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+}
+
+// chunkWriter records the size of each call to Write.
+type chunkWriter struct {
+	sizes []int
+	total int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.sizes = append(w.sizes, len(p))
+	w.total += len(p)
+	return len(p), nil
+}
+
+func TestChunkerWriteTo(t *testing.T) {
+	data := rtest.Random(7, 10*1024*1024)
+	expected := expectedChunks(t, data)
+
+	c := New(bytes.NewReader(data), testPol)
+	defer func() {
+		rtest.OK(t, c.Close())
+	}()
+
+	var w chunkWriter
+	n, err := c.WriteTo(&w)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len(data)), n)
+	rtest.Equals(t, len(data), w.total)
+	rtest.Equals(t, len(expected), len(w.sizes))
+	for i, exp := range expected {
+		rtest.Equals(t, len(exp), w.sizes[i])
+	}
+}
+
+func BenchmarkChunker(b *testing.B) {
+	data := rtest.Random(23, 10*1024*1024)
+	rd := bytes.NewReader(data)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rd.Reset(data)
+		c := New(rd, testPol)
+		_, err := c.WriteTo(io.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,182 @@
+package restic
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/filter"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// BackupOptions configure a backup.
+type BackupOptions struct {
+	// Host is the hostname stored in the snapshot. If empty, the hostname of
+	// the system is used.
+	Host string
+	// Tags are added to the snapshot.
+	Tags []string
+	// Excludes contains patterns for files and directories which are not
+	// included in the backup, see the --exclude option of the restic command.
+	Excludes []string
+	// Parent is the ID of the snapshot used to detect unchanged files. If
+	// empty, the latest snapshot of the same host and paths is used.
+	Parent string
+	// Time is stored as time of the snapshot. If zero, the current time is
+	// used.
+	Time time.Time
+	// ProgramVersion is stored in the snapshot to identify the program which
+	// created it.
+	ProgramVersion string
+}
+
+// BackupSummary describes a completed backup.
+type BackupSummary struct {
+	// SnapshotID is the ID of the new snapshot.
+	SnapshotID string
+
+	FilesNew        uint
+	FilesChanged    uint
+	FilesUnmodified uint
+	DirsNew         uint
+	DirsChanged     uint
+	DirsUnmodified  uint
+	// DataAdded is the size of the data added to the repository.
+	DataAdded uint64
+	// TotalBytesProcessed is the size of all files included in the backup.
+	TotalBytesProcessed uint64
+
+	BackupStart time.Time
+	BackupEnd   time.Time
+
+	// Warnings lists files and directories which could not be read. These
+	// are not included in the snapshot.
+	Warnings []string
+}
+
+// Backup creates a new snapshot of the files and directories in paths.
+// Relative paths are interpreted relative to the current working directory.
+// If some files cannot be read, the snapshot is saved nevertheless and the
+// affected files are listed in BackupSummary.Warnings.
+func (r *Repository) Backup(ctx context.Context, paths []string, opts BackupOptions) (*BackupSummary, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no paths to back up")
+	}
+
+	targets := make([]string, 0, len(paths))
+	for _, p := range paths {
+		target, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+
+	if opts.Host == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		opts.Host = host
+	}
+
+	backupStart := time.Now()
+	if opts.Time.IsZero() {
+		opts.Time = backupStart
+	}
+
+	if err := filter.ValidatePatterns(opts.Excludes); err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+
+	ctx, unlock, err := r.lock(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	parent, err := r.findParent(ctx, opts, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.repo.LoadIndex(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	arch := archiver.New(r.repo, fs.Local{}, archiver.Options{})
+	if len(opts.Excludes) > 0 {
+		arch.SelectByName = archiver.CombineRejectByNames([]archiver.RejectByNameFunc{
+			archiver.RejectByNameFunc(filter.RejectByPattern(opts.Excludes, r.opts.warnf)),
+		})
+	}
+
+	var m sync.Mutex
+	var warnings []string
+	arch.Error = func(item string, err error) error {
+		if errors.IsFatal(err) {
+			return err
+		}
+		m.Lock()
+		warnings = append(warnings, fmt.Sprintf("%v: %v", item, err))
+		m.Unlock()
+		return nil
+	}
+
+	_, id, summary, err := arch.Snapshot(ctx, targets, archiver.SnapshotOptions{
+		Tags:           opts.Tags,
+		Hostname:       opts.Host,
+		Excludes:       opts.Excludes,
+		BackupStart:    backupStart,
+		Time:           opts.Time,
+		ParentSnapshot: parent,
+		ProgramVersion: opts.ProgramVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to save snapshot: %w", err)
+	}
+
+	return &BackupSummary{
+		SnapshotID:          id.String(),
+		FilesNew:            summary.Files.New,
+		FilesChanged:        summary.Files.Changed,
+		FilesUnmodified:     summary.Files.Unchanged,
+		DirsNew:             summary.Dirs.New,
+		DirsChanged:         summary.Dirs.Changed,
+		DirsUnmodified:      summary.Dirs.Unchanged,
+		DataAdded:           summary.ItemStats.DataSizeInRepo + summary.ItemStats.TreeSizeInRepo,
+		TotalBytesProcessed: summary.ProcessedBytes,
+		BackupStart:         summary.BackupStart,
+		BackupEnd:           summary.BackupEnd,
+		Warnings:            warnings,
+	}, nil
+}
+
+// findParent returns the parent snapshot for a backup, or nil if there is
+// none.
+func (r *Repository) findParent(ctx context.Context, opts BackupOptions, targets []string) (*restic.Snapshot, error) {
+	if opts.Parent != "" {
+		sn, _, err := restic.FindSnapshot(ctx, r.repo, r.repo, opts.Parent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find parent snapshot: %w", err)
+		}
+		return sn, nil
+	}
+
+	f := restic.SnapshotFilter{
+		Hosts:          []string{opts.Host},
+		Paths:          targets,
+		TimestampLimit: opts.Time,
+	}
+	sn, _, err := f.FindLatest(ctx, r.repo, r.repo, "latest")
+	if errors.Is(err, restic.ErrNoSnapshotFound) {
+		return nil, nil
+	}
+	return sn, err
+}
@@ -0,0 +1,28 @@
+// Package restic is the supported Go API for embedding restic in other
+// programs. It allows creating and opening repositories, creating and
+// restoring backups, listing snapshots and removing snapshots according to a
+// policy, without running the restic command line program.
+//
+// The types in this package are stable: fields and functions are only added,
+// but not removed or changed in an incompatible way. The implementation is
+// based on the same code as the restic command, so repositories written by
+// this package can be used by the restic command and vice versa. All other
+// packages of restic are internal and may change at any time.
+//
+// A minimal example:
+//
+//	repo, err := restic.Open(ctx, restic.Options{
+//		Location: "/srv/restic-repo",
+//		Password: "secret",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer repo.Close()
+//
+//	summary, err := repo.Backup(ctx, []string{"/home/user"}, restic.BackupOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Printf("snapshot %v saved\n", summary.SnapshotID)
+package restic
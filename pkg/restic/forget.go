@@ -0,0 +1,136 @@
+package restic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ForgetPolicy specifies which snapshots are kept, see the forget command of
+// restic for a detailed description. Snapshots are grouped by host and paths,
+// the policy is applied to each group separately.
+type ForgetPolicy struct {
+	KeepLast    int
+	KeepHourly  int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	KeepYearly  int
+	// KeepWithin keeps all snapshots created within this duration before the
+	// newest snapshot of a group. It is rounded down to full hours.
+	KeepWithin time.Duration
+	// KeepTags keeps all snapshots which have at least one of the tags.
+	KeepTags []string
+}
+
+// ForgetOptions configure which snapshots are removed.
+type ForgetOptions struct {
+	// Snapshots lists the IDs of snapshots to remove. If set, the policy is
+	// not used.
+	Snapshots []string
+	// Filter limits the snapshots to which the policy is applied.
+	Filter SnapshotFilter
+	// Policy specifies which snapshots are kept.
+	Policy ForgetPolicy
+	// DryRun only reports which snapshots would be removed.
+	DryRun bool
+	// OverrideHold removes snapshots even though the repository is under a
+	// legal hold. The justification is recorded in the hold.
+	OverrideHold string
+}
+
+// ForgetResult lists the kept and removed snapshots.
+type ForgetResult struct {
+	Keep   []Snapshot
+	Remove []Snapshot
+}
+
+func (p ForgetPolicy) internal() restic.ExpirePolicy {
+	policy := restic.ExpirePolicy{
+		Last:    p.KeepLast,
+		Hourly:  p.KeepHourly,
+		Daily:   p.KeepDaily,
+		Weekly:  p.KeepWeekly,
+		Monthly: p.KeepMonthly,
+		Yearly:  p.KeepYearly,
+		Within:  restic.Duration{Hours: int(p.KeepWithin / time.Hour)},
+	}
+	for _, tag := range p.KeepTags {
+		policy.Tags = append(policy.Tags, restic.TagList{tag})
+	}
+	return policy
+}
+
+// Forget removes snapshots from the repository, either the snapshots listed
+// in opts.Snapshots or those which are not kept by opts.Policy. The data
+// referenced by the removed snapshots remains in the repository until it is
+// pruned using the restic command. If the repository is under a legal hold,
+// an error wrapping ErrHold is returned unless opts.OverrideHold is set.
+func (r *Repository) Forget(ctx context.Context, opts ForgetOptions) (*ForgetResult, error) {
+	policy := opts.Policy.internal()
+	if len(opts.Snapshots) == 0 && policy.Empty() {
+		return nil, errors.New("no policy was specified, no snapshots will be removed")
+	}
+
+	ctx, unlock, err := r.lock(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if !opts.DryRun {
+		hold, err := restic.CheckHold(ctx, r.repo, "forget", opts.OverrideHold)
+		var herr *restic.HoldError
+		if errors.As(err, &herr) {
+			return nil, fmt.Errorf("%w since %v: %v", ErrHold, herr.Hold.Time.Format(time.RFC3339), herr.Hold.Reason)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hold != nil {
+			r.opts.warnf("overriding legal hold (%v) with justification: %v", hold.Reason, opts.OverrideHold)
+		}
+	}
+
+	snapshots, err := r.findSnapshots(ctx, opts.Filter, opts.Snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ForgetResult
+	var remove restic.Snapshots
+	if len(opts.Snapshots) > 0 {
+		remove = snapshots
+	} else {
+		groups, _, err := restic.GroupSnapshots(snapshots, restic.SnapshotGroupByOptions{Host: true, Path: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			keep, rm, _ := restic.ApplyPolicy(group, policy)
+			for _, sn := range keep {
+				result.Keep = append(result.Keep, newSnapshot(sn))
+			}
+			remove = append(remove, rm...)
+		}
+	}
+
+	// groups are not ordered
+	sort.SliceStable(result.Keep, func(i, j int) bool { return result.Keep[i].Time.Before(result.Keep[j].Time) })
+	sort.SliceStable(remove, func(i, j int) bool { return remove[i].Time.Before(remove[j].Time) })
+
+	for _, sn := range remove {
+		result.Remove = append(result.Remove, newSnapshot(sn))
+		if opts.DryRun {
+			continue
+		}
+		if err := r.repo.RemoveUnpacked(ctx, restic.SnapshotFile, *sn.ID()); err != nil {
+			return &result, fmt.Errorf("unable to remove snapshot %v: %w", sn.ID().Str(), err)
+		}
+	}
+	return &result, nil
+}
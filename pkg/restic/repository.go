@@ -0,0 +1,305 @@
+package restic
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/azure"
+	"github.com/restic/restic/internal/backend/b2"
+	"github.com/restic/restic/internal/backend/cache"
+	"github.com/restic/restic/internal/backend/gdrive"
+	"github.com/restic/restic/internal/backend/gs"
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/logger"
+	"github.com/restic/restic/internal/backend/rclone"
+	"github.com/restic/restic/internal/backend/rest"
+	"github.com/restic/restic/internal/backend/retry"
+	"github.com/restic/restic/internal/backend/s3"
+	"github.com/restic/restic/internal/backend/sema"
+	"github.com/restic/restic/internal/backend/sftp"
+	"github.com/restic/restic/internal/backend/smb"
+	"github.com/restic/restic/internal/backend/swift"
+	"github.com/restic/restic/internal/backend/transform"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+// maxKeys is the number of keys which are tried to find the one matching the
+// password, the same limit is used by the restic command.
+const maxKeys = 20
+
+// ErrNoRepository is returned by Open if there is no repository at the
+// location.
+var ErrNoRepository = errors.New("repository does not exist")
+
+// ErrHold is returned by operations which remove data if the repository is
+// under a legal hold, see the hold command of restic.
+var ErrHold = errors.New("repository is under a legal hold")
+
+// Options configure how a repository is created or opened.
+type Options struct {
+	// Location of the repository, using the same syntax as the --repo option
+	// of the restic command, for example "/srv/restic-repo" or
+	// "s3:s3.amazonaws.com/bucket/restic".
+	Location string
+	// Password of the repository.
+	Password string
+
+	// Extended contains backend specific options, using the same keys as the
+	// --option flag of the restic command, for example "s3.region". The
+	// credentials of a backend are read from the environment as usual. The
+	// options of pack transforms are also set here, for example
+	// "transform.sign.key-file".
+	Extended map[string]string
+
+	// PackTransforms lists the transforms which Init applies to all pack
+	// files of the new repository, see the --pack-transform option of the
+	// restic init command. If set, the repository is created using format
+	// version 3. When opening a repository, the transforms stored in its
+	// config are used.
+	PackTransforms []string
+
+	// CacheDir is the directory for the local cache. If empty, the default
+	// cache directory is used.
+	CacheDir string
+	// NoCache disables the local cache.
+	NoCache bool
+
+	// Compression is one of "auto", "off" or "max". If empty, "auto" is used.
+	Compression string
+	// PackSize is the target size of pack files in MiB. If zero, the default
+	// is used.
+	PackSize uint
+
+	// Warn is called for messages which are not fatal, for example when a
+	// backend request is retried. If nil, these messages are discarded.
+	Warn func(msg string)
+}
+
+func (opts Options) warnf(format string, args ...interface{}) {
+	if opts.Warn != nil {
+		opts.Warn(fmt.Sprintf(format, args...))
+	}
+}
+
+// Repository is a restic repository. It is safe for concurrent use.
+type Repository struct {
+	repo *repository.Repository
+	opts Options
+}
+
+// Init creates a new repository at opts.Location using the stable repository
+// format version and opens it.
+func Init(ctx context.Context, opts Options) (*Repository, error) {
+	if opts.Password == "" {
+		return nil, errors.New("an empty password is not allowed")
+	}
+
+	version := uint(restic.StableRepoVersion)
+	if len(opts.PackTransforms) > 0 {
+		version = restic.FeaturesRepoVersion
+		// make sure that the transforms are configured before creating the repository
+		if _, err := transform.Load(opts.PackTransforms, opts.Extended); err != nil {
+			return nil, err
+		}
+	}
+
+	be, err := openBackend(ctx, opts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := newRepository(be, opts)
+	if err != nil {
+		_ = be.Close()
+		return nil, err
+	}
+
+	err = repo.Init(ctx, version, opts.Password, nil, opts.PackTransforms)
+	if err != nil {
+		_ = be.Close()
+		return nil, fmt.Errorf("create repository at %v failed: %w", location.StripPassword(backends, opts.Location), err)
+	}
+
+	r := &Repository{repo: repo, opts: opts}
+	r.useCache()
+	return r, nil
+}
+
+// Open opens the existing repository at opts.Location. If there is no
+// repository, an error wrapping ErrNoRepository is returned.
+func Open(ctx context.Context, opts Options) (*Repository, error) {
+	be, err := openBackend(ctx, opts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = be.Stat(ctx, backend.Handle{Type: restic.ConfigFile})
+	if be.IsNotExist(err) {
+		// replacing the config may have been interrupted
+		var recovered bool
+		recovered, err = repository.RecoverConfig(ctx, be)
+		if err == nil && !recovered {
+			_, err = be.Stat(ctx, backend.Handle{Type: restic.ConfigFile})
+		}
+	}
+	if be.IsNotExist(err) {
+		_ = be.Close()
+		return nil, fmt.Errorf("%w at %v", ErrNoRepository, location.StripPassword(backends, opts.Location))
+	}
+	if err != nil {
+		_ = be.Close()
+		return nil, fmt.Errorf("unable to open config file: %w", err)
+	}
+
+	repo, err := newRepository(be, opts)
+	if err != nil {
+		_ = be.Close()
+		return nil, err
+	}
+
+	err = repo.SearchKey(ctx, opts.Password, maxKeys, "")
+	if err != nil {
+		_ = be.Close()
+		return nil, err
+	}
+
+	err = repository.CheckIndexManifest(ctx, repo)
+	var merr *repository.IndexManifestError
+	if errors.As(err, &merr) {
+		opts.warnf("%v, the repository was probably copied or synchronized incompletely. Missing index files: %v", err, append(merr.Missing, merr.Truncated...))
+	} else if err != nil {
+		opts.warnf("unable to verify index manifest: %v", err)
+	}
+
+	r := &Repository{repo: repo, opts: opts}
+	r.useCache()
+	return r, nil
+}
+
+// ID returns the unique ID of the repository.
+func (r *Repository) ID() string {
+	return r.repo.Config().ID
+}
+
+// Close closes the connection to the repository. The repository must not be
+// used afterwards.
+func (r *Repository) Close() error {
+	return r.repo.Close()
+}
+
+// lock locks the repository. The returned context is canceled if the lock
+// cannot be refreshed, all further operations must use it.
+func (r *Repository) lock(ctx context.Context, exclusive bool) (context.Context, func(), error) {
+	lock, ctx, err := repository.Lock(ctx, r.repo, exclusive, 0, func(string) {}, r.opts.warnf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, lock.Unlock, nil
+}
+
+func (r *Repository) useCache() {
+	if r.opts.NoCache {
+		return
+	}
+
+	c, err := cache.New(r.repo.Config().ID, r.opts.CacheDir)
+	if err != nil {
+		r.opts.warnf("unable to open cache: %v", err)
+		return
+	}
+	r.repo.UseCache(c)
+}
+
+func newRepository(be backend.Backend, opts Options) (*repository.Repository, error) {
+	var compression repository.CompressionMode
+	if opts.Compression != "" {
+		if err := compression.Set(opts.Compression); err != nil {
+			return nil, err
+		}
+	}
+
+	return repository.New(be, repository.Options{
+		Compression:          compression,
+		PackSize:             opts.PackSize * 1024 * 1024,
+		PackTransformOptions: opts.Extended,
+	})
+}
+
+// backends contains all backends which are supported by the restic command.
+var backends = func() *location.Registry {
+	registry := location.NewRegistry()
+	for _, factory := range []location.Factory{
+		azure.NewFactory(),
+		b2.NewFactory(),
+		gdrive.NewFactory(),
+		gs.NewFactory(),
+		local.NewFactory(),
+		rclone.NewFactory(),
+		rest.NewFactory(),
+		s3.NewFactory(),
+		sftp.NewFactory(),
+		smb.NewFactory(),
+		swift.NewFactory(),
+	} {
+		registry.Register(factory)
+	}
+	return registry
+}()
+
+func openBackend(ctx context.Context, opts Options, create bool) (backend.Backend, error) {
+	loc, err := location.Parse(backends, opts.Location)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repository location failed: %w", err)
+	}
+
+	cfg := loc.Config
+	if cfg, ok := cfg.(backend.ApplyEnvironmenter); ok {
+		cfg.ApplyEnvironment("")
+	}
+	extended := options.Options(opts.Extended).Extract(loc.Scheme)
+	if err := extended.Apply(loc.Scheme, cfg); err != nil {
+		return nil, err
+	}
+
+	rt, err := backend.Transport(backend.TransportOptions{})
+	if err != nil {
+		return nil, err
+	}
+	lim := limiter.NewStaticLimiter(limiter.Limits{})
+
+	factory := backends.Lookup(loc.Scheme)
+	debug.Log("opening %v repository", loc.Scheme)
+	var be backend.Backend
+	if create {
+		be, err = factory.Create(ctx, cfg, rt, lim)
+	} else {
+		be, err = factory.Open(ctx, cfg, rt, lim)
+	}
+	if errors.Is(err, backend.ErrNoRepository) {
+		return nil, fmt.Errorf("%w at %v: %v", ErrNoRepository, location.StripPassword(backends, opts.Location), err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open repository at %v: %w", location.StripPassword(backends, opts.Location), err)
+	}
+
+	be = logger.New(sema.NewBackend(be))
+	report := func(msg string, err error, d time.Duration) {
+		if d >= 0 {
+			opts.warnf("%v returned error, retrying after %v: %v", msg, d, err)
+		} else {
+			opts.warnf("%v failed: %v", msg, err)
+		}
+	}
+	success := func(msg string, retries int) {
+		opts.warnf("%v operation successful after %d retries", msg, retries)
+	}
+	return retry.New(be, 15*time.Minute, report, success), nil
+}
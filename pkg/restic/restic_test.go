@@ -0,0 +1,186 @@
+package restic
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testOptions(t *testing.T) Options {
+	repository.TestUseLowSecurityKDFParameters(t)
+	return Options{
+		Location: filepath.Join(t.TempDir(), "repo"),
+		Password: "secret",
+		NoCache:  true,
+	}
+}
+
+func TestBackupRestoreForget(t *testing.T) {
+	ctx := context.Background()
+	opts := testOptions(t)
+
+	repo, err := Init(ctx, opts)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Close())
+
+	repo, err = Open(ctx, opts)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, repo.Close())
+	}()
+
+	source := t.TempDir()
+	data := rtest.Random(23, 100000)
+	rtest.OK(t, os.Mkdir(filepath.Join(source, "sub"), 0o755))
+	rtest.OK(t, os.WriteFile(filepath.Join(source, "sub", "file"), data, 0o644))
+	rtest.OK(t, os.WriteFile(filepath.Join(source, "excluded"), data, 0o644))
+
+	first, err := repo.Backup(ctx, []string{source}, BackupOptions{
+		Host:     "example",
+		Tags:     []string{"foo"},
+		Excludes: []string{"excluded"},
+		Time:     time.Now().Add(-time.Hour),
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(1), first.FilesNew)
+	rtest.Equals(t, 0, len(first.Warnings))
+
+	second, err := repo.Backup(ctx, []string{source}, BackupOptions{Host: "example"})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(1), second.FilesUnmodified)
+
+	snapshots, err := repo.Snapshots(ctx, SnapshotFilter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(snapshots))
+	rtest.Equals(t, first.SnapshotID, snapshots[0].ID)
+	rtest.Equals(t, second.SnapshotID, snapshots[1].ID)
+	rtest.Equals(t, first.SnapshotID, snapshots[1].Parent)
+
+	snapshots, err = repo.Snapshots(ctx, SnapshotFilter{Tags: []string{"foo"}})
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(snapshots))
+	rtest.Equals(t, []string{"foo"}, snapshots[0].Tags)
+
+	target := t.TempDir()
+	summary, err := repo.Restore(ctx, first.SnapshotID[:8], target, RestoreOptions{})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(1), summary.FilesRestored)
+	buf, err := os.ReadFile(filepath.Join(target, source, "sub", "file"))
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+	_, err = os.Stat(filepath.Join(target, source, "excluded"))
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "excluded file was restored: %v", err)
+
+	result, err := repo.Forget(ctx, ForgetOptions{Policy: ForgetPolicy{KeepLast: 1}, DryRun: true})
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(result.Remove))
+	snapshots, err = repo.Snapshots(ctx, SnapshotFilter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(snapshots))
+
+	result, err = repo.Forget(ctx, ForgetOptions{Policy: ForgetPolicy{KeepLast: 1}})
+	rtest.OK(t, err)
+	rtest.Equals(t, second.SnapshotID, result.Keep[0].ID)
+	rtest.Equals(t, first.SnapshotID, result.Remove[0].ID)
+	snapshots, err = repo.Snapshots(ctx, SnapshotFilter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(snapshots))
+	rtest.Equals(t, second.SnapshotID, snapshots[0].ID)
+
+	_, err = repo.Forget(ctx, ForgetOptions{})
+	rtest.Assert(t, err != nil, "forget without policy did not fail")
+}
+
+func TestOpenErrors(t *testing.T) {
+	ctx := context.Background()
+	opts := testOptions(t)
+
+	_, err := Open(ctx, opts)
+	rtest.Assert(t, errors.Is(err, ErrNoRepository), "unexpected error %v", err)
+
+	repo, err := Init(ctx, opts)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Close())
+
+	opts.Password = "wrong"
+	_, err = Open(ctx, opts)
+	rtest.Assert(t, err != nil, "wrong password accepted")
+
+	opts.Location = "invalid:foo"
+	_, err = Open(ctx, opts)
+	rtest.Assert(t, err != nil, "invalid location accepted")
+}
+
+func TestPackTransforms(t *testing.T) {
+	ctx := context.Background()
+	opts := testOptions(t)
+	keyFile := filepath.Join(t.TempDir(), "sign.key")
+	rtest.OK(t, os.WriteFile(keyFile, rtest.Random(42, 32), 0o600))
+	opts.PackTransforms = []string{"sign"}
+
+	_, err := Init(ctx, opts)
+	rtest.Assert(t, err != nil, "missing transform options accepted")
+
+	opts.Extended = map[string]string{"transform.sign.key-file": keyFile}
+	repo, err := Init(ctx, opts)
+	rtest.OK(t, err)
+	source := t.TempDir()
+	rtest.OK(t, os.WriteFile(filepath.Join(source, "file"), rtest.Random(23, 1000), 0o644))
+	_, err = repo.Backup(ctx, []string{source}, BackupOptions{Host: "example"})
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Close())
+
+	// the transforms are taken from the config, only their options are required
+	opts.PackTransforms = nil
+	repo, err = Open(ctx, opts)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, repo.Close())
+	}()
+	rtest.Equals(t, []string{"sign"}, repo.repo.Config().PackTransforms)
+	snapshots, err := repo.Snapshots(ctx, SnapshotFilter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(snapshots))
+	_, err = repo.Restore(ctx, snapshots[0].ID, t.TempDir(), RestoreOptions{})
+	rtest.OK(t, err)
+}
+
+func TestForgetHold(t *testing.T) {
+	ctx := context.Background()
+	opts := testOptions(t)
+	opts.PackTransforms = []string{"sign"}
+	keyFile := filepath.Join(t.TempDir(), "sign.key")
+	rtest.OK(t, os.WriteFile(keyFile, rtest.Random(42, 32), 0o600))
+	opts.Extended = map[string]string{"transform.sign.key-file": keyFile}
+
+	repo, err := Init(ctx, opts)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, repo.Close())
+	}()
+	source := t.TempDir()
+	rtest.OK(t, os.WriteFile(filepath.Join(source, "file"), rtest.Random(23, 1000), 0o644))
+	result, err := repo.Backup(ctx, []string{source}, BackupOptions{Host: "example"})
+	rtest.OK(t, err)
+	_, err = restic.PlaceHold(ctx, repo.repo, "litigation")
+	rtest.OK(t, err)
+
+	forget := ForgetOptions{Snapshots: []string{result.SnapshotID}}
+	_, err = repo.Forget(ctx, forget)
+	rtest.Assert(t, errors.Is(err, ErrHold), "unexpected error %v", err)
+
+	forget.OverrideHold = "approved"
+	_, err = repo.Forget(ctx, forget)
+	rtest.OK(t, err)
+	hold, err := restic.LoadHold(ctx, repo.repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(hold.Overrides))
+	rtest.Equals(t, "approved", hold.Overrides[0].Justification)
+}
@@ -0,0 +1,117 @@
+package restic
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/filter"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/restorer"
+)
+
+// RestoreOptions configure a restore.
+type RestoreOptions struct {
+	// Includes contains patterns for files and directories which are
+	// restored, see the --include option of the restic command. If empty,
+	// everything is restored.
+	Includes []string
+	// Excludes contains patterns for files and directories which are not
+	// restored, see the --exclude option of the restic command. Includes and
+	// Excludes cannot be used at the same time.
+	Excludes []string
+	// Overwrite is one of "always", "if-changed", "if-newer" or "never" and
+	// specifies how existing files are handled. If empty, "always" is used.
+	Overwrite string
+	// Sparse restores files as sparse files if possible.
+	Sparse bool
+}
+
+// RestoreSummary describes a completed restore.
+type RestoreSummary struct {
+	// FilesRestored is the number of restored files.
+	FilesRestored uint64
+	// Warnings lists problems which did not prevent the restore, like items
+	// whose name only differs in case from another item.
+	Warnings []string
+}
+
+// Restore restores the snapshot with the given ID to the target directory.
+// The ID can be shortened, "latest" selects the newest snapshot. A subfolder
+// of the snapshot can be restored using the syntax "ID:path/to/folder".
+func (r *Repository) Restore(ctx context.Context, snapshotID string, target string, opts RestoreOptions) (*RestoreSummary, error) {
+	if len(opts.Includes) > 0 && len(opts.Excludes) > 0 {
+		return nil, errors.New("includes and excludes cannot be used at the same time")
+	}
+	if target == "" {
+		return nil, errors.New("no target directory specified")
+	}
+	if err := filter.ValidatePatterns(opts.Includes); err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if err := filter.ValidatePatterns(opts.Excludes); err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+
+	var overwrite restorer.OverwriteBehavior
+	if opts.Overwrite != "" {
+		if err := overwrite.Set(opts.Overwrite); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, unlock, err := r.lock(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	sn, subfolder, err := (&restic.SnapshotFilter{}).FindLatest(ctx, r.repo, r.repo, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find snapshot: %w", err)
+	}
+
+	if err := r.repo.LoadIndex(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	sn.Tree, err = restic.FindTreeDirectory(ctx, r.repo, sn.Tree, subfolder)
+	if err != nil {
+		return nil, err
+	}
+
+	res := restorer.NewRestorer(r.repo, sn, restorer.Options{
+		Sparse:    opts.Sparse,
+		Overwrite: overwrite,
+	})
+
+	var m sync.Mutex
+	var summary RestoreSummary
+	res.Warn = func(message string) {
+		m.Lock()
+		summary.Warnings = append(summary.Warnings, message)
+		m.Unlock()
+	}
+
+	switch {
+	case len(opts.Excludes) > 0:
+		reject := filter.RejectByPattern(opts.Excludes, r.opts.warnf)
+		res.SelectFilter = func(item string, isDir bool) (bool, bool) {
+			selected := !reject(item)
+			return selected, selected && isDir
+		}
+	case len(opts.Includes) > 0:
+		include := filter.IncludeByPattern(opts.Includes, r.opts.warnf)
+		res.SelectFilter = func(item string, isDir bool) (bool, bool) {
+			selected, childMayBeSelected := include(item)
+			return selected, childMayBeSelected && isDir
+		}
+	}
+
+	summary.FilesRestored, err = res.RestoreTo(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
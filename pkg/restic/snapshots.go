@@ -0,0 +1,104 @@
+package restic
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// Snapshot describes a snapshot stored in the repository.
+type Snapshot struct {
+	// ID is the full ID of the snapshot, the first eight characters can be
+	// used as a short ID.
+	ID       string
+	Time     time.Time
+	Hostname string
+	Username string
+	Paths    []string
+	Tags     []string
+	// Parent is the ID of the snapshot used as parent, it is empty if no
+	// parent was used.
+	Parent string
+}
+
+func newSnapshot(sn *restic.Snapshot) Snapshot {
+	s := Snapshot{
+		ID:       sn.ID().String(),
+		Time:     sn.Time,
+		Hostname: sn.Hostname,
+		Username: sn.Username,
+		Paths:    sn.Paths,
+		Tags:     sn.Tags,
+	}
+	if sn.Parent != nil {
+		s.Parent = sn.Parent.String()
+	}
+	return s
+}
+
+// SnapshotFilter selects snapshots. A snapshot matches if it matches all
+// fields which are set.
+type SnapshotFilter struct {
+	// Hosts matches snapshots created on one of the hosts.
+	Hosts []string
+	// Tags matches snapshots which have all of the tags.
+	Tags []string
+	// Paths matches snapshots which contain exactly these paths.
+	Paths []string
+}
+
+func (f SnapshotFilter) internal() *restic.SnapshotFilter {
+	filter := &restic.SnapshotFilter{
+		Hosts: f.Hosts,
+		Paths: f.Paths,
+	}
+	if len(f.Tags) > 0 {
+		filter.Tags = restic.TagLists{f.Tags}
+	}
+	return filter
+}
+
+// Snapshots returns the snapshots which match the filter, sorted from oldest
+// to newest.
+func (r *Repository) Snapshots(ctx context.Context, filter SnapshotFilter) ([]Snapshot, error) {
+	ctx, unlock, err := r.lock(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	snapshots, err := r.findSnapshots(ctx, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Snapshot, 0, len(snapshots))
+	for _, sn := range snapshots {
+		result = append(result, newSnapshot(sn))
+	}
+	return result, nil
+}
+
+// findSnapshots loads the snapshots which match the filter or, if ids is not
+// empty, the snapshots with these IDs. The snapshots are sorted from oldest to
+// newest.
+func (r *Repository) findSnapshots(ctx context.Context, filter SnapshotFilter, ids []string) (restic.Snapshots, error) {
+	var snapshots restic.Snapshots
+	err := filter.internal().FindAll(ctx, r.repo, r.repo, ids, func(_ string, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, sn)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}